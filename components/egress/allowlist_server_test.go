@@ -0,0 +1,99 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alibaba/opensandbox/egress/pkg/dnsproxy"
+	"github.com/alibaba/opensandbox/egress/pkg/policy"
+)
+
+func getAllowlist(t *testing.T, proxy *dnsproxy.Proxy) policy.Allowlist {
+	t.Helper()
+	w := httptest.NewRecorder()
+	allowlistHandler(proxy)(w, httptest.NewRequest(http.MethodGet, "/allowlist", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /allowlist: status %d: %s", w.Code, w.Body)
+	}
+	var list policy.Allowlist
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode allowlist: %v", err)
+	}
+	return list
+}
+
+func TestAllowlist_FollowsPolicyReloads(t *testing.T) {
+	proxy := &dnsproxy.Proxy{}
+	loaded, err := policy.ParsePolicyFrom("env", `{"defaultAction":"deny","egress":[
+		{"action":"allow","target":"*.pypi.org"},
+		{"action":"allow","target":"github.com"},
+		{"action":"deny","target":"upload.pypi.org"}
+	],"block":["ads.example"]}`)
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	proxy.UpdatePolicy(loaded)
+
+	list := getAllowlist(t, proxy)
+	if list.DefaultAction != policy.ActionDeny ||
+		!slices.Equal(list.Allow, []string{"github.com", "*.pypi.org"}) ||
+		!slices.Equal(list.Deny, []string{"upload.pypi.org", "*.ads.example", "ads.example"}) {
+		t.Fatalf("unexpected allowlist for the loaded policy: %+v", list)
+	}
+
+	server := &policyServer{proxy: proxy}
+	w := httptest.NewRecorder()
+	server.handlePolicy(w, httptest.NewRequest(http.MethodPost, "/policy", strings.NewReader(`{"defaultAction":"deny","egress":[{"action":"allow","target":"example.com"}]}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /policy: status %d: %s", w.Code, w.Body)
+	}
+	list = getAllowlist(t, proxy)
+	if !slices.Equal(list.Allow, []string{"example.com"}) || len(list.Deny) != 0 {
+		t.Fatalf("expected the allowlist of the reloaded policy, got %+v", list)
+	}
+
+	w = httptest.NewRecorder()
+	server.handlePolicy(w, httptest.NewRequest(http.MethodPost, "/policy", nil))
+	if list = getAllowlist(t, proxy); list.DefaultAction != policy.ActionDeny || len(list.Allow) != 0 {
+		t.Fatalf("expected nothing allowed after a reset, got %+v", list)
+	}
+}
+
+func TestAllowlist_RejectsWrites(t *testing.T) {
+	w := httptest.NewRecorder()
+	allowlistHandler(&dnsproxy.Proxy{})(w, httptest.NewRequest(http.MethodPost, "/allowlist", strings.NewReader("{}")))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", w.Code)
+	}
+}
+
+func TestCheckLoopback(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:18081", "[::1]:18081", "localhost:18081"} {
+		if err := checkLoopback(addr); err != nil {
+			t.Errorf("%s: %v", addr, err)
+		}
+	}
+	for _, addr := range []string{":18081", "0.0.0.0:18081", "10.0.0.7:18081", "127.0.0.1"} {
+		if err := checkLoopback(addr); err == nil {
+			t.Errorf("%s: expected an error", addr)
+		}
+	}
+}
@@ -4,12 +4,12 @@ go 1.24.0
 
 require (
 	github.com/miekg/dns v1.1.61
+	golang.org/x/net v0.26.0
 	golang.org/x/sys v0.21.0
 )
 
 require (
 	golang.org/x/mod v0.18.0 // indirect
-	golang.org/x/net v0.26.0 // indirect
 	golang.org/x/sync v0.7.0 // indirect
 	golang.org/x/tools v0.22.0 // indirect
 )
@@ -16,11 +16,17 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
+	"github.com/alibaba/opensandbox/egress/pkg/connlog"
 	"github.com/alibaba/opensandbox/egress/pkg/dnsproxy"
 	"github.com/alibaba/opensandbox/egress/pkg/iptables"
 	"github.com/alibaba/opensandbox/egress/pkg/policy"
@@ -28,6 +34,13 @@ import (
 
 // Linux MVP: DNS proxy + iptables REDIRECT. No nftables/full isolation yet.
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "test-policy" {
+		os.Exit(runTestPolicy(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "wait-ready" {
+		os.Exit(runWaitReady(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
@@ -44,22 +57,281 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to init dns proxy: %v", err)
 	}
-	if err := proxy.Start(ctx); err != nil {
-		log.Fatalf("failed to start dns proxy: %v", err)
+	if source := os.Getenv(policy.EgressUpstreamSourceEnv); source != "" {
+		if err := proxy.SetUpstreamSource(source); err != nil {
+			log.Fatalf("invalid %s: %v", policy.EgressUpstreamSourceEnv, err)
+		}
+		log.Printf("upstream dns queries will egress from %s", source)
 	}
-	log.Println("dns proxy started on 127.0.0.1:15353")
-
-	if err := iptables.SetupRedirect(15353); err != nil {
-		log.Fatalf("failed to install iptables redirect: %v", err)
+	if raw := os.Getenv(policy.EgressUpstreamSOCKS5Env); raw != "" {
+		if err := proxy.SetUpstreamSOCKS5(raw); err != nil {
+			log.Fatalf("invalid %s: %v", policy.EgressUpstreamSOCKS5Env, err)
+		}
+		log.Printf("upstream dns queries will go over tcp through socks5 proxy %s", proxy.UpstreamSOCKS5())
+	}
+	if sinkhole := os.Getenv(policy.EgressSinkholeEnv); sinkhole != "" {
+		if err := proxy.SetSinkhole(sinkhole); err != nil {
+			log.Fatalf("invalid %s: %v", policy.EgressSinkholeEnv, err)
+		}
+		log.Printf("blocked names will resolve to sinkhole %s", sinkhole)
+	}
+	if raw := os.Getenv(policy.EgressMinTTLEnv); raw != "" {
+		minTTL, err := dnsproxy.ParseMinTTL(raw)
+		if err == nil {
+			err = proxy.SetMinTTL(minTTL)
+		}
+		if err != nil {
+			log.Fatalf("invalid %s: %v", policy.EgressMinTTLEnv, err)
+		}
+		log.Printf("answer ttls below %ds will be raised to %ds", minTTL, minTTL)
+	}
+	if raw := os.Getenv(policy.EgressECSEnv); raw != "" {
+		ecs, err := dnsproxy.ParseECS(raw)
+		if err == nil {
+			err = proxy.SetECS(ecs)
+		}
+		if err != nil {
+			log.Fatalf("invalid %s: %v", policy.EgressECSEnv, err)
+		}
+		if ecs.Mode == dnsproxy.ECSSet {
+			log.Printf("upstream dns queries will carry client subnet %s", ecs.Subnet)
+		} else {
+			log.Printf("client subnet of upstream dns queries: %s", ecs.Mode)
+		}
+	}
+	if classes := os.Getenv(policy.EgressAllowedClassesEnv); classes != "" {
+		if err := proxy.SetAllowedClasses(classes); err != nil {
+			log.Fatalf("invalid %s: %v", policy.EgressAllowedClassesEnv, err)
+		}
+		log.Printf("dns classes %s will be forwarded besides IN", classes)
+	}
+	if raw := os.Getenv(policy.EgressQNameMinimizationEnv); raw != "" {
+		enabled, err := dnsproxy.ParseQNameMinimization(raw)
+		if err != nil {
+			log.Fatalf("invalid %s: %v", policy.EgressQNameMinimizationEnv, err)
+		}
+		proxy.SetQNameMinimization(enabled)
+		if enabled {
+			log.Printf("upstream queries will use qname minimization")
+		}
+	}
+	if raw := os.Getenv(policy.EgressStickyUpstreamEnv); raw != "" {
+		enabled, err := dnsproxy.ParseStickyUpstream(raw)
+		if err != nil {
+			log.Fatalf("invalid %s: %v", policy.EgressStickyUpstreamEnv, err)
+		}
+		proxy.SetStickyUpstream(enabled)
+		if enabled {
+			log.Printf("queries will stick to the upstream that last answered their name, among %v", proxy.Upstreams())
+		}
+	}
+	if raw := os.Getenv(policy.EgressLatencyUpstreamEnv); raw != "" {
+		enabled, err := dnsproxy.ParseLatencyUpstream(raw)
+		if err != nil {
+			log.Fatalf("invalid %s: %v", policy.EgressLatencyUpstreamEnv, err)
+		}
+		proxy.SetLatencyUpstream(enabled)
+		if enabled {
+			log.Printf("queries will go to the fastest healthy upstream among %v", proxy.Upstreams())
+		}
+	}
+	if raw := os.Getenv(policy.EgressDNSCacheEnv); raw != "" {
+		enabled, err := dnsproxy.ParseCache(raw)
+		if err != nil {
+			log.Fatalf("invalid %s: %v", policy.EgressDNSCacheEnv, err)
+		}
+		proxy.SetCache(enabled)
+		if enabled {
+			log.Printf("upstream answers will be cached until their ttl expires")
+		}
+	}
+	if raw := os.Getenv(policy.EgressDNSCacheStaleEnv); raw != "" {
+		window, err := dnsproxy.ParseCacheStaleWindow(raw)
+		if err == nil {
+			err = proxy.SetCacheStaleWindow(window)
+		}
+		if err != nil {
+			log.Fatalf("invalid %s: %v", policy.EgressDNSCacheStaleEnv, err)
+		}
+		if window > 0 {
+			log.Printf("expired cached answers will be served for up to %ds while they are refreshed", window)
+		}
+	}
+	if raw := os.Getenv(policy.EgressDNSCacheSizeEnv); raw != "" {
+		entries, err := dnsproxy.ParseCacheSize(raw)
+		if err == nil {
+			err = proxy.SetCacheSize(entries)
+		}
+		if err != nil {
+			log.Fatalf("invalid %s: %v", policy.EgressDNSCacheSizeEnv, err)
+		}
+		log.Printf("answer cache will hold up to %d entries", entries)
+	}
+	if raw := os.Getenv(policy.EgressDNSQueryTypeRulesEnv); raw != "" {
+		if err := proxy.SetQueryTypeRules(raw); err != nil {
+			log.Fatalf("invalid %s: %v", policy.EgressDNSQueryTypeRulesEnv, err)
+		}
+		log.Printf("per query type dns rules: %s", raw)
+	}
+	if raw := os.Getenv(policy.EgressDNSListenersEnv); raw != "" {
+		listeners, err := dnsproxy.ParseListeners(raw)
+		if err != nil {
+			log.Fatalf("invalid %s: %v", policy.EgressDNSListenersEnv, err)
+		}
+		for _, l := range listeners {
+			if err := proxy.AddListener(l); err != nil {
+				log.Fatalf("invalid %s: %v", policy.EgressDNSListenersEnv, err)
+			}
+			source := "the shared policy"
+			if l.Policy != nil {
+				source = "policy " + l.Policy.Source()
+			}
+			log.Printf("dns listener %s on %s decides with %s", l.Label, l.Addr, source)
+		}
+	}
+	if err := applyListenOptions(proxy); err != nil {
+		log.Fatalf("invalid dns listen options: %v", err)
+	}
+	deathAction, err := dnsproxy.ParseDeathAction(os.Getenv(policy.ProxyDeathActionEnv))
+	if err != nil {
+		log.Fatalf("invalid %s: %v", policy.ProxyDeathActionEnv, err)
+	}
+	coexistence, err := iptables.ParseCoexistence(os.Getenv(policy.EgressIptablesCoexistenceEnv))
+	if err != nil {
+		log.Fatalf("invalid %s: %v", policy.EgressIptablesCoexistenceEnv, err)
+	}
+	if err := loadQueryLogSampling(proxy); err != nil {
+		log.Fatalf("invalid query log sampling: %v", err)
+	}
+	reloadQueryLogOnHangup(ctx, proxy)
+	// allowed answers are handed to each of these before they reach the client
+	var onResolve []func(dnsproxy.ResolveEvent)
+	var connLogger *connlog.Logger
+	if raw := os.Getenv(policy.EgressConnectionLogEnv); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			log.Fatalf("invalid %s: %v", policy.EgressConnectionLogEnv, err)
+		}
+		if enabled {
+			var learn func(dnsproxy.ResolveEvent)
+			connLogger, learn, err = newConnectionLogger()
+			if err != nil {
+				log.Fatalf("invalid %s: %v", policy.EgressConnectionLogRateEnv, err)
+			}
+			onResolve = append(onResolve, learn)
+		}
+	}
+	var enforcer *iptables.Enforcer
+	var byteMeter *iptables.ByteMeter
+	if raw := os.Getenv(policy.EgressEnforceEnv); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			log.Fatalf("invalid %s: %v", policy.EgressEnforceEnv, err)
+		}
+		if enabled {
+			enforcer = iptables.NewEnforcer()
+			if raw := os.Getenv(policy.EgressEnforceTTLEnv); raw != "" {
+				ttl, err := iptables.ParseEnforceTTL(raw)
+				if err != nil {
+					log.Fatalf("invalid %s: %v", policy.EgressEnforceTTLEnv, err)
+				}
+				enforcer.SetTTL(ttl)
+				if ttl > 0 {
+					log.Printf("enforce mode: resolved addresses stay allowed for %s, whatever their answer ttl", ttl)
+				}
+			}
+			onResolve = append(onResolve, func(ev dnsproxy.ResolveEvent) {
+				if err := enforcer.Allow(ev.Name, ev.Addrs, time.Duration(ev.TTL)*time.Second); err != nil {
+					log.Printf("enforce mode: addresses of %s not allowed: %v", ev.Name, err)
+				}
+			})
+		}
+	}
+	if raw := os.Getenv(policy.EgressByteMetricsEnv); raw != "" {
+		topN, err := iptables.ParseByteMetrics(raw)
+		if err != nil {
+			log.Fatalf("invalid %s: %v", policy.EgressByteMetricsEnv, err)
+		}
+		switch {
+		case topN == 0:
+		case enforcer == nil:
+			log.Printf("WARNING: %s ignored: bytes are counted by the enforce mode rules, set %s=true", policy.EgressByteMetricsEnv, policy.EgressEnforceEnv)
+		default:
+			byteMeter = iptables.NewByteMeter(iptables.NewByteCounter(), enforcer.Domains, topN)
+			log.Printf("enforce mode: counting bytes sent per domain, reporting the top %d domains", topN)
+		}
+	}
+	if enforcer != nil && connLogger != nil {
+		enforcer.LogBlocked(connLogGroup)
+	}
+	if len(onResolve) > 0 {
+		proxy.SetResolveHandler(func(ev dnsproxy.ResolveEvent) {
+			for _, fn := range onResolve {
+				fn(ev)
+			}
+		})
+	}
+	ready := newReadiness(os.Getenv(policy.EgressReadyFileEnv))
+	// a sentinel left by a previous run must not let workloads through early
+	if err := ready.MarkNotReady(); err != nil {
+		log.Fatalf("%v", err)
+	}
+	startProxy := func() error {
+		if err := proxy.Start(ctx); err != nil {
+			return err
+		}
+		log.Println("dns proxy started on 127.0.0.1:15353")
+		return nil
+	}
+	installRedirect := func() error {
+		foreign, installed, err := iptables.SetupRedirectCoexisting(15353, coexistence)
+		if err != nil {
+			var cmdErr *iptables.CommandError
+			if errors.As(err, &cmdErr) {
+				if diag, mErr := json.Marshal(cmdErr); mErr == nil {
+					log.Printf("iptables diagnostics: %s", diag)
+				}
+			}
+			return err
+		}
+		for _, rule := range foreign {
+			log.Printf("found nat OUTPUT redirect of another tool: %s", rule)
+		}
+		if installed {
+			log.Printf("iptables redirect configured (OUTPUT 53 -> 15353, %s other redirects) with SO_MARK bypass for proxy upstream traffic", coexistence)
+			startWatchdog(ctx, proxy, deathAction, coexistence, enforcer, ready)
+		} else {
+			log.Printf("WARNING: iptables redirect skipped (%s=%s) because other tools redirect traffic; DNS does not go through the egress policy",
+				policy.EgressIptablesCoexistenceEnv, coexistence)
+		}
+		if enforcer != nil {
+			if !installed {
+				// without the redirect no answer is seen, so everything would be dropped
+				return fmt.Errorf("%s needs the dns redirect, which was skipped", policy.EgressEnforceEnv)
+			}
+			startEnforcer(ctx, proxy, enforcer)
+		}
+		return nil
+	}
+	if err := bringUp(ready, startProxy, installRedirect); err != nil {
+		log.Fatalf("%v", err)
+	}
+	if ready.file != "" {
+		log.Printf("egress ready; wrote %s", ready.file)
+	}
+	if byteMeter != nil {
+		startByteMeter(ctx, byteMeter)
+	}
+	if connLogger != nil {
+		startConnectionLog(ctx, connLogger)
 	}
-	log.Printf("iptables redirect configured (OUTPUT 53 -> 15353) with SO_MARK bypass for proxy upstream traffic")
 
 	httpAddr := os.Getenv(policy.EgressServerAddrEnv)
 	if httpAddr == "" {
 		httpAddr = policy.DefaultEgressServerAddr
 	}
 	token := os.Getenv(policy.EgressAuthTokenEnv)
-	if err := startPolicyServer(ctx, proxy, httpAddr, token); err != nil {
+	if err := startPolicyServer(ctx, proxy, enforcer, byteMeter, ready, httpAddr, token); err != nil {
 		log.Fatalf("failed to start policy server: %v", err)
 	}
 	if token == "" {
@@ -67,8 +339,195 @@ func main() {
 	} else {
 		log.Printf("policy server listening on %s (POST /policy) with token auth", httpAddr)
 	}
+	if addr := os.Getenv(policy.EgressAllowlistAddrEnv); addr != "" {
+		if err := startAllowlistServer(ctx, proxy, addr); err != nil {
+			log.Fatalf("failed to start allowlist server: %v", err)
+		}
+		log.Printf("allowlist server listening on %s (GET /allowlist)", addr)
+	}
 
 	<-ctx.Done()
 	log.Println("received shutdown signal; exiting")
+	if err := ready.MarkNotReady(); err != nil {
+		log.Printf("%v", err)
+	}
 	_ = os.Stderr.Sync()
 }
+
+// startWatchdog reacts to the proxy dying while the redirect is installed, so
+// the sandbox is not left with DNS silently black-holed. Failing open also
+// removes enforce mode, which only lets through what the proxy resolved, and
+// the sidecar is no longer ready.
+func startWatchdog(ctx context.Context, proxy *dnsproxy.Proxy, action dnsproxy.DeathAction, coexistence iptables.Coexistence, enforcer *iptables.Enforcer, ready *readiness) {
+	w := &dnsproxy.Watchdog{
+		Action: action,
+		FailOpen: func() error {
+			err := errors.Join(ready.MarkNotReady(), iptables.RemoveRedirectCoexisting(15353, coexistence))
+			if enforcer != nil {
+				err = errors.Join(err, enforcer.Remove())
+			}
+			return err
+		},
+		Exit: func(code int) {
+			_ = os.Stderr.Sync()
+			os.Exit(code)
+		},
+	}
+	go w.Watch(ctx, proxy)
+	log.Printf("dns proxy watchdog armed; action on proxy death: %s", action)
+}
+
+// applyListenOptions sets the proxy's socket options from the environment.
+func applyListenOptions(proxy *dnsproxy.Proxy) error {
+	var opts dnsproxy.ListenOptions
+	var err error
+	if opts.UDPReadBuffer, err = dnsproxy.ParseListenCount(os.Getenv(policy.EgressDNSUDPReadBufferEnv)); err != nil {
+		return fmt.Errorf("%s: %w", policy.EgressDNSUDPReadBufferEnv, err)
+	}
+	if opts.TCPBacklog, err = dnsproxy.ParseListenCount(os.Getenv(policy.EgressDNSTCPBacklogEnv)); err != nil {
+		return fmt.Errorf("%s: %w", policy.EgressDNSTCPBacklogEnv, err)
+	}
+	if opts.Workers, err = dnsproxy.ParseListenCount(os.Getenv(policy.EgressDNSWorkersEnv)); err != nil {
+		return fmt.Errorf("%s: %w", policy.EgressDNSWorkersEnv, err)
+	}
+	if raw := os.Getenv(policy.EgressDNSReusePortEnv); raw != "" {
+		if opts.ReusePort, err = strconv.ParseBool(raw); err != nil {
+			return fmt.Errorf("%s: %w", policy.EgressDNSReusePortEnv, err)
+		}
+	}
+	if opts == (dnsproxy.ListenOptions{}) {
+		return nil
+	}
+	if err := proxy.SetListenOptions(opts); err != nil {
+		return err
+	}
+	log.Printf("dns listen options: udp read buffer %d, tcp backlog %d, reuse port %t, workers %d (0 = default)",
+		opts.UDPReadBuffer, opts.TCPBacklog, opts.ReusePort, opts.Workers)
+	return nil
+}
+
+// loadQueryLogSampling applies the query log sampling from the environment,
+// or from the sampling file when one is configured.
+func loadQueryLogSampling(proxy *dnsproxy.Proxy) error {
+	sampling, err := dnsproxy.ParseQueryLogSampling(os.Getenv(policy.EgressQueryLogSampleEnv), os.Getenv(policy.EgressQueryLogRateEnv))
+	if path := os.Getenv(policy.EgressQueryLogFileEnv); path != "" && err == nil {
+		sampling, err = dnsproxy.ReadQueryLogSampling(path)
+	}
+	if err != nil {
+		return err
+	}
+	proxy.SetQueryLogSampling(sampling)
+	log.Printf("allowed dns queries logged: %s", sampling)
+	return nil
+}
+
+// reloadQueryLogOnHangup re-reads the query log sampling on SIGHUP. A bad
+// value is reported and the previous sampling kept.
+func reloadQueryLogOnHangup(ctx context.Context, proxy *dnsproxy.Proxy) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				if err := loadQueryLogSampling(proxy); err != nil {
+					log.Printf("query log sampling not reloaded, keeping %s: %v", proxy.QueryLogSampling(), err)
+				}
+			}
+		}
+	}()
+}
+
+// connLogGroup is the NFLOG group new outbound connections are logged to.
+const connLogGroup = 100
+
+// newConnectionLogger returns the logger along with the resolve handler that
+// remembers the addresses of allowed answers, so logged connections can be
+// attributed to the domain they were resolved for.
+func newConnectionLogger() (*connlog.Logger, func(dnsproxy.ResolveEvent), error) {
+	rate := connlog.DefaultRate
+	if raw := os.Getenv(policy.EgressConnectionLogRateEnv); raw != "" {
+		var err error
+		if rate, err = connlog.ParseRate(raw); err != nil {
+			return nil, nil, err
+		}
+	}
+	resolutions := connlog.NewResolutions(0)
+	learn := func(ev dnsproxy.ResolveEvent) {
+		resolutions.Learn(ev.Name, ev.Addrs, time.Duration(ev.TTL)*time.Second)
+	}
+	return connlog.NewLogger(resolutions, rate), learn, nil
+}
+
+// startConnectionLog installs the NFLOG rule and logs connections in the
+// background. Failures only disable connection logging; DNS filtering is
+// unaffected.
+func startConnectionLog(ctx context.Context, logger *connlog.Logger) {
+	src, err := connlog.NewNflogSource(connLogGroup)
+	if err == nil {
+		err = iptables.SetupConnLog(connLogGroup)
+	}
+	if err != nil {
+		log.Printf("connection logging disabled: %v", err)
+		return
+	}
+	go func() {
+		if err := logger.Run(ctx, src); err != nil {
+			log.Printf("connection logging stopped: %v", err)
+		}
+	}()
+	log.Printf("logging new outbound connections via nflog group %d", connLogGroup)
+}
+
+// enforceExpiryInterval is how often addresses past their retention stop
+// being allowed in enforce mode.
+const enforceExpiryInterval = time.Minute
+
+// startEnforcer installs the enforce mode rules with the current policy's
+// default action and expires allowed addresses in the background.
+func startEnforcer(ctx context.Context, proxy *dnsproxy.Proxy, enforcer *iptables.Enforcer) {
+	if err := enforcer.Setup(proxy.CurrentPolicy().DefaultAction == policy.ActionDeny); err != nil {
+		log.Fatalf("failed to install enforce mode rules: %v", err)
+	}
+	log.Printf("enforce mode on: filter chain %s drops traffic to addresses no allowed answer handed out while the policy denies by default; loopback and the proxy's marked upstream queries are exempt",
+		iptables.EnforceChain)
+	go func() {
+		ticker := time.NewTicker(enforceExpiryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := enforcer.Expire(); err != nil {
+					log.Printf("enforce mode: expiring allowed addresses: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// byteScrapeInterval is how often the enforce mode rules' byte counters are
+// read into the per-domain totals.
+const byteScrapeInterval = 15 * time.Second
+
+// startByteMeter scrapes the byte counters in the background.
+func startByteMeter(ctx context.Context, meter *iptables.ByteMeter) {
+	go func() {
+		ticker := time.NewTicker(byteScrapeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := meter.Scrape(); err != nil {
+					log.Printf("enforce mode: reading byte counters: %v", err)
+				}
+			}
+		}
+	}()
+}
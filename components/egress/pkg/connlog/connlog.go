@@ -0,0 +1,140 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package connlog logs outbound connections and attributes each destination
+// address to the domain the DNS proxy resolved it for.
+package connlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	VerdictAllowed = "allowed"
+	VerdictBlocked = "blocked"
+
+	// DefaultRate is the number of connection records logged per second.
+	DefaultRate = 100
+)
+
+// Event is one outbound connection reported by a Source.
+type Event struct {
+	Time time.Time
+	// Proto is "tcp", "udp", "icmp", "icmpv6" or the IP protocol number.
+	Proto   string
+	Dst     netip.AddrPort
+	Verdict string
+}
+
+// Source reports outbound connections, e.g. from an NFLOG group.
+type Source interface {
+	// Run calls emit for each connection until ctx is done or the source fails.
+	Run(ctx context.Context, emit func(Event)) error
+}
+
+// Record is the logged form of an Event.
+type Record struct {
+	Time    time.Time `json:"time"`
+	Proto   string    `json:"proto"`
+	DstIP   string    `json:"dstIp"`
+	DstPort uint16    `json:"dstPort,omitempty"`
+	Verdict string    `json:"verdict"`
+	// Domain is the name the address was resolved for; empty when the
+	// destination was never handed out by the proxy, e.g. a hard-coded IP.
+	Domain string `json:"domain,omitempty"`
+}
+
+// Logger turns events into records, logging at most rate of them per second.
+// Records over the budget are counted and reported in a single summary line.
+type Logger struct {
+	resolutions *Resolutions
+	rate        int
+	emit        func(Record)
+	now         func() time.Time
+
+	mu          sync.Mutex
+	windowStart time.Time
+	inWindow    int
+	suppressed  int
+}
+
+// NewLogger returns a logger attributing destinations through resolutions;
+// rate of zero or less uses DefaultRate.
+func NewLogger(resolutions *Resolutions, rate int) *Logger {
+	if rate <= 0 {
+		rate = DefaultRate
+	}
+	return &Logger{resolutions: resolutions, rate: rate, emit: logRecord, now: time.Now}
+}
+
+// Run logs the events of src until it stops.
+func (l *Logger) Run(ctx context.Context, src Source) error {
+	return src.Run(ctx, l.Handle)
+}
+
+// Handle logs one event, subject to the rate limit.
+func (l *Logger) Handle(ev Event) {
+	l.mu.Lock()
+	now := l.now()
+	if now.Sub(l.windowStart) >= time.Second {
+		if l.suppressed > 0 {
+			log.Printf("[conn] suppressed %d connection records over the %d/s limit", l.suppressed, l.rate)
+		}
+		l.windowStart, l.inWindow, l.suppressed = now, 0, 0
+	}
+	if l.inWindow >= l.rate {
+		l.suppressed++
+		l.mu.Unlock()
+		return
+	}
+	l.inWindow++
+	l.mu.Unlock()
+
+	rec := Record{
+		Time:    ev.Time,
+		Proto:   ev.Proto,
+		DstIP:   ev.Dst.Addr().Unmap().String(),
+		DstPort: ev.Dst.Port(),
+		Verdict: ev.Verdict,
+	}
+	if l.resolutions != nil {
+		rec.Domain = l.resolutions.Lookup(ev.Dst.Addr())
+	}
+	l.emit(rec)
+}
+
+func logRecord(rec Record) {
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	log.Printf("[conn] %s", raw)
+}
+
+// ParseRate parses the records-per-second limit.
+func ParseRate(raw string) (int, error) {
+	rate, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || rate <= 0 {
+		return 0, fmt.Errorf("connection log rate %q is not a positive number", raw)
+	}
+	return rate, nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connlog
+
+import (
+	"context"
+	"encoding/binary"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// stubSource replays fixed events, standing in for an NFLOG group.
+type stubSource []Event
+
+func (s stubSource) Run(_ context.Context, emit func(Event)) error {
+	for _, ev := range s {
+		emit(ev)
+	}
+	return nil
+}
+
+func newTestLogger(res *Resolutions, rate int, now *time.Time) (*Logger, *[]Record) {
+	var records []Record
+	l := NewLogger(res, rate)
+	l.emit = func(r Record) { records = append(records, r) }
+	l.now = func() time.Time { return *now }
+	return l, &records
+}
+
+func TestLogger_CorrelatesDestinationsWithDomains(t *testing.T) {
+	now := time.Unix(1000, 0)
+	res := NewResolutions(0)
+	res.now = func() time.Time { return now }
+	res.Learn("API.GitHub.com.", []netip.Addr{netip.MustParseAddr("140.82.112.6")}, time.Minute)
+	res.Learn("pypi.org.", []netip.Addr{netip.MustParseAddr("2a04:4e42::223")}, time.Minute)
+
+	src := stubSource{
+		{Time: now, Proto: "tcp", Dst: netip.MustParseAddrPort("140.82.112.6:443"), Verdict: VerdictAllowed},
+		{Time: now, Proto: "tcp", Dst: netip.MustParseAddrPort("[2a04:4e42::223]:443"), Verdict: VerdictAllowed},
+		{Time: now, Proto: "udp", Dst: netip.MustParseAddrPort("[::ffff:140.82.112.6]:443"), Verdict: VerdictAllowed},
+		{Time: now, Proto: "tcp", Dst: netip.MustParseAddrPort("203.0.113.9:22"), Verdict: VerdictBlocked},
+	}
+	l, records := newTestLogger(res, 0, &now)
+	if err := l.Run(context.Background(), src); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	want := []Record{
+		{Time: now, Proto: "tcp", DstIP: "140.82.112.6", DstPort: 443, Verdict: VerdictAllowed, Domain: "api.github.com"},
+		{Time: now, Proto: "tcp", DstIP: "2a04:4e42::223", DstPort: 443, Verdict: VerdictAllowed, Domain: "pypi.org"},
+		{Time: now, Proto: "udp", DstIP: "140.82.112.6", DstPort: 443, Verdict: VerdictAllowed, Domain: "api.github.com"},
+		{Time: now, Proto: "tcp", DstIP: "203.0.113.9", DstPort: 22, Verdict: VerdictBlocked},
+	}
+	if len(*records) != len(want) {
+		t.Fatalf("expected %d records, got %+v", len(want), *records)
+	}
+	for i := range want {
+		if (*records)[i] != want[i] {
+			t.Fatalf("record %d = %+v, want %+v", i, (*records)[i], want[i])
+		}
+	}
+
+	// Attribution ends after the retention period.
+	now = now.Add(minRetention + time.Second)
+	if got := res.Lookup(netip.MustParseAddr("140.82.112.6")); got != "" {
+		t.Fatalf("expected expired resolution, got %q", got)
+	}
+}
+
+func TestLogger_RateLimit(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l, records := newTestLogger(nil, 3, &now)
+	ev := Event{Proto: "tcp", Dst: netip.MustParseAddrPort("192.0.2.1:80"), Verdict: VerdictAllowed}
+	for i := 0; i < 10; i++ {
+		l.Handle(ev)
+	}
+	if len(*records) != 3 || l.suppressed != 7 {
+		t.Fatalf("expected 3 records and 7 suppressed, got %d and %d", len(*records), l.suppressed)
+	}
+	now = now.Add(time.Second)
+	l.Handle(ev)
+	if len(*records) != 4 || l.suppressed != 0 {
+		t.Fatalf("expected the budget to refill, got %d records and %d suppressed", len(*records), l.suppressed)
+	}
+}
+
+func TestResolutions_Bounded(t *testing.T) {
+	res := NewResolutions(2)
+	a, b, c := netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.2"), netip.MustParseAddr("192.0.2.3")
+	res.Learn("a.example.com", []netip.Addr{a}, 0)
+	res.Learn("b.example.com", []netip.Addr{b}, 0)
+	res.Learn("a2.example.com", []netip.Addr{a}, 0) // refreshes a
+	res.Learn("c.example.com", []netip.Addr{c}, 0)  // evicts b
+
+	if res.Len() != 2 {
+		t.Fatalf("expected 2 entries, got %d", res.Len())
+	}
+	if got := res.Lookup(a); got != "a2.example.com" {
+		t.Fatalf("expected a to map to its latest name, got %q", got)
+	}
+	if got := res.Lookup(b); got != "" {
+		t.Fatalf("expected b to be evicted, got %q", got)
+	}
+}
+
+func TestParsePacket(t *testing.T) {
+	ip := make([]byte, 24)
+	ip[0] = 0x45
+	ip[9] = 6
+	copy(ip[16:20], []byte{140, 82, 112, 6})
+	ip[22], ip[23] = 0x01, 0xbb // dst port 443
+
+	body := []byte{2, 0, 0, 100} // nfgenmsg: AF_INET, v0, group 100
+	body = appendAttr(body, nfulaPrefix, []byte("egress-allow\x00"))
+	body = appendAttr(body, nfulaPayload, ip)
+
+	now := time.Unix(1000, 0)
+	ev, ok := parsePacket(body, now)
+	if !ok {
+		t.Fatal("expected a packet")
+	}
+	want := Event{Time: now, Proto: "tcp", Dst: netip.MustParseAddrPort("140.82.112.6:443"), Verdict: VerdictAllowed}
+	if ev != want {
+		t.Fatalf("parsePacket = %+v, want %+v", ev, want)
+	}
+	if _, ok := parsePacket(body[:8], now); ok {
+		t.Fatal("expected a truncated message to be rejected")
+	}
+}
+
+func appendAttr(b []byte, typ uint16, value []byte) []byte {
+	b = binary.NativeEndian.AppendUint16(b, uint16(4+len(value)))
+	b = binary.NativeEndian.AppendUint16(b, typ)
+	b = append(b, value...)
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
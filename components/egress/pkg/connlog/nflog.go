@@ -0,0 +1,139 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connlog
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alibaba/opensandbox/egress/pkg/iptables"
+)
+
+// nfnetlink_log message layout, see linux/netfilter/nfnetlink_log.h.
+const (
+	nfnlSubsysULOG  = 4
+	nfulnlMsgPacket = 0
+	nfulnlMsgConfig = 1
+
+	nfulaPayload = 9
+	nfulaPrefix  = 10
+
+	nfulaCfgCmd  = 1
+	nfulaCfgMode = 2
+
+	nfulnlCfgCmdBind     = 1
+	nfulnlCfgCmdPFBind   = 3
+	nfulnlCfgCmdPFUnbind = 4
+	nfulnlCopyPacket     = 2
+
+	nfgenmsgLen = 4
+	nlaTypeMask = 0x3fff
+
+	// copyRange is enough for an IPv6 header and the transport ports.
+	copyRange = 128
+)
+
+// parsePacket decodes an NFULNL_MSG_PACKET body: the nfgenmsg header
+// followed by netlink attributes. It reports false when the payload is not
+// an IP packet it can read a destination from.
+func parsePacket(body []byte, now time.Time) (Event, bool) {
+	if len(body) < nfgenmsgLen {
+		return Event{}, false
+	}
+	var payload []byte
+	var prefix string
+	for attrs := body[nfgenmsgLen:]; len(attrs) >= 4; {
+		length := int(binary.NativeEndian.Uint16(attrs[0:2]))
+		if length < 4 || length > len(attrs) {
+			break
+		}
+		switch binary.NativeEndian.Uint16(attrs[2:4]) & nlaTypeMask {
+		case nfulaPayload:
+			payload = attrs[4:length]
+		case nfulaPrefix:
+			prefix = strings.TrimRight(string(attrs[4:length]), "\x00")
+		}
+		attrs = attrs[min(nlaAlign(length), len(attrs)):]
+	}
+	ev, ok := parseIPHeader(payload)
+	if !ok {
+		return Event{}, false
+	}
+	ev.Time = now
+	switch prefix {
+	case iptables.ConnLogPrefix:
+		ev.Verdict = VerdictAllowed
+	case iptables.BlockedLogPrefix:
+		ev.Verdict = VerdictBlocked
+	default:
+		ev.Verdict = prefix
+	}
+	return ev, true
+}
+
+// parseIPHeader reads the protocol and destination of an IPv4 or IPv6
+// packet. Ports are only read for TCP and UDP directly after the IP header.
+func parseIPHeader(b []byte) (Event, bool) {
+	if len(b) == 0 {
+		return Event{}, false
+	}
+	var proto byte
+	var dst netip.Addr
+	var transport []byte
+	switch b[0] >> 4 {
+	case 4:
+		ihl := int(b[0]&0x0f) * 4
+		if len(b) < 20 || ihl < 20 {
+			return Event{}, false
+		}
+		proto, dst = b[9], netip.AddrFrom4([4]byte(b[16:20]))
+		if len(b) > ihl {
+			transport = b[ihl:]
+		}
+	case 6:
+		if len(b) < 40 {
+			return Event{}, false
+		}
+		proto, dst, transport = b[6], netip.AddrFrom16([16]byte(b[24:40])), b[40:]
+	default:
+		return Event{}, false
+	}
+	var port uint16
+	if (proto == 6 || proto == 17) && len(transport) >= 4 {
+		port = binary.BigEndian.Uint16(transport[2:4])
+	}
+	return Event{Proto: protoName(proto), Dst: netip.AddrPortFrom(dst, port)}, true
+}
+
+func protoName(proto byte) string {
+	switch proto {
+	case 1:
+		return "icmp"
+	case 6:
+		return "tcp"
+	case 17:
+		return "udp"
+	case 58:
+		return "icmpv6"
+	}
+	return strconv.Itoa(int(proto))
+}
+
+func nlaAlign(n int) int {
+	return (n + 3) &^ 3
+}
@@ -0,0 +1,160 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package connlog
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// NflogSource reads connections logged by iptables NFLOG rules to a group.
+// Binding the group needs CAP_NET_ADMIN.
+type NflogSource struct {
+	group uint16
+}
+
+// NewNflogSource returns a source for NFLOG group (0-65535).
+func NewNflogSource(group int) (*NflogSource, error) {
+	if group < 0 || group > 0xffff {
+		return nil, fmt.Errorf("nflog group %d out of range", group)
+	}
+	return &NflogSource{group: uint16(group)}, nil
+}
+
+// Run binds the group and emits one event per logged packet. The socket
+// polls ctx once a second. Records the kernel drops because the socket
+// buffer overflowed are counted in a log line rather than failing the source.
+func (s *NflogSource) Run(ctx context.Context, emit func(Event)) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_NETFILTER)
+	if err != nil {
+		return fmt.Errorf("open nflog socket: %w", err)
+	}
+	defer unix.Close(fd)
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return fmt.Errorf("bind nflog socket: %w", err)
+	}
+	_ = unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_RCVBUF, 1<<20)
+	tv := unix.NsecToTimeval(time.Second.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		return fmt.Errorf("set nflog socket timeout: %w", err)
+	}
+	if err := s.configure(fd); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 1<<16)
+	for ctx.Err() == nil {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		switch {
+		case errors.Is(err, unix.EAGAIN), errors.Is(err, unix.EINTR):
+			continue
+		case errors.Is(err, unix.ENOBUFS):
+			log.Printf("[conn] nflog socket overflowed; some connections were not logged")
+			continue
+		case err != nil:
+			return fmt.Errorf("read nflog socket: %w", err)
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+		for _, m := range msgs {
+			if m.Header.Type != nfnlSubsysULOG<<8|nfulnlMsgPacket {
+				continue
+			}
+			if ev, ok := parsePacket(m.Data, time.Now()); ok {
+				emit(ev)
+			}
+		}
+	}
+	return nil
+}
+
+// configure binds the socket to the group and asks for packet headers. The
+// per-family bind steps are no-ops on current kernels and may fail harmlessly.
+func (s *NflogSource) configure(fd int) error {
+	seq := uint32(0)
+	request := func(family uint8, resID uint16, attrType uint16, value []byte) error {
+		seq++
+		if err := unix.Sendto(fd, configMessage(seq, family, resID, attrType, value), 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+			return err
+		}
+		return readAck(fd, seq)
+	}
+	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		_ = request(family, 0, nfulaCfgCmd, []byte{nfulnlCfgCmdPFUnbind})
+		_ = request(family, 0, nfulaCfgCmd, []byte{nfulnlCfgCmdPFBind})
+	}
+	if err := request(unix.AF_UNSPEC, s.group, nfulaCfgCmd, []byte{nfulnlCfgCmdBind}); err != nil {
+		return fmt.Errorf("bind nflog group %d: %w", s.group, err)
+	}
+	mode := make([]byte, 6)
+	binary.BigEndian.PutUint32(mode[0:4], copyRange)
+	mode[4] = nfulnlCopyPacket
+	if err := request(unix.AF_UNSPEC, s.group, nfulaCfgMode, mode); err != nil {
+		return fmt.Errorf("set nflog group %d copy mode: %w", s.group, err)
+	}
+	return nil
+}
+
+// configMessage builds an NFULNL_MSG_CONFIG request carrying one attribute.
+func configMessage(seq uint32, family uint8, resID uint16, attrType uint16, value []byte) []byte {
+	attrLen := 4 + len(value)
+	msgLen := unix.NLMSG_HDRLEN + nfgenmsgLen + nlaAlign(attrLen)
+	b := make([]byte, msgLen)
+	binary.NativeEndian.PutUint32(b[0:4], uint32(msgLen))
+	binary.NativeEndian.PutUint16(b[4:6], nfnlSubsysULOG<<8|nfulnlMsgConfig)
+	binary.NativeEndian.PutUint16(b[6:8], unix.NLM_F_REQUEST|unix.NLM_F_ACK)
+	binary.NativeEndian.PutUint32(b[8:12], seq)
+	b[16] = family
+	binary.BigEndian.PutUint16(b[18:20], resID)
+	binary.NativeEndian.PutUint16(b[20:22], uint16(attrLen))
+	binary.NativeEndian.PutUint16(b[22:24], attrType)
+	copy(b[24:], value)
+	return b
+}
+
+// readAck waits for the kernel's acknowledgement of request seq.
+func readAck(fd int, seq uint32) error {
+	buf := make([]byte, 4096)
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if m.Header.Seq != seq || m.Header.Type != unix.NLMSG_ERROR || len(m.Data) < 4 {
+				continue
+			}
+			if code := int32(binary.NativeEndian.Uint32(m.Data[0:4])); code != 0 {
+				return unix.Errno(-code)
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package connlog
+
+import (
+	"context"
+	"errors"
+)
+
+// NflogSource is only available on Linux.
+type NflogSource struct{}
+
+// NewNflogSource fails outside Linux.
+func NewNflogSource(group int) (*NflogSource, error) {
+	return nil, errors.New("nflog is only supported on linux")
+}
+
+// Run is never reached since NewNflogSource fails.
+func (s *NflogSource) Run(ctx context.Context, emit func(Event)) error {
+	return errors.New("nflog is only supported on linux")
+}
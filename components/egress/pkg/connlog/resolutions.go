@@ -0,0 +1,109 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connlog
+
+import (
+	"container/list"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultResolutionCapacity bounds the number of remembered addresses.
+	DefaultResolutionCapacity = 4096
+	// minRetention keeps an address longer than a short TTL: clients often
+	// connect, or reconnect, well after the answer expired for them.
+	minRetention = 10 * time.Minute
+)
+
+// Resolutions remembers which domain each address was handed out for by the
+// DNS proxy, so connections to the address can be attributed to it. When
+// full, the least recently resolved address is forgotten.
+type Resolutions struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[netip.Addr]*list.Element
+	order    *list.List // of *resolution, most recent first
+	now      func() time.Time
+}
+
+type resolution struct {
+	addr    netip.Addr
+	domain  string
+	expires time.Time
+}
+
+// NewResolutions returns a table holding up to capacity addresses; zero or
+// less uses DefaultResolutionCapacity.
+func NewResolutions(capacity int) *Resolutions {
+	if capacity <= 0 {
+		capacity = DefaultResolutionCapacity
+	}
+	return &Resolutions{
+		capacity: capacity,
+		entries:  make(map[netip.Addr]*list.Element),
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+// Learn records that domain resolved to addrs with the given TTL. An address
+// resolved for several names is attributed to the latest one.
+func (r *Resolutions) Learn(domain string, addrs []netip.Addr, ttl time.Duration) {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	expires := r.now().Add(max(ttl, minRetention))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, addr := range addrs {
+		addr = addr.Unmap()
+		if el, ok := r.entries[addr]; ok {
+			res := el.Value.(*resolution)
+			res.domain, res.expires = domain, expires
+			r.order.MoveToFront(el)
+			continue
+		}
+		r.entries[addr] = r.order.PushFront(&resolution{addr: addr, domain: domain, expires: expires})
+		if r.order.Len() > r.capacity {
+			oldest := r.order.Back()
+			r.order.Remove(oldest)
+			delete(r.entries, oldest.Value.(*resolution).addr)
+		}
+	}
+}
+
+// Lookup returns the domain addr was last resolved for, or "" if it is
+// unknown or was resolved too long ago.
+func (r *Resolutions) Lookup(addr netip.Addr) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	el, ok := r.entries[addr.Unmap()]
+	if !ok {
+		return ""
+	}
+	res := el.Value.(*resolution)
+	if r.now().After(res.expires) {
+		return ""
+	}
+	return res.domain
+}
+
+// Len returns the number of remembered addresses, expired ones included.
+func (r *Resolutions) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.order.Len()
+}
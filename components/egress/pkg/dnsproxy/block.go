@@ -0,0 +1,98 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/alibaba/opensandbox/egress/pkg/policy"
+)
+
+// BlockReasonUnsupportedClass marks queries refused because their QCLASS is
+// not allowed; it never comes from the policy itself.
+const BlockReasonUnsupportedClass policy.BlockReason = "unsupported_class"
+
+// BlockEvent describes one blocked query.
+type BlockEvent struct {
+	Time   time.Time          `json:"time"`
+	Name   string             `json:"name"`
+	QType  string             `json:"qtype"`
+	QClass string             `json:"qclass"`
+	Reason policy.BlockReason `json:"reason"`
+	// Rule is the target of the rule behind the decision, empty when the
+	// default action or a proxy check blocked the query.
+	Rule string `json:"rule,omitempty"`
+	// Origin locates that rule in the policy document, see policy.RuleOrigin.
+	Origin *policy.RuleOrigin `json:"origin,omitempty"`
+	// Listener is the label of the listener the query arrived on, empty for
+	// the main one.
+	Listener string `json:"listener,omitempty"`
+}
+
+// blockStats counts blocked queries per reason and fans events out to an
+// optional handler.
+type blockStats struct {
+	mu      sync.Mutex
+	counts  map[policy.BlockReason]uint64
+	handler func(BlockEvent)
+}
+
+// SetBlockHandler registers fn to receive every block event; nil removes it.
+// fn runs on the query path and should not block.
+func (p *Proxy) SetBlockHandler(fn func(BlockEvent)) {
+	p.blocks.mu.Lock()
+	p.blocks.handler = fn
+	p.blocks.mu.Unlock()
+}
+
+// BlockCounts returns the number of blocked queries per reason since start.
+func (p *Proxy) BlockCounts() map[policy.BlockReason]uint64 {
+	p.blocks.mu.Lock()
+	defer p.blocks.mu.Unlock()
+	out := make(map[policy.BlockReason]uint64, len(p.blocks.counts))
+	for reason, n := range p.blocks.counts {
+		out[reason] = n
+	}
+	return out
+}
+
+func (p *Proxy) recordBlock(q dns.Question, l *Listener, reason policy.BlockReason, rule string, origin *policy.RuleOrigin) {
+	ev := BlockEvent{
+		Time:     time.Now(),
+		Name:     q.Name,
+		QType:    dns.Type(q.Qtype).String(),
+		QClass:   dns.Class(q.Qclass).String(),
+		Reason:   reason,
+		Rule:     rule,
+		Origin:   origin,
+		Listener: listenerLabel(l),
+	}
+	// blocks bypass the query log sampling
+	p.queries.logf("[dns] blocked %s %s %s reason=%s rule=%q%s%s", ev.Name, ev.QClass, ev.QType, ev.Reason, ev.Rule, listenerField(l), originField(origin))
+
+	p.blocks.mu.Lock()
+	if p.blocks.counts == nil {
+		p.blocks.counts = make(map[policy.BlockReason]uint64)
+	}
+	p.blocks.counts[reason]++
+	handler := p.blocks.handler
+	p.blocks.mu.Unlock()
+	if handler != nil {
+		handler(ev)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/alibaba/opensandbox/egress/pkg/policy"
+)
+
+func TestServeDNS_BlockEventsCarryReason(t *testing.T) {
+	pol, err := policy.ParsePolicy(`{"defaultAction":"deny","egress":[
+		{"action":"deny","target":"*.blocked.test"},
+		{"action":"allow","target":"nightly.test","window":{"start":"00:00","end":"00:01"}}
+	]}`)
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	if pol.Egress[1].Window.Contains(time.Now()) {
+		t.Skip("test runs inside the rule window")
+	}
+	p := &Proxy{policy: pol, upstream: "127.0.0.1:1"}
+	var events []BlockEvent
+	p.SetBlockHandler(func(ev BlockEvent) { events = append(events, ev) })
+
+	query(t, p, "a.blocked.test.", dns.TypeA)
+	query(t, p, "other.test.", dns.TypeAAAA)
+	query(t, p, "nightly.test.", dns.TypeA)
+	queryClass(t, p, "version.bind.", dns.TypeTXT, dns.ClassCHAOS)
+
+	want := []BlockEvent{
+		{Name: "a.blocked.test.", QType: "A", QClass: "IN", Reason: policy.BlockReasonExplicitDeny, Rule: "*.blocked.test",
+			Origin: &policy.RuleOrigin{Field: "egress", Index: 0, Line: 2}},
+		{Name: "other.test.", QType: "AAAA", QClass: "IN", Reason: policy.BlockReasonNoMatchingRule},
+		{Name: "nightly.test.", QType: "A", QClass: "IN", Reason: policy.BlockReasonOutsideWindow, Rule: "nightly.test",
+			Origin: &policy.RuleOrigin{Field: "egress", Index: 1, Line: 3}},
+		{Name: "version.bind.", QType: "TXT", QClass: "CH", Reason: BlockReasonUnsupportedClass},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d block events, got %+v", len(want), events)
+	}
+	for i, ev := range events {
+		if ev.Time.IsZero() {
+			t.Fatalf("event %d has no time", i)
+		}
+		ev.Time = time.Time{}
+		if !reflect.DeepEqual(ev, want[i]) {
+			t.Fatalf("event %d = %+v, want %+v", i, ev, want[i])
+		}
+	}
+
+	counts := p.BlockCounts()
+	for _, reason := range []policy.BlockReason{
+		policy.BlockReasonExplicitDeny, policy.BlockReasonNoMatchingRule,
+		policy.BlockReasonOutsideWindow, BlockReasonUnsupportedClass,
+	} {
+		if counts[reason] != 1 {
+			t.Fatalf("expected one %s block, got %v", reason, counts)
+		}
+	}
+}
+
+func TestServeDNS_AllowedQueryIsNotABlock(t *testing.T) {
+	p := newDenyProxy(t)
+	p.SetBlockHandler(func(ev BlockEvent) { t.Fatalf("unexpected block event %+v", ev) })
+	query(t, p, "example.test.", dns.TypeA)
+	if len(p.BlockCounts()) != 0 {
+		t.Fatalf("expected no block counts, got %v", p.BlockCounts())
+	}
+}
@@ -0,0 +1,327 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/alibaba/opensandbox/egress/pkg/policy"
+)
+
+// defaultCacheEntries bounds the answer cache unless SetCacheSize says
+// otherwise. Once it is full, expired answers are swept out first and then the
+// answer closest to expiry makes room for the new one.
+const defaultCacheEntries = 4096
+
+// Reasons a cached answer is evicted, as reported in CacheStats.
+const (
+	evictSize = "size" // made room for a new answer in a full cache
+	evictTTL  = "ttl"  // expired, including any stale window
+)
+
+type cacheKey struct {
+	name   string // lower-cased FQDN
+	qtype  uint16
+	qclass uint16
+}
+
+type cacheEntry struct {
+	key      cacheKey
+	msg      *dns.Msg
+	storedAt time.Time
+	expires  time.Time
+	size     int // packed length of msg
+	index    int // position in answerCache.byExpiry
+}
+
+// expiryQueue is a min-heap of cache entries ordered by expiry, so the entry
+// closest to expiry is always at the front.
+type expiryQueue []*cacheEntry
+
+func (q expiryQueue) Len() int           { return len(q) }
+func (q expiryQueue) Less(i, j int) bool { return q[i].expires.Before(q[j].expires) }
+func (q expiryQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *expiryQueue) Push(x any) {
+	entry := x.(*cacheEntry)
+	entry.index = len(*q)
+	*q = append(*q, entry)
+}
+
+func (q *expiryQueue) Pop() any {
+	old := *q
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return entry
+}
+
+// answerCache holds successful upstream answers until their smallest TTL runs
+// out, plus the stale window if one is set.
+type answerCache struct {
+	mu         sync.Mutex
+	entries    map[cacheKey]*cacheEntry
+	byExpiry   expiryQueue // the same entries, closest to expiry first
+	maxEntries int
+	// how long past expiry an answer may still be served while it is refreshed
+	staleWindow time.Duration
+	refreshing  map[cacheKey]struct{} // keys with a revalidation in flight
+
+	bytes        int // sum of the entries' sizes
+	hits, misses uint64
+	evictions    map[string]uint64 // by evictSize or evictTTL
+}
+
+// CacheStats is a snapshot of the answer cache for the metrics endpoint.
+type CacheStats struct {
+	Entries    int `json:"entries"`
+	MaxEntries int `json:"max_entries"`
+	// Bytes is the wire size of the cached answers, not counting map overhead.
+	Bytes     int               `json:"bytes"`
+	Hits      uint64            `json:"hits"`
+	Misses    uint64            `json:"misses"`
+	Evictions map[string]uint64 `json:"evictions"`
+}
+
+// CacheStats returns the answer cache's size and counters since start, or nil
+// when the cache is disabled.
+func (p *Proxy) CacheStats() *CacheStats {
+	if p.cache == nil {
+		return nil
+	}
+	p.cache.mu.Lock()
+	defer p.cache.mu.Unlock()
+	stats := &CacheStats{
+		Entries:    len(p.cache.entries),
+		MaxEntries: p.cache.maxEntries,
+		Bytes:      p.cache.bytes,
+		Hits:       p.cache.hits,
+		Misses:     p.cache.misses,
+		Evictions:  map[string]uint64{evictSize: 0, evictTTL: 0},
+	}
+	for reason, n := range p.cache.evictions {
+		stats.Evictions[reason] = n
+	}
+	return stats
+}
+
+// put stores entry under its key, replacing any entry there. Callers hold mu.
+func (c *answerCache) put(entry *cacheEntry) {
+	c.remove(entry.key, "")
+	c.entries[entry.key] = entry
+	heap.Push(&c.byExpiry, entry)
+	c.bytes += entry.size
+}
+
+// remove drops the entry under key, if any, counting it as evicted for reason
+// unless reason is empty. Callers hold mu.
+func (c *answerCache) remove(key cacheKey, reason string) {
+	entry, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	delete(c.entries, key)
+	heap.Remove(&c.byExpiry, entry.index)
+	c.bytes -= entry.size
+	if reason != "" {
+		c.evictions[reason]++
+	}
+}
+
+// makeRoom evicts entries until one more fits: first every expired one, then
+// the one that would expire soonest. Both come off the front of byExpiry, so
+// each eviction costs O(log n). Callers hold mu.
+func (c *answerCache) makeRoom(now time.Time) {
+	if len(c.entries) < c.maxEntries {
+		return
+	}
+	for len(c.byExpiry) > 0 && !now.Before(c.usableUntil(c.byExpiry[0])) {
+		c.remove(c.byExpiry[0].key, evictTTL)
+	}
+	for len(c.entries) >= c.maxEntries {
+		c.remove(c.byExpiry[0].key, evictSize)
+	}
+}
+
+// usableUntil is when an entry stops being served at all, fresh or stale.
+func (c *answerCache) usableUntil(entry *cacheEntry) time.Time {
+	return entry.expires.Add(c.staleWindow)
+}
+
+func newCacheKey(q dns.Question) cacheKey {
+	return cacheKey{name: strings.ToLower(dns.Fqdn(q.Name)), qtype: q.Qtype, qclass: q.Qclass}
+}
+
+// SetCache enables answering repeated queries from a local cache of upstream
+// answers. Policy updates purge entries whose name the new policy denies.
+func (p *Proxy) SetCache(enabled bool) {
+	if !enabled {
+		p.cache = nil
+		return
+	}
+	p.cache = &answerCache{
+		entries:    make(map[cacheKey]*cacheEntry),
+		maxEntries: defaultCacheEntries,
+		refreshing: make(map[cacheKey]struct{}),
+		evictions:  make(map[string]uint64),
+	}
+}
+
+// SetCacheSize caps the answer cache at entries answers. The answer cache
+// must be enabled first.
+func (p *Proxy) SetCacheSize(entries int) error {
+	if entries <= 0 {
+		return fmt.Errorf("cache size %d is not positive", entries)
+	}
+	if p.cache == nil {
+		return errors.New("cache size needs the answer cache to be enabled")
+	}
+	p.cache.mu.Lock()
+	defer p.cache.mu.Unlock()
+	p.cache.maxEntries = entries
+	return nil
+}
+
+// ParseCacheSize parses a cache size given as a number of answers, e.g. "10000".
+func ParseCacheSize(raw string) (int, error) {
+	entries, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil {
+		return 0, fmt.Errorf("cache size %q is not a number of entries: %w", raw, err)
+	}
+	return entries, nil
+}
+
+// ParseCache parses the on/off switch, e.g. "true" or "1".
+func ParseCache(raw string) (bool, error) {
+	enabled, err := strconv.ParseBool(strings.TrimSpace(raw))
+	if err != nil {
+		return false, fmt.Errorf("dns cache %q is not a boolean: %w", raw, err)
+	}
+	return enabled, nil
+}
+
+// cached returns a copy of the stored answer for r's question with r's ID and
+// TTLs reduced by the time spent in the cache, or nil on a miss. stale reports
+// an answer past its TTL but within the stale window; it carries staleAnswerTTL.
+func (p *Proxy) cached(r *dns.Msg, now time.Time) (resp *dns.Msg, stale bool) {
+	if p.cache == nil {
+		return nil, false
+	}
+	key := newCacheKey(r.Question[0])
+	p.cache.mu.Lock()
+	entry, ok := p.cache.entries[key]
+	if ok && !now.Before(p.cache.usableUntil(entry)) {
+		p.cache.remove(key, evictTTL)
+		ok = false
+	}
+	if ok {
+		p.cache.hits++
+	} else {
+		p.cache.misses++
+	}
+	p.cache.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	resp = entry.msg.Copy()
+	resp.Id = r.Id
+	resp.Question = r.Question
+	stale = !now.Before(entry.expires)
+	elapsed := uint32(now.Sub(entry.storedAt) / time.Second)
+	for _, rr := range resp.Answer {
+		if stale {
+			rr.Header().Ttl = staleAnswerTTL
+		} else {
+			rr.Header().Ttl -= elapsed
+		}
+	}
+	return resp, stale
+}
+
+// storeCached keeps resp for later queries. evaluatedBy is the policy that
+// allowed the query; if a reload replaced it meanwhile, the answer is dropped
+// so it cannot reappear after the reload's purge.
+func (p *Proxy) storeCached(resp *dns.Msg, evaluatedBy *policy.NetworkPolicy, now time.Time) {
+	if p.cache == nil || resp == nil || resp.Rcode != dns.RcodeSuccess || len(resp.Question) == 0 || len(resp.Answer) == 0 {
+		return
+	}
+	ttl := resp.Answer[0].Header().Ttl
+	for _, rr := range resp.Answer[1:] {
+		if rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+		}
+	}
+	if ttl == 0 {
+		return
+	}
+
+	p.policyMu.RLock()
+	defer p.policyMu.RUnlock()
+	if p.policy != evaluatedBy {
+		return
+	}
+	key := newCacheKey(resp.Question[0])
+	p.cache.mu.Lock()
+	defer p.cache.mu.Unlock()
+	if _, ok := p.cache.entries[key]; !ok {
+		p.cache.makeRoom(now)
+	}
+	p.cache.put(&cacheEntry{
+		key:      key,
+		msg:      resp.Copy(),
+		storedAt: now,
+		expires:  now.Add(time.Duration(ttl) * time.Second),
+		size:     resp.Len(),
+	})
+}
+
+// purgeStale drops cached answers for names that pol no longer allows, or
+// that pol forwards to a different upstream than old did, and returns how
+// many were removed. Callers hold policyMu for writing.
+func (p *Proxy) purgeStale(old, pol *policy.NetworkPolicy) int {
+	if p.cache == nil {
+		return 0
+	}
+	p.cache.mu.Lock()
+	defer p.cache.mu.Unlock()
+	purged := 0
+	for key := range p.cache.entries {
+		if pol.Explain(key.name).Action == policy.ActionDeny || upstreamFor(old, key.name) != pol.UpstreamFor(key.name) {
+			p.cache.remove(key, "")
+			purged++
+		}
+	}
+	return purged
+}
+
+func upstreamFor(pol *policy.NetworkPolicy, name string) string {
+	if pol == nil {
+		return ""
+	}
+	return pol.UpstreamFor(name)
+}
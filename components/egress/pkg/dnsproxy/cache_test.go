@@ -0,0 +1,224 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/alibaba/opensandbox/egress/pkg/policy"
+)
+
+func mustPolicy(t *testing.T, raw string) *policy.NetworkPolicy {
+	t.Helper()
+	pol, err := policy.ParsePolicy(raw)
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	return pol
+}
+
+func TestServeDNS_ReloadPurgesCachedAnswers(t *testing.T) {
+	upstream := startStub(t, "127.0.0.1:0", func(q dns.Question, resp *dns.Msg) {
+		resp.Answer = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300}, A: net.ParseIP("192.0.2.20")}}
+	})
+	p := &Proxy{upstream: upstream.addr}
+	p.SetCache(true)
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"deny","egress":[{"action":"allow","target":"api.example.com"},{"action":"allow","target":"cdn.example.com"}]}`))
+
+	probe := new(dns.Msg)
+	probe.SetQuestion("probe.example.com.", dns.TypeA)
+	_, err := p.forward(probe)
+	skipIfNoMark(t, err)
+	if err != nil {
+		t.Fatalf("forward: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp := query(t, p, "api.example.com.", dns.TypeA)
+		if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 {
+			t.Fatalf("resolution %d: unexpected reply %v", i, resp)
+		}
+	}
+	query(t, p, "cdn.example.com.", dns.TypeA)
+	if got := len(upstream.questions()); got != 3 {
+		t.Fatalf("expected the repeated query to be served from cache, upstream saw %v", upstream.questions())
+	}
+
+	// Reload without api.example.com: it must be blocked, not answered from cache.
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"deny","egress":[{"action":"allow","target":"cdn.example.com"}]}`))
+	if resp := query(t, p, "api.example.com.", dns.TypeA); resp.Rcode != dns.RcodeNameError {
+		t.Fatalf("expected NXDOMAIN after reload, got %v", resp)
+	}
+	if _, ok := p.cache.entries[cacheKey{name: "api.example.com.", qtype: dns.TypeA, qclass: dns.ClassINET}]; ok {
+		t.Fatalf("denied answer still cached")
+	}
+	if _, ok := p.cache.entries[cacheKey{name: "cdn.example.com.", qtype: dns.TypeA, qclass: dns.ClassINET}]; !ok {
+		t.Fatalf("still-allowed answer was purged")
+	}
+
+	// Allowing it again resolves upstream instead of reviving the old entry.
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"deny","egress":[{"action":"allow","target":"api.example.com"}]}`))
+	query(t, p, "api.example.com.", dns.TypeA)
+	if got := len(upstream.questions()); got != 4 {
+		t.Fatalf("expected a fresh upstream query after re-allowing, upstream saw %v", upstream.questions())
+	}
+}
+
+func TestStoreCached_DropsAnswersFromReplacedPolicy(t *testing.T) {
+	old := mustPolicy(t, `{"defaultAction":"allow"}`)
+	p := &Proxy{policy: old}
+	p.SetCache(true)
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"allow"}`))
+
+	req := new(dns.Msg)
+	req.SetQuestion("late.example.com.", dns.TypeA)
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Answer = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: "late.example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.ParseIP("192.0.2.30")}}
+
+	now := time.Now()
+	p.storeCached(resp, old, now)
+	if hit, _ := p.cached(req, now); hit != nil {
+		t.Fatalf("answer evaluated under a replaced policy was cached")
+	}
+
+	p.storeCached(resp, p.CurrentPolicy(), now)
+	hit, stale := p.cached(req, now.Add(15*time.Second))
+	if hit == nil || stale || hit.Id != req.Id || hit.Answer[0].Header().Ttl != 45 {
+		t.Fatalf("expected cache hit with decremented ttl, got %v", hit)
+	}
+	if hit, _ := p.cached(req, now.Add(time.Minute)); hit != nil {
+		t.Fatalf("expired answer served from cache")
+	}
+}
+
+func TestParseCache(t *testing.T) {
+	if enabled, err := ParseCache(" true "); err != nil || !enabled {
+		t.Fatalf("ParseCache(true) = %v, %v", enabled, err)
+	}
+	if _, err := ParseCache("sometimes"); err == nil {
+		t.Fatalf("expected error for non-boolean")
+	}
+}
+
+func TestCacheStats_EvictsBySizeAndTTL(t *testing.T) {
+	p := &Proxy{}
+	if stats := p.CacheStats(); stats != nil {
+		t.Fatalf("expected no stats without the cache, got %+v", stats)
+	}
+	p.SetCache(true)
+	if err := p.SetCacheSize(2); err != nil {
+		t.Fatalf("SetCacheSize: %v", err)
+	}
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"allow"}`))
+
+	seedCache(t, p, "a.example.com.", "192.0.2.1", 60, 0)
+	seedCache(t, p, "b.example.com.", "192.0.2.2", 300, 0)
+	stats := p.CacheStats()
+	if stats.Entries != 2 || stats.MaxEntries != 2 || stats.Bytes <= 0 {
+		t.Fatalf("unexpected stats for two entries: %+v", stats)
+	}
+	twoEntries := stats.Bytes
+
+	// A third answer evicts the one expiring soonest.
+	seedCache(t, p, "c.example.com.", "192.0.2.3", 300, 0)
+	stats = p.CacheStats()
+	if stats.Entries != 2 || stats.Evictions["size"] != 1 || stats.Evictions["ttl"] != 0 {
+		t.Fatalf("expected one size eviction, got %+v", stats)
+	}
+	if _, ok := p.cache.entries[cacheKey{name: "a.example.com.", qtype: dns.TypeA, qclass: dns.ClassINET}]; ok {
+		t.Fatalf("expected the answer closest to expiry to be evicted")
+	}
+	// Replacing a cached answer evicts nothing.
+	seedCache(t, p, "c.example.com.", "192.0.2.3", 300, 0)
+	if stats = p.CacheStats(); stats.Evictions["size"] != 1 || stats.Bytes != twoEntries {
+		t.Fatalf("replacing an answer changed the counters: %+v", stats)
+	}
+
+	// An expired entry found on lookup is a miss and a ttl eviction.
+	req := new(dns.Msg)
+	req.SetQuestion("b.example.com.", dns.TypeA)
+	if resp, _ := p.cached(req, time.Now()); resp == nil {
+		t.Fatalf("expected a hit")
+	}
+	if resp, _ := p.cached(req, time.Now().Add(time.Hour)); resp != nil {
+		t.Fatalf("expected the expired answer to be dropped")
+	}
+	stats = p.CacheStats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Evictions["ttl"] != 1 || stats.Entries != 1 || stats.Bytes >= twoEntries {
+		t.Fatalf("unexpected stats after the ttl eviction: %+v", stats)
+	}
+
+	// A full cache sweeps expired entries before evicting live ones.
+	seedCache(t, p, "d.example.com.", "192.0.2.4", 60, 2*time.Minute)
+	seedCache(t, p, "e.example.com.", "192.0.2.5", 300, 0)
+	if stats = p.CacheStats(); stats.Evictions["size"] != 1 || stats.Evictions["ttl"] != 2 || stats.Entries != 2 {
+		t.Fatalf("expected the expired entry to make room, got %+v", stats)
+	}
+}
+
+func TestMakeRoom_KeepsTheLongestLivedAnswers(t *testing.T) {
+	p := &Proxy{}
+	p.SetCache(true)
+	if err := p.SetCacheSize(10); err != nil {
+		t.Fatalf("SetCacheSize: %v", err)
+	}
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"allow"}`))
+
+	// TTLs 60..159 arrive out of order, then a long-lived answer; it and the
+	// nine largest of the others must survive.
+	for i := 0; i < 100; i++ {
+		ttl := uint32(60 + (i*37)%100)
+		seedCache(t, p, fmt.Sprintf("h%d.example.com.", i), "192.0.2.1", ttl, 0)
+	}
+	seedCache(t, p, "last.example.com.", "192.0.2.1", 1000, 0)
+	stats := p.CacheStats()
+	if stats.Entries != 10 || stats.Evictions["size"] != 91 {
+		t.Fatalf("expected 10 entries after 91 size evictions, got %+v", stats)
+	}
+	for key, entry := range p.cache.entries {
+		if ttl := entry.msg.Answer[0].Header().Ttl; ttl < 151 {
+			t.Fatalf("%s with ttl %d survived over longer-lived answers", key.name, ttl)
+		}
+		if p.cache.byExpiry[entry.index] != entry {
+			t.Fatalf("%s is out of place in the expiry queue", key.name)
+		}
+	}
+}
+
+func TestSetCacheSize(t *testing.T) {
+	p := &Proxy{}
+	if err := p.SetCacheSize(100); err == nil {
+		t.Fatalf("expected error without the answer cache")
+	}
+	p.SetCache(true)
+	if err := p.SetCacheSize(0); err == nil {
+		t.Fatalf("expected error for a zero size")
+	}
+	if err := p.SetCacheSize(100); err != nil || p.cache.maxEntries != 100 {
+		t.Fatalf("SetCacheSize(100) = %v, max %d", err, p.cache.maxEntries)
+	}
+	if size, err := ParseCacheSize(" 10000 "); err != nil || size != 10000 {
+		t.Fatalf("ParseCacheSize(10000) = %d, %v", size, err)
+	}
+	if _, err := ParseCacheSize("10k"); err == nil {
+		t.Fatalf("expected error for a non-numeric size")
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ECSMode selects what happens to the EDNS Client Subnet option (RFC 7871) of
+// forwarded queries.
+type ECSMode string
+
+const (
+	// ECSPassthrough forwards the client's option, if any, unchanged.
+	ECSPassthrough ECSMode = "passthrough"
+	// ECSStrip removes the option so upstreams never learn a client subnet.
+	ECSStrip ECSMode = "strip"
+	// ECSSet replaces the option with a configured subnet.
+	ECSSet ECSMode = "set"
+)
+
+// ednsUDPSize is advertised when the proxy has to add an OPT record of its own.
+const ednsUDPSize = 1232
+
+// ECSConfig is a parsed ECS setting; Subnet is only set for ECSSet.
+type ECSConfig struct {
+	Mode   ECSMode
+	Subnet *net.IPNet
+}
+
+// ParseECS parses "passthrough", "strip" or a CIDR to send instead of the
+// client's subnet, e.g. "203.0.113.0/24". Host bits of the CIDR are dropped.
+func ParseECS(raw string) (ECSConfig, error) {
+	value := strings.TrimSpace(raw)
+	switch ECSMode(strings.ToLower(value)) {
+	case ECSPassthrough:
+		return ECSConfig{Mode: ECSPassthrough}, nil
+	case ECSStrip:
+		return ECSConfig{Mode: ECSStrip}, nil
+	}
+	_, subnet, err := net.ParseCIDR(value)
+	if err != nil {
+		return ECSConfig{}, fmt.Errorf("ecs %q is neither %q, %q nor a CIDR", raw, ECSPassthrough, ECSStrip)
+	}
+	return ECSConfig{Mode: ECSSet, Subnet: subnet}, nil
+}
+
+// SetECS configures EDNS Client Subnet handling; the default is passthrough.
+func (p *Proxy) SetECS(cfg ECSConfig) error {
+	switch cfg.Mode {
+	case ECSPassthrough, ECSStrip:
+		p.ecs = ECSConfig{Mode: cfg.Mode}
+	case ECSSet:
+		if cfg.Subnet == nil {
+			return fmt.Errorf("ecs mode %q needs a subnet", ECSSet)
+		}
+		p.ecs = cfg
+	default:
+		return fmt.Errorf("unknown ecs mode %q", cfg.Mode)
+	}
+	return nil
+}
+
+// ecsPerClient reports whether the upstream answer for r may depend on a
+// client-supplied subnet, which the question-keyed cache cannot tell apart.
+func (p *Proxy) ecsPerClient(r *dns.Msg) bool {
+	return !p.ecsRewrites() && clientSubnet(r) != nil
+}
+
+func (p *Proxy) ecsRewrites() bool {
+	return p.ecs.Mode == ECSStrip || p.ecs.Mode == ECSSet
+}
+
+// applyECS returns the query to send upstream. Unless the mode is passthrough
+// it is a copy of r with the client's subnet removed and, in set mode, the
+// configured one added. An OPT record is only added when r had none.
+func (p *Proxy) applyECS(r *dns.Msg) *dns.Msg {
+	if !p.ecsRewrites() {
+		return r
+	}
+	out := r.Copy()
+	opt := out.IsEdns0()
+	if opt != nil {
+		opt.Option = withoutSubnet(opt.Option)
+	}
+	if p.ecs.Mode != ECSSet {
+		return out
+	}
+	if opt == nil {
+		out.SetEdns0(ednsUDPSize, false)
+		opt = out.IsEdns0()
+	}
+	opt.Option = append(opt.Option, subnetOption(p.ecs.Subnet))
+	return out
+}
+
+// restoreECS undoes applyECS on the upstream response: the client gets no
+// subnet it did not send, and no OPT record if its query carried none.
+func (p *Proxy) restoreECS(r, resp *dns.Msg) {
+	if !p.ecsRewrites() || resp == nil {
+		return
+	}
+	if r.IsEdns0() == nil {
+		extra := resp.Extra[:0]
+		for _, rr := range resp.Extra {
+			if rr.Header().Rrtype != dns.TypeOPT {
+				extra = append(extra, rr)
+			}
+		}
+		resp.Extra = extra
+		return
+	}
+	if opt := resp.IsEdns0(); opt != nil {
+		opt.Option = withoutSubnet(opt.Option)
+	}
+}
+
+func clientSubnet(r *dns.Msg) *dns.EDNS0_SUBNET {
+	opt := r.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+	for _, o := range opt.Option {
+		if s, ok := o.(*dns.EDNS0_SUBNET); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+func withoutSubnet(options []dns.EDNS0) []dns.EDNS0 {
+	kept := options[:0]
+	for _, o := range options {
+		if o.Option() != dns.EDNS0SUBNET {
+			kept = append(kept, o)
+		}
+	}
+	return kept
+}
+
+func subnetOption(subnet *net.IPNet) *dns.EDNS0_SUBNET {
+	ones, _ := subnet.Mask.Size()
+	o := &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		SourceNetmask: uint8(ones),
+	}
+	if ip4 := subnet.IP.To4(); ip4 != nil {
+		o.Family = 1
+		o.Address = ip4
+	} else {
+		o.Family = 2
+		o.Address = subnet.IP
+	}
+	return o
+}
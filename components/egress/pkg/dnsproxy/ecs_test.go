@@ -0,0 +1,218 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// ecsUpstream is an ECS-aware upstream: it records the subnet of every query,
+// echoes it back with scope /24 like a CDN resolver, and answers with an
+// address inside the subnet so tailored answers are recognizable.
+type ecsUpstream struct {
+	addr string
+	mu   sync.Mutex
+	seen []string // subnet per query, "" when the query carried none
+}
+
+func startECSUpstream(t *testing.T) *ecsUpstream {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen fake upstream: %v", err)
+	}
+	u := &ecsUpstream{addr: pc.LocalAddr().String()}
+	srv := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(r)
+		ip := net.ParseIP("192.0.2.1")
+		seen := ""
+		if s := clientSubnet(r); s != nil {
+			seen = (&net.IPNet{IP: s.Address, Mask: net.CIDRMask(int(s.SourceNetmask), 8*len(s.Address.To4()))}).String()
+			ip = append(net.IP(nil), s.Address.To4()...)
+			ip[3] = 1
+			echo := *s
+			echo.SourceScope = 24
+			resp.SetEdns0(ednsUDPSize, false)
+			opt := resp.IsEdns0()
+			opt.Option = append(opt.Option, &echo)
+		}
+		u.mu.Lock()
+		u.seen = append(u.seen, seen)
+		u.mu.Unlock()
+		q := r.Question[0]
+		resp.Answer = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: ip}}
+		_ = w.WriteMsg(resp)
+	})}
+	go func() { _ = srv.ActivateAndServe() }()
+	t.Cleanup(func() { _ = srv.Shutdown() })
+	return u
+}
+
+func (u *ecsUpstream) subnets() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]string(nil), u.seen...)
+}
+
+// ecsQuery sends an A query for name through p, carrying subnet as ECS when
+// it is not empty and an OPT record without ECS when edns is set.
+func ecsQuery(t *testing.T, p *Proxy, name, subnet string, edns bool) *dns.Msg {
+	t.Helper()
+	req := new(dns.Msg)
+	req.SetQuestion(name, dns.TypeA)
+	if subnet != "" || edns {
+		req.SetEdns0(ednsUDPSize, false)
+	}
+	if subnet != "" {
+		_, n, err := net.ParseCIDR(subnet)
+		if err != nil {
+			t.Fatalf("parse %s: %v", subnet, err)
+		}
+		opt := req.IsEdns0()
+		opt.Option = append(opt.Option, subnetOption(n))
+	}
+	w := &recordingWriter{}
+	p.serveDNS(w, req)
+	if w.msg == nil {
+		t.Fatalf("no reply for %s", name)
+	}
+	if w.msg.Rcode != dns.RcodeSuccess || len(w.msg.Answer) != 1 {
+		t.Fatalf("%s: unexpected reply %v", name, w.msg)
+	}
+	return w.msg
+}
+
+func newECSProxy(t *testing.T, upstream, ecs string) *Proxy {
+	t.Helper()
+	p := &Proxy{upstream: upstream}
+	p.SetCache(true)
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"allow"}`))
+	if ecs != "" {
+		cfg, err := ParseECS(ecs)
+		if err != nil {
+			t.Fatalf("parse ecs: %v", err)
+		}
+		if err := p.SetECS(cfg); err != nil {
+			t.Fatalf("set ecs: %v", err)
+		}
+	}
+	probe := new(dns.Msg)
+	probe.SetQuestion("probe.example.com.", dns.TypeA)
+	_, err := p.forward(probe)
+	skipIfNoMark(t, err)
+	if err != nil {
+		t.Fatalf("forward: %v", err)
+	}
+	return p
+}
+
+func TestServeDNS_ECSPassthroughBypassesCache(t *testing.T) {
+	u := startECSUpstream(t)
+	p := newECSProxy(t, u.addr, "")
+
+	a := ecsQuery(t, p, "www.example.com.", "198.51.100.0/24", false)
+	b := ecsQuery(t, p, "www.example.com.", "203.0.113.0/24", false)
+	if got := a.Answer[0].(*dns.A).A.String(); got != "198.51.100.1" {
+		t.Errorf("first client got %s, want its tailored answer", got)
+	}
+	if got := b.Answer[0].(*dns.A).A.String(); got != "203.0.113.1" {
+		t.Errorf("second client got %s; a cached answer for another subnet leaked", got)
+	}
+	if s := clientSubnet(b); s == nil || s.SourceScope != 24 {
+		t.Errorf("the upstream's ECS echo should reach the client, got %v", b.IsEdns0())
+	}
+	want := []string{"", "198.51.100.0/24", "203.0.113.0/24"}
+	if got := u.subnets(); len(got) != len(want) || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("upstream saw %v, want %v", got, want)
+	}
+}
+
+func TestServeDNS_ECSStrip(t *testing.T) {
+	u := startECSUpstream(t)
+	p := newECSProxy(t, u.addr, "strip")
+
+	resp := ecsQuery(t, p, "www.example.com.", "198.51.100.0/24", false)
+	if got := resp.Answer[0].(*dns.A).A.String(); got != "192.0.2.1" {
+		t.Errorf("got %s, want the untailored answer", got)
+	}
+	if clientSubnet(resp) != nil {
+		t.Errorf("stripped query should not be answered with ECS")
+	}
+	// The answer no longer depends on the client, so the cache serves it.
+	ecsQuery(t, p, "www.example.com.", "203.0.113.0/24", false)
+	for _, s := range u.subnets() {
+		if s != "" {
+			t.Fatalf("upstream learned client subnet %s", s)
+		}
+	}
+	if got := len(u.subnets()); got != 2 {
+		t.Fatalf("upstream saw %d queries, want the probe and one miss", got)
+	}
+}
+
+func TestServeDNS_ECSSet(t *testing.T) {
+	u := startECSUpstream(t)
+	p := newECSProxy(t, u.addr, "198.51.100.77/24")
+
+	plain := ecsQuery(t, p, "a.example.com.", "", false)
+	if got := plain.Answer[0].(*dns.A).A.String(); got != "198.51.100.1" {
+		t.Errorf("got %s, want an answer for the configured subnet", got)
+	}
+	if plain.IsEdns0() != nil {
+		t.Errorf("a client without EDNS must not get an OPT record back")
+	}
+
+	withEDNS := ecsQuery(t, p, "b.example.com.", "", true)
+	if withEDNS.IsEdns0() == nil || clientSubnet(withEDNS) != nil {
+		t.Errorf("an EDNS client keeps its OPT record but gets no subnet it did not send: %v", withEDNS.IsEdns0())
+	}
+
+	ecsQuery(t, p, "c.example.com.", "203.0.113.0/24", false)
+	for _, s := range u.subnets()[1:] {
+		if s != "198.51.100.0/24" {
+			t.Fatalf("upstream saw subnet %q, want only the configured one", s)
+		}
+	}
+}
+
+func TestParseECS(t *testing.T) {
+	for raw, want := range map[string]ECSConfig{
+		"passthrough":   {Mode: ECSPassthrough},
+		" Strip ":       {Mode: ECSStrip},
+		"10.1.2.3/16":   {Mode: ECSSet, Subnet: &net.IPNet{IP: net.IP{10, 1, 0, 0}, Mask: net.CIDRMask(16, 32)}},
+		"2001:db8::/56": {Mode: ECSSet, Subnet: &net.IPNet{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(56, 128)}},
+	} {
+		got, err := ParseECS(raw)
+		if err != nil {
+			t.Fatalf("ParseECS(%q): %v", raw, err)
+		}
+		if got.Mode != want.Mode || got.Subnet.String() != want.Subnet.String() {
+			t.Errorf("ParseECS(%q) = %v %v, want %v %v", raw, got.Mode, got.Subnet, want.Mode, want.Subnet)
+		}
+	}
+	for _, raw := range []string{"", "on", "10.1.2.3"} {
+		if _, err := ParseECS(raw); err == nil {
+			t.Errorf("ParseECS(%q) should fail", raw)
+		}
+	}
+	if err := (&Proxy{}).SetECS(ECSConfig{Mode: ECSSet}); err == nil {
+		t.Errorf("set mode without a subnet should be rejected")
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"net"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// answerWith returns a stub answer func replying with a fixed A record, so a
+// reply shows which upstream produced it.
+func answerWith(ip string) func(q dns.Question, resp *dns.Msg) {
+	return func(q dns.Question, resp *dns.Msg) {
+		resp.Answer = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.ParseIP(ip)}}
+	}
+}
+
+func TestServeDNS_ConditionalForwarding(t *testing.T) {
+	public := startStub(t, "127.0.0.1:0", answerWith("192.0.2.1"))
+	cluster := startStub(t, "127.0.0.1:0", answerWith("10.96.0.1"))
+	corp := startStub(t, "127.0.0.1:0", answerWith("10.0.0.1"))
+	corpEU := startStub(t, "127.0.0.1:0", answerWith("10.1.0.1"))
+
+	p := &Proxy{upstream: public.addr}
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"allow","egress":[{"action":"deny","target":"*.blocked.corp.example.com"}],"forward":[
+		{"suffix":"svc.cluster.local","upstream":"`+cluster.addr+`"},
+		{"suffix":"corp.example.com","upstream":"`+corp.addr+`"},
+		{"suffix":"eu.corp.example.com","upstream":"`+corpEU.addr+`"}
+	]}`))
+
+	probe := new(dns.Msg)
+	probe.SetQuestion("probe.example.com.", dns.TypeA)
+	_, err := p.forward(probe)
+	skipIfNoMark(t, err)
+	if err != nil {
+		t.Fatalf("forward: %v", err)
+	}
+
+	cases := map[string]string{
+		"api.default.svc.cluster.local.": "10.96.0.1",
+		"git.corp.example.com.":          "10.0.0.1",
+		"git.eu.corp.example.com.":       "10.1.0.1",
+		"www.example.com.":               "192.0.2.1",
+		"cluster.local.":                 "192.0.2.1",
+	}
+	for name, want := range cases {
+		resp := query(t, p, name, dns.TypeA)
+		if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 {
+			t.Fatalf("%s: unexpected reply %v", name, resp)
+		}
+		if got := resp.Answer[0].(*dns.A).A.String(); got != want {
+			t.Errorf("%s answered by the upstream for %s, want %s", name, got, want)
+		}
+	}
+
+	// The policy still applies before forwarding.
+	if resp := query(t, p, "x.blocked.corp.example.com.", dns.TypeA); resp.Rcode != dns.RcodeNameError {
+		t.Fatalf("expected NXDOMAIN for a denied name, got %v", resp)
+	}
+	for _, q := range corp.questions() {
+		if q == "x.blocked.corp.example.com. A" {
+			t.Fatalf("denied name was forwarded")
+		}
+	}
+}
+
+func TestUpdatePolicy_PurgesAnswersOfReroutedNames(t *testing.T) {
+	public := startStub(t, "127.0.0.1:0", answerWith("192.0.2.1"))
+	corp := startStub(t, "127.0.0.1:0", answerWith("10.0.0.1"))
+
+	p := &Proxy{upstream: public.addr}
+	p.SetCache(true)
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"allow"}`))
+	probe := new(dns.Msg)
+	probe.SetQuestion("probe.example.com.", dns.TypeA)
+	_, err := p.forward(probe)
+	skipIfNoMark(t, err)
+	if err != nil {
+		t.Fatalf("forward: %v", err)
+	}
+
+	query(t, p, "git.corp.example.com.", dns.TypeA)
+	query(t, p, "www.example.com.", dns.TypeA)
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"allow","forward":[{"suffix":"corp.example.com","upstream":"`+corp.addr+`"}]}`))
+
+	if got := query(t, p, "git.corp.example.com.", dns.TypeA).Answer[0].(*dns.A).A.String(); got != "10.0.0.1" {
+		t.Fatalf("expected the rerouted name to be resolved by the new upstream, got %s", got)
+	}
+	if _, ok := p.cache.entries[cacheKey{name: "www.example.com.", qtype: dns.TypeA, qclass: dns.ClassINET}]; !ok {
+		t.Fatalf("answer of a name whose upstream did not change was purged")
+	}
+}
+
+func TestForward_StickyUpstreamPerName(t *testing.T) {
+	var refuse atomic.Bool
+	first := startStub(t, "127.0.0.1:0", answerWith("192.0.2.1"))
+	second := startStub(t, "127.0.0.1:0", func(q dns.Question, resp *dns.Msg) {
+		if refuse.Load() {
+			resp.Rcode = dns.RcodeRefused
+			return
+		}
+		answerWith("192.0.2.2")(q, resp)
+	})
+
+	p := &Proxy{upstream: first.addr, upstreams: []string{first.addr, second.addr}}
+	p.SetStickyUpstream(true)
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"allow"}`))
+	answer := func(name string) string {
+		t.Helper()
+		req := new(dns.Msg)
+		req.SetQuestion(name, dns.TypeA)
+		resp, err := p.forward(req)
+		skipIfNoMark(t, err)
+		if err != nil || len(resp.Answer) != 1 {
+			t.Fatalf("forward %s: %v, %v", name, resp, err)
+		}
+		return resp.Answer[0].(*dns.A).A.String()
+	}
+	asked := func(s *stubServer, name string) int {
+		return len(slices.DeleteFunc(s.questions(), func(q string) bool { return q != name+" A" }))
+	}
+
+	// names not seen yet are spread over the upstreams, then stay on theirs
+	if answer("a.example.com.") != "192.0.2.1" || answer("b.example.com.") != "192.0.2.2" {
+		t.Fatalf("expected new names to be spread over the upstreams")
+	}
+	for i := 0; i < 5; i++ {
+		if got := answer("b.example.com."); got != "192.0.2.2" {
+			t.Fatalf("query %d for b.example.com. answered by %s, want the upstream that answered it before", i, got)
+		}
+		if got := answer("A.Example.COM."); got != "192.0.2.1" {
+			t.Fatalf("query %d for a.example.com. answered by %s, want the upstream that answered it before", i, got)
+		}
+	}
+	if asked(second, "b.example.com.") != 6 || asked(first, "b.example.com.") != 0 {
+		t.Fatalf("expected every query for b.example.com. to reach only its upstream: %v / %v", first.questions(), second.questions())
+	}
+
+	// a failing upstream falls back, and the name sticks to the one that answered
+	refuse.Store(true)
+	if got := answer("b.example.com."); got != "192.0.2.1" {
+		t.Fatalf("expected a fallback to the healthy upstream, got %s", got)
+	}
+	refuse.Store(false)
+	if got := answer("b.example.com."); got != "192.0.2.1" {
+		t.Fatalf("expected the name to stay on the upstream that answered it last, got %s", got)
+	}
+	if asked(second, "b.example.com.") != 7 {
+		t.Fatalf("expected the recovered upstream not to be asked again: %v", second.questions())
+	}
+}
+
+func TestParseStickyUpstream(t *testing.T) {
+	if enabled, err := ParseStickyUpstream(" true "); err != nil || !enabled {
+		t.Fatalf("ParseStickyUpstream = %v, %v", enabled, err)
+	}
+	if _, err := ParseStickyUpstream("sometimes"); err == nil {
+		t.Fatalf("expected a non-boolean to be rejected")
+	}
+}
+
+func TestForward_LatencyUpstreamPrefersFastest(t *testing.T) {
+	var refuse atomic.Bool
+	slow := startStub(t, "127.0.0.1:0", func(q dns.Question, resp *dns.Msg) {
+		time.Sleep(30 * time.Millisecond)
+		answerWith("192.0.2.1")(q, resp)
+	})
+	fast := startStub(t, "127.0.0.1:0", func(q dns.Question, resp *dns.Msg) {
+		if refuse.Load() {
+			resp.Rcode = dns.RcodeRefused
+			return
+		}
+		answerWith("192.0.2.2")(q, resp)
+	})
+
+	// the slow upstream comes first in resolv.conf
+	p := &Proxy{upstream: slow.addr, upstreams: []string{slow.addr, fast.addr}}
+	p.SetLatencyUpstream(true)
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"allow"}`))
+	answer := func(name string) string {
+		t.Helper()
+		req := new(dns.Msg)
+		req.SetQuestion(name, dns.TypeA)
+		resp, err := p.forward(req)
+		skipIfNoMark(t, err)
+		if err != nil || len(resp.Answer) != 1 {
+			t.Fatalf("forward %s: %v, %v", name, resp, err)
+		}
+		return resp.Answer[0].(*dns.A).A.String()
+	}
+
+	// both are measured once, then only probes reach the slow one
+	fastAnswers := 0
+	for i := 0; i < 2*latencyProbeEvery; i++ {
+		if answer("www.example.com.") == "192.0.2.2" {
+			fastAnswers++
+		}
+	}
+	if got := len(slow.questions()); got != 3 {
+		t.Fatalf("expected the slow upstream to see its first measurement and 2 probes, got %d queries", got)
+	}
+	if fastAnswers != 2*latencyProbeEvery-3 {
+		t.Fatalf("expected all other queries to be answered by the fast upstream, got %d", fastAnswers)
+	}
+
+	// a failing upstream falls back right away and drops below the slow one
+	refuse.Store(true)
+	if got := answer("www.example.com."); got != "192.0.2.1" {
+		t.Fatalf("expected a fallback to the slow upstream, got %s", got)
+	}
+	refuse.Store(false)
+	if got := answer("www.example.com."); got != "192.0.2.1" {
+		t.Fatalf("expected the failed upstream to rank below the slow one, got %s", got)
+	}
+}
+
+func TestLatencyUpstreams_SkipsFailingUpstreams(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := &latencyUpstreams{stats: make(map[string]*upstreamLatency), now: func() time.Time { return now }}
+	upstreams := []string{"a:53", "b:53"}
+	s.observe("a:53", time.Millisecond, true)
+	s.observe("b:53", 6*time.Second, true)
+	for i := 0; i < latencyMaxFailures; i++ {
+		s.observe("a:53", time.Millisecond, false)
+	}
+	// b's average is worse than a's penalized one, but a is down
+	if order := s.order(upstreams); order[0] != "b:53" {
+		t.Fatalf("expected the failing upstream last, got %v", order)
+	}
+	now = now.Add(latencyCooldown)
+	if order := s.order(upstreams); order[0] != "a:53" {
+		t.Fatalf("expected the upstream back by its average after the cooldown, got %v", order)
+	}
+}
+
+func TestLatencyUpstreams_ProbesOnlyHealthyUpstreams(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := &latencyUpstreams{stats: make(map[string]*upstreamLatency), now: func() time.Time { return now }}
+	upstreams := []string{"a:53", "b:53", "c:53"}
+	s.observe("a:53", time.Millisecond, true)
+	s.observe("b:53", 10*time.Millisecond, true)
+	for i := 0; i < latencyMaxFailures; i++ {
+		s.observe("c:53", time.Millisecond, false)
+	}
+	probed := map[string]int{}
+	for i := 0; i < 10*latencyProbeEvery; i++ {
+		probed[s.order(upstreams)[0]]++
+	}
+	if probed["c:53"] != 0 {
+		t.Fatalf("a down upstream must not be probed, it went first %d times", probed["c:53"])
+	}
+	if probed["b:53"] != 10 {
+		t.Fatalf("expected every probe to go to the slower healthy upstream, got %v", probed)
+	}
+
+	// with a single healthy upstream there is nothing to probe
+	for i := 0; i < latencyMaxFailures; i++ {
+		s.observe("b:53", time.Millisecond, false)
+	}
+	for i := 0; i < latencyProbeEvery; i++ {
+		if first := s.order(upstreams)[0]; first != "a:53" {
+			t.Fatalf("expected the only healthy upstream first, got %s", first)
+		}
+	}
+}
+
+func TestParseLatencyUpstream(t *testing.T) {
+	if enabled, err := ParseLatencyUpstream("1"); err != nil || !enabled {
+		t.Fatalf("ParseLatencyUpstream = %v, %v", enabled, err)
+	}
+	if _, err := ParseLatencyUpstream("fastest"); err == nil {
+		t.Fatalf("expected a non-boolean to be rejected")
+	}
+}
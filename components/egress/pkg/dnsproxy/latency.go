@@ -0,0 +1,177 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// latencyAlpha is the weight of a new sample in an upstream's moving
+	// average; the rest is its history.
+	latencyAlpha = 0.3
+	// latencyFailurePenalty is the sample recorded for a failed exchange, the
+	// upstream client timeout, so a failing upstream sinks in the ranking.
+	latencyFailurePenalty = 5 * time.Second
+	// every latencyProbeEvery-th query tries a slower upstream first, in turn,
+	// so one that got faster is noticed.
+	latencyProbeEvery = 20
+	// an upstream failing latencyMaxFailures times in a row is only tried
+	// after the healthy ones for latencyCooldown.
+	latencyMaxFailures = 3
+	latencyCooldown    = 30 * time.Second
+)
+
+// upstreamLatency is what latency selection knows about one upstream.
+type upstreamLatency struct {
+	ewma      time.Duration // zero until the upstream has answered or failed once
+	measured  bool
+	failures  int       // consecutive failed exchanges
+	downUntil time.Time // set after latencyMaxFailures failures in a row
+}
+
+// latencyUpstreams ranks the upstreams by an exponentially weighted moving
+// average of their response times.
+type latencyUpstreams struct {
+	mu      sync.Mutex
+	stats   map[string]*upstreamLatency
+	queries uint64
+	now     func() time.Time
+}
+
+// SetLatencyUpstream makes the proxy use every nameserver of resolv.conf and
+// send each query to the one with the lowest moving average of recent
+// response times, skipping upstreams that keep failing. Upstreams not measured
+// yet are tried first, and every 20th query goes to a slower healthy one in
+// turn so its average follows it. When the chosen upstream fails, the others are
+// tried from fastest to slowest. Conditional upstreams and query name
+// minimization are not affected, and sticky selection takes precedence.
+func (p *Proxy) SetLatencyUpstream(enabled bool) {
+	if !enabled {
+		p.latency = nil
+		return
+	}
+	p.latency = &latencyUpstreams{stats: make(map[string]*upstreamLatency), now: time.Now}
+}
+
+// ParseLatencyUpstream parses the on/off switch, e.g. "true" or "1".
+func ParseLatencyUpstream(raw string) (bool, error) {
+	enabled, err := strconv.ParseBool(strings.TrimSpace(raw))
+	if err != nil {
+		return false, fmt.Errorf("latency upstream %q is not a boolean: %w", raw, err)
+	}
+	return enabled, nil
+}
+
+// exchangeLatency sends r to the upstreams in latency order until one answers
+// with something other than an error, SERVFAIL or REFUSED, timing each
+// exchange. The last failure is returned when all of them fail.
+func (p *Proxy) exchangeLatency(r *dns.Msg) (*dns.Msg, error) {
+	var (
+		resp *dns.Msg
+		err  error
+	)
+	for _, server := range p.latency.order(p.Upstreams()) {
+		start := time.Now()
+		resp, err = p.exchange(r, server)
+		ok := err == nil && resp.Rcode != dns.RcodeServerFailure && resp.Rcode != dns.RcodeRefused
+		p.latency.observe(server, time.Since(start), ok)
+		if ok {
+			return resp, nil
+		}
+	}
+	return resp, err
+}
+
+// order returns the upstreams to try, healthy ones first and each group from
+// lowest to highest average; unmeasured upstreams count as the fastest. On
+// probe queries the next slower healthy upstream in turn is moved to the front.
+func (s *latencyUpstreams) order(upstreams []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	type ranked struct {
+		server string
+		down   bool
+		ewma   time.Duration
+	}
+	ranking := make([]ranked, len(upstreams))
+	for i, server := range upstreams {
+		ranking[i] = ranked{server: server}
+		if st := s.stats[server]; st != nil {
+			ranking[i].down = now.Before(st.downUntil)
+			ranking[i].ewma = st.ewma
+		}
+	}
+	sort.SliceStable(ranking, func(i, j int) bool {
+		if ranking[i].down != ranking[j].down {
+			return !ranking[i].down
+		}
+		return ranking[i].ewma < ranking[j].ewma
+	})
+	order := make([]string, len(ranking))
+	healthy := 0
+	for i, r := range ranking {
+		order[i] = r.server
+		if !r.down {
+			healthy++
+		}
+	}
+
+	// a down upstream is not probed: it would likely cost the query a timeout
+	n := s.queries
+	s.queries++
+	if healthy > 1 && n%latencyProbeEvery == latencyProbeEvery-1 {
+		probe := 1 + int(n/latencyProbeEvery)%(healthy-1)
+		server := order[probe]
+		copy(order[1:probe+1], order[:probe])
+		order[0] = server
+	}
+	return order
+}
+
+// observe folds one exchange with server into its average and health.
+func (s *latencyUpstreams) observe(server string, elapsed time.Duration, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stats[server]
+	if st == nil {
+		st = &upstreamLatency{}
+		s.stats[server] = st
+	}
+	sample := elapsed
+	if ok {
+		st.failures = 0
+		st.downUntil = time.Time{}
+	} else {
+		sample = max(elapsed, latencyFailurePenalty)
+		st.failures++
+		if st.failures >= latencyMaxFailures {
+			st.downUntil = s.now().Add(latencyCooldown)
+		}
+	}
+	if !st.measured {
+		st.ewma, st.measured = sample, true
+		return
+	}
+	st.ewma = time.Duration(latencyAlpha*float64(sample) + (1-latencyAlpha)*float64(st.ewma))
+}
@@ -0,0 +1,160 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	maxUDPReadBuffer = 64 << 20
+	maxTCPBacklog    = 65535
+	maxListenWorkers = 64
+)
+
+// ListenOptions tune the proxy's sockets for query bursts. The zero value
+// keeps the system defaults and one UDP and one TCP socket.
+type ListenOptions struct {
+	// UDPReadBuffer is the UDP socket's receive buffer in bytes; 0 keeps the
+	// system default.
+	UDPReadBuffer int
+	// TCPBacklog is the TCP listen backlog; 0 keeps the system default.
+	TCPBacklog int
+	// ReusePort sets SO_REUSEPORT so other processes, or Workers, can bind the
+	// same address. Linux only.
+	ReusePort bool
+	// Workers is the number of UDP and TCP socket pairs served in parallel;
+	// more than one requires ReusePort. 0 means 1.
+	Workers int
+}
+
+// SetListenOptions validates and stores the socket options applied by Start.
+func (p *Proxy) SetListenOptions(opts ListenOptions) error {
+	if opts.UDPReadBuffer < 0 || opts.UDPReadBuffer > maxUDPReadBuffer {
+		return fmt.Errorf("udp read buffer must be between 0 and %d bytes", maxUDPReadBuffer)
+	}
+	if opts.TCPBacklog < 0 || opts.TCPBacklog > maxTCPBacklog {
+		return fmt.Errorf("tcp backlog must be between 0 and %d", maxTCPBacklog)
+	}
+	if opts.Workers < 0 || opts.Workers > maxListenWorkers {
+		return fmt.Errorf("workers must be between 0 and %d", maxListenWorkers)
+	}
+	if opts.Workers > 1 && !opts.ReusePort {
+		return fmt.Errorf("%d workers need reuse port to share the address", opts.Workers)
+	}
+	if err := checkListenOptions(opts); err != nil {
+		return err
+	}
+	p.listen = opts
+	return nil
+}
+
+// ParseListenCount parses a non-negative socket option such as a buffer size
+// or backlog; empty means 0.
+func ParseListenCount(raw string) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a non-negative number", raw)
+	}
+	return n, nil
+}
+
+// listenServers opens the sockets described by p.listen on addr and wraps
+// each in a server. A port of 0 is resolved by the first socket, so all of
+// them share one port.
+func (p *Proxy) listenServers(ctx context.Context, addr string, handler dns.Handler) ([]*dns.Server, error) {
+	handler = recoverQueries(handler)
+	opts := p.listen
+	workers := max(opts.Workers, 1)
+	lc := listenConfig(opts)
+	var servers []*dns.Server
+	closeAll := func() { closeServers(servers) }
+	for i := 0; i < workers; i++ {
+		pc, err := lc.ListenPacket(ctx, "udp", addr)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("listen udp %s: %w", addr, err)
+		}
+		servers = append(servers, &dns.Server{PacketConn: pc, Handler: handler})
+		addr = pc.LocalAddr().String()
+		if opts.UDPReadBuffer > 0 {
+			if err := setUDPReadBuffer(pc.(*net.UDPConn), opts.UDPReadBuffer); err != nil {
+				closeAll()
+				return nil, fmt.Errorf("set udp read buffer: %w", err)
+			}
+		}
+
+		ln, err := lc.Listen(ctx, "tcp", addr)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("listen tcp %s: %w", addr, err)
+		}
+		servers = append(servers, &dns.Server{Listener: ln, Handler: handler})
+		if opts.TCPBacklog > 0 {
+			if err := setTCPBacklog(ln.(*net.TCPListener), opts.TCPBacklog); err != nil {
+				closeAll()
+				return nil, fmt.Errorf("set tcp backlog: %w", err)
+			}
+		}
+	}
+	return servers, nil
+}
+
+// closeServers closes the sockets of servers that were never started.
+func closeServers(servers []*dns.Server) {
+	for _, srv := range servers {
+		if srv.PacketConn != nil {
+			_ = srv.PacketConn.Close()
+		}
+		if srv.Listener != nil {
+			_ = srv.Listener.Close()
+		}
+	}
+}
+
+// recoverQueries answers a query whose handler panics with SERVFAIL. The
+// server runs each query on a goroutine of its own, where a panic would
+// otherwise take the whole process down.
+func recoverQueries(handler dns.Handler) dns.Handler {
+	return dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		defer func() {
+			if v := recover(); v != nil {
+				log.Printf("[dns] panic answering %s: %v", queryName(r), v)
+				resp := new(dns.Msg)
+				resp.SetRcode(r, dns.RcodeServerFailure)
+				_ = w.WriteMsg(resp)
+			}
+		}()
+		handler.ServeDNS(w, r)
+	})
+}
+
+func queryName(r *dns.Msg) string {
+	if len(r.Question) == 0 {
+		return "an empty query"
+	}
+	return r.Question[0].Name
+}
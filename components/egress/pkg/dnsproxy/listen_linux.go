@@ -0,0 +1,76 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package dnsproxy
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func checkListenOptions(ListenOptions) error { return nil }
+
+func listenConfig(opts ListenOptions) net.ListenConfig {
+	if !opts.ReusePort {
+		return net.ListenConfig{}
+	}
+	return net.ListenConfig{Control: func(_, _ string, c syscall.RawConn) error {
+		var opErr error
+		if err := c.Control(func(fd uintptr) {
+			opErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+		}); err != nil {
+			return err
+		}
+		return opErr
+	}}
+}
+
+// setUDPReadBuffer prefers SO_RCVBUFFORCE, which the sidecar's CAP_NET_ADMIN
+// allows beyond net.core.rmem_max, and falls back to a capped SO_RCVBUF.
+func setUDPReadBuffer(conn *net.UDPConn, size int) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var forceErr error
+	if err := raw.Control(func(fd uintptr) {
+		forceErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RCVBUFFORCE, size)
+	}); err != nil {
+		return err
+	}
+	if forceErr == nil {
+		return nil
+	}
+	return conn.SetReadBuffer(size)
+}
+
+// setTCPBacklog calls listen(2) again on the bound socket, which Linux
+// accepts to resize the accept queue; it stays capped by net.core.somaxconn.
+func setTCPBacklog(ln *net.TCPListener, backlog int) error {
+	raw, err := ln.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var opErr error
+	if err := raw.Control(func(fd uintptr) {
+		opErr = unix.Listen(int(fd), backlog)
+	}); err != nil {
+		return err
+	}
+	return opErr
+}
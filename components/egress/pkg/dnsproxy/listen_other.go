@@ -0,0 +1,38 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package dnsproxy
+
+import (
+	"errors"
+	"net"
+)
+
+func checkListenOptions(opts ListenOptions) error {
+	if opts.ReusePort {
+		return errors.New("reuse port is only supported on linux")
+	}
+	if opts.TCPBacklog > 0 {
+		return errors.New("tcp backlog is only supported on linux")
+	}
+	return nil
+}
+
+func listenConfig(ListenOptions) net.ListenConfig { return net.ListenConfig{} }
+
+func setUDPReadBuffer(conn *net.UDPConn, size int) error { return conn.SetReadBuffer(size) }
+
+func setTCPBacklog(*net.TCPListener, int) error { return nil }
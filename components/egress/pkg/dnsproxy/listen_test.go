@@ -0,0 +1,168 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package dnsproxy
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/sys/unix"
+)
+
+// sockopt reads an option from the socket behind c.
+func sockopt(t *testing.T, c syscall.Conn, read func(fd int) (int, error)) int {
+	t.Helper()
+	raw, err := c.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v int
+	var opErr error
+	if err := raw.Control(func(fd uintptr) { v, opErr = read(int(fd)) }); err != nil {
+		t.Fatal(err)
+	}
+	if opErr != nil {
+		t.Fatal(opErr)
+	}
+	return v
+}
+
+func TestStart_AppliesListenOptions(t *testing.T) {
+	p := &Proxy{listenAddr: "127.0.0.1:0", policy: mustPolicy(t, `{"defaultAction":"deny"}`)}
+	if err := p.SetListenOptions(ListenOptions{UDPReadBuffer: 1 << 20, TCPBacklog: 77, ReusePort: true, Workers: 2}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := p.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if len(p.servers) != 4 {
+		t.Fatalf("expected a udp and a tcp server per worker, got %d", len(p.servers))
+	}
+
+	var addr string
+	for _, srv := range p.servers {
+		var conn syscall.Conn
+		if srv.PacketConn != nil {
+			conn = srv.PacketConn.(*net.UDPConn)
+			// the kernel reports twice the requested size for its bookkeeping
+			if got := sockopt(t, conn, func(fd int) (int, error) { return unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_RCVBUF) }); got < 1<<20 {
+				t.Errorf("SO_RCVBUF = %d, want at least %d", got, 1<<20)
+			}
+			if addr == "" {
+				addr = srv.PacketConn.LocalAddr().String()
+			} else if got := srv.PacketConn.LocalAddr().String(); got != addr {
+				t.Errorf("workers listen on %s and %s", addr, got)
+			}
+		} else {
+			conn = srv.Listener.(*net.TCPListener)
+			// for a listening socket tcpi_sacked is the accept queue limit
+			backlog := sockopt(t, conn, func(fd int) (int, error) {
+				info, err := unix.GetsockoptTCPInfo(fd, unix.IPPROTO_TCP, unix.TCP_INFO)
+				if err != nil {
+					return 0, err
+				}
+				return int(info.Sacked), nil
+			})
+			if backlog != 77 {
+				t.Errorf("tcp backlog = %d, want 77", backlog)
+			}
+			if got := srv.Listener.Addr().String(); got != addr {
+				t.Errorf("tcp listens on %s, udp on %s", got, addr)
+			}
+		}
+		if got := sockopt(t, conn, func(fd int) (int, error) { return unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_REUSEPORT) }); got != 1 {
+			t.Errorf("SO_REUSEPORT = %d, want 1", got)
+		}
+	}
+
+	// another process could share the port, and the workers answer queries
+	other := &Proxy{listenAddr: addr}
+	if err := other.SetListenOptions(ListenOptions{ReusePort: true}); err != nil {
+		t.Fatal(err)
+	}
+	servers, err := other.listenServers(ctx, addr, dns.HandlerFunc(other.serveDNS))
+	if err != nil {
+		t.Fatalf("second proxy could not share %s: %v", addr, err)
+	}
+	for _, srv := range servers {
+		if srv.PacketConn != nil {
+			_ = srv.PacketConn.Close()
+		} else {
+			_ = srv.Listener.Close()
+		}
+	}
+	req := new(dns.Msg)
+	req.SetQuestion("denied.example.com.", dns.TypeA)
+	for _, network := range []string{"udp", "tcp"} {
+		client := &dns.Client{Net: network, Timeout: 2 * time.Second}
+		resp, _, err := client.Exchange(req, addr)
+		if err != nil || resp.Rcode != dns.RcodeNameError {
+			t.Fatalf("%s query: %v %v", network, resp, err)
+		}
+	}
+}
+
+func TestStart_DefaultListenersKeepOneSocketPair(t *testing.T) {
+	p := &Proxy{listenAddr: "127.0.0.1:0"}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := p.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if len(p.servers) != 2 {
+		t.Fatalf("expected one udp and one tcp server, got %d", len(p.servers))
+	}
+	if got := sockopt(t, p.servers[0].PacketConn.(*net.UDPConn), func(fd int) (int, error) { return unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_REUSEPORT) }); got != 0 {
+		t.Fatalf("SO_REUSEPORT set without being asked for")
+	}
+	// without reuse port the address stays exclusive
+	if _, err := net.ListenPacket("udp", p.servers[0].PacketConn.LocalAddr().String()); err == nil {
+		t.Fatalf("expected the port to be taken")
+	}
+}
+
+func TestSetListenOptionsValidation(t *testing.T) {
+	p := &Proxy{}
+	for _, opts := range []ListenOptions{
+		{UDPReadBuffer: -1},
+		{UDPReadBuffer: maxUDPReadBuffer + 1},
+		{TCPBacklog: maxTCPBacklog + 1},
+		{Workers: 2},
+		{Workers: maxListenWorkers + 1, ReusePort: true},
+	} {
+		if err := p.SetListenOptions(opts); err == nil {
+			t.Errorf("expected %+v to be rejected", opts)
+		}
+	}
+	if err := p.SetListenOptions(ListenOptions{UDPReadBuffer: 4 << 20, TCPBacklog: 4096, ReusePort: true, Workers: 4}); err != nil {
+		t.Fatalf("expected valid options to be accepted, got %v", err)
+	}
+	for raw, want := range map[string]int{"": 0, " 4096 ": 4096} {
+		if got, err := ParseListenCount(raw); err != nil || got != want {
+			t.Errorf("ParseListenCount(%q) = %d, %v", raw, got, err)
+		}
+	}
+	if _, err := ParseListenCount("-1"); err == nil {
+		t.Errorf("expected a negative count to be rejected")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"github.com/alibaba/opensandbox/egress/pkg/policy"
+)
+
+var listenerLabelPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Listener is an address the proxy answers on besides its main one, e.g. the
+// pod IP for queries from sidecars next to the loopback address used by the
+// sandbox's own processes.
+type Listener struct {
+	// Label names the listener in the query log and in block events.
+	Label string
+	Addr  string
+	// Policy decides the queries arriving on the listener; nil shares the
+	// proxy's policy and its updates. Conditional forwarding and the answer
+	// cache still follow the proxy's policy.
+	Policy *policy.NetworkPolicy
+}
+
+// AddListener has Start also serve l. It must be called before Start; the
+// listen options apply to every listener.
+func (p *Proxy) AddListener(l Listener) error {
+	if !listenerLabelPattern.MatchString(l.Label) {
+		return fmt.Errorf("listener label %q must be lowercase letters, digits, '-' or '_'", l.Label)
+	}
+	if _, _, err := net.SplitHostPort(l.Addr); err != nil {
+		return fmt.Errorf("listener %s: invalid address %q: %w", l.Label, l.Addr, err)
+	}
+	for _, other := range p.listeners {
+		if other.Label == l.Label {
+			return fmt.Errorf("duplicate listener label %q", l.Label)
+		}
+	}
+	if l.Policy != nil {
+		l.Policy = ensurePolicyDefaults(l.Policy)
+	}
+	p.listeners = append(p.listeners, l)
+	return nil
+}
+
+// Listeners returns the listeners added besides the main one.
+func (p *Proxy) Listeners() []Listener {
+	return append([]Listener(nil), p.listeners...)
+}
+
+// ParseListeners parses comma-separated "label=host:port" listeners, each
+// optionally followed by "@path" naming a policy file for its queries, e.g.
+// "sidecar=10.0.0.5:15353@/etc/egress/sidecar.json".
+func ParseListeners(raw string) ([]Listener, error) {
+	var listeners []Listener
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		label, rest, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("listener %q: expected label=host:port[@policy file]", entry)
+		}
+		l := Listener{Label: strings.TrimSpace(label)}
+		addr, path, withPolicy := strings.Cut(rest, "@")
+		l.Addr = strings.TrimSpace(addr)
+		if withPolicy {
+			path = strings.TrimSpace(path)
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("listener %s: %w", l.Label, err)
+			}
+			if l.Policy, err = policy.ParsePolicyFrom(path, string(raw)); err != nil {
+				return nil, fmt.Errorf("listener %s: policy %s: %w", l.Label, path, err)
+			}
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// listenerHandler serves the queries arriving on l.
+func (p *Proxy) listenerHandler(l Listener) dns.Handler {
+	return dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		p.serveQuery(w, r, &l)
+	})
+}
+
+// listenerField renders the listener a query arrived on for a log line, or
+// nothing for the main listener.
+func listenerField(l *Listener) string {
+	if l == nil {
+		return ""
+	}
+	return " listener=" + l.Label
+}
+
+func listenerLabel(l *Listener) string {
+	if l == nil {
+		return ""
+	}
+	return l.Label
+}
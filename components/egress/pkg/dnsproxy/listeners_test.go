@@ -0,0 +1,148 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/alibaba/opensandbox/egress/pkg/policy"
+)
+
+func TestStart_ListenersDecideWithTheirOwnPolicy(t *testing.T) {
+	// the main listener allows everything but tracker.test; the sidecar one
+	// only allows telemetry.test
+	p := &Proxy{listenAddr: "127.0.0.1:0", upstream: "127.0.0.1:1",
+		policy: mustPolicy(t, `{"defaultAction":"allow","egress":[{"action":"deny","target":"tracker.test"}]}`)}
+	if err := p.AddListener(Listener{Label: "sidecar", Addr: "127.0.0.1:0",
+		Policy: mustPolicy(t, `{"defaultAction":"deny","egress":[{"action":"allow","target":"telemetry.test"}]}`)}); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var events []BlockEvent
+	p.SetBlockHandler(func(ev BlockEvent) {
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+	})
+	logs := captureQueryLog(p)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := p.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if len(p.servers) != 4 {
+		t.Fatalf("expected a udp and a tcp server per listener, got %d", len(p.servers))
+	}
+	mainAddr, sidecarAddr := p.servers[0].PacketConn.LocalAddr().String(), p.servers[2].PacketConn.LocalAddr().String()
+
+	exchange := func(addr, name string) *dns.Msg {
+		t.Helper()
+		req := new(dns.Msg)
+		req.SetQuestion(name, dns.TypeA)
+		client := &dns.Client{Timeout: 10 * time.Second}
+		resp, _, err := client.Exchange(req, addr)
+		if err != nil {
+			t.Fatalf("query %s on %s: %v", name, addr, err)
+		}
+		return resp
+	}
+	if resp := exchange(mainAddr, "tracker.test."); resp.Rcode != dns.RcodeNameError {
+		t.Fatalf("main listener: expected NXDOMAIN for tracker.test, got %v", resp)
+	}
+	if resp := exchange(sidecarAddr, "tracker.test."); resp.Rcode != dns.RcodeNameError {
+		t.Fatalf("sidecar listener: expected NXDOMAIN for tracker.test, got %v", resp)
+	}
+	// allowed names are forwarded, to an upstream that does not answer
+	if resp := exchange(sidecarAddr, "telemetry.test."); resp.Rcode == dns.RcodeNameError {
+		t.Fatalf("sidecar listener blocked telemetry.test")
+	}
+	if resp := exchange(sidecarAddr, "docs.test."); resp.Rcode != dns.RcodeNameError {
+		t.Fatalf("sidecar listener: expected NXDOMAIN for docs.test, got %v", resp)
+	}
+	if resp := exchange(mainAddr, "docs.test."); resp.Rcode == dns.RcodeNameError {
+		t.Fatalf("main listener blocked docs.test")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []struct {
+		name, listener string
+		reason         policy.BlockReason
+	}{
+		{"tracker.test.", "", policy.BlockReasonExplicitDeny},
+		{"tracker.test.", "sidecar", policy.BlockReasonNoMatchingRule},
+		{"docs.test.", "sidecar", policy.BlockReasonNoMatchingRule},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d block events, got %+v", len(want), events)
+	}
+	for i, w := range want {
+		if ev := events[i]; ev.Name != w.name || ev.Listener != w.listener || ev.Reason != w.reason {
+			t.Errorf("event %d = %+v, want %s on %q for %s", i, ev, w.name, w.listener, w.reason)
+		}
+	}
+	var sidecarLines int
+	for _, line := range logs() {
+		if strings.Contains(line, " listener=sidecar") {
+			sidecarLines++
+		}
+	}
+	if sidecarLines != 2 {
+		t.Fatalf("expected the sidecar's 2 blocks to be logged with its label, got %v", logs())
+	}
+}
+
+func TestParseListeners(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sidecar.json")
+	if err := os.WriteFile(path, []byte(`{"defaultAction":"deny","egress":[{"action":"allow","target":"telemetry.test"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	listeners, err := ParseListeners(" sidecar=10.0.0.5:15353@" + path + ", debug=127.0.0.2:15353,")
+	if err != nil {
+		t.Fatalf("ParseListeners: %v", err)
+	}
+	if len(listeners) != 2 || listeners[0].Label != "sidecar" || listeners[0].Addr != "10.0.0.5:15353" || listeners[1].Addr != "127.0.0.2:15353" {
+		t.Fatalf("unexpected listeners %+v", listeners)
+	}
+	if listeners[0].Policy == nil || listeners[0].Policy.Source() != path || listeners[1].Policy != nil {
+		t.Fatalf("expected only the sidecar to carry a policy from %s, got %+v", path, listeners)
+	}
+
+	for _, raw := range []string{"10.0.0.5:15353", "sidecar=10.0.0.5:15353@" + path + ".missing"} {
+		if _, err := ParseListeners(raw); err == nil {
+			t.Errorf("ParseListeners(%q): expected an error", raw)
+		}
+	}
+	p := &Proxy{}
+	for _, l := range []Listener{{Label: "Sidecar", Addr: "10.0.0.5:15353"}, {Label: "sidecar", Addr: "10.0.0.5"}} {
+		if err := p.AddListener(l); err == nil {
+			t.Errorf("AddListener(%+v): expected an error", l)
+		}
+	}
+	if err := p.AddListener(listeners[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddListener(listeners[1]); err == nil {
+		t.Errorf("expected a duplicate label to be rejected")
+	}
+}
@@ -19,7 +19,9 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"net/url"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -34,8 +36,35 @@ type Proxy struct {
 	policyMu   sync.RWMutex
 	policy     *policy.NetworkPolicy
 	listenAddr string
-	upstream   string // single upstream for MVP
-	servers    []*dns.Server
+	upstream   string    // default upstream; policy forward rules may override it per suffix
+	upstreams  []string  // every resolv.conf nameserver, upstream first; see SetStickyUpstream and SetLatencyUpstream
+	sourceIP   net.IP    // optional local address for upstream queries
+	socks      *url.URL  // optional SOCKS5 proxy upstream queries are sent through
+	sinkholeV4 net.IP    // optional answer for blocked A queries
+	sinkholeV6 net.IP    // optional answer for blocked AAAA queries
+	minTTL     uint32    // optional floor for answer TTLs, in seconds
+	ecs        ECSConfig // EDNS Client Subnet handling; the zero value passes it through
+	// query classes besides IN that are evaluated and forwarded; others are refused
+	extraClasses []uint16
+	// per query type handling, see SetQueryTypeRules; types without a rule are forwarded and cached alike
+	qtypeRules map[uint16]qtypeRule
+	// walk the delegation chain one label at a time instead of sending the full name upstream
+	qnameMinimization bool
+	qminPort          string            // port of delegated servers; empty means 53
+	cache             *answerCache      // nil when answer caching is off
+	sticky            *stickyUpstreams  // nil when sticky upstream selection is off
+	latency           *latencyUpstreams // nil when latency-based upstream selection is off
+	blocks            blockStats
+	inflight          flightGroup // identical queries waiting on one upstream exchange
+	queries           queryLog
+	resolveHandler    func(ResolveEvent) // optional, see SetResolveHandler
+	listen            ListenOptions
+	listeners         []Listener // served besides listenAddr, see AddListener
+
+	serveMu     sync.Mutex
+	servers     []*dns.Server
+	exited      chan error // see Exited; replaced by every Start
+	stopServers func()     // shuts down the servers of the last Start on purpose
 }
 
 // New builds a proxy with resolved upstream; listenAddr can be empty for default.
@@ -46,31 +75,66 @@ func New(p *policy.NetworkPolicy, listenAddr string) (*Proxy, error) {
 	if p == nil {
 		p = policy.DefaultDenyPolicy()
 	}
-	upstream, err := discoverUpstream()
+	upstreams, err := discoverUpstreams()
 	if err != nil {
 		return nil, err
 	}
 	proxy := &Proxy{
 		listenAddr: listenAddr,
-		upstream:   upstream,
+		upstream:   upstreams[0],
+		upstreams:  upstreams,
 		policy:     ensurePolicyDefaults(p),
 	}
 	return proxy, nil
 }
 
 func (p *Proxy) Start(ctx context.Context) error {
-	handler := dns.HandlerFunc(p.serveDNS)
-
-	udpServer := &dns.Server{Addr: p.listenAddr, Net: "udp", Handler: handler}
-	tcpServer := &dns.Server{Addr: p.listenAddr, Net: "tcp", Handler: handler}
-	p.servers = []*dns.Server{udpServer, tcpServer}
+	servers, err := p.listenServers(ctx, p.listenAddr, dns.HandlerFunc(p.serveDNS))
+	if err != nil {
+		return fmt.Errorf("dns proxy failed: %w", err)
+	}
+	for _, l := range p.listeners {
+		more, err := p.listenServers(ctx, l.Addr, p.listenerHandler(l))
+		if err != nil {
+			closeServers(servers)
+			return fmt.Errorf("dns proxy failed: listener %s: %w", l.Label, err)
+		}
+		servers = append(servers, more...)
+	}
+	exited := make(chan error, 1)
+	stopped := make(chan struct{})
+	var stopOnce sync.Once
+	stop := func() {
+		stopOnce.Do(func() {
+			close(stopped)
+			for _, srv := range servers {
+				_ = srv.Shutdown()
+			}
+		})
+	}
+	p.serveMu.Lock()
+	p.servers = servers
+	p.exited = exited
+	p.stopServers = stop
+	p.serveMu.Unlock()
 
-	errCh := make(chan error, len(p.servers))
-	for _, srv := range p.servers {
+	errCh := make(chan error, len(servers))
+	for _, srv := range servers {
 		s := srv
 		go func() {
-			if err := s.ListenAndServe(); err != nil {
-				errCh <- err
+			err := activateAndServe(s)
+			select {
+			case <-stopped:
+				return
+			default:
+			}
+			if err == nil {
+				err = fmt.Errorf("%s server stopped serving", serverNet(s))
+			}
+			errCh <- err
+			select {
+			case exited <- err:
+			default:
 			}
 		}()
 	}
@@ -78,13 +142,12 @@ func (p *Proxy) Start(ctx context.Context) error {
 	// Shutdown on context done
 	go func() {
 		<-ctx.Done()
-		for _, srv := range p.servers {
-			_ = srv.Shutdown()
-		}
+		stop()
 	}()
 
 	select {
 	case err := <-errCh:
+		stop()
 		return fmt.Errorf("dns proxy failed: %w", err)
 	case <-time.After(200 * time.Millisecond):
 		// small grace window; running fine
@@ -92,25 +155,130 @@ func (p *Proxy) Start(ctx context.Context) error {
 	}
 }
 
+// activateAndServe runs srv until it stops, turning a panic in the serving
+// loop itself into an error so the proxy's death is reported instead of
+// crashing. Queries are answered on goroutines of their own, out of its
+// reach; recoverQueries covers those.
+func activateAndServe(srv *dns.Server) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s server panicked: %v", serverNet(srv), r)
+		}
+	}()
+	return srv.ActivateAndServe()
+}
+
+func serverNet(srv *dns.Server) string {
+	if srv.PacketConn != nil {
+		return "udp"
+	}
+	return "tcp"
+}
+
+// Exited delivers the first error of a server that stopped serving after
+// Start returned, other than through ctx or Restart. The proxy then no longer
+// answers on at least one transport.
+func (p *Proxy) Exited() <-chan error {
+	p.serveMu.Lock()
+	defer p.serveMu.Unlock()
+	return p.exited
+}
+
+// Restart shuts down the running servers, including any still alive, and
+// starts new ones on the configured listen addresses.
+func (p *Proxy) Restart(ctx context.Context) error {
+	p.serveMu.Lock()
+	stop := p.stopServers
+	p.serveMu.Unlock()
+	if stop != nil {
+		stop()
+	}
+	return p.Start(ctx)
+}
+
 func (p *Proxy) serveDNS(w dns.ResponseWriter, r *dns.Msg) {
+	p.serveQuery(w, r, nil)
+}
+
+// serveQuery answers r, which arrived on listener l or, when l is nil, on the
+// main listen address.
+func (p *Proxy) serveQuery(w dns.ResponseWriter, r *dns.Msg, l *Listener) {
 	if len(r.Question) == 0 {
 		_ = w.WriteMsg(new(dns.Msg)) // empty response
 		return
 	}
 	q := r.Question[0]
 	domain := q.Name
+	if !p.classAllowed(q.Qclass) {
+		p.recordBlock(q, l, BlockReasonUnsupportedClass, "", nil)
+		resp := new(dns.Msg)
+		resp.SetRcode(r, dns.RcodeRefused)
+		_ = w.WriteMsg(resp)
+		return
+	}
+	if p.answerQueryType(w, r, l) {
+		return
+	}
 
 	p.policyMu.RLock()
 	currentPolicy := p.policy
 	p.policyMu.RUnlock()
-	if currentPolicy != nil && currentPolicy.Evaluate(domain) == policy.ActionDeny {
-		resp := new(dns.Msg)
-		resp.SetRcode(r, dns.RcodeNameError)
-		_ = w.WriteMsg(resp)
-		return
+	decider := currentPolicy
+	if l != nil && l.Policy != nil {
+		decider = l.Policy
+	}
+	// the resolve handler opens the firewall in enforce mode, which follows
+	// the main policy; a name only a listener's own policy allows is answered
+	// but not reported
+	report := true
+	var origin *policy.RuleOrigin
+	if decider != nil {
+		decision := decider.Explain(domain)
+		origin = decision.Origin
+		if decision.Action == policy.ActionDeny {
+			p.recordBlock(q, l, decision.Reason, decision.Target, origin)
+			if p.sinkholeEnabled() {
+				_ = w.WriteMsg(p.sinkholeReply(r))
+				return
+			}
+			resp := new(dns.Msg)
+			resp.SetRcode(r, dns.RcodeNameError)
+			_ = w.WriteMsg(resp)
+			return
+		}
+		if decider != currentPolicy && currentPolicy != nil {
+			report = currentPolicy.Explain(domain).Action != policy.ActionDeny
+		}
+	}
+
+	// Answers tailored to a client-supplied subnet are neither served from nor
+	// stored in the cache, which is keyed by question only, nor shared with
+	// other clients' queries.
+	perClient := p.ecsPerClient(r)
+	cacheable := !perClient && !p.qtypeRuleFor(q.Qtype).noCache
+	if cacheable {
+		if resp, stale := p.cached(r, time.Now()); resp != nil {
+			if stale {
+				p.revalidate(r, currentPolicy)
+			}
+			if report {
+				p.recordResolve(resp)
+			}
+			p.logAllowed(q, l, resp, origin)
+			_ = w.WriteMsg(resp)
+			return
+		}
 	}
 
-	resp, err := p.forward(r)
+	var (
+		resp *dns.Msg
+		err  error
+	)
+	if perClient {
+		resp, err = p.forward(r)
+	} else {
+		resp, err = p.forwardShared(r)
+	}
 	if err != nil {
 		log.Printf("[dns] forward error for %s: %v", domain, err)
 		fail := new(dns.Msg)
@@ -118,18 +286,173 @@ func (p *Proxy) serveDNS(w dns.ResponseWriter, r *dns.Msg) {
 		_ = w.WriteMsg(fail)
 		return
 	}
+	p.clampTTL(resp)
+	if cacheable {
+		p.storeCached(resp, currentPolicy, time.Now())
+	}
+	if report {
+		p.recordResolve(resp)
+	}
+	p.logAllowed(q, l, resp, origin)
 	_ = w.WriteMsg(resp)
 }
 
+// forward resolves r through the policy's conditional upstream for its name,
+// if any, and otherwise through the default upstream. Conditional upstreams
+// are queried directly: their zones are usually not publicly delegated, so
+// qname minimization does not apply to them. The client subnet option is
+// handled per the ECS mode on the way out and undone on the way back.
 func (p *Proxy) forward(r *dns.Msg) (*dns.Msg, error) {
+	resp, err := p.forwardQuery(p.applyECS(r))
+	if err != nil {
+		return nil, err
+	}
+	p.restoreECS(r, resp)
+	return resp, nil
+}
+
+func (p *Proxy) forwardQuery(r *dns.Msg) (*dns.Msg, error) {
+	if pol := p.CurrentPolicy(); pol != nil {
+		if upstream := pol.UpstreamFor(r.Question[0].Name); upstream != "" {
+			return p.exchange(r, upstream)
+		}
+	}
+	if p.qnameMinimization {
+		return p.forwardMinimized(r)
+	}
+	if p.sticky != nil {
+		return p.exchangeSticky(r)
+	}
+	if p.latency != nil {
+		return p.exchangeLatency(r)
+	}
+	return p.exchange(r, p.upstream)
+}
+
+// exchange sends r to server over the upstream dialer, or through the SOCKS5
+// proxy when one is configured.
+func (p *Proxy) exchange(r *dns.Msg, server string) (*dns.Msg, error) {
+	if p.socks != nil {
+		return p.exchangeSOCKS5(r, server)
+	}
 	c := &dns.Client{
 		Timeout: 5 * time.Second,
-		Dialer:  p.dialerWithMark(),
+		Dialer:  p.upstreamDialer(),
 	}
-	resp, _, err := c.Exchange(r, p.upstream)
+	resp, _, err := c.Exchange(r, server)
 	return resp, err
 }
 
+// upstreamDialer returns the SO_MARK dialer, bound to the configured source address if any.
+func (p *Proxy) upstreamDialer() *net.Dialer {
+	d := p.dialerWithMark()
+	if p.sourceIP != nil {
+		// forward always exchanges over UDP, so a UDP local address is sufficient.
+		d.LocalAddr = &net.UDPAddr{IP: p.sourceIP}
+	}
+	return d
+}
+
+// SetUpstreamSource makes upstream queries leave from a specific local IP, or
+// from the named interface's first address in the upstream's address family.
+// It fails if the address is not assigned to this host, or if any upstream,
+// including the conditional upstreams of the current policy, is in the other
+// address family. Empty clears it.
+func (p *Proxy) SetUpstreamSource(source string) error {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		p.sourceIP = nil
+		return nil
+	}
+	upstreamIP := net.ParseIP(p.UpstreamHost())
+	wantIPv4 := upstreamIP == nil || upstreamIP.To4() != nil
+
+	ip, err := resolveSourceIP(source, wantIPv4)
+	if err != nil {
+		return err
+	}
+	for _, upstream := range p.Upstreams() {
+		if err := checkUpstreamFamily(upstream, ip); err != nil {
+			return err
+		}
+	}
+	if err := checkForwardFamily(p.CurrentPolicy(), ip); err != nil {
+		return err
+	}
+	p.sourceIP = ip
+	return nil
+}
+
+// CheckPolicy reports whether pol can be used with the upstream source: its
+// conditional upstreams must be in the source's address family.
+func (p *Proxy) CheckPolicy(pol *policy.NetworkPolicy) error {
+	if p.sourceIP == nil {
+		return nil
+	}
+	return checkForwardFamily(pol, p.sourceIP)
+}
+
+func checkForwardFamily(pol *policy.NetworkPolicy, source net.IP) error {
+	if pol == nil {
+		return nil
+	}
+	for _, rule := range pol.Forward {
+		if err := checkUpstreamFamily(rule.Upstream, source); err != nil {
+			return fmt.Errorf("forward rule for %s: %w", rule.Suffix, err)
+		}
+	}
+	return nil
+}
+
+// checkUpstreamFamily fails when upstream, a host:port, is an IP in another
+// address family than source, which could then never reach it.
+func checkUpstreamFamily(upstream string, source net.IP) error {
+	host, _, err := net.SplitHostPort(upstream)
+	if err != nil {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && (ip.To4() != nil) != (source.To4() != nil) {
+		return fmt.Errorf("upstream %s does not match the address family of upstream source %s", upstream, source)
+	}
+	return nil
+}
+
+func resolveSourceIP(source string, wantIPv4 bool) (net.IP, error) {
+	if ip := net.ParseIP(source); ip != nil {
+		if (ip.To4() != nil) != wantIPv4 {
+			return nil, fmt.Errorf("upstream source %s does not match the upstream address family", source)
+		}
+		addrs, err := net.InterfaceAddrs()
+		if err != nil {
+			return nil, fmt.Errorf("list interface addresses: %w", err)
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				return ip, nil
+			}
+		}
+		return nil, fmt.Errorf("upstream source %s is not assigned to any local interface", source)
+	}
+
+	iface, err := net.InterfaceByName(source)
+	if err != nil {
+		return nil, fmt.Errorf("upstream source %q is neither an IP nor an interface: %w", source, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("list addresses of %s: %w", source, err)
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || (ipNet.IP.To4() != nil) != wantIPv4 || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		return ipNet.IP, nil
+	}
+	return nil, fmt.Errorf("interface %s has no usable address for the upstream family", source)
+}
+
 // UpstreamHost returns the host part of the upstream resolver, empty on parse error.
 func (p *Proxy) UpstreamHost() string {
 	host, _, err := net.SplitHostPort(p.upstream)
@@ -141,10 +464,16 @@ func (p *Proxy) UpstreamHost() string {
 
 // UpdatePolicy swaps the in-memory policy used by the proxy.
 // Passing nil reverts to the default deny-all policy.
+// Cached answers for names the new policy denies or forwards elsewhere are purged.
 func (p *Proxy) UpdatePolicy(newPolicy *policy.NetworkPolicy) {
 	p.policyMu.Lock()
+	old := p.policy
 	p.policy = ensurePolicyDefaults(newPolicy)
+	purged := p.purgeStale(old, p.policy)
 	p.policyMu.Unlock()
+	if purged > 0 {
+		log.Printf("[dns] purged %d cached answers made stale by the new policy", purged)
+	}
 }
 
 // CurrentPolicy returns the policy currently enforced by the proxy.
@@ -154,14 +483,18 @@ func (p *Proxy) CurrentPolicy() *policy.NetworkPolicy {
 	return p.policy
 }
 
-func discoverUpstream() (string, error) {
+func discoverUpstreams() ([]string, error) {
 	cfg, err := dns.ClientConfigFromFile("/etc/resolv.conf")
 	if err == nil && len(cfg.Servers) > 0 {
-		return net.JoinHostPort(cfg.Servers[0], cfg.Port), nil
+		upstreams := make([]string, 0, len(cfg.Servers))
+		for _, server := range cfg.Servers {
+			upstreams = append(upstreams, net.JoinHostPort(server, cfg.Port))
+		}
+		return upstreams, nil
 	}
 	// fallback to public resolver; comment to explain deterministic behavior
 	log.Printf("[dns] fallback upstream resolver due to error: %v", err)
-	return "8.8.8.8:53", nil
+	return []string{"8.8.8.8:53"}, nil
 }
 
 // LoadPolicyFromEnvVar reads the given env var and parses a policy; empty falls back to default deny-all.
@@ -170,7 +503,7 @@ func LoadPolicyFromEnvVar(envName string) (*policy.NetworkPolicy, error) {
 	if raw == "" {
 		return policy.DefaultDenyPolicy(), nil
 	}
-	return policy.ParsePolicy(raw)
+	return policy.ParsePolicyFrom("env:"+envName, raw)
 }
 
 func ensurePolicyDefaults(p *policy.NetworkPolicy) *policy.NetworkPolicy {
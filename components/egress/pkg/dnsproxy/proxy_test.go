@@ -15,8 +15,13 @@
 package dnsproxy
 
 import (
+	"errors"
+	"net"
+	"syscall"
 	"testing"
 
+	"github.com/miekg/dns"
+
 	"github.com/alibaba/opensandbox/egress/pkg/policy"
 )
 
@@ -66,6 +71,9 @@ func TestLoadPolicyFromEnvVar(t *testing.T) {
 	if pol == nil || pol.Evaluate("example.com.") != policy.ActionAllow {
 		t.Fatalf("expected parsed policy to allow example.com")
 	}
+	if origin := pol.Explain("example.com.").Origin; origin == nil || origin.String() != "env:"+envName+" egress[0] line 1" {
+		t.Fatalf("expected the env var as the rule's origin, got %v", origin)
+	}
 
 	t.Setenv(envName, "")
 	pol, err = LoadPolicyFromEnvVar(envName)
@@ -79,3 +87,120 @@ func TestLoadPolicyFromEnvVar(t *testing.T) {
 		t.Fatalf("expected default deny when env is empty, got %+v", pol)
 	}
 }
+
+func TestUpstreamDialerUsesConfiguredSource(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen fake upstream: %v", err)
+	}
+	defer pc.Close()
+
+	seen := make(chan net.Addr, 1)
+	go func() {
+		buf := make([]byte, 512)
+		n, from, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		seen <- from
+		req := new(dns.Msg)
+		if req.Unpack(buf[:n]) == nil {
+			resp := new(dns.Msg)
+			resp.SetReply(req)
+			out, _ := resp.Pack()
+			_, _ = pc.WriteTo(out, from)
+		}
+	}()
+
+	proxy := &Proxy{upstream: pc.LocalAddr().String()}
+	if err := proxy.SetUpstreamSource("127.0.0.1"); err != nil {
+		t.Fatalf("SetUpstreamSource: %v", err)
+	}
+
+	local, ok := proxy.upstreamDialer().LocalAddr.(*net.UDPAddr)
+	if !ok || !local.IP.Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatalf("expected dialer bound to 127.0.0.1, got %#v", proxy.upstreamDialer().LocalAddr)
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.com.", dns.TypeA)
+	if _, err := proxy.forward(msg); err != nil {
+		if errors.Is(err, syscall.EPERM) {
+			t.Skipf("SO_MARK needs CAP_NET_ADMIN: %v", err)
+		}
+		t.Fatalf("forward: %v", err)
+	}
+	from := (<-seen).(*net.UDPAddr)
+	if !from.IP.Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatalf("upstream saw query from %s, want 127.0.0.1", from.IP)
+	}
+}
+
+func TestSetUpstreamSourceValidation(t *testing.T) {
+	proxy := &Proxy{upstream: "127.0.0.1:53"}
+
+	if err := proxy.SetUpstreamSource("192.0.2.123"); err == nil {
+		t.Fatalf("expected error for address not assigned locally")
+	}
+	if err := proxy.SetUpstreamSource("::1"); err == nil {
+		t.Fatalf("expected error for address family mismatch")
+	}
+	if err := proxy.SetUpstreamSource("no-such-iface0"); err == nil {
+		t.Fatalf("expected error for unknown interface")
+	}
+
+	lo := loopbackInterface(t)
+	if err := proxy.SetUpstreamSource(lo); err != nil {
+		t.Fatalf("SetUpstreamSource(%s): %v", lo, err)
+	}
+	if proxy.sourceIP == nil || !proxy.sourceIP.IsLoopback() {
+		t.Fatalf("expected loopback source from %s, got %v", lo, proxy.sourceIP)
+	}
+
+	if err := proxy.SetUpstreamSource(""); err != nil || proxy.sourceIP != nil {
+		t.Fatalf("expected empty source to clear binding, err=%v ip=%v", err, proxy.sourceIP)
+	}
+	if proxy.upstreamDialer().LocalAddr != nil {
+		t.Fatalf("expected unbound dialer after clearing source")
+	}
+}
+
+func TestSetUpstreamSourceChecksEveryUpstream(t *testing.T) {
+	proxy := &Proxy{upstream: "127.0.0.1:53", upstreams: []string{"127.0.0.1:53", "[::1]:53"}}
+	if err := proxy.SetUpstreamSource("127.0.0.1"); err == nil || proxy.sourceIP != nil {
+		t.Fatalf("expected an error for a nameserver of the other family, got %v", err)
+	}
+
+	v6Forward := mustPolicy(t, `{"defaultAction":"allow","forward":[{"suffix":"corp.example.com","upstream":"::1"}]}`)
+	proxy = &Proxy{upstream: "127.0.0.1:53"}
+	proxy.UpdatePolicy(v6Forward)
+	if err := proxy.SetUpstreamSource("127.0.0.1"); err == nil || proxy.sourceIP != nil {
+		t.Fatalf("expected an error for a conditional upstream of the other family, got %v", err)
+	}
+
+	proxy.UpdatePolicy(nil)
+	if err := proxy.SetUpstreamSource("127.0.0.1"); err != nil {
+		t.Fatalf("SetUpstreamSource: %v", err)
+	}
+	if err := proxy.CheckPolicy(v6Forward); err == nil {
+		t.Fatalf("expected a policy forwarding to the other family to be rejected")
+	}
+	if err := proxy.CheckPolicy(mustPolicy(t, `{"defaultAction":"allow","forward":[{"suffix":"corp.example.com","upstream":"127.0.0.2"}]}`)); err != nil {
+		t.Fatalf("CheckPolicy: %v", err)
+	}
+}
+
+func loopbackInterface(t *testing.T) string {
+	t.Helper()
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatalf("list interfaces: %v", err)
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			return iface.Name
+		}
+	}
+	t.Skip("no loopback interface")
+	return ""
+}
@@ -0,0 +1,64 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// SetAllowedClasses lets queries of extra classes (comma-separated mnemonics such
+// as "CH" or generic "CLASS3") through policy evaluation and forwarding like IN.
+// IN is always allowed; any other class is answered with REFUSED, since egress
+// rules describe Internet names and a CHAOS query for "version.bind" says nothing
+// about them. Empty restores IN only.
+func (p *Proxy) SetAllowedClasses(spec string) error {
+	var classes []uint16
+	for _, field := range strings.Split(spec, ",") {
+		name := strings.ToUpper(strings.TrimSpace(field))
+		if name == "" {
+			continue
+		}
+		class, ok := dns.StringToClass[name]
+		if !ok {
+			digits, generic := strings.CutPrefix(name, "CLASS")
+			n, err := strconv.ParseUint(digits, 10, 16)
+			if !generic || err != nil {
+				return fmt.Errorf("unknown dns class %q", field)
+			}
+			class = uint16(n)
+		}
+		if class != dns.ClassINET {
+			classes = append(classes, class)
+		}
+	}
+	p.extraClasses = classes
+	return nil
+}
+
+func (p *Proxy) classAllowed(class uint16) bool {
+	if class == dns.ClassINET {
+		return true
+	}
+	for _, c := range p.extraClasses {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
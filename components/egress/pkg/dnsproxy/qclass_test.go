@@ -0,0 +1,99 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func queryClass(t *testing.T, p *Proxy, name string, qtype, qclass uint16) *dns.Msg {
+	t.Helper()
+	req := new(dns.Msg)
+	req.SetQuestion(name, qtype)
+	req.Question[0].Qclass = qclass
+	w := &recordingWriter{}
+	p.serveDNS(w, req)
+	if w.msg == nil {
+		t.Fatalf("no reply for %s", name)
+	}
+	return w.msg
+}
+
+func TestServeDNS_RefusesChaosByDefault(t *testing.T) {
+	p := newDenyProxy(t)
+	if err := p.SetSinkhole("10.0.0.2"); err != nil {
+		t.Fatalf("SetSinkhole: %v", err)
+	}
+
+	// Neither forwarded (which would SERVFAIL) nor matched against the policy.
+	for _, name := range []string{"version.bind.", "api.blocked.test."} {
+		resp := queryClass(t, p, name, dns.TypeTXT, dns.ClassCHAOS)
+		if resp.Rcode != dns.RcodeRefused || len(resp.Answer) != 0 {
+			t.Fatalf("%s CH: expected REFUSED, got rcode=%d answers=%v", name, resp.Rcode, resp.Answer)
+		}
+	}
+	for _, class := range []uint16{dns.ClassHESIOD, dns.ClassANY, 4242} {
+		if resp := queryClass(t, p, "api.blocked.test.", dns.TypeA, class); resp.Rcode != dns.RcodeRefused {
+			t.Fatalf("class %d: expected REFUSED, got rcode=%d", class, resp.Rcode)
+		}
+	}
+
+	// IN queries keep going through the policy.
+	if resp := queryClass(t, p, "api.blocked.test.", dns.TypeA, dns.ClassINET); resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 {
+		t.Fatalf("IN: expected sinkhole answer, got rcode=%d answers=%v", resp.Rcode, resp.Answer)
+	}
+}
+
+func TestServeDNS_AllowedClassFollowsPolicy(t *testing.T) {
+	p := newDenyProxy(t)
+	if err := p.SetAllowedClasses("ch"); err != nil {
+		t.Fatalf("SetAllowedClasses: %v", err)
+	}
+
+	if resp := queryClass(t, p, "api.blocked.test.", dns.TypeTXT, dns.ClassCHAOS); resp.Rcode != dns.RcodeNameError {
+		t.Fatalf("denied CH name: expected NXDOMAIN, got rcode=%d", resp.Rcode)
+	}
+	// Allowed names are forwarded; the unroutable upstream turns that into SERVFAIL.
+	if resp := queryClass(t, p, "version.bind.", dns.TypeTXT, dns.ClassCHAOS); resp.Rcode != dns.RcodeServerFailure {
+		t.Fatalf("allowed CH name: expected forward attempt, got rcode=%d", resp.Rcode)
+	}
+	if resp := queryClass(t, p, "version.bind.", dns.TypeTXT, dns.ClassHESIOD); resp.Rcode != dns.RcodeRefused {
+		t.Fatalf("HS: expected REFUSED, got rcode=%d", resp.Rcode)
+	}
+
+	if err := p.SetAllowedClasses(""); err != nil {
+		t.Fatalf("SetAllowedClasses(\"\"): %v", err)
+	}
+	if resp := queryClass(t, p, "version.bind.", dns.TypeTXT, dns.ClassCHAOS); resp.Rcode != dns.RcodeRefused {
+		t.Fatalf("expected REFUSED after clearing allowed classes, got rcode=%d", resp.Rcode)
+	}
+}
+
+func TestSetAllowedClasses(t *testing.T) {
+	p := &Proxy{}
+	if err := p.SetAllowedClasses(" CH , class4242, IN "); err != nil {
+		t.Fatalf("SetAllowedClasses: %v", err)
+	}
+	if !p.classAllowed(dns.ClassCHAOS) || !p.classAllowed(4242) || !p.classAllowed(dns.ClassINET) || p.classAllowed(dns.ClassHESIOD) {
+		t.Fatalf("unexpected allowed classes: %v", p.extraClasses)
+	}
+	for _, spec := range []string{"CHAOSNET", "CLASS", "CLASS70000", "CLASS-1"} {
+		if err := p.SetAllowedClasses(spec); err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+}
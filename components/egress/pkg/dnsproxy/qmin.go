@@ -0,0 +1,235 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// defaultAuthorityPort is where delegated name servers found through referrals are queried.
+const defaultAuthorityPort = "53"
+
+// errNotMinimized aborts a minimized resolution; the query is then forwarded in full.
+var errNotMinimized = errors.New("qname minimization not possible")
+
+// SetQNameMinimization enables RFC 7816 query name minimization. Instead of
+// sending the full name to the upstream, the proxy walks the delegation chain
+// itself, asking each server only for the NS records of the next label, and
+// sends the full query to the last server only.
+func (p *Proxy) SetQNameMinimization(enabled bool) {
+	p.qnameMinimization = enabled
+}
+
+// ParseQNameMinimization parses the on/off switch, e.g. "true" or "1".
+func ParseQNameMinimization(raw string) (bool, error) {
+	enabled, err := strconv.ParseBool(strings.TrimSpace(raw))
+	if err != nil {
+		return false, fmt.Errorf("qname minimization %q is not a boolean: %w", raw, err)
+	}
+	return enabled, nil
+}
+
+// maxMinimizedHops bounds the referrals and aliases a minimized resolution
+// follows once it asks for the full name.
+const maxMinimizedHops = 8
+
+// resolveMinimized resolves r by revealing one more label per hop, starting at
+// the upstream. A referral moves to the delegated server; a name that is not a
+// zone cut stays on the current one. A referral for the full name is followed
+// like the others, and an alias out of the answering server's zone is resolved
+// the same way; hops counts how many of those were followed so far. Anything
+// unexpected (errors, REFUSED, NXDOMAIN from servers that get empty
+// non-terminals wrong, aliases on the way, referrals without glue) returns
+// errNotMinimized so the caller can fall back.
+func (p *Proxy) resolveMinimized(r *dns.Msg, hops int) (*dns.Msg, error) {
+	q := r.Question[0]
+	labels := dns.SplitDomainName(q.Name)
+	server := p.upstream
+
+	for n := 1; n < len(labels); n++ {
+		name := dns.Fqdn(strings.Join(labels[len(labels)-n:], "."))
+		m := new(dns.Msg)
+		m.SetQuestion(name, dns.TypeNS)
+		m.Question[0].Qclass = q.Qclass
+		m.RecursionDesired = r.RecursionDesired
+
+		resp, err := p.exchange(m, server)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s NS via %s: %v", errNotMinimized, name, server, err)
+		}
+		if resp.Rcode != dns.RcodeSuccess {
+			return nil, fmt.Errorf("%w: %s NS via %s: %s", errNotMinimized, name, server, dns.RcodeToString[resp.Rcode])
+		}
+		for _, rr := range resp.Answer {
+			if rr.Header().Rrtype == dns.TypeCNAME {
+				return nil, fmt.Errorf("%w: %s is an alias", errNotMinimized, name)
+			}
+		}
+		if len(resp.Answer) > 0 {
+			// The server is authoritative for name itself; keep asking it.
+			continue
+		}
+		next, referral, err := p.referralTarget(resp, name)
+		if err != nil {
+			return nil, err
+		}
+		if referral {
+			server = next
+		}
+	}
+
+	for {
+		resp, err := p.exchange(r.Copy(), server)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s via %s: %v", errNotMinimized, q.Name, server, err)
+		}
+		zone := referralZone(resp, q.Name)
+		if zone == "" {
+			return p.followAlias(r, resp, hops)
+		}
+		// the full name is itself a zone cut, or lies below one
+		if hops++; hops > maxMinimizedHops {
+			return nil, fmt.Errorf("%w: %s: more than %d referrals or aliases", errNotMinimized, q.Name, maxMinimizedHops)
+		}
+		if server, _, err = p.referralTarget(resp, zone); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// referralZone returns the zone an answerless NOERROR response for name
+// delegates it to, or "" when the response is not a referral.
+func referralZone(resp *dns.Msg, name string) string {
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) > 0 {
+		return ""
+	}
+	zone := ""
+	for _, rr := range resp.Ns {
+		switch rr := rr.(type) {
+		case *dns.SOA:
+			// NODATA carries the SOA of the zone the name is in
+			return ""
+		case *dns.NS:
+			if zone == "" && dns.IsSubDomain(rr.Hdr.Name, name) {
+				zone = dns.CanonicalName(rr.Hdr.Name)
+			}
+		}
+	}
+	return zone
+}
+
+// followAlias completes resp, the answer to r, when it ends in an alias the
+// answering server could not resolve, usually because the target is in
+// another zone: the target is resolved minimized too and its answer appended
+// to the chain.
+func (p *Proxy) followAlias(r, resp *dns.Msg, hops int) (*dns.Msg, error) {
+	q := r.Question[0]
+	if resp.Rcode != dns.RcodeSuccess || q.Qtype == dns.TypeCNAME || q.Qtype == dns.TypeANY {
+		return resp, nil
+	}
+	name := dns.CanonicalName(q.Name)
+	target := name
+	// each record moves the chain at most once, which also stops alias loops
+	for range resp.Answer {
+		next := ""
+		for _, rr := range resp.Answer {
+			if dns.CanonicalName(rr.Header().Name) != target {
+				continue
+			}
+			if cname, ok := rr.(*dns.CNAME); ok {
+				next = dns.CanonicalName(cname.Target)
+			} else if rr.Header().Rrtype == q.Qtype {
+				return resp, nil
+			}
+		}
+		if next == "" {
+			break
+		}
+		target = next
+	}
+	if target == name {
+		return resp, nil
+	}
+	if hops++; hops > maxMinimizedHops {
+		return nil, fmt.Errorf("%w: %s: more than %d referrals or aliases", errNotMinimized, q.Name, maxMinimizedHops)
+	}
+	m := r.Copy()
+	m.Question[0].Name = target
+	tail, err := p.resolveMinimized(m, hops)
+	if err != nil {
+		return nil, err
+	}
+	out := resp.Copy()
+	out.Answer = append(out.Answer, tail.Answer...)
+	out.Ns = tail.Ns
+	out.Rcode = tail.Rcode
+	out.Authoritative = false
+	return out, nil
+}
+
+// referralTarget inspects an answerless NOERROR response for name. It reports
+// the address of a delegated server when the response is a referral for name,
+// and no referral when it is NODATA (name exists but is not a zone cut).
+func (p *Proxy) referralTarget(resp *dns.Msg, name string) (string, bool, error) {
+	var targets []string
+	for _, rr := range resp.Ns {
+		if ns, ok := rr.(*dns.NS); ok && dns.CanonicalName(ns.Hdr.Name) == dns.CanonicalName(name) {
+			targets = append(targets, dns.CanonicalName(ns.Ns))
+		}
+	}
+	if len(targets) == 0 {
+		return "", false, nil
+	}
+	for _, target := range targets {
+		for _, rr := range resp.Extra {
+			if dns.CanonicalName(rr.Header().Name) != target {
+				continue
+			}
+			switch glue := rr.(type) {
+			case *dns.A:
+				return net.JoinHostPort(glue.A.String(), p.authorityPort()), true, nil
+			case *dns.AAAA:
+				return net.JoinHostPort(glue.AAAA.String(), p.authorityPort()), true, nil
+			}
+		}
+	}
+	return "", false, fmt.Errorf("%w: referral for %s carries no glue", errNotMinimized, name)
+}
+
+func (p *Proxy) authorityPort() string {
+	if p.qminPort != "" {
+		return p.qminPort
+	}
+	return defaultAuthorityPort
+}
+
+// forwardMinimized tries a minimized resolution and falls back to forwarding
+// the full query to the upstream, so an uncooperative chain costs latency but
+// never an answer.
+func (p *Proxy) forwardMinimized(r *dns.Msg) (*dns.Msg, error) {
+	resp, err := p.resolveMinimized(r, 0)
+	if err == nil {
+		return resp, nil
+	}
+	log.Printf("[dns] qname minimization for %s fell back to full query: %v", r.Question[0].Name, err)
+	return p.exchange(r, p.upstream)
+}
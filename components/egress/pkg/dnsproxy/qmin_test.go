@@ -0,0 +1,274 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// stubServer is an authoritative server that records every question it gets.
+type stubServer struct {
+	addr   string
+	mu     sync.Mutex
+	seen   []string
+	answer func(q dns.Question, resp *dns.Msg)
+}
+
+func startStub(t *testing.T, addr string, answer func(q dns.Question, resp *dns.Msg)) *stubServer {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		t.Skipf("listen stub on %s: %v", addr, err)
+	}
+	s := &stubServer{addr: pc.LocalAddr().String(), answer: answer}
+	srv := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		q := r.Question[0]
+		s.mu.Lock()
+		s.seen = append(s.seen, q.Name+" "+dns.TypeToString[q.Qtype])
+		s.mu.Unlock()
+		resp := new(dns.Msg)
+		resp.SetReply(r)
+		s.answer(q, resp)
+		_ = w.WriteMsg(resp)
+	})}
+	go func() { _ = srv.ActivateAndServe() }()
+	t.Cleanup(func() { _ = srv.Shutdown() })
+	return s
+}
+
+func (s *stubServer) questions() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.seen...)
+}
+
+func referral(zone, ns, glue string) func(dns.Question, *dns.Msg) {
+	return func(q dns.Question, resp *dns.Msg) {
+		resp.Ns = []dns.RR{&dns.NS{Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: 300}, Ns: ns}}
+		resp.Extra = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: ns, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300}, A: net.ParseIP(glue)}}
+	}
+}
+
+func freeUDPPort(t *testing.T) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer pc.Close()
+	return strconv.Itoa(pc.LocalAddr().(*net.UDPAddr).Port)
+}
+
+func skipIfNoMark(t *testing.T, err error) {
+	t.Helper()
+	if errors.Is(err, syscall.EPERM) {
+		t.Skipf("SO_MARK needs CAP_NET_ADMIN: %v", err)
+	}
+}
+
+func TestForward_QNameMinimizationWalksDelegations(t *testing.T) {
+	// Delegated servers share one port on distinct loopback addresses, since glue carries no port.
+	port := freeUDPPort(t)
+	root := startStub(t, "127.0.0.1:0", referral("com.", "ns.tld.test.", "127.0.0.2"))
+	tld := startStub(t, "127.0.0.2:"+port, referral("example.com.", "ns.example.test.", "127.0.0.3"))
+	auth := startStub(t, "127.0.0.3:"+port, func(q dns.Question, resp *dns.Msg) {
+		resp.Authoritative = true
+		if q.Qtype == dns.TypeNS {
+			// www.example.com exists but is not a zone cut: NODATA.
+			resp.Ns = []dns.RR{&dns.SOA{Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 60}, Ns: "ns.example.test.", Mbox: "admin.example.com."}}
+			return
+		}
+		resp.Answer = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.ParseIP("192.0.2.10")}}
+	})
+
+	p := &Proxy{upstream: root.addr, qminPort: port}
+	p.SetQNameMinimization(true)
+	req := new(dns.Msg)
+	req.SetQuestion("a.www.example.com.", dns.TypeA)
+	resp, err := p.forward(req)
+	skipIfNoMark(t, err)
+	if err != nil {
+		t.Fatalf("forward: %v", err)
+	}
+	if resp.Id != req.Id || len(resp.Answer) != 1 || !resp.Answer[0].(*dns.A).A.Equal(net.ParseIP("192.0.2.10")) {
+		t.Fatalf("unexpected answer: %v", resp)
+	}
+
+	want := map[string][]string{
+		"root": {"com. NS"},
+		"tld":  {"example.com. NS"},
+		"auth": {"www.example.com. NS", "a.www.example.com. A"},
+	}
+	for name, s := range map[string]*stubServer{"root": root, "tld": tld, "auth": auth} {
+		got := s.questions()
+		if len(got) != len(want[name]) {
+			t.Fatalf("%s saw %v, want %v", name, got, want[name])
+		}
+		for i := range got {
+			if got[i] != want[name][i] {
+				t.Fatalf("%s saw %v, want %v", name, got, want[name])
+			}
+		}
+	}
+}
+
+func TestForward_QNameMinimizationFallsBack(t *testing.T) {
+	upstream := startStub(t, "127.0.0.1:0", func(q dns.Question, resp *dns.Msg) {
+		if q.Qtype == dns.TypeNS {
+			resp.Rcode = dns.RcodeRefused
+			return
+		}
+		resp.Answer = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.ParseIP("192.0.2.20")}}
+	})
+
+	p := &Proxy{upstream: upstream.addr}
+	p.SetQNameMinimization(true)
+	req := new(dns.Msg)
+	req.SetQuestion("a.www.example.com.", dns.TypeA)
+	resp, err := p.forward(req)
+	skipIfNoMark(t, err)
+	if err != nil {
+		t.Fatalf("forward: %v", err)
+	}
+	if len(resp.Answer) != 1 || !resp.Answer[0].(*dns.A).A.Equal(net.ParseIP("192.0.2.20")) {
+		t.Fatalf("expected the full query's answer after fallback, got %v", resp)
+	}
+	got := upstream.questions()
+	if len(got) != 2 || got[0] != "com. NS" || got[1] != "a.www.example.com. A" {
+		t.Fatalf("expected one refused minimized query then the full query, got %v", got)
+	}
+}
+
+func TestForward_QNameMinimizationFollowsReferralForFullName(t *testing.T) {
+	port := freeUDPPort(t)
+	root := startStub(t, "127.0.0.1:0", referral("com.", "ns.tld.test.", "127.0.0.2"))
+	startStub(t, "127.0.0.2:"+port, referral("example.com.", "ns.example.test.", "127.0.0.3"))
+	// shop.example.com is a zone of its own, which the walk never asks about
+	startStub(t, "127.0.0.3:"+port, referral("shop.example.com.", "ns.shop.test.", "127.0.0.4"))
+	shop := startStub(t, "127.0.0.4:"+port, func(q dns.Question, resp *dns.Msg) {
+		resp.Authoritative = true
+		resp.Answer = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.ParseIP("192.0.2.30")}}
+	})
+
+	p := &Proxy{upstream: root.addr, qminPort: port}
+	p.SetQNameMinimization(true)
+	req := new(dns.Msg)
+	req.SetQuestion("shop.example.com.", dns.TypeA)
+	resp, err := p.resolveMinimized(req, 0)
+	skipIfNoMark(t, err)
+	if err != nil {
+		t.Fatalf("resolveMinimized: %v", err)
+	}
+	if len(resp.Answer) != 1 || !resp.Answer[0].(*dns.A).A.Equal(net.ParseIP("192.0.2.30")) {
+		t.Fatalf("expected the delegated server's answer, got %v", resp)
+	}
+	if got := shop.questions(); len(got) != 1 || got[0] != "shop.example.com. A" {
+		t.Fatalf("shop saw %v", got)
+	}
+}
+
+func TestForward_QNameMinimizationFollowsAliasOutOfZone(t *testing.T) {
+	port := freeUDPPort(t)
+	root := startStub(t, "127.0.0.1:0", func(q dns.Question, resp *dns.Msg) {
+		if dns.IsSubDomain("net.", q.Name) {
+			referral("net.", "ns.cdn.test.", "127.0.0.5")(q, resp)
+			return
+		}
+		referral("com.", "ns.tld.test.", "127.0.0.2")(q, resp)
+	})
+	startStub(t, "127.0.0.2:"+port, referral("example.com.", "ns.example.test.", "127.0.0.3"))
+	startStub(t, "127.0.0.3:"+port, func(q dns.Question, resp *dns.Msg) {
+		resp.Authoritative = true
+		resp.Answer = []dns.RR{&dns.CNAME{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 60}, Target: "edge.cdn.net."}}
+	})
+	cdn := startStub(t, "127.0.0.5:"+port, func(q dns.Question, resp *dns.Msg) {
+		resp.Authoritative = true
+		if q.Qtype == dns.TypeNS {
+			resp.Ns = []dns.RR{&dns.SOA{Hdr: dns.RR_Header{Name: "net.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 60}, Ns: "ns.cdn.test.", Mbox: "admin.cdn.net."}}
+			return
+		}
+		resp.Answer = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.ParseIP("192.0.2.40")}}
+	})
+
+	p := &Proxy{upstream: root.addr, qminPort: port}
+	p.SetQNameMinimization(true)
+	req := new(dns.Msg)
+	req.SetQuestion("www.example.com.", dns.TypeA)
+	resp, err := p.resolveMinimized(req, 0)
+	skipIfNoMark(t, err)
+	if err != nil {
+		t.Fatalf("resolveMinimized: %v", err)
+	}
+	if resp.Question[0].Name != "www.example.com." || len(resp.Answer) != 2 {
+		t.Fatalf("expected the alias and its target's address, got %v", resp)
+	}
+	if cname, ok := resp.Answer[0].(*dns.CNAME); !ok || cname.Target != "edge.cdn.net." {
+		t.Fatalf("expected the alias first, got %v", resp.Answer[0])
+	}
+	if a, ok := resp.Answer[1].(*dns.A); !ok || !a.A.Equal(net.ParseIP("192.0.2.40")) {
+		t.Fatalf("expected the target's address, got %v", resp.Answer[1])
+	}
+	if got := cdn.questions(); len(got) != 2 || got[0] != "cdn.net. NS" || got[1] != "edge.cdn.net. A" {
+		t.Fatalf("cdn saw %v", got)
+	}
+}
+
+func TestReferralZone(t *testing.T) {
+	resp := new(dns.Msg)
+	referral("example.com.", "ns.example.test.", "192.0.2.53")(dns.Question{}, resp)
+	if got := referralZone(resp, "www.example.com."); got != "example.com." {
+		t.Fatalf("referralZone = %q, want example.com.", got)
+	}
+	if got := referralZone(resp, "www.example.org."); got != "" {
+		t.Fatalf("a referral elsewhere is not one for the name, got %q", got)
+	}
+	resp.Ns = append(resp.Ns, &dns.SOA{Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeSOA, Class: dns.ClassINET}})
+	if got := referralZone(resp, "www.example.com."); got != "" {
+		t.Fatalf("NODATA is no referral, got %q", got)
+	}
+}
+
+func TestReferralTarget_RequiresGlue(t *testing.T) {
+	p := &Proxy{}
+	resp := new(dns.Msg)
+	referral("example.com.", "ns.example.test.", "192.0.2.53")(dns.Question{}, resp)
+	if addr, ok, err := p.referralTarget(resp, "example.com."); err != nil || !ok || addr != "192.0.2.53:53" {
+		t.Fatalf("referralTarget = %q, %v, %v", addr, ok, err)
+	}
+
+	resp.Extra = nil
+	if _, _, err := p.referralTarget(resp, "example.com."); !errors.Is(err, errNotMinimized) {
+		t.Fatalf("expected errNotMinimized without glue, got %v", err)
+	}
+	if _, ok, err := p.referralTarget(new(dns.Msg), "example.com."); ok || err != nil {
+		t.Fatalf("expected NODATA to be no referral, got %v, %v", ok, err)
+	}
+}
+
+func TestParseQNameMinimization(t *testing.T) {
+	if got, err := ParseQNameMinimization(" true "); err != nil || !got {
+		t.Fatalf("ParseQNameMinimization(true) = %v, %v", got, err)
+	}
+	if _, err := ParseQNameMinimization("sometimes"); err == nil {
+		t.Fatalf("expected error for a non-boolean")
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"github.com/alibaba/opensandbox/egress/pkg/policy"
+)
+
+// BlockReasonQueryType marks queries answered locally because a query type
+// rule refuses them or answers them empty; it never comes from the policy.
+const BlockReasonQueryType policy.BlockReason = "query_type"
+
+// qtypeAction is what the proxy does with an allowed query of a type.
+type qtypeAction string
+
+const (
+	qtypeForward qtypeAction = "forward" // resolve it upstream, the default
+	qtypeRefuse  qtypeAction = "refuse"  // answer REFUSED
+	qtypeNoData  qtypeAction = "nodata"  // answer NOERROR without records
+)
+
+// qtypeRule tunes how queries of one type are handled. The zero value
+// behaves like a type without a rule.
+type qtypeRule struct {
+	action  qtypeAction
+	noCache bool
+	// hasMinTTL replaces the proxy-wide minimum TTL with minTTL for the type.
+	hasMinTTL bool
+	minTTL    uint32
+	maxTTL    uint32 // 0 leaves answer TTLs uncapped
+}
+
+// SetQueryTypeRules sets per query type handling from a spec of
+// semicolon-separated "TYPE:option,..." entries, e.g.
+// "TXT:nocache;A:min=60,max=3600;ANY:refuse". Types are mnemonics such as
+// "AAAA" or generic "TYPE65". Options are:
+//
+//   - cache / nocache: whether answers are kept in the answer cache
+//   - forward / refuse / nodata: resolve upstream, answer REFUSED, or answer
+//     NOERROR without records
+//   - min=SECONDS / max=SECONDS: clamp answer TTLs, and so how long they are
+//     cached; min replaces the proxy-wide minimum TTL for the type
+//
+// Types without an entry are forwarded and cached alike. Empty clears every
+// rule.
+func (p *Proxy) SetQueryTypeRules(spec string) error {
+	rules := make(map[uint16]qtypeRule)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, options, ok := strings.Cut(entry, ":")
+		if !ok {
+			return fmt.Errorf("query type rule %q has no options, expected TYPE:option,...", entry)
+		}
+		qtype, err := parseQueryType(name)
+		if err != nil {
+			return err
+		}
+		if _, dup := rules[qtype]; dup {
+			return fmt.Errorf("query type %s has more than one rule", dns.Type(qtype))
+		}
+		rule, err := parseQtypeOptions(options)
+		if err != nil {
+			return fmt.Errorf("query type %s: %w", dns.Type(qtype), err)
+		}
+		rules[qtype] = rule
+	}
+	if len(rules) == 0 {
+		rules = nil
+	}
+	p.qtypeRules = rules
+	return nil
+}
+
+func parseQueryType(raw string) (uint16, error) {
+	name := strings.ToUpper(strings.TrimSpace(raw))
+	if qtype, ok := dns.StringToType[name]; ok {
+		return qtype, nil
+	}
+	digits, generic := strings.CutPrefix(name, "TYPE")
+	n, err := strconv.ParseUint(digits, 10, 16)
+	if !generic || err != nil {
+		return 0, fmt.Errorf("unknown dns query type %q", raw)
+	}
+	return uint16(n), nil
+}
+
+func parseQtypeOptions(raw string) (qtypeRule, error) {
+	rule := qtypeRule{action: qtypeForward}
+	for _, field := range strings.Split(raw, ",") {
+		option := strings.ToLower(strings.TrimSpace(field))
+		key, value, hasValue := strings.Cut(option, "=")
+		switch {
+		case option == "cache":
+			rule.noCache = false
+		case option == "nocache":
+			rule.noCache = true
+		case option == string(qtypeForward), option == string(qtypeRefuse), option == string(qtypeNoData):
+			rule.action = qtypeAction(option)
+		case hasValue && (key == "min" || key == "max"):
+			seconds, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return qtypeRule{}, fmt.Errorf("%s ttl %q is not a number of seconds", key, value)
+			}
+			if key == "min" {
+				if seconds > maxMinTTL {
+					return qtypeRule{}, fmt.Errorf("minimum ttl %ds exceeds %ds", seconds, maxMinTTL)
+				}
+				rule.hasMinTTL, rule.minTTL = true, uint32(seconds)
+			} else {
+				if seconds == 0 {
+					return qtypeRule{}, fmt.Errorf("maximum ttl must be positive")
+				}
+				rule.maxTTL = uint32(seconds)
+			}
+		default:
+			return qtypeRule{}, fmt.Errorf("unknown option %q", field)
+		}
+	}
+	if rule.hasMinTTL && rule.maxTTL > 0 && rule.minTTL > rule.maxTTL {
+		return qtypeRule{}, fmt.Errorf("minimum ttl %ds exceeds maximum ttl %ds", rule.minTTL, rule.maxTTL)
+	}
+	return rule, nil
+}
+
+// qtypeRuleFor returns the rule for qtype, or the zero rule without one.
+func (p *Proxy) qtypeRuleFor(qtype uint16) qtypeRule {
+	return p.qtypeRules[qtype]
+}
+
+// answerQueryType answers r locally when its type's rule says so, and
+// reports whether it did.
+func (p *Proxy) answerQueryType(w dns.ResponseWriter, r *dns.Msg, l *Listener) bool {
+	q := r.Question[0]
+	rule := p.qtypeRuleFor(q.Qtype)
+	resp := new(dns.Msg)
+	switch rule.action {
+	case qtypeRefuse:
+		resp.SetRcode(r, dns.RcodeRefused)
+	case qtypeNoData:
+		resp.SetReply(r)
+	default:
+		return false
+	}
+	p.recordBlock(q, l, BlockReasonQueryType, "", nil)
+	_ = w.WriteMsg(resp)
+	return true
+}
@@ -0,0 +1,147 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServeDNS_QueryTypeRulesBypassCache(t *testing.T) {
+	upstream := startStub(t, "127.0.0.1:0", func(q dns.Question, resp *dns.Msg) {
+		hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: dns.ClassINET, Ttl: 300}
+		switch q.Qtype {
+		case dns.TypeA:
+			resp.Answer = []dns.RR{&dns.A{Hdr: hdr, A: net.ParseIP("192.0.2.30")}}
+		case dns.TypeTXT:
+			resp.Answer = []dns.RR{&dns.TXT{Hdr: hdr, Txt: []string{"token"}}}
+		}
+	})
+	p := &Proxy{upstream: upstream.addr}
+	p.SetCache(true)
+	if err := p.SetQueryTypeRules("TXT:nocache"); err != nil {
+		t.Fatalf("SetQueryTypeRules: %v", err)
+	}
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"allow"}`))
+
+	probe := new(dns.Msg)
+	probe.SetQuestion("probe.example.com.", dns.TypeA)
+	_, err := p.forward(probe)
+	skipIfNoMark(t, err)
+	if err != nil {
+		t.Fatalf("forward: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if resp := query(t, p, "acme.example.com.", dns.TypeTXT); len(resp.Answer) != 1 {
+			t.Fatalf("TXT resolution %d: unexpected reply %v", i, resp)
+		}
+		if resp := query(t, p, "acme.example.com.", dns.TypeA); len(resp.Answer) != 1 {
+			t.Fatalf("A resolution %d: unexpected reply %v", i, resp)
+		}
+	}
+	seen := map[string]int{}
+	for _, q := range upstream.questions() {
+		seen[q]++
+	}
+	if seen["acme.example.com. TXT"] != 2 {
+		t.Fatalf("expected every TXT query to go upstream, upstream saw %v", upstream.questions())
+	}
+	if seen["acme.example.com. A"] != 1 {
+		t.Fatalf("expected the repeated A query to be served from cache, upstream saw %v", upstream.questions())
+	}
+	if _, ok := p.cache.entries[cacheKey{name: "acme.example.com.", qtype: dns.TypeTXT, qclass: dns.ClassINET}]; ok {
+		t.Fatalf("TXT answer was cached")
+	}
+}
+
+func TestServeDNS_QueryTypeRulesAnswerLocally(t *testing.T) {
+	p := &Proxy{upstream: "127.0.0.1:1"}
+	if err := p.SetQueryTypeRules("ANY:refuse; AAAA:nodata"); err != nil {
+		t.Fatalf("SetQueryTypeRules: %v", err)
+	}
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"allow"}`))
+
+	if resp := query(t, p, "example.com.", dns.TypeANY); resp.Rcode != dns.RcodeRefused {
+		t.Fatalf("expected REFUSED for ANY, got %v", resp)
+	}
+	resp := query(t, p, "example.com.", dns.TypeAAAA)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 {
+		t.Fatalf("expected an empty NOERROR answer for AAAA, got %v", resp)
+	}
+	if got := p.BlockCounts()[BlockReasonQueryType]; got != 2 {
+		t.Fatalf("expected 2 query type blocks, got %d", got)
+	}
+}
+
+func TestClampTTL_QueryTypeBounds(t *testing.T) {
+	p := &Proxy{}
+	if err := p.SetMinTTL(30); err != nil {
+		t.Fatalf("SetMinTTL: %v", err)
+	}
+	if err := p.SetQueryTypeRules("A:min=60,max=600;TXT:max=10"); err != nil {
+		t.Fatalf("SetQueryTypeRules: %v", err)
+	}
+	answer := func(qtype uint16, ttls ...uint32) *dns.Msg {
+		resp := new(dns.Msg)
+		resp.SetQuestion("cdn.test.", qtype)
+		for _, ttl := range ttls {
+			resp.Answer = append(resp.Answer, &dns.A{Hdr: dns.RR_Header{Name: "cdn.test.", Rrtype: qtype, Ttl: ttl}})
+		}
+		p.clampTTL(resp)
+		return resp
+	}
+	check := func(resp *dns.Msg, want ...uint32) {
+		t.Helper()
+		for i, rr := range resp.Answer {
+			if rr.Header().Ttl != want[i] {
+				t.Fatalf("%s answer %d: ttl %d, want %d", dns.TypeToString[resp.Question[0].Qtype], i, rr.Header().Ttl, want[i])
+			}
+		}
+	}
+	check(answer(dns.TypeA, 5, 300, 3600), 60, 300, 600)
+	// the cap wins over the proxy-wide floor
+	check(answer(dns.TypeTXT, 5, 300), 10, 10)
+	check(answer(dns.TypeMX, 5, 300), 30, 300)
+}
+
+func TestSetQueryTypeRules_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"TXT",
+		"BOGUS:nocache",
+		"TXT:sometimes",
+		"A:min=soon",
+		"A:min=90000",
+		"A:max=0",
+		"A:min=60,max=30",
+		"A:nocache;A:cache",
+	} {
+		if err := (&Proxy{}).SetQueryTypeRules(spec); err == nil {
+			t.Fatalf("expected %q to be rejected", spec)
+		}
+	}
+	p := &Proxy{}
+	if err := p.SetQueryTypeRules(" type16 : NoCache , refuse "); err != nil {
+		t.Fatalf("SetQueryTypeRules: %v", err)
+	}
+	if rule := p.qtypeRuleFor(dns.TypeTXT); !rule.noCache || rule.action != qtypeRefuse {
+		t.Fatalf("unexpected rule %+v", rule)
+	}
+	if err := p.SetQueryTypeRules(""); err != nil || p.qtypeRules != nil {
+		t.Fatalf("expected an empty spec to clear the rules, got %v, %v", p.qtypeRules, err)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/alibaba/opensandbox/egress/pkg/policy"
+)
+
+// QueryLogSampling selects which allowed queries are logged. Blocked queries
+// are always logged, whatever the sampling.
+type QueryLogSampling struct {
+	// Every logs one in Every allowed queries: 0 logs none, 1 logs all.
+	Every int
+	// Rate caps the allowed queries logged per second; 0 means no cap.
+	Rate int
+}
+
+func (s QueryLogSampling) String() string {
+	switch {
+	case s.Every == 0:
+		return "off"
+	case s.Rate == 0:
+		return fmt.Sprintf("1 in %d", s.Every)
+	default:
+		return fmt.Sprintf("1 in %d, at most %d/s", s.Every, s.Rate)
+	}
+}
+
+// ParseQueryLogSampling parses the sample interval and the optional
+// per-second cap; empty values mean 0.
+func ParseQueryLogSampling(every, rate string) (QueryLogSampling, error) {
+	var s QueryLogSampling
+	var err error
+	if every = strings.TrimSpace(every); every != "" {
+		if s.Every, err = strconv.Atoi(every); err != nil || s.Every < 0 {
+			return QueryLogSampling{}, fmt.Errorf("query log sample %q is not a non-negative number", every)
+		}
+	}
+	if rate = strings.TrimSpace(rate); rate != "" {
+		if s.Rate, err = strconv.Atoi(rate); err != nil || s.Rate < 0 {
+			return QueryLogSampling{}, fmt.Errorf("query log rate %q is not a non-negative number", rate)
+		}
+	}
+	return s, nil
+}
+
+// ReadQueryLogSampling reads the sampling from a file of "sample=N" and
+// "rate=N" lines; blank lines and lines starting with "#" are ignored, and a
+// missing key means 0.
+func ReadQueryLogSampling(path string) (QueryLogSampling, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return QueryLogSampling{}, err
+	}
+	defer f.Close()
+	var every, rate string
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		switch strings.TrimSpace(key) {
+		case "sample":
+			every = value
+		case "rate":
+			rate = value
+		default:
+			ok = false
+		}
+		if !ok {
+			return QueryLogSampling{}, fmt.Errorf("%s:%d: expected sample=N or rate=N", path, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return QueryLogSampling{}, err
+	}
+	return ParseQueryLogSampling(every, rate)
+}
+
+// queryLog writes the per-query log lines. The zero value logs blocked
+// queries only.
+type queryLog struct {
+	mu       sync.Mutex
+	sampling QueryLogSampling
+	allowed  uint64 // allowed queries seen since the sampling was set
+	// rate window, as in connlog.Logger
+	windowStart time.Time
+	inWindow    int
+	suppressed  int
+
+	// printf and now are replaced in tests.
+	printf func(format string, args ...any)
+	now    func() time.Time
+}
+
+// SetQueryLogSampling changes which allowed queries are logged. It is safe to
+// call while the proxy is serving, e.g. on SIGHUP.
+func (p *Proxy) SetQueryLogSampling(s QueryLogSampling) {
+	p.queries.mu.Lock()
+	defer p.queries.mu.Unlock()
+	p.queries.sampling = s
+	p.queries.allowed = 0
+	p.queries.inWindow, p.queries.suppressed = 0, 0
+}
+
+// QueryLogSampling returns the current sampling.
+func (p *Proxy) QueryLogSampling() QueryLogSampling {
+	p.queries.mu.Lock()
+	defer p.queries.mu.Unlock()
+	return p.queries.sampling
+}
+
+func (l *queryLog) logf(format string, args ...any) {
+	if l.printf != nil {
+		l.printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// sampleAllowed reports whether the next allowed query is logged.
+func (l *queryLog) sampleAllowed() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.sampling
+	if s.Every <= 0 {
+		return false
+	}
+	l.allowed++
+	if (l.allowed-1)%uint64(s.Every) != 0 {
+		return false
+	}
+	if s.Rate <= 0 {
+		return true
+	}
+	now := time.Now()
+	if l.now != nil {
+		now = l.now()
+	}
+	if now.Sub(l.windowStart) >= time.Second {
+		if l.suppressed > 0 {
+			l.logf("[dns] suppressed %d sampled query records over the %d/s limit", l.suppressed, s.Rate)
+		}
+		l.windowStart, l.inWindow, l.suppressed = now, 0, 0
+	}
+	if l.inWindow >= s.Rate {
+		l.suppressed++
+		return false
+	}
+	l.inWindow++
+	return true
+}
+
+// logAllowed logs an answered query if it is sampled.
+func (p *Proxy) logAllowed(q dns.Question, l *Listener, resp *dns.Msg, origin *policy.RuleOrigin) {
+	if !p.queries.sampleAllowed() {
+		return
+	}
+	p.queries.logf("[dns] allowed %s %s %s rcode=%s answers=%d%s%s", q.Name, dns.Class(q.Qclass), dns.Type(q.Qtype),
+		dns.RcodeToString[resp.Rcode], len(resp.Answer), listenerField(l), originField(origin))
+}
+
+// originField renders the origin of the deciding rule for a log line, or
+// nothing when the default action decided.
+func originField(origin *policy.RuleOrigin) string {
+	if origin == nil {
+		return ""
+	}
+	return fmt.Sprintf(" origin=%q", origin.String())
+}
@@ -0,0 +1,158 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// captureQueryLog collects the proxy's query log lines.
+func captureQueryLog(p *Proxy) func() []string {
+	var mu sync.Mutex
+	var lines []string
+	p.queries.printf = func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), lines...)
+	}
+}
+
+func countPrefix(lines []string, prefix string) int {
+	n := 0
+	for _, l := range lines {
+		if strings.HasPrefix(l, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestServeDNS_QueryLogSamplesAllowsButNotBlocks(t *testing.T) {
+	upstream := startStub(t, "127.0.0.1:0", answerWith("192.0.2.1"))
+	p := &Proxy{upstream: upstream.addr}
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"allow","egress":[{"action":"deny","target":"*.blocked.test"}]}`))
+	probe := new(dns.Msg)
+	probe.SetQuestion("probe.example.com.", dns.TypeA)
+	_, err := p.forward(probe)
+	skipIfNoMark(t, err)
+
+	lines := captureQueryLog(p)
+	p.SetQueryLogSampling(QueryLogSampling{Every: 5})
+	for i := 0; i < 20; i++ {
+		if resp := query(t, p, fmt.Sprintf("host%d.example.com.", i), dns.TypeA); resp.Rcode != dns.RcodeSuccess {
+			t.Fatalf("unexpected reply %v", resp)
+		}
+		query(t, p, fmt.Sprintf("host%d.blocked.test.", i), dns.TypeA)
+	}
+	got := lines()
+	if want := `[dns] blocked host0.blocked.test. IN A reason=explicit_deny rule="*.blocked.test" origin="egress[0] line 1"`; countPrefix(got, want) != 1 {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	if n := countPrefix(got, "[dns] blocked "); n != 20 {
+		t.Fatalf("expected every block logged, got %d: %q", n, got)
+	}
+	if n := countPrefix(got, "[dns] allowed "); n != 4 {
+		t.Fatalf("expected 1 in 5 allows logged, got %d: %q", n, got)
+	}
+	if want := "[dns] allowed host0.example.com. IN A rcode=NOERROR answers=1"; countPrefix(got, want) != 1 {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	// sampling off still logs blocks
+	p.SetQueryLogSampling(QueryLogSampling{})
+	query(t, p, "late.example.com.", dns.TypeA)
+	query(t, p, "late.blocked.test.", dns.TypeA)
+	got = lines()[len(got):]
+	if len(got) != 1 || !strings.HasPrefix(got[0], "[dns] blocked late.blocked.test.") {
+		t.Fatalf("expected only the block logged, got %q", got)
+	}
+}
+
+func TestQueryLog_RateCapSummarizesExcess(t *testing.T) {
+	p := &Proxy{}
+	lines := captureQueryLog(p)
+	now := time.Unix(1000, 0)
+	p.queries.now = func() time.Time { return now }
+	p.SetQueryLogSampling(QueryLogSampling{Every: 1, Rate: 3})
+
+	logged := 0
+	for i := 0; i < 10; i++ {
+		if p.queries.sampleAllowed() {
+			logged++
+		}
+	}
+	if logged != 3 {
+		t.Fatalf("expected 3 allows logged within a second, got %d", logged)
+	}
+	now = now.Add(time.Second)
+	if !p.queries.sampleAllowed() {
+		t.Fatalf("expected logging to resume in the next second")
+	}
+	if got := lines(); len(got) != 1 || !strings.Contains(got[0], "suppressed 7 ") {
+		t.Fatalf("expected a summary of 7 suppressed records, got %q", got)
+	}
+}
+
+func TestParseQueryLogSampling(t *testing.T) {
+	cases := []struct {
+		every, rate string
+		want        QueryLogSampling
+		wantErr     bool
+	}{
+		{"", "", QueryLogSampling{}, false},
+		{"1", "", QueryLogSampling{Every: 1}, false},
+		{" 100 ", "50", QueryLogSampling{Every: 100, Rate: 50}, false},
+		{"-1", "", QueryLogSampling{}, true},
+		{"ten", "", QueryLogSampling{}, true},
+		{"1", "-5", QueryLogSampling{}, true},
+	}
+	for _, c := range cases {
+		got, err := ParseQueryLogSampling(c.every, c.rate)
+		if (err != nil) != c.wantErr || got != c.want {
+			t.Errorf("ParseQueryLogSampling(%q, %q) = %+v, %v", c.every, c.rate, got, err)
+		}
+	}
+}
+
+func TestReadQueryLogSampling(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "querylog")
+	if err := os.WriteFile(path, []byte("# reloaded on SIGHUP\nsample=10\n\nrate = 20\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadQueryLogSampling(path)
+	if err != nil || got != (QueryLogSampling{Every: 10, Rate: 20}) {
+		t.Fatalf("ReadQueryLogSampling = %+v, %v", got, err)
+	}
+	if err := os.WriteFile(path, []byte("every=10\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadQueryLogSampling(path); err == nil {
+		t.Fatalf("expected an unknown key to be rejected")
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"net/netip"
+
+	"github.com/miekg/dns"
+)
+
+// ResolveEvent describes the addresses an allowed query was answered with.
+type ResolveEvent struct {
+	// Name is the queried name, even when the addresses belong to a CNAME target.
+	Name  string
+	Addrs []netip.Addr
+	// TTL is the lowest TTL among the address records, in seconds.
+	TTL uint32
+}
+
+// SetResolveHandler registers fn to receive the addresses of every answer
+// the main policy allows, forwarded or cached; nil removes it. Sinkhole
+// answers, and answers only a listener's own policy allows, are not reported. fn runs on the query path before the answer is written, so it
+// can prepare for the client's connection, and should return quickly.
+func (p *Proxy) SetResolveHandler(fn func(ResolveEvent)) {
+	p.resolveHandler = fn
+}
+
+func (p *Proxy) recordResolve(resp *dns.Msg) {
+	if p.resolveHandler == nil || resp == nil || len(resp.Question) == 0 {
+		return
+	}
+	ev := ResolveEvent{Name: resp.Question[0].Name}
+	for _, rr := range resp.Answer {
+		var addr netip.Addr
+		switch v := rr.(type) {
+		case *dns.A:
+			addr, _ = netip.AddrFromSlice(v.A.To4())
+		case *dns.AAAA:
+			addr, _ = netip.AddrFromSlice(v.AAAA)
+		default:
+			continue
+		}
+		if !addr.IsValid() {
+			continue
+		}
+		if len(ev.Addrs) == 0 || rr.Header().Ttl < ev.TTL {
+			ev.TTL = rr.Header().Ttl
+		}
+		ev.Addrs = append(ev.Addrs, addr)
+	}
+	if len(ev.Addrs) > 0 {
+		p.resolveHandler(ev)
+	}
+}
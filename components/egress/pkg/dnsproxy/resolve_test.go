@@ -0,0 +1,93 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServeDNS_ResolveHandler(t *testing.T) {
+	upstream := startStub(t, "127.0.0.1:0", func(q dns.Question, resp *dns.Msg) {
+		resp.Answer = []dns.RR{
+			&dns.CNAME{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 300}, Target: "edge.cdn.example.net."},
+			&dns.A{Hdr: dns.RR_Header{Name: "edge.cdn.example.net.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.ParseIP("192.0.2.7")},
+			&dns.A{Hdr: dns.RR_Header{Name: "edge.cdn.example.net.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 30}, A: net.ParseIP("192.0.2.8")},
+		}
+	})
+	p := &Proxy{upstream: upstream.addr}
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"deny","egress":[{"action":"allow","target":"www.example.com"}]}`))
+	p.SetCache(true)
+	var events []ResolveEvent
+	p.SetResolveHandler(func(ev ResolveEvent) { events = append(events, ev) })
+
+	probe := new(dns.Msg)
+	probe.SetQuestion("www.example.com.", dns.TypeA)
+	_, err := p.forward(probe)
+	skipIfNoMark(t, err)
+
+	query(t, p, "www.example.com.", dns.TypeA)
+	query(t, p, "www.example.com.", dns.TypeA) // from the cache
+	query(t, p, "denied.example.com.", dns.TypeA)
+
+	if len(events) != 2 {
+		t.Fatalf("expected one event per allowed answer, got %+v", events)
+	}
+	want := []netip.Addr{netip.MustParseAddr("192.0.2.7"), netip.MustParseAddr("192.0.2.8")}
+	ev := events[0]
+	if ev.Name != "www.example.com." || ev.TTL != 30 || len(ev.Addrs) != 2 || ev.Addrs[0] != want[0] || ev.Addrs[1] != want[1] {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+}
+
+func TestServeQuery_ListenerPolicyAnswersAreNotReported(t *testing.T) {
+	upstream := startStub(t, "127.0.0.1:0", func(q dns.Question, resp *dns.Msg) {
+		resp.Answer = []dns.RR{
+			&dns.A{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.ParseIP("192.0.2.9")},
+		}
+	})
+	p := &Proxy{upstream: upstream.addr}
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"deny","egress":[{"action":"allow","target":"shared.test"}]}`))
+	sidecar := &Listener{Label: "sidecar",
+		Policy: mustPolicy(t, `{"defaultAction":"deny","egress":[{"action":"allow","target":"shared.test"},{"action":"allow","target":"telemetry.test"}]}`)}
+	var events []ResolveEvent
+	p.SetResolveHandler(func(ev ResolveEvent) { events = append(events, ev) })
+
+	probe := new(dns.Msg)
+	probe.SetQuestion("shared.test.", dns.TypeA)
+	_, err := p.forward(probe)
+	skipIfNoMark(t, err)
+
+	ask := func(name string) *dns.Msg {
+		req := new(dns.Msg)
+		req.SetQuestion(name, dns.TypeA)
+		w := &recordingWriter{}
+		p.serveQuery(w, req, sidecar)
+		return w.msg
+	}
+	// the sidecar's policy allows telemetry.test, the main one does not
+	if resp := ask("telemetry.test."); resp == nil || len(resp.Answer) != 1 {
+		t.Fatalf("expected the sidecar to answer telemetry.test, got %v", resp)
+	}
+	if resp := ask("shared.test."); resp == nil || len(resp.Answer) != 1 {
+		t.Fatalf("expected the sidecar to answer shared.test, got %v", resp)
+	}
+	if len(events) != 1 || events[0].Name != "shared.test." {
+		t.Fatalf("expected only the answer the main policy allows to be reported, got %+v", events)
+	}
+}
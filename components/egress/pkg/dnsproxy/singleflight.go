@@ -0,0 +1,74 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// flight is one upstream exchange that identical queries arriving while it
+// is in progress wait for instead of sending their own.
+type flight struct {
+	done chan struct{}
+	resp *dns.Msg // read-only once done is closed; callers get copies
+	err  error
+}
+
+// flightGroup tracks the upstream exchanges in progress, by question.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[cacheKey]*flight
+}
+
+// forwardShared forwards r like forward, except that concurrent queries for
+// the same question share one upstream exchange and all get its answer, or
+// its error. Like the cache, it treats answers as depending on the question
+// only, so it must not be used for queries whose answer is tailored to the
+// client. Each caller gets its own copy with its own message ID.
+func (p *Proxy) forwardShared(r *dns.Msg) (*dns.Msg, error) {
+	key := newCacheKey(r.Question[0])
+	g := &p.inflight
+	g.mu.Lock()
+	if f, ok := g.flights[key]; ok {
+		g.mu.Unlock()
+		<-f.done
+		return sharedReply(r, f)
+	}
+	if g.flights == nil {
+		g.flights = make(map[cacheKey]*flight)
+	}
+	f := &flight{done: make(chan struct{})}
+	g.flights[key] = f
+	g.mu.Unlock()
+
+	f.resp, f.err = p.forward(r)
+
+	g.mu.Lock()
+	delete(g.flights, key)
+	g.mu.Unlock()
+	close(f.done)
+	return sharedReply(r, f)
+}
+
+func sharedReply(r *dns.Msg, f *flight) (*dns.Msg, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	resp := f.resp.Copy()
+	resp.Id = r.Id
+	return resp, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestServeDNS_CoalescesConcurrentIdenticalQueries(t *testing.T) {
+	release := make(chan struct{})
+	upstream := startStub(t, "127.0.0.1:0", func(q dns.Question, resp *dns.Msg) {
+		if q.Name == "slow.example.com." {
+			// hold the first exchange until every query has arrived
+			<-release
+		}
+		answerWith("192.0.2.30")(q, resp)
+	})
+	p := &Proxy{upstream: upstream.addr}
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"allow"}`))
+	probe := new(dns.Msg)
+	probe.SetQuestion("probe.example.com.", dns.TypeA)
+	_, err := p.forward(probe)
+	skipIfNoMark(t, err)
+	if err != nil {
+		t.Fatalf("forward: %v", err)
+	}
+
+	const clients = 50
+	replies := make([]*dns.Msg, clients)
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := new(dns.Msg)
+			req.SetQuestion("slow.example.com.", dns.TypeA)
+			req.Id = uint16(1000 + i)
+			w := &recordingWriter{}
+			p.serveDNS(w, req)
+			replies[i] = w.msg
+		}()
+	}
+	time.Sleep(200 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	n := 0
+	for _, q := range upstream.questions() {
+		if q == "slow.example.com. A" {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Fatalf("expected one upstream query for %d identical queries, got %d", clients, n)
+	}
+	for i, resp := range replies {
+		if resp == nil || resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 {
+			t.Fatalf("client %d: unexpected reply %v", i, resp)
+		}
+		if resp.Id != uint16(1000+i) {
+			t.Fatalf("client %d: reply carries ID %d of another query", i, resp.Id)
+		}
+		if got := resp.Answer[0].(*dns.A).A.String(); got != "192.0.2.30" {
+			t.Fatalf("client %d: got %s", i, got)
+		}
+	}
+
+	// once the exchange is done, the next query goes upstream again
+	query(t, p, "slow.example.com.", dns.TypeA)
+	if got := len(upstream.questions()); got != 3 {
+		t.Fatalf("expected a later query to be forwarded, got %v", upstream.questions())
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// sinkholeTTL keeps synthesized answers short-lived so a policy change that
+// unblocks a name takes effect quickly in client caches.
+const sinkholeTTL = 30
+
+// SetSinkhole answers blocked names with the given addresses instead of
+// NXDOMAIN. spec is a comma-separated list holding at most one IPv4 and one
+// IPv6 address; empty disables sinkhole mode.
+func (p *Proxy) SetSinkhole(spec string) error {
+	var v4, v6 net.IP
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		ip := net.ParseIP(part)
+		if ip == nil {
+			return fmt.Errorf("sinkhole address %q is not an IP", part)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			if v4 != nil {
+				return fmt.Errorf("multiple IPv4 sinkhole addresses: %s, %s", v4, ip4)
+			}
+			v4 = ip4
+			continue
+		}
+		if v6 != nil {
+			return fmt.Errorf("multiple IPv6 sinkhole addresses: %s, %s", v6, ip)
+		}
+		v6 = ip
+	}
+	p.sinkholeV4, p.sinkholeV6 = v4, v6
+	return nil
+}
+
+func (p *Proxy) sinkholeEnabled() bool {
+	return p.sinkholeV4 != nil || p.sinkholeV6 != nil
+}
+
+// sinkholeReply points A/AAAA questions at the sinkhole. Other types, and a
+// family with no sinkhole address, get an empty NOERROR answer so clients do
+// not fall back to another resolver.
+func (p *Proxy) sinkholeReply(r *dns.Msg) *dns.Msg {
+	resp := new(dns.Msg)
+	resp.SetReply(r)
+	resp.Authoritative = true
+
+	q := r.Question[0]
+	hdr := dns.RR_Header{Name: q.Name, Class: dns.ClassINET, Ttl: sinkholeTTL}
+	switch {
+	case q.Qtype == dns.TypeA && p.sinkholeV4 != nil:
+		hdr.Rrtype = dns.TypeA
+		resp.Answer = append(resp.Answer, &dns.A{Hdr: hdr, A: p.sinkholeV4})
+	case q.Qtype == dns.TypeAAAA && p.sinkholeV6 != nil:
+		hdr.Rrtype = dns.TypeAAAA
+		resp.Answer = append(resp.Answer, &dns.AAAA{Hdr: hdr, AAAA: p.sinkholeV6})
+	}
+	return resp
+}
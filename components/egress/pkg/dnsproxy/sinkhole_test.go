@@ -0,0 +1,130 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	"github.com/alibaba/opensandbox/egress/pkg/policy"
+)
+
+// recordingWriter captures the reply written by serveDNS.
+type recordingWriter struct {
+	msg *dns.Msg
+}
+
+func (w *recordingWriter) LocalAddr() net.Addr       { return &net.UDPAddr{} }
+func (w *recordingWriter) RemoteAddr() net.Addr      { return &net.UDPAddr{} }
+func (w *recordingWriter) WriteMsg(m *dns.Msg) error { w.msg = m; return nil }
+func (w *recordingWriter) Write([]byte) (int, error) { return 0, nil }
+func (w *recordingWriter) Close() error              { return nil }
+func (w *recordingWriter) TsigStatus() error         { return nil }
+func (w *recordingWriter) TsigTimersOnly(bool)       {}
+func (w *recordingWriter) Hijack()                   {}
+
+func query(t *testing.T, p *Proxy, name string, qtype uint16) *dns.Msg {
+	t.Helper()
+	req := new(dns.Msg)
+	req.SetQuestion(name, qtype)
+	w := &recordingWriter{}
+	p.serveDNS(w, req)
+	if w.msg == nil {
+		t.Fatalf("no reply for %s", name)
+	}
+	return w.msg
+}
+
+func newDenyProxy(t *testing.T) *Proxy {
+	t.Helper()
+	pol, err := policy.ParsePolicy(`{"defaultAction":"allow","egress":[{"action":"deny","target":"*.blocked.test"}]}`)
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	// the unroutable upstream makes any forwarded query fail fast with SERVFAIL.
+	return &Proxy{policy: pol, upstream: "127.0.0.1:1"}
+}
+
+func TestServeDNS_SinkholeAnswersBlockedNames(t *testing.T) {
+	p := newDenyProxy(t)
+	if err := p.SetSinkhole("10.0.0.2, fd00::2"); err != nil {
+		t.Fatalf("SetSinkhole: %v", err)
+	}
+
+	resp := query(t, p, "api.blocked.test.", dns.TypeA)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 {
+		t.Fatalf("expected one A answer, got rcode=%d answers=%v", resp.Rcode, resp.Answer)
+	}
+	a, ok := resp.Answer[0].(*dns.A)
+	if !ok || !a.A.Equal(net.ParseIP("10.0.0.2")) || a.Hdr.Name != "api.blocked.test." || a.Hdr.Ttl != sinkholeTTL {
+		t.Fatalf("unexpected A answer: %v", resp.Answer[0])
+	}
+
+	resp = query(t, p, "api.blocked.test.", dns.TypeAAAA)
+	aaaa, ok := resp.Answer[0].(*dns.AAAA)
+	if !ok || !aaaa.AAAA.Equal(net.ParseIP("fd00::2")) {
+		t.Fatalf("unexpected AAAA answer: %v", resp.Answer)
+	}
+
+	resp = query(t, p, "api.blocked.test.", dns.TypeMX)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 {
+		t.Fatalf("expected empty NOERROR for MX, got rcode=%d answers=%v", resp.Rcode, resp.Answer)
+	}
+}
+
+func TestServeDNS_SinkholeMissingFamilyIsNoData(t *testing.T) {
+	p := newDenyProxy(t)
+	if err := p.SetSinkhole("10.0.0.2"); err != nil {
+		t.Fatalf("SetSinkhole: %v", err)
+	}
+
+	resp := query(t, p, "api.blocked.test.", dns.TypeAAAA)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 {
+		t.Fatalf("expected empty NOERROR for AAAA without IPv6 sinkhole, got rcode=%d answers=%v", resp.Rcode, resp.Answer)
+	}
+}
+
+func TestServeDNS_BlockedWithoutSinkholeIsNXDomain(t *testing.T) {
+	p := newDenyProxy(t)
+
+	resp := query(t, p, "api.blocked.test.", dns.TypeA)
+	if resp.Rcode != dns.RcodeNameError || len(resp.Answer) != 0 {
+		t.Fatalf("expected NXDOMAIN, got rcode=%d answers=%v", resp.Rcode, resp.Answer)
+	}
+
+	if err := p.SetSinkhole("10.0.0.2"); err != nil {
+		t.Fatalf("SetSinkhole: %v", err)
+	}
+	if err := p.SetSinkhole(""); err != nil {
+		t.Fatalf("SetSinkhole(\"\"): %v", err)
+	}
+	if resp = query(t, p, "api.blocked.test.", dns.TypeA); resp.Rcode != dns.RcodeNameError {
+		t.Fatalf("expected NXDOMAIN after clearing sinkhole, got rcode=%d", resp.Rcode)
+	}
+}
+
+func TestSetSinkholeValidation(t *testing.T) {
+	p := &Proxy{}
+	for _, spec := range []string{"not-an-ip", "10.0.0.1,10.0.0.2", "fd00::1,fd00::2"} {
+		if err := p.SetSinkhole(spec); err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+	if p.sinkholeEnabled() {
+		t.Fatalf("failed SetSinkhole must not enable sinkhole mode")
+	}
+}
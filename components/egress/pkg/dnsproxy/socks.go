@@ -0,0 +1,107 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/proxy"
+)
+
+// ParseSOCKS5URL validates a socks5://[user[:password]@]host:port proxy URL.
+func ParseSOCKS5URL(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "socks5" && u.Scheme != "socks5h" {
+		return nil, fmt.Errorf("scheme must be socks5, got %q", u.Scheme)
+	}
+	if u.Hostname() == "" || u.Port() == "" {
+		return nil, fmt.Errorf("%s needs a host and a port", u.Redacted())
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		return nil, fmt.Errorf("%s must not have a path or query", u.Redacted())
+	}
+	return u, nil
+}
+
+// SetUpstreamSOCKS5 sends upstream queries through the SOCKS5 proxy at raw.
+// SOCKS tunnels such as ssh -D carry TCP only, so queries are forwarded over
+// TCP whichever transport they arrived on. Empty clears it.
+func (p *Proxy) SetUpstreamSOCKS5(raw string) error {
+	if strings.TrimSpace(raw) == "" {
+		p.socks = nil
+		return nil
+	}
+	u, err := ParseSOCKS5URL(raw)
+	if err != nil {
+		return err
+	}
+	p.socks = u
+	return nil
+}
+
+// UpstreamSOCKS5 returns the configured SOCKS5 proxy with its password
+// redacted, empty when there is none.
+func (p *Proxy) UpstreamSOCKS5() string {
+	if p.socks == nil {
+		return ""
+	}
+	return p.socks.Redacted()
+}
+
+// socksDialer connects through the SOCKS5 proxy. The connection to the proxy
+// carries SO_MARK like any other upstream query.
+func (p *Proxy) socksDialer() (proxy.ContextDialer, error) {
+	forward := p.dialerWithMark()
+	if p.sourceIP != nil {
+		forward.LocalAddr = &net.TCPAddr{IP: p.sourceIP}
+	}
+	var auth *proxy.Auth
+	if p.socks.User != nil {
+		password, _ := p.socks.User.Password()
+		auth = &proxy.Auth{User: p.socks.User.Username(), Password: password}
+	}
+	d, err := proxy.SOCKS5("tcp", p.socks.Host, auth, forward)
+	if err != nil {
+		return nil, err
+	}
+	return d.(proxy.ContextDialer), nil
+}
+
+// exchangeSOCKS5 sends r to server over TCP through the SOCKS5 proxy.
+func (p *Proxy) exchangeSOCKS5(r *dns.Msg, server string) (*dns.Msg, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	d, err := p.socksDialer()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := d.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, fmt.Errorf("socks5 proxy %s: %w", p.socks.Host, err)
+	}
+	defer conn.Close()
+	c := &dns.Client{Net: "tcp", Timeout: 5 * time.Second}
+	resp, _, err := c.ExchangeWithConnContext(ctx, r, &dns.Conn{Conn: conn})
+	return resp, err
+}
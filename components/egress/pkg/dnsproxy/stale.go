@@ -0,0 +1,99 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/alibaba/opensandbox/egress/pkg/policy"
+)
+
+const (
+	// staleAnswerTTL is the TTL of answers served past expiry, as RFC 8767
+	// recommends, so clients come back soon and pick up the refreshed answer.
+	staleAnswerTTL = 30
+	// maxStaleWindow caps the stale window at the three days RFC 8767 suggests
+	// as an upper bound.
+	maxStaleWindow = 3 * 24 * 60 * 60
+)
+
+// SetCacheStaleWindow lets the cache serve an answer for up to seconds past its
+// TTL while refreshing it in the background (stale-while-revalidate). Queries
+// for answers older than that are forwarded as usual and fail if the upstream
+// does. Zero disables it; the answer cache must be enabled first.
+func (p *Proxy) SetCacheStaleWindow(seconds uint32) error {
+	if seconds > maxStaleWindow {
+		return fmt.Errorf("stale window %ds exceeds %ds", seconds, maxStaleWindow)
+	}
+	if p.cache == nil {
+		if seconds == 0 {
+			return nil
+		}
+		return errors.New("stale window needs the answer cache to be enabled")
+	}
+	p.cache.staleWindow = time.Duration(seconds) * time.Second
+	return nil
+}
+
+// ParseCacheStaleWindow parses a stale window given in seconds, e.g. "300".
+func ParseCacheStaleWindow(raw string) (uint32, error) {
+	seconds, err := strconv.ParseUint(strings.TrimSpace(raw), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("stale window %q is not a number of seconds: %w", raw, err)
+	}
+	return uint32(seconds), nil
+}
+
+// revalidate refreshes the cached answer for r's question in the background.
+// At most one refresh per question runs at a time. A successful reply replaces
+// the entry, or drops it when the reply is not cacheable (e.g. the name is
+// gone); a failed exchange leaves it to be served until the stale window ends.
+func (p *Proxy) revalidate(r *dns.Msg, evaluatedBy *policy.NetworkPolicy) {
+	cache := p.cache
+	key := newCacheKey(r.Question[0])
+	cache.mu.Lock()
+	if _, busy := cache.refreshing[key]; busy {
+		cache.mu.Unlock()
+		return
+	}
+	cache.refreshing[key] = struct{}{}
+	cache.mu.Unlock()
+
+	req := r.Copy()
+	go func() {
+		defer func() {
+			cache.mu.Lock()
+			delete(cache.refreshing, key)
+			cache.mu.Unlock()
+		}()
+		resp, err := p.forward(req)
+		if err != nil {
+			log.Printf("[dns] revalidating stale answer for %s: %v", req.Question[0].Name, err)
+			return
+		}
+		p.clampTTL(resp)
+		cache.mu.Lock()
+		cache.remove(key, "")
+		cache.mu.Unlock()
+		p.storeCached(resp, evaluatedBy, time.Now())
+	}()
+}
@@ -0,0 +1,174 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// seedCache stores an answer for name as if it had been resolved age ago with the given ttl.
+func seedCache(t *testing.T, p *Proxy, name, ip string, ttl uint32, age time.Duration) {
+	t.Helper()
+	req := new(dns.Msg)
+	req.SetQuestion(name, dns.TypeA)
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Answer = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl}, A: net.ParseIP(ip)}}
+	p.storeCached(resp, p.CurrentPolicy(), time.Now().Add(-age))
+}
+
+func answerIP(t *testing.T, resp *dns.Msg) (string, uint32) {
+	t.Helper()
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 {
+		t.Fatalf("unexpected reply %v", resp)
+	}
+	a := resp.Answer[0].(*dns.A)
+	return a.A.String(), a.Hdr.Ttl
+}
+
+func TestServeDNS_ServesStaleWhileRevalidating(t *testing.T) {
+	const delay = 500 * time.Millisecond
+	slow := answerWith("192.0.2.99")
+	upstream := startStub(t, "127.0.0.1:0", func(q dns.Question, resp *dns.Msg) {
+		time.Sleep(delay)
+		slow(q, resp)
+	})
+	p := &Proxy{upstream: upstream.addr}
+	p.SetCache(true)
+	if err := p.SetCacheStaleWindow(300); err != nil {
+		t.Fatalf("SetCacheStaleWindow: %v", err)
+	}
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"allow"}`))
+
+	probe := new(dns.Msg)
+	probe.SetQuestion("probe.example.com.", dns.TypeA)
+	_, err := p.forward(probe)
+	skipIfNoMark(t, err)
+	if err != nil {
+		t.Fatalf("forward: %v", err)
+	}
+
+	// Expired 30s ago, well inside the 300s stale window.
+	seedCache(t, p, "api.example.com.", "192.0.2.1", 60, 90*time.Second)
+
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		resp := query(t, p, "api.example.com.", dns.TypeA)
+		if elapsed := time.Since(start); elapsed >= delay {
+			t.Fatalf("stale answer %d took %v, it waited for the slow upstream", i, elapsed)
+		}
+		if ip, ttl := answerIP(t, resp); ip != "192.0.2.1" || ttl != staleAnswerTTL {
+			t.Fatalf("stale answer %d = %s ttl %d, want the cached address with ttl %d", i, ip, ttl, staleAnswerTTL)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		req := new(dns.Msg)
+		req.SetQuestion("api.example.com.", dns.TypeA)
+		if resp, stale := p.cached(req, time.Now()); resp != nil && !stale {
+			if ip, _ := answerIP(t, resp); ip != "192.0.2.99" {
+				t.Fatalf("refreshed answer = %s, want the upstream's", ip)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stale answer was never refreshed")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	// One probe plus a single refresh, although the stale answer was served twice.
+	if got := upstream.questions(); len(got) != 2 {
+		t.Fatalf("expected one background refresh, upstream saw %v", got)
+	}
+}
+
+func TestServeDNS_StaleWindowBoundsUpstreamFailures(t *testing.T) {
+	// The unroutable upstream makes every refresh and forward fail.
+	p := &Proxy{upstream: "127.0.0.1:1"}
+	p.SetCache(true)
+	if err := p.SetCacheStaleWindow(300); err != nil {
+		t.Fatalf("SetCacheStaleWindow: %v", err)
+	}
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"allow"}`))
+
+	seedCache(t, p, "within.example.com.", "192.0.2.1", 60, 90*time.Second)
+	seedCache(t, p, "beyond.example.com.", "192.0.2.2", 60, 400*time.Second)
+
+	if ip, _ := answerIP(t, query(t, p, "within.example.com.", dns.TypeA)); ip != "192.0.2.1" {
+		t.Fatalf("within the stale window got %s, want the cached address", ip)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		p.cache.mu.Lock()
+		_, busy := p.cache.refreshing[newCacheKey(dns.Question{Name: "within.example.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET})]
+		p.cache.mu.Unlock()
+		if !busy {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("refresh never finished")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if ip, _ := answerIP(t, query(t, p, "within.example.com.", dns.TypeA)); ip != "192.0.2.1" {
+		t.Fatalf("a failed refresh dropped the stale answer, got %s", ip)
+	}
+
+	if resp := query(t, p, "beyond.example.com.", dns.TypeA); resp.Rcode != dns.RcodeServerFailure {
+		t.Fatalf("beyond the stale window with the upstream down, expected SERVFAIL, got %v", resp)
+	}
+}
+
+func TestCached_StaleWindowDisabledByDefault(t *testing.T) {
+	p := &Proxy{}
+	p.SetCache(true)
+	p.UpdatePolicy(mustPolicy(t, `{"defaultAction":"allow"}`))
+	seedCache(t, p, "api.example.com.", "192.0.2.1", 60, 61*time.Second)
+
+	req := new(dns.Msg)
+	req.SetQuestion("api.example.com.", dns.TypeA)
+	if resp, _ := p.cached(req, time.Now()); resp != nil {
+		t.Fatalf("expired answer served without a stale window: %v", resp)
+	}
+}
+
+func TestSetCacheStaleWindow(t *testing.T) {
+	p := &Proxy{}
+	if err := p.SetCacheStaleWindow(60); err == nil {
+		t.Fatalf("expected error without the answer cache")
+	}
+	if err := p.SetCacheStaleWindow(0); err != nil {
+		t.Fatalf("disabling without the cache: %v", err)
+	}
+	p.SetCache(true)
+	if err := p.SetCacheStaleWindow(maxStaleWindow + 1); err == nil {
+		t.Fatalf("expected error above the cap")
+	}
+	if err := p.SetCacheStaleWindow(300); err != nil || p.cache.staleWindow != 300*time.Second {
+		t.Fatalf("SetCacheStaleWindow(300) = %v, window %v", err, p.cache.staleWindow)
+	}
+
+	if window, err := ParseCacheStaleWindow(" 300 "); err != nil || window != 300 {
+		t.Fatalf("ParseCacheStaleWindow(300) = %d, %v", window, err)
+	}
+	if _, err := ParseCacheStaleWindow("5m"); err == nil {
+		t.Fatalf("expected error for a non-numeric window")
+	}
+}
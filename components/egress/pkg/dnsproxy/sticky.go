@@ -0,0 +1,119 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+)
+
+// maxStickyNames bounds how many names remember their upstream; past it, an
+// arbitrary name forgets its upstream to make room.
+const maxStickyNames = 4096
+
+// stickyUpstreams remembers, per query name, the upstream that last answered
+// it successfully.
+type stickyUpstreams struct {
+	mu    sync.Mutex
+	names map[string]string // lower-cased FQDN -> upstream address
+	next  atomic.Uint32     // rotation for names without an upstream yet
+}
+
+// SetStickyUpstream makes the proxy use every nameserver of resolv.conf and
+// send repeated queries for a name to the upstream that answered it last, so
+// geo- or ECS-sensitive answers stay the same. Names seen for the first time
+// are spread over the upstreams in turn. When the remembered upstream fails,
+// the others are tried in order and the first to answer is remembered instead.
+// Conditional upstreams and query name minimization are not affected.
+func (p *Proxy) SetStickyUpstream(enabled bool) {
+	if !enabled {
+		p.sticky = nil
+		return
+	}
+	p.sticky = &stickyUpstreams{names: make(map[string]string)}
+}
+
+// ParseStickyUpstream parses the on/off switch, e.g. "true" or "1".
+func ParseStickyUpstream(raw string) (bool, error) {
+	enabled, err := strconv.ParseBool(strings.TrimSpace(raw))
+	if err != nil {
+		return false, fmt.Errorf("sticky upstream %q is not a boolean: %w", raw, err)
+	}
+	return enabled, nil
+}
+
+// Upstreams returns the default upstreams, the first of which is used unless
+// sticky or latency-based selection is on.
+func (p *Proxy) Upstreams() []string {
+	if len(p.upstreams) == 0 {
+		return []string{p.upstream}
+	}
+	return append([]string(nil), p.upstreams...)
+}
+
+// exchangeSticky sends r to the upstream remembered for its name, or to the
+// next one in turn, and falls back to the others while the answer is an error,
+// SERVFAIL or REFUSED. The last failure is returned when all of them fail.
+func (p *Proxy) exchangeSticky(r *dns.Msg) (*dns.Msg, error) {
+	upstreams := p.Upstreams()
+	name := strings.ToLower(r.Question[0].Name)
+	first := p.sticky.pick(name, upstreams)
+
+	var (
+		resp *dns.Msg
+		err  error
+	)
+	for i := range upstreams {
+		server := upstreams[(first+i)%len(upstreams)]
+		resp, err = p.exchange(r, server)
+		if err == nil && resp.Rcode != dns.RcodeServerFailure && resp.Rcode != dns.RcodeRefused {
+			p.sticky.remember(name, server)
+			return resp, nil
+		}
+	}
+	return resp, err
+}
+
+// pick returns the index of the upstream to try first for name.
+func (s *stickyUpstreams) pick(name string, upstreams []string) int {
+	s.mu.Lock()
+	server, ok := s.names[name]
+	s.mu.Unlock()
+	if ok {
+		for i, u := range upstreams {
+			if u == server {
+				return i
+			}
+		}
+	}
+	return int((s.next.Add(1) - 1) % uint32(len(upstreams)))
+}
+
+func (s *stickyUpstreams) remember(name, server string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.names[name]; !ok && len(s.names) >= maxStickyNames {
+		for evict := range s.names {
+			delete(s.names, evict)
+			break
+		}
+	}
+	s.names[name] = server
+}
@@ -0,0 +1,80 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// maxMinTTL caps the configurable floor at one day; anything longer would pin
+// stale addresses well past a CDN's rotation.
+const maxMinTTL = 86400
+
+// SetMinTTL raises answer TTLs below seconds up to it, so clients keep short-TTL
+// CDN addresses for at least that long instead of re-resolving every few
+// seconds. Zero disables clamping.
+func (p *Proxy) SetMinTTL(seconds uint32) error {
+	if seconds > maxMinTTL {
+		return fmt.Errorf("minimum ttl %ds exceeds %ds", seconds, maxMinTTL)
+	}
+	p.minTTL = seconds
+	return nil
+}
+
+// ParseMinTTL parses a minimum TTL given in seconds, e.g. "30".
+func ParseMinTTL(raw string) (uint32, error) {
+	seconds, err := strconv.ParseUint(strings.TrimSpace(raw), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("minimum ttl %q is not a number of seconds: %w", raw, err)
+	}
+	return uint32(seconds), nil
+}
+
+// clampTTL applies the minimum TTL, or the TTL bounds of the question's
+// query type rule, to the answer section. Authority records, including the
+// SOA that bounds negative caching, are left untouched.
+func (p *Proxy) clampTTL(resp *dns.Msg) {
+	if resp == nil {
+		return
+	}
+	floor, ceiling := p.minTTL, uint32(0)
+	if len(resp.Question) > 0 {
+		rule := p.qtypeRuleFor(resp.Question[0].Qtype)
+		if rule.hasMinTTL {
+			floor = rule.minTTL
+		}
+		ceiling = rule.maxTTL
+	}
+	if ceiling > 0 && floor > ceiling {
+		// a type's cap wins over the proxy-wide floor
+		floor = ceiling
+	}
+	if floor == 0 && ceiling == 0 {
+		return
+	}
+	for _, rr := range resp.Answer {
+		hdr := rr.Header()
+		if hdr.Ttl < floor {
+			hdr.Ttl = floor
+		}
+		if ceiling > 0 && hdr.Ttl > ceiling {
+			hdr.Ttl = ceiling
+		}
+	}
+}
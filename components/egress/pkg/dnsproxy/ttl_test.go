@@ -0,0 +1,142 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	"github.com/alibaba/opensandbox/egress/pkg/policy"
+)
+
+// shortTTLUpstream answers every A query with a CNAME and an A record, both
+// carrying a 1s TTL, like a CDN edge.
+func shortTTLUpstream(t *testing.T) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen fake upstream: %v", err)
+	}
+	t.Cleanup(func() { _ = pc.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := new(dns.Msg)
+			if req.Unpack(buf[:n]) != nil {
+				continue
+			}
+			resp := new(dns.Msg)
+			resp.SetReply(req)
+			name := req.Question[0].Name
+			resp.Answer = []dns.RR{
+				&dns.CNAME{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 1}, Target: "edge.cdn.test."},
+				&dns.A{Hdr: dns.RR_Header{Name: "edge.cdn.test.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 1}, A: net.ParseIP("203.0.113.7")},
+			}
+			out, _ := resp.Pack()
+			_, _ = pc.WriteTo(out, from)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func TestServeDNS_MinTTLStabilizesShortTTLAnswers(t *testing.T) {
+	pol, err := policy.ParsePolicy(`{"defaultAction":"allow"}`)
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	p := &Proxy{policy: pol, upstream: shortTTLUpstream(t)}
+	if err := p.SetMinTTL(30); err != nil {
+		t.Fatalf("SetMinTTL: %v", err)
+	}
+
+	// Every resolution in a row must keep the same address for at least the floor.
+	for i := 0; i < 5; i++ {
+		req := new(dns.Msg)
+		req.SetQuestion("static.cdn.test.", dns.TypeA)
+		if _, err := p.forward(req); err != nil {
+			if errors.Is(err, syscall.EPERM) {
+				t.Skipf("SO_MARK needs CAP_NET_ADMIN: %v", err)
+			}
+			t.Fatalf("forward: %v", err)
+		}
+
+		resp := query(t, p, "static.cdn.test.", dns.TypeA)
+		if len(resp.Answer) != 2 {
+			t.Fatalf("resolution %d: expected 2 answers, got %v", i, resp.Answer)
+		}
+		for _, rr := range resp.Answer {
+			if rr.Header().Ttl != 30 {
+				t.Fatalf("resolution %d: expected ttl raised to 30, got %v", i, rr)
+			}
+		}
+		if a := resp.Answer[1].(*dns.A); !a.A.Equal(net.ParseIP("203.0.113.7")) {
+			t.Fatalf("resolution %d: address changed to %s", i, a.A)
+		}
+	}
+}
+
+func TestClampTTL(t *testing.T) {
+	soa := &dns.SOA{Hdr: dns.RR_Header{Name: "cdn.test.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 5}}
+	resp := &dns.Msg{
+		Answer: []dns.RR{
+			&dns.A{Hdr: dns.RR_Header{Name: "a.cdn.test.", Rrtype: dns.TypeA, Ttl: 3}},
+			&dns.A{Hdr: dns.RR_Header{Name: "b.cdn.test.", Rrtype: dns.TypeA, Ttl: 600}},
+		},
+		Ns: []dns.RR{soa},
+	}
+
+	p := &Proxy{}
+	p.clampTTL(resp)
+	if resp.Answer[0].Header().Ttl != 3 {
+		t.Fatalf("expected no clamping when disabled")
+	}
+
+	if err := p.SetMinTTL(60); err != nil {
+		t.Fatalf("SetMinTTL: %v", err)
+	}
+	p.clampTTL(resp)
+	if got := resp.Answer[0].Header().Ttl; got != 60 {
+		t.Fatalf("expected short ttl raised to 60, got %d", got)
+	}
+	if got := resp.Answer[1].Header().Ttl; got != 600 {
+		t.Fatalf("expected long ttl kept at 600, got %d", got)
+	}
+	if soa.Hdr.Ttl != 5 {
+		t.Fatalf("expected negative-caching SOA untouched, got %d", soa.Hdr.Ttl)
+	}
+}
+
+func TestParseMinTTL(t *testing.T) {
+	if got, err := ParseMinTTL(" 30 "); err != nil || got != 30 {
+		t.Fatalf("ParseMinTTL(30) = %d, %v", got, err)
+	}
+	for _, raw := range []string{"30s", "-1", "abc"} {
+		if _, err := ParseMinTTL(raw); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+	if err := (&Proxy{}).SetMinTTL(maxMinTTL + 1); err == nil {
+		t.Fatalf("expected error above the maximum")
+	}
+}
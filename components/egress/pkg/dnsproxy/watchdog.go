@@ -0,0 +1,122 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// DeathAction is what the watchdog does when the proxy stops serving while
+// port 53 is still redirected to it.
+type DeathAction string
+
+const (
+	// DeathActionRestart starts the proxy again, exiting if that keeps failing.
+	DeathActionRestart DeathAction = "restart"
+	// DeathActionFailOpen removes the redirect so queries reach the resolver
+	// directly, unfiltered.
+	DeathActionFailOpen DeathAction = "fail-open"
+	// DeathActionExit exits the process so the container is restarted.
+	DeathActionExit DeathAction = "exit"
+)
+
+const (
+	restartAttempts     = 3
+	defaultRestartDelay = time.Second
+)
+
+// ParseDeathAction parses a DeathAction; empty means restart.
+func ParseDeathAction(raw string) (DeathAction, error) {
+	switch action := DeathAction(strings.ToLower(strings.TrimSpace(raw))); action {
+	case "":
+		return DeathActionRestart, nil
+	case DeathActionRestart, DeathActionFailOpen, DeathActionExit:
+		return action, nil
+	default:
+		return "", fmt.Errorf("proxy death action %q is not one of restart, fail-open, exit", raw)
+	}
+}
+
+// Watchdog reacts to the proxy stopping unexpectedly, so a dead proxy does not
+// leave the sandbox without DNS.
+type Watchdog struct {
+	Action DeathAction
+	// FailOpen removes the DNS redirect; used by DeathActionFailOpen.
+	FailOpen func() error
+	// Exit ends the process; used by DeathActionExit and when another action fails.
+	Exit func(code int)
+	// RestartDelay is the pause between restart attempts; 0 means one second.
+	RestartDelay time.Duration
+}
+
+// Watch blocks until ctx is done or the watchdog has given up on the proxy.
+func (w *Watchdog) Watch(ctx context.Context, p *Proxy) {
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case err = <-p.Exited():
+		}
+		log.Printf("dns proxy stopped unexpectedly: %v; action %s", err, w.Action)
+		switch w.Action {
+		case DeathActionRestart:
+			if !w.restart(ctx, p) {
+				if ctx.Err() == nil {
+					log.Printf("dns proxy could not be restarted after %d attempts; exiting", restartAttempts)
+					w.Exit(1)
+				}
+				return
+			}
+			log.Printf("dns proxy restarted")
+		case DeathActionFailOpen:
+			if err := w.FailOpen(); err != nil {
+				log.Printf("failed to remove dns redirect: %v; exiting", err)
+				w.Exit(1)
+				return
+			}
+			log.Printf("dns redirect removed; queries now bypass the proxy and the egress policy is not enforced")
+			return
+		default:
+			w.Exit(1)
+			return
+		}
+	}
+}
+
+// restart tries to start the proxy again a few times.
+func (w *Watchdog) restart(ctx context.Context, p *Proxy) bool {
+	delay := w.RestartDelay
+	if delay == 0 {
+		delay = defaultRestartDelay
+	}
+	for attempt := 1; attempt <= restartAttempts; attempt++ {
+		err := p.Restart(ctx)
+		if err == nil {
+			return true
+		}
+		log.Printf("dns proxy restart attempt %d failed: %v", attempt, err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsproxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// startWatchedProxy starts a deny-all proxy on a free loopback port and runs
+// w over it; exits receives every Exit code and failOpens every FailOpen call.
+func startWatchedProxy(t *testing.T, action DeathAction, failOpenErr error) (p *Proxy, exits chan int, failOpens chan struct{}) {
+	t.Helper()
+	p = &Proxy{listenAddr: "127.0.0.1:0", policy: mustPolicy(t, `{"defaultAction":"deny"}`)}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := p.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	exits = make(chan int, 1)
+	failOpens = make(chan struct{}, 1)
+	w := &Watchdog{
+		Action:       action,
+		FailOpen:     func() error { failOpens <- struct{}{}; return failOpenErr },
+		Exit:         func(code int) { exits <- code },
+		RestartDelay: 10 * time.Millisecond,
+	}
+	go w.Watch(ctx, p)
+	return p, exits, failOpens
+}
+
+// killUDP closes the UDP socket under the running server, which makes its
+// serving goroutine return as if it had died.
+func killUDP(p *Proxy) {
+	p.serveMu.Lock()
+	defer p.serveMu.Unlock()
+	_ = p.servers[0].PacketConn.Close()
+}
+
+func udpAddr(p *Proxy) string {
+	p.serveMu.Lock()
+	defer p.serveMu.Unlock()
+	return p.servers[0].PacketConn.LocalAddr().String()
+}
+
+func TestWatchdog_RestartsDeadProxy(t *testing.T) {
+	p, exits, _ := startWatchedProxy(t, DeathActionRestart, nil)
+	dead := udpAddr(p)
+	killUDP(p)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if addr := udpAddr(p); addr != dead {
+			req := new(dns.Msg).SetQuestion("blocked.example.", dns.TypeA)
+			client := &dns.Client{Timeout: time.Second}
+			if resp, _, err := client.Exchange(req, addr); err == nil && resp.Rcode == dns.RcodeNameError {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("proxy was not restarted")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	select {
+	case code := <-exits:
+		t.Fatalf("unexpected exit %d after a successful restart", code)
+	default:
+	}
+}
+
+func TestWatchdog_ExitsWhenRestartFails(t *testing.T) {
+	p, exits, _ := startWatchedProxy(t, DeathActionRestart, nil)
+	p.listenAddr = "192.0.2.1:0" // not a local address, so listening fails
+	killUDP(p)
+	select {
+	case code := <-exits:
+		if code != 1 {
+			t.Fatalf("expected exit code 1, got %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchdog did not exit after failed restarts")
+	}
+}
+
+func TestWatchdog_FailOpen(t *testing.T) {
+	p, exits, failOpens := startWatchedProxy(t, DeathActionFailOpen, nil)
+	killUDP(p)
+	select {
+	case <-failOpens:
+	case <-time.After(5 * time.Second):
+		t.Fatal("redirect was not removed")
+	}
+	select {
+	case code := <-exits:
+		t.Fatalf("unexpected exit %d after failing open", code)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWatchdog_FailOpenErrorExits(t *testing.T) {
+	p, exits, _ := startWatchedProxy(t, DeathActionFailOpen, errors.New("iptables gone"))
+	killUDP(p)
+	select {
+	case <-exits:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchdog did not exit when the redirect could not be removed")
+	}
+}
+
+func TestWatchdog_Exit(t *testing.T) {
+	p, exits, _ := startWatchedProxy(t, DeathActionExit, nil)
+	killUDP(p)
+	select {
+	case code := <-exits:
+		if code != 1 {
+			t.Fatalf("expected exit code 1, got %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchdog did not exit")
+	}
+}
+
+func TestProxy_ShutdownIsNotADeath(t *testing.T) {
+	p := &Proxy{listenAddr: "127.0.0.1:0", policy: mustPolicy(t, `{"defaultAction":"deny"}`)}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := p.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	exited := p.Exited()
+	if err := p.Restart(ctx); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	cancel()
+	select {
+	case err := <-exited:
+		t.Fatalf("a restart reported a death: %v", err)
+	case err := <-p.Exited():
+		t.Fatalf("a shutdown reported a death: %v", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestParseDeathAction(t *testing.T) {
+	for raw, want := range map[string]DeathAction{"": DeathActionRestart, "restart": DeathActionRestart, " Fail-Open ": DeathActionFailOpen, "exit": DeathActionExit} {
+		got, err := ParseDeathAction(raw)
+		if err != nil || got != want {
+			t.Fatalf("ParseDeathAction(%q) = %q, %v; want %q", raw, got, err, want)
+		}
+	}
+	if _, err := ParseDeathAction("ignore"); err == nil {
+		t.Fatal("expected an unknown action to be rejected")
+	}
+}
+
+func TestProxy_SurvivesPanickingQuery(t *testing.T) {
+	p, _, _ := startWatchedProxy(t, DeathActionRestart, nil)
+	p.SetBlockHandler(func(ev BlockEvent) {
+		if ev.Name == "boom.example.com." {
+			panic("block handler failed")
+		}
+	})
+	addr := p.servers[0].PacketConn.LocalAddr().String()
+	client := &dns.Client{Timeout: 2 * time.Second}
+
+	req := new(dns.Msg)
+	req.SetQuestion("boom.example.com.", dns.TypeA)
+	resp, _, err := client.Exchange(req, addr)
+	if err != nil || resp.Rcode != dns.RcodeServerFailure {
+		t.Fatalf("expected SERVFAIL for the panicking query, got %v %v", resp, err)
+	}
+	req.SetQuestion("denied.example.com.", dns.TypeA)
+	resp, _, err = client.Exchange(req, addr)
+	if err != nil || resp.Rcode != dns.RcodeNameError {
+		t.Fatalf("the proxy stopped answering after a panic: %v %v", resp, err)
+	}
+	select {
+	case err := <-p.Exited():
+		t.Fatalf("a panicking query was reported as a death: %v", err)
+	default:
+	}
+}
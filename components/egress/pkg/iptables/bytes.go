@@ -0,0 +1,209 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// ByteMetricsOther is the domain label of traffic to domains beyond the
+	// tracked ones, and to addresses no longer attributed to a domain.
+	ByteMetricsOther = "_other"
+
+	egressBytesMetric = "opensandbox_egress_bytes_total"
+
+	// maxMeteredDomains bounds the domains a ByteMeter keeps totals for,
+	// whatever topN is; bytes of domains beyond it go to ByteMetricsOther.
+	maxMeteredDomains = 4096
+)
+
+// labelEscaper escapes a Prometheus label value.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// ByteCounter reads the byte counters of EnforceChain's address rules.
+type ByteCounter interface {
+	AddrBytes() (map[netip.Addr]uint64, error)
+}
+
+// saveCounter reads the counters from iptables-save and ip6tables-save.
+type saveCounter struct{}
+
+// NewByteCounter returns the ByteCounter of the installed rules.
+func NewByteCounter() ByteCounter {
+	return saveCounter{}
+}
+
+func (saveCounter) AddrBytes() (map[netip.Addr]uint64, error) {
+	counts := make(map[netip.Addr]uint64)
+	for _, bin := range []string{"iptables-save", "ip6tables-save"} {
+		args := []string{"-c", "-t", "filter"}
+		output, err := runCommand(bin, args...)
+		if err != nil {
+			return nil, newCommandError(bin, args, output, err)
+		}
+		parseAddrBytes(string(output), counts)
+	}
+	return counts, nil
+}
+
+// parseAddrBytes adds the byte counts of the address rules in iptables-save
+// -c output, lines like "[3:180] -A OPENSANDBOX_EGRESS -d 1.2.3.4/32 -j RETURN",
+// to counts.
+func parseAddrBytes(output string, counts map[netip.Addr]uint64) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		counters, rule, ok := strings.Cut(scanner.Text(), "] ")
+		if !ok || !strings.HasPrefix(counters, "[") {
+			continue
+		}
+		fields := strings.Fields(rule)
+		if len(fields) != 6 || fields[0] != "-A" || fields[1] != EnforceChain || fields[2] != "-d" || fields[4] != "-j" || fields[5] != "RETURN" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(fields[3])
+		if err != nil || !prefix.IsSingleIP() {
+			continue
+		}
+		_, raw, _ := strings.Cut(counters[1:], ":")
+		bytes, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			continue
+		}
+		counts[prefix.Addr()] += bytes
+	}
+}
+
+// ParseByteMetrics parses the number of domains SetTopDomains tracks; zero
+// turns byte accounting off.
+func ParseByteMetrics(raw string) (int, error) {
+	n, err := strconv.ParseUint(strings.TrimSpace(raw), 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("byte metrics %q is not a number of domains: %w", raw, err)
+	}
+	return int(n), nil
+}
+
+// ByteMeter attributes the bytes sent through EnforceChain's address rules to
+// the domains whose answers allowed the addresses. It keeps a total for every
+// domain, up to maxMeteredDomains, but reports only the topN domains with the
+// most bytes; the rest are summed as ByteMetricsOther. A domain that overtakes
+// one of them takes over its place, so a series can appear or go away between
+// scrapes and ByteMetricsOther can go down. It is safe for concurrent use.
+type ByteMeter struct {
+	source  ByteCounter
+	domains func() map[netip.Addr]string
+	topN    int
+
+	mu     sync.Mutex
+	last   map[netip.Addr]uint64 // counter of each address rule at the last scrape
+	totals map[string]uint64     // domain -> bytes, ByteMetricsOther included
+}
+
+// NewByteMeter returns a ByteMeter reading source and attributing addresses
+// with domains, e.g. Enforcer.Domains.
+func NewByteMeter(source ByteCounter, domains func() map[netip.Addr]string, topN int) *ByteMeter {
+	return &ByteMeter{
+		source:  source,
+		domains: domains,
+		topN:    topN,
+		last:    make(map[netip.Addr]uint64),
+		totals:  map[string]uint64{ByteMetricsOther: 0},
+	}
+}
+
+// Scrape reads the counters and adds what each address sent since the last
+// scrape to its domain. A rule deleted and reinstalled in between starts
+// from zero again, so what was sent to the address after the last scrape and
+// before the deletion is lost.
+func (m *ByteMeter) Scrape() error {
+	counts, err := m.source.AddrBytes()
+	if err != nil {
+		return err
+	}
+	domains := m.domains()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for addr, bytes := range counts {
+		delta := bytes
+		if prev, ok := m.last[addr]; ok && bytes >= prev {
+			delta = bytes - prev
+		}
+		if delta == 0 {
+			continue
+		}
+		domain := ByteMetricsOther
+		if name, ok := domains[addr]; ok {
+			domain = strings.TrimSuffix(strings.ToLower(name), ".")
+		}
+		if _, tracked := m.totals[domain]; !tracked && len(m.totals)-1 >= maxMeteredDomains {
+			domain = ByteMetricsOther
+		}
+		m.totals[domain] += delta
+	}
+	m.last = counts
+	return nil
+}
+
+// Totals returns the bytes of the topN domains that sent the most, and of
+// all the others as ByteMetricsOther.
+func (m *ByteMeter) Totals() map[string]uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.totals))
+	for domain := range m.totals {
+		if domain != ByteMetricsOther {
+			names = append(names, domain)
+		}
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Or(cmp.Compare(m.totals[b], m.totals[a]), strings.Compare(a, b))
+	})
+	totals := map[string]uint64{ByteMetricsOther: m.totals[ByteMetricsOther]}
+	for i, domain := range names {
+		if i < m.topN {
+			totals[domain] = m.totals[domain]
+		} else {
+			totals[ByteMetricsOther] += m.totals[domain]
+		}
+	}
+	return totals
+}
+
+// WriteMetrics writes the totals in the Prometheus text format.
+func (m *ByteMeter) WriteMetrics(w io.Writer) error {
+	totals := m.Totals()
+	domains := make([]string, 0, len(totals))
+	for domain := range totals {
+		domains = append(domains, domain)
+	}
+	slices.Sort(domains)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP %s Bytes sent to allowed domains in enforce mode.\n", egressBytesMetric)
+	fmt.Fprintf(&b, "# TYPE %s counter\n", egressBytesMetric)
+	for _, domain := range domains {
+		fmt.Fprintf(&b, "%s{domain=\"%s\"} %d\n", egressBytesMetric, labelEscaper.Replace(domain), totals[domain])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,183 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"maps"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubCounter hands out preset rule counters.
+type stubCounter struct {
+	counts map[netip.Addr]uint64
+}
+
+func (s *stubCounter) AddrBytes() (map[netip.Addr]uint64, error) {
+	return maps.Clone(s.counts), nil
+}
+
+func TestByteMeter_AttributesBytesToDomains(t *testing.T) {
+	a, b, c := netip.MustParseAddr("1.1.1.1"), netip.MustParseAddr("2.2.2.2"), netip.MustParseAddr("2001:db8::1")
+	e := NewEnforcer()
+	for name, addrs := range map[string][]netip.Addr{"API.example.com.": {a}, "cdn.example.net.": {b, c}} {
+		if err := e.Allow(name, addrs, time.Minute); err != nil {
+			t.Fatalf("Allow(%s): %v", name, err)
+		}
+	}
+	source := &stubCounter{counts: map[netip.Addr]uint64{a: 100, b: 40, c: 2}}
+	meter := NewByteMeter(source, e.Domains, 10)
+	if err := meter.Scrape(); err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	source.counts = map[netip.Addr]uint64{a: 150, b: 40, c: 10}
+	if err := meter.Scrape(); err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	want := map[string]uint64{"api.example.com": 150, "cdn.example.net": 50, ByteMetricsOther: 0}
+	if got := meter.Totals(); !maps.Equal(got, want) {
+		t.Fatalf("Totals() = %v, want %v", got, want)
+	}
+
+	// the rule of an expired and reallowed address starts from zero again
+	source.counts = map[netip.Addr]uint64{a: 7, b: 40, c: 10}
+	if err := meter.Scrape(); err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	if got := meter.Totals()["api.example.com"]; got != 157 {
+		t.Fatalf("after a counter reset api.example.com = %d, want 157", got)
+	}
+
+	// an address handed out for another name goes to the latest one
+	if err := e.Allow("www.example.org.", []netip.Addr{b}, time.Minute); err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	source.counts[b] = 45
+	if err := meter.Scrape(); err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	if got := meter.Totals(); got["www.example.org"] != 5 || got["cdn.example.net"] != 50 {
+		t.Fatalf("bytes to a shared address went to %v, want the latest name", got)
+	}
+
+	// a reloaded policy denying that name hands the address back to one still allowed
+	if _, err := e.SwapPolicy(true, func(name string) bool { return name != "www.example.org." }); err != nil {
+		t.Fatalf("SwapPolicy: %v", err)
+	}
+	if got := e.Domains()[b]; got != "cdn.example.net." {
+		t.Fatalf("after the reload %s is attributed to %q, want cdn.example.net.", b, got)
+	}
+}
+
+func TestByteMeter_BoundsDomains(t *testing.T) {
+	domains := map[netip.Addr]string{}
+	counts := map[netip.Addr]uint64{}
+	for i, name := range []string{"small.test.", "big.test.", "medium.test.", "late.test."} {
+		addr := netip.AddrFrom4([4]byte{10, 0, 0, byte(i + 1)})
+		domains[addr] = name
+		counts[addr] = map[string]uint64{"small.test.": 1, "big.test.": 300, "medium.test.": 20}[name]
+	}
+	source := &stubCounter{counts: counts}
+	meter := NewByteMeter(source, func() map[netip.Addr]string { return domains }, 2)
+	if err := meter.Scrape(); err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	want := map[string]uint64{"big.test": 300, "medium.test": 20, ByteMetricsOther: 1}
+	if got := meter.Totals(); !maps.Equal(got, want) {
+		t.Fatalf("Totals() = %v, want the 2 biggest domains and the rest as %s: %v", got, ByteMetricsOther, want)
+	}
+
+	// a domain that starts later takes the place of the smaller one once it
+	// sent more, and unattributed addresses count as the rest
+	source.counts = maps.Clone(counts)
+	source.counts[netip.AddrFrom4([4]byte{10, 0, 0, 4})] = 1000
+	source.counts[netip.MustParseAddr("192.0.2.9")] = 3
+	if err := meter.Scrape(); err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	want = map[string]uint64{"late.test": 1000, "big.test": 300, ByteMetricsOther: 24}
+	if got := meter.Totals(); !maps.Equal(got, want) {
+		t.Fatalf("Totals() = %v, want %v", got, want)
+	}
+
+	// totals of the others are kept, so one overtakes again as it grows
+	source.counts = maps.Clone(source.counts)
+	source.counts[netip.AddrFrom4([4]byte{10, 0, 0, 1})] = 2001
+	if err := meter.Scrape(); err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	want = map[string]uint64{"small.test": 2001, "late.test": 1000, ByteMetricsOther: 323}
+	if got := meter.Totals(); !maps.Equal(got, want) {
+		t.Fatalf("Totals() = %v, want %v", got, want)
+	}
+}
+
+func TestByteMeter_WriteMetrics(t *testing.T) {
+	addr := netip.MustParseAddr("1.1.1.1")
+	source := &stubCounter{counts: map[netip.Addr]uint64{addr: 42}}
+	meter := NewByteMeter(source, func() map[netip.Addr]string { return map[netip.Addr]string{addr: `we"ird.test.`} }, 5)
+	if err := meter.Scrape(); err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	var out strings.Builder
+	if err := meter.WriteMetrics(&out); err != nil {
+		t.Fatalf("WriteMetrics: %v", err)
+	}
+	want := `# HELP opensandbox_egress_bytes_total Bytes sent to allowed domains in enforce mode.
+# TYPE opensandbox_egress_bytes_total counter
+opensandbox_egress_bytes_total{domain="_other"} 0
+opensandbox_egress_bytes_total{domain="we\"ird.test"} 42
+`
+	if out.String() != want {
+		t.Fatalf("WriteMetrics() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestParseAddrBytes(t *testing.T) {
+	output := `# Generated by iptables-save
+*filter
+:OUTPUT ACCEPT [10:600]
+:OPENSANDBOX_EGRESS - [0:0]
+[5:300] -A OUTPUT -j OPENSANDBOX_EGRESS
+[2:120] -A OPENSANDBOX_EGRESS -d 93.184.216.34/32 -j RETURN
+[9:4096] -A OPENSANDBOX_EGRESS -d 2606:2800:220:1::/128 -j RETURN
+[1:60] -A OPENSANDBOX_EGRESS -d 10.0.0.0/8 -j RETURN
+[3:180] -A OPENSANDBOX_EGRESS -o lo -j RETURN
+[4:240] -A OPENSANDBOX_EGRESS -j DROP
+COMMIT
+`
+	counts := map[netip.Addr]uint64{}
+	parseAddrBytes(output, counts)
+	want := map[netip.Addr]uint64{
+		netip.MustParseAddr("93.184.216.34"):     120,
+		netip.MustParseAddr("2606:2800:220:1::"): 4096,
+	}
+	if !maps.Equal(counts, want) {
+		t.Fatalf("parseAddrBytes() = %v, want %v", counts, want)
+	}
+}
+
+func TestParseByteMetrics(t *testing.T) {
+	if n, err := ParseByteMetrics(" 50 "); err != nil || n != 50 {
+		t.Fatalf("ParseByteMetrics(50) = %d, %v", n, err)
+	}
+	for _, raw := range []string{"", "-1", "many"} {
+		if _, err := ParseByteMetrics(raw); err == nil {
+			t.Errorf("ParseByteMetrics(%q) should fail", raw)
+		}
+	}
+}
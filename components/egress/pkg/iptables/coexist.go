@@ -0,0 +1,158 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Coexistence decides where the DNS redirect goes when other tools, such as
+// a service mesh, already redirect traffic in the nat OUTPUT chain.
+type Coexistence string
+
+const (
+	// CoexistBefore inserts the redirect ahead of the other rules. The proxy's
+	// own upstream queries fall through to them instead of leaving the chain.
+	CoexistBefore Coexistence = "before"
+	// CoexistAfter appends the redirect; DNS the other rules capture never
+	// reaches the proxy.
+	CoexistAfter Coexistence = "after"
+	// CoexistSkip installs no redirect at all when other rules are found.
+	CoexistSkip Coexistence = "skip"
+)
+
+// ParseCoexistence parses a Coexistence; empty means before.
+func ParseCoexistence(raw string) (Coexistence, error) {
+	switch mode := Coexistence(strings.ToLower(strings.TrimSpace(raw))); mode {
+	case "":
+		return CoexistBefore, nil
+	case CoexistBefore, CoexistAfter, CoexistSkip:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("iptables coexistence %q is not one of before, after, skip", raw)
+	}
+}
+
+// terminal targets cannot hand a packet to another tool's rules.
+var terminalTargets = map[string]bool{
+	"ACCEPT": true, "DROP": true, "RETURN": true, "REJECT": true,
+	"LOG": true, "NFLOG": true, "MARK": true, "CONNMARK": true,
+}
+
+// ForeignRedirects lists the nat OUTPUT rules, in iptables -S form, that
+// other tools installed to redirect traffic: REDIRECT or DNAT rules not
+// pointing at port, and jumps to their own chains (ISTIO_OUTPUT,
+// PROXY_INIT_OUTPUT, DOCKER_OUTPUT, ...).
+func ForeignRedirects(port int) ([]string, error) {
+	var foreign []string
+	for _, bin := range []string{"iptables", "ip6tables"} {
+		args := []string{"-t", "nat", "-S", "OUTPUT"}
+		output, err := runCommand(bin, args...)
+		if err != nil {
+			return nil, newCommandError(bin, args, output, err)
+		}
+		for _, line := range strings.Split(string(output), "\n") {
+			line = strings.TrimSpace(line)
+			if isForeignRedirect(line, port) {
+				foreign = append(foreign, bin+" "+line)
+			}
+		}
+	}
+	return foreign, nil
+}
+
+func isForeignRedirect(rule string, port int) bool {
+	fields := strings.Fields(rule)
+	if len(fields) < 2 || fields[0] != "-A" || fields[1] != "OUTPUT" {
+		return false
+	}
+	var target string
+	for i := 2; i < len(fields)-1; i++ {
+		if fields[i] == "-j" || fields[i] == "-g" {
+			target = fields[i+1]
+		}
+	}
+	switch {
+	case target == "REDIRECT" || target == "DNAT":
+		for i := 2; i < len(fields)-1; i++ {
+			if fields[i] == "--to-ports" && fields[i+1] == strconv.Itoa(port) {
+				return false
+			}
+		}
+		return true
+	case target == "" || terminalTargets[target]:
+		return false
+	default:
+		return true
+	}
+}
+
+// SetupRedirectCoexisting installs the DNS redirect to port, placed according
+// to mode relative to the redirects of other tools. It returns those rules and
+// whether the redirect was installed; under CoexistSkip it is not when any
+// are found.
+func SetupRedirectCoexisting(port int, mode Coexistence) ([]string, bool, error) {
+	foreign, err := ForeignRedirects(port)
+	if err != nil {
+		return nil, false, err
+	}
+	switch {
+	case mode == CoexistSkip && len(foreign) > 0:
+		return foreign, false, nil
+	case mode == CoexistBefore:
+		return foreign, true, runRules(insertedRedirectRules(port))
+	default:
+		return foreign, true, SetupRedirect(port)
+	}
+}
+
+// RemoveRedirectCoexisting removes the rules SetupRedirectCoexisting installed
+// under mode.
+func RemoveRedirectCoexisting(port int, mode Coexistence) error {
+	if mode != CoexistBefore {
+		return RemoveRedirect(port)
+	}
+	return removeRules(beforeRedirectRules("-D", port))
+}
+
+// beforeRedirectRules redirect DNS except the proxy's own marked queries.
+// Unlike the RETURN rules of redirectRules, the negated mark lets those
+// queries carry on to the rules that follow.
+func beforeRedirectRules(op string, port int) [][]string {
+	targetPort := strconv.Itoa(port)
+	var rules [][]string
+	for _, bin := range []string{"iptables", "ip6tables"} {
+		for _, proto := range []string{"udp", "tcp"} {
+			rules = append(rules, []string{bin, "-t", "nat", op, "OUTPUT", "-p", proto, "--dport", "53",
+				"-m", "mark", "!", "--mark", bypassMark, "-j", "REDIRECT", "--to-port", targetPort})
+		}
+	}
+	return rules
+}
+
+// insertedRedirectRules places beforeRedirectRules at the top of OUTPUT, in
+// order.
+func insertedRedirectRules(port int) [][]string {
+	rules := beforeRedirectRules("-I", port)
+	positions := map[string]int{}
+	for i, args := range rules {
+		positions[args[0]]++
+		// "-I OUTPUT" takes the rule number right after the chain name
+		rules[i] = append(args[:5:5], append([]string{strconv.Itoa(positions[args[0]])}, args[5:]...)...)
+	}
+	return rules
+}
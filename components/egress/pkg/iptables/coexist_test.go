@@ -0,0 +1,149 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"strings"
+	"testing"
+)
+
+// istioNatOutput is what istio-iptables leaves in nat OUTPUT with DNS capture on.
+const istioNatOutput = `-P OUTPUT ACCEPT
+-A OUTPUT -p tcp -j ISTIO_OUTPUT
+-A OUTPUT -p udp -m udp --dport 53 -m owner --uid-owner 1337 -j RETURN
+-A OUTPUT -p udp -m udp --dport 53 -j REDIRECT --to-ports 15053
+`
+
+// dryRun records every command instead of running it, answering "-S" listings
+// with listing.
+func dryRun(t *testing.T, listing string) *[]string {
+	t.Helper()
+	var calls []string
+	stubRunCommand(t, func(name string, args ...string) ([]byte, error) {
+		if len(args) > 2 && args[2] == "-S" {
+			return []byte(listing), nil
+		}
+		calls = append(calls, name+" "+strings.Join(args, " "))
+		return nil, nil
+	})
+	return &calls
+}
+
+func TestParseCoexistence(t *testing.T) {
+	for raw, want := range map[string]Coexistence{"": CoexistBefore, "before": CoexistBefore, " After ": CoexistAfter, "skip": CoexistSkip} {
+		got, err := ParseCoexistence(raw)
+		if err != nil || got != want {
+			t.Fatalf("ParseCoexistence(%q) = %q, %v; want %q", raw, got, err, want)
+		}
+	}
+	if _, err := ParseCoexistence("first"); err == nil {
+		t.Fatalf("expected an error for an unknown mode")
+	}
+}
+
+func TestForeignRedirects(t *testing.T) {
+	dryRun(t, istioNatOutput+"-A OUTPUT -p udp --dport 53 -j REDIRECT --to-ports 15353\n")
+
+	foreign, err := ForeignRedirects(15353)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"iptables -A OUTPUT -p tcp -j ISTIO_OUTPUT",
+		"iptables -A OUTPUT -p udp -m udp --dport 53 -j REDIRECT --to-ports 15053",
+		"ip6tables -A OUTPUT -p tcp -j ISTIO_OUTPUT",
+		"ip6tables -A OUTPUT -p udp -m udp --dport 53 -j REDIRECT --to-ports 15053",
+	}
+	if strings.Join(foreign, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("foreign redirects:\n%s\nwant:\n%s", strings.Join(foreign, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSetupRedirectCoexisting_Before(t *testing.T) {
+	calls := dryRun(t, istioNatOutput)
+
+	foreign, installed, err := SetupRedirectCoexisting(15353, CoexistBefore)
+	if err != nil || !installed {
+		t.Fatalf("installed = %t, err = %v", installed, err)
+	}
+	if len(foreign) != 4 {
+		t.Fatalf("expected the mesh rules to be reported, got %v", foreign)
+	}
+	want := []string{
+		"iptables -t nat -I OUTPUT 1 -p udp --dport 53 -m mark ! --mark 0x1 -j REDIRECT --to-port 15353",
+		"iptables -t nat -I OUTPUT 2 -p tcp --dport 53 -m mark ! --mark 0x1 -j REDIRECT --to-port 15353",
+		"ip6tables -t nat -I OUTPUT 1 -p udp --dport 53 -m mark ! --mark 0x1 -j REDIRECT --to-port 15353",
+		"ip6tables -t nat -I OUTPUT 2 -p tcp --dport 53 -m mark ! --mark 0x1 -j REDIRECT --to-port 15353",
+	}
+	if strings.Join(*calls, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("inserted:\n%s\nwant:\n%s", strings.Join(*calls, "\n"), strings.Join(want, "\n"))
+	}
+	for _, call := range *calls {
+		if strings.Contains(call, "RETURN") {
+			t.Fatalf("a RETURN would skip the mesh rules for the proxy's upstream queries: %s", call)
+		}
+	}
+
+	*calls = nil
+	if err := RemoveRedirectCoexisting(15353, CoexistBefore); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantRemoved := []string{
+		"iptables -t nat -D OUTPUT -p udp --dport 53 -m mark ! --mark 0x1 -j REDIRECT --to-port 15353",
+		"iptables -t nat -D OUTPUT -p tcp --dport 53 -m mark ! --mark 0x1 -j REDIRECT --to-port 15353",
+		"ip6tables -t nat -D OUTPUT -p udp --dport 53 -m mark ! --mark 0x1 -j REDIRECT --to-port 15353",
+		"ip6tables -t nat -D OUTPUT -p tcp --dport 53 -m mark ! --mark 0x1 -j REDIRECT --to-port 15353",
+	}
+	if strings.Join(*calls, "\n") != strings.Join(wantRemoved, "\n") {
+		t.Fatalf("removed:\n%s\nwant:\n%s", strings.Join(*calls, "\n"), strings.Join(wantRemoved, "\n"))
+	}
+}
+
+func TestSetupRedirectCoexisting_After(t *testing.T) {
+	calls := dryRun(t, istioNatOutput)
+
+	_, installed, err := SetupRedirectCoexisting(15353, CoexistAfter)
+	if err != nil || !installed {
+		t.Fatalf("installed = %t, err = %v", installed, err)
+	}
+	if len(*calls) != 8 {
+		t.Fatalf("expected the 8 appended rules, got %v", *calls)
+	}
+	for _, call := range *calls {
+		if !strings.Contains(call, " -A OUTPUT ") {
+			t.Fatalf("expected an append, got %q", call)
+		}
+	}
+}
+
+func TestSetupRedirectCoexisting_Skip(t *testing.T) {
+	calls := dryRun(t, istioNatOutput)
+
+	foreign, installed, err := SetupRedirectCoexisting(15353, CoexistSkip)
+	if err != nil || installed {
+		t.Fatalf("installed = %t, err = %v", installed, err)
+	}
+	if len(foreign) == 0 || len(*calls) != 0 {
+		t.Fatalf("expected nothing installed next to %v, ran %v", foreign, *calls)
+	}
+
+	calls = dryRun(t, "-P OUTPUT ACCEPT\n")
+	if _, installed, err := SetupRedirectCoexisting(15353, CoexistSkip); err != nil || !installed {
+		t.Fatalf("without other redirects: installed = %t, err = %v", installed, err)
+	}
+	if len(*calls) != 8 {
+		t.Fatalf("expected the 8 default rules, got %v", *calls)
+	}
+}
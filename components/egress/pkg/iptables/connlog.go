@@ -0,0 +1,42 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import "strconv"
+
+// ConnLogPrefix tags NFLOG records of connections that were let through, so
+// a reader can tell them from records of the drop rule.
+const ConnLogPrefix = "egress-allow"
+
+// BlockedLogPrefix tags NFLOG records of connections enforce mode drops.
+const BlockedLogPrefix = "egress-deny"
+
+// SetupConnLog logs the first packet of every new outbound connection to
+// NFLOG group. Loopback traffic and the proxy's own upstream queries (marked
+// bypassMark) are skipped. Failures are reported as *CommandError.
+func SetupConnLog(group int) error {
+	rules := [][]string{}
+	for _, bin := range []string{"iptables", "ip6tables"} {
+		rules = append(rules, []string{bin, "-t", "filter", "-A", "OUTPUT", "!", "-o", "lo",
+			"-m", "conntrack", "--ctstate", "NEW", "-m", "mark", "!", "--mark", bypassMark,
+			"-j", "NFLOG", "--nflog-group", strconv.Itoa(group), "--nflog-prefix", ConnLogPrefix})
+	}
+	for _, args := range rules {
+		if output, err := runCommand(args[0], args[1:]...); err != nil {
+			return newCommandError(args[0], args[1:], output, err)
+		}
+	}
+	return nil
+}
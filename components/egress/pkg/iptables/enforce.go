@@ -0,0 +1,390 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"fmt"
+	"maps"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// EnforceChain is the filter chain of enforce mode. It is jumped to first
+	// from OUTPUT; traffic it lets through returns to OUTPUT, so rules such as
+	// the connection log still see it.
+	EnforceChain = "OPENSANDBOX_EGRESS"
+	// EnforceMinRetention keeps an address allowed longer than a short TTL:
+	// clients often connect, or reconnect, well after the answer expired.
+	EnforceMinRetention = 10 * time.Minute
+)
+
+// enforceExceptions are the EnforceChain rules that keep DNS itself working
+// under a default DROP, in order:
+//   - loopback, which queries redirected to the proxy and the policy server use;
+//   - the proxy's own upstream queries, marked bypassMark by its dialer. The
+//     sandbox cannot set marks without CAP_NET_ADMIN;
+//   - replies and related traffic of connections that were let through.
+func enforceExceptions(bin string) [][]string {
+	return [][]string{
+		{bin, "-t", "filter", "-A", EnforceChain, "-o", "lo", "-j", "RETURN"},
+		{bin, "-t", "filter", "-A", EnforceChain, "-m", "mark", "--mark", bypassMark, "-j", "RETURN"},
+		{bin, "-t", "filter", "-A", EnforceChain, "-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "RETURN"},
+	}
+}
+
+// enforceSetupRules creates EnforceChain with its exceptions and default
+// verdict last, then hooks it in at the top of OUTPUT. A non-negative
+// logGroup logs what the verdict drops to that NFLOG group.
+func enforceSetupRules(deny bool, logGroup int) [][]string {
+	var rules [][]string
+	for _, bin := range []string{"iptables", "ip6tables"} {
+		rules = append(rules, []string{bin, "-t", "filter", "-N", EnforceChain})
+		rules = append(rules, enforceExceptions(bin)...)
+		rules = append(rules, verdictRules(bin, "-A", deny, logGroup)...)
+		rules = append(rules, []string{bin, "-t", "filter", "-I", "OUTPUT", "1", "-j", EnforceChain})
+	}
+	return rules
+}
+
+func enforceRemoveRules() [][]string {
+	var rules [][]string
+	for _, bin := range []string{"iptables", "ip6tables"} {
+		rules = append(rules,
+			[]string{bin, "-t", "filter", "-D", "OUTPUT", "-j", EnforceChain},
+			[]string{bin, "-t", "filter", "-F", EnforceChain},
+			[]string{bin, "-t", "filter", "-X", EnforceChain},
+		)
+	}
+	return rules
+}
+
+// verdictRules are the last rules of EnforceChain: DROP when the policy
+// denies by default, RETURN when it allows. With a non-negative logGroup the
+// DROP is preceded by an NFLOG rule, so the connection log reports the first
+// packet of each dropped connection as blocked.
+func verdictRules(bin, op string, deny bool, logGroup int) [][]string {
+	if !deny {
+		return [][]string{{bin, "-t", "filter", op, EnforceChain, "-j", "RETURN"}}
+	}
+	var rules [][]string
+	if logGroup >= 0 {
+		rules = append(rules, []string{bin, "-t", "filter", op, EnforceChain, "-m", "conntrack", "--ctstate", "NEW",
+			"-j", "NFLOG", "--nflog-group", strconv.Itoa(logGroup), "--nflog-prefix", BlockedLogPrefix})
+	}
+	return append(rules, []string{bin, "-t", "filter", op, EnforceChain, "-j", "DROP"})
+}
+
+// addrRule lets traffic to addr through. Address rules are inserted at the
+// top of EnforceChain, so they always come before the verdict.
+func addrRule(op string, addr netip.Addr) []string {
+	bin := "iptables"
+	if addr.Is6() {
+		bin = "ip6tables"
+	}
+	rule := []string{bin, "-t", "filter", op, EnforceChain}
+	if op == "-I" {
+		rule = append(rule, "1")
+	}
+	return append(rule, "-d", addr.String(), "-j", "RETURN")
+}
+
+// Enforcer drops outbound traffic to addresses that no allowed DNS answer
+// handed out, when the policy denies by default. Under a policy that allows
+// by default, only blocked names are refused, by the DNS proxy. It is safe
+// for concurrent use.
+type Enforcer struct {
+	mu       sync.Mutex
+	active   bool
+	deny     bool
+	logGroup int                                // NFLOG group of dropped connections; negative when not logged
+	ttl      time.Duration                      // overrides answer TTLs when positive
+	allowed  map[netip.Addr]time.Time           // address -> when it stops being allowed
+	names    map[netip.Addr]map[string]struct{} // address -> names whose answers handed it out
+	latest   map[netip.Addr]string              // address -> name whose answer handed it out last
+	now      func() time.Time
+}
+
+// NewEnforcer returns an Enforcer whose rules are not installed yet.
+func NewEnforcer() *Enforcer {
+	return &Enforcer{
+		logGroup: -1,
+		allowed:  make(map[netip.Addr]time.Time),
+		names:    make(map[netip.Addr]map[string]struct{}),
+		latest:   make(map[netip.Addr]string),
+		now:      time.Now,
+	}
+}
+
+// LogBlocked logs the connections the default verdict drops to NFLOG group
+// with BlockedLogPrefix. It applies to rules installed from then on, so call
+// it before Setup.
+func (e *Enforcer) LogBlocked(group int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.logGroup = group
+}
+
+// Setup installs EnforceChain with deny as the default verdict, along with
+// the addresses allowed so far. Failures are reported as *CommandError.
+func (e *Enforcer) Setup(deny bool) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rules := enforceSetupRules(deny, e.logGroup)
+	for addr := range e.allowed {
+		rules = append(rules, addrRule("-I", addr))
+	}
+	if err := runRules(rules); err != nil {
+		return err
+	}
+	e.active, e.deny = true, deny
+	return nil
+}
+
+// Remove deletes EnforceChain, letting all traffic through again. Every rule
+// is attempted; the first failure is reported as *CommandError.
+func (e *Enforcer) Remove() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.active = false
+	return removeRules(enforceRemoveRules())
+}
+
+// SetDefaultDeny switches the verdict for addresses that were not handed
+// out. The new verdict is appended behind the old one before that is
+// deleted, so no packet sees the chain without one.
+func (e *Enforcer) SetDefaultDeny(deny bool) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.active || deny == e.deny {
+		e.deny = deny
+		return nil
+	}
+	for _, bin := range []string{"iptables", "ip6tables"} {
+		if err := runRules(e.swapVerdictRules(bin, deny)); err != nil {
+			return err
+		}
+	}
+	e.deny = deny
+	return nil
+}
+
+// swapVerdictRules appends the verdict for deny behind the current one and
+// then deletes the current one.
+func (e *Enforcer) swapVerdictRules(bin string, deny bool) [][]string {
+	return append(verdictRules(bin, "-A", deny, e.logGroup), verdictRules(bin, "-D", e.deny, e.logGroup)...)
+}
+
+// ParseEnforceTTL parses a number of seconds for SetTTL.
+func ParseEnforceTTL(raw string) (time.Duration, error) {
+	seconds, err := strconv.ParseUint(strings.TrimSpace(raw), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("enforce ttl %q is not a number of seconds: %w", raw, err)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// SetTTL makes every allowed address stay allowed for ttl, whatever the TTL
+// of the answer that handed it out and however short; zero restores the
+// answer TTL with EnforceMinRetention. What clients cache is not affected.
+// It applies to addresses allowed from then on.
+func (e *Enforcer) SetTTL(ttl time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.ttl = max(ttl, 0)
+}
+
+// Allow lets traffic to addrs, the answer for name, through for ttl, or
+// EnforceMinRetention if that is longer, unless SetTTL overrides it. It runs
+// before the answer reaches the client, so the client's first connection is
+// not dropped. The addresses new to each family are installed in a single
+// iptables-restore transaction, so an answer costs at most one command per
+// family, and none when every address is already allowed.
+func (e *Enforcer) Allow(name string, addrs []netip.Addr, ttl time.Duration) error {
+	now := e.now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	until := now.Add(max(ttl, EnforceMinRetention))
+	if e.ttl > 0 {
+		until = now.Add(e.ttl)
+	}
+	if e.active {
+		added := map[string][][]string{} // bin -> rules of new addresses
+		seen := make(map[netip.Addr]struct{}, len(addrs))
+		for _, addr := range addrs {
+			addr = addr.Unmap()
+			if _, ok := e.allowed[addr]; ok {
+				continue
+			}
+			if _, ok := seen[addr]; ok {
+				continue
+			}
+			seen[addr] = struct{}{}
+			rule := addrRule("-I", addr)
+			added[rule[0]] = append(added[rule[0]], rule)
+		}
+		for _, bin := range []string{"iptables", "ip6tables"} {
+			if err := restoreRules(bin, added[bin]); err != nil {
+				return err
+			}
+		}
+	}
+	for _, addr := range addrs {
+		addr = addr.Unmap()
+		if until.After(e.allowed[addr]) {
+			e.allowed[addr] = until
+		}
+		if e.names[addr] == nil {
+			e.names[addr] = make(map[string]struct{})
+		}
+		e.names[addr][name] = struct{}{}
+		e.latest[addr] = name
+	}
+	return nil
+}
+
+// SwapPolicy brings the chain in line with a reloaded policy: deny becomes
+// the default verdict, and addresses only handed out for names allowed no
+// longer stop being allowed. Addresses of names still allowed are left in
+// place rather than reinstalled, so their connections never see the chain
+// without them; names the new policy allows get theirs from Allow as they
+// are resolved. The changes to each address family are applied in a single
+// iptables-restore transaction. It returns how many addresses were removed.
+func (e *Enforcer) SwapPolicy(deny bool, allowed func(name string) bool) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	revoked := map[string][]netip.Addr{} // bin -> addresses to remove
+	kept := make(map[netip.Addr]map[string]struct{}, len(e.names))
+	for addr, names := range e.names {
+		still := make(map[string]struct{}, len(names))
+		for name := range names {
+			if allowed(name) {
+				still[name] = struct{}{}
+			}
+		}
+		if len(names) > 0 && len(still) == 0 {
+			bin := addrRule("-D", addr)[0]
+			revoked[bin] = append(revoked[bin], addr)
+			continue
+		}
+		kept[addr] = still
+	}
+	removed := 0
+	for _, bin := range []string{"iptables", "ip6tables"} {
+		if e.active {
+			var ops [][]string
+			if deny != e.deny {
+				ops = append(ops, e.swapVerdictRules(bin, deny)...)
+			}
+			for _, addr := range revoked[bin] {
+				ops = append(ops, addrRule("-D", addr))
+			}
+			if err := restoreRules(bin, ops); err != nil {
+				return removed, err
+			}
+		}
+		for _, addr := range revoked[bin] {
+			delete(e.allowed, addr)
+			delete(e.names, addr)
+			delete(e.latest, addr)
+			removed++
+		}
+	}
+	for addr, names := range kept {
+		e.names[addr] = names
+		if _, ok := names[e.latest[addr]]; !ok && len(names) > 0 {
+			e.latest[addr] = slices.Min(slices.Collect(maps.Keys(names)))
+		}
+	}
+	e.deny = deny
+	return removed, nil
+}
+
+// restoreRules applies filter table rules, each given as for runRules, in one
+// iptables-restore transaction of bin: either all of them take effect or none.
+func restoreRules(bin string, rules [][]string) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	var script strings.Builder
+	script.WriteString("*filter\n")
+	for _, rule := range rules {
+		// drop the binary and "-t filter"; the table is the script's
+		script.WriteString(strings.Join(rule[3:], " "))
+		script.WriteByte('\n')
+	}
+	script.WriteString("COMMIT\n")
+	file, err := os.CreateTemp("", "egress-enforce-*.rules")
+	if err != nil {
+		return fmt.Errorf("write %s-restore input: %w", bin, err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(script.String())
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("write %s-restore input: %w", bin, err)
+	}
+	args := []string{"--noflush", file.Name()}
+	if output, err := runCommand(bin+"-restore", args...); err != nil {
+		return newCommandError(bin+"-restore", args, output, err)
+	}
+	return nil
+}
+
+// Expire stops allowing addresses whose time has passed and returns how many
+// were removed. Connections already made to them carry on.
+func (e *Enforcer) Expire() (int, error) {
+	now := e.now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	removed := 0
+	for addr, until := range e.allowed {
+		if now.Before(until) {
+			continue
+		}
+		if e.active {
+			if err := runRules([][]string{addrRule("-D", addr)}); err != nil {
+				return removed, err
+			}
+		}
+		delete(e.allowed, addr)
+		delete(e.names, addr)
+		delete(e.latest, addr)
+		removed++
+	}
+	return removed, nil
+}
+
+// Domains returns, for each allowed address, the name whose answer handed it
+// out last, which traffic to the address is attributed to.
+func (e *Enforcer) Domains() map[netip.Addr]string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return maps.Clone(e.latest)
+}
+
+// Len returns the number of allowed addresses.
+func (e *Enforcer) Len() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.allowed)
+}
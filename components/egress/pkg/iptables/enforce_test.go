@@ -0,0 +1,519 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"fmt"
+	"maps"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// filterTable replays the filter rule commands it is given and evaluates
+// packets against the result, like iptables would.
+type filterTable struct {
+	chains map[string][][]string // bin/chain -> rule specs, in order
+	// commands counts the commands run; observe, when set, sees the table
+	// after each of them.
+	commands int
+	observe  func()
+}
+
+type packet struct {
+	out   string // output interface
+	mark  string
+	state string // conntrack state
+	dst   string
+}
+
+func newFilterTable(t *testing.T) *filterTable {
+	ft := &filterTable{chains: map[string][][]string{}}
+	stubRunCommand(t, func(name string, args ...string) ([]byte, error) {
+		ft.commands++
+		output, err := ft.run(name, args)
+		if err == nil && ft.observe != nil {
+			ft.observe()
+		}
+		return output, err
+	})
+	return ft
+}
+
+func (ft *filterTable) run(name string, args []string) ([]byte, error) {
+	if bin, ok := strings.CutSuffix(name, "-restore"); ok {
+		return ft.restore(bin, args)
+	}
+	if len(args) < 4 || args[0] != "-t" || args[1] != "filter" {
+		return nil, fmt.Errorf("unexpected command %s %v", name, args)
+	}
+	return applyRule(ft.chains, name+"/"+args[3], args[2], args[4:])
+}
+
+// restore applies an iptables-restore --noflush script all at once, or not
+// at all when one of its rules fails.
+func (ft *filterTable) restore(bin string, args []string) ([]byte, error) {
+	if len(args) != 2 || args[0] != "--noflush" {
+		return nil, fmt.Errorf("unexpected restore arguments %v", args)
+	}
+	script, err := os.ReadFile(args[1])
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(script)), "\n")
+	if len(lines) < 2 || lines[0] != "*filter" || lines[len(lines)-1] != "COMMIT" {
+		return nil, fmt.Errorf("unexpected restore script %q", script)
+	}
+	chains := maps.Clone(ft.chains)
+	for _, line := range lines[1 : len(lines)-1] {
+		fields := strings.Fields(line)
+		if output, err := applyRule(chains, bin+"/"+fields[1], fields[0], fields[2:]); err != nil {
+			return output, err
+		}
+	}
+	ft.chains = chains
+	return nil, nil
+}
+
+func applyRule(chains map[string][][]string, key, op string, spec []string) ([]byte, error) {
+	switch op {
+	case "-N":
+		chains[key] = [][]string{}
+	case "-A":
+		chains[key] = append(slices.Clip(chains[key]), spec)
+	case "-I":
+		chains[key] = slices.Insert(slices.Clip(chains[key]), 0, spec[1:])
+	case "-D":
+		i := slices.IndexFunc(chains[key], func(r []string) bool { return slices.Equal(r, spec) })
+		if i < 0 {
+			return []byte("iptables: Bad rule (does a matching rule exist in that chain?)."), fmt.Errorf("exit status 1")
+		}
+		chains[key] = slices.Delete(slices.Clone(chains[key]), i, i+1)
+	case "-F":
+		chains[key] = [][]string{}
+	case "-X":
+		delete(chains, key)
+	}
+	return nil, nil
+}
+
+// verdict walks OUTPUT of bin for p and returns ACCEPT or DROP; OUTPUT's
+// policy is ACCEPT.
+func (ft *filterTable) verdict(bin string, p packet) string {
+	if ft.walk(bin, "OUTPUT", p) == "DROP" {
+		return "DROP"
+	}
+	return "ACCEPT"
+}
+
+func (ft *filterTable) walk(bin, chain string, p packet) string {
+	for _, rule := range ft.chains[bin+"/"+chain] {
+		if !matches(rule, p) {
+			continue
+		}
+		switch target := rule[len(rule)-1]; target {
+		case "RETURN":
+			return ""
+		case "DROP", "ACCEPT":
+			return target
+		default:
+			if v := ft.walk(bin, target, p); v != "" {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+func matches(rule []string, p packet) bool {
+	for i := 0; i < len(rule)-1; i++ {
+		switch rule[i] {
+		case "-o":
+			if p.out != rule[i+1] {
+				return false
+			}
+		case "--mark":
+			if p.mark != rule[i+1] {
+				return false
+			}
+		case "--ctstate":
+			if !strings.Contains(rule[i+1], p.state) {
+				return false
+			}
+		case "-d":
+			if p.dst != rule[i+1] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestEnforcer_DNSKeepsWorkingUnderDefaultDeny(t *testing.T) {
+	ft := newFilterTable(t)
+	e := NewEnforcer()
+	if err := e.Setup(true); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		bin  string
+		p    packet
+		want string
+	}{
+		// the REDIRECT rewrites the client's query to the proxy on loopback
+		{"query redirected to the proxy", "iptables", packet{out: "lo", state: "NEW", dst: "127.0.0.1"}, "ACCEPT"},
+		{"proxy upstream query", "iptables", packet{out: "eth0", mark: bypassMark, state: "NEW", dst: "10.96.0.10"}, "ACCEPT"},
+		{"proxy upstream query over ipv6", "ip6tables", packet{out: "eth0", mark: bypassMark, state: "NEW", dst: "fd00::a"}, "ACCEPT"},
+		{"reply of the policy server", "iptables", packet{out: "eth0", state: "ESTABLISHED", dst: "10.0.0.7"}, "ACCEPT"},
+		{"unmarked query to another resolver", "iptables", packet{out: "eth0", state: "NEW", dst: "1.1.1.1"}, "DROP"},
+		{"connection to an address never resolved", "ip6tables", packet{out: "eth0", state: "NEW", dst: "2606:4700::1111"}, "DROP"},
+	}
+	for _, tc := range cases {
+		if got := ft.verdict(tc.bin, tc.p); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+
+	chain := ft.chains["iptables/"+EnforceChain]
+	if last := chain[len(chain)-1]; !slices.Equal(last, []string{"-j", "DROP"}) {
+		t.Fatalf("expected the DROP last, after the exceptions, got %v", chain)
+	}
+	if first := ft.chains["iptables/OUTPUT"][0]; !slices.Equal(first, []string{"-j", EnforceChain}) {
+		t.Fatalf("expected the enforce chain to be jumped to first, got %v", ft.chains["iptables/OUTPUT"])
+	}
+}
+
+func TestEnforcer_AllowsResolvedAddressesUntilExpiry(t *testing.T) {
+	ft := newFilterTable(t)
+	now := time.Unix(1_700_000_000, 0)
+	e := NewEnforcer()
+	e.now = func() time.Time { return now }
+
+	// addresses learned before Setup are installed with it
+	early := netip.MustParseAddr("::ffff:93.184.216.34")
+	if err := e.Allow("example.com.", []netip.Addr{early}, time.Minute); err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if err := e.Setup(true); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	later := netip.MustParseAddr("2001:db8::1")
+	if err := e.Allow("example.com.", []netip.Addr{later, later}, time.Hour); err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	conn := func(bin, dst string) string {
+		return ft.verdict(bin, packet{out: "eth0", state: "NEW", dst: dst})
+	}
+	if conn("iptables", "93.184.216.34") != "ACCEPT" || conn("ip6tables", "2001:db8::1") != "ACCEPT" {
+		t.Fatalf("expected resolved addresses to be let through: %v", ft.chains)
+	}
+	if n := len(ft.chains["ip6tables/"+EnforceChain]); n != 5 {
+		t.Fatalf("expected a repeated address to be installed once, got %d rules", n)
+	}
+
+	// short TTLs are kept for the minimum retention
+	now = now.Add(EnforceMinRetention)
+	if removed, err := e.Expire(); err != nil || removed != 1 {
+		t.Fatalf("Expire: removed %d, %v", removed, err)
+	}
+	if conn("iptables", "93.184.216.34") != "DROP" || conn("ip6tables", "2001:db8::1") != "ACCEPT" {
+		t.Fatalf("expected only the expired address to be dropped again: %v", ft.chains)
+	}
+	if e.Len() != 1 {
+		t.Fatalf("expected one allowed address left, got %d", e.Len())
+	}
+}
+
+func TestEnforcer_AllowBatchesAddressesPerFamily(t *testing.T) {
+	ft := newFilterTable(t)
+	e := NewEnforcer()
+	if err := e.Setup(true); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	addrs := []netip.Addr{
+		netip.MustParseAddr("192.0.2.1"),
+		netip.MustParseAddr("192.0.2.2"),
+		netip.MustParseAddr("::ffff:192.0.2.1"), // the first, mapped
+		netip.MustParseAddr("2001:db8::1"),
+		netip.MustParseAddr("2001:db8::2"),
+	}
+	ft.commands = 0
+	if err := e.Allow("example.com.", addrs, time.Hour); err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if ft.commands != 2 {
+		t.Fatalf("expected one iptables-restore per family, got %d commands", ft.commands)
+	}
+	for _, dst := range []struct{ bin, addr string }{
+		{"iptables", "192.0.2.1"}, {"iptables", "192.0.2.2"},
+		{"ip6tables", "2001:db8::1"}, {"ip6tables", "2001:db8::2"},
+	} {
+		if ft.verdict(dst.bin, packet{out: "eth0", state: "NEW", dst: dst.addr}) != "ACCEPT" {
+			t.Fatalf("expected %s to be let through: %v", dst.addr, ft.chains)
+		}
+	}
+	if n := len(ft.chains["iptables/"+EnforceChain]); n != 6 {
+		t.Fatalf("expected a repeated address to be installed once, got %d rules", n)
+	}
+
+	// an answer with nothing new runs no command
+	ft.commands = 0
+	if err := e.Allow("www.example.com.", addrs[:2], time.Hour); err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if ft.commands != 0 {
+		t.Fatalf("expected no command for addresses already allowed, got %d", ft.commands)
+	}
+}
+
+func TestEnforcer_DefaultFollowsPolicy(t *testing.T) {
+	ft := newFilterTable(t)
+	e := NewEnforcer()
+	if err := e.Setup(false); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	unknown := packet{out: "eth0", state: "NEW", dst: "198.51.100.1"}
+	if ft.verdict("iptables", unknown) != "ACCEPT" {
+		t.Fatalf("expected a default-allow policy to let unresolved addresses through")
+	}
+	if err := e.SetDefaultDeny(true); err != nil {
+		t.Fatalf("SetDefaultDeny: %v", err)
+	}
+	if ft.verdict("iptables", unknown) != "DROP" || ft.verdict("ip6tables", packet{out: "eth0", state: "NEW", dst: "2001:db8::2"}) != "DROP" {
+		t.Fatalf("expected a default-deny policy to drop unresolved addresses: %v", ft.chains)
+	}
+	if err := e.SetDefaultDeny(true); err != nil {
+		t.Fatalf("SetDefaultDeny again: %v", err)
+	}
+	if n := len(ft.chains["iptables/"+EnforceChain]); n != 4 {
+		t.Fatalf("expected exactly one verdict rule, got %v", ft.chains["iptables/"+EnforceChain])
+	}
+
+	if err := e.Remove(); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, ok := ft.chains["iptables/"+EnforceChain]; ok || len(ft.chains["iptables/OUTPUT"]) != 0 {
+		t.Fatalf("expected the chain and its jump to be gone: %v", ft.chains)
+	}
+	if ft.verdict("iptables", unknown) != "ACCEPT" {
+		t.Fatalf("expected traffic to flow after Remove")
+	}
+}
+
+func TestEnforcer_TTLOverridesAnswerTTL(t *testing.T) {
+	ft := newFilterTable(t)
+	now := time.Unix(1_700_000_000, 0)
+	e := NewEnforcer()
+	e.now = func() time.Time { return now }
+	e.SetTTL(30 * time.Second)
+	if err := e.Setup(true); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	conn := func(dst string) string {
+		return ft.verdict("iptables", packet{out: "eth0", state: "NEW", dst: dst})
+	}
+
+	// neither a day-long answer TTL nor the minimum retention applies
+	if err := e.Allow("example.com.", []netip.Addr{netip.MustParseAddr("192.0.2.1")}, 24*time.Hour); err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if got := e.allowed[netip.MustParseAddr("192.0.2.1")]; !got.Equal(now.Add(30 * time.Second)) {
+		t.Fatalf("expected the entry to last the override ttl, until %v, got %v", now.Add(30*time.Second), got)
+	}
+	now = now.Add(30 * time.Second)
+	if removed, err := e.Expire(); err != nil || removed != 1 {
+		t.Fatalf("Expire: removed %d, %v", removed, err)
+	}
+	if conn("192.0.2.1") != "DROP" {
+		t.Fatalf("expected the address to be dropped once the override ttl passed")
+	}
+
+	// a zero override restores the answer ttl
+	e.SetTTL(0)
+	if err := e.Allow("example.com.", []netip.Addr{netip.MustParseAddr("192.0.2.2")}, time.Hour); err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if got := e.allowed[netip.MustParseAddr("192.0.2.2")]; !got.Equal(now.Add(time.Hour)) {
+		t.Fatalf("expected the answer ttl without an override, got %v", got)
+	}
+}
+
+func TestParseEnforceTTL(t *testing.T) {
+	if ttl, err := ParseEnforceTTL(" 120 "); err != nil || ttl != 2*time.Minute {
+		t.Fatalf("ParseEnforceTTL = %v, %v", ttl, err)
+	}
+	for _, raw := range []string{"", "-1", "2m", "1.5"} {
+		if _, err := ParseEnforceTTL(raw); err == nil {
+			t.Fatalf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestEnforcer_SwapPolicyKeepsStillAllowedAddresses(t *testing.T) {
+	ft := newFilterTable(t)
+	e := NewEnforcer()
+	if err := e.Setup(true); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	retained := netip.MustParseAddr("192.0.2.1")
+	revoked := netip.MustParseAddr("192.0.2.2")
+	shared := netip.MustParseAddr("2001:db8::3") // handed out for both names
+	for name, addrs := range map[string][]netip.Addr{
+		"keep.example.":    {retained, shared},
+		"removed.example.": {revoked, shared},
+	} {
+		if err := e.Allow(name, addrs, time.Hour); err != nil {
+			t.Fatalf("Allow %s: %v", name, err)
+		}
+	}
+	conn := func(bin, dst string) string {
+		return ft.verdict(bin, packet{out: "eth0", state: "NEW", dst: dst})
+	}
+
+	// the new policy drops removed.example and adds new.example
+	allowed := map[string]bool{"keep.example.": true, "new.example.": true}
+	ft.commands = 0
+	ft.observe = func() {
+		if conn("iptables", retained.String()) != "ACCEPT" {
+			t.Errorf("retained address dropped mid-swap: %v", ft.chains["iptables/"+EnforceChain])
+		}
+	}
+	removed, err := e.SwapPolicy(true, func(name string) bool { return allowed[name] })
+	if err != nil || removed != 1 {
+		t.Fatalf("SwapPolicy: removed %d, %v", removed, err)
+	}
+	if ft.commands != 1 {
+		t.Fatalf("expected the swap to be one iptables-restore transaction, got %d commands", ft.commands)
+	}
+	if conn("iptables", retained.String()) != "ACCEPT" || conn("ip6tables", shared.String()) != "ACCEPT" {
+		t.Fatalf("expected addresses of a still allowed name to be kept: %v", ft.chains)
+	}
+	if conn("iptables", revoked.String()) != "DROP" || e.Len() != 2 {
+		t.Fatalf("expected the address of the removed name to be revoked: %v", ft.chains)
+	}
+
+	// a newly allowed name is let through once resolved
+	added := netip.MustParseAddr("198.51.100.7")
+	if err := e.Allow("new.example.", []netip.Addr{added}, time.Hour); err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if conn("iptables", added.String()) != "ACCEPT" {
+		t.Fatalf("expected the new name's address to be allowed: %v", ft.chains)
+	}
+
+	// the shared address now only stands for keep.example
+	delete(allowed, "keep.example.")
+	if removed, err := e.SwapPolicy(false, func(name string) bool { return allowed[name] }); err != nil || removed != 2 {
+		t.Fatalf("SwapPolicy: removed %d, %v", removed, err)
+	}
+	if n := len(ft.chains["ip6tables/"+EnforceChain]); n != 4 {
+		t.Fatalf("expected only the exceptions and verdict left for ipv6, got %v", ft.chains["ip6tables/"+EnforceChain])
+	}
+	if conn("iptables", "203.0.113.9") != "ACCEPT" {
+		t.Fatalf("expected the default verdict to follow the new policy")
+	}
+}
+
+func TestEnforcer_ShortTTLMembershipStableAcrossReloads(t *testing.T) {
+	ft := newFilterTable(t)
+	now := time.Unix(1_700_000_000, 0)
+	e := NewEnforcer()
+	e.now = func() time.Time { return now }
+	if err := e.Setup(true); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	cdn := []netip.Addr{netip.MustParseAddr("192.0.2.10"), netip.MustParseAddr("192.0.2.11")}
+	allowed := func(name string) bool { return name == "cdn.example." }
+
+	// a CDN answering with a 2s TTL is re-resolved every 2s for longer than
+	// the retention, with expiry sweeps and a policy reload every 5 minutes
+	if err := e.Allow("cdn.example.", cdn, 2*time.Second); err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	ft.commands = 0
+	for step := 1; step <= 900; step++ {
+		now = now.Add(2 * time.Second)
+		if err := e.Allow("cdn.example.", cdn, 2*time.Second); err != nil {
+			t.Fatalf("Allow at step %d: %v", step, err)
+		}
+		if _, err := e.Expire(); err != nil {
+			t.Fatalf("Expire at step %d: %v", step, err)
+		}
+		if step%150 == 0 {
+			if _, err := e.SwapPolicy(true, allowed); err != nil {
+				t.Fatalf("SwapPolicy at step %d: %v", step, err)
+			}
+		}
+		if got := e.Domains(); len(got) != 2 || got[cdn[0]] != "cdn.example." || got[cdn[1]] != "cdn.example." {
+			t.Fatalf("allowed set changed at step %d: %v", step, got)
+		}
+	}
+	if ft.commands != 0 {
+		t.Fatalf("expected no rule churn for a stable short-TTL answer, got %d commands", ft.commands)
+	}
+}
+
+func TestEnforcer_LogsBlockedConnectionsBeforeDrop(t *testing.T) {
+	ft := newFilterTable(t)
+	e := NewEnforcer()
+	e.LogBlocked(100)
+	if err := e.Setup(true); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	logRule := []string{"-m", "conntrack", "--ctstate", "NEW", "-j", "NFLOG", "--nflog-group", "100", "--nflog-prefix", BlockedLogPrefix}
+	assertLoggedDrop := func(when string) {
+		t.Helper()
+		for _, bin := range []string{"iptables", "ip6tables"} {
+			chain := ft.chains[bin+"/"+EnforceChain]
+			if len(chain) < 2 || !slices.Equal(chain[len(chain)-2], logRule) || !slices.Equal(chain[len(chain)-1], []string{"-j", "DROP"}) {
+				t.Fatalf("%s: expected the NFLOG rule right before the DROP in %s, got %v", when, bin, chain)
+			}
+		}
+	}
+	assertLoggedDrop("after Setup")
+
+	if err := e.SetDefaultDeny(false); err != nil {
+		t.Fatalf("SetDefaultDeny: %v", err)
+	}
+	for _, bin := range []string{"iptables", "ip6tables"} {
+		chain := ft.chains[bin+"/"+EnforceChain]
+		if last := chain[len(chain)-1]; !slices.Equal(last, []string{"-j", "RETURN"}) || slices.ContainsFunc(chain, func(r []string) bool { return slices.Contains(r, "NFLOG") }) {
+			t.Fatalf("expected nothing logged under a default-allow policy in %s, got %v", bin, chain)
+		}
+	}
+	if err := e.SetDefaultDeny(true); err != nil {
+		t.Fatalf("SetDefaultDeny: %v", err)
+	}
+	assertLoggedDrop("after SetDefaultDeny")
+
+	if _, err := e.SwapPolicy(false, func(string) bool { return true }); err != nil {
+		t.Fatalf("SwapPolicy: %v", err)
+	}
+	if _, err := e.SwapPolicy(true, func(string) bool { return true }); err != nil {
+		t.Fatalf("SwapPolicy: %v", err)
+	}
+	assertLoggedDrop("after SwapPolicy")
+	if n := len(ft.chains["iptables/"+EnforceChain]); n != 5 {
+		t.Fatalf("expected the exceptions, one log rule and one verdict, got %v", ft.chains["iptables/"+EnforceChain])
+	}
+	if got := ft.verdict("iptables", packet{out: "eth0", state: "NEW", dst: "203.0.113.9"}); got != "DROP" {
+		t.Fatalf("expected the log rule to leave the verdict alone, got %s", got)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	hintMissingNetAdmin = "missing NET_ADMIN capability? run the sidecar with --cap-add=NET_ADMIN"
+	hintBinaryNotFound  = "iptables binary not found in PATH; install iptables in the sidecar image"
+)
+
+// CommandError is returned when an iptables invocation fails. It carries
+// enough context to diagnose the failure without re-running the command.
+type CommandError struct {
+	Command  string   `json:"command"`
+	Args     []string `json:"args"`
+	ExitCode int      `json:"exitCode"`
+	Stderr   string   `json:"stderr,omitempty"`
+	Hint     string   `json:"hint,omitempty"`
+	Err      error    `json:"-"`
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("%s %s failed (exit code %d): %v", e.Command, strings.Join(e.Args, " "), e.ExitCode, e.Err)
+	if e.Stderr != "" {
+		msg += fmt.Sprintf(" (output: %s)", e.Stderr)
+	}
+	if e.Hint != "" {
+		msg += fmt.Sprintf(" (hint: %s)", e.Hint)
+	}
+	return msg
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermissionDenied reports whether the command failed due to missing privileges.
+func (e *CommandError) IsPermissionDenied() bool {
+	return e.Hint == hintMissingNetAdmin
+}
+
+func newCommandError(name string, args []string, output []byte, err error) *CommandError {
+	cmdErr := &CommandError{
+		Command:  name,
+		Args:     args,
+		ExitCode: -1,
+		Stderr:   strings.TrimSpace(string(output)),
+		Err:      err,
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		cmdErr.ExitCode = exitErr.ExitCode()
+	}
+	cmdErr.Hint = hintFor(cmdErr)
+	return cmdErr
+}
+
+// hintFor maps well-known failure signatures to an actionable hint.
+func hintFor(e *CommandError) string {
+	switch {
+	case errors.Is(e.Err, exec.ErrNotFound):
+		return hintBinaryNotFound
+	case errors.Is(e.Err, os.ErrPermission):
+		return hintMissingNetAdmin
+	}
+	stderr := strings.ToLower(e.Stderr)
+	if strings.Contains(stderr, "permission denied") || strings.Contains(stderr, "operation not permitted") {
+		return hintMissingNetAdmin
+	}
+	return ""
+}
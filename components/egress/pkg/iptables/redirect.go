@@ -15,37 +15,67 @@
 package iptables
 
 import (
-	"fmt"
 	"os/exec"
 	"strconv"
 )
 
 const bypassMark = "0x1"
 
+// runCommand executes a single rule command; swapped in tests.
+var runCommand = func(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).CombinedOutput()
+}
+
 // SetupRedirect installs OUTPUT nat redirect for DNS (udp/tcp 53 -> port).
 // Packets carrying mark bypassMark will RETURN (used by the proxy's own upstream
 // queries to avoid redirect loops). Requires CAP_NET_ADMIN inside the namespace.
+// Failures are reported as *CommandError.
 func SetupRedirect(port int) error {
+	return runRules(redirectRules("-A", port))
+}
+
+// RemoveRedirect deletes the rules installed by SetupRedirect, so DNS goes
+// to the configured resolver directly again. Every rule is attempted; the
+// first failure is reported as *CommandError.
+func RemoveRedirect(port int) error {
+	return removeRules(redirectRules("-D", port))
+}
+
+// redirectRules returns the redirect rules with op ("-A" or "-D") applied.
+func redirectRules(op string, port int) [][]string {
 	targetPort := strconv.Itoa(port)
 
-	rules := [][]string{
+	return [][]string{
 		// Bypass packets marked by the proxy itself (see dnsproxy dialer).
-		{"iptables", "-t", "nat", "-A", "OUTPUT", "-p", "udp", "--dport", "53", "-m", "mark", "--mark", bypassMark, "-j", "RETURN"},
-		{"iptables", "-t", "nat", "-A", "OUTPUT", "-p", "tcp", "--dport", "53", "-m", "mark", "--mark", bypassMark, "-j", "RETURN"},
+		{"iptables", "-t", "nat", op, "OUTPUT", "-p", "udp", "--dport", "53", "-m", "mark", "--mark", bypassMark, "-j", "RETURN"},
+		{"iptables", "-t", "nat", op, "OUTPUT", "-p", "tcp", "--dport", "53", "-m", "mark", "--mark", bypassMark, "-j", "RETURN"},
 		// Redirect all other DNS traffic to local proxy port.
-		{"iptables", "-t", "nat", "-A", "OUTPUT", "-p", "udp", "--dport", "53", "-j", "REDIRECT", "--to-port", targetPort},
-		{"iptables", "-t", "nat", "-A", "OUTPUT", "-p", "tcp", "--dport", "53", "-j", "REDIRECT", "--to-port", targetPort},
+		{"iptables", "-t", "nat", op, "OUTPUT", "-p", "udp", "--dport", "53", "-j", "REDIRECT", "--to-port", targetPort},
+		{"iptables", "-t", "nat", op, "OUTPUT", "-p", "tcp", "--dport", "53", "-j", "REDIRECT", "--to-port", targetPort},
 		// IPv6 equivalents (ip6tables)
-		{"ip6tables", "-t", "nat", "-A", "OUTPUT", "-p", "udp", "--dport", "53", "-m", "mark", "--mark", bypassMark, "-j", "RETURN"},
-		{"ip6tables", "-t", "nat", "-A", "OUTPUT", "-p", "tcp", "--dport", "53", "-m", "mark", "--mark", bypassMark, "-j", "RETURN"},
-		{"ip6tables", "-t", "nat", "-A", "OUTPUT", "-p", "udp", "--dport", "53", "-j", "REDIRECT", "--to-port", targetPort},
-		{"ip6tables", "-t", "nat", "-A", "OUTPUT", "-p", "tcp", "--dport", "53", "-j", "REDIRECT", "--to-port", targetPort},
+		{"ip6tables", "-t", "nat", op, "OUTPUT", "-p", "udp", "--dport", "53", "-m", "mark", "--mark", bypassMark, "-j", "RETURN"},
+		{"ip6tables", "-t", "nat", op, "OUTPUT", "-p", "tcp", "--dport", "53", "-m", "mark", "--mark", bypassMark, "-j", "RETURN"},
+		{"ip6tables", "-t", "nat", op, "OUTPUT", "-p", "udp", "--dport", "53", "-j", "REDIRECT", "--to-port", targetPort},
+		{"ip6tables", "-t", "nat", op, "OUTPUT", "-p", "tcp", "--dport", "53", "-j", "REDIRECT", "--to-port", targetPort},
 	}
+}
 
+func runRules(rules [][]string) error {
 	for _, args := range rules {
-		if output, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
-			return fmt.Errorf("iptables command failed: %v (output: %s)", err, output)
+		if output, err := runCommand(args[0], args[1:]...); err != nil {
+			return newCommandError(args[0], args[1:], output, err)
 		}
 	}
 	return nil
 }
+
+// removeRules runs every deletion in rules and reports the first failure.
+func removeRules(rules [][]string) error {
+	var first error
+	for _, args := range rules {
+		if output, err := runCommand(args[0], args[1:]...); err != nil && first == nil {
+			first = newCommandError(args[0], args[1:], output, err)
+		}
+	}
+	return first
+}
@@ -0,0 +1,158 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func stubRunCommand(t *testing.T, fn func(name string, args ...string) ([]byte, error)) {
+	t.Helper()
+	orig := runCommand
+	runCommand = fn
+	t.Cleanup(func() { runCommand = orig })
+}
+
+func TestSetupRedirect_PermissionDeniedHint(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found in PATH")
+	}
+	stubRunCommand(t, func(_ string, _ ...string) ([]byte, error) {
+		return exec.Command("sh", "-c", "echo 'iptables v1.8.9 (legacy): cannot initialize iptables table nat: Permission denied (you must be root)' >&2; exit 3").CombinedOutput()
+	})
+
+	err := SetupRedirect(15353)
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("expected *CommandError, got %T: %v", err, err)
+	}
+	if cmdErr.Command != "iptables" {
+		t.Fatalf("unexpected command: %s", cmdErr.Command)
+	}
+	if len(cmdErr.Args) == 0 || cmdErr.Args[0] != "-t" {
+		t.Fatalf("expected full argv, got %v", cmdErr.Args)
+	}
+	if cmdErr.ExitCode != 3 {
+		t.Fatalf("expected exit code 3, got %d", cmdErr.ExitCode)
+	}
+	if !cmdErr.IsPermissionDenied() || cmdErr.Hint != hintMissingNetAdmin {
+		t.Fatalf("expected NET_ADMIN hint, got %q", cmdErr.Hint)
+	}
+}
+
+func TestSetupRedirect_EPERMFromExec(t *testing.T) {
+	stubRunCommand(t, func(name string, _ ...string) ([]byte, error) {
+		return nil, &os.PathError{Op: "fork/exec", Path: name, Err: syscall.EPERM}
+	})
+
+	err := SetupRedirect(15353)
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("expected *CommandError, got %T: %v", err, err)
+	}
+	if !errors.Is(err, syscall.EPERM) {
+		t.Fatalf("expected error chain to contain EPERM: %v", err)
+	}
+	if cmdErr.ExitCode != -1 {
+		t.Fatalf("expected exit code -1 when the process never ran, got %d", cmdErr.ExitCode)
+	}
+	if !cmdErr.IsPermissionDenied() {
+		t.Fatalf("expected NET_ADMIN hint, got %q", cmdErr.Hint)
+	}
+}
+
+func TestSetupRedirect_NoHintForUnknownFailure(t *testing.T) {
+	stubRunCommand(t, func(_ string, _ ...string) ([]byte, error) {
+		return []byte("iptables: No chain/target/match by that name."), errors.New("exit status 1")
+	})
+
+	err := SetupRedirect(15353)
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("expected *CommandError, got %T: %v", err, err)
+	}
+	if cmdErr.Hint != "" {
+		t.Fatalf("expected no hint, got %q", cmdErr.Hint)
+	}
+	if cmdErr.Stderr == "" {
+		t.Fatalf("expected stderr to be captured")
+	}
+}
+
+func TestSetupRedirect_Success(t *testing.T) {
+	var calls int
+	stubRunCommand(t, func(_ string, _ ...string) ([]byte, error) {
+		calls++
+		return nil, nil
+	})
+
+	if err := SetupRedirect(15353); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 8 {
+		t.Fatalf("expected 8 rule invocations, got %d", calls)
+	}
+}
+
+func TestSetupConnLog_Rules(t *testing.T) {
+	var calls [][]string
+	stubRunCommand(t, func(name string, args ...string) ([]byte, error) {
+		calls = append(calls, append([]string{name}, args...))
+		return nil, nil
+	})
+
+	if err := SetupConnLog(100); err != nil {
+		t.Fatalf("SetupConnLog: %v", err)
+	}
+	if len(calls) != 2 || calls[0][0] != "iptables" || calls[1][0] != "ip6tables" {
+		t.Fatalf("expected one iptables and one ip6tables rule, got %v", calls)
+	}
+	rule := strings.Join(calls[0], " ")
+	for _, want := range []string{"--ctstate NEW", "! --mark " + bypassMark, "--nflog-group 100", "--nflog-prefix " + ConnLogPrefix} {
+		if !strings.Contains(rule, want) {
+			t.Fatalf("rule %q does not contain %q", rule, want)
+		}
+	}
+}
+
+func TestRemoveRedirect_DeletesEveryRule(t *testing.T) {
+	var calls [][]string
+	stubRunCommand(t, func(name string, args ...string) ([]byte, error) {
+		calls = append(calls, append([]string{name}, args...))
+		if len(calls) == 1 {
+			return []byte("iptables: Bad rule (does a matching rule exist in that chain?)."), errors.New("exit status 1")
+		}
+		return nil, nil
+	})
+
+	err := RemoveRedirect(15353)
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("expected *CommandError, got %T: %v", err, err)
+	}
+	if len(calls) != 8 {
+		t.Fatalf("expected all 8 rules to be attempted after a failure, got %d", len(calls))
+	}
+	for _, call := range calls {
+		if call[3] != "-D" {
+			t.Fatalf("expected a delete, got %v", call)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import "time"
+
+// Allowlist is the part of a policy that code inside the sandbox needs to
+// tell what it may reach: a name is allowed when it matches Allow and does
+// not match Deny, or when it matches neither and DefaultAction is allow.
+// Where both match, the order rules are evaluated in decides; Explain does.
+type Allowlist struct {
+	DefaultAction string `json:"defaultAction"`
+	// Allow lists the targets of allow rules in effect, in evaluation order.
+	Allow []string `json:"allow"`
+	// Deny lists the targets of deny rules in effect, in evaluation order,
+	// followed by the Block suffixes as "*.suffix" and "suffix".
+	Deny []string `json:"deny"`
+}
+
+// Allowlist returns the allowlist in effect at the current time.
+func (p *NetworkPolicy) Allowlist() Allowlist {
+	return p.AllowlistAt(now())
+}
+
+// AllowlistAt returns the allowlist in effect at t; rules whose window does
+// not contain t are left out. A nil policy allows nothing.
+func (p *NetworkPolicy) AllowlistAt(t time.Time) Allowlist {
+	list := Allowlist{DefaultAction: ActionDeny, Allow: []string{}, Deny: []string{}}
+	if p == nil {
+		return list
+	}
+	list.DefaultAction = p.DefaultAction
+	for _, i := range p.evaluationOrder() {
+		r := p.Egress[i]
+		if r.Window != nil && !r.Window.Contains(t) {
+			continue
+		}
+		if r.Action == ActionAllow {
+			list.Allow = append(list.Allow, r.Target)
+		} else {
+			list.Deny = append(list.Deny, r.Target)
+		}
+	}
+	for _, suffix := range p.Block {
+		list.Deny = append(list.Deny, "*."+suffix, suffix)
+	}
+	return list
+}
@@ -0,0 +1,46 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAllowlist_LeavesOutClosedWindows(t *testing.T) {
+	p, err := ParsePolicy(`{"defaultAction":"allow","egress":[
+		{"action":"deny","target":"*.pypi.org","window":{"start":"09:00","end":"17:00"}},
+		{"action":"deny","target":"example.com"}
+	]}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	clock := useFakeClock(t, time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC))
+	if list := p.Allowlist(); list.DefaultAction != ActionAllow || !slices.Equal(list.Deny, []string{"example.com"}) || len(list.Allow) != 0 {
+		t.Fatalf("unexpected allowlist outside the window: %+v", list)
+	}
+	clock.Advance(2 * time.Hour)
+	if list := p.Allowlist(); !slices.Equal(list.Deny, []string{"example.com", "*.pypi.org"}) {
+		t.Fatalf("unexpected allowlist inside the window: %+v", list)
+	}
+}
+
+func TestAllowlist_NilPolicyAllowsNothing(t *testing.T) {
+	var p *NetworkPolicy
+	if list := p.Allowlist(); list.DefaultAction != ActionDeny || len(list.Allow) != 0 {
+		t.Fatalf("unexpected allowlist: %+v", list)
+	}
+}
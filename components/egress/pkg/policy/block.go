@@ -0,0 +1,90 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// blockSet holds the normalized Block suffixes of a parsed policy.
+type blockSet struct {
+	suffixes map[string]struct{}
+	// entries is len(Block) when the set was built.
+	entries int
+}
+
+// normalizeSuffix lower-cases a domain suffix and strips a leading "*." and
+// surrounding dots, so "*.Example.com." becomes "example.com".
+func normalizeSuffix(raw string) (string, error) {
+	suffix := strings.ToLower(strings.TrimSpace(raw))
+	suffix = strings.Trim(strings.TrimPrefix(suffix, "*."), ".")
+	if suffix == "" {
+		return "", fmt.Errorf("suffix is empty")
+	}
+	return suffix, nil
+}
+
+// buildBlockSet normalizes Block in place and indexes it.
+func (p *NetworkPolicy) buildBlockSet() error {
+	set := &blockSet{suffixes: make(map[string]struct{}, len(p.Block)), entries: len(p.Block)}
+	for i, raw := range p.Block {
+		suffix, err := normalizeSuffix(raw)
+		if err != nil {
+			return fmt.Errorf("block[%d] (%s): %w", i, raw, err)
+		}
+		p.Block[i] = suffix
+		set.suffixes[suffix] = struct{}{}
+	}
+	p.blocks = set
+	return nil
+}
+
+// blockedBy returns the Block entry covering domain (lower-cased, no trailing
+// dot), or "" when none does. An entry covers its own name and every name
+// below it, on label boundaries.
+func (p *NetworkPolicy) blockedBy(domain string) string {
+	if len(p.Block) == 0 || domain == "" {
+		return ""
+	}
+	if p.blocks == nil || p.blocks.entries != len(p.Block) {
+		for _, raw := range p.Block {
+			if suffix, err := normalizeSuffix(raw); err == nil && (domain == suffix || strings.HasSuffix(domain, "."+suffix)) {
+				return suffix
+			}
+		}
+		return ""
+	}
+	for name := domain; ; {
+		if _, ok := p.blocks.suffixes[name]; ok {
+			return name
+		}
+		i := strings.IndexByte(name, '.')
+		if i < 0 {
+			return ""
+		}
+		name = name[i+1:]
+	}
+}
+
+// blockOrigin locates the first Block entry that normalizes to suffix.
+func (p *NetworkPolicy) blockOrigin(suffix string) *RuleOrigin {
+	for i, raw := range p.Block {
+		if normalized, err := normalizeSuffix(raw); err == nil && normalized == suffix {
+			return p.origin(OriginFieldBlock, i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import "testing"
+
+func TestExplain_DefaultAllowWithBlockedSuffixes(t *testing.T) {
+	p, err := ParsePolicy(`{"defaultAction":"allow","block":["ads.example","*.Tracker.NET.","rival-api.com"],"egress":[
+		{"action":"allow","target":"static.ads.example","priority":100},
+		{"action":"allow","target":"*.tracker.net"},
+		{"action":"deny","target":"*.internal.example.com"}
+	]}`)
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+
+	cases := []struct {
+		domain string
+		action string
+		reason BlockReason
+		target string
+	}{
+		// a block entry covers its own name and everything below it
+		{"ads.example", ActionDeny, BlockReasonBlockedSuffix, "ads.example"},
+		{"x.y.ads.example.", ActionDeny, BlockReasonBlockedSuffix, "ads.example"},
+		{"tracker.net", ActionDeny, BlockReasonBlockedSuffix, "tracker.net"},
+		{"API.Rival-API.com", ActionDeny, BlockReasonBlockedSuffix, "rival-api.com"},
+		// blocks win over any allow, whatever its priority or specificity
+		{"static.ads.example", ActionDeny, BlockReasonBlockedSuffix, "ads.example"},
+		{"pixel.tracker.net", ActionDeny, BlockReasonBlockedSuffix, "tracker.net"},
+		// only whole labels match
+		{"notads.example", ActionAllow, "", ""},
+		{"rival-api.com.evil.net", ActionAllow, "", ""},
+		// egress rules and the default action still apply elsewhere
+		{"db.internal.example.com", ActionDeny, BlockReasonExplicitDeny, "*.internal.example.com"},
+		{"example.org", ActionAllow, "", ""},
+	}
+	for _, c := range cases {
+		got := p.Explain(c.domain)
+		if got.Action != c.action || got.Reason != c.reason || got.Target != c.target {
+			t.Errorf("Explain(%q) = %+v, want %s/%s via %q", c.domain, got, c.action, c.reason, c.target)
+		}
+	}
+	if got := p.Explain("ads.example"); got.Rule != -1 {
+		t.Errorf("expected a block entry to report rule -1, got %d", got.Rule)
+	}
+}
+
+func TestExplain_DefaultActionIndependentOfRules(t *testing.T) {
+	cases := []struct {
+		raw    string
+		domain string
+		action string
+	}{
+		// blocks alone, either default
+		{`{"defaultAction":"allow","block":["ads.example"]}`, "example.org", ActionAllow},
+		{`{"defaultAction":"deny","block":["ads.example"]}`, "example.org", ActionDeny},
+		// allow rules do not turn a default-allow policy into an allowlist
+		{`{"defaultAction":"allow","block":["ads.example"],"egress":[{"action":"allow","target":"pypi.org"}]}`, "example.org", ActionAllow},
+		// and blocks still win in a default-deny policy
+		{`{"defaultAction":"deny","block":["pypi.org"],"egress":[{"action":"allow","target":"pypi.org"}]}`, "pypi.org", ActionDeny},
+	}
+	for _, c := range cases {
+		p, err := ParsePolicy(c.raw)
+		if err != nil {
+			t.Fatalf("parse %s: %v", c.raw, err)
+		}
+		if got := p.Evaluate(c.domain); got != c.action {
+			t.Errorf("%s: Evaluate(%q) = %s, want %s", c.raw, c.domain, got, c.action)
+		}
+	}
+}
+
+func TestBlockedBy_UnparsedPolicyAndInvalidEntries(t *testing.T) {
+	p := &NetworkPolicy{DefaultAction: ActionAllow, Block: []string{"*.Ads.Example"}}
+	if got := p.Explain("a.ads.example"); got.Action != ActionDeny || got.Target != "ads.example" {
+		t.Fatalf("expected blocks to apply without parsing, got %+v", got)
+	}
+	for _, raw := range []string{`{"block":[""]}`, `{"block":["*."]}`, `{"block":[" . "]}`} {
+		if _, err := ParsePolicy(raw); err == nil {
+			t.Errorf("expected %s to be rejected", raw)
+		}
+	}
+}
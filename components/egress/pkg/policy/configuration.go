@@ -23,4 +23,94 @@ const (
 
 	// Optional bootstrap policy at sidecar start; same shape as /policy.
 	EgressRulesEnv = "OPENSANDBOX_EGRESS_RULES"
+
+	// Optional local IP or interface name that upstream DNS queries egress from.
+	EgressUpstreamSourceEnv = "OPENSANDBOX_EGRESS_UPSTREAM_SOURCE"
+
+	// Optional socks5://[user[:password]@]host:port proxy that upstream DNS queries are sent through over TCP.
+	EgressUpstreamSOCKS5Env = "OPENSANDBOX_EGRESS_UPSTREAM_SOCKS5"
+
+	// Optional IPv4 and/or IPv6 (comma-separated) returned for blocked names instead of NXDOMAIN.
+	EgressSinkholeEnv = "OPENSANDBOX_EGRESS_SINKHOLE"
+
+	// Optional floor, in seconds, for TTLs of forwarded answers.
+	EgressMinTTLEnv = "OPENSANDBOX_EGRESS_MIN_TTL"
+
+	// Optional EDNS Client Subnet handling: "passthrough" (default), "strip", or a CIDR sent instead of the client's.
+	EgressECSEnv = "OPENSANDBOX_EGRESS_ECS"
+
+	// Optional switch ("true"/"false") for RFC 7816 query name minimization towards the upstream.
+	EgressQNameMinimizationEnv = "OPENSANDBOX_EGRESS_QNAME_MINIMIZATION"
+
+	// Optional switch ("true"/"false") for sending repeated queries for a name to the resolv.conf nameserver that answered it last.
+	EgressStickyUpstreamEnv = "OPENSANDBOX_EGRESS_STICKY_UPSTREAM"
+
+	// Optional switch ("true"/"false") for sending queries to the resolv.conf nameserver with the lowest recent response times.
+	EgressLatencyUpstreamEnv = "OPENSANDBOX_EGRESS_LATENCY_UPSTREAM"
+
+	// Optional switch ("true"/"false") for answering repeated queries from a TTL-bounded local cache.
+	EgressDNSCacheEnv = "OPENSANDBOX_EGRESS_DNS_CACHE"
+
+	// Optional seconds past expiry a cached answer is still served while it is refreshed (default 0, off).
+	EgressDNSCacheStaleEnv = "OPENSANDBOX_EGRESS_DNS_CACHE_STALE"
+
+	// Optional maximum number of cached answers (default 4096); the soonest to expire is evicted when full.
+	EgressDNSCacheSizeEnv = "OPENSANDBOX_EGRESS_DNS_CACHE_SIZE"
+
+	// Optional per query type rules (e.g. "TXT:nocache;A:min=60,max=3600;ANY:refuse") for caching, TTLs and forwarding.
+	EgressDNSQueryTypeRulesEnv = "OPENSANDBOX_EGRESS_DNS_QTYPE_RULES"
+
+	// Optional extra DNS listeners (comma-separated "label=host:port[@policy file]"), each deciding its queries with its own policy.
+	EgressDNSListenersEnv = "OPENSANDBOX_EGRESS_DNS_LISTENERS"
+
+	// Optional DNS classes (comma-separated, e.g. "CH") handled like IN; other non-IN queries are refused.
+	EgressAllowedClassesEnv = "OPENSANDBOX_EGRESS_ALLOWED_QCLASSES"
+
+	// Optional switch ("true"/"false") for logging new outbound connections with the domain they were resolved for.
+	EgressConnectionLogEnv = "OPENSANDBOX_EGRESS_CONNECTION_LOG"
+
+	// Optional cap on connection log records per second (default 100); the excess is summarized.
+	EgressConnectionLogRateEnv = "OPENSANDBOX_EGRESS_CONNECTION_LOG_RATE"
+
+	// Optional interval for logging allowed DNS queries: 1 logs every one, N one in N (default 0, none). Blocks are always logged.
+	EgressQueryLogSampleEnv = "OPENSANDBOX_EGRESS_QUERY_LOG_SAMPLE"
+
+	// Optional cap on sampled allowed-query log lines per second (default 0, no cap); the excess is summarized.
+	EgressQueryLogRateEnv = "OPENSANDBOX_EGRESS_QUERY_LOG_RATE"
+
+	// Optional file of "sample=N" and "rate=N" lines overriding the two settings above; re-read on SIGHUP.
+	EgressQueryLogFileEnv = "OPENSANDBOX_EGRESS_QUERY_LOG_FILE"
+
+	// Optional receive buffer, in bytes, for the proxy's UDP socket; raise it when query bursts are dropped.
+	EgressDNSUDPReadBufferEnv = "OPENSANDBOX_EGRESS_DNS_UDP_RCVBUF"
+
+	// Optional listen backlog for the proxy's TCP socket (Linux, capped by net.core.somaxconn).
+	EgressDNSTCPBacklogEnv = "OPENSANDBOX_EGRESS_DNS_TCP_BACKLOG"
+
+	// Optional switch ("true"/"false") for SO_REUSEPORT on the proxy's sockets (Linux).
+	EgressDNSReusePortEnv = "OPENSANDBOX_EGRESS_DNS_REUSEPORT"
+
+	// Optional number of UDP/TCP socket pairs serving queries in parallel; more than one needs SO_REUSEPORT.
+	EgressDNSWorkersEnv = "OPENSANDBOX_EGRESS_DNS_WORKERS"
+
+	// Optional reaction ("restart", "fail-open" or "exit") when the DNS proxy stops serving while port 53 is redirected to it.
+	ProxyDeathActionEnv = "OPENSANDBOX_PROXY_DEATH_ACTION"
+
+	// Optional switch ("true"/"false") for dropping outbound traffic to addresses no allowed answer handed out, while the policy denies by default.
+	EgressEnforceEnv = "OPENSANDBOX_EGRESS_ENFORCE"
+
+	// Optional lifetime, in seconds, of addresses allowed in enforce mode, replacing the answer TTL; 0 keeps the answer TTL.
+	EgressEnforceTTLEnv = "OPENSANDBOX_EGRESS_ENFORCE_TTL"
+
+	// Optional number of domains (e.g. "50"), those that sent the most, whose bytes sent in enforce mode get their own opensandbox_egress_bytes_total series on GET /metrics; 0 turns byte accounting off.
+	EgressByteMetricsEnv = "OPENSANDBOX_EGRESS_BYTE_METRICS"
+
+	// Optional loopback host:port (e.g. "127.0.0.1:18081") serving the current allowlist read-only at GET /allowlist to the sandbox.
+	EgressAllowlistAddrEnv = "OPENSANDBOX_EGRESS_ALLOWLIST_ADDR"
+
+	// Optional placement ("before", "after" or "skip") of the DNS redirect relative to nat OUTPUT redirects of other tools such as a service mesh.
+	EgressIptablesCoexistenceEnv = "OPENSANDBOX_EGRESS_IPTABLES_COEXISTENCE"
+
+	// Optional path of a sentinel file written once the DNS proxy serves and the redirect is installed, and removed on shutdown.
+	EgressReadyFileEnv = "OPENSANDBOX_EGRESS_READY_FILE"
 )
@@ -0,0 +1,103 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"strings"
+	"time"
+)
+
+// BlockReason is a machine-readable cause for a deny decision.
+type BlockReason string
+
+const (
+	// BlockReasonNoPolicy means no policy was loaded, which denies everything.
+	BlockReasonNoPolicy BlockReason = "no_policy"
+	// BlockReasonExplicitDeny means a deny rule matched the name.
+	BlockReasonExplicitDeny BlockReason = "explicit_deny"
+	// BlockReasonNoMatchingRule means no rule matched and the default action is deny.
+	BlockReasonNoMatchingRule BlockReason = "no_matching_rule"
+	// BlockReasonBlockedSuffix means the name is at or below a Block entry.
+	BlockReasonBlockedSuffix BlockReason = "blocked_suffix"
+	// BlockReasonOutsideWindow means an allow rule for the name was skipped
+	// because its time window was closed.
+	BlockReasonOutsideWindow BlockReason = "outside_window"
+)
+
+// Decision is the outcome of evaluating a name, with the reason it was
+// blocked and the rule responsible.
+type Decision struct {
+	Action string `json:"action"`
+	// Reason is empty when the name is allowed.
+	Reason BlockReason `json:"reason,omitempty"`
+	// Rule is the index of the deciding rule in Egress, or -1 when the
+	// default action or a Block entry applied.
+	Rule int `json:"rule"`
+	// Target and Priority describe the deciding rule; both are zero for the
+	// default action. For a Block entry, Target is the blocked suffix.
+	Target   string `json:"target,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+	// Origin locates the deciding egress rule or Block entry in the policy
+	// document; nil for the default action.
+	Origin *RuleOrigin `json:"origin,omitempty"`
+}
+
+// Explain evaluates domain at the current time and reports why.
+func (p *NetworkPolicy) Explain(domain string) Decision {
+	return p.ExplainAt(domain, now())
+}
+
+// ExplainAt is the decision path behind EvaluateAt. A name covered by Block
+// is denied outright; otherwise matching rules are tried in precedence order
+// (see evaluationOrder) and the first active one wins. When a deny is reached
+// after skipping a closed-window allow rule for the same name, the block is
+// attributed to that rule since the name would have been allowed inside it.
+func (p *NetworkPolicy) ExplainAt(domain string, t time.Time) Decision {
+	if p == nil {
+		return Decision{Action: ActionDeny, Reason: BlockReasonNoPolicy, Rule: -1}
+	}
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if suffix := p.blockedBy(domain); suffix != "" {
+		return Decision{Action: ActionDeny, Reason: BlockReasonBlockedSuffix, Rule: -1, Target: suffix, Origin: p.blockOrigin(suffix)}
+	}
+	closedAllow := -1
+	decision := Decision{Action: p.DefaultAction, Reason: BlockReasonNoMatchingRule, Rule: -1}
+	for _, i := range p.matchingRules(domain) {
+		r := p.Egress[i]
+		if r.Window != nil && !r.Window.Contains(t) {
+			if r.Action == ActionAllow && closedAllow < 0 {
+				closedAllow = i
+			}
+			continue
+		}
+		decision = Decision{Action: r.Action, Reason: BlockReasonExplicitDeny, Rule: i, Target: r.Target, Priority: r.Priority}
+		break
+	}
+	if decision.Action == "" {
+		decision.Action = ActionDeny
+	}
+	if decision.Action != ActionDeny {
+		decision.Reason = ""
+	} else if closedAllow >= 0 {
+		decision.Reason = BlockReasonOutsideWindow
+		decision.Rule = closedAllow
+		decision.Target = p.Egress[closedAllow].Target
+		decision.Priority = p.Egress[closedAllow].Priority
+	}
+	if decision.Rule >= 0 {
+		decision.Origin = p.origin(OriginFieldEgress, decision.Rule)
+	}
+	return decision
+}
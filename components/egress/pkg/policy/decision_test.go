@@ -0,0 +1,74 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExplainAt_BlockReasons(t *testing.T) {
+	p, err := ParsePolicy(`{"defaultAction":"deny","egress":[
+		{"action":"deny","target":"bad.example.com"},
+		{"action":"allow","target":"deb.debian.org","window":{"start":"02:00","end":"04:00"}},
+		{"action":"allow","target":"*.example.com"}
+	]}`)
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	noon := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	night := time.Date(2026, 3, 2, 3, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		domain string
+		at     time.Time
+		want   Decision
+	}{
+		{"bad.example.com.", noon, Decision{Action: ActionDeny, Reason: BlockReasonExplicitDeny, Rule: 0, Target: "bad.example.com", Origin: &RuleOrigin{Field: "egress", Index: 0, Line: 2}}},
+		{"unknown.test.", noon, Decision{Action: ActionDeny, Reason: BlockReasonNoMatchingRule, Rule: -1}},
+		{"deb.debian.org.", noon, Decision{Action: ActionDeny, Reason: BlockReasonOutsideWindow, Rule: 1, Target: "deb.debian.org", Origin: &RuleOrigin{Field: "egress", Index: 1, Line: 3}}},
+		{"deb.debian.org.", night, Decision{Action: ActionAllow, Rule: 1, Target: "deb.debian.org", Origin: &RuleOrigin{Field: "egress", Index: 1, Line: 3}}},
+		{"api.example.com.", noon, Decision{Action: ActionAllow, Rule: 2, Target: "*.example.com", Origin: &RuleOrigin{Field: "egress", Index: 2, Line: 4}}},
+	}
+	for _, c := range cases {
+		if got := p.ExplainAt(c.domain, c.at); !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("ExplainAt(%s, %s) = %+v, want %+v", c.domain, c.at.Format(time.Kitchen), got, c.want)
+		}
+		if got := p.EvaluateAt(c.domain, c.at); got != c.want.Action {
+			t.Fatalf("EvaluateAt(%s) = %s, disagrees with ExplainAt", c.domain, got)
+		}
+	}
+
+	var missing *NetworkPolicy
+	if got := missing.Explain("example.com"); got.Reason != BlockReasonNoPolicy || got.Action != ActionDeny {
+		t.Fatalf("expected nil policy to deny with no_policy, got %+v", got)
+	}
+}
+
+func TestExplainAt_ClosedWindowThenDenyRule(t *testing.T) {
+	p, err := ParsePolicy(`{"defaultAction":"allow","egress":[
+		{"action":"allow","target":"*.pypi.org","priority":10,"window":{"start":"09:00","end":"17:00"}},
+		{"action":"deny","target":"*.pypi.org"}
+	]}`)
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	// The deny rule decides, but the name would be allowed inside the window.
+	got := p.ExplainAt("files.pypi.org", time.Date(2026, 3, 2, 20, 0, 0, 0, time.UTC))
+	if got.Action != ActionDeny || got.Reason != BlockReasonOutsideWindow || got.Rule != 0 {
+		t.Fatalf("expected outside_window on rule 0, got %+v", got)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ForwardRule sends queries for Suffix and every name below it to Upstream
+// instead of the default resolver. It does not affect allow/deny decisions.
+type ForwardRule struct {
+	// Suffix is a domain such as "svc.cluster.local"; a leading "*." is accepted
+	// and means the same.
+	Suffix string `json:"suffix"`
+	// Upstream is the resolver address, an IP with an optional port (default 53).
+	Upstream string `json:"upstream"`
+}
+
+// normalize lower-cases the suffix, strips wildcard and dots, and gives the
+// upstream an explicit port.
+func (r *ForwardRule) normalize() error {
+	suffix, err := normalizeSuffix(r.Suffix)
+	if err != nil {
+		return err
+	}
+	upstream := strings.TrimSpace(r.Upstream)
+	host, port, err := net.SplitHostPort(upstream)
+	if err != nil {
+		host, port = strings.Trim(upstream, "[]"), "53"
+	}
+	if net.ParseIP(host) == nil {
+		return fmt.Errorf("upstream %q is not an IP address", r.Upstream)
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return fmt.Errorf("upstream %q has an invalid port", r.Upstream)
+	}
+	r.Suffix = suffix
+	r.Upstream = net.JoinHostPort(host, port)
+	return nil
+}
+
+// matches reports whether domain is Suffix or below it, on label boundaries.
+func (r *ForwardRule) matches(domain string) bool {
+	return domain == r.Suffix || strings.HasSuffix(domain, "."+r.Suffix)
+}
+
+// UpstreamFor returns the upstream of the most specific forward rule covering
+// domain, or "" when the default resolver should answer it. Among rules with
+// the same suffix the first one wins.
+func (p *NetworkPolicy) UpstreamFor(domain string) string {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	best := -1
+	for i := range p.Forward {
+		r := &p.Forward[i]
+		if r.matches(domain) && (best < 0 || len(r.Suffix) > len(p.Forward[best].Suffix)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+	return p.Forward[best].Upstream
+}
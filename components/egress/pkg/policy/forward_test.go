@@ -0,0 +1,57 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import "testing"
+
+func TestUpstreamFor_MostSpecificSuffixWins(t *testing.T) {
+	p, err := ParsePolicy(`{"defaultAction":"allow","forward":[
+		{"suffix":"cluster.local","upstream":"10.96.0.10"},
+		{"suffix":"*.corp.example.com.","upstream":"10.0.0.53:5353"},
+		{"suffix":"eu.corp.example.com","upstream":"[fd00::53]:53"}
+	]}`)
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	cases := map[string]string{
+		"kube-dns.kube-system.svc.cluster.local.": "10.96.0.10:53",
+		"cluster.local":            "10.96.0.10:53",
+		"corp.example.com.":        "10.0.0.53:5353",
+		"git.corp.example.com.":    "10.0.0.53:5353",
+		"Git.EU.corp.example.com.": "[fd00::53]:53",
+		"eu.corp.example.com":      "[fd00::53]:53",
+		"notcluster.local.":        "",
+		"example.com.":             "",
+	}
+	for name, want := range cases {
+		if got := p.UpstreamFor(name); got != want {
+			t.Errorf("UpstreamFor(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestParsePolicy_RejectsInvalidForwardRules(t *testing.T) {
+	for _, raw := range []string{
+		`{"forward":[{"suffix":"","upstream":"10.0.0.1"}]}`,
+		`{"forward":[{"suffix":"*.","upstream":"10.0.0.1"}]}`,
+		`{"forward":[{"suffix":"corp.example.com","upstream":"dns.corp.example.com"}]}`,
+		`{"forward":[{"suffix":"corp.example.com","upstream":""}]}`,
+		`{"forward":[{"suffix":"corp.example.com","upstream":"10.0.0.1:dns"}]}`,
+	} {
+		if _, err := ParsePolicy(raw); err == nil {
+			t.Errorf("expected %s to be rejected", raw)
+		}
+	}
+}
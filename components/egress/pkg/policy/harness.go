@@ -0,0 +1,156 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// TestSuite is a policy together with the decisions it is expected to make,
+// like unit tests for the policy itself.
+type TestSuite struct {
+	// Policy is the policy under test; when empty the caller supplies one,
+	// e.g. the policy currently enforced.
+	Policy json.RawMessage `json:"policy,omitempty"`
+	Cases  []TestCase      `json:"cases"`
+}
+
+// TestCase expects the policy to allow or deny a query.
+type TestCase struct {
+	Name string `json:"name"`
+	// QType is the query type, "A" by default. Policies decide by name, so
+	// every type gets the same decision; it is checked and reported only.
+	QType  string `json:"qtype,omitempty"`
+	Expect string `json:"expect"`
+	// Reason optionally pins why a denied name must be denied.
+	Reason BlockReason `json:"reason,omitempty"`
+	// At evaluates the case at this time instead of now, for windowed rules.
+	At *time.Time `json:"at,omitempty"`
+}
+
+// TestResult is the outcome of one TestCase.
+type TestResult struct {
+	TestCase
+	Passed   bool     `json:"passed"`
+	Decision Decision `json:"decision"`
+	// Message says what did not match; empty when the case passed.
+	Message string `json:"message,omitempty"`
+}
+
+// TestReport collects the results of a test suite.
+type TestReport struct {
+	Passed  int          `json:"passed"`
+	Failed  int          `json:"failed"`
+	Results []TestResult `json:"results"`
+}
+
+// ParseTestSuite parses a suite, either an object with "policy" and "cases"
+// or a bare array of cases, and checks every case.
+func ParseTestSuite(raw []byte) (*TestSuite, error) {
+	var suite TestSuite
+	trimmed := strings.TrimSpace(string(raw))
+	if strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal([]byte(trimmed), &suite.Cases); err != nil {
+			return nil, err
+		}
+	} else if err := json.Unmarshal([]byte(trimmed), &suite); err != nil {
+		return nil, err
+	}
+	if len(suite.Cases) == 0 {
+		return nil, errors.New("test suite has no cases")
+	}
+	for i := range suite.Cases {
+		if err := suite.Cases[i].validate(); err != nil {
+			return nil, fmt.Errorf("cases[%d] (%s): %w", i, suite.Cases[i].Name, err)
+		}
+	}
+	return &suite, nil
+}
+
+func (c *TestCase) validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return errors.New("name is required")
+	}
+	if c.QType == "" {
+		c.QType = "A"
+	}
+	c.QType = strings.ToUpper(c.QType)
+	if _, ok := dns.StringToType[c.QType]; !ok {
+		return fmt.Errorf("unknown qtype %q", c.QType)
+	}
+	c.Expect = strings.ToLower(c.Expect)
+	if c.Expect != ActionAllow && c.Expect != ActionDeny {
+		return fmt.Errorf("expect must be %q or %q", ActionAllow, ActionDeny)
+	}
+	if c.Reason != "" && c.Expect != ActionDeny {
+		return errors.New("reason only applies to denied names")
+	}
+	return nil
+}
+
+// RunTests evaluates every case through Explain, at its At time or now, and
+// reports which ones the policy does not meet.
+func (p *NetworkPolicy) RunTests(cases []TestCase) TestReport {
+	report := TestReport{Results: make([]TestResult, 0, len(cases))}
+	for _, c := range cases {
+		at := now()
+		if c.At != nil {
+			at = *c.At
+		}
+		result := TestResult{TestCase: c, Decision: p.ExplainAt(c.Name, at)}
+		switch {
+		case result.Decision.Action != c.Expect:
+			result.Message = fmt.Sprintf("expected %s, got %s", c.Expect, result.Decision.Action)
+		case c.Reason != "" && result.Decision.Reason != c.Reason:
+			result.Message = fmt.Sprintf("expected reason %s, got %s", c.Reason, result.Decision.Reason)
+		default:
+			result.Passed = true
+		}
+		if !result.Passed {
+			result.Message += " (" + result.Decision.describe() + ")"
+			report.Failed++
+		} else {
+			report.Passed++
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+// describe names the rule behind the decision for failure messages.
+func (d Decision) describe() string {
+	var s string
+	switch {
+	case d.Reason == BlockReasonBlockedSuffix:
+		s = "blocked suffix " + d.Target
+	case d.Rule >= 0:
+		s = "rule " + d.Target
+	default:
+		s = "default action"
+	}
+	if d.Reason != "" && d.Reason != BlockReasonBlockedSuffix {
+		s += ", " + string(d.Reason)
+	}
+	if d.Origin != nil {
+		s += " at " + d.Origin.String()
+	}
+	return s
+}
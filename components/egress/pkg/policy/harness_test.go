@@ -0,0 +1,76 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunTests_ReportsMismatches(t *testing.T) {
+	p, err := ParsePolicyFrom("policy.json", `{"defaultAction":"deny","egress":[
+		{"action":"allow","target":"*.example.com"},
+		{"action":"deny","target":"admin.example.com"},
+		{"action":"allow","target":"deb.debian.org","window":{"start":"02:00","end":"04:00"}}
+	]}`)
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	suite, err := ParseTestSuite([]byte(`[
+		{"name":"api.example.com","expect":"allow"},
+		{"name":"admin.example.com","qtype":"aaaa","expect":"allow"},
+		{"name":"other.test","expect":"deny","reason":"explicit_deny"},
+		{"name":"deb.debian.org","expect":"allow","at":"2026-03-02T03:00:00Z"},
+		{"name":"deb.debian.org","expect":"deny","reason":"outside_window","at":"2026-03-02T12:00:00Z"}
+	]`))
+	if err != nil {
+		t.Fatalf("parse suite: %v", err)
+	}
+
+	report := p.RunTests(suite.Cases)
+	if report.Passed != 3 || report.Failed != 2 || len(report.Results) != 5 {
+		t.Fatalf("unexpected totals: %+v", report)
+	}
+	if r := report.Results[1]; r.Passed || r.QType != "AAAA" ||
+		r.Message != "expected allow, got deny (rule admin.example.com, explicit_deny at policy.json egress[1] line 3)" {
+		t.Fatalf("unexpected result for the denied name: %+v", r)
+	}
+	if r := report.Results[2]; r.Passed || r.Message != "expected reason explicit_deny, got no_matching_rule (default action, no_matching_rule)" {
+		t.Fatalf("unexpected result for the reason mismatch: %+v", r)
+	}
+	if !report.Results[3].Passed || !report.Results[4].Passed {
+		t.Fatalf("expected windowed cases to be evaluated at their time: %+v", report.Results[3:])
+	}
+}
+
+func TestParseTestSuite_RejectsBadCases(t *testing.T) {
+	cases := map[string]string{
+		`{"cases":[]}`:                                                  "no cases",
+		`[{"expect":"allow"}]`:                                          "name is required",
+		`[{"name":"a.test","expect":"maybe"}]`:                          "expect must be",
+		`[{"name":"a.test","qtype":"NOPE","expect":"deny"}]`:            "unknown qtype",
+		`[{"name":"a.test","expect":"allow","reason":"explicit_deny"}]`: "reason only applies",
+	}
+	for raw, want := range cases {
+		if _, err := ParseTestSuite([]byte(raw)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("ParseTestSuite(%s) = %v, want an error containing %q", raw, err, want)
+		}
+	}
+
+	suite, err := ParseTestSuite([]byte(`{"policy":{"defaultAction":"allow"},"cases":[{"name":"a.test","expect":"ALLOW"}]}`))
+	if err != nil || string(suite.Policy) != `{"defaultAction":"allow"}` || suite.Cases[0].Expect != ActionAllow || suite.Cases[0].QType != "A" {
+		t.Fatalf("unexpected suite: %+v, %v", suite, err)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"sort"
+	"strings"
+)
+
+// ruleIndex finds the rules matching a name without scanning Egress. Targets
+// are stored in a trie keyed by labels from the right, so "api.example.com"
+// lives at com -> example -> api, and a lookup walks one node per label of
+// the queried name.
+type ruleIndex struct {
+	root *labelNode
+	// rank maps an Egress index to its position in evaluation order.
+	rank []int
+}
+
+type labelNode struct {
+	children map[string]*labelNode
+	// exact holds rules whose target is this node's name.
+	exact []int
+	// wildcard holds "*.<name>" rules, which match names strictly below.
+	wildcard []int
+}
+
+// buildIndex indexes rules by target; order is their evaluation order.
+func buildIndex(rules []EgressRule, order []int) *ruleIndex {
+	idx := &ruleIndex{root: &labelNode{}, rank: make([]int, len(rules))}
+	for pos, i := range order {
+		idx.rank[i] = pos
+	}
+	for i := range rules {
+		pattern := strings.ToLower(strings.TrimSpace(rules[i].Target))
+		if pattern == "" {
+			continue
+		}
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			// A bare "*." never matches a name without a trailing dot.
+			if suffix != "" {
+				node := idx.root.insert(suffix)
+				node.wildcard = append(node.wildcard, i)
+			}
+			continue
+		}
+		node := idx.root.insert(pattern)
+		node.exact = append(node.exact, i)
+	}
+	return idx
+}
+
+// insert returns the node for name, creating the path to it.
+func (n *labelNode) insert(name string) *labelNode {
+	for {
+		label, rest := lastLabel(name)
+		child := n.children[label]
+		if child == nil {
+			if n.children == nil {
+				n.children = make(map[string]*labelNode)
+			}
+			child = &labelNode{}
+			n.children[label] = child
+		}
+		if !strings.Contains(name, ".") {
+			return child
+		}
+		n, name = child, rest
+	}
+}
+
+// lastLabel splits "a.b.c" into "c" and "a.b".
+func lastLabel(name string) (label, rest string) {
+	i := strings.LastIndexByte(name, '.')
+	if i < 0 {
+		return name, ""
+	}
+	return name[i+1:], name[:i]
+}
+
+// lookup returns the indexes of the rules matching domain (lower-cased, no
+// trailing dot), ordered by precedence.
+func (idx *ruleIndex) lookup(domain string) []int {
+	var matched []int
+	node := idx.root
+	for rest := domain; ; {
+		label, next := lastLabel(rest)
+		node = node.children[label]
+		if node == nil {
+			break
+		}
+		if !strings.Contains(rest, ".") {
+			matched = append(matched, node.exact...)
+			break
+		}
+		matched = append(matched, node.wildcard...)
+		rest = next
+	}
+	if len(matched) > 1 {
+		sort.Slice(matched, func(a, b int) bool { return idx.rank[matched[a]] < idx.rank[matched[b]] })
+	}
+	return matched
+}
+
+// matchingRules returns the indexes of the rules matching domain in
+// evaluation order. Policies built by ParsePolicy use the index; others fall
+// back to testing every rule.
+func (p *NetworkPolicy) matchingRules(domain string) []int {
+	if p.index != nil && len(p.index.rank) == len(p.Egress) {
+		return p.index.lookup(domain)
+	}
+	var matched []int
+	for _, i := range p.evaluationOrder() {
+		if p.Egress[i].matchesDomain(domain) {
+			matched = append(matched, i)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,133 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// linearMatches is the reference matcher the index must agree with.
+func linearMatches(p *NetworkPolicy, domain string) []int {
+	var matched []int
+	for _, i := range p.evaluationOrder() {
+		if p.Egress[i].matchesDomain(domain) {
+			matched = append(matched, i)
+		}
+	}
+	return matched
+}
+
+func TestRuleIndex_AgreesWithLinearScan(t *testing.T) {
+	rules := []EgressRule{
+		{Action: ActionAllow, Target: "example.com"},
+		{Action: ActionDeny, Target: "*.example.com"},
+		{Action: ActionAllow, Target: " API.Example.com "},
+		{Action: ActionAllow, Target: "*.api.example.com", Priority: 5},
+		{Action: ActionDeny, Target: "*.example.com", Priority: 5},
+		{Action: ActionAllow, Target: "*."},
+		{Action: ActionAllow, Target: "*"},
+		{Action: ActionAllow, Target: ""},
+		{Action: ActionAllow, Target: "com"},
+		{Action: ActionDeny, Target: "*.com"},
+		{Action: ActionAllow, Target: "*.*.example.com"},
+		{Action: ActionAllow, Target: "example.com."},
+	}
+	p := policyWithRules(t, rules)
+	domains := []string{
+		"example.com", "a.example.com", "api.example.com", "v1.api.example.com",
+		"x.y.api.example.com", "com", "org", "example.org", "*.example.com",
+		"a.*.example.com", "*", "", "notexample.com", "example.com.evil.net",
+	}
+	for _, d := range domains {
+		if got, want := p.matchingRules(d), linearMatches(p, d); !reflect.DeepEqual(got, want) {
+			t.Fatalf("matchingRules(%q) = %v, linear scan = %v", d, got, want)
+		}
+	}
+}
+
+func TestRuleIndex_RandomPolicies(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	labels := []string{"a", "b", "example", "com", "*"}
+	name := func() string {
+		parts := make([]string, 1+rng.Intn(4))
+		for i := range parts {
+			parts[i] = labels[rng.Intn(len(labels))]
+		}
+		return strings.Join(parts, ".")
+	}
+	for round := 0; round < 200; round++ {
+		rules := make([]EgressRule, 1+rng.Intn(20))
+		for i := range rules {
+			rules[i] = EgressRule{Action: ActionAllow, Target: name(), Priority: rng.Intn(3)}
+			if rng.Intn(2) == 0 {
+				rules[i].Action = ActionDeny
+			}
+		}
+		p := policyWithRules(t, rules)
+		for q := 0; q < 20; q++ {
+			d := name()
+			if got, want := p.matchingRules(d), linearMatches(p, d); !reflect.DeepEqual(got, want) {
+				t.Fatalf("round %d: matchingRules(%q) = %v, linear scan = %v, rules %+v", round, d, got, want, rules)
+			}
+		}
+	}
+}
+
+func TestRuleIndex_LiteralPolicyFallsBack(t *testing.T) {
+	p := &NetworkPolicy{DefaultAction: ActionDeny, Egress: []EgressRule{{Action: ActionAllow, Target: "*.example.com"}}}
+	if got := p.Evaluate("a.example.com"); got != ActionAllow {
+		t.Fatalf("expected allow without an index, got %s", got)
+	}
+	parsed := policyWithRules(t, p.Egress)
+	parsed.Egress = append(parsed.Egress, EgressRule{Action: ActionDeny, Target: "a.example.com"})
+	if got := parsed.Evaluate("a.example.com"); got != ActionDeny {
+		t.Fatalf("expected rules appended after parsing to be honored, got %s", got)
+	}
+}
+
+// largePolicy returns a policy of n exact rules and n wildcard rules under
+// distinct domains.
+func largePolicy(b *testing.B, n int) *NetworkPolicy {
+	rules := make([]EgressRule, 0, 2*n)
+	for i := 0; i < n; i++ {
+		rules = append(rules,
+			EgressRule{Action: ActionAllow, Target: fmt.Sprintf("api%d.vendor%d.com", i, i%997)},
+			EgressRule{Action: ActionDeny, Target: fmt.Sprintf("*.tracker%d.net", i)},
+		)
+	}
+	return policyWithRules(b, rules)
+}
+
+// BenchmarkEvaluate shows per-query cost staying flat as the policy grows.
+func BenchmarkEvaluate(b *testing.B) {
+	for _, n := range []int{100, 10_000, 50_000} {
+		p := largePolicy(b, n)
+		domains := []string{
+			fmt.Sprintf("api%d.vendor%d.com", n/2, (n/2)%997),
+			fmt.Sprintf("pixel.cdn.tracker%d.net", n-1),
+			"unknown.example.org",
+		}
+		b.Run(fmt.Sprintf("rules=%d", 2*n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				p.Evaluate(domains[i%len(domains)])
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"sort"
+	"strings"
+)
+
+// evaluationOrder returns Egress indexes sorted by precedence, highest first:
+//
+//  1. higher Priority;
+//  2. more specific target: exact names before wildcards, and longer
+//     wildcard suffixes before shorter ones;
+//  3. deny before allow;
+//  4. earlier position in Egress.
+//
+// The result depends only on the rules, so two policies listing the same
+// rules in a different order evaluate identically unless rules tie on all
+// of the above.
+func (p *NetworkPolicy) evaluationOrder() []int {
+	if len(p.order) == len(p.Egress) {
+		return p.order
+	}
+	order := make([]int, len(p.Egress))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ra, rb := &p.Egress[order[a]], &p.Egress[order[b]]
+		if ra.Priority != rb.Priority {
+			return ra.Priority > rb.Priority
+		}
+		if sa, sb := ra.specificity(), rb.specificity(); sa != sb {
+			return sa > sb
+		}
+		return ra.isDeny() && !rb.isDeny()
+	})
+	return order
+}
+
+// specificity ranks how narrowly a target matches: exact names score above
+// any wildcard, and wildcards score by the length of their fixed suffix.
+func (r *EgressRule) specificity() int {
+	pattern := strings.ToLower(strings.TrimSpace(r.Target))
+	if strings.HasPrefix(pattern, "*.") {
+		return len(pattern) - 1
+	}
+	// 253 is the longest DNS name, so exact targets always outrank wildcards.
+	return 256 + len(pattern)
+}
+
+// isDeny treats an empty action as deny, matching evaluation.
+func (r *EgressRule) isDeny() bool {
+	return r.Action != ActionAllow
+}
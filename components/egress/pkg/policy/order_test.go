@@ -0,0 +1,103 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestExplainAt_PrecedenceAcrossRuleTypes(t *testing.T) {
+	rules := []EgressRule{
+		{Action: ActionAllow, Target: "api.example.com"},
+		{Action: ActionDeny, Target: "*.example.com"},
+		{Action: ActionAllow, Target: "*.cdn.example.com"},
+		{Action: ActionAllow, Target: "mirror.example.com"},
+		{Action: ActionDeny, Target: "mirror.example.com"},
+		{Action: ActionDeny, Target: "*.internal.example.com", Priority: 10},
+		{Action: ActionAllow, Target: "ci.internal.example.com"},
+		{Action: ActionAllow, Target: "*.pkg.example.com", Priority: 20, Window: &TimeWindow{Start: "02:00", End: "04:00"}},
+	}
+	night := time.Date(2026, 5, 4, 3, 0, 0, 0, time.UTC)
+	noon := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		domain string
+		at     time.Time
+		action string
+		target string
+	}{
+		// exact beats wildcard at the same priority
+		{"api.example.com", noon, ActionAllow, "api.example.com"},
+		{"www.example.com", noon, ActionDeny, "*.example.com"},
+		// the longer wildcard suffix is more specific
+		{"img.cdn.example.com", noon, ActionAllow, "*.cdn.example.com"},
+		// deny beats allow for the same target and priority
+		{"mirror.example.com", noon, ActionDeny, "mirror.example.com"},
+		// a higher priority wildcard beats a lower priority exact name
+		{"ci.internal.example.com", noon, ActionDeny, "*.internal.example.com"},
+		// a high priority windowed rule only wins while its window is open
+		{"deb.pkg.example.com", night, ActionAllow, "*.pkg.example.com"},
+		{"deb.pkg.example.com", noon, ActionDeny, "*.pkg.example.com"},
+	}
+
+	forward := policyWithRules(t, rules)
+	reversed := make([]EgressRule, len(rules))
+	for i, r := range rules {
+		reversed[len(rules)-1-i] = r
+	}
+	backward := policyWithRules(t, reversed)
+
+	for _, c := range cases {
+		for name, p := range map[string]*NetworkPolicy{"forward": forward, "reversed": backward} {
+			got := p.ExplainAt(c.domain, c.at)
+			if got.Action != c.action || got.Target != c.target {
+				t.Fatalf("%s: %s at %s = %s via %q, want %s via %q",
+					name, c.domain, c.at.Format(time.Kitchen), got.Action, got.Target, c.action, c.target)
+			}
+		}
+	}
+
+	if got := forward.ExplainAt("ci.internal.example.com", noon); got.Priority != 10 || got.Rule != 5 {
+		t.Fatalf("expected explain to report the winning rule's priority and index, got %+v", got)
+	}
+}
+
+func TestEvaluationOrder_UnparsedPolicy(t *testing.T) {
+	p := &NetworkPolicy{
+		DefaultAction: ActionAllow,
+		Egress: []EgressRule{
+			{Action: ActionAllow, Target: "*.example.com"},
+			{Action: ActionDeny, Target: "bad.example.com"},
+		},
+	}
+	if got := p.Evaluate("bad.example.com"); got != ActionDeny {
+		t.Fatalf("expected precedence without ParsePolicy, got %s", got)
+	}
+}
+
+func policyWithRules(t testing.TB, rules []EgressRule) *NetworkPolicy {
+	t.Helper()
+	raw, err := json.Marshal(NetworkPolicy{DefaultAction: ActionDeny, Egress: rules})
+	if err != nil {
+		t.Fatalf("marshal policy: %v", err)
+	}
+	p, err := ParsePolicy(string(raw))
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	return p
+}
@@ -0,0 +1,142 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// OriginFieldEgress locates a rule in a policy's egress list.
+	OriginFieldEgress = "egress"
+	// OriginFieldBlock locates an entry in a policy's block list.
+	OriginFieldBlock = "block"
+)
+
+// RuleOrigin says where the rule behind a decision was written.
+type RuleOrigin struct {
+	// Source names the document the policy was parsed from, e.g.
+	// "env:OPENSANDBOX_EGRESS_RULES" or "api"; empty when unnamed.
+	Source string `json:"source,omitempty"`
+	// Field and Index locate the rule in that document, e.g. egress[3].
+	Field string `json:"field"`
+	Index int    `json:"index"`
+	// Line is the 1-based line the rule starts on, 0 when unknown.
+	Line int `json:"line,omitempty"`
+}
+
+func (o RuleOrigin) String() string {
+	s := fmt.Sprintf("%s[%d]", o.Field, o.Index)
+	if o.Line > 0 {
+		s += fmt.Sprintf(" line %d", o.Line)
+	}
+	if o.Source != "" {
+		s = o.Source + " " + s
+	}
+	return s
+}
+
+// ParsePolicyFrom is ParsePolicy for a policy read from source, which is
+// recorded in the origin of every decision the policy makes.
+func ParsePolicyFrom(source, raw string) (*NetworkPolicy, error) {
+	p, err := ParsePolicy(raw)
+	if err != nil {
+		return nil, err
+	}
+	p.source = source
+	return p, nil
+}
+
+// Source is the name the policy was parsed under, see ParsePolicyFrom.
+func (p *NetworkPolicy) Source() string {
+	if p == nil {
+		return ""
+	}
+	return p.source
+}
+
+// origin locates entry i of field; lines are filled in when ParsePolicy
+// could map the entry back to the document.
+func (p *NetworkPolicy) origin(field string, i int) *RuleOrigin {
+	o := &RuleOrigin{Source: p.source, Field: field, Index: i}
+	if lines := p.lines[field]; i < len(lines) {
+		o.Line = lines[i]
+	}
+	return o
+}
+
+// ruleLines maps each element of the top-level egress and block arrays of raw
+// to the line it starts on. It expects raw to be valid JSON and gives up
+// silently, returning what it has, on anything else.
+func ruleLines(raw string) map[string][]int {
+	dec := json.NewDecoder(strings.NewReader(raw))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil
+	}
+	lines := make(map[string][]int)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return lines
+		}
+		key, _ := tok.(string)
+		field := ""
+		// encoding/json matches field names case-insensitively, so do the same
+		for _, f := range []string{OriginFieldEgress, OriginFieldBlock} {
+			if strings.EqualFold(key, f) {
+				field = f
+			}
+		}
+		if field == "" {
+			var skip json.RawMessage
+			if dec.Decode(&skip) != nil {
+				return lines
+			}
+			continue
+		}
+		tok, err = dec.Token()
+		if err != nil {
+			return lines
+		}
+		// a repeated key replaces the earlier list, as in json.Unmarshal
+		lines[field] = nil
+		if tok != json.Delim('[') {
+			continue // null
+		}
+		for dec.More() {
+			start := int(dec.InputOffset())
+			var skip json.RawMessage
+			if dec.Decode(&skip) != nil {
+				return lines
+			}
+			lines[field] = append(lines[field], lineAt(raw, start))
+		}
+		if _, err := dec.Token(); err != nil {
+			return lines
+		}
+	}
+	return lines
+}
+
+// lineAt is the 1-based line of the first value byte at or after off, which
+// may point at the separator before it.
+func lineAt(raw string, off int) int {
+	for off < len(raw) && strings.IndexByte(" \t\r\n,", raw[off]) >= 0 {
+		off++
+	}
+	return 1 + strings.Count(raw[:off], "\n")
+}
@@ -0,0 +1,96 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExplain_OriginNamesSourceAndLine(t *testing.T) {
+	const raw = `
+{
+  "defaultAction": "deny",
+  "forward": [{"suffix": "corp.example.com", "upstream": "10.0.0.53"}],
+  "egress": [
+    {"action": "allow", "target": "*.example.com"},
+    {
+      "action": "deny",
+      "target": "secret.example.com"
+    }, {"action": "allow", "target": "pypi.org"}
+  ],
+  "block": ["ads.example.com",
+    "*.tracker.test"]
+}`
+	env, err := ParsePolicyFrom("env:OPENSANDBOX_EGRESS_RULES", raw)
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	api, err := ParsePolicyFrom("api", raw)
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+
+	cases := map[string]*RuleOrigin{
+		"www.example.com":    {Field: "egress", Index: 0, Line: 6},
+		"secret.example.com": {Field: "egress", Index: 1, Line: 7},
+		"pypi.org":           {Field: "egress", Index: 2, Line: 10},
+		"x.ads.example.com":  {Field: "block", Index: 0, Line: 12},
+		"cdn.tracker.test":   {Field: "block", Index: 1, Line: 13},
+		"unmatched.invalid":  nil,
+	}
+	for name, want := range cases {
+		for _, p := range []*NetworkPolicy{env, api} {
+			got := p.Explain(name).Origin
+			if want == nil {
+				if got != nil {
+					t.Fatalf("%s: default action has origin %v", name, got)
+				}
+				continue
+			}
+			w := *want
+			w.Source = p.Source()
+			if !reflect.DeepEqual(got, &w) {
+				t.Fatalf("%s: origin %v, want %v", name, got, &w)
+			}
+		}
+	}
+	if got := env.Explain("pypi.org").Origin.String(); got != "env:OPENSANDBOX_EGRESS_RULES egress[2] line 10" {
+		t.Fatalf("unexpected origin string %q", got)
+	}
+}
+
+func TestRuleLines_FollowsJSONFieldMatching(t *testing.T) {
+	p, err := ParsePolicy(`{"Egress":[{"action":"deny","target":"a.test"}],
+"egress":[
+{"action":"allow","target":"b.test"}],
+"BLOCK":null}`)
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	// json.Unmarshal keeps the last of the keys matching Egress, and so must the lines
+	if len(p.Egress) != 1 || p.Egress[0].Target != "b.test" {
+		t.Fatalf("unexpected egress %+v", p.Egress)
+	}
+	if got := p.Explain("b.test").Origin; got == nil || got.Line != 3 {
+		t.Fatalf("expected line 3, got %v", got)
+	}
+
+	// a policy built in code has origins without lines or source
+	built := &NetworkPolicy{DefaultAction: ActionDeny, Egress: []EgressRule{{Action: ActionAllow, Target: "c.test"}}}
+	if got := built.Explain("c.test").Origin; got == nil || got.String() != "egress[0]" {
+		t.Fatalf("expected a bare index, got %v", got)
+	}
+}
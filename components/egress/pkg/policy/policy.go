@@ -16,7 +16,9 @@ package policy
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
+	"time"
 )
 
 const (
@@ -34,11 +36,34 @@ func DefaultDenyPolicy() *NetworkPolicy {
 type NetworkPolicy struct {
 	Egress        []EgressRule `json:"egress"`
 	DefaultAction string       `json:"defaultAction"`
+	// Forward routes allowed queries under a domain suffix to a dedicated
+	// upstream, e.g. cluster-internal zones to CoreDNS.
+	Forward []ForwardRule `json:"forward,omitempty"`
+	// Block lists domain suffixes that are denied together with every name
+	// below them, whatever the egress rules say; "ads.example" and
+	// "*.ads.example" both block ads.example and x.ads.example.
+	Block []string `json:"block,omitempty"`
+
+	// order holds Egress indexes in evaluation order, filled by ParsePolicy.
+	order []int
+	// index finds the rules matching a name, filled by ParsePolicy.
+	index *ruleIndex
+	// blocks indexes Block, filled by ParsePolicy.
+	blocks *blockSet
+	// source and lines locate rules in the parsed document, see RuleOrigin.
+	source string
+	lines  map[string][]int
 }
 
 type EgressRule struct {
 	Action string `json:"action"`
 	Target string `json:"target"`
+	// Window optionally limits the rule to a recurring time window; outside
+	// it the rule is skipped and evaluation falls through to later rules.
+	Window *TimeWindow `json:"window,omitempty"`
+	// Priority ranks the rule against others matching the same name; higher
+	// wins. Rules default to 0, see evaluationOrder for tie-breaking.
+	Priority int `json:"priority,omitempty"`
 }
 
 // ParsePolicy parses JSON from env/config into a NetworkPolicy.
@@ -53,27 +78,36 @@ func ParsePolicy(raw string) (*NetworkPolicy, error) {
 	if err := json.Unmarshal([]byte(trimmed), &p); err != nil {
 		return nil, err
 	}
-	return ensureDefaults(&p), nil
-}
-
-// Evaluate returns allow/deny for a given domain (lowercased).
-func (p *NetworkPolicy) Evaluate(domain string) string {
-	if p == nil {
-		return ActionDeny
-	}
-	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
-	for _, r := range p.Egress {
-		if r.matchesDomain(domain) {
-			if r.Action == "" {
-				return ActionDeny
+	for i := range p.Egress {
+		if w := p.Egress[i].Window; w != nil {
+			if err := w.validate(); err != nil {
+				return nil, fmt.Errorf("egress[%d] (%s): %w", i, p.Egress[i].Target, err)
 			}
-			return r.Action
 		}
 	}
-	if p.DefaultAction == "" {
-		return ActionDeny
+	for i := range p.Forward {
+		if err := p.Forward[i].normalize(); err != nil {
+			return nil, fmt.Errorf("forward[%d] (%s): %w", i, p.Forward[i].Suffix, err)
+		}
 	}
-	return p.DefaultAction
+	if err := p.buildBlockSet(); err != nil {
+		return nil, err
+	}
+	p.order = p.evaluationOrder()
+	p.index = buildIndex(p.Egress, p.order)
+	p.lines = ruleLines(raw)
+	return ensureDefaults(&p), nil
+}
+
+// Evaluate returns allow/deny for a given domain (lowercased) at the current time.
+func (p *NetworkPolicy) Evaluate(domain string) string {
+	return p.EvaluateAt(domain, now())
+}
+
+// EvaluateAt returns allow/deny for a given domain as of t; rules whose
+// window does not contain t are ignored.
+func (p *NetworkPolicy) EvaluateAt(domain string, t time.Time) string {
+	return p.ExplainAt(domain, t).Action
 }
 
 // ensureDefaults guarantees a policy always has a default action.
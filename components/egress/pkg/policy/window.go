@@ -0,0 +1,144 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	// Embed the zone database so windows resolve on slim images without tzdata.
+	_ "time/tzdata"
+)
+
+// now is the clock used by Evaluate; tests replace it to cross window edges.
+var now = time.Now
+
+// TimeWindow restricts a rule to a recurring daily wall-clock interval.
+//
+// Start and End are "HH:MM" in Timezone (IANA name, default UTC). The window
+// is half-open: Start is inside, End is not. When End is earlier than Start
+// the window wraps past midnight and the early-morning part belongs to the
+// day the window opened, which is what Days (e.g. ["mon","fri"]) filters on.
+//
+// Times are compared on the local wall clock, so across DST changes a window
+// keeps its nominal hours: a spring-forward gap inside the window shortens it
+// and a fall-back overlap lengthens it by the repeated hour.
+type TimeWindow struct {
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	Timezone string   `json:"timezone,omitempty"`
+	Days     []string `json:"days,omitempty"`
+
+	compiled *compiledWindow
+}
+
+type compiledWindow struct {
+	start, end int // minutes since local midnight
+	loc        *time.Location
+	days       map[time.Weekday]bool // nil means every day
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Contains reports whether t falls inside the window. A window that fails to
+// compile is treated as closed so a malformed entry never widens egress.
+func (w *TimeWindow) Contains(t time.Time) bool {
+	cw := w.compiled
+	if cw == nil {
+		var err error
+		if cw, err = w.compile(); err != nil {
+			return false
+		}
+	}
+
+	local := t.In(cw.loc)
+	minute := local.Hour()*60 + local.Minute()
+	day := local.Weekday()
+
+	if cw.start < cw.end {
+		return minute >= cw.start && minute < cw.end && cw.allows(day)
+	}
+	if minute >= cw.start {
+		return cw.allows(day)
+	}
+	if minute < cw.end {
+		return cw.allows((day + 6) % 7)
+	}
+	return false
+}
+
+func (w *TimeWindow) validate() error {
+	cw, err := w.compile()
+	if err != nil {
+		return err
+	}
+	w.compiled = cw
+	return nil
+}
+
+func (w *TimeWindow) compile() (*compiledWindow, error) {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return nil, fmt.Errorf("window start: %w", err)
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return nil, fmt.Errorf("window end: %w", err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("window start and end must differ (%s)", w.Start)
+	}
+
+	loc := time.UTC
+	if tz := strings.TrimSpace(w.Timezone); tz != "" {
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("window timezone %q: %w", tz, err)
+		}
+	}
+
+	var days map[time.Weekday]bool
+	if len(w.Days) > 0 {
+		days = make(map[time.Weekday]bool, len(w.Days))
+		for _, d := range w.Days {
+			wd, ok := weekdays[strings.ToLower(strings.TrimSpace(d))]
+			if !ok {
+				return nil, fmt.Errorf("window day %q is not one of sun, mon, tue, wed, thu, fri, sat", d)
+			}
+			days[wd] = true
+		}
+	}
+
+	return &compiledWindow{start: start, end: end, loc: loc, days: days}, nil
+}
+
+func (cw *compiledWindow) allows(day time.Weekday) bool {
+	return cw.days == nil || cw.days[day]
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.t }
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func useFakeClock(t *testing.T, start time.Time) *fakeClock {
+	t.Helper()
+	clock := &fakeClock{t: start}
+	prev := now
+	now = clock.Now
+	t.Cleanup(func() { now = prev })
+	return clock
+}
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("load location %s: %v", name, err)
+	}
+	return loc
+}
+
+func TestEvaluate_WindowCrossesEdge(t *testing.T) {
+	p, err := ParsePolicy(`{"defaultAction":"deny","egress":[
+		{"action":"allow","target":"deb.debian.org","window":{"start":"02:00","end":"04:00","timezone":"Asia/Shanghai"}}
+	]}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	shanghai := mustLocation(t, "Asia/Shanghai")
+	clock := useFakeClock(t, time.Date(2026, 3, 2, 1, 59, 0, 0, shanghai))
+
+	steps := []struct {
+		advance time.Duration
+		want    string
+	}{
+		{0, ActionDeny},                  // 01:59, before the window
+		{time.Minute, ActionAllow},       // 02:00, start is inclusive
+		{119 * time.Minute, ActionAllow}, // 03:59
+		{time.Minute, ActionDeny},        // 04:00, end is exclusive
+	}
+	for i, s := range steps {
+		clock.Advance(s.advance)
+		if got := p.Evaluate("deb.debian.org."); got != s.want {
+			t.Fatalf("step %d at %s: got %s, want %s", i, clock.t.In(shanghai).Format(time.Kitchen), got, s.want)
+		}
+	}
+
+	// The same instant expressed in UTC must give the same answer.
+	if got := p.EvaluateAt("deb.debian.org", time.Date(2026, 3, 1, 18, 30, 0, 0, time.UTC)); got != ActionAllow {
+		t.Fatalf("expected allow at 02:30 Shanghai given in UTC, got %s", got)
+	}
+}
+
+func TestEvaluate_WindowFallsThroughOutsideWindow(t *testing.T) {
+	p, err := ParsePolicy(`{"defaultAction":"deny","egress":[
+		{"action":"deny","target":"*.pypi.org","window":{"start":"09:00","end":"17:00"}},
+		{"action":"allow","target":"*.pypi.org"}
+	]}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	day := time.Date(2026, 5, 4, 0, 0, 0, 0, time.UTC)
+	if got := p.EvaluateAt("files.pypi.org", day.Add(10*time.Hour)); got != ActionDeny {
+		t.Fatalf("expected windowed deny to apply inside window, got %s", got)
+	}
+	if got := p.EvaluateAt("files.pypi.org", day.Add(18*time.Hour)); got != ActionAllow {
+		t.Fatalf("expected fallthrough to allow outside window, got %s", got)
+	}
+}
+
+func TestTimeWindow_WrapsMidnightWithDays(t *testing.T) {
+	w := &TimeWindow{Start: "22:00", End: "02:00", Days: []string{"fri"}}
+	if err := w.validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fri := time.Date(2026, 5, 8, 0, 0, 0, 0, time.UTC) // a Friday
+	cases := []struct {
+		at   time.Time
+		want bool
+	}{
+		{fri.Add(21*time.Hour + 59*time.Minute), false},
+		{fri.Add(22 * time.Hour), true},
+		{fri.Add(25 * time.Hour), true},   // Saturday 01:00 belongs to Friday's window
+		{fri.Add(26 * time.Hour), false},  // Saturday 02:00
+		{fri.Add(-23 * time.Hour), false}, // Thursday 01:00 belongs to Wednesday's window
+		{fri.Add(23*time.Hour).AddDate(0, 0, 1), false},
+	}
+	for _, c := range cases {
+		if got := w.Contains(c.at); got != c.want {
+			t.Fatalf("Contains(%s) = %v, want %v", c.at.Format(time.RFC1123), got, c.want)
+		}
+	}
+}
+
+func TestTimeWindow_DSTKeepsWallClockHours(t *testing.T) {
+	ny := mustLocation(t, "America/New_York")
+	w := &TimeWindow{Start: "01:00", End: "04:00", Timezone: "America/New_York"}
+	if err := w.validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Spring forward (2026-03-08): 02:00 jumps to 03:00, so the window lasts two hours.
+	open := time.Date(2026, 3, 8, 1, 0, 0, 0, ny)
+	if !w.Contains(open) || !w.Contains(open.Add(119*time.Minute)) {
+		t.Fatalf("expected window open for two real hours on spring-forward day")
+	}
+	if w.Contains(open.Add(2 * time.Hour)) {
+		t.Fatalf("expected window closed at 04:00 EDT on spring-forward day")
+	}
+
+	// Fall back (2026-11-01): 01:00-02:00 repeats, so the window lasts four hours.
+	open = time.Date(2026, 11, 1, 1, 0, 0, 0, ny)
+	if !w.Contains(open.Add(239 * time.Minute)) {
+		t.Fatalf("expected window still open 3h59m later on fall-back day")
+	}
+	if w.Contains(open.Add(4 * time.Hour)) {
+		t.Fatalf("expected window closed four real hours after opening on fall-back day")
+	}
+}
+
+func TestParsePolicy_RejectsInvalidWindow(t *testing.T) {
+	cases := map[string]string{
+		"bad start":    `{"egress":[{"action":"allow","target":"a.com","window":{"start":"25:00","end":"01:00"}}]}`,
+		"empty window": `{"egress":[{"action":"allow","target":"a.com","window":{"start":"01:00","end":"01:00"}}]}`,
+		"bad timezone": `{"egress":[{"action":"allow","target":"a.com","window":{"start":"01:00","end":"02:00","timezone":"Mars/Olympus"}}]}`,
+		"bad day":      `{"egress":[{"action":"allow","target":"a.com","window":{"start":"01:00","end":"02:00","days":["funday"]}}]}`,
+	}
+	for name, raw := range cases {
+		if _, err := ParsePolicy(raw); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/alibaba/opensandbox/egress/pkg/dnsproxy"
+	"github.com/alibaba/opensandbox/egress/pkg/iptables"
 	"github.com/alibaba/opensandbox/egress/pkg/policy"
 )
 
@@ -34,14 +35,35 @@ import (
 // Supported endpoints:
 //   - GET  /policy : returns the currently enforced policy.
 //   - POST /policy : replace the policy; empty body resets to default deny-all.
-func startPolicyServer(ctx context.Context, proxy *dnsproxy.Proxy, addr string, token string) error {
+//   - GET  /policy/explain?domain=x : the decision for x and the rule that made it.
+//   - POST /policy/test : run a policy test suite against its own policy or the current one.
+//   - GET  /blocks : blocked query counts by reason.
+//   - GET  /metrics : answer cache size, hits, misses and evictions, and bytes
+//     sent per domain; the latter in the Prometheus text format when asked for.
+//   - GET  /readyz : 200 once DNS goes through the proxy, 503 before and on shutdown.
+//
+// enforcer, when not nil, follows the default action of every new policy.
+// byteMeter, when not nil, is reported on /metrics.
+func startPolicyServer(ctx context.Context, proxy *dnsproxy.Proxy, enforcer *iptables.Enforcer, byteMeter *iptables.ByteMeter, ready *readiness, addr string, token string) error {
 	mux := http.NewServeMux()
-	handler := &policyServer{proxy: proxy, token: token}
+	handler := &policyServer{proxy: proxy, enforcer: enforcer, byteMeter: byteMeter, token: token}
 	mux.HandleFunc("/policy", handler.handlePolicy)
+	mux.HandleFunc("/policy/explain", handler.handleExplain)
+	mux.HandleFunc("/policy/test", handler.handleTest)
+	mux.HandleFunc("/blocks", handler.handleBlocks)
+	mux.HandleFunc("/metrics", handler.handleMetrics)
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		if !ready.Ready() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	})
 
 	srv := &http.Server{Addr: addr, Handler: mux}
 	handler.server = srv
@@ -77,10 +99,16 @@ func startPolicyServer(ctx context.Context, proxy *dnsproxy.Proxy, addr string,
 	}
 }
 
+// policySourceAPI names policies replaced through POST /policy in rule origins.
+const policySourceAPI = "api"
+
 type policyServer struct {
-	proxy  *dnsproxy.Proxy
-	server *http.Server
-	token  string
+	proxy    *dnsproxy.Proxy
+	enforcer *iptables.Enforcer // nil unless enforce mode is on
+	// byteMeter is nil unless byte accounting is on
+	byteMeter *iptables.ByteMeter
+	server    *http.Server
+	token     string
 }
 
 func (s *policyServer) handlePolicy(w http.ResponseWriter, r *http.Request) {
@@ -99,12 +127,117 @@ func (s *policyServer) handlePolicy(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *policyServer) handleExplain(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	domain := strings.TrimSpace(r.URL.Query().Get("domain"))
+	if domain == "" {
+		http.Error(w, "missing domain", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.proxy.CurrentPolicy().Explain(domain))
+}
+
+// handleTest runs a policy.TestSuite. Without a policy in the suite the cases
+// run against the current one; the current policy is never changed. Failed
+// cases are reported in the body, not by the status.
+func (s *policyServer) handleTest(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	defer r.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20)) // 1MB limit
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read body: %v", err), http.StatusBadRequest)
+		return
+	}
+	suite, err := policy.ParseTestSuite(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid test suite: %v", err), http.StatusBadRequest)
+		return
+	}
+	pol := s.proxy.CurrentPolicy()
+	if len(suite.Policy) > 0 {
+		if pol, err = policy.ParsePolicyFrom(policySourceAPI, string(suite.Policy)); err != nil {
+			http.Error(w, fmt.Sprintf("invalid policy: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, pol.RunTests(suite.Cases))
+}
+
+func (s *policyServer) handleBlocks(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"blocked": s.proxy.BlockCounts(),
+	})
+}
+
+func (s *policyServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if wantsPrometheus(r) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if s.byteMeter != nil {
+			_ = s.byteMeter.WriteMetrics(w)
+		}
+		return
+	}
+	var egressBytes map[string]uint64
+	if s.byteMeter != nil {
+		egressBytes = s.byteMeter.Totals()
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"cache":        s.proxy.CacheStats(),
+		"egress_bytes": egressBytes,
+	})
+}
+
+// wantsPrometheus tells a Prometheus scrape, which asks for the text format,
+// from a request for the JSON document; ?format=prometheus forces the former.
+func wantsPrometheus(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "prometheus" {
+		return true
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "text/plain") || strings.Contains(accept, "application/openmetrics-text")
+}
+
 func (s *policyServer) handleGet(w http.ResponseWriter) {
 	current := s.proxy.CurrentPolicy()
 	mode := modeFromPolicy(current)
 	writeJSON(w, http.StatusOK, map[string]any{
 		"mode":   mode,
 		"policy": current,
+		"source": current.Source(),
 	})
 }
 
@@ -119,6 +252,7 @@ func (s *policyServer) handlePost(w http.ResponseWriter, r *http.Request) {
 	raw := strings.TrimSpace(string(body))
 	if raw == "" {
 		s.proxy.UpdatePolicy(policy.DefaultDenyPolicy())
+		s.enforceDefault()
 		writeJSON(w, http.StatusOK, map[string]any{
 			"status": "ok",
 			"mode":   "deny_all",
@@ -127,18 +261,44 @@ func (s *policyServer) handlePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pol, err := policy.ParsePolicy(raw)
+	pol, err := policy.ParsePolicyFrom(policySourceAPI, raw)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("invalid policy: %v", err), http.StatusBadRequest)
 		return
 	}
+	if err := s.proxy.CheckPolicy(pol); err != nil {
+		http.Error(w, fmt.Sprintf("invalid policy: %v", err), http.StatusBadRequest)
+		return
+	}
 	s.proxy.UpdatePolicy(pol)
+	s.enforceDefault()
 	writeJSON(w, http.StatusOK, map[string]any{
 		"status": "ok",
 		"mode":   modeFromPolicy(pol),
 	})
 }
 
+// enforceDefault makes enforce mode drop unresolved addresses exactly while
+// the current policy denies by default, and revokes the addresses of names it
+// no longer allows, keeping those of names it still does. The DNS side of the
+// policy is in effect either way, so a failure is only logged.
+func (s *policyServer) enforceDefault() {
+	if s.enforcer == nil {
+		return
+	}
+	pol := s.proxy.CurrentPolicy()
+	removed, err := s.enforcer.SwapPolicy(pol.DefaultAction == policy.ActionDeny, func(name string) bool {
+		return pol.Evaluate(name) == policy.ActionAllow
+	})
+	if err != nil {
+		log.Printf("enforce mode not updated to the new policy: %v", err)
+		return
+	}
+	if removed > 0 {
+		log.Printf("enforce mode: revoked %d addresses of names the new policy denies", removed)
+	}
+}
+
 func (s *policyServer) authorize(r *http.Request) bool {
 	if s.token == "" {
 		return true
@@ -163,7 +323,7 @@ func modeFromPolicy(p *policy.NetworkPolicy) string {
 	if p == nil {
 		return "deny_all"
 	}
-	if p.DefaultAction == policy.ActionAllow && len(p.Egress) == 0 {
+	if p.DefaultAction == policy.ActionAllow && len(p.Egress) == 0 && len(p.Block) == 0 {
 		return "allow_all"
 	} else if p.DefaultAction == policy.ActionDeny && len(p.Egress) == 0 {
 		return "deny_all"
@@ -0,0 +1,140 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/alibaba/opensandbox/egress/pkg/policy"
+)
+
+// readiness tracks whether the sandbox's DNS goes through the proxy: it is
+// ready once the proxy is serving and the redirect is installed, and stops
+// being ready on shutdown. It is served on GET /readyz and, when file is set,
+// mirrored by a sentinel file other containers of the pod can watch.
+type readiness struct {
+	file  string
+	ready atomic.Bool
+}
+
+func newReadiness(file string) *readiness {
+	return &readiness{file: file}
+}
+
+func (r *readiness) Ready() bool {
+	return r.ready.Load()
+}
+
+// MarkReady writes the sentinel file, through a rename so a watcher never
+// sees it half written, and then reports ready.
+func (r *readiness) MarkReady() error {
+	if r.file != "" {
+		tmp := r.file + ".tmp"
+		stamp := time.Now().UTC().Format(time.RFC3339) + "\n"
+		if err := os.WriteFile(tmp, []byte(stamp), 0o644); err != nil {
+			return fmt.Errorf("failed to write ready file: %w", err)
+		}
+		if err := os.Rename(tmp, r.file); err != nil {
+			_ = os.Remove(tmp)
+			return fmt.Errorf("failed to write ready file: %w", err)
+		}
+	}
+	r.ready.Store(true)
+	return nil
+}
+
+// MarkNotReady reports not ready and removes the sentinel file, also one a
+// previous run of the sidecar left behind.
+func (r *readiness) MarkNotReady() error {
+	r.ready.Store(false)
+	if r.file == "" {
+		return nil
+	}
+	if err := os.Remove(r.file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove ready file: %w", err)
+	}
+	return nil
+}
+
+// bringUp starts the DNS proxy, then installs the redirect to it, and marks
+// the sidecar ready only when both succeeded. Until then workloads waiting on
+// readiness would otherwise resolve names outside the policy.
+func bringUp(ready *readiness, startProxy, installRedirect func() error) error {
+	if err := startProxy(); err != nil {
+		return fmt.Errorf("failed to start dns proxy: %w", err)
+	}
+	if err := installRedirect(); err != nil {
+		return fmt.Errorf("failed to install iptables redirect: %w", err)
+	}
+	return ready.MarkReady()
+}
+
+// waitReadyInterval is how often wait-ready checks the sidecar.
+const waitReadyInterval = 200 * time.Millisecond
+
+// runWaitReady implements "egress wait-ready [-file FILE] [-url URL] [-timeout D]".
+// It blocks until the ready file exists or the readiness URL answers 200, so
+// a container sharing the pod can run it before its own command. The file
+// defaults to OPENSANDBOX_EGRESS_READY_FILE. The exit code is 0 once ready, 1
+// on timeout and 2 on bad input.
+func runWaitReady(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("wait-ready", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	file := flags.String("file", os.Getenv(policy.EgressReadyFileEnv), "ready file written by the sidecar")
+	url := flags.String("url", "", "readiness URL of the sidecar, e.g. http://127.0.0.1:18080/readyz")
+	timeout := flags.Duration("timeout", time.Minute, "how long to wait; 0 waits forever")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 || (*file == "" && *url == "") {
+		fmt.Fprintln(stderr, "usage: egress wait-ready [-file FILE] [-url URL] [-timeout DURATION]")
+		return 2
+	}
+	client := &http.Client{Timeout: time.Second}
+	isReady := func() bool {
+		if *file != "" {
+			if _, err := os.Stat(*file); err == nil {
+				return true
+			}
+		}
+		if *url != "" {
+			resp, err := client.Get(*url)
+			if err == nil {
+				resp.Body.Close()
+				return resp.StatusCode == http.StatusOK
+			}
+		}
+		return false
+	}
+
+	start := time.Now()
+	for !isReady() {
+		if *timeout > 0 && time.Since(start) >= *timeout {
+			fmt.Fprintf(stderr, "egress not ready after %s\n", *timeout)
+			return 1
+		}
+		time.Sleep(waitReadyInterval)
+	}
+	fmt.Fprintf(stdout, "egress ready after %s\n", time.Since(start).Round(time.Millisecond))
+	return 0
+}
@@ -0,0 +1,107 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBringUp_ReadyOnlyAfterProxyAndRedirect(t *testing.T) {
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+	fail := errors.New("boom")
+	tests := []struct {
+		name        string
+		proxyErr    error
+		redirectErr error
+		wantReady   bool
+	}{
+		{name: "both succeed", wantReady: true},
+		{name: "proxy fails", proxyErr: fail},
+		{name: "redirect fails", redirectErr: fail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "ready")
+			ready := newReadiness(file)
+			var redirected bool
+			startProxy := func() error {
+				if exists(file) || ready.Ready() {
+					t.Errorf("ready before the proxy started")
+				}
+				return tt.proxyErr
+			}
+			installRedirect := func() error {
+				redirected = true
+				if exists(file) || ready.Ready() {
+					t.Errorf("ready before the redirect was installed")
+				}
+				return tt.redirectErr
+			}
+
+			err := bringUp(ready, startProxy, installRedirect)
+			if (err == nil) != tt.wantReady {
+				t.Fatalf("bringUp() error = %v", err)
+			}
+			if tt.proxyErr != nil && redirected {
+				t.Errorf("redirect installed although the proxy failed to start")
+			}
+			if exists(file) != tt.wantReady || ready.Ready() != tt.wantReady {
+				t.Fatalf("ready file %t, ready %t, want %t", exists(file), ready.Ready(), tt.wantReady)
+			}
+		})
+	}
+}
+
+func TestReadiness_MarkNotReadyRemovesFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ready")
+	ready := newReadiness(file)
+	if err := ready.MarkNotReady(); err != nil {
+		t.Fatalf("MarkNotReady() without a file: %v", err)
+	}
+	if err := ready.MarkReady(); err != nil {
+		t.Fatalf("MarkReady(): %v", err)
+	}
+	if err := ready.MarkNotReady(); err != nil {
+		t.Fatalf("MarkNotReady(): %v", err)
+	}
+	if _, err := os.Stat(file); !errors.Is(err, os.ErrNotExist) || ready.Ready() {
+		t.Fatalf("still ready after shutdown: stat %v, ready %t", err, ready.Ready())
+	}
+}
+
+func TestRunWaitReady(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ready")
+	var stdout, stderr bytes.Buffer
+	if code := runWaitReady([]string{"-file", file, "-timeout", "300ms"}, &stdout, &stderr); code != 1 {
+		t.Fatalf("exit code %d without the ready file, want 1; stderr: %s", code, stderr.String())
+	}
+	if err := newReadiness(file).MarkReady(); err != nil {
+		t.Fatalf("MarkReady(): %v", err)
+	}
+	if code := runWaitReady([]string{"-file", file}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d with the ready file, want 0; stderr: %s", code, stderr.String())
+	}
+	t.Setenv("OPENSANDBOX_EGRESS_READY_FILE", "")
+	if code := runWaitReady(nil, &stdout, &stderr); code != 2 {
+		t.Fatalf("exit code %d with nothing to wait for, want 2", code)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alibaba/opensandbox/egress/pkg/policy"
+)
+
+// runTestPolicy implements "egress test-policy [-policy FILE] [-json] SUITE...".
+// Each suite (see policy.TestSuite) runs against -policy, or else its own
+// policy. Failed cases are printed with the decision that failed them. The
+// exit code is 0 when every case passes, 1 when one fails and 2 on bad input,
+// so CI can run it as is.
+func runTestPolicy(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("test-policy", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	policyFile := fs.String("policy", "", "policy JSON file to test, instead of the suites' own")
+	asJSON := fs.Bool("json", false, "print each suite's report as JSON")
+	verbose := fs.Bool("v", false, "also print passing cases")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(stderr, "usage: egress test-policy [-policy FILE] [-json] [-v] SUITE...")
+		return 2
+	}
+
+	var override *policy.NetworkPolicy
+	if *policyFile != "" {
+		raw, err := os.ReadFile(*policyFile)
+		if err == nil {
+			override, err = policy.ParsePolicyFrom(*policyFile, string(raw))
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", *policyFile, err)
+			return 2
+		}
+	}
+
+	code := 0
+	for _, path := range fs.Args() {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		suite, err := policy.ParseTestSuite(raw)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", path, err)
+			return 2
+		}
+		pol := override
+		if pol == nil {
+			if len(suite.Policy) == 0 {
+				fmt.Fprintf(stderr, "%s: no policy to test; pass -policy or add one to the suite\n", path)
+				return 2
+			}
+			// origins of the embedded policy count lines from its opening brace
+			if pol, err = policy.ParsePolicyFrom(path+"#policy", string(suite.Policy)); err != nil {
+				fmt.Fprintf(stderr, "%s: invalid policy: %v\n", path, err)
+				return 2
+			}
+		}
+
+		report := pol.RunTests(suite.Cases)
+		if report.Failed > 0 {
+			code = 1
+		}
+		if *asJSON {
+			out, _ := json.Marshal(map[string]any{"suite": path, "report": report})
+			fmt.Fprintln(stdout, string(out))
+			continue
+		}
+		for _, r := range report.Results {
+			if !r.Passed {
+				fmt.Fprintf(stdout, "FAIL %s: %s %s: %s\n", path, r.Name, r.QType, r.Message)
+			} else if *verbose {
+				fmt.Fprintf(stdout, "ok   %s: %s %s: %s\n", path, r.Name, r.QType, r.Expect)
+			}
+		}
+		fmt.Fprintf(stdout, "%s: %d passed, %d failed\n", path, report.Passed, report.Failed)
+	}
+	return code
+}
@@ -0,0 +1,63 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunTestPolicy_ReportsFailures(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := runTestPolicy([]string{"testdata/policy-suite.json"}, &stdout, &stderr); code != 1 {
+		t.Fatalf("expected exit code 1 for a failing suite, got %d: %s", code, stderr.String())
+	}
+	want := `FAIL testdata/policy-suite.json: gist.github.com A: expected allow, got deny (blocked suffix gist.github.com at testdata/policy-suite.json#policy block[0] line 9)
+FAIL testdata/policy-suite.json: example.com A: expected reason explicit_deny, got no_matching_rule (default action, no_matching_rule)
+testdata/policy-suite.json: 5 passed, 2 failed
+`
+	if stdout.String() != want {
+		t.Fatalf("unexpected report:\n%s\nwant:\n%s", stdout.String(), want)
+	}
+}
+
+func TestRunTestPolicy_PolicyFlag(t *testing.T) {
+	dir := t.TempDir()
+	pol := filepath.Join(dir, "policy.json")
+	if err := os.WriteFile(pol, []byte(`{"defaultAction":"deny","egress":[{"action":"allow","target":"*.example.com"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	suite := filepath.Join(dir, "cases.json")
+	if err := os.WriteFile(suite, []byte(`[{"name":"www.example.com","expect":"allow"},{"name":"example.org","expect":"deny"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runTestPolicy([]string{"-policy", pol, "-v", suite}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected a passing suite, got exit code %d: %s%s", code, stdout.String(), stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "ok   "+suite+": www.example.com A: allow") || !strings.HasSuffix(stdout.String(), ": 2 passed, 0 failed\n") {
+		t.Fatalf("unexpected report: %s", stdout.String())
+	}
+
+	// a suite without a policy needs -policy
+	stdout.Reset()
+	if code := runTestPolicy([]string{suite}, &stdout, &stderr); code != 2 || !strings.Contains(stderr.String(), "no policy to test") {
+		t.Fatalf("expected a usage error, got exit code %d: %s", code, stderr.String())
+	}
+}
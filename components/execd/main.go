@@ -16,11 +16,13 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	_ "go.uber.org/automaxprocs/maxprocs"
 
 	"github.com/alibaba/opensandbox/execd/pkg/flag"
 	"github.com/alibaba/opensandbox/execd/pkg/log"
+	"github.com/alibaba/opensandbox/execd/pkg/runtime"
 	_ "github.com/alibaba/opensandbox/execd/pkg/util/safego"
 	"github.com/alibaba/opensandbox/execd/pkg/web"
 	"github.com/alibaba/opensandbox/execd/pkg/web/controller"
@@ -28,6 +30,9 @@ import (
 
 // main initializes and starts the execd server.
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == runtime.WasmRunArg {
+		os.Exit(runtime.RunWasmModule(os.Args[2:]))
+	}
 	flag.InitFlags()
 
 	log.SetLevel(flag.ServerLogLevel)
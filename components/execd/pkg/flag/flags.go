@@ -34,4 +34,47 @@ var (
 
 	// ApiGracefulShutdownTimeout waits before tearing down SSE streams.
 	ApiGracefulShutdownTimeout time.Duration
+
+	// MaxKernels caps live Jupyter kernels, evicting idle ones LRU; 0 means unlimited.
+	MaxKernels int
+
+	// KernelPool sets how many idle kernels are kept ready per language for new contexts.
+	KernelPool map[string]int
+
+	// MaxConcurrentCommands caps foreground commands running at once, queueing the rest; 0 means unlimited.
+	MaxConcurrentCommands int
+
+	// CommandGroupWeights sets each group's share of command slots while queued; unlisted groups weigh 1.
+	CommandGroupWeights map[string]int
+
+	// MaxScratchMB caps the scratch tmpfs a command may request; 0 disables scratch space.
+	MaxScratchMB int
+
+	// WasmRuntime is an external WASI runtime binary that runs wasm commands
+	// instead of the embedded one.
+	WasmRuntime string
+
+	// TranscriptDir receives a JSON transcript per execution when set.
+	TranscriptDir string
+
+	// CloudEventSink receives a CloudEvent per execution start and end when set.
+	CloudEventSink string
+
+	// MaxCommandsPerLane caps commands of one lane running at once; 0 means 1.
+	MaxCommandsPerLane int
+
+	// CommandCgroup is the cgroup v2 directory commands get a cgroup of their own under; empty disables it.
+	CommandCgroup string
+
+	// StopGracePeriod is how long stopped commands get between SIGTERM and SIGKILL; 0 means 3s.
+	StopGracePeriod time.Duration
+
+	// DuplicateWindow is how long an execution identical to an earlier one is treated as a resubmission; 0 disables it.
+	DuplicateWindow time.Duration
+
+	// DuplicateAction is "coalesce" or "reject", what happens to a resubmitted execution.
+	DuplicateAction string
+
+	// EnvFileWatchInterval enables polling EXECD_ENVS for changes when positive.
+	EnvFileWatchInterval time.Duration
 )
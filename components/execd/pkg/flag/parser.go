@@ -16,8 +16,12 @@ package flag
 
 import (
 	"flag"
+	"fmt"
 	stdlog "log"
+	"net/url"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -28,6 +32,20 @@ const (
 	jupyterHostEnv             = "JUPYTER_HOST"
 	jupyterTokenEnv            = "JUPYTER_TOKEN"
 	gracefulShutdownTimeoutEnv = "EXECD_API_GRACE_SHUTDOWN"
+	envFileWatchIntervalEnv    = "EXECD_ENVS_WATCH_INTERVAL"
+	maxKernelsEnv              = "EXECD_MAX_KERNELS"
+	kernelPoolEnv              = "EXECD_KERNEL_POOL"
+	maxConcurrentCommandsEnv   = "EXECD_MAX_CONCURRENT_COMMANDS"
+	commandGroupWeightsEnv     = "EXECD_COMMAND_GROUP_WEIGHTS"
+	transcriptDirEnv           = "EXECD_TRANSCRIPT_DIR"
+	cloudEventSinkEnv          = "EXECD_CLOUDEVENTS_SINK"
+	maxScratchMBEnv            = "EXECD_MAX_SCRATCH_MB"
+	wasmRuntimeEnv             = "EXECD_WASM_RUNTIME"
+	stopGracePeriodEnv         = "EXECD_STOP_GRACE_PERIOD"
+	commandCgroupEnv           = "EXECD_COMMAND_CGROUP"
+	maxLaneCommandsEnv         = "EXECD_MAX_LANE_COMMANDS"
+	duplicateWindowEnv         = "EXECD_DUPLICATE_WINDOW"
+	duplicateActionEnv         = "EXECD_DUPLICATE_ACTION"
 )
 
 // InitFlags registers CLI flags and env overrides.
@@ -37,6 +55,8 @@ func InitFlags() {
 	ServerLogLevel = 6
 	ServerAccessToken = ""
 	ApiGracefulShutdownTimeout = time.Second * 1
+	MaxScratchMB = 1024
+	DuplicateAction = "coalesce"
 
 	// First, set default values from environment variables
 	if jupyterFromEnv := os.Getenv(jupyterHostEnv); jupyterFromEnv != "" {
@@ -67,6 +87,126 @@ func InitFlags() {
 
 	flag.DurationVar(&ApiGracefulShutdownTimeout, "graceful-shutdown-timeout", ApiGracefulShutdownTimeout, "API graceful shutdown timeout duration (default: 3s)")
 
+	if watchInterval := os.Getenv(envFileWatchIntervalEnv); watchInterval != "" {
+		duration, err := time.ParseDuration(watchInterval)
+		if err != nil {
+			stdlog.Panicf("Failed to parse env file watch interval from env: %v", err)
+		}
+		EnvFileWatchInterval = duration
+	}
+
+	flag.DurationVar(&EnvFileWatchInterval, "envs-watch-interval", EnvFileWatchInterval, "Poll interval for reloading the EXECD_ENVS file; 0 disables watching (default: 0)")
+
+	if stopGrace := os.Getenv(stopGracePeriodEnv); stopGrace != "" {
+		duration, err := time.ParseDuration(stopGrace)
+		if err != nil {
+			stdlog.Panicf("Failed to parse stop grace period from env: %v", err)
+		}
+		StopGracePeriod = duration
+	}
+
+	flag.DurationVar(&StopGracePeriod, "stop-grace-period", StopGracePeriod, "Time stopped or interrupted commands get to exit after SIGTERM before SIGKILL; 0 means the default (default: 3s)")
+
+	if commandCgroup := os.Getenv(commandCgroupEnv); commandCgroup != "" {
+		CommandCgroup = commandCgroup
+	}
+
+	flag.StringVar(&CommandCgroup, "command-cgroup", CommandCgroup, "Writable cgroup v2 directory under which each command runs in a cgroup of its own, so daemons it starts can still be stopped; linux only, empty disables it")
+
+	if maxKernels := os.Getenv(maxKernelsEnv); maxKernels != "" {
+		limit, err := strconv.Atoi(maxKernels)
+		if err != nil {
+			stdlog.Panicf("Failed to parse max kernels from env: %v", err)
+		}
+		MaxKernels = limit
+	}
+
+	flag.IntVar(&MaxKernels, "max-kernels", MaxKernels, "Maximum live Jupyter kernels; least recently used idle kernels are evicted beyond it, 0 means unlimited (default: 0)")
+
+	if pool := os.Getenv(kernelPoolEnv); pool != "" {
+		if err := (*kernelPoolSizes)(&KernelPool).Set(pool); err != nil {
+			stdlog.Panicf("Failed to parse kernel pool from env: %v", err)
+		}
+	}
+
+	flag.Var((*kernelPoolSizes)(&KernelPool), "kernel-pool", "Idle kernels kept ready per language for new contexts, e.g. python=4,bash=1")
+
+	if maxCommands := os.Getenv(maxConcurrentCommandsEnv); maxCommands != "" {
+		limit, err := strconv.Atoi(maxCommands)
+		if err != nil {
+			stdlog.Panicf("Failed to parse max concurrent commands from env: %v", err)
+		}
+		MaxConcurrentCommands = limit
+	}
+
+	flag.IntVar(&MaxConcurrentCommands, "max-concurrent-commands", MaxConcurrentCommands, "Maximum foreground commands running at once; extra requests wait, shared fairly across groups, 0 means unlimited (default: 0)")
+
+	if maxLaneCommands := os.Getenv(maxLaneCommandsEnv); maxLaneCommands != "" {
+		limit, err := strconv.Atoi(maxLaneCommands)
+		if err != nil {
+			stdlog.Panicf("Failed to parse max lane commands from env: %v", err)
+		}
+		MaxCommandsPerLane = limit
+	}
+
+	flag.IntVar(&MaxCommandsPerLane, "max-lane-commands", MaxCommandsPerLane, "Maximum commands of one lane running at once; later ones wait in order, 0 means 1 (default: 1)")
+
+	if weights := os.Getenv(commandGroupWeightsEnv); weights != "" {
+		if err := (*groupWeights)(&CommandGroupWeights).Set(weights); err != nil {
+			stdlog.Panicf("Failed to parse command group weights from env: %v", err)
+		}
+	}
+
+	flag.Var((*groupWeights)(&CommandGroupWeights), "command-group-weights", "Share of queued command slots per group, e.g. team-a=3,team-b=1; unlisted groups weigh 1")
+
+	if maxScratch := os.Getenv(maxScratchMBEnv); maxScratch != "" {
+		limit, err := strconv.Atoi(maxScratch)
+		if err != nil {
+			stdlog.Panicf("Failed to parse max scratch size from env: %v", err)
+		}
+		MaxScratchMB = limit
+	}
+
+	flag.IntVar(&MaxScratchMB, "max-scratch-mb", MaxScratchMB, "Largest tmpfs scratch space in MiB a command may request, 0 disables scratch space (default: 1024)")
+
+	if wasmRuntime := os.Getenv(wasmRuntimeEnv); wasmRuntime != "" {
+		WasmRuntime = wasmRuntime
+	}
+
+	flag.StringVar(&WasmRuntime, "wasm-runtime", WasmRuntime, "WASI runtime binary for wasm commands, invoked as <runtime> run <module> [args...] (default: the embedded runtime)")
+
+	if transcriptDir := os.Getenv(transcriptDirEnv); transcriptDir != "" {
+		TranscriptDir = transcriptDir
+	}
+
+	flag.StringVar(&TranscriptDir, "transcript-dir", TranscriptDir, "Directory to write a JSON transcript of every execution to; empty disables transcripts")
+
+	if sink := os.Getenv(cloudEventSinkEnv); sink != "" {
+		if err := setCloudEventSink(sink); err != nil {
+			stdlog.Panicf("Invalid %s: %v", cloudEventSinkEnv, err)
+		}
+	}
+
+	flag.Func("cloudevents-sink", "HTTP endpoint to publish execution started/completed/failed CloudEvents to; empty disables them", setCloudEventSink)
+
+	if window := os.Getenv(duplicateWindowEnv); window != "" {
+		duration, err := time.ParseDuration(window)
+		if err != nil {
+			stdlog.Panicf("Failed to parse duplicate window from env: %v", err)
+		}
+		DuplicateWindow = duration
+	}
+
+	flag.DurationVar(&DuplicateWindow, "duplicate-window", DuplicateWindow, "Executions identical to one submitted less than this long ago are coalesced or rejected instead of run; 0 disables it (default: 0)")
+
+	if action := os.Getenv(duplicateActionEnv); action != "" {
+		if err := setDuplicateAction(action); err != nil {
+			stdlog.Panicf("Invalid %s: %v", duplicateActionEnv, err)
+		}
+	}
+
+	flag.Func("duplicate-action", "What happens to an execution resubmitted within the duplicate window: coalesce (share the earlier one's output) or reject (default: coalesce)", setDuplicateAction)
+
 	// Parse flags - these will override environment variables if provided
 	flag.Parse()
 
@@ -74,3 +214,81 @@ func InitFlags() {
 	log.Info("Jupyter server host is: %s", JupyterServerHost)
 	log.Info("Jupyter server token is: %s", JupyterServerToken)
 }
+
+func setDuplicateAction(raw string) error {
+	action := strings.ToLower(strings.TrimSpace(raw))
+	if action != "coalesce" && action != "reject" {
+		return fmt.Errorf("unknown duplicate action %q, want coalesce or reject", raw)
+	}
+	DuplicateAction = action
+	return nil
+}
+
+func setCloudEventSink(raw string) error {
+	if raw != "" {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid sink %q, want an http:// or https:// URL", raw)
+		}
+	}
+	CloudEventSink = raw
+	return nil
+}
+
+// groupWeights parses "group=weight" pairs separated by commas.
+type groupWeights map[string]int
+
+func (g *groupWeights) String() string {
+	return formatPairs(*g)
+}
+
+func (g *groupWeights) Set(raw string) error {
+	weights, err := parsePairs(raw, "group weight", "group=positive integer")
+	if err != nil {
+		return err
+	}
+	*g = weights
+	return nil
+}
+
+// kernelPoolSizes parses "language=size" pairs separated by commas.
+type kernelPoolSizes map[string]int
+
+func (k *kernelPoolSizes) String() string {
+	return formatPairs(*k)
+}
+
+func (k *kernelPoolSizes) Set(raw string) error {
+	sizes, err := parsePairs(raw, "kernel pool size", "language=positive integer")
+	if err != nil {
+		return err
+	}
+	*k = sizes
+	return nil
+}
+
+func formatPairs(m map[string]int) string {
+	pairs := make([]string, 0, len(m))
+	for key, value := range m {
+		pairs = append(pairs, key+"="+strconv.Itoa(value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func parsePairs(raw, what, want string) (map[string]int, error) {
+	values := make(map[string]int)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid %s %q, want %s", what, pair, want)
+		}
+		values[strings.TrimSpace(key)] = n
+	}
+	return values, nil
+}
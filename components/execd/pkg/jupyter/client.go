@@ -193,6 +193,12 @@ func (c *Client) ExecuteCodeStream(kernelId, code string, resultChan chan *execu
 	return c.executeClient.ExecuteCodeStream(code, resultChan)
 }
 
+// ExecuteSilentStream streams execution results into resultChan without
+// recording the code in the kernel's history or bumping execution_count.
+func (c *Client) ExecuteSilentStream(kernelId, code string, resultChan chan *execute.ExecutionResult) error {
+	return c.executeClient.ExecuteSilentStream(code, resultChan)
+}
+
 // ExecuteCodeWithCallback processes execution events via callbacks.
 func (c *Client) ExecuteCodeWithCallback(code string, handler execute.CallbackHandler) error {
 	return c.executeClient.ExecuteCodeWithCallback(code, handler)
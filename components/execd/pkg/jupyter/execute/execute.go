@@ -113,6 +113,16 @@ func (c *Client) IsConnected() bool {
 
 // ExecuteCodeStream executes code in streaming mode, sending results to the provided channel
 func (c *Client) ExecuteCodeStream(code string, resultChan chan *ExecutionResult) error {
+	return c.executeCodeStream(code, false, resultChan)
+}
+
+// ExecuteSilentStream executes code like ExecuteCodeStream, but silently: it
+// is kept out of the kernel's history and does not bump execution_count.
+func (c *Client) ExecuteSilentStream(code string, resultChan chan *ExecutionResult) error {
+	return c.executeCodeStream(code, true, resultChan)
+}
+
+func (c *Client) executeCodeStream(code string, silent bool, resultChan chan *ExecutionResult) error {
 	if !c.IsConnected() {
 		return errors.New("not connected to kernel, please call Connect method")
 	}
@@ -124,8 +134,8 @@ func (c *Client) ExecuteCodeStream(code string, resultChan chan *ExecutionResult
 	msgID := c.nextMessageID()
 	request := &ExecuteRequest{
 		Code:            code,
-		Silent:          false,
-		StoreHistory:    true,
+		Silent:          silent,
+		StoreHistory:    !silent,
 		UserExpressions: make(map[string]string),
 		AllowStdin:      false,
 		StopOnError:     true,
@@ -164,10 +174,26 @@ func (c *Client) ExecuteCodeStream(code string, resultChan chan *ExecutionResult
 	var executeDone bool
 	var executeMutex sync.Mutex
 	var executeResult *ExecuteResult
+	var replied bool
 
 	// Create mutex to protect result object
 	var resultMutex sync.Mutex
 
+	// answered reports whether the result channel can be closed. A silent
+	// execution keeps execution_count where it was, which is 0 on a fresh
+	// kernel, so it waits for the reply instead.
+	answered := func() bool {
+		resultMutex.Lock()
+		defer resultMutex.Unlock()
+		if result.Error != nil {
+			return true
+		}
+		if silent {
+			return replied
+		}
+		return result.ExecutionCount > 0
+	}
+
 	// Clear temporary handlers
 	c.clearTemporaryHandlers()
 
@@ -178,8 +204,10 @@ func (c *Client) ExecuteCodeStream(code string, resultChan chan *ExecutionResult
 		}
 
 		resultMutex.Lock()
+		replied = true
 		result.ExecutionCount = execReply.ExecutionCount
 		if execReply.EName != "" {
+			execReply.ErrorOutput.normalize()
 			result.Error = &execReply.ErrorOutput
 		}
 		resultMutex.Unlock()
@@ -202,6 +230,7 @@ func (c *Client) ExecuteCodeStream(code string, resultChan chan *ExecutionResult
 		notify := &ExecutionResult{}
 		notify.ExecutionCount = executeResult.ExecutionCount
 		notify.ExecutionData = executeResult.Data
+		notify.ExecutionMetadata = executeResult.Metadata
 
 		resultChan <- notify
 		resultMutex.Unlock()
@@ -230,6 +259,7 @@ func (c *Client) ExecuteCodeStream(code string, resultChan chan *ExecutionResult
 		if err := json.Unmarshal(msg.Content, &errOutput); err != nil {
 			return
 		}
+		errOutput.normalize()
 
 		resultMutex.Lock()
 		result.Status = "error"
@@ -268,7 +298,7 @@ func (c *Client) ExecuteCodeStream(code string, resultChan chan *ExecutionResult
 					resultChan <- notify
 					resultMutex.Unlock()
 
-					for result.ExecutionCount <= 0 && result.Error == nil {
+					for !answered() {
 						time.Sleep(300 * time.Millisecond)
 					}
 
@@ -376,6 +406,7 @@ func (c *Client) ExecuteCodeWithCallback(code string, handler CallbackHandler) e
 			if err := json.Unmarshal(msg.Content, &errOutput); err != nil {
 				return
 			}
+			errOutput.normalize()
 
 			// calls callback functions
 			handler.OnError(&errOutput)
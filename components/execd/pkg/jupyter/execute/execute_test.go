@@ -156,3 +156,62 @@ func TestExecuteCodeStream(t *testing.T) {
 		t.Errorf("expected at least 4 results, got %d", resultCount)
 	}
 }
+
+// Test silent execution on a fresh kernel, whose execution_count stays 0
+func TestExecuteSilentStream(t *testing.T) {
+	requests := make(chan ExecuteRequest, 1)
+	server := createTestServer(t, func(conn *websocket.Conn) {
+		var executeRequest Message
+		if err := conn.ReadJSON(&executeRequest); err != nil {
+			t.Errorf("failed to read execution request: %v", err)
+			return
+		}
+		var request ExecuteRequest
+		_ = json.Unmarshal(executeRequest.Content, &request)
+		requests <- request
+
+		statusContent, _ := json.Marshal(StatusUpdate{ExecutionState: StateIdle})
+		conn.WriteJSON(Message{
+			Header:       Header{MessageID: "status-msg-id", MessageType: string(MsgStatus)},
+			ParentHeader: executeRequest.Header,
+			Content:      json.RawMessage(statusContent),
+		})
+		replyContent, _ := json.Marshal(ExecuteReply{Status: "ok", ExecutionCount: 0})
+		conn.WriteJSON(Message{
+			Header:       Header{MessageID: "reply-msg-id", MessageType: string(MsgExecuteReply)},
+			ParentHeader: executeRequest.Header,
+			Content:      json.RawMessage(replyContent),
+		})
+		// keep the connection open until the client is done
+		_, _, _ = conn.ReadMessage()
+	})
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/kernels/test-kernel-id/channels"
+	executor := NewExecutor(wsURL, nil)
+	if err := executor.Connect(); err != nil {
+		t.Fatalf("failed to connect to WebSocket: %v", err)
+	}
+	defer executor.Disconnect()
+
+	resultChan := make(chan *ExecutionResult, 10)
+	if err := executor.ExecuteSilentStream("import os", resultChan); err != nil {
+		t.Fatalf("failed to start silent execution: %v", err)
+	}
+	request := <-requests
+	if !request.Silent || request.StoreHistory {
+		t.Errorf("expected silent=true and store_history=false, got silent=%v store_history=%v", request.Silent, request.StoreHistory)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for range resultChan {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the result channel was not closed after the reply")
+	}
+}
@@ -46,6 +46,11 @@ func (e *Executor) ExecuteCodeStream(code string, resultChan chan *ExecutionResu
 	return e.client.ExecuteCodeStream(code, resultChan)
 }
 
+// ExecuteSilentStream executes code silently in streaming mode, sending results to the provided channel
+func (e *Executor) ExecuteSilentStream(code string, resultChan chan *ExecutionResult) error {
+	return e.client.ExecuteSilentStream(code, resultChan)
+}
+
 // ExecuteCodeWithCallback executes code using callback functions
 func (e *Executor) ExecuteCodeWithCallback(code string, handler CallbackHandler) error {
 	return e.client.ExecuteCodeWithCallback(code, handler)
@@ -0,0 +1,111 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package execute
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+	// File "/usr/lib/python3.11/json/decoder.py", line 355, in raw_decode
+	plainFrame = regexp.MustCompile(`^\s*File "([^"]+)", line (\d+)(?:, in (\S+))?`)
+	// File /usr/lib/python3.11/json/decoder.py:355, in JSONDecoder.raw_decode(self, s, idx)  (IPython 8+)
+	ipythonFileFrame = regexp.MustCompile(`^\s*File (\S.*?):(\d+)(?:, in ([^\s(]+))?`)
+	// Cell In[3], line 2, in f()  (IPython 8+)
+	ipythonCellFrame = regexp.MustCompile(`^\s*Cell (In\[\d+\]), line (\d+)(?:, in ([^\s(]+))?`)
+	// ZeroDivisionError: division by zero
+	exceptionLine = regexp.MustCompile(`^([A-Za-z_][\w.]*(?:Error|Exception|Interrupt|Exit|Warning|Iteration))(?::\s?(.*))?$`)
+)
+
+// TracebackFrame is one stack frame recovered from a kernel traceback.
+type TracebackFrame struct {
+	// File is the source path, or the cell label such as "In[3]" for notebook code.
+	File string `json:"file"`
+
+	// Line is the 1-based line number within File.
+	Line int `json:"line"`
+
+	// Function is the enclosing function, "<module>" for top-level cell code.
+	Function string `json:"function,omitempty"`
+}
+
+// stripANSI removes terminal color escapes that IPython embeds in tracebacks.
+func stripANSI(s string) string {
+	return ansiEscape.ReplaceAllString(s, "")
+}
+
+// normalize fills Frames from a Python-style Traceback and, when the kernel
+// omitted them, EName and EValue from the final "Type: message" line.
+// Tracebacks that do not look like Python leave the output unchanged.
+func (e *ErrorOutput) normalize() {
+	if e == nil || len(e.Traceback) == 0 {
+		return
+	}
+
+	var lines []string
+	for _, entry := range e.Traceback {
+		lines = append(lines, strings.Split(stripANSI(entry), "\n")...)
+	}
+
+	e.Frames = parseTracebackFrames(lines)
+
+	if e.EName != "" {
+		return
+	}
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+		if m := exceptionLine.FindStringSubmatch(line); m != nil {
+			e.EName = m[1]
+			if e.EValue == "" {
+				e.EValue = m[2]
+			}
+		}
+		return
+	}
+}
+
+func parseTracebackFrames(lines []string) []TracebackFrame {
+	var frames []TracebackFrame
+	for _, line := range lines {
+		var m []string
+		switch {
+		case strings.Contains(line, `File "`):
+			m = plainFrame.FindStringSubmatch(line)
+		case strings.HasPrefix(strings.TrimSpace(line), "Cell "):
+			m = ipythonCellFrame.FindStringSubmatch(line)
+			if m != nil && m[3] == "" {
+				m[3] = "<module>"
+			}
+		default:
+			m = ipythonFileFrame.FindStringSubmatch(line)
+		}
+		if m == nil {
+			continue
+		}
+		lineNo, err := strconv.Atoi(m[2])
+		if err != nil {
+			continue
+		}
+		frames = append(frames, TracebackFrame{File: m[1], Line: lineNo, Function: m[3]})
+	}
+	return frames
+}
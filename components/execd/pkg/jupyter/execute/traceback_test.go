@@ -0,0 +1,120 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package execute
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestErrorOutputNormalize(t *testing.T) {
+	tests := []struct {
+		name       string
+		raw        string
+		wantName   string
+		wantValue  string
+		wantFrames []TracebackFrame
+	}{
+		{
+			name: "ipython 8 cell error with ansi colors",
+			raw: `{"ename":"ZeroDivisionError","evalue":"division by zero","traceback":[
+				"\u001b[0;31m---------------------------------------------------------------------------\u001b[0m",
+				"\u001b[0;31mZeroDivisionError\u001b[0m                         Traceback (most recent call last)",
+				"Cell \u001b[0;32mIn[1], line 1\u001b[0m\n\u001b[0;32m----> 1\u001b[0m \u001b[38;5;241;43m1\u001b[39;49m\u001b[38;5;241;43m/\u001b[39;49m\u001b[38;5;241;43m0\u001b[39;49m\n",
+				"\u001b[0;31mZeroDivisionError\u001b[0m: division by zero"
+			]}`,
+			wantName:  "ZeroDivisionError",
+			wantValue: "division by zero",
+			wantFrames: []TracebackFrame{
+				{File: "In[1]", Line: 1, Function: "<module>"},
+			},
+		},
+		{
+			name: "ipython 8 frames through a function and a library",
+			raw: `{"ename":"JSONDecodeError","evalue":"Expecting value: line 1 column 1 (char 0)","traceback":[
+				"\u001b[0;31mJSONDecodeError\u001b[0m                           Traceback (most recent call last)",
+				"Cell \u001b[0;32mIn[4], line 5\u001b[0m\n\u001b[1;32m      2\u001b[0m \u001b[38;5;28;01mdef\u001b[39;00m \u001b[38;5;21mparse\u001b[39m(s):\n\u001b[0;32m----> 5\u001b[0m parse(\u001b[38;5;124m\"\u001b[39m\u001b[38;5;124m\"\u001b[39m)\n",
+				"Cell \u001b[0;32mIn[4], line 3\u001b[0m, in \u001b[0;36mparse\u001b[0;34m(s)\u001b[0m\n\u001b[0;32m----> 3\u001b[0m \u001b[38;5;28;01mreturn\u001b[39;00m json\u001b[38;5;241m.\u001b[39mloads(s)\n",
+				"File \u001b[0;32m/usr/lib/python3.11/json/__init__.py:346\u001b[0m, in \u001b[0;36mloads\u001b[0;34m(s, cls, object_hook, parse_float, parse_int, parse_constant, object_pairs_hook, **kw)\u001b[0m\n\u001b[1;32m    341\u001b[0m     s \u001b[38;5;241m=\u001b[39m s\u001b[38;5;241m.\u001b[39mdecode(detect_encoding(s), \u001b[38;5;124m'\u001b[39m\u001b[38;5;124msurrogatepass\u001b[39m\u001b[38;5;124m'\u001b[39m)\n",
+				"File \u001b[0;32m/usr/lib/python3.11/json/decoder.py:355\u001b[0m, in \u001b[0;36mJSONDecoder.raw_decode\u001b[0;34m(self, s, idx)\u001b[0m\n",
+				"\u001b[0;31mJSONDecodeError\u001b[0m: Expecting value: line 1 column 1 (char 0)"
+			]}`,
+			wantName:  "JSONDecodeError",
+			wantValue: "Expecting value: line 1 column 1 (char 0)",
+			wantFrames: []TracebackFrame{
+				{File: "In[4]", Line: 5, Function: "<module>"},
+				{File: "In[4]", Line: 3, Function: "parse"},
+				{File: "/usr/lib/python3.11/json/__init__.py", Line: 346, Function: "loads"},
+				{File: "/usr/lib/python3.11/json/decoder.py", Line: 355, Function: "JSONDecoder.raw_decode"},
+			},
+		},
+		{
+			name: "plain python traceback without ename",
+			raw: `{"traceback":[
+				"Traceback (most recent call last):",
+				"  File \"/workspace/app.py\", line 10, in <module>\n    main()",
+				"  File \"/workspace/app.py\", line 7, in main\n    raise KeyError('missing')",
+				"KeyError: 'missing'"
+			]}`,
+			wantName:  "KeyError",
+			wantValue: "'missing'",
+			wantFrames: []TracebackFrame{
+				{File: "/workspace/app.py", Line: 10, Function: "<module>"},
+				{File: "/workspace/app.py", Line: 7, Function: "main"},
+			},
+		},
+		{
+			name: "syntax error frame has no function",
+			raw: `{"ename":"SyntaxError","evalue":"invalid syntax (3563234745.py, line 1)","traceback":[
+				"  File \"/tmp/ipykernel_1/3563234745.py\", line 1\n    print(\n         ^\n",
+				"SyntaxError: invalid syntax"
+			]}`,
+			wantName:  "SyntaxError",
+			wantValue: "invalid syntax (3563234745.py, line 1)",
+			wantFrames: []TracebackFrame{
+				{File: "/tmp/ipykernel_1/3563234745.py", Line: 1},
+			},
+		},
+		{
+			name:      "non python traceback is left alone",
+			raw:       `{"ename":"","evalue":"","traceback":["bash: foo: command not found"]}`,
+			wantName:  "",
+			wantValue: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out ErrorOutput
+			if err := json.Unmarshal([]byte(tt.raw), &out); err != nil {
+				t.Fatalf("unmarshal sample: %v", err)
+			}
+			traceback := append([]string(nil), out.Traceback...)
+
+			out.normalize()
+
+			if out.EName != tt.wantName || out.EValue != tt.wantValue {
+				t.Fatalf("got ename=%q evalue=%q, want %q %q", out.EName, out.EValue, tt.wantName, tt.wantValue)
+			}
+			if !reflect.DeepEqual(out.Frames, tt.wantFrames) {
+				t.Fatalf("frames mismatch:\n got %+v\nwant %+v", out.Frames, tt.wantFrames)
+			}
+			if !reflect.DeepEqual(out.Traceback, traceback) {
+				t.Fatalf("raw traceback must be preserved")
+			}
+		})
+	}
+}
@@ -208,6 +208,10 @@ type ErrorOutput struct {
 
 	// Traceback is the traceback of the error
 	Traceback []string `json:"traceback"`
+
+	// Frames is the stack parsed from Traceback, outermost first; empty when
+	// the traceback is not in Python format
+	Frames []TracebackFrame `json:"frames,omitempty"`
 }
 
 func (e *ErrorOutput) String() string {
@@ -241,8 +245,12 @@ type ExecutionResult struct {
 	// ExecutionTime is the total time of code execution
 	ExecutionTime time.Duration `json:"execution_time"`
 
-	// ExecutionData
+	// ExecutionData is the MIME bundle of an execute_result, the value of the
+	// cell's last expression
 	ExecutionData map[string]interface{} `json:"execution_data"`
+
+	// ExecutionMetadata is the metadata sent with ExecutionData
+	ExecutionMetadata map[string]interface{} `json:"execution_metadata,omitempty"`
 }
 
 // CallbackHandler defines callback functions for handling different types of messages
@@ -28,6 +28,11 @@ func (in *ErrorOutput) DeepCopyInto(out *ErrorOutput) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Frames != nil {
+		in, out := &in.Frames, &out.Frames
+		*out = make([]TracebackFrame, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ErrorOutput.
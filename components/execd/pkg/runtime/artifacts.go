@@ -0,0 +1,201 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+)
+
+const (
+	// defaultArtifactMaxFiles and maxArtifactMaxFiles bound the manifest size.
+	defaultArtifactMaxFiles = 1000
+	maxArtifactMaxFiles     = 10000
+	// maxArtifactHashBytes is the largest file that is hashed; bigger ones are
+	// listed without a hash so a scan cannot stall on huge outputs.
+	maxArtifactHashBytes = 64 << 20
+)
+
+var errArtifactsBackground = errors.New("artifacts are only supported for foreground commands")
+
+// ArtifactScan asks for a manifest of the files a command produced, taken
+// after it exits successfully.
+type ArtifactScan struct {
+	// Dir is the directory to scan, relative to the command's working
+	// directory; empty scans the working directory itself.
+	Dir string `json:"dir,omitempty"`
+	// Include keeps only files matching one of these globs; empty keeps all.
+	Include []string `json:"include,omitempty"`
+	// Exclude drops files and directories matching one of these globs.
+	Exclude []string `json:"exclude,omitempty"`
+	// MaxFiles caps the number of entries; 0 means 1000.
+	MaxFiles int `json:"max_files,omitempty"`
+}
+
+// ArtifactManifest lists the files modified while a command ran.
+type ArtifactManifest struct {
+	// Root is the absolute scanned directory; entry paths are relative to it.
+	Root  string          `json:"root"`
+	Files []ArtifactEntry `json:"files"`
+	// Truncated is set when more files matched than MaxFiles.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ArtifactEntry describes one produced file.
+type ArtifactEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	// SHA256 is the hex digest, empty for files above 64 MiB.
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// Validate checks the scan options before the command starts.
+func (s *ArtifactScan) Validate() error {
+	if s.Dir != "" && !filepath.IsLocal(s.Dir) {
+		return fmt.Errorf("artifacts dir %q must be relative and stay inside the working directory", s.Dir)
+	}
+	if s.MaxFiles < 0 || s.MaxFiles > maxArtifactMaxFiles {
+		return fmt.Errorf("artifacts max files must be between 0 and %d", maxArtifactMaxFiles)
+	}
+	for _, pattern := range append(append([]string{}, s.Include...), s.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid artifacts glob %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// reportArtifacts scans for the request's artifacts, if any, and passes the
+// manifest to OnExecuteArtifacts. It runs on every exit path, so a failed
+// command still reports what it left behind; a failed scan is returned for
+// the caller to report alongside the command's own outcome.
+func reportArtifacts(request *ExecuteCodeRequest, cwd string, since time.Time) error {
+	if request.Artifacts == nil {
+		return nil
+	}
+	manifest, err := scanArtifacts(request.Artifacts, cwd, since)
+	if err != nil {
+		log.Error("ArtifactScanError: %v", err)
+		return err
+	}
+	request.Hooks.OnExecuteArtifacts(manifest)
+	return nil
+}
+
+// scanArtifacts lists regular files under cwd/s.Dir modified at or after
+// since, sorted by path. Symlinks are neither listed nor followed.
+func scanArtifacts(s *ArtifactScan, cwd string, since time.Time) (*ArtifactManifest, error) {
+	if cwd == "" {
+		var err error
+		if cwd, err = os.Getwd(); err != nil {
+			return nil, err
+		}
+	}
+	root, err := filepath.Abs(filepath.Join(cwd, s.Dir))
+	if err != nil {
+		return nil, err
+	}
+	limit := s.MaxFiles
+	if limit == 0 {
+		limit = defaultArtifactMaxFiles
+	}
+	// Filesystems with coarse timestamps may round a new file's mtime down.
+	since = since.Truncate(time.Second)
+
+	manifest := &ArtifactManifest{Root: root, Files: []ArtifactEntry{}}
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			return nil // unreadable entries, or a directory never created, are skipped
+		}
+		if p == root {
+			return nil
+		}
+		rel := filepath.ToSlash(strings.TrimPrefix(p, root+string(filepath.Separator)))
+		if matchesAnyGlob(s.Exclude, rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || (len(s.Include) > 0 && !matchesAnyGlob(s.Include, rel)) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().Before(since) {
+			return nil
+		}
+		if len(manifest.Files) == limit {
+			manifest.Truncated = true
+			return filepath.SkipAll
+		}
+		entry := ArtifactEntry{Path: rel, Size: info.Size(), ModTime: info.ModTime().UTC()}
+		if info.Size() <= maxArtifactHashBytes {
+			entry.SHA256, _ = hashFile(p)
+		}
+		manifest.Files = append(manifest.Files, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan artifacts in %s: %w", root, err)
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool { return manifest.Files[i].Path < manifest.Files[j].Path })
+	return manifest, nil
+}
+
+// matchesAnyGlob matches rel, a slash-separated relative path, against
+// patterns. A pattern without a slash is matched against the base name, so
+// "*.o" covers every directory; one with a slash against the whole path.
+func matchesAnyGlob(patterns []string, rel string) bool {
+	base := path.Base(rel)
+	for _, pattern := range patterns {
+		target := rel
+		if !strings.Contains(pattern, "/") {
+			target = base
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func hashFile(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+func runArtifactCommand(t *testing.T, cwd, code string, scan *ArtifactScan) *ArtifactManifest {
+	t.Helper()
+	var manifest *ArtifactManifest
+	req := &ExecuteCodeRequest{
+		Language:  Command,
+		Code:      code,
+		Cwd:       cwd,
+		Artifacts: scan,
+		Hooks:     noopHooks(),
+	}
+	req.Hooks.OnExecuteArtifacts = func(m *ArtifactManifest) { manifest = m }
+	if err := NewController("", "").Execute(req); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if manifest == nil {
+		t.Fatal("expected an artifacts manifest")
+	}
+	return manifest
+}
+
+func TestArtifacts_ManifestListsCreatedFiles(t *testing.T) {
+	skipWithoutBash(t)
+	cwd := t.TempDir()
+	old := filepath.Join(cwd, "out", "stale.bin")
+	if err := os.MkdirAll(filepath.Dir(old), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(old, []byte("old"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(old, past, past); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	manifest := runArtifactCommand(t, cwd,
+		"mkdir -p logs && printf hello > out/app.bin && printf obj > out/app.o && echo log > logs/x.log",
+		&ArtifactScan{Include: []string{"*.bin", "*.o", "logs/*"}, Exclude: []string{"*.o"}})
+
+	root, _ := filepath.Abs(cwd)
+	assert.Equal(t, root, manifest.Root)
+	assert.False(t, manifest.Truncated)
+	paths := []string{}
+	for _, f := range manifest.Files {
+		paths = append(paths, f.Path)
+	}
+	assert.Equal(t, []string{"logs/x.log", "out/app.bin"}, paths)
+
+	sum := sha256.Sum256([]byte("hello"))
+	bin := manifest.Files[1]
+	assert.Equal(t, int64(5), bin.Size)
+	assert.Equal(t, hex.EncodeToString(sum[:]), bin.SHA256)
+}
+
+func TestArtifacts_DirAndMaxFiles(t *testing.T) {
+	skipWithoutBash(t)
+	cwd := t.TempDir()
+
+	manifest := runArtifactCommand(t, cwd,
+		"mkdir -p build && for i in 1 2 3 4; do echo $i > build/f$i; done && echo x > top",
+		&ArtifactScan{Dir: "build", MaxFiles: 2})
+	assert.True(t, manifest.Truncated)
+	assert.Len(t, manifest.Files, 2)
+	assert.Equal(t, filepath.Join(cwd, "build"), manifest.Root)
+
+	manifest = runArtifactCommand(t, cwd, "true", &ArtifactScan{Dir: "missing"})
+	assert.Empty(t, manifest.Files)
+}
+
+func TestArtifacts_ReportedWhenCommandFails(t *testing.T) {
+	skipWithoutBash(t)
+	cwd := t.TempDir()
+	var manifest *ArtifactManifest
+	var gotErr *execute.ErrorOutput
+	req := &ExecuteCodeRequest{
+		Language:  Command,
+		Code:      "echo partial > log.txt; exit 3",
+		Cwd:       cwd,
+		Artifacts: &ArtifactScan{},
+		Hooks:     noopHooks(),
+	}
+	req.Hooks.OnExecuteArtifacts = func(m *ArtifactManifest) { manifest = m }
+	req.Hooks.OnExecuteError = func(err *execute.ErrorOutput) { gotErr = err }
+	if err := NewController("", "").Execute(req); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if assert.NotNil(t, gotErr) {
+		assert.Equal(t, "3", gotErr.EValue)
+	}
+	if assert.NotNil(t, manifest) && assert.Len(t, manifest.Files, 1) {
+		assert.Equal(t, "log.txt", manifest.Files[0].Path)
+	}
+}
+
+func TestArtifactScan_Validate(t *testing.T) {
+	assert.NoError(t, (&ArtifactScan{Dir: "out/bin", Include: []string{"*.tar.gz"}}).Validate())
+	assert.Error(t, (&ArtifactScan{Dir: "../x"}).Validate())
+	assert.Error(t, (&ArtifactScan{Dir: "/etc"}).Validate())
+	assert.Error(t, (&ArtifactScan{MaxFiles: maxArtifactMaxFiles + 1}).Validate())
+	assert.Error(t, (&ArtifactScan{Exclude: []string{"[a-"}}).Validate())
+}
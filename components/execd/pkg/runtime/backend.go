@@ -0,0 +1,42 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import "context"
+
+// Backend runs a request after the Controller has admitted it, i.e. after a
+// command has waited for its slot and transcript recording has been set up.
+// It reports progress through request.Hooks in the order the built-in
+// runners use, and returns an error only for requests it cannot start.
+//
+// The built-in backend spawns processes and talks to Jupyter; tests can swap
+// in a scripted one, see package runtimetest.
+type Backend interface {
+	Run(ctx context.Context, request *ExecuteCodeRequest) error
+}
+
+// SetBackend replaces the built-in runners with b for every language; nil
+// restores them. Contexts, command status and stdin are not routed through b.
+func (c *Controller) SetBackend(b Backend) {
+	c.backend = b
+}
+
+// run hands request to the configured backend, or to builtin.
+func (c *Controller) run(ctx context.Context, request *ExecuteCodeRequest, builtin func(context.Context, *ExecuteCodeRequest) error) error {
+	if c.backend != nil {
+		return c.backend.Run(ctx, request)
+	}
+	return builtin(ctx, request)
+}
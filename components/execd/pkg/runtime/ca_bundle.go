@@ -0,0 +1,143 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+)
+
+// caBundleEnvKeys point OpenSSL, Go, curl, Python requests and Node.js at the
+// bundle. NODE_EXTRA_CA_CERTS adds to Node's built-in roots; the others
+// replace the system bundle, which is why it is copied into the file.
+var caBundleEnvKeys = []string{"SSL_CERT_FILE", "REQUESTS_CA_BUNDLE", "NODE_EXTRA_CA_CERTS"}
+
+// systemCABundles are the usual locations of the system trust store, as
+// searched by crypto/x509 on Linux.
+var systemCABundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/pki/tls/cacert.pem",
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+	"/etc/ssl/cert.pem",
+}
+
+// ValidateCACerts checks that certs is one or more PEM-encoded X.509
+// certificates and nothing else.
+func ValidateCACerts(certs string) error {
+	if strings.TrimSpace(certs) == "" {
+		return nil
+	}
+	rest := []byte(certs)
+	count := 0
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("ca certs: unexpected PEM block %q", block.Type)
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return fmt.Errorf("ca certs: certificate %d: %w", count+1, err)
+		}
+		count++
+	}
+	if len(bytes.TrimSpace(rest)) > 0 {
+		return errors.New("ca certs: trailing data is not PEM")
+	}
+	if count == 0 {
+		return errors.New("ca certs: no PEM certificate found")
+	}
+	return nil
+}
+
+// caBundle is the CA file written for one command.
+type caBundle struct {
+	path string
+}
+
+// prepareCABundle writes the request's CA certs after the trust store the
+// command would otherwise use, and points the standard variables at the
+// file. It returns nil when the request has no certs.
+func prepareCABundle(session string, request *ExecuteCodeRequest, resolved *ResolvedCommand) (*caBundle, error) {
+	if strings.TrimSpace(request.CACerts) == "" {
+		return nil, nil
+	}
+	if err := ValidateCACerts(request.CACerts); err != nil {
+		return nil, err
+	}
+
+	var content []byte
+	if base := baseCABundle(resolved.env); base != "" {
+		data, err := os.ReadFile(base)
+		if err != nil {
+			request.Warn(WarningCABundle, "ca bundle for %s: system trust store %s unreadable, using the given certs only: %v", session, base, err)
+		} else {
+			content = append(data, '\n')
+		}
+	}
+	content = append(content, request.CACerts...)
+	if !strings.HasSuffix(request.CACerts, "\n") {
+		content = append(content, '\n')
+	}
+
+	path := filepath.Join(os.TempDir(), "execd-ca-"+session+".pem")
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return nil, fmt.Errorf("write ca bundle: %w", err)
+	}
+	extra := make(map[string]string, len(caBundleEnvKeys))
+	for _, key := range caBundleEnvKeys {
+		extra[key] = path
+	}
+	resolved.env = mergeEnvs(resolved.env, extra)
+	return &caBundle{path: path}, nil
+}
+
+// baseCABundle returns the bundle the command's environment already selects
+// through SSL_CERT_FILE, or else the first system bundle found.
+func baseCABundle(env []string) string {
+	for _, kv := range env {
+		if value, ok := strings.CutPrefix(kv, "SSL_CERT_FILE="); ok && value != "" {
+			return value
+		}
+	}
+	for _, path := range systemCABundles {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+	}
+	return ""
+}
+
+// release removes the bundle file.
+func (b *caBundle) release() {
+	if b == nil {
+		return
+	}
+	if err := os.Remove(b.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Error("failed to remove ca bundle %s: %v", b.path, err)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"os"
+	goruntime "runtime"
+)
+
+// Capability says whether an execution feature works on this execd.
+type Capability struct {
+	Available bool
+	// Reason explains why the feature is unavailable, or what restricts it
+	// when it is available.
+	Reason string
+}
+
+// Capabilities lists the command options that depend on the platform, the
+// privileges execd runs with and its configuration. Options not listed work
+// everywhere.
+type Capabilities struct {
+	OS   string
+	Arch string
+	// Privileged is set when execd runs as root, which mounts and raised
+	// limits need.
+	Privileged bool
+
+	Stdin        Capability
+	Wasm         Capability
+	ReadOnlyFS   Capability
+	Scratch      Capability
+	RLimitNofile Capability
+	Priority     Capability
+	ProcessTree  Capability
+}
+
+// capabilityProbe is what Capabilities are derived from.
+type capabilityProbe struct {
+	goos          string
+	goarch        string
+	privileged    bool
+	readOnlyFS    error // result of checkReadOnlyFS
+	wasmRuntime   error // lookup of the WASI runtime launcher
+	commandCgroup string
+	maxScratchMB  int
+}
+
+// Capabilities reports which command options can be used here, so callers
+// can avoid requesting ones that would fail or be ignored.
+func (c *Controller) Capabilities() Capabilities {
+	_, wasmErr := c.wasmLauncher()
+	return resolveCapabilities(capabilityProbe{
+		goos:          goruntime.GOOS,
+		goarch:        goruntime.GOARCH,
+		privileged:    os.Geteuid() == 0,
+		readOnlyFS:    checkReadOnlyFS(true),
+		wasmRuntime:   wasmErr,
+		commandCgroup: c.commandCgroup,
+		maxScratchMB:  c.maxScratchMB,
+	})
+}
+
+func resolveCapabilities(p capabilityProbe) Capabilities {
+	caps := Capabilities{OS: p.goos, Arch: p.goarch, Privileged: p.privileged}
+	linux := p.goos == "linux"
+
+	caps.Stdin = capability(p.goos != "windows", "stdin is not supported on windows")
+	caps.Wasm = capabilityErr(p.wasmRuntime)
+
+	if linux {
+		caps.ReadOnlyFS = capabilityErr(p.readOnlyFS)
+	} else {
+		caps.ReadOnlyFS = capability(false, "mount namespaces are only supported on linux")
+	}
+
+	switch {
+	case p.maxScratchMB <= 0:
+		caps.Scratch = capability(false, "scratch space is disabled by --max-scratch-mb")
+	case !linux:
+		caps.Scratch = capability(false, "tmpfs scratch space is only supported on linux")
+	case !p.privileged:
+		caps.Scratch = capability(false, "mounting tmpfs needs root; commands run without scratch space")
+	default:
+		caps.Scratch = capability(true, "")
+	}
+
+	caps.RLimitNofile = capability(linux, "open file limits are only supported on linux")
+	caps.Priority = capability(linux, "nice and io priority are only supported on linux")
+	if linux && !p.privileged {
+		caps.RLimitNofile.Reason = "limits above execd's hard limit need root"
+		caps.Priority.Reason = "negative nice values and the realtime io class need privileges"
+	}
+
+	switch {
+	case p.goos == "windows":
+		caps.ProcessTree = capability(true, "")
+	case !linux:
+		caps.ProcessTree = capability(false, "daemon tracking is only supported on linux and windows")
+	case p.commandCgroup == "":
+		caps.ProcessTree = capability(false, "no --command-cgroup is configured")
+	default:
+		caps.ProcessTree = capability(true, "")
+	}
+	return caps
+}
+
+// capability returns an available capability, or an unavailable one for reason.
+func capability(available bool, reason string) Capability {
+	if available {
+		return Capability{Available: true}
+	}
+	return Capability{Reason: reason}
+}
+
+func capabilityErr(err error) Capability {
+	if err == nil {
+		return Capability{Available: true}
+	}
+	return Capability{Reason: err.Error()}
+}
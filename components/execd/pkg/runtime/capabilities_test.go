@@ -0,0 +1,88 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveCapabilities_ByOS(t *testing.T) {
+	probe := func(goos string) capabilityProbe {
+		return capabilityProbe{goos: goos, goarch: "amd64", privileged: true, commandCgroup: "/sys/fs/cgroup/execd", maxScratchMB: 1024}
+	}
+
+	linux := resolveCapabilities(probe("linux"))
+	assert.Equal(t, "linux", linux.OS)
+	for name, c := range map[string]Capability{
+		"stdin": linux.Stdin, "wasm": linux.Wasm, "read_only_fs": linux.ReadOnlyFS, "scratch": linux.Scratch,
+		"rlimit_nofile": linux.RLimitNofile, "priority": linux.Priority, "process_tree": linux.ProcessTree,
+	} {
+		assert.True(t, c.Available, "%s should be available to a privileged execd on linux", name)
+		assert.Empty(t, c.Reason, name)
+	}
+
+	darwin := resolveCapabilities(probe("darwin"))
+	assert.True(t, darwin.Stdin.Available)
+	for name, c := range map[string]Capability{
+		"read_only_fs": darwin.ReadOnlyFS, "scratch": darwin.Scratch, "rlimit_nofile": darwin.RLimitNofile,
+		"priority": darwin.Priority, "process_tree": darwin.ProcessTree,
+	} {
+		assert.False(t, c.Available, "%s should not be available on darwin", name)
+		assert.NotEmpty(t, c.Reason, name)
+	}
+
+	windows := resolveCapabilities(probe("windows"))
+	assert.False(t, windows.Stdin.Available)
+	assert.False(t, windows.ReadOnlyFS.Available)
+	assert.True(t, windows.ProcessTree.Available, "commands always run in a job object on windows")
+}
+
+func TestResolveCapabilities_ByPrivilege(t *testing.T) {
+	p := capabilityProbe{goos: "linux", maxScratchMB: 1024, readOnlyFS: errors.New("mount namespaces are not available: operation not permitted")}
+	caps := resolveCapabilities(p)
+	assert.False(t, caps.Privileged)
+	assert.False(t, caps.Scratch.Available)
+	assert.False(t, caps.ReadOnlyFS.Available)
+	assert.Contains(t, caps.ReadOnlyFS.Reason, "operation not permitted")
+	assert.True(t, caps.RLimitNofile.Available)
+	assert.NotEmpty(t, caps.RLimitNofile.Reason, "the restriction of an unprivileged execd is explained")
+	assert.True(t, caps.Priority.Available)
+
+	p.privileged, p.readOnlyFS = true, nil
+	caps = resolveCapabilities(p)
+	assert.True(t, caps.Scratch.Available)
+	assert.True(t, caps.ReadOnlyFS.Available)
+	assert.Empty(t, caps.RLimitNofile.Reason)
+}
+
+func TestResolveCapabilities_ByConfiguration(t *testing.T) {
+	caps := resolveCapabilities(capabilityProbe{goos: "linux", privileged: true, wasmRuntime: errors.New(`exec: "wasmtime": executable file not found in $PATH`)})
+	assert.False(t, caps.Wasm.Available)
+	assert.Contains(t, caps.Wasm.Reason, "wasmtime")
+	assert.False(t, caps.Scratch.Available, "scratch space is off with --max-scratch-mb 0")
+	assert.False(t, caps.ProcessTree.Available, "daemons are tracked only with a command cgroup")
+}
+
+func TestController_CapabilitiesMatchesPlatform(t *testing.T) {
+	c := NewController("", "")
+	c.SetWasmRuntime("/nonexistent/wasm-runtime")
+	caps := c.Capabilities()
+	assert.False(t, caps.Wasm.Available)
+	assert.Equal(t, checkReadOnlyFS(true) == nil, caps.ReadOnlyFS.Available)
+	assert.Equal(t, nil == checkNofileLimit(1), caps.RLimitNofile.Available)
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+var errClassifyBackground = errors.New("exit classification is only supported for foreground commands")
+
+// ExitCategory is a caller-defined verdict on how a command ended, so
+// schedulers can decide on retries without knowing each tool's exit codes.
+type ExitCategory string
+
+const (
+	ExitSuccess   ExitCategory = "success"
+	ExitRetryable ExitCategory = "retryable"
+	ExitFatal     ExitCategory = "fatal"
+)
+
+// ExitOutcome is what a Classifier sees of a finished command.
+type ExitOutcome struct {
+	// ExitCode is the process exit code, -1 when it was killed by a signal
+	// or could not be waited for.
+	ExitCode int
+	// Signal is the name of the signal that killed the process, e.g.
+	// "SIGKILL"; empty when it exited.
+	Signal string
+	// Stderr is the tail of the command's stderr, at most 4 KiB.
+	Stderr string
+}
+
+// Classifier maps a command's outcome to a category.
+type Classifier func(outcome ExitOutcome) ExitCategory
+
+// ExitRules is a declarative Classifier for API callers. Exit code 0 and the
+// Success codes are success; a Retryable code, a RetryableSignals signal or
+// stderr containing a RetryableStderr substring is retryable; anything else
+// is fatal.
+type ExitRules struct {
+	Success          []int
+	Retryable        []int
+	RetryableSignals []string
+	RetryableStderr  []string
+}
+
+// Classify implements Classifier.
+func (r *ExitRules) Classify(outcome ExitOutcome) ExitCategory {
+	if outcome.Signal == "" && (outcome.ExitCode == 0 || slices.Contains(r.Success, outcome.ExitCode)) {
+		return ExitSuccess
+	}
+	if outcome.Signal == "" && slices.Contains(r.Retryable, outcome.ExitCode) {
+		return ExitRetryable
+	}
+	if outcome.Signal != "" && slices.ContainsFunc(r.RetryableSignals, func(sig string) bool {
+		return normalizeSignalName(sig) == outcome.Signal
+	}) {
+		return ExitRetryable
+	}
+	for _, needle := range r.RetryableStderr {
+		if needle != "" && strings.Contains(outcome.Stderr, needle) {
+			return ExitRetryable
+		}
+	}
+	return ExitFatal
+}
+
+// normalizeSignalName turns "kill" or "SIGKILL" into "SIGKILL".
+func normalizeSignalName(name string) string {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	return name
+}
+
+// ReportExit runs the request's Classifier, if any, on a finished foreground
+// command and passes the category to OnExecuteExitCategory. Backends call it
+// once, before the completion or error event.
+func (req *ExecuteCodeRequest) ReportExit(outcome ExitOutcome) {
+	if req.Classify == nil || req.Hooks.OnExecuteExitCategory == nil {
+		return
+	}
+	req.Hooks.OnExecuteExitCategory(req.Classify(outcome))
+}
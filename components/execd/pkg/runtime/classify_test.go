@@ -0,0 +1,127 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package runtime
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+func TestExitRules_Classify(t *testing.T) {
+	rules := &ExitRules{
+		Success:          []int{1},
+		Retryable:        []int{2, 75},
+		RetryableSignals: []string{"term", "SIGKILL"},
+		RetryableStderr:  []string{"connection reset"},
+	}
+	cases := []struct {
+		outcome ExitOutcome
+		want    ExitCategory
+	}{
+		{ExitOutcome{ExitCode: 0}, ExitSuccess},
+		{ExitOutcome{ExitCode: 1}, ExitSuccess},
+		{ExitOutcome{ExitCode: 2}, ExitRetryable},
+		{ExitOutcome{ExitCode: 75}, ExitRetryable},
+		{ExitOutcome{ExitCode: 3}, ExitFatal},
+		{ExitOutcome{ExitCode: 3, Stderr: "curl: (56) connection reset by peer"}, ExitRetryable},
+		{ExitOutcome{ExitCode: -1, Signal: "SIGTERM"}, ExitRetryable},
+		{ExitOutcome{ExitCode: -1, Signal: "SIGKILL"}, ExitRetryable},
+		{ExitOutcome{ExitCode: -1, Signal: "SIGSEGV"}, ExitFatal},
+	}
+	for _, c := range cases {
+		if got := rules.Classify(c.outcome); got != c.want {
+			t.Errorf("Classify(%+v) = %s, want %s", c.outcome, got, c.want)
+		}
+	}
+	if got := (&ExitRules{}).Classify(ExitOutcome{ExitCode: 2}); got != ExitFatal {
+		t.Errorf("expected a non-zero exit to be fatal without rules, got %s", got)
+	}
+}
+
+func TestExecute_CustomClassifierSeesExitCodeSignalAndStderr(t *testing.T) {
+	skipWithoutBash(t)
+	// a tool for which exit 2 means "try again" and 3 means "give up"
+	classify := func(o ExitOutcome) ExitCategory {
+		switch {
+		case o.Signal != "":
+			return ExitRetryable
+		case o.ExitCode == 0:
+			return ExitSuccess
+		case o.ExitCode == 2 && o.Stderr == "busy\n":
+			return ExitRetryable
+		default:
+			return ExitFatal
+		}
+	}
+	cases := map[string]struct {
+		want    ExitCategory
+		outcome ExitOutcome
+	}{
+		"true":                  {ExitSuccess, ExitOutcome{}},
+		"echo busy >&2; exit 2": {ExitRetryable, ExitOutcome{ExitCode: 2, Stderr: "busy\n"}},
+		"exit 3":                {ExitFatal, ExitOutcome{ExitCode: 3}},
+		"kill -KILL $$":         {ExitRetryable, ExitOutcome{ExitCode: -1, Signal: "SIGKILL"}},
+	}
+	c := NewController("", "")
+	for code, tc := range cases {
+		var seen []ExitOutcome
+		var categories []ExitCategory
+		var events []string
+		hooks := noopHooks()
+		hooks.OnExecuteExitCategory = func(category ExitCategory) {
+			categories = append(categories, category)
+			events = append(events, "category")
+		}
+		hooks.OnExecuteComplete = func(time.Duration) { events = append(events, "complete") }
+		hooks.OnExecuteError = func(*execute.ErrorOutput) { events = append(events, "error") }
+		req := &ExecuteCodeRequest{
+			Language: Command,
+			Code:     code,
+			Timeout:  5 * time.Second,
+			Classify: func(o ExitOutcome) ExitCategory { seen = append(seen, o); return classify(o) },
+			Hooks:    hooks,
+		}
+		if err := c.Execute(req); err != nil {
+			t.Fatalf("%s: Execute: %v", code, err)
+		}
+		if len(seen) != 1 || seen[0] != tc.outcome {
+			t.Fatalf("%s: classifier saw %+v, want %+v", code, seen, tc.outcome)
+		}
+		if len(categories) != 1 || categories[0] != tc.want {
+			t.Fatalf("%s: expected category %s, got %v", code, tc.want, categories)
+		}
+		if len(events) != 2 || events[0] != "category" {
+			t.Fatalf("%s: expected the category before the final event, got %v", code, events)
+		}
+	}
+}
+
+func TestExecute_ClassifyRejectsBackgroundCommands(t *testing.T) {
+	req := &ExecuteCodeRequest{
+		Language: BackgroundCommand,
+		Code:     "true",
+		Classify: (&ExitRules{}).Classify,
+		Hooks:    noopHooks(),
+	}
+	if err := NewController("", "").Execute(req); !errors.Is(err, errClassifyBackground) {
+		t.Fatalf("expected errClassifyBackground, got %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+	"github.com/alibaba/opensandbox/execd/pkg/util/safego"
+)
+
+// CloudEvents types of the execution lifecycle.
+const (
+	CloudEventExecutionStarted   = "io.opensandbox.execd.execution.started"
+	CloudEventExecutionCompleted = "io.opensandbox.execd.execution.completed"
+	CloudEventExecutionFailed    = "io.opensandbox.execd.execution.failed"
+)
+
+// cloudEventQueueSize bounds the events waiting to be published; newer ones
+// are dropped while a slow sink catches up.
+const cloudEventQueueSize = 1024
+
+var cloudEventClient = &http.Client{Timeout: 10 * time.Second}
+
+// cloudEvent is a CloudEvents 1.0 event in the structured JSON format.
+type cloudEvent struct {
+	SpecVersion     string              `json:"specversion"`
+	ID              string              `json:"id"`
+	Source          string              `json:"source"`
+	Type            string              `json:"type"`
+	Subject         string              `json:"subject,omitempty"`
+	Time            time.Time           `json:"time"`
+	DataContentType string              `json:"datacontenttype"`
+	Data            *ExecutionEventData `json:"data"`
+}
+
+// ExecutionEventData is the data of an execution lifecycle CloudEvent.
+type ExecutionEventData struct {
+	ExecutionID string   `json:"execution_id"`
+	Language    Language `json:"language"`
+	// ExitCode is the exit code of a command once it has ended.
+	ExitCode *int `json:"exit_code,omitempty"`
+	// DurationMs is how long the execution took; unset when it starts.
+	DurationMs *int64 `json:"duration_ms,omitempty"`
+	// Error is the error a failed execution reported.
+	Error *ExecutionEventError `json:"error,omitempty"`
+	// Warnings are the non-fatal conditions the execution met.
+	Warnings []ExecutionWarning `json:"warnings,omitempty"`
+}
+
+// ExecutionEventError summarizes the error of a failed execution.
+type ExecutionEventError struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// cloudEventEmitter publishes events to one sink, in order, from a worker of
+// its own so a slow or failing sink never holds up an execution.
+type cloudEventEmitter struct {
+	sink   string
+	source string
+	queue  chan *cloudEvent
+	stop   chan struct{}
+}
+
+// SetCloudEventSink publishes a CloudEvent to url, an HTTP endpoint taking
+// structured-mode events, when each execution starts and when it completes
+// or fails. Empty disables publishing. Failures to publish are logged and
+// never affect the execution.
+func (c *Controller) SetCloudEventSink(url string) {
+	c.cloudEventMu.Lock()
+	defer c.cloudEventMu.Unlock()
+
+	if c.cloudEvents != nil {
+		close(c.cloudEvents.stop)
+		c.cloudEvents = nil
+	}
+	if url == "" {
+		return
+	}
+	source := "/opensandbox/execd"
+	if hostname, err := os.Hostname(); err == nil {
+		source += "/" + hostname
+	}
+	e := &cloudEventEmitter{sink: url, source: source, queue: make(chan *cloudEvent, cloudEventQueueSize), stop: make(chan struct{})}
+	safego.Go(e.run)
+	c.cloudEvents = e
+}
+
+// run publishes queued events until the sink is replaced; events still
+// queued for the old sink then are dropped.
+func (e *cloudEventEmitter) run() {
+	for {
+		select {
+		case <-e.stop:
+			return
+		case event := <-e.queue:
+			if err := e.publish(event); err != nil {
+				log.Warning("failed to publish cloud event %s (%s) to %s: %v", event.ID, event.Type, e.sink, err)
+			}
+		}
+	}
+}
+
+func (e *cloudEventEmitter) publish(event *cloudEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.sink, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/cloudevents+json; charset=utf-8")
+	resp, err := cloudEventClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("sink answered %s", resp.Status)
+	}
+	return nil
+}
+
+// executionEvents follows one execution through its hooks and emits its
+// lifecycle events.
+type executionEvents struct {
+	emitter  *cloudEventEmitter
+	id       string
+	language Language
+
+	mu      sync.Mutex
+	session string
+	started time.Time
+	err     *execute.ErrorOutput
+}
+
+// newExecutionEvents returns nil when no sink is set.
+func (c *Controller) newExecutionEvents(request *ExecuteCodeRequest) *executionEvents {
+	c.cloudEventMu.Lock()
+	emitter := c.cloudEvents
+	c.cloudEventMu.Unlock()
+	if emitter == nil {
+		return nil
+	}
+	return &executionEvents{
+		emitter:  emitter,
+		id:       c.newContextID(),
+		language: request.Language,
+		session:  request.Context,
+		started:  time.Now(),
+	}
+}
+
+// wrap emits the started event once the execution has a session and
+// remembers the error it reports.
+func (x *executionEvents) wrap(hooks ExecuteResultHook) ExecuteResultHook {
+	wrapped := hooks
+	wrapped.OnExecuteInit = func(session string) {
+		x.mu.Lock()
+		x.session = session
+		x.mu.Unlock()
+		x.emit(CloudEventExecutionStarted, &ExecutionEventData{ExecutionID: x.id, Language: x.language})
+		if hooks.OnExecuteInit != nil {
+			hooks.OnExecuteInit(session)
+		}
+	}
+	wrapped.OnExecuteError = func(err *execute.ErrorOutput) {
+		x.mu.Lock()
+		x.err = err
+		x.mu.Unlock()
+		if hooks.OnExecuteError != nil {
+			hooks.OnExecuteError(err)
+		}
+	}
+	return wrapped
+}
+
+// finishExecutionEvents emits the completed or failed event, with every
+// warning of the execution, once it has ended; for a background command,
+// when its process exits.
+func (c *Controller) finishExecutionEvents(x *executionEvents, warnings []ExecutionWarning, execErr error) {
+	x.mu.Lock()
+	session, reported := x.session, x.err
+	x.mu.Unlock()
+
+	duration := time.Since(x.started).Milliseconds()
+	data := &ExecutionEventData{ExecutionID: x.id, Language: x.language, DurationMs: &duration, Warnings: warnings}
+	if x.language == Command || x.language == BackgroundCommand {
+		if kernel := c.commandSnapshot(session); kernel != nil && kernel.exitCode != nil {
+			code := *kernel.exitCode
+			data.ExitCode = &code
+		}
+	}
+	eventType := CloudEventExecutionCompleted
+	switch {
+	case reported != nil:
+		eventType = CloudEventExecutionFailed
+		data.Error = &ExecutionEventError{Name: reported.EName, Value: reported.EValue}
+	case execErr != nil:
+		eventType = CloudEventExecutionFailed
+		data.Error = &ExecutionEventError{Name: "ExecuteError", Value: execErr.Error()}
+	case x.language == BackgroundCommand && data.ExitCode != nil && *data.ExitCode != 0:
+		// nothing is left to report the failure of a background command
+		eventType = CloudEventExecutionFailed
+		data.Error = &ExecutionEventError{Name: "CommandExecError", Value: strconv.Itoa(*data.ExitCode)}
+	}
+	x.emit(eventType, data)
+}
+
+func (x *executionEvents) emit(eventType string, data *ExecutionEventData) {
+	x.mu.Lock()
+	session := x.session
+	x.mu.Unlock()
+
+	event := &cloudEvent{
+		SpecVersion:     "1.0",
+		ID:              uuid.New().String(),
+		Source:          x.emitter.source,
+		Type:            eventType,
+		Subject:         session,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data:            data,
+	}
+	select {
+	case x.emitter.queue <- event:
+	default:
+		log.Warning("dropped cloud event %s (%s): %d events are waiting for %s", event.ID, eventType, cloudEventQueueSize, x.emitter.sink)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+// eventReceiver is a stub CloudEvents sink that records what it is sent.
+type eventReceiver struct {
+	mu     sync.Mutex
+	events []map[string]any
+	status int
+}
+
+func newEventReceiver(t *testing.T, status int) (*eventReceiver, string) {
+	r := &eventReceiver{status: status}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "application/cloudevents+json; charset=utf-8", req.Header.Get("Content-Type"))
+		var event map[string]any
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&event))
+		r.mu.Lock()
+		r.events = append(r.events, event)
+		r.mu.Unlock()
+		w.WriteHeader(r.status)
+	}))
+	t.Cleanup(srv.Close)
+	return r, srv.URL
+}
+
+func (r *eventReceiver) snapshot() []map[string]any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]map[string]any(nil), r.events...)
+}
+
+func TestCloudEvents_CommandLifecycle(t *testing.T) {
+	skipWithoutBash(t)
+	receiver, url := newEventReceiver(t, http.StatusAccepted)
+	c := NewController("", "")
+	c.SetCloudEventSink(url)
+
+	run := func(code string) string {
+		var session string
+		req := &ExecuteCodeRequest{Language: Command, Code: code, Timeout: 5 * time.Second, Hooks: noopHooks()}
+		req.Hooks.OnExecuteInit = func(s string) { session = s }
+		require.NoError(t, c.Execute(req))
+		return session
+	}
+	ok := run("echo hi")
+	failed := run("exit 3")
+	require.Eventually(t, func() bool { return len(receiver.snapshot()) == 4 }, 5*time.Second, 10*time.Millisecond)
+
+	events := receiver.snapshot()
+	for _, event := range events {
+		assert.Equal(t, "1.0", event["specversion"])
+		assert.NotEmpty(t, event["id"])
+		assert.Contains(t, event["source"], "/opensandbox/execd")
+		assert.Equal(t, "application/json", event["datacontenttype"])
+		_, err := time.Parse(time.RFC3339, event["time"].(string))
+		assert.NoError(t, err)
+	}
+	assert.NotEqual(t, events[0]["id"], events[1]["id"], "every event has an id of its own")
+
+	assert.Equal(t, CloudEventExecutionStarted, events[0]["type"])
+	assert.Equal(t, ok, events[0]["subject"])
+	assert.NotContains(t, events[0]["data"], "duration_ms")
+	assert.Equal(t, CloudEventExecutionCompleted, events[1]["type"])
+	assert.Equal(t, ok, events[1]["subject"])
+	data := events[1]["data"].(map[string]any)
+	assert.Equal(t, float64(0), data["exit_code"])
+	assert.Contains(t, data, "duration_ms")
+	assert.Equal(t, string(Command), data["language"])
+	assert.Equal(t, events[0]["data"].(map[string]any)["execution_id"], data["execution_id"], "both events name the same execution")
+
+	assert.Equal(t, CloudEventExecutionStarted, events[2]["type"])
+	assert.Equal(t, CloudEventExecutionFailed, events[3]["type"])
+	assert.Equal(t, failed, events[3]["subject"])
+	data = events[3]["data"].(map[string]any)
+	assert.Equal(t, float64(3), data["exit_code"])
+	assert.Equal(t, map[string]any{"name": "CommandExecError", "value": "3"}, data["error"])
+}
+
+func TestCloudEvents_BackgroundCommandCompletesOnExit(t *testing.T) {
+	skipWithoutBash(t)
+	receiver, url := newEventReceiver(t, http.StatusAccepted)
+	c := NewController("", "")
+	c.SetCloudEventSink(url)
+
+	release := filepath.Join(t.TempDir(), "release")
+	req := &ExecuteCodeRequest{
+		Language: BackgroundCommand,
+		Code:     "while [ ! -e " + release + " ]; do sleep 0.05; done; exit 4",
+		Hooks:    noopHooks(),
+	}
+	require.NoError(t, c.Execute(req))
+	require.Eventually(t, func() bool { return len(receiver.snapshot()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	require.Len(t, receiver.snapshot(), 1, "a running background command has only started")
+	assert.Equal(t, CloudEventExecutionStarted, receiver.snapshot()[0]["type"])
+
+	require.NoError(t, os.WriteFile(release, nil, 0o644))
+	require.Eventually(t, func() bool { return len(receiver.snapshot()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	event := receiver.snapshot()[1]
+	assert.Equal(t, CloudEventExecutionFailed, event["type"])
+	data := event["data"].(map[string]any)
+	assert.Equal(t, float64(4), data["exit_code"])
+	assert.Equal(t, map[string]any{"name": "CommandExecError", "value": "4"}, data["error"])
+}
+
+func TestCloudEvents_SinkFailureDoesNotAffectExecution(t *testing.T) {
+	skipWithoutBash(t)
+	receiver, url := newEventReceiver(t, http.StatusInternalServerError)
+	c := NewController("", "")
+	c.SetCloudEventSink(url)
+
+	var stdout []string
+	var completed bool
+	req := &ExecuteCodeRequest{Language: Command, Code: "echo hi", Timeout: 5 * time.Second, Hooks: noopHooks()}
+	req.Hooks.OnExecuteStdout = func(s string) { stdout = append(stdout, s) }
+	req.Hooks.OnExecuteComplete = func(time.Duration) { completed = true }
+	req.Hooks.OnExecuteError = func(err *execute.ErrorOutput) { t.Errorf("unexpected error %s: %s", err.EName, err.EValue) }
+	require.NoError(t, c.Execute(req))
+	assert.Equal(t, []string{"hi"}, stdout)
+	assert.True(t, completed)
+	require.Eventually(t, func() bool { return len(receiver.snapshot()) == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	// an unreachable sink does not either
+	c.SetCloudEventSink("http://127.0.0.1:1")
+	completed = false
+	require.NoError(t, c.Execute(req))
+	assert.True(t, completed)
+
+	c.SetCloudEventSink("")
+	assert.Nil(t, c.newExecutionEvents(req), "empty disables publishing")
+}
@@ -24,11 +24,14 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	goruntime "runtime"
 	"strconv"
 	"sync"
 	"syscall"
 	"time"
 
+	"golang.org/x/sys/unix"
+
 	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
 	"github.com/alibaba/opensandbox/execd/pkg/log"
 	"github.com/alibaba/opensandbox/execd/pkg/util/safego"
@@ -36,7 +39,47 @@ import (
 
 // runCommand executes shell commands and streams their output.
 func (c *Controller) runCommand(ctx context.Context, request *ExecuteCodeRequest) error {
+	resolved := resolveCommand(goruntime.GOOS, request, c.commandExtraEnv(request))
+	if err := c.resolveWasm(request, resolved); err != nil {
+		return err
+	}
+	if err := checkNofileLimit(request.RLimitNofile); err != nil {
+		return err
+	}
+	if err := checkReadOnlyFS(request.ReadOnlyFS); err != nil {
+		return err
+	}
+	enc, err := outputEncoding(request.OutputEncoding)
+	if err != nil {
+		return err
+	}
+	if request.redactor != nil && request.OutputHighWatermark > 0 {
+		return errRedactHighWatermark
+	}
+	if request.DryRun {
+		return c.dryRunCommand(request, resolved)
+	}
+
 	session := c.newContextID()
+	scratch, err := c.prepareScratch(session, request, resolved)
+	if err != nil {
+		return err
+	}
+	defer scratch.release()
+	rofs, err := c.prepareReadOnlyFS(session, request, resolved, scratch)
+	if err != nil {
+		return err
+	}
+	defer rofs.release()
+	bundle, err := prepareCABundle(session, request, resolved)
+	if err != nil {
+		return err
+	}
+	defer bundle.release()
+	snap, err := snapshotWorkdir(request.WorkdirDiff, resolved.Cwd)
+	if err != nil {
+		return err
+	}
 
 	signals := make(chan os.Signal, 1)
 	defer close(signals)
@@ -52,36 +95,77 @@ func (c *Controller) runCommand(ctx context.Context, request *ExecuteCodeRequest
 
 	startAt := time.Now()
 	log.Info("received command: %v", request.Code)
-	cmd := exec.CommandContext(ctx, "bash", "-c", request.Code)
+	cmd := exec.CommandContext(ctx, resolved.Argv[0], resolved.Argv[1:]...)
 
-	cmd.Stdout = stdout
-	cmd.Stderr = stderr
-	cmd.Env = mergeEnvs(os.Environ(), loadExtraEnvFromFile())
+	cmd.Env = resolved.env
 
 	done := make(chan struct{}, 1)
 	var wg sync.WaitGroup
-	wg.Add(2)
-	safego.Go(func() {
-		defer wg.Done()
-		c.tailStdPipe(stdoutPath, request.Hooks.OnExecuteStdout, done)
-	})
-	safego.Go(func() {
-		defer wg.Done()
-		c.tailStdPipe(stderrPath, request.Hooks.OnExecuteStderr, done)
-	})
+	// without tailers nothing would open the gates, so backpressure needs them
+	var stdoutPipe, stderrPipe *outputPipe
+	var throttle *outputThrottle
+	if request.OutputSink == nil || request.OutputSink.Tee {
+		if stdoutPipe, err = newOutputPipe(stdout, request.OutputHighWatermark); err != nil {
+			return fmt.Errorf("failed to create stdout pipe: %w", err)
+		}
+		if stderrPipe, err = newOutputPipe(stderr, request.OutputHighWatermark); err != nil {
+			stdoutPipe.finish()
+			return fmt.Errorf("failed to create stderr pipe: %w", err)
+		}
+		throttle = newOutputThrottle(request.OutputRateLimit)
+		wg.Add(2)
+		safego.Go(func() {
+			defer wg.Done()
+			onStdout, flushDecoded := decodedOutput(request.Hooks.OnExecuteStdout, enc)
+			onStdout, flush := coalescedOutput(request.OutputCoalesce, onStdout, stdoutPipe.gateOrNil())
+			c.tailStdPipe(stdoutPath, onStdout, request.OutputCoalesce != nil, done, throttle, stdoutPipe.gateOrNil())
+			flush()
+			flushDecoded()
+		})
+		safego.Go(func() {
+			defer wg.Done()
+			onStderr, flushDecoded := decodedOutput(request.Hooks.OnExecuteStderr, enc)
+			onStderr, flush := coalescedOutput(request.OutputCoalesce, onStderr, stderrPipe.gateOrNil())
+			c.tailStdPipe(stderrPath, onStderr, request.OutputCoalesce != nil, done, throttle, stderrPipe.gateOrNil())
+			flush()
+			flushDecoded()
+		})
+	}
+	cmd.Stdout = stdoutPipe.writer(stdout)
+	cmd.Stderr = stderrPipe.writer(stderr)
+	sink := startOutputSink(request.OutputSink, request.redactor, stdoutPath, stderrPath, done)
 
-	cmd.Dir = request.Cwd
+	cmd.Dir = resolved.Cwd
 	// use a dedicated process group so signals propagate to children.
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	// the process group misses daemons that setsid; the tree does not
+	tree := c.newProcessTree(session)
+	tree.attach(cmd)
 
-	err = cmd.Start()
+	err = rofs.start(cmd)
+	tree.started(cmd)
+	stdoutPipe.started()
+	stderrPipe.started()
 	if err != nil {
+		tree.release()
+		stdoutPipe.finish()
+		stderrPipe.finish()
+		close(done)
 		request.Hooks.OnExecuteInit(session)
-		request.Hooks.OnExecuteError(&execute.ErrorOutput{EName: "CommandExecError", EValue: err.Error()})
+		c.runPostCommand(request, resolved)
+		traceback := []string{err.Error()}
+		if sinkErr := finishOutputSink(request, sink); sinkErr != nil {
+			traceback = append(traceback, sinkErr.Error())
+		}
+		if artifactErr := reportArtifacts(request, resolved.Cwd, startAt); artifactErr != nil {
+			traceback = append(traceback, artifactErr.Error())
+		}
+		request.Hooks.OnExecuteError(&execute.ErrorOutput{EName: "CommandExecError", EValue: err.Error(), Traceback: traceback})
 		log.Error("CommandExecError: error starting commands: %v", err)
 		return nil
 	}
 
+	applyPriority(cmd.Process.Pid, request)
 	kernel := &commandKernel{
 		pid:          cmd.Process.Pid,
 		stdoutPath:   stdoutPath,
@@ -90,6 +174,8 @@ func (c *Controller) runCommand(ctx context.Context, request *ExecuteCodeRequest
 		running:      true,
 		content:      request.Code,
 		isBackground: false,
+		group:        request.GroupID,
+		tree:         tree,
 	}
 	c.storeCommandKernel(session, kernel)
 	request.Hooks.OnExecuteInit(session)
@@ -103,8 +189,10 @@ func (c *Controller) runCommand(ctx context.Context, request *ExecuteCodeRequest
 				if sig == nil {
 					continue
 				}
-				// DO NOT forward syscall.SIGURG to children processes.
-				if sig != syscall.SIGCHLD && sig != syscall.SIGURG {
+				// DO NOT forward syscall.SIGURG to children processes. SIGPIPE
+				// is execd's own, from a client that went away mid-stream; it
+				// would kill a command that has nothing to do with that socket.
+				if sig != syscall.SIGCHLD && sig != syscall.SIGURG && sig != syscall.SIGPIPE {
 					_ = syscall.Kill(-cmd.Process.Pid, sig.(syscall.Signal))
 				}
 			}
@@ -112,8 +200,19 @@ func (c *Controller) runCommand(ctx context.Context, request *ExecuteCodeRequest
 	}()
 
 	err = cmd.Wait()
+	c.settleProcessTree(session, tree)
+	stdoutPipe.finish()
+	stderrPipe.finish()
 	close(done)
 	wg.Wait()
+	c.runPostCommand(request, resolved)
+	sinkErr := finishOutputSink(request, sink)
+	reportWorkdirDiff(request, snap)
+	outcome := commandExitOutcome(err, stderrPath)
+	request.ReportExit(outcome)
+	throttle.warnTruncated(request)
+	reportCommandSummary(request, outcome, cmd.ProcessState, stdoutPath, stderrPath, throttle.truncated())
+	artifactErr := reportArtifacts(request, resolved.Cwd, startAt)
 	if err != nil {
 		var eName, eValue string
 		var eCode int
@@ -131,6 +230,12 @@ func (c *Controller) runCommand(ctx context.Context, request *ExecuteCodeRequest
 			eCode = 1
 		}
 		traceback = []string{err.Error()}
+		if artifactErr != nil {
+			traceback = append(traceback, artifactErr.Error())
+		}
+		if sinkErr != nil {
+			traceback = append(traceback, sinkErr.Error())
+		}
 
 		request.Hooks.OnExecuteError(&execute.ErrorOutput{
 			EName:     eName,
@@ -144,17 +249,88 @@ func (c *Controller) runCommand(ctx context.Context, request *ExecuteCodeRequest
 	}
 
 	c.markCommandFinished(session, 0, "")
+	if sinkErr != nil {
+		request.Hooks.OnExecuteError(&execute.ErrorOutput{EName: "OutputSinkError", EValue: sinkErr.Error()})
+		return nil
+	}
+	if artifactErr != nil {
+		request.Hooks.OnExecuteError(&execute.ErrorOutput{EName: "ArtifactScanError", EValue: artifactErr.Error()})
+		return nil
+	}
 	request.Hooks.OnExecuteComplete(time.Since(startAt))
 	return nil
 }
 
 // runBackgroundCommand executes shell commands in detached mode.
+// OnExecuteComplete fires once the process has started; its exit status,
+// output tail and warnings are reported by GetCommandStatus after it finishes.
 func (c *Controller) runBackgroundCommand(_ context.Context, request *ExecuteCodeRequest) error {
+	resolved := resolveCommand(goruntime.GOOS, request, c.commandExtraEnv(request))
+	if err := c.resolveWasm(request, resolved); err != nil {
+		return err
+	}
+	if err := checkNofileLimit(request.RLimitNofile); err != nil {
+		return err
+	}
+	if err := checkReadOnlyFS(request.ReadOnlyFS); err != nil {
+		return err
+	}
+	if request.DryRun {
+		return c.dryRunCommand(request, resolved)
+	}
+
+	if request.OutputSink != nil {
+		return errOutputSinkBackground
+	}
+	if request.Artifacts != nil {
+		return errArtifactsBackground
+	}
+	if request.Classify != nil {
+		return errClassifyBackground
+	}
+	if request.PostCommand != nil {
+		return errPostCommandBackground
+	}
+	if request.OutputDigest != nil {
+		return errOutputDigestBackground
+	}
+	if request.CancelOnDisconnect {
+		return errCancelOnDisconnectBackground
+	}
+	if request.Summary {
+		return errSummaryBackground
+	}
+	if request.WorkdirDiff != nil {
+		return errWorkdirDiffBackground
+	}
+
 	session := c.newContextID()
+	scratch, err := c.prepareScratch(session, request, resolved)
+	if err != nil {
+		return err
+	}
+	rofs, err := c.prepareReadOnlyFS(session, request, resolved, scratch)
+	if err != nil {
+		scratch.release()
+		return err
+	}
+	bundle, err := prepareCABundle(session, request, resolved)
+	if err != nil {
+		rofs.release()
+		scratch.release()
+		return err
+	}
+	// release drops the command's scratch space, writable dir and CA bundle once it is done with them.
+	release := func() {
+		rofs.release()
+		scratch.release()
+		bundle.release()
+	}
 	request.Hooks.OnExecuteInit(session)
 
 	pipe, err := c.combinedOutputDescriptor(session)
 	if err != nil {
+		release()
 		return fmt.Errorf("failed to get combined output descriptor: %w", err)
 	}
 	stdoutPath := c.combinedOutputFileName(session)
@@ -167,44 +343,83 @@ func (c *Controller) runBackgroundCommand(_ context.Context, request *ExecuteCod
 
 	startAt := time.Now()
 	log.Info("received command: %v", request.Code)
-	cmd := exec.CommandContext(context.Background(), "bash", "-c", request.Code)
+	cmd := exec.CommandContext(context.Background(), resolved.Argv[0], resolved.Argv[1:]...)
 
-	cmd.Dir = request.Cwd
+	cmd.Dir = resolved.Cwd
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	tree := c.newProcessTree(session)
+	tree.attach(cmd)
 	cmd.Stdout = pipe
 	cmd.Stderr = pipe
-	cmd.Env = mergeEnvs(os.Environ(), loadExtraEnvFromFile())
+	cmd.Env = resolved.env
 
-	// use DevNull as stdin so interactive programs exit immediately.
-	cmd.Stdin = os.NewFile(uintptr(syscall.Stdin), os.DevNull)
+	// a nil stdin is the null device, so interactive programs exit
+	// immediately, unless the caller wants to feed input through WriteStdin.
+	// Wrapping fd 0 in an *os.File instead would close it once collected,
+	// along with whatever file has the number by then.
+	var stdin *commandStdin
+	if request.Stdin {
+		var stdinReader *os.File
+		stdin, stdinReader, err = newCommandStdin()
+		if err != nil {
+			pipe.Close()
+			tree.release()
+			release()
+			return err
+		}
+		defer stdinReader.Close()
+		cmd.Stdin = stdinReader
+	}
 
-	safego.Go(func() {
-		defer pipe.Close()
+	kernel := &commandKernel{
+		pid:          -1,
+		stdoutPath:   stdoutPath,
+		stderrPath:   stderrPath,
+		startedAt:    startAt,
+		running:      true,
+		content:      request.Code,
+		isBackground: true,
+		stdin:        stdin,
+		group:        request.GroupID,
+		tree:         tree,
+	}
 
-		err := cmd.Start()
-		kernel := &commandKernel{
-			pid:          -1,
-			stdoutPath:   stdoutPath,
-			stderrPath:   stderrPath,
-			startedAt:    startAt,
-			running:      true,
-			content:      request.Code,
-			isBackground: true,
+	// Start before reporting completion so the session is already pollable
+	// through GetCommandStatus when the caller sees OnExecuteComplete.
+	err = rofs.start(cmd)
+	tree.started(cmd)
+	if err != nil {
+		pipe.Close()
+		tree.release()
+		release()
+		if stdin != nil {
+			_ = stdin.close()
 		}
+		log.Error("CommandExecError: error starting commands: %v", err)
+		kernel.running = false
+		c.storeCommandKernel(session, kernel)
+		c.markCommandFinished(session, 255, err.Error())
+		request.Hooks.OnExecuteError(&execute.ErrorOutput{EName: "CommandExecError", EValue: err.Error()})
+		return nil
+	}
 
-		if err != nil {
-			log.Error("CommandExecError: error starting commands: %v", err)
-			kernel.running = false
-			c.storeCommandKernel(session, kernel)
-			c.markCommandFinished(session, 255, err.Error())
-			return
-		}
+	kernel.pid = cmd.Process.Pid
+	applyPriority(kernel.pid, request)
+	c.storeCommandKernel(session, kernel)
+	exited := make(chan struct{})
+	request.exited = exited
 
-		kernel.running = true
-		kernel.pid = cmd.Process.Pid
-		c.storeCommandKernel(session, kernel)
+	safego.Go(func() {
+		defer close(exited)
+		defer pipe.Close()
+		defer release()
 
-		err = cmd.Wait()
+		err := cmd.Wait()
+		c.settleProcessTree(session, tree)
+		if stdin != nil {
+			_ = stdin.close()
+		}
+		c.setCommandWarnings(session, request.warnings.finish())
 		if err != nil {
 			log.Error("CommandExecError: error running commands: %v", err)
 			exitCode := 1
@@ -221,3 +436,20 @@ func (c *Controller) runBackgroundCommand(_ context.Context, request *ExecuteCod
 	request.Hooks.OnExecuteComplete(time.Since(startAt))
 	return nil
 }
+
+// commandExitOutcome describes how a waited-for command ended.
+func commandExitOutcome(err error, stderrPath string) ExitOutcome {
+	outcome := ExitOutcome{Stderr: readFileTail(stderrPath, commandOutputTailBytes)}
+	var exitError *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitError):
+		outcome.ExitCode = exitError.ExitCode()
+		if status, ok := exitError.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			outcome.Signal = unix.SignalName(status.Signal())
+		}
+	default:
+		outcome.ExitCode = -1
+	}
+	return outcome
+}
@@ -17,15 +17,115 @@ package runtime
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
+	"unicode/utf8"
+
+	"github.com/alibaba/opensandbox/execd/pkg/log"
 )
 
-// tailStdPipe streams appended log data until the process finishes.
-func (c *Controller) tailStdPipe(file string, onExecute func(text string), done <-chan struct{}) {
+// shellArgv wraps code in the platform shell used to run commands on goos.
+func shellArgv(goos string, code string) []string {
+	if goos == "windows" {
+		return []string{"cmd", "/C", code}
+	}
+	return []string{"bash", "-c", code}
+}
+
+// resolveCommand assembles the invocation for a command request on goos,
+// overlaying extraEnv and then the request's own Envs onto the current
+// process environment.
+func resolveCommand(goos string, request *ExecuteCodeRequest, extraEnv map[string]string) *ResolvedCommand {
+	env := mergeEnvs(mergeEnvs(mergeEnvs(os.Environ(), extraEnv), request.Envs), localeEnv(request))
+
+	extraKeys := make([]string, 0, len(extraEnv))
+	for k := range extraEnv {
+		extraKeys = append(extraKeys, k)
+	}
+	sort.Strings(extraKeys)
+
+	code := request.Code
+	var nofile uint64
+	if goos == "linux" && request.RLimitNofile > 0 {
+		nofile = request.RLimitNofile
+		code = nofileLimitPrelude(nofile) + code
+	}
+	var umask string
+	if goos != "windows" && request.Umask != "" {
+		if mask, err := parseUmask(request.Umask); err == nil {
+			umask = fmt.Sprintf("%04o", mask)
+			code = "umask " + umask + "\n" + code
+		}
+	}
+
+	return &ResolvedCommand{
+		Argv:         shellArgv(goos, code),
+		Cwd:          request.Cwd,
+		RLimitNofile: nofile,
+		Umask:        umask,
+		Locale:       request.Locale,
+		Timezone:     request.Timezone,
+		EnvCount:     len(env),
+		ExtraEnvKeys: extraKeys,
+		env:          env,
+	}
+}
+
+// CheckRLimitNofile reports whether a command's open file limit can be
+// applied, so callers can reject the request before streaming starts.
+func (c *Controller) CheckRLimitNofile(limit uint64) error {
+	return checkNofileLimit(limit)
+}
+
+// CheckUmask reports whether a command's umask is a valid octal mask, so
+// callers can reject the request before streaming starts.
+func (c *Controller) CheckUmask(umask string) error {
+	if umask == "" {
+		return nil
+	}
+	_, err := parseUmask(umask)
+	return err
+}
+
+// parseUmask parses an octal file mode creation mask such as "077" or "0027".
+func parseUmask(umask string) (uint32, error) {
+	mask, err := strconv.ParseUint(umask, 8, 32)
+	if err != nil || len(umask) > 4 || mask > 0o777 {
+		return 0, fmt.Errorf("%w: %q is not an octal mask between 000 and 0777", ErrUmask, umask)
+	}
+	return uint32(mask), nil
+}
+
+// nofileLimitPrelude lowers the shell's soft and hard open file limit before
+// the user's code runs, so neither it nor its children can raise it again.
+// checkNofileLimit rejects limits that cannot be set up front; the fallback
+// covers whatever the kernel still refuses.
+func nofileLimitPrelude(limit uint64) string {
+	return fmt.Sprintf("ulimit -n %d || { echo \"execd: cannot set open file limit to %d\" >&2; exit 126; }\n", limit, limit)
+}
+
+// dryRunCommand reports the resolved invocation and completes without starting a process.
+func (c *Controller) dryRunCommand(request *ExecuteCodeRequest, resolved *ResolvedCommand) error {
+	startAt := time.Now()
+	log.Info("dry-run command: %v", resolved.Argv)
+	request.Hooks.OnExecuteDryRun(resolved)
+	request.Hooks.OnExecuteComplete(time.Since(startAt))
+	return nil
+}
+
+// tailStdPipe streams appended log data until the process finishes. A non-nil
+// throttle paces delivery, shared by every stream of the command; a non-nil
+// gate is told how far the file has been delivered, and wakes the tailer as
+// soon as the command wrote more instead of on the next tick. With verbatim,
+// lines are delivered with their terminators, see readFromPosLimited.
+func (c *Controller) tailStdPipe(file string, onExecute func(text string), verbatim bool, done <-chan struct{}, throttle *outputThrottle, gate *outputGate) {
+	tail := throttle.tail(verbatim)
 	lastPos := int64(0)
 	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
@@ -34,12 +134,14 @@ func (c *Controller) tailStdPipe(file string, onExecute func(text string), done
 	for {
 		select {
 		case <-done:
-			c.readFromPos(mutex, file, lastPos, onExecute, true)
+			c.readFromPosLimited(mutex, file, lastPos, onExecute, true, tail, gate, verbatim)
 			return
 		case <-ticker.C:
-			newPos := c.readFromPos(mutex, file, lastPos, onExecute, false)
-			lastPos = newPos
+		case <-gate.writes():
 		}
+		newPos := c.readFromPosLimited(mutex, file, lastPos, onExecute, false, tail, gate, verbatim)
+		lastPos = newPos
+		gate.advance(newPos)
 	}
 }
 
@@ -93,6 +195,23 @@ func (c *Controller) combinedOutputFileName(session string) string {
 
 // readFromPos streams new content from a file starting at startPos.
 func (c *Controller) readFromPos(mutex *sync.Mutex, filepath string, startPos int64, onExecute func(string), flushIncomplete bool) int64 {
+	return c.readFromPosThrottled(mutex, filepath, startPos, onExecute, flushIncomplete, nil)
+}
+
+// readFromPosThrottled is readFromPos with delivery paced by tail; a nil tail
+// delivers every line immediately.
+func (c *Controller) readFromPosThrottled(mutex *sync.Mutex, filepath string, startPos int64, onExecute func(string), flushIncomplete bool, tail *throttledTail) int64 {
+	return c.readFromPosLimited(mutex, filepath, startPos, onExecute, flushIncomplete, tail, nil, false)
+}
+
+// readFromPosLimited is readFromPosThrottled for a stream behind gate: a line
+// reaching the gate's maxLine is delivered without waiting for its end, cut on
+// a rune boundary, and the gate is advanced after every delivery, so the
+// writer gets room back without waiting for the whole pass. A nil gate is
+// unlimited. Lines are delivered without their terminators and empty ones are
+// skipped, unless verbatim: then every line keeps its terminator, so
+// concatenating what was delivered gives back the output as written.
+func (c *Controller) readFromPosLimited(mutex *sync.Mutex, filepath string, startPos int64, onExecute func(string), flushIncomplete bool, tail *throttledTail, gate *outputGate, verbatim bool) int64 {
 	if !mutex.TryLock() {
 		return -1
 	}
@@ -109,6 +228,7 @@ func (c *Controller) readFromPos(mutex *sync.Mutex, filepath string, startPos in
 	reader := bufio.NewReader(file)
 	var buffer bytes.Buffer
 	var currentPos int64 = startPos
+	maxLine := gate.maxLine()
 
 	for {
 		b, err := reader.ReadByte()
@@ -116,9 +236,10 @@ func (c *Controller) readFromPos(mutex *sync.Mutex, filepath string, startPos in
 			if err == io.EOF {
 				// If buffer has content but no newline, flush if needed, otherwise wait for next read
 				if flushIncomplete && buffer.Len() > 0 {
-					onExecute(buffer.String())
+					tail.deliver(onExecute, buffer.String())
 					buffer.Reset()
 				}
+				tail.flushDropped(onExecute)
 			}
 			break
 		}
@@ -126,16 +247,40 @@ func (c *Controller) readFromPos(mutex *sync.Mutex, filepath string, startPos in
 
 		// Check if it's a line terminator (\n or \r)
 		if b == '\n' || b == '\r' {
+			if tail.skipping() {
+				tail.endSkip()
+				continue
+			}
+			lineLen := buffer.Len()
+			if verbatim {
+				buffer.WriteByte(b)
+			}
 			// If buffer has content, output this line
 			if buffer.Len() > 0 {
-				onExecute(buffer.String())
+				if pos, skipped := tail.skipBacklog(file, currentPos, lineLen); skipped {
+					reader.Reset(file)
+					currentPos = pos
+				} else {
+					tail.deliver(onExecute, buffer.String())
+				}
 				buffer.Reset()
+				gate.advance(currentPos)
 			}
 			// Skip line terminator
 			continue
 		}
 
+		if tail.skipping() {
+			tail.drop(1)
+			continue
+		}
 		buffer.WriteByte(b)
+		if maxLine > 0 && buffer.Len() >= maxLine {
+			if cut := completeRunes(buffer.Bytes()); cut > 0 {
+				tail.deliver(onExecute, string(buffer.Next(cut)))
+				gate.advance(currentPos - int64(buffer.Len()))
+			}
+		}
 	}
 
 	endPos, _ := file.Seek(0, 1)
@@ -145,3 +290,17 @@ func (c *Controller) readFromPos(mutex *sync.Mutex, filepath string, startPos in
 	}
 	return endPos
 }
+
+// completeRunes returns the length of b without a UTF-8 sequence cut off at
+// its end.
+func completeRunes(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if utf8.FullRune(b[i:]) {
+				return len(b)
+			}
+			return i
+		}
+	}
+	return len(b)
+}
@@ -0,0 +1,192 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"container/list"
+	"context"
+)
+
+// strideScale is the virtual time a weight-1 group is charged per command;
+// heavier groups are charged proportionally less.
+const strideScale = 1 << 20
+
+// commandWaiter is a queued request for a command slot. ready is closed once
+// the slot has been handed over.
+type commandWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// commandGroup is one tenant's FIFO of waiters. pass is the virtual time at
+// which its next command is due; the backlogged group with the lowest pass goes
+// next, so slots are shared in proportion to weight instead of arrival order.
+type commandGroup struct {
+	name    string
+	waiters list.List
+	pass    uint64
+	// seq orders groups with equal pass by when they became backlogged.
+	seq uint64
+}
+
+// SetMaxConcurrentCommands caps foreground commands running at once; extra
+// requests wait, shared fairly across groups. Zero or less means unlimited.
+func (c *Controller) SetMaxConcurrentCommands(limit int) {
+	c.commandSlotMu.Lock()
+	defer c.commandSlotMu.Unlock()
+
+	c.maxCommands = limit
+}
+
+// SetCommandGroupWeights sets each group's share of command slots while
+// requests are queued. Groups not listed, including the default empty group,
+// weigh 1; a group weighing 3 starts three commands for each one of a group
+// weighing 1 when both are backlogged.
+func (c *Controller) SetCommandGroupWeights(weights map[string]int) {
+	c.commandSlotMu.Lock()
+	defer c.commandSlotMu.Unlock()
+
+	c.commandWeights = make(map[string]int, len(weights))
+	for group, weight := range weights {
+		if weight > 0 {
+			c.commandWeights[group] = weight
+		}
+	}
+}
+
+// acquireCommandSlot blocks until a command of group may start or ctx is done.
+// A request cancelled while queued leaves the queue without taking a slot. The
+// returned release must be called once the command has finished.
+func (c *Controller) acquireCommandSlot(ctx context.Context, group string) (func(), error) {
+	c.commandSlotMu.Lock()
+	if c.maxCommands <= 0 {
+		c.commandSlotMu.Unlock()
+		return func() {}, nil
+	}
+	if err := ctx.Err(); err != nil {
+		c.commandSlotMu.Unlock()
+		return nil, err
+	}
+	if c.runningCommands < c.maxCommands && c.queuedCommandCount == 0 {
+		// nobody is waiting, so there is no share to account for.
+		c.runningCommands++
+		c.commandSlotMu.Unlock()
+		return c.releaseCommandSlot, nil
+	}
+
+	g := c.commandGroupLocked(group)
+	if g.waiters.Len() == 0 {
+		// a group returning from idle must not spend credit saved while idle.
+		g.pass = max(g.pass, c.commandVirtualTime)
+		c.commandGroupSeq++
+		g.seq = c.commandGroupSeq
+	}
+	waiter := &commandWaiter{ready: make(chan struct{})}
+	elem := g.waiters.PushBack(waiter)
+	c.queuedCommandCount++
+	c.commandSlotMu.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return c.releaseCommandSlot, nil
+	case <-ctx.Done():
+	}
+
+	c.commandSlotMu.Lock()
+	if waiter.granted {
+		// the slot was handed over while ctx fired; pass it on.
+		c.commandSlotMu.Unlock()
+		c.releaseCommandSlot()
+	} else {
+		g.waiters.Remove(elem)
+		c.queuedCommandCount--
+		c.commandSlotMu.Unlock()
+	}
+	return nil, ctx.Err()
+}
+
+// releaseCommandSlot hands the slot to the head of the group that is furthest
+// behind its fair share, or frees it.
+func (c *Controller) releaseCommandSlot() {
+	c.commandSlotMu.Lock()
+	defer c.commandSlotMu.Unlock()
+
+	var next *commandGroup
+	for name, g := range c.commandGroups {
+		if g.waiters.Len() == 0 {
+			// an idle group owing nothing would rejoin at the virtual time anyway.
+			if g.pass <= c.commandVirtualTime {
+				delete(c.commandGroups, name)
+			}
+			continue
+		}
+		if next == nil || g.pass < next.pass || (g.pass == next.pass && g.seq < next.seq) {
+			next = g
+		}
+	}
+	if next == nil {
+		c.runningCommands--
+		return
+	}
+
+	waiter := next.waiters.Remove(next.waiters.Front()).(*commandWaiter)
+	c.queuedCommandCount--
+	c.commandVirtualTime = next.pass
+	c.chargeCommandGroup(next)
+	waiter.granted = true
+	close(waiter.ready)
+}
+
+// commandGroupLocked returns group's queue state, creating it on first use.
+func (c *Controller) commandGroupLocked(group string) *commandGroup {
+	if c.commandGroups == nil {
+		c.commandGroups = make(map[string]*commandGroup)
+	}
+	g, ok := c.commandGroups[group]
+	if !ok {
+		g = &commandGroup{name: group, pass: c.commandVirtualTime}
+		c.commandGroups[group] = g
+	}
+	return g
+}
+
+// chargeCommandGroup advances g's pass by one command at its weight.
+func (c *Controller) chargeCommandGroup(g *commandGroup) {
+	weight := max(c.commandWeights[g.name], 1)
+	g.pass = max(g.pass, c.commandVirtualTime) + strideScale/uint64(weight)
+}
+
+// queuedCommands reports how many commands are waiting for a slot.
+func (c *Controller) queuedCommands() int {
+	c.commandSlotMu.Lock()
+	defer c.commandSlotMu.Unlock()
+
+	return c.queuedCommandCount
+}
+
+// waitCommandSlot queues for a slot in group until either caller or the
+// execution context is done.
+func (c *Controller) waitCommandSlot(caller, ctx context.Context, group string) (func(), error) {
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(caller, cancel)
+	defer stop()
+
+	release, err := c.acquireCommandSlot(waitCtx, group)
+	if err != nil && caller.Err() != nil {
+		err = caller.Err()
+	}
+	return release, err
+}
@@ -0,0 +1,279 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+func waitForQueued(t *testing.T, c *Controller, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for c.queuedCommands() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d queued commands, got %d", want, c.queuedCommands())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAcquireCommandSlot_CancelledWaiterLeavesQueue(t *testing.T) {
+	c := NewController("", "")
+	c.SetMaxConcurrentCommands(1)
+
+	holder, err := c.acquireCommandSlot(context.Background(), "")
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	errA := make(chan error, 1)
+	go func() {
+		_, err := c.acquireCommandSlot(ctxA, "")
+		errA <- err
+	}()
+	waitForQueued(t, c, 1)
+
+	gotB := make(chan func(), 1)
+	go func() {
+		release, err := c.acquireCommandSlot(context.Background(), "")
+		if err != nil {
+			t.Errorf("second waiter: %v", err)
+		}
+		gotB <- release
+	}()
+	waitForQueued(t, c, 2)
+
+	cancelA()
+	if err := <-errA; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	waitForQueued(t, c, 1)
+
+	holder()
+	releaseB := <-gotB
+	if c.runningCommands != 1 {
+		t.Fatalf("expected slot handed to the next waiter, running=%d", c.runningCommands)
+	}
+	releaseB()
+	if c.runningCommands != 0 || c.queuedCommands() != 0 {
+		t.Fatalf("expected idle limiter, running=%d queued=%d", c.runningCommands, c.queuedCommands())
+	}
+}
+
+func TestExecuteContext_CancelledWhileQueuedNeverStarts(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("bash not available on windows")
+	}
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found in PATH")
+	}
+
+	const total = 16
+
+	c := NewController("", "")
+	c.SetMaxConcurrentCommands(2)
+	dir := t.TempDir()
+
+	// Occupy every slot so all requests below queue up.
+	var holders []func()
+	for i := 0; i < 2; i++ {
+		release, err := c.acquireCommandSlot(context.Background(), "")
+		if err != nil {
+			t.Fatalf("acquire holder: %v", err)
+		}
+		holders = append(holders, release)
+	}
+
+	type outcome struct {
+		mu       sync.Mutex
+		started  bool
+		errNames []string
+	}
+	outcomes := make([]*outcome, total)
+	cancels := make([]context.CancelFunc, total)
+
+	var wg sync.WaitGroup
+	for i := 0; i < total; i++ {
+		o := &outcome{}
+		outcomes[i] = o
+		caller, cancel := context.WithCancel(context.Background())
+		cancels[i] = cancel
+
+		req := &ExecuteCodeRequest{
+			Language: Command,
+			Code:     fmt.Sprintf("touch %s", filepath.Join(dir, fmt.Sprint(i))),
+			Timeout:  10 * time.Second,
+			Hooks: ExecuteResultHook{
+				OnExecuteInit: func(string) {
+					o.mu.Lock()
+					o.started = true
+					o.mu.Unlock()
+				},
+				OnExecuteStdout: func(string) {},
+				OnExecuteStderr: func(string) {},
+				OnExecuteError: func(err *execute.ErrorOutput) {
+					o.mu.Lock()
+					o.errNames = append(o.errNames, err.EName)
+					o.mu.Unlock()
+				},
+				OnExecuteComplete: func(time.Duration) {},
+			},
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.ExecuteContext(caller, req); err != nil {
+				t.Errorf("ExecuteContext returned error: %v", err)
+			}
+		}()
+	}
+	waitForQueued(t, c, total)
+
+	// Cancel every other request concurrently while the queue is full.
+	var cancelWG sync.WaitGroup
+	for i := 0; i < total; i += 2 {
+		cancelWG.Add(1)
+		go func(i int) {
+			defer cancelWG.Done()
+			cancels[i]()
+		}(i)
+	}
+	cancelWG.Wait()
+	waitForQueued(t, c, total/2)
+
+	for _, release := range holders {
+		release()
+	}
+	wg.Wait()
+
+	for i, o := range outcomes {
+		_, statErr := os.Stat(filepath.Join(dir, fmt.Sprint(i)))
+		if i%2 == 0 {
+			if o.started || statErr == nil {
+				t.Fatalf("request %d was cancelled while queued but started", i)
+			}
+			if len(o.errNames) != 1 || o.errNames[0] != "Cancelled" {
+				t.Fatalf("request %d: expected a single Cancelled event, got %v", i, o.errNames)
+			}
+			continue
+		}
+		if !o.started || statErr != nil {
+			t.Fatalf("request %d should have run: started=%v stat=%v", i, o.started, statErr)
+		}
+		if len(o.errNames) != 0 {
+			t.Fatalf("request %d: unexpected errors %v", i, o.errNames)
+		}
+		cancels[i]()
+	}
+
+	if c.runningCommands != 0 || c.queuedCommands() != 0 {
+		t.Fatalf("expected idle limiter, running=%d queued=%d", c.runningCommands, c.queuedCommands())
+	}
+}
+
+// queueGroups fills a single-slot limiter with waiters of each group in order and
+// returns the groups in the order slots are granted.
+func queueGroups(t *testing.T, c *Controller, groups []string) []string {
+	t.Helper()
+
+	holder, err := c.acquireCommandSlot(context.Background(), "")
+	if err != nil {
+		t.Fatalf("acquire holder: %v", err)
+	}
+	type grant struct {
+		group   string
+		release func()
+	}
+	grants := make(chan grant, len(groups))
+	for i, group := range groups {
+		go func() {
+			release, err := c.acquireCommandSlot(context.Background(), group)
+			if err != nil {
+				t.Errorf("acquire %s: %v", group, err)
+			}
+			grants <- grant{group: group, release: release}
+		}()
+		waitForQueued(t, c, i+1)
+	}
+
+	holder()
+	order := make([]string, 0, len(groups))
+	for range groups {
+		g := <-grants
+		order = append(order, g.group)
+		g.release()
+	}
+	if c.runningCommands != 0 || c.queuedCommands() != 0 {
+		t.Fatalf("expected idle limiter, running=%d queued=%d", c.runningCommands, c.queuedCommands())
+	}
+	return order
+}
+
+func TestAcquireCommandSlot_FloodingGroupDoesNotStarveOthers(t *testing.T) {
+	c := NewController("", "")
+	c.SetMaxConcurrentCommands(1)
+
+	var groups []string
+	for i := 0; i < 20; i++ {
+		groups = append(groups, "noisy")
+	}
+	groups = append(groups, "quiet", "quiet")
+
+	order := queueGroups(t, c, groups)
+	// FIFO would serve quiet only after all 20 noisy requests.
+	want := []string{"noisy", "quiet", "noisy", "quiet", "noisy"}
+	for i, group := range want {
+		if order[i] != group {
+			t.Fatalf("expected groups to alternate, got %v", order)
+		}
+	}
+}
+
+func TestAcquireCommandSlot_SharesSlotsByWeight(t *testing.T) {
+	c := NewController("", "")
+	c.SetMaxConcurrentCommands(1)
+	c.SetCommandGroupWeights(map[string]int{"heavy": 3, "ignored": 0})
+
+	var groups []string
+	for i := 0; i < 8; i++ {
+		groups = append(groups, "light", "heavy")
+	}
+
+	order := queueGroups(t, c, groups)
+	heavy := 0
+	for _, group := range order[:8] {
+		if group == "heavy" {
+			heavy++
+		}
+	}
+	if heavy != 6 {
+		t.Fatalf("expected heavy to get 6 of the first 8 slots, got %d: %v", heavy, order)
+	}
+	if _, ok := c.commandWeights["ignored"]; ok {
+		t.Fatalf("expected non-positive weight to be dropped")
+	}
+}
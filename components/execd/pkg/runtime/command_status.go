@@ -21,15 +21,31 @@ import (
 	"time"
 )
 
+// commandOutputTailBytes bounds the output tail reported for finished background commands.
+const commandOutputTailBytes = 4096
+
+// CommandState is the coarse lifecycle state of a command session.
+type CommandState string
+
+const (
+	CommandStateRunning CommandState = "running"
+	CommandStateExited  CommandState = "exited"
+)
+
 // CommandStatus describes the lifecycle state of a command.
 type CommandStatus struct {
-	Session    string     `json:"session"`
-	Running    bool       `json:"running"`
-	ExitCode   *int       `json:"exit_code,omitempty"`
-	Error      string     `json:"error,omitempty"`
-	StartedAt  time.Time  `json:"started_at,omitempty"`
-	FinishedAt *time.Time `json:"finished_at,omitempty"`
-	Content    string     `json:"content,omitempty"`
+	Session    string       `json:"session"`
+	State      CommandState `json:"state"`
+	Running    bool         `json:"running"`
+	ExitCode   *int         `json:"exit_code,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	StartedAt  time.Time    `json:"started_at,omitempty"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+	Content    string       `json:"content,omitempty"`
+	// OutputTail holds the last bytes of combined output once a background command exits.
+	OutputTail string `json:"output_tail,omitempty"`
+	// Warnings are the non-fatal conditions a background command met, set once it exits.
+	Warnings []ExecutionWarning `json:"warnings,omitempty"`
 }
 
 // CommandOutput contains non-streamed stdout/stderr plus status.
@@ -61,6 +77,7 @@ func (c *Controller) GetCommandStatus(session string) (*CommandStatus, error) {
 
 	status := &CommandStatus{
 		Session:    session,
+		State:      CommandStateRunning,
 		Running:    kernel.running,
 		ExitCode:   kernel.exitCode,
 		Error:      kernel.errMsg,
@@ -68,9 +85,39 @@ func (c *Controller) GetCommandStatus(session string) (*CommandStatus, error) {
 		FinishedAt: kernel.finishedAt,
 		Content:    kernel.content,
 	}
+	if !kernel.running {
+		status.State = CommandStateExited
+		if kernel.isBackground {
+			status.OutputTail = readFileTail(kernel.stdoutPath, commandOutputTailBytes)
+			status.Warnings = kernel.warnings
+		}
+	}
 	return status, nil
 }
 
+// readFileTail returns up to limit trailing bytes of path, or "" if it cannot be read.
+func readFileTail(path string, limit int64) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return ""
+	}
+	offset := info.Size() - limit
+	if offset < 0 {
+		offset = 0
+	}
+	data, err := io.ReadAll(io.NewSectionReader(file, offset, info.Size()-offset))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
 // SeekBackgroundCommandOutput returns accumulated stdout/stderr and status for a session.
 func (c *Controller) SeekBackgroundCommandOutput(session string, cursor int64) ([]byte, int64, error) {
 	kernel := c.commandSnapshot(session)
@@ -110,6 +157,16 @@ func (c *Controller) SeekBackgroundCommandOutput(session string, cursor int64) (
 }
 
 // markCommandFinished updates bookkeeping when a command exits.
+// setCommandWarnings records the warnings of a background command that is
+// about to be marked finished.
+func (c *Controller) setCommandWarnings(session string, warnings []ExecutionWarning) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if kernel := c.commandClientMap[session]; kernel != nil {
+		kernel.warnings = warnings
+	}
+}
+
 func (c *Controller) markCommandFinished(session string, exitCode int, errMsg string) {
 	now := time.Now()
 
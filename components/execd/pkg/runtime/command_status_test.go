@@ -17,10 +17,13 @@ package runtime
 import (
 	"context"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
 )
 
 func TestGetCommandStatus_NotFound(t *testing.T) {
@@ -185,3 +188,110 @@ func TestSeekBackgroundCommandOutput_WithRunBackgroundCommand(t *testing.T) {
 		t.Fatalf("cursor should not move backwards: got %d < %d", cursor2, cursor)
 	}
 }
+
+func TestGetCommandStatus_BackgroundTransitionsToExited(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found in PATH")
+	}
+	c := NewController("", "")
+
+	var (
+		session   string
+		completed bool
+	)
+	req := &ExecuteCodeRequest{
+		Language: BackgroundCommand,
+		Code:     "echo started; sleep 0.5; echo bye; exit 7",
+		Hooks: ExecuteResultHook{
+			OnExecuteInit:     func(id string) { session = id },
+			OnExecuteComplete: func(time.Duration) { completed = true },
+		},
+	}
+	if err := c.runBackgroundCommand(context.Background(), req); err != nil {
+		t.Fatalf("runBackgroundCommand error: %v", err)
+	}
+	if !completed {
+		t.Fatalf("expected OnExecuteComplete after launch")
+	}
+
+	// The session must be registered by the time the launch is reported.
+	status, err := c.GetCommandStatus(session)
+	if err != nil {
+		t.Fatalf("GetCommandStatus right after launch: %v", err)
+	}
+	if status.State != CommandStateRunning || !status.Running || status.ExitCode != nil {
+		t.Fatalf("expected running status, got %+v", status)
+	}
+	if status.OutputTail != "" {
+		t.Fatalf("expected no output tail while running, got %q", status.OutputTail)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for status.Running && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		if status, err = c.GetCommandStatus(session); err != nil {
+			t.Fatalf("GetCommandStatus error: %v", err)
+		}
+	}
+	if status.State != CommandStateExited || status.Running {
+		t.Fatalf("expected exited status, got %+v", status)
+	}
+	if status.ExitCode == nil || *status.ExitCode != 7 {
+		t.Fatalf("expected exit code 7, got %v", status.ExitCode)
+	}
+	if status.FinishedAt == nil {
+		t.Fatalf("expected finishedAt to be set")
+	}
+	if status.OutputTail != "started\nbye\n" {
+		t.Fatalf("unexpected output tail: %q", status.OutputTail)
+	}
+}
+
+func TestGetCommandStatus_BackgroundStartFailure(t *testing.T) {
+	c := NewController("", "")
+
+	var (
+		session string
+		execErr *execute.ErrorOutput
+	)
+	req := &ExecuteCodeRequest{
+		Language: BackgroundCommand,
+		Code:     "true",
+		Cwd:      filepath.Join(t.TempDir(), "missing"),
+		Hooks: ExecuteResultHook{
+			OnExecuteInit:     func(id string) { session = id },
+			OnExecuteError:    func(err *execute.ErrorOutput) { execErr = err },
+			OnExecuteComplete: func(time.Duration) { t.Fatalf("launch failure must not report completion") },
+		},
+	}
+	if err := c.runBackgroundCommand(context.Background(), req); err != nil {
+		t.Fatalf("runBackgroundCommand error: %v", err)
+	}
+	if execErr == nil {
+		t.Fatalf("expected OnExecuteError for start failure")
+	}
+
+	status, err := c.GetCommandStatus(session)
+	if err != nil {
+		t.Fatalf("GetCommandStatus error: %v", err)
+	}
+	if status.State != CommandStateExited || status.ExitCode == nil || *status.ExitCode != 255 {
+		t.Fatalf("expected exited with code 255, got %+v", status)
+	}
+}
+
+func TestReadFileTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out")
+	if err := os.WriteFile(path, []byte("0123456789"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if got := readFileTail(path, 4); got != "6789" {
+		t.Fatalf("expected last 4 bytes, got %q", got)
+	}
+	if got := readFileTail(path, 100); got != "0123456789" {
+		t.Fatalf("expected whole file, got %q", got)
+	}
+	if got := readFileTail(filepath.Join(t.TempDir(), "missing"), 4); got != "" {
+		t.Fatalf("expected empty tail for missing file, got %q", got)
+	}
+}
@@ -253,3 +253,77 @@ func TestRunCommand_Error(t *testing.T) {
 		t.Fatalf("unexpected error payload: %+v", gotErr)
 	}
 }
+
+func TestResolveCommand_ShellWrappingPerGOOS(t *testing.T) {
+	code := `echo "a b" && set`
+	tests := []struct {
+		goos string
+		want []string
+	}{
+		{goos: "linux", want: []string{"bash", "-c", code}},
+		{goos: "darwin", want: []string{"bash", "-c", code}},
+		{goos: "windows", want: []string{"cmd", "/C", code}},
+	}
+
+	extra := map[string]string{"ZZ_EXTRA": "1", "AA_EXTRA": "2"}
+	for _, tt := range tests {
+		t.Run(tt.goos, func(t *testing.T) {
+			resolved := resolveCommand(tt.goos, &ExecuteCodeRequest{Code: code, Cwd: "/work"}, extra)
+			assert.Equal(t, tt.want, resolved.Argv)
+			assert.Equal(t, "/work", resolved.Cwd)
+			assert.Equal(t, []string{"AA_EXTRA", "ZZ_EXTRA"}, resolved.ExtraEnvKeys)
+			assert.Equal(t, len(resolved.env), resolved.EnvCount)
+			assert.Contains(t, resolved.env, "AA_EXTRA=2")
+		})
+	}
+}
+
+func TestRunCommand_DryRunDoesNotStartProcess(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "marker")
+	envFile := filepath.Join(dir, "env")
+	if err := os.WriteFile(envFile, []byte("DRY_RUN_KEY=secret\n"), 0o644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	t.Setenv("EXECD_ENVS", envFile)
+
+	for _, lang := range []Language{Command, BackgroundCommand} {
+		t.Run(string(lang), func(t *testing.T) {
+			var (
+				resolved  *ResolvedCommand
+				completed bool
+			)
+			c := NewController("", "")
+			req := &ExecuteCodeRequest{
+				Language: lang,
+				Code:     "echo ran > " + marker,
+				Cwd:      dir,
+				DryRun:   true,
+				Hooks: ExecuteResultHook{
+					OnExecuteInit:   func(string) { t.Fatalf("dry run should not create a session") },
+					OnExecuteDryRun: func(r *ResolvedCommand) { resolved = r },
+					OnExecuteError: func(err *execute.ErrorOutput) {
+						t.Fatalf("unexpected error hook: %+v", err)
+					},
+					OnExecuteComplete: func(time.Duration) { completed = true },
+				},
+			}
+
+			if err := c.Execute(req); err != nil {
+				t.Fatalf("Execute returned error: %v", err)
+			}
+			if resolved == nil || !completed {
+				t.Fatalf("expected dry-run and completion hooks, got resolved=%v completed=%v", resolved, completed)
+			}
+			assert.Equal(t, shellArgv(goruntime.GOOS, req.Code), resolved.Argv)
+			assert.Equal(t, dir, resolved.Cwd)
+			assert.Equal(t, []string{"DRY_RUN_KEY"}, resolved.ExtraEnvKeys)
+
+			time.Sleep(50 * time.Millisecond)
+			if _, err := os.Stat(marker); !os.IsNotExist(err) {
+				t.Fatalf("dry run must not execute the command, stat err: %v", err)
+			}
+			assert.Empty(t, c.commandClientMap)
+		})
+	}
+}
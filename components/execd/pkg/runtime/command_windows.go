@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	goruntime "runtime"
 	"strconv"
 	"time"
 
@@ -33,7 +34,43 @@ import (
 
 // runCommand executes shell commands and streams their output on Windows.
 func (c *Controller) runCommand(ctx context.Context, request *ExecuteCodeRequest) error {
+	resolved := resolveCommand(goruntime.GOOS, request, c.commandExtraEnv(request))
+	if err := c.resolveWasm(request, resolved); err != nil {
+		return err
+	}
+	if err := checkNofileLimit(request.RLimitNofile); err != nil {
+		return err
+	}
+	if err := checkReadOnlyFS(request.ReadOnlyFS); err != nil {
+		return err
+	}
+	enc, err := outputEncoding(request.OutputEncoding)
+	if err != nil {
+		return err
+	}
+	if request.redactor != nil && request.OutputHighWatermark > 0 {
+		return errRedactHighWatermark
+	}
+	if request.DryRun {
+		return c.dryRunCommand(request, resolved)
+	}
+	if request.OutputSink != nil {
+		return fmt.Errorf("%w: not supported on windows", ErrOutputSink)
+	}
+	if request.OutputHighWatermark > 0 {
+		return errors.New("output high watermark is not supported on windows")
+	}
+
 	session := c.newContextID()
+	bundle, err := prepareCABundle(session, request, resolved)
+	if err != nil {
+		return err
+	}
+	defer bundle.release()
+	snap, err := snapshotWorkdir(request.WorkdirDiff, request.Cwd)
+	if err != nil {
+		return err
+	}
 	request.Hooks.OnExecuteInit(session)
 
 	stdout, stderr, err := c.stdLogDescriptor(session)
@@ -43,37 +80,65 @@ func (c *Controller) runCommand(ctx context.Context, request *ExecuteCodeRequest
 
 	startAt := time.Now()
 	log.Info("received command: %v", request.Code)
-	cmd := exec.CommandContext(ctx, "cmd", "/C", request.Code)
+	cmd := exec.CommandContext(ctx, resolved.Argv[0], resolved.Argv[1:]...)
 
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
 	cmd.Dir = request.Cwd
-	cmd.Env = mergeEnvs(os.Environ(), loadExtraEnvFromFile())
+	cmd.Env = resolved.env
 
 	done := make(chan struct{}, 1)
+	throttle := newOutputThrottle(request.OutputRateLimit)
 	safego.Go(func() {
-		c.tailStdPipe(c.stdoutFileName(session), request.Hooks.OnExecuteStdout, done)
+		onStdout, flushDecoded := decodedOutput(request.Hooks.OnExecuteStdout, enc)
+		onStdout, flush := coalescedOutput(request.OutputCoalesce, onStdout, nil)
+		c.tailStdPipe(c.stdoutFileName(session), onStdout, request.OutputCoalesce != nil, done, throttle, nil)
+		flush()
+		flushDecoded()
 	})
 	safego.Go(func() {
-		c.tailStdPipe(c.stderrFileName(session), request.Hooks.OnExecuteStderr, done)
+		onStderr, flushDecoded := decodedOutput(request.Hooks.OnExecuteStderr, enc)
+		onStderr, flush := coalescedOutput(request.OutputCoalesce, onStderr, nil)
+		c.tailStdPipe(c.stderrFileName(session), onStderr, request.OutputCoalesce != nil, done, throttle, nil)
+		flush()
+		flushDecoded()
 	})
 
+	tree := c.newProcessTree(session)
 	err = cmd.Start()
+	tree.started(cmd)
 	if err != nil {
-		request.Hooks.OnExecuteError(&execute.ErrorOutput{EName: "CommandExecError", EValue: err.Error()})
+		tree.release()
+		c.runPostCommand(request, resolved)
+		traceback := []string{err.Error()}
+		if artifactErr := reportArtifacts(request, resolved.Cwd, startAt); artifactErr != nil {
+			traceback = append(traceback, artifactErr.Error())
+		}
+		request.Hooks.OnExecuteError(&execute.ErrorOutput{EName: "CommandExecError", EValue: err.Error(), Traceback: traceback})
 		log.Error("CommandExecError: error starting commands: %v", err)
 		return nil
 	}
 
+	applyPriority(cmd.Process.Pid, request)
 	kernel := &commandKernel{
 		pid:          cmd.Process.Pid,
 		content:      request.Code,
 		isBackground: false,
+		group:        request.GroupID,
+		tree:         tree,
 	}
 	c.storeCommandKernel(session, kernel)
 
 	err = cmd.Wait()
+	c.settleProcessTree(session, tree)
 	close(done)
+	c.runPostCommand(request, resolved)
+	reportWorkdirDiff(request, snap)
+	outcome := commandExitOutcome(err, c.stderrFileName(session))
+	request.ReportExit(outcome)
+	throttle.warnTruncated(request)
+	reportCommandSummary(request, outcome, cmd.ProcessState, c.stdoutFileName(session), c.stderrFileName(session), throttle.truncated())
+	artifactErr := reportArtifacts(request, resolved.Cwd, startAt)
 	if err != nil {
 		var eName, eValue string
 		var traceback []string
@@ -88,6 +153,9 @@ func (c *Controller) runCommand(ctx context.Context, request *ExecuteCodeRequest
 			eValue = err.Error()
 		}
 		traceback = []string{err.Error()}
+		if artifactErr != nil {
+			traceback = append(traceback, artifactErr.Error())
+		}
 
 		request.Hooks.OnExecuteError(&execute.ErrorOutput{
 			EName:     eName,
@@ -98,17 +166,65 @@ func (c *Controller) runCommand(ctx context.Context, request *ExecuteCodeRequest
 		log.Error("CommandExecError: error running commands: %v", err)
 		return nil
 	}
+	if artifactErr != nil {
+		request.Hooks.OnExecuteError(&execute.ErrorOutput{EName: "ArtifactScanError", EValue: artifactErr.Error()})
+		return nil
+	}
 	request.Hooks.OnExecuteComplete(time.Since(startAt))
 	return nil
 }
 
 // runBackgroundCommand executes shell commands in detached mode on Windows.
+// As on Unix, OnExecuteComplete only reports a successful launch.
 func (c *Controller) runBackgroundCommand(_ context.Context, request *ExecuteCodeRequest) error {
+	resolved := resolveCommand(goruntime.GOOS, request, c.commandExtraEnv(request))
+	if err := c.resolveWasm(request, resolved); err != nil {
+		return err
+	}
+	if err := checkNofileLimit(request.RLimitNofile); err != nil {
+		return err
+	}
+	if err := checkReadOnlyFS(request.ReadOnlyFS); err != nil {
+		return err
+	}
+	if request.DryRun {
+		return c.dryRunCommand(request, resolved)
+	}
+	if request.OutputSink != nil {
+		return errOutputSinkBackground
+	}
+	if request.Classify != nil {
+		return errClassifyBackground
+	}
+	if request.PostCommand != nil {
+		return errPostCommandBackground
+	}
+	if request.OutputDigest != nil {
+		return errOutputDigestBackground
+	}
+	if request.CancelOnDisconnect {
+		return errCancelOnDisconnectBackground
+	}
+	if request.Summary {
+		return errSummaryBackground
+	}
+	if request.WorkdirDiff != nil {
+		return errWorkdirDiffBackground
+	}
+	if request.Artifacts != nil {
+		return errArtifactsBackground
+	}
+
 	session := c.newContextID()
+	bundle, err := prepareCABundle(session, request, resolved)
+	if err != nil {
+		return err
+	}
 	request.Hooks.OnExecuteInit(session)
 
 	pipe, err := c.combinedOutputDescriptor(session)
 	if err != nil {
+		bundle.release()
 		return fmt.Errorf("failed to get combined output descriptor: %w", err)
 	}
 	stdoutPath := c.combinedOutputFileName(session)
@@ -116,38 +232,60 @@ func (c *Controller) runBackgroundCommand(_ context.Context, request *ExecuteCod
 
 	startAt := time.Now()
 	log.Info("received command: %v", request.Code)
-	cmd := exec.CommandContext(context.Background(), "cmd", "/C", request.Code)
+	cmd := exec.CommandContext(context.Background(), resolved.Argv[0], resolved.Argv[1:]...)
 
 	cmd.Dir = request.Cwd
 	cmd.Stdout = pipe
 	cmd.Stderr = pipe
-	cmd.Env = mergeEnvs(os.Environ(), loadExtraEnvFromFile())
+	cmd.Env = resolved.env
 
 	devNull, _ := os.OpenFile(os.DevNull, os.O_RDWR, 0) // best-effort, ignore error
 	cmd.Stdin = devNull
 
-	safego.Go(func() {
-		err := cmd.Start()
-		if err != nil {
-			log.Error("CommandExecError: error starting commands: %v", err)
-			pipe.Close() // best-effort
-			return
-		}
+	kernel := &commandKernel{
+		pid:          -1,
+		content:      request.Code,
+		stdoutPath:   stdoutPath,
+		stderrPath:   stderrPath,
+		startedAt:    startAt,
+		running:      true,
+		isBackground: true,
+		group:        request.GroupID,
+	}
 
-		kernel := &commandKernel{
-			pid:          cmd.Process.Pid,
-			content:      request.Code,
-			stdoutPath:   stdoutPath,
-			stderrPath:   stderrPath,
-			startedAt:    startAt,
-			running:      true,
-			isBackground: true,
-		}
+	// Start before reporting completion so the session is already pollable
+	// through GetCommandStatus when the caller sees OnExecuteComplete.
+	tree := c.newProcessTree(session)
+	err = cmd.Start()
+	tree.started(cmd)
+	if err != nil {
+		pipe.Close()    // best-effort
+		devNull.Close() // best-effort
+		tree.release()
+		bundle.release()
+		log.Error("CommandExecError: error starting commands: %v", err)
+		kernel.running = false
 		c.storeCommandKernel(session, kernel)
+		c.markCommandFinished(session, 255, err.Error())
+		request.Hooks.OnExecuteError(&execute.ErrorOutput{EName: "CommandExecError", EValue: err.Error()})
+		return nil
+	}
+
+	kernel.pid = cmd.Process.Pid
+	kernel.tree = tree
+	applyPriority(kernel.pid, request)
+	c.storeCommandKernel(session, kernel)
+	exited := make(chan struct{})
+	request.exited = exited
 
-		err = cmd.Wait()
+	safego.Go(func() {
+		defer close(exited)
+		err := cmd.Wait()
+		c.settleProcessTree(session, tree)
 		pipe.Close()    // best-effort
 		devNull.Close() // best-effort
+		bundle.release()
+		c.setCommandWarnings(session, request.warnings.finish())
 
 		if err != nil {
 			log.Error("CommandExecError: error running commands: %v", err)
@@ -165,3 +303,18 @@ func (c *Controller) runBackgroundCommand(_ context.Context, request *ExecuteCod
 	request.Hooks.OnExecuteComplete(time.Since(startAt))
 	return nil
 }
+
+// commandExitOutcome describes how a waited-for command ended. Windows has
+// no signals, so only the exit code is set.
+func commandExitOutcome(err error, stderrPath string) ExitOutcome {
+	outcome := ExitOutcome{Stderr: readFileTail(stderrPath, commandOutputTailBytes)}
+	var exitError *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitError):
+		outcome.ExitCode = exitError.ExitCode()
+	default:
+		outcome.ExitCode = -1
+	}
+	return outcome
+}
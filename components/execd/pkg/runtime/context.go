@@ -38,6 +38,20 @@ func (c *Controller) CreateContext(req *CreateContextRequest) (string, error) {
 		err     error
 	)
 
+	// A pooled kernel was started in the default directory, so it can only
+	// serve contexts without their own cwd.
+	if req.Cwd == "" {
+		if pooled, ok := c.takePooledKernel(req.Language); ok {
+			return c.initContext(pooled.session, pooled.kernel, req)
+		}
+	}
+
+	release, err := c.reserveKernelSlot()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
 	err = retry.OnError(kernelWaitingBackoff, func(err error) bool {
 		log.Error("failed to create session, retrying: %v", err)
 		return err != nil
@@ -54,14 +68,27 @@ func (c *Controller) CreateContext(req *CreateContextRequest) (string, error) {
 		client:   client,
 		language: req.Language,
 	}
-	c.storeJupyterKernel(session.ID, kernel)
+	return c.initContext(session.ID, kernel, req)
+}
+
+// initContext registers a newly started kernel as a context and prepares it
+// for the request.
+func (c *Controller) initContext(session string, kernel *jupyterKernel, req *CreateContextRequest) (string, error) {
+	kernel.touch()
+	c.storeJupyterKernel(session, kernel)
 
-	err = c.setWorkingDir(kernel, req)
+	err := c.setWorkingDir(kernel, req)
 	if err != nil {
 		return "", fmt.Errorf("failed to setup working dir: %w", err)
 	}
 
-	return session.ID, nil
+	if req.InitScript != "" {
+		if err := c.runInitScript(session, kernel, req); err != nil {
+			return "", err
+		}
+	}
+
+	return session, nil
 }
 
 func (c *Controller) DeleteContext(session string) error {
@@ -115,7 +142,13 @@ func (c *Controller) deleteSessionAndCleanup(session string) error {
 	if err := c.jupyterClient().DeleteSession(session); err != nil {
 		return err
 	}
+	c.unmapJupyterKernel(session)
+	return nil
+}
 
+// unmapJupyterKernel forgets the kernel of session, also as the default
+// context of its language.
+func (c *Controller) unmapJupyterKernel(session string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -125,7 +158,6 @@ func (c *Controller) deleteSessionAndCleanup(session string) error {
 			delete(c.defaultLanguageJupyterSessions, lang)
 		}
 	}
-	return nil
 }
 
 func (c *Controller) newContextID() string {
@@ -150,6 +182,13 @@ func (c *Controller) createDefaultLanguageContext(language Language) error {
 		session *jupytersession.Session
 		err     error
 	)
+
+	release, err := c.reserveKernelSlot()
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	err = retry.OnError(kernelWaitingBackoff, func(err error) bool {
 		log.Error("failed to create context, retrying: %v", err)
 		return err != nil
@@ -167,12 +206,14 @@ func (c *Controller) createDefaultLanguageContext(language Language) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.defaultLanguageJupyterSessions[language] = session.ID
-	c.jupyterClientMap[session.ID] = &jupyterKernel{
+	kernel := &jupyterKernel{
 		kernelID: session.Kernel.ID,
 		client:   client,
 		language: language,
 	}
+	kernel.touch()
+	c.defaultLanguageJupyterSessions[language] = session.ID
+	c.jupyterClientMap[session.ID] = kernel
 	return nil
 }
 
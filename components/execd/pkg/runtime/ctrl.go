@@ -17,13 +17,18 @@ package runtime
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"k8s.io/apimachinery/pkg/util/wait"
 
 	"github.com/alibaba/opensandbox/execd/pkg/jupyter"
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+	"github.com/alibaba/opensandbox/execd/pkg/util/safego"
 )
 
 var kernelWaitingBackoff = wait.Backoff{
@@ -33,6 +38,8 @@ var kernelWaitingBackoff = wait.Backoff{
 	Jitter:   0.1,
 }
 
+var errCancelOnDisconnectBackground = errors.New("cancel on disconnect is only supported for foreground commands")
+
 // Controller manages code execution across runtimes.
 type Controller struct {
 	baseURL                        string
@@ -43,6 +50,63 @@ type Controller struct {
 	commandClientMap               map[string]*commandKernel
 	db                             *sql.DB
 	dbOnce                         sync.Once
+
+	kernelSlotMu    sync.Mutex
+	maxKernels      int
+	pendingKernels  int
+	onKernelEvicted func(KernelEviction)
+	evictedSessions map[string]time.Time
+
+	// poolMu guards the warm kernel pools; it is taken after kernelSlotMu.
+	poolMu        sync.Mutex
+	poolSizes     map[Language]int
+	pooledKernels map[Language][]*pooledKernel
+	poolStarting  map[Language]int
+
+	commandSlotMu      sync.Mutex
+	maxCommands        int
+	runningCommands    int
+	commandGroups      map[string]*commandGroup
+	commandWeights     map[string]int
+	commandVirtualTime uint64
+	commandGroupSeq    uint64
+	queuedCommandCount int
+
+	// laneSlotMu guards the per-lane queues; it is taken before commandSlotMu.
+	laneSlotMu      sync.Mutex
+	maxLaneCommands int
+	laneQueues      map[string]*laneQueue
+
+	transcriptMu  sync.Mutex
+	transcriptDir string
+	onTranscript  func(*Transcript)
+
+	cloudEventMu sync.Mutex
+	cloudEvents  *cloudEventEmitter
+
+	// duplicateMu guards the guard against rapidly resubmitted identical executions.
+	duplicateMu      sync.Mutex
+	duplicateWindow  time.Duration
+	duplicateAction  DuplicateAction
+	duplicateFlights map[string]*duplicateFlight
+
+	maxScratchMB int
+
+	stopMu    sync.Mutex
+	stopGrace time.Duration
+
+	// commandCgroup is the cgroup v2 directory commands get a cgroup of their own under; empty disables it.
+	commandCgroup string
+
+	// wasmRuntime is an external WASI runtime binary that runs wasm commands;
+	// empty means the embedded one.
+	wasmRuntime string
+
+	// backend runs admitted requests in place of the built-in runners; nil uses them.
+	backend Backend
+
+	// preExecHooks run before every command is launched, see AddPreExecHook.
+	preExecHooks []PreExecHook
 }
 
 type jupyterKernel struct {
@@ -50,6 +114,14 @@ type jupyterKernel struct {
 	kernelID string
 	client   *jupyter.Client
 	language Language
+	lastUsed atomic.Int64
+	// evicted is set, under mu, once the kernel limit shut the kernel down.
+	evicted bool
+
+	// envMu guards pendingEnv, the EXECD_ENVS changes applied ahead of the
+	// kernel's next cell.
+	envMu      sync.Mutex
+	pendingEnv *kernelEnvUpdate
 }
 
 type commandKernel struct {
@@ -63,6 +135,15 @@ type commandKernel struct {
 	running      bool
 	isBackground bool
 	content      string
+	// group is the GroupID the command was started with.
+	group string
+	// stdin is the writable end of a background command's stdin, nil unless requested.
+	stdin *commandStdin
+	// tree holds every process the command started, even ones that detached
+	// from its process group; nil when there is no cgroup or job object.
+	tree *processTree
+	// warnings are those of a background command, set when it exits.
+	warnings []ExecutionWarning
 }
 
 // NewController creates a runtime controller.
@@ -74,11 +155,79 @@ func NewController(baseURL, token string) *Controller {
 		jupyterClientMap:               make(map[string]*jupyterKernel),
 		defaultLanguageJupyterSessions: make(map[Language]string),
 		commandClientMap:               make(map[string]*commandKernel),
+		evictedSessions:                make(map[string]time.Time),
+		pooledKernels:                  make(map[Language][]*pooledKernel),
+		poolStarting:                   make(map[Language]int),
 	}
 }
 
 // Execute dispatches a request to the correct backend.
 func (c *Controller) Execute(request *ExecuteCodeRequest) error {
+	return c.ExecuteContext(context.Background(), request)
+}
+
+// ExecuteContext is like Execute, but a command still waiting for a slot is
+// dropped when caller is done. Once started, execution is bounded only by
+// request.Timeout. A request coalesced into an identical earlier one, see
+// SetDuplicateWindow, gets that execution's events instead of running.
+func (c *Controller) ExecuteContext(caller context.Context, request *ExecuteCodeRequest) (err error) {
+	flight, joined, err := c.admitDuplicate(request)
+	if err != nil {
+		return err
+	}
+	if joined {
+		return flight.follow(caller, request.Hooks)
+	}
+	if flight != nil {
+		request.Hooks = flight.wrap(request.Hooks)
+		defer flight.finish()
+	}
+	events := c.newExecutionEvents(request)
+	if events != nil {
+		request.Hooks = events.wrap(request.Hooks)
+	}
+	if digest := newOutputDigester(request.OutputDigest); digest != nil {
+		request.Hooks = digest.wrap(request.Hooks)
+	}
+	if summary := newExecutionSummarizer(request); summary != nil {
+		request.Hooks = summary.wrap(request.Hooks)
+	}
+	request.warnings = &executionWarnings{}
+	request.Hooks = request.warnings.wrap(request.Hooks)
+	rec := c.newTranscriptRecorder(request)
+	if rec != nil {
+		request.Hooks = rec.wrap(request.Hooks)
+	}
+	// redaction comes last, so every hook above only sees masked output
+	if request.redactor = newOutputRedactor(request, loadExtraEnvFromFile()); request.redactor != nil {
+		request.Hooks = request.redactor.wrap(request.Hooks)
+	}
+	err = c.execute(caller, request)
+	execErr := err
+	if rec != nil {
+		request.afterExecution(func() { c.finishTranscript(rec, execErr) })
+	}
+	if events != nil {
+		request.afterExecution(func() { c.finishExecutionEvents(events, request.warnings.finish(), execErr) })
+	}
+	return err
+}
+
+// afterExecution runs fn once the execution is over: right away, or, for a
+// background command that started, once its process has exited.
+func (request *ExecuteCodeRequest) afterExecution(fn func()) {
+	if request.exited == nil {
+		fn()
+		return
+	}
+	exited := request.exited
+	safego.Go(func() {
+		<-exited
+		fn()
+	})
+}
+
+func (c *Controller) execute(caller context.Context, request *ExecuteCodeRequest) error {
 	var cancel context.CancelFunc
 	var ctx context.Context
 	if request.Timeout > 0 {
@@ -87,16 +236,43 @@ func (c *Controller) Execute(request *ExecuteCodeRequest) error {
 		ctx, cancel = context.WithCancel(context.Background())
 	}
 	defer cancel()
+	if request.CancelOnDisconnect {
+		stop := context.AfterFunc(caller, cancel)
+		defer stop()
+	}
+
+	release, err := c.waitLaneSlot(caller, ctx, request)
+	if err != nil {
+		log.Info("execution cancelled while queued in its lane: %v", err)
+		request.Hooks.OnExecuteError(&execute.ErrorOutput{EName: "Cancelled", EValue: err.Error()})
+		return nil
+	}
+	defer release()
 
 	switch request.Language {
 	case Command:
-		return c.runCommand(ctx, request)
+		if !request.DryRun {
+			release, err := c.waitCommandSlot(caller, ctx, request.GroupID)
+			if err != nil {
+				log.Info("command cancelled while queued: %v", err)
+				request.Hooks.OnExecuteError(&execute.ErrorOutput{EName: "Cancelled", EValue: err.Error()})
+				return nil
+			}
+			defer release()
+		}
+		if !c.runPreExecHooks(ctx, request) {
+			return nil
+		}
+		return c.run(ctx, request, c.runCommand)
 	case BackgroundCommand:
-		return c.runBackgroundCommand(ctx, request)
+		if !c.runPreExecHooks(ctx, request) {
+			return nil
+		}
+		return c.run(ctx, request, c.runBackgroundCommand)
 	case Bash, Python, Java, JavaScript, TypeScript, Go:
-		return c.runJupyter(ctx, request)
+		return c.run(ctx, request, c.runJupyter)
 	case SQL:
-		return c.runSQL(ctx, request)
+		return c.run(ctx, request, c.runSQL)
 	default:
 		return fmt.Errorf("unknown language: %s", request.Language)
 	}
@@ -0,0 +1,287 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+)
+
+// DuplicateAction is what happens to an execution identical to one submitted
+// within the duplicate window.
+type DuplicateAction string
+
+const (
+	// DuplicateCoalesce attaches the duplicate to the earlier execution: it
+	// receives the same events, from the start, instead of running again.
+	DuplicateCoalesce DuplicateAction = "coalesce"
+	// DuplicateReject fails the duplicate with ErrDuplicateExecution.
+	DuplicateReject DuplicateAction = "reject"
+)
+
+// SetDuplicateWindow guards against clients resubmitting the same execution
+// in a loop. An execution whose request matches, in every field but the hooks,
+// one submitted less than window ago is handled per action rather than run
+// again; later ones run normally, so at most one identical execution starts
+// per window. Requests with a Classify function always run. Zero disables it.
+func (c *Controller) SetDuplicateWindow(window time.Duration, action DuplicateAction) {
+	c.duplicateMu.Lock()
+	defer c.duplicateMu.Unlock()
+	c.duplicateWindow = max(window, 0)
+	c.duplicateAction = action
+	c.duplicateFlights = make(map[string]*duplicateFlight)
+}
+
+// duplicateFlight is an execution later identical ones can be coalesced
+// into. Its events are recorded while the window is open, so a duplicate
+// sees them from the start even when it arrives after the execution ended.
+type duplicateFlight struct {
+	submitted time.Time
+	window    time.Duration
+
+	mu        sync.Mutex
+	events    []func(ExecuteResultHook)
+	followers map[int]*duplicateFollower
+	nextID    int
+	done      chan struct{}
+}
+
+// admitDuplicate returns the flight request belongs to. joined reports that
+// an identical execution is under way or just finished and request should
+// follow it; otherwise the flight is new and request runs, publishing its
+// events through it. A nil flight means the guard is off.
+func (c *Controller) admitDuplicate(request *ExecuteCodeRequest) (flight *duplicateFlight, joined bool, err error) {
+	c.duplicateMu.Lock()
+	defer c.duplicateMu.Unlock()
+	// a classifier is code, which cannot be compared
+	if c.duplicateWindow <= 0 || request.Classify != nil {
+		return nil, false, nil
+	}
+
+	now := time.Now()
+	for key, f := range c.duplicateFlights {
+		if now.Sub(f.submitted) >= c.duplicateWindow {
+			delete(c.duplicateFlights, key)
+		}
+	}
+	key, err := duplicateKey(request)
+	if err != nil {
+		return nil, false, err
+	}
+	if f, ok := c.duplicateFlights[key]; ok {
+		age := now.Sub(f.submitted).Round(time.Millisecond)
+		if c.duplicateAction == DuplicateReject {
+			return nil, false, fmt.Errorf("%w: the last one was submitted %s ago", ErrDuplicateExecution, age)
+		}
+		log.Warning("coalescing an execution identical to one submitted %s ago", age)
+		return f, true, nil
+	}
+
+	flight = &duplicateFlight{
+		submitted: now,
+		window:    c.duplicateWindow,
+		followers: make(map[int]*duplicateFollower),
+		done:      make(chan struct{}),
+	}
+	c.duplicateFlights[key] = flight
+	return flight, false, nil
+}
+
+// duplicateKey identifies what an execution would do: every field of the
+// request but its hooks.
+func duplicateKey(request *ExecuteCodeRequest) (string, error) {
+	// JSON writes maps sorted by key, so equal requests encode alike
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(encoded)
+	// the patterns are compiled, so JSON leaves them out
+	if d := request.OutputDigest; d != nil {
+		for _, re := range d.Ignore {
+			fmt.Fprintf(h, "%d:%s", len(re.String()), re.String())
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// duplicateFollower is a duplicate following a flight on its own goroutine.
+// The flight queues events for it, so a slow follower holds back neither the
+// execution nor the other followers.
+type duplicateFollower struct {
+	queue []func(ExecuteResultHook)
+	wake  chan struct{}
+}
+
+// publish records ev while the window is open and queues it for every
+// follower. Followers get events in order, and one that joins never misses
+// or repeats one.
+func (f *duplicateFlight) publish(ev func(ExecuteResultHook)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if time.Since(f.submitted) < f.window {
+		f.events = append(f.events, ev)
+	} else {
+		f.events = nil
+	}
+	for _, follower := range f.followers {
+		follower.queue = append(follower.queue, ev)
+		select {
+		case follower.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// finish lets the followers return once the execution has ended.
+func (f *duplicateFlight) finish() {
+	close(f.done)
+}
+
+// follow replays what the execution has reported so far to hooks, then
+// forwards the rest until it ends or caller is done.
+func (f *duplicateFlight) follow(caller context.Context, hooks ExecuteResultHook) error {
+	follower := &duplicateFollower{wake: make(chan struct{}, 1)}
+	f.mu.Lock()
+	follower.queue = slices.Clone(f.events)
+	id := f.nextID
+	f.nextID++
+	f.followers[id] = follower
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		delete(f.followers, id)
+		f.mu.Unlock()
+	}()
+
+	deliver := func() {
+		f.mu.Lock()
+		queued := follower.queue
+		follower.queue = nil
+		f.mu.Unlock()
+		for _, ev := range queued {
+			ev(hooks)
+		}
+	}
+	for {
+		deliver()
+		select {
+		case <-follower.wake:
+		case <-f.done:
+			// the execution published its last event before it finished
+			deliver()
+			return nil
+		case <-caller.Done():
+			return nil
+		}
+	}
+}
+
+// wrap publishes every event of hooks to the flight after delivering it.
+func (f *duplicateFlight) wrap(hooks ExecuteResultHook) ExecuteResultHook {
+	wrapped := hooks
+	wrapped.OnExecuteInit = func(session string) {
+		callHook(hooks.OnExecuteInit, session)
+		f.publish(func(h ExecuteResultHook) { callHook(h.OnExecuteInit, session) })
+	}
+	wrapped.OnExecuteResult = func(result map[string]any, count int) {
+		if hooks.OnExecuteResult != nil {
+			hooks.OnExecuteResult(result, count)
+		}
+		f.publish(func(h ExecuteResultHook) {
+			if h.OnExecuteResult != nil {
+				h.OnExecuteResult(result, count)
+			}
+		})
+	}
+	wrapped.OnExecuteStatus = func(status string) {
+		callHook(hooks.OnExecuteStatus, status)
+		f.publish(func(h ExecuteResultHook) { callHook(h.OnExecuteStatus, status) })
+	}
+	wrapped.OnExecuteStdout = func(text string) {
+		callHook(hooks.OnExecuteStdout, text)
+		f.publish(func(h ExecuteResultHook) { callHook(h.OnExecuteStdout, text) })
+	}
+	wrapped.OnExecuteStderr = func(text string) {
+		callHook(hooks.OnExecuteStderr, text)
+		f.publish(func(h ExecuteResultHook) { callHook(h.OnExecuteStderr, text) })
+	}
+	wrapped.OnExecuteError = func(err *execute.ErrorOutput) {
+		callHook(hooks.OnExecuteError, err)
+		f.publish(func(h ExecuteResultHook) { callHook(h.OnExecuteError, err) })
+	}
+	wrapped.OnExecuteComplete = func(elapsed time.Duration) {
+		callHook(hooks.OnExecuteComplete, elapsed)
+		f.publish(func(h ExecuteResultHook) { callHook(h.OnExecuteComplete, elapsed) })
+	}
+	wrapped.OnExecuteDryRun = func(resolved *ResolvedCommand) {
+		callHook(hooks.OnExecuteDryRun, resolved)
+		f.publish(func(h ExecuteResultHook) { callHook(h.OnExecuteDryRun, resolved) })
+	}
+	wrapped.OnExecuteOutputSink = func(result *OutputSinkResult) {
+		callHook(hooks.OnExecuteOutputSink, result)
+		f.publish(func(h ExecuteResultHook) { callHook(h.OnExecuteOutputSink, result) })
+	}
+	wrapped.OnExecuteArtifacts = func(manifest *ArtifactManifest) {
+		callHook(hooks.OnExecuteArtifacts, manifest)
+		f.publish(func(h ExecuteResultHook) { callHook(h.OnExecuteArtifacts, manifest) })
+	}
+	wrapped.OnExecuteExitCategory = func(category ExitCategory) {
+		callHook(hooks.OnExecuteExitCategory, category)
+		f.publish(func(h ExecuteResultHook) { callHook(h.OnExecuteExitCategory, category) })
+	}
+	wrapped.OnExecutePostCommand = func(result *PostCommandResult) {
+		callHook(hooks.OnExecutePostCommand, result)
+		f.publish(func(h ExecuteResultHook) { callHook(h.OnExecutePostCommand, result) })
+	}
+	wrapped.OnExecuteValue = func(value *execute.ExecuteResult) {
+		callHook(hooks.OnExecuteValue, value)
+		f.publish(func(h ExecuteResultHook) { callHook(h.OnExecuteValue, value) })
+	}
+	wrapped.OnExecuteOutputDigest = func(result *OutputDigestResult) {
+		callHook(hooks.OnExecuteOutputDigest, result)
+		f.publish(func(h ExecuteResultHook) { callHook(h.OnExecuteOutputDigest, result) })
+	}
+	wrapped.OnExecuteSummary = func(summary *ExecutionSummary) {
+		callHook(hooks.OnExecuteSummary, summary)
+		f.publish(func(h ExecuteResultHook) { callHook(h.OnExecuteSummary, summary) })
+	}
+	wrapped.OnExecuteWorkdirDiff = func(changes *WorkdirChanges) {
+		callHook(hooks.OnExecuteWorkdirDiff, changes)
+		f.publish(func(h ExecuteResultHook) { callHook(h.OnExecuteWorkdirDiff, changes) })
+	}
+	wrapped.OnExecuteWarnings = func(warnings []ExecutionWarning) {
+		callHook(hooks.OnExecuteWarnings, warnings)
+		f.publish(func(h ExecuteResultHook) { callHook(h.OnExecuteWarnings, warnings) })
+	}
+	return wrapped
+}
+
+// callHook calls a single-argument hook unless it is unset.
+func callHook[T any](hook func(T), arg T) {
+	if hook != nil {
+		hook(arg)
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingCommand appends a line to a file each time it runs, then prints
+// once it has been running for a while.
+func countingCommand(t *testing.T) (*ExecuteCodeRequest, func() int) {
+	t.Helper()
+	runs := filepath.Join(t.TempDir(), "runs")
+	request := &ExecuteCodeRequest{
+		Language: Command,
+		Code:     "echo run >> " + runs + "; sleep 0.3; echo done",
+		Envs:     map[string]string{"MODE": "retry"},
+	}
+	count := func() int {
+		raw, _ := os.ReadFile(runs)
+		return strings.Count(string(raw), "run")
+	}
+	return request, count
+}
+
+func TestDuplicate_CoalescesRapidResubmissions(t *testing.T) {
+	skipWithoutBash(t)
+	c := NewController("", "")
+	c.SetDuplicateWindow(time.Second, DuplicateCoalesce)
+	template, runs := countingCommand(t)
+
+	var wg sync.WaitGroup
+	stdout := make([][]string, 5)
+	completed := make([]bool, 5)
+	for i := range stdout {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := *template
+			req.Hooks = noopHooks()
+			req.Hooks.OnExecuteStdout = func(s string) { stdout[i] = append(stdout[i], s) }
+			req.Hooks.OnExecuteComplete = func(time.Duration) { completed[i] = true }
+			assert.NoError(t, c.Execute(&req))
+		}()
+		time.Sleep(20 * time.Millisecond)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, runs(), "identical executions within the window should run once")
+	for i := range stdout {
+		assert.Equal(t, []string{"done"}, stdout[i], "submission %d should get the output of the shared execution", i)
+		assert.True(t, completed[i], "submission %d should see the execution complete", i)
+	}
+
+	// a different environment is a different execution
+	other := *template
+	other.Envs = map[string]string{"MODE": "other"}
+	other.Hooks = noopHooks()
+	require.NoError(t, c.Execute(&other))
+	assert.Equal(t, 2, runs())
+
+	// after the window, the same execution runs again
+	time.Sleep(time.Second)
+	again := *template
+	again.Hooks = noopHooks()
+	require.NoError(t, c.Execute(&again))
+	assert.Equal(t, 3, runs())
+}
+
+func TestDuplicate_RejectsRapidResubmissions(t *testing.T) {
+	skipWithoutBash(t)
+	c := NewController("", "")
+	c.SetDuplicateWindow(500*time.Millisecond, DuplicateReject)
+	template, runs := countingCommand(t)
+
+	var rejected int
+	for i := 0; i < 5; i++ {
+		req := *template
+		req.Hooks = noopHooks()
+		if err := c.Execute(&req); err != nil {
+			require.ErrorIs(t, err, ErrDuplicateExecution)
+			rejected++
+		}
+	}
+	assert.Equal(t, 1, runs(), "the first submission runs and the rest of the burst is rejected")
+	assert.Equal(t, 4, rejected)
+
+	time.Sleep(500 * time.Millisecond)
+	req := *template
+	req.Hooks = noopHooks()
+	require.NoError(t, c.Execute(&req), "a submission after the window runs")
+	assert.Equal(t, 2, runs())
+}
+
+func TestDuplicate_OffByDefault(t *testing.T) {
+	skipWithoutBash(t)
+	c := NewController("", "")
+	template, runs := countingCommand(t)
+	for i := 0; i < 2; i++ {
+		req := *template
+		req.Hooks = noopHooks()
+		require.NoError(t, c.Execute(&req))
+	}
+	assert.Equal(t, 2, runs())
+}
+
+func TestDuplicate_ClassifiedRequestsAlwaysRun(t *testing.T) {
+	skipWithoutBash(t)
+	c := NewController("", "")
+	c.SetDuplicateWindow(time.Second, DuplicateCoalesce)
+	template, runs := countingCommand(t)
+	template.Classify = func(ExitOutcome) ExitCategory { return ExitSuccess }
+	for i := 0; i < 2; i++ {
+		req := *template
+		req.Hooks = noopHooks()
+		require.NoError(t, c.Execute(&req))
+	}
+	assert.Equal(t, 2, runs(), "a classifier cannot be compared, so its requests are never merged")
+}
+
+func TestDuplicateKey_CoversEveryField(t *testing.T) {
+	key := func(req *ExecuteCodeRequest) string {
+		k, err := duplicateKey(req)
+		require.NoError(t, err)
+		return k
+	}
+	base := func() *ExecuteCodeRequest {
+		return &ExecuteCodeRequest{Language: Command, Code: "make", Envs: map[string]string{"A": "1", "B": "2"}}
+	}
+
+	same := base()
+	same.Envs = map[string]string{"B": "2", "A": "1"}
+	same.Hooks = noopHooks()
+	assert.Equal(t, key(base()), key(same), "hooks and map order do not tell executions apart")
+
+	for name, change := range map[string]func(*ExecuteCodeRequest){
+		"background":   func(r *ExecuteCodeRequest) { r.Language = BackgroundCommand },
+		"timeout":      func(r *ExecuteCodeRequest) { r.Timeout = time.Second },
+		"group":        func(r *ExecuteCodeRequest) { r.GroupID = "tenant-a" },
+		"post command": func(r *ExecuteCodeRequest) { r.PostCommand = &PostCommand{Code: "rm -rf build"} },
+		"stdin":        func(r *ExecuteCodeRequest) { r.Stdin = true },
+		"nice":         func(r *ExecuteCodeRequest) { r.Nice = 5 },
+	} {
+		req := base()
+		change(req)
+		assert.NotEqual(t, key(base()), key(req), "requests differing in %s must not be merged", name)
+	}
+}
+
+// A follower that is slow to take its events holds back neither the
+// execution it follows nor its own order of events.
+func TestDuplicate_SlowFollowerDoesNotStallLeader(t *testing.T) {
+	f := &duplicateFlight{
+		submitted: time.Now(),
+		window:    time.Minute,
+		followers: make(map[int]*duplicateFollower),
+		done:      make(chan struct{}),
+	}
+	unblock := make(chan struct{})
+	var got []string
+	followed := make(chan struct{})
+	go func() {
+		defer close(followed)
+		hooks := noopHooks()
+		hooks.OnExecuteStdout = func(s string) {
+			<-unblock
+			got = append(got, s)
+		}
+		assert.NoError(t, f.follow(context.Background(), hooks))
+	}()
+	require.Eventually(t, func() bool {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return len(f.followers) == 1
+	}, time.Second, time.Millisecond)
+
+	leader := f.wrap(noopHooks())
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for i := 0; i < 100; i++ {
+			leader.OnExecuteStdout(strconv.Itoa(i))
+		}
+	}()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("the leader waited for its follower")
+	}
+
+	close(unblock)
+	f.finish()
+	<-followed
+	require.Len(t, got, 100)
+	for i, s := range got {
+		assert.Equal(t, strconv.Itoa(i), s)
+	}
+}
@@ -15,28 +15,44 @@
 package runtime
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
 	"github.com/alibaba/opensandbox/execd/pkg/log"
 )
 
 // loadExtraEnvFromFile reads key=value lines from EXECD_ENVS (if set).
 // Empty lines and lines starting with '#' are ignored.
 func loadExtraEnvFromFile() map[string]string {
+	envs, _ := readExtraEnvFile()
+	return envs
+}
+
+// readExtraEnvFile is loadExtraEnvFromFile that also returns the malformed
+// lines it skipped.
+func readExtraEnvFile() (map[string]string, []string) {
 	path := os.Getenv("EXECD_ENVS")
 	if path == "" {
-		return nil
+		return nil, nil
 	}
 
 	data, err := os.ReadFile(path)
 	if err != nil {
 		log.Warn("EXECD_ENVS: failed to read file %s: %v", path, err)
-		return nil
+		return nil, nil
 	}
 
 	envs := make(map[string]string)
+	var skipped []string
 	lines := strings.Split(string(data), "\n")
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
@@ -46,12 +62,13 @@ func loadExtraEnvFromFile() map[string]string {
 		kv := strings.SplitN(line, "=", 2)
 		if len(kv) != 2 {
 			log.Warn("EXECD_ENVS: skip malformed line: %s", line)
+			skipped = append(skipped, line)
 			continue
 		}
 		envs[kv[0]] = os.ExpandEnv(kv[1])
 	}
 
-	return envs
+	return envs, skipped
 }
 
 // mergeEnvs overlays extra into base and returns a merged slice.
@@ -79,3 +96,231 @@ func mergeEnvs(base []string, extra map[string]string) []string {
 
 	return out
 }
+
+// commandExtraEnv returns the EXECD_ENVS overlay for a command, warning the
+// request about each line that could not be applied.
+func (c *Controller) commandExtraEnv(request *ExecuteCodeRequest) map[string]string {
+	envs, skipped := readExtraEnvFile()
+	for _, line := range skipped {
+		// only the first word: the rest may be a value meant to stay out of the response
+		word, _, _ := strings.Cut(line, " ")
+		request.Warn(WarningEnvDropped, "EXECD_ENVS: dropped malformed line starting with %q, it has no '='", word)
+	}
+	return envs
+}
+
+// WatchExtraEnvFile polls the EXECD_ENVS file every interval and, when it
+// changes, logs which keys were touched and queues the change for every live
+// Jupyter kernel whose language can set its own environment. Commands read
+// the file when they start and need no watching; running commands keep the
+// environment they were launched with. Blocks until ctx is done.
+func (c *Controller) WatchExtraEnvFile(ctx context.Context, interval time.Duration) {
+	path := os.Getenv("EXECD_ENVS")
+	if path == "" || interval <= 0 {
+		return
+	}
+
+	prev := loadExtraEnvFromFile()
+	last := statEnvFile(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := statEnvFile(path)
+			if current == last {
+				continue
+			}
+			last = current
+			prev = c.reloadExtraEnv(prev)
+		}
+	}
+}
+
+// reloadExtraEnv re-reads EXECD_ENVS, queues what changed since prev for the
+// live kernels and returns the new variables.
+func (c *Controller) reloadExtraEnv(prev map[string]string) map[string]string {
+	next := loadExtraEnvFromFile()
+
+	added, changed, removed := diffEnvKeys(prev, next)
+	if len(added)+len(changed)+len(removed) == 0 {
+		return next
+	}
+
+	c.mu.RLock()
+	kernels := make([]*jupyterKernel, 0, len(c.jupyterClientMap))
+	for _, kernel := range c.jupyterClientMap {
+		kernels = append(kernels, kernel)
+	}
+	c.mu.RUnlock()
+
+	var skipped []Language
+	for _, kernel := range kernels {
+		if !kernelCanSetEnv(kernel.language) {
+			if !slices.Contains(skipped, kernel.language) {
+				skipped = append(skipped, kernel.language)
+			}
+			continue
+		}
+		kernel.queueEnv(next, append(added, changed...), removed)
+	}
+	log.Info("EXECD_ENVS: reloaded, added=%v changed=%v removed=%v; applies to new commands and the next cell of python and bash kernels, running commands and %v kernels keep their environment",
+		added, changed, removed, skipped)
+	return next
+}
+
+var shellNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// kernelEnvUpdate holds EXECD_ENVS changes not yet applied to a kernel.
+type kernelEnvUpdate struct {
+	set   map[string]string
+	unset map[string]struct{}
+}
+
+// kernelCanSetEnv reports whether code run in a kernel of language can
+// change the environment later cells and their subprocesses see.
+func kernelCanSetEnv(language Language) bool {
+	return language == Python || language == Bash
+}
+
+// queueEnv merges the keys of next that were set and the removed keys into
+// the kernel's pending update.
+func (k *jupyterKernel) queueEnv(next map[string]string, set, removed []string) {
+	k.envMu.Lock()
+	defer k.envMu.Unlock()
+	if k.pendingEnv == nil {
+		k.pendingEnv = &kernelEnvUpdate{set: map[string]string{}, unset: map[string]struct{}{}}
+	}
+	for _, key := range set {
+		k.pendingEnv.set[key] = next[key]
+		delete(k.pendingEnv.unset, key)
+	}
+	for _, key := range removed {
+		delete(k.pendingEnv.set, key)
+		k.pendingEnv.unset[key] = struct{}{}
+	}
+}
+
+// takeEnvUpdate returns the code that applies the kernel's pending update and
+// clears it, or "" when nothing is pending.
+func (k *jupyterKernel) takeEnvUpdate() string {
+	k.envMu.Lock()
+	update := k.pendingEnv
+	k.pendingEnv = nil
+	k.envMu.Unlock()
+	if update == nil {
+		return ""
+	}
+	return envUpdateCode(k.language, update)
+}
+
+// envUpdateCode renders update as a cell for language.
+func envUpdateCode(language Language, update *kernelEnvUpdate) string {
+	set := slices.Sorted(maps.Keys(update.set))
+	unset := slices.Sorted(maps.Keys(update.unset))
+	var b strings.Builder
+	switch language {
+	case Python:
+		// JSON strings are valid Python string literals
+		b.WriteString("import os as _execd_os\n")
+		for _, key := range set {
+			k, _ := json.Marshal(key)
+			v, _ := json.Marshal(update.set[key])
+			fmt.Fprintf(&b, "_execd_os.environ[%s] = %s\n", k, v)
+		}
+		for _, key := range unset {
+			k, _ := json.Marshal(key)
+			fmt.Fprintf(&b, "_execd_os.environ.pop(%s, None)\n", k)
+		}
+		b.WriteString("del _execd_os\n")
+	case Bash:
+		// a key bash cannot name is left out rather than failing the cell
+		for _, key := range set {
+			if shellNamePattern.MatchString(key) {
+				fmt.Fprintf(&b, "export %s=%s\n", key, shellQuote(update.set[key]))
+			}
+		}
+		for _, key := range unset {
+			if shellNamePattern.MatchString(key) {
+				fmt.Fprintf(&b, "unset %s\n", key)
+			}
+		}
+	default:
+		return ""
+	}
+	return b.String()
+}
+
+// shellQuote quotes s as a single bash word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// applyKernelEnv runs the kernel's pending EXECD_ENVS update ahead of the
+// next cell, silently so it leaves no trace in the kernel's history or
+// execution_count. A failure is logged and does not keep the cell from running.
+func (c *Controller) applyKernelEnv(ctx context.Context, kernel *jupyterKernel) {
+	code := kernel.takeEnvUpdate()
+	if code == "" {
+		return
+	}
+	var failure *execute.ErrorOutput
+	run := &ExecuteCodeRequest{
+		Language: kernel.language,
+		Code:     code,
+		silent:   true,
+		Hooks: ExecuteResultHook{
+			OnExecuteResult:   func(map[string]any, int) {},
+			OnExecuteStatus:   func(string) {},
+			OnExecuteStdout:   func(string) {},
+			OnExecuteStderr:   func(string) {},
+			OnExecuteError:    func(err *execute.ErrorOutput) { failure = err },
+			OnExecuteComplete: func(time.Duration) {},
+		},
+	}
+	if err := c.runJupyterCode(ctx, kernel, run); err != nil {
+		log.Warning("EXECD_ENVS: failed to update kernel %s: %v", kernel.kernelID, err)
+	} else if failure != nil {
+		log.Warning("EXECD_ENVS: failed to update kernel %s: %s: %s", kernel.kernelID, failure.EName, failure.EValue)
+	}
+}
+
+// envFileStamp identifies a version of the env file without reading it.
+type envFileStamp struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func statEnvFile(path string) envFileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return envFileStamp{}
+	}
+	return envFileStamp{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+// diffEnvKeys reports sorted keys added, changed and removed between two snapshots.
+func diffEnvKeys(prev, next map[string]string) (added, changed, removed []string) {
+	for k, v := range next {
+		old, ok := prev[k]
+		switch {
+		case !ok:
+			added = append(added, k)
+		case old != v:
+			changed = append(changed, k)
+		}
+	}
+	for k := range prev {
+		if _, ok := next[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return added, changed, removed
+}
@@ -15,10 +15,13 @@
 package runtime
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadExtraEnvFromFileUnset(t *testing.T) {
@@ -100,3 +103,93 @@ func TestMergeEnvsOverlaysExtra(t *testing.T) {
 		t.Fatalf("C mismatch, got %q", got["C"])
 	}
 }
+
+func TestDiffEnvKeys(t *testing.T) {
+	prev := map[string]string{"KEEP": "1", "CHANGE": "old", "DROP": "x"}
+	next := map[string]string{"KEEP": "1", "CHANGE": "new", "ADD": "y"}
+
+	added, changed, removed := diffEnvKeys(prev, next)
+	if !reflect.DeepEqual(added, []string{"ADD"}) {
+		t.Fatalf("added mismatch: %v", added)
+	}
+	if !reflect.DeepEqual(changed, []string{"CHANGE"}) {
+		t.Fatalf("changed mismatch: %v", changed)
+	}
+	if !reflect.DeepEqual(removed, []string{"DROP"}) {
+		t.Fatalf("removed mismatch: %v", removed)
+	}
+}
+
+func TestLoadExtraEnvFromFileReadsFresh(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), "env")
+	t.Setenv("EXECD_ENVS", envFile)
+
+	for _, want := range []string{"before", "after"} {
+		if err := os.WriteFile(envFile, []byte("FOO="+want+"\n"), 0o644); err != nil {
+			t.Fatalf("write env file: %v", err)
+		}
+		if got := loadExtraEnvFromFile()["FOO"]; got != want {
+			t.Fatalf("expected FOO=%q right after writing the file, got %q", want, got)
+		}
+	}
+}
+
+func TestWatchExtraEnvFileQueuesKernelUpdates(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), "env")
+	t.Setenv("EXECD_ENVS", envFile)
+	if err := os.WriteFile(envFile, []byte("FOO=before\nDROP=x\n"), 0o644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	c := NewController("", "")
+	python := &jupyterKernel{kernelID: "py", language: Python}
+	java := &jupyterKernel{kernelID: "java", language: Java}
+	c.storeJupyterKernel("py-session", python)
+	c.storeJupyterKernel("java-session", java)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.WatchExtraEnvFile(ctx, 10*time.Millisecond)
+	}()
+	// let the watcher take its first snapshot before the file changes
+	time.Sleep(50 * time.Millisecond)
+
+	if err := os.WriteFile(envFile, []byte("FOO=it's new\nBAR=1\n"), 0o644); err != nil {
+		t.Fatalf("rewrite env file: %v", err)
+	}
+	var code string
+	deadline := time.Now().Add(2 * time.Second)
+	for code == "" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		code = python.takeEnvUpdate()
+	}
+	cancel()
+	<-done
+
+	want := "import os as _execd_os\n" +
+		"_execd_os.environ[\"BAR\"] = \"1\"\n" +
+		"_execd_os.environ[\"FOO\"] = \"it's new\"\n" +
+		"_execd_os.environ.pop(\"DROP\", None)\n" +
+		"del _execd_os\n"
+	if code != want {
+		t.Fatalf("python kernel update mismatch:\n%s", code)
+	}
+	if again := python.takeEnvUpdate(); again != "" {
+		t.Fatalf("expected the update to be applied once, got %q", again)
+	}
+	if got := java.takeEnvUpdate(); got != "" {
+		t.Fatalf("java kernels cannot set their environment, got %q", got)
+	}
+}
+
+func TestEnvUpdateCodeBash(t *testing.T) {
+	code := envUpdateCode(Bash, &kernelEnvUpdate{
+		set:   map[string]string{"QUOTED": "a'b", "NOT-A-NAME": "x"},
+		unset: map[string]struct{}{"OLD": {}},
+	})
+	if want := "export QUOTED='a'\\''b'\nunset OLD\n"; code != want {
+		t.Fatalf("bash kernel update mismatch: %q", code)
+	}
+}
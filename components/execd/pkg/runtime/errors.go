@@ -17,3 +17,48 @@ package runtime
 import "errors"
 
 var ErrContextNotFound = errors.New("context not found")
+
+// ErrKernelLimitReached is returned when a new kernel is needed but every kernel is busy.
+var ErrKernelLimitReached = errors.New("kernel limit reached and all kernels are busy")
+
+// ErrSessionInitFailed is returned when a context's init script errors; the context is removed.
+var ErrSessionInitFailed = errors.New("session init script failed")
+
+// ErrCommandNotFound is returned for an unknown command session.
+var ErrCommandNotFound = errors.New("command not found")
+
+// ErrStdinUnavailable is returned when writing to a command that was not started with a writable stdin.
+var ErrStdinUnavailable = errors.New("command has no writable stdin")
+
+// ErrStdinClosed is returned when writing to a stdin that was closed or whose process stopped reading it.
+var ErrStdinClosed = errors.New("command stdin is closed")
+
+// ErrStdinBusy is returned when a process does not drain its stdin in time; the caller should retry later.
+var ErrStdinBusy = errors.New("command stdin is full")
+
+// ErrRLimitNofile is returned when a command's open file limit cannot be applied.
+var ErrRLimitNofile = errors.New("cannot set open file limit")
+
+// ErrUmask is returned for a umask that is not an octal mask.
+var ErrUmask = errors.New("invalid umask")
+
+// ErrPriority is returned for a nice value or I/O priority that is out of range or malformed.
+var ErrPriority = errors.New("invalid process priority")
+
+// ErrOutputSink is returned when a command's output could not be stored in its sink.
+var ErrOutputSink = errors.New("output sink upload failed")
+
+// ErrWasm is returned when a wasm command cannot be run, e.g. without a WASI runtime or with shell-only options.
+var ErrWasm = errors.New("cannot run wasm module")
+
+// ErrScratchTooLarge is returned when a command asks for a scratch tmpfs above the configured maximum.
+var ErrScratchTooLarge = errors.New("scratch size exceeds the configured maximum")
+
+// ErrReadOnlyFS is returned when a command cannot be confined to a read-only filesystem.
+var ErrReadOnlyFS = errors.New("cannot run command on a read-only filesystem")
+
+// ErrLocale is returned for a locale or timezone that cannot be put in the environment.
+var ErrLocale = errors.New("invalid locale or timezone")
+
+// ErrDuplicateExecution is returned when an identical execution was submitted within the duplicate window.
+var ErrDuplicateExecution = errors.New("identical execution submitted too recently")
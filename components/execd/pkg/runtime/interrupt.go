@@ -37,13 +37,16 @@ func (c *Controller) Interrupt(sessionID string) error {
 		return kernel.client.InterruptKernel(kernel.kernelID)
 	case c.getCommandKernel(sessionID) != nil:
 		kernel := c.getCommandKernel(sessionID)
-		return c.killPid(kernel.pid)
+		err := c.killPid(kernel.pid)
+		// processes the command detached are only reachable through its tree
+		return errors.Join(err, kernel.tree.kill())
 	default:
 		return errors.New("no such session")
 	}
 }
 
-// killPid sends SIGTERM followed by SIGKILL if needed.
+// killPid sends SIGTERM followed, after the stop grace period, by SIGKILL if
+// needed.
 func (c *Controller) killPid(pid int) error {
 	process, err := os.FindProcess(pid)
 	if err != nil {
@@ -69,7 +72,7 @@ func (c *Controller) killPid(pid int) error {
 				log.Info("Process %d terminated gracefully", pid)
 				return nil
 			}
-		case <-time.After(3 * time.Second):
+		case <-time.After(c.stopGracePeriod()):
 			log.Warning("Process %d did not terminate after SIGTERM, using SIGKILL", pid)
 		}
 	}
@@ -35,7 +35,9 @@ func (c *Controller) Interrupt(sessionID string) error {
 		return kernel.client.InterruptKernel(kernel.kernelID)
 	case c.getCommandKernel(sessionID) != nil:
 		kernel := c.getCommandKernel(sessionID)
-		return c.killPid(kernel.pid)
+		err := c.killPid(kernel.pid)
+		// processes the command detached are only reachable through its tree
+		return errors.Join(err, kernel.tree.kill())
 	default:
 		return errors.New("no such session")
 	}
@@ -46,23 +46,38 @@ func (c *Controller) runJupyter(ctx context.Context, request *ExecuteCodeRequest
 
 	kernel := c.getJupyterKernel(targetSessionID)
 	if kernel == nil {
+		if _, evicted := c.evictedAt(targetSessionID); evicted {
+			c.reportEviction(request, targetSessionID)
+			return nil
+		}
 		return ErrContextNotFound
 	}
 
 	request.SetDefaultHooks()
 	request.Hooks.OnExecuteInit(targetSessionID)
 
-	return c.runJupyterCode(ctx, kernel, request)
+	c.applyKernelEnv(ctx, kernel)
+	err := c.runJupyterCode(ctx, kernel, request)
+	if errors.Is(err, errKernelEvicted) {
+		c.reportEviction(request, targetSessionID)
+		return nil
+	}
+	return err
 }
 
 // runJupyterCode streams execution results for a single kernel.
 //
 //nolint:gocognit // complex due to hook handling; refactor later
 func (c *Controller) runJupyterCode(ctx context.Context, kernel *jupyterKernel, request *ExecuteCodeRequest) error {
-	if !kernel.mu.TryLock() {
-		return errors.New("session is busy")
-	}
+	// executions are queued per session beforehand; this only waits out an
+	// eviction check or a session init script
+	kernel.mu.Lock()
 	defer kernel.mu.Unlock()
+	if kernel.evicted {
+		return errKernelEvicted
+	}
+	kernel.touch()
+	defer kernel.touch()
 
 	err := kernel.client.ConnectToKernel(kernel.kernelID)
 	if err != nil {
@@ -72,7 +87,11 @@ func (c *Controller) runJupyterCode(ctx context.Context, kernel *jupyterKernel,
 
 	results := make(chan *execute.ExecutionResult, 10)
 
-	err = kernel.client.ExecuteCodeStream(kernel.kernelID, request.Code, results)
+	if request.silent {
+		err = kernel.client.ExecuteSilentStream(kernel.kernelID, request.Code, results)
+	} else {
+		err = kernel.client.ExecuteCodeStream(kernel.kernelID, request.Code, results)
+	}
 	if err != nil {
 		return err
 	}
@@ -88,6 +107,14 @@ func (c *Controller) runJupyterCode(ctx context.Context, kernel *jupyterKernel,
 				request.Hooks.OnExecuteResult(result.ExecutionData, result.ExecutionCount)
 			}
 
+			if len(result.ExecutionData) > 0 && request.Hooks.OnExecuteValue != nil {
+				request.Hooks.OnExecuteValue(&execute.ExecuteResult{
+					ExecutionCount: result.ExecutionCount,
+					Data:           result.ExecutionData,
+					Metadata:       result.ExecutionMetadata,
+				})
+			}
+
 			if result.Status != "" {
 				request.Hooks.OnExecuteStatus(result.Status)
 			}
@@ -0,0 +1,72 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+func TestRunJupyterCode_ReportsCellValueApartFromStdout(t *testing.T) {
+	_, server := newFakeStatefulKernel(t)
+	c := NewController(server.URL, "token")
+	kernel := addFakeKernel(c, server, "ctx-value")
+
+	var (
+		stdout  []string
+		results []map[string]any
+		counts  []int
+		values  []*execute.ExecuteResult
+	)
+	hooks := noopHooks()
+	hooks.OnExecuteStdout = func(s string) { stdout = append(stdout, s) }
+	hooks.OnExecuteStatus = func(string) {}
+	hooks.OnExecuteResult = func(result map[string]any, count int) {
+		results = append(results, result)
+		counts = append(counts, count)
+	}
+	hooks.OnExecuteValue = func(value *execute.ExecuteResult) { values = append(values, value) }
+
+	run := func(code string) {
+		t.Helper()
+		require.NoError(t, c.runJupyterCode(t.Context(), kernel, &ExecuteCodeRequest{
+			Language: Python,
+			Context:  "ctx-value",
+			Code:     code,
+			Hooks:    hooks,
+		}))
+	}
+
+	// an assignment and a print have no value
+	run("answer = 42\nprint(answer)")
+	assert.Equal(t, []string{"42"}, stdout)
+	assert.Empty(t, values)
+
+	run("print(answer)\nanswer")
+	assert.Equal(t, []string{"42", "42"}, stdout, "the value is not reported as printed output")
+	require.Len(t, values, 1)
+	assert.Equal(t, &execute.ExecuteResult{
+		ExecutionCount: 2,
+		Data:           map[string]any{"text/plain": "'42'"},
+		Metadata:       map[string]any{"source": "answer"},
+	}, values[0])
+	require.NotEmpty(t, results)
+	assert.Equal(t, values[0].Data, results[len(results)-1], "OnExecuteResult sees the same bundle")
+	assert.Equal(t, 2, counts[len(counts)-1])
+}
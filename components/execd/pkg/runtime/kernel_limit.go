@@ -0,0 +1,245 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+)
+
+// maxEvictedRecords bounds how many evicted session IDs are remembered.
+const maxEvictedRecords = 1024
+
+// ContextEvictedErrorName is the ename of the error event sent for code run
+// in a context whose kernel was evicted by the kernel limit.
+const ContextEvictedErrorName = "ContextEvicted"
+
+// errKernelEvicted is returned by runJupyterCode for a kernel evicted while
+// the execution waited for it.
+var errKernelEvicted = errors.New("kernel evicted to free a kernel slot")
+
+// KernelEviction describes a kernel reclaimed to stay under the kernel limit.
+type KernelEviction struct {
+	Session   string
+	Language  Language
+	LastUsed  time.Time
+	EvictedAt time.Time
+}
+
+// SetMaxKernels caps the number of live Jupyter kernels; zero or less means unlimited.
+func (c *Controller) SetMaxKernels(limit int) {
+	c.kernelSlotMu.Lock()
+	defer c.kernelSlotMu.Unlock()
+
+	c.maxKernels = limit
+}
+
+// SetKernelEvictionHandler registers a callback invoked after a kernel is evicted.
+func (c *Controller) SetKernelEvictionHandler(fn func(KernelEviction)) {
+	c.kernelSlotMu.Lock()
+	defer c.kernelSlotMu.Unlock()
+
+	c.onKernelEvicted = fn
+}
+
+// touch records kernel use for LRU ordering.
+func (k *jupyterKernel) touch() {
+	k.lastUsed.Store(time.Now().UnixNano())
+}
+
+// reserveKernelSlot makes room for one more kernel, shutting down an idle
+// pooled kernel or else evicting the least recently used idle kernel when the
+// limit is reached. It fails with ErrKernelLimitReached when every kernel is
+// busy. The returned release must be called once the new kernel is stored or
+// its creation failed.
+func (c *Controller) reserveKernelSlot() (func(), error) {
+	return c.reserveKernelSlotWith(true)
+}
+
+// reserveKernelSlotWithoutEviction is like reserveKernelSlot but fails rather
+// than shut down any kernel.
+func (c *Controller) reserveKernelSlotWithoutEviction() (func(), error) {
+	return c.reserveKernelSlotWith(false)
+}
+
+// reserveKernelSlotWith takes the kernels to shut down out of service under
+// kernelSlotMu, and shuts them down once it is released: a slow shutdown
+// must not hold up the slots of other sessions. Their slots are the
+// reservation's from the moment they are taken, so nobody else counts them
+// as free in between.
+func (c *Controller) reserveKernelSlotWith(evict bool) (func(), error) {
+	release, shutdowns, err := c.claimKernelSlot(evict)
+	for _, shutdown := range shutdowns {
+		shutdown()
+	}
+	return release, err
+}
+
+func (c *Controller) claimKernelSlot(evict bool) (func(), []func(), error) {
+	c.kernelSlotMu.Lock()
+	defer c.kernelSlotMu.Unlock()
+
+	var shutdowns []func()
+	if c.maxKernels > 0 {
+		c.mu.RLock()
+		live := len(c.jupyterClientMap)
+		c.mu.RUnlock()
+		live += c.pooledKernelCount()
+
+		for live+c.pendingKernels >= c.maxKernels {
+			var shutdown func()
+			if evict {
+				if shutdown = c.dropPooledKernel(); shutdown == nil {
+					shutdown = c.takeIdleKernel()
+				}
+			}
+			if shutdown == nil {
+				return nil, shutdowns, ErrKernelLimitReached
+			}
+			shutdowns = append(shutdowns, shutdown)
+			live--
+		}
+	}
+
+	c.pendingKernels++
+	return func() {
+		c.kernelSlotMu.Lock()
+		defer c.kernelSlotMu.Unlock()
+
+		c.pendingKernels--
+	}, shutdowns, nil
+}
+
+// evictIdleKernel evicts the least recently used kernel that is not
+// executing, reporting whether there was one.
+func (c *Controller) evictIdleKernel() bool {
+	c.kernelSlotMu.Lock()
+	shutdown := c.takeIdleKernel()
+	c.kernelSlotMu.Unlock()
+	if shutdown == nil {
+		return false
+	}
+	shutdown()
+	return true
+}
+
+// takeIdleKernel unmaps the least recently used kernel that is not executing
+// and returns the shutdown that completes its eviction, or nil when every
+// kernel is busy. Callers must hold kernelSlotMu.
+func (c *Controller) takeIdleKernel() func() {
+	type candidate struct {
+		session  string
+		kernel   *jupyterKernel
+		lastUsed int64
+	}
+
+	c.mu.RLock()
+	candidates := make([]candidate, 0, len(c.jupyterClientMap))
+	for session, kernel := range c.jupyterClientMap {
+		if kernel != nil {
+			candidates = append(candidates, candidate{session: session, kernel: kernel, lastUsed: kernel.lastUsed.Load()})
+		}
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].lastUsed < candidates[j].lastUsed
+	})
+
+	for _, cand := range candidates {
+		// a kernel running code holds its lock, so only idle kernels are taken.
+		if !cand.kernel.mu.TryLock() {
+			continue
+		}
+		// the kernel may have run code, or been deleted, since it was listed
+		if !c.stillIdle(cand.session, cand.kernel, cand.lastUsed) {
+			cand.kernel.mu.Unlock()
+			continue
+		}
+		c.unmapJupyterKernel(cand.session)
+		// a runJupyter that fetched the kernel before it was unmapped
+		// finds this once it gets the lock
+		cand.kernel.evicted = true
+		cand.kernel.mu.Unlock()
+
+		eviction := KernelEviction{
+			Session:   cand.session,
+			Language:  cand.kernel.language,
+			LastUsed:  time.Unix(0, cand.lastUsed),
+			EvictedAt: time.Now(),
+		}
+		c.recordEviction(eviction)
+		limit, onEvicted := c.maxKernels, c.onKernelEvicted
+		return func() {
+			if err := c.jupyterClient().DeleteSession(eviction.Session); err != nil {
+				log.Warning("failed to shut down evicted kernel %s: %v", eviction.Session, err)
+			}
+			log.Info("evicted idle kernel %s (%s) to stay within %d kernels", eviction.Session, eviction.Language, limit)
+			if onEvicted != nil {
+				onEvicted(eviction)
+			}
+		}
+	}
+	return nil
+}
+
+// stillIdle reports whether kernel is still mapped to session and unused
+// since lastUsed. Callers must hold kernel.mu.
+func (c *Controller) stillIdle(session string, kernel *jupyterKernel, lastUsed int64) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.jupyterClientMap[session] == kernel && kernel.lastUsed.Load() == lastUsed
+}
+
+func (c *Controller) recordEviction(eviction KernelEviction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.evictedSessions) >= maxEvictedRecords {
+		for session := range c.evictedSessions {
+			delete(c.evictedSessions, session)
+			break
+		}
+	}
+	c.evictedSessions[eviction.Session] = eviction.EvictedAt
+}
+
+// evictedAt reports when session was reclaimed by the kernel limit.
+func (c *Controller) evictedAt(session string) (time.Time, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	at, ok := c.evictedSessions[session]
+	return at, ok
+}
+
+// reportEviction tells the caller of an execution that its context was
+// evicted, as an error event, so a client can tell it from any other failure
+// and create a new context.
+func (c *Controller) reportEviction(request *ExecuteCodeRequest, session string) {
+	at, _ := c.evictedAt(session)
+	log.Info("execution in evicted context %s refused", session)
+	request.SetDefaultHooks()
+	request.Hooks.OnExecuteError(&execute.ErrorOutput{
+		EName:  ContextEvictedErrorName,
+		EValue: fmt.Sprintf("context %s was evicted at %s to free a kernel slot, create a new context", session, at.UTC().Format(time.RFC3339)),
+	})
+}
@@ -0,0 +1,257 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+// newKernelLimitController returns a controller backed by a fake Jupyter server
+// that accepts session deletes and records them.
+func newKernelLimitController(t *testing.T, limit int) (*Controller, func() []string) {
+	t.Helper()
+
+	var (
+		mu      sync.Mutex
+		deleted []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			t.Errorf("unexpected method: %s", r.Method)
+		}
+		mu.Lock()
+		deleted = append(deleted, path.Base(r.URL.Path))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	c := NewController(server.URL, "token")
+	c.SetMaxKernels(limit)
+	return c, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), deleted...)
+	}
+}
+
+func addKernel(c *Controller, session string, lastUsed time.Time) *jupyterKernel {
+	kernel := &jupyterKernel{language: Python}
+	kernel.lastUsed.Store(lastUsed.UnixNano())
+	c.storeJupyterKernel(session, kernel)
+	return kernel
+}
+
+func TestReserveKernelSlot_EvictsLeastRecentlyUsedIdleKernel(t *testing.T) {
+	c, deleted := newKernelLimitController(t, 3)
+
+	base := time.Now().Add(-time.Hour)
+	busy := addKernel(c, "oldest-busy", base)
+	addKernel(c, "older-idle", base.Add(time.Minute))
+	addKernel(c, "newest-idle", base.Add(2*time.Minute))
+
+	// The oldest kernel is executing code, so it must be skipped.
+	busy.mu.Lock()
+	defer busy.mu.Unlock()
+
+	var evictions []KernelEviction
+	c.SetKernelEvictionHandler(func(e KernelEviction) { evictions = append(evictions, e) })
+
+	release, err := c.reserveKernelSlot()
+	if err != nil {
+		t.Fatalf("reserveKernelSlot error: %v", err)
+	}
+	release()
+
+	if got := deleted(); len(got) != 1 || got[0] != "older-idle" {
+		t.Fatalf("expected older-idle to be shut down, got %v", got)
+	}
+	if c.getJupyterKernel("older-idle") != nil {
+		t.Fatalf("expected evicted kernel to be removed from cache")
+	}
+	if c.getJupyterKernel("oldest-busy") == nil || c.getJupyterKernel("newest-idle") == nil {
+		t.Fatalf("expected busy and recently used kernels to survive")
+	}
+	if len(evictions) != 1 || evictions[0].Session != "older-idle" || evictions[0].Language != Python {
+		t.Fatalf("unexpected eviction events: %+v", evictions)
+	}
+
+	var reported *execute.ErrorOutput
+	req := &ExecuteCodeRequest{Language: Python, Context: "older-idle"}
+	req.Hooks.OnExecuteError = func(e *execute.ErrorOutput) { reported = e }
+	if err = c.runJupyter(t.Context(), req); err != nil {
+		t.Fatalf("expected the eviction to be reported as an event, got %v", err)
+	}
+	if reported == nil || reported.EName != ContextEvictedErrorName || !strings.Contains(reported.EValue, "older-idle") {
+		t.Fatalf("expected a ContextEvicted error event, got %+v", reported)
+	}
+	err = c.runJupyter(t.Context(), &ExecuteCodeRequest{Language: Python, Context: "never-existed"})
+	if !errors.Is(err, ErrContextNotFound) {
+		t.Fatalf("expected ErrContextNotFound for unknown context, got %v", err)
+	}
+}
+
+// A kernel fetched for an execution just before it was evicted must not be
+// run on once the execution gets its lock.
+func TestRunJupyterCode_KernelEvictedWhileWaiting(t *testing.T) {
+	c, _ := newKernelLimitController(t, 1)
+	kernel := addKernel(c, "fetched", time.Now().Add(-time.Minute))
+
+	fetched := c.getJupyterKernel("fetched")
+	if !c.evictIdleKernel() {
+		t.Fatalf("expected the idle kernel to be evicted")
+	}
+	if err := c.runJupyterCode(t.Context(), fetched, &ExecuteCodeRequest{Language: Python}); !errors.Is(err, errKernelEvicted) {
+		t.Fatalf("expected errKernelEvicted, got %v", err)
+	}
+	if !kernel.evicted {
+		t.Fatalf("expected the kernel to be marked evicted")
+	}
+}
+
+// A kernel used after the candidates were listed is no longer the least
+// recently used one and is skipped.
+func TestStillIdle_KernelUsedSinceListing(t *testing.T) {
+	c, _ := newKernelLimitController(t, 1)
+	kernel := addKernel(c, "used", time.Now().Add(-time.Minute))
+	if !c.stillIdle("used", kernel, kernel.lastUsed.Load()) {
+		t.Fatalf("expected an unused, mapped kernel to be idle")
+	}
+	listed := kernel.lastUsed.Load()
+	kernel.touch()
+	if c.stillIdle("used", kernel, listed) {
+		t.Fatalf("expected a kernel used since listing not to count as idle")
+	}
+	if c.stillIdle("other", kernel, kernel.lastUsed.Load()) {
+		t.Fatalf("expected an unmapped kernel not to count as idle")
+	}
+}
+
+func TestReserveKernelSlot_RejectsWhenAllBusy(t *testing.T) {
+	c, deleted := newKernelLimitController(t, 2)
+
+	for _, session := range []string{"a", "b"} {
+		kernel := addKernel(c, session, time.Now())
+		kernel.mu.Lock()
+		defer kernel.mu.Unlock()
+	}
+
+	if _, err := c.reserveKernelSlot(); !errors.Is(err, ErrKernelLimitReached) {
+		t.Fatalf("expected ErrKernelLimitReached, got %v", err)
+	}
+	if got := deleted(); len(got) != 0 {
+		t.Fatalf("expected no kernels shut down, got %v", got)
+	}
+}
+
+func TestReserveKernelSlot_CountsPendingReservations(t *testing.T) {
+	c, deleted := newKernelLimitController(t, 2)
+	addKernel(c, "idle", time.Now())
+
+	release, err := c.reserveKernelSlot()
+	if err != nil {
+		t.Fatalf("first reservation should fit under the limit: %v", err)
+	}
+	if got := deleted(); len(got) != 0 {
+		t.Fatalf("expected no eviction for the first reservation, got %v", got)
+	}
+
+	// A second reservation while the first is still pending needs the idle slot.
+	release2, err := c.reserveKernelSlot()
+	if err != nil {
+		t.Fatalf("second reservation error: %v", err)
+	}
+	release()
+	release2()
+
+	if got := deleted(); len(got) != 1 || got[0] != "idle" {
+		t.Fatalf("expected idle kernel to be evicted, got %v", got)
+	}
+}
+
+func TestReserveKernelSlot_Unlimited(t *testing.T) {
+	c, deleted := newKernelLimitController(t, 0)
+	for i := 0; i < 5; i++ {
+		addKernel(c, string(rune('a'+i)), time.Now())
+	}
+
+	release, err := c.reserveKernelSlot()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	release()
+	if got := deleted(); len(got) != 0 {
+		t.Fatalf("expected no eviction without a limit, got %v", got)
+	}
+}
+
+// A kernel shut down to make room does not hold up other reservations while
+// the shutdown is in flight.
+func TestReserveKernelSlot_ShutdownDoesNotBlockOtherSlots(t *testing.T) {
+	deleting := make(chan struct{})
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(deleting)
+		<-unblock
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	defer close(unblock)
+
+	c := NewController(server.URL, "token")
+	c.SetMaxKernels(1)
+	addKernel(c, "idle", time.Now().Add(-time.Minute))
+
+	reserved := make(chan error, 1)
+	go func() {
+		release, err := c.reserveKernelSlot()
+		if err == nil {
+			release()
+		}
+		reserved <- err
+	}()
+	<-deleting
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.reserveKernelSlotWithoutEviction()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrKernelLimitReached) {
+			t.Fatalf("expected the evicted kernel's slot to stay reserved, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("a reservation waited for another session's kernel shutdown")
+	}
+	if c.getJupyterKernel("idle") != nil {
+		t.Fatalf("expected the evicted kernel to be unmapped before its shutdown")
+	}
+
+	unblock <- struct{}{}
+	if err := <-reserved; err != nil {
+		t.Fatalf("reserveKernelSlot error: %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"k8s.io/client-go/util/retry"
+
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+	"github.com/alibaba/opensandbox/execd/pkg/util/safego"
+)
+
+// pooledKernel is an idle session started ahead of demand. It has never run
+// code, so handing it out gives the caller a fresh interpreter.
+type pooledKernel struct {
+	session string
+	kernel  *jupyterKernel
+}
+
+// SetKernelPool keeps sizes[language] idle kernels ready so that CreateContext
+// hands one out instead of starting a kernel, and starts filling the pools in
+// the background. Languages without a Jupyter kernel are ignored.
+func (c *Controller) SetKernelPool(sizes map[string]int) {
+	if c.baseURL == "" || c.token == "" {
+		if len(sizes) > 0 {
+			log.Warning("kernel pool disabled: language runtime server not configured")
+		}
+		return
+	}
+
+	c.poolMu.Lock()
+	c.poolSizes = make(map[Language]int, len(sizes))
+	for name, size := range sizes {
+		language := Language(name)
+		if !isKernelLanguage(language) {
+			log.Warning("kernel pool: %s has no kernel, ignored", name)
+			continue
+		}
+		c.poolSizes[language] = size
+	}
+	languages := make([]Language, 0, len(c.poolSizes))
+	for language := range c.poolSizes {
+		languages = append(languages, language)
+	}
+	c.poolMu.Unlock()
+
+	for _, language := range languages {
+		c.replenishKernelPool(language)
+	}
+}
+
+func isKernelLanguage(language Language) bool {
+	switch language {
+	case Bash, Python, Java, JavaScript, TypeScript, Go:
+		return true
+	default:
+		return false
+	}
+}
+
+// takePooledKernel removes an idle kernel for language from the pool and
+// schedules its replacement.
+func (c *Controller) takePooledKernel(language Language) (*pooledKernel, bool) {
+	c.poolMu.Lock()
+	idle := c.pooledKernels[language]
+	if len(idle) == 0 {
+		c.poolMu.Unlock()
+		return nil, false
+	}
+	pooled := idle[0]
+	c.pooledKernels[language] = idle[1:]
+	c.poolMu.Unlock()
+
+	c.replenishKernelPool(language)
+	return pooled, true
+}
+
+// replenishKernelPool starts enough kernels to bring the pool for language
+// back to its configured size.
+func (c *Controller) replenishKernelPool(language Language) {
+	c.poolMu.Lock()
+	missing := c.poolSizes[language] - len(c.pooledKernels[language]) - c.poolStarting[language]
+	if missing > 0 {
+		c.poolStarting[language] += missing
+	}
+	c.poolMu.Unlock()
+
+	for i := 0; i < missing; i++ {
+		safego.Go(func() { c.startPooledKernel(language) })
+	}
+}
+
+// startPooledKernel starts one kernel for the pool. Pooled kernels count
+// toward the kernel limit but never evict a context to make room.
+func (c *Controller) startPooledKernel(language Language) {
+	defer func() {
+		c.poolMu.Lock()
+		c.poolStarting[language]--
+		c.poolMu.Unlock()
+	}()
+
+	release, err := c.reserveKernelSlotWithoutEviction()
+	if err != nil {
+		log.Info("kernel pool for %s not refilled: %v", language, err)
+		return
+	}
+	defer release()
+
+	var pooled *pooledKernel
+	err = retry.OnError(kernelWaitingBackoff, func(err error) bool {
+		log.Error("failed to start pooled kernel, retrying: %v", err)
+		return err != nil
+	}, func() error {
+		client, session, err := c.createContext(CreateContextRequest{Language: language})
+		if err != nil {
+			return err
+		}
+		pooled = &pooledKernel{
+			session: session.ID,
+			kernel:  &jupyterKernel{kernelID: session.Kernel.ID, client: client, language: language},
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error("failed to start pooled %s kernel: %v", language, err)
+		return
+	}
+
+	c.poolMu.Lock()
+	c.pooledKernels[language] = append(c.pooledKernels[language], pooled)
+	c.poolMu.Unlock()
+	log.Info("pooled %s kernel %s ready", language, pooled.session)
+}
+
+// pooledKernelCount is the number of idle kernels held by the pools; those
+// still starting hold a pending reservation instead.
+func (c *Controller) pooledKernelCount() int {
+	c.poolMu.Lock()
+	defer c.poolMu.Unlock()
+
+	n := 0
+	for _, idle := range c.pooledKernels {
+		n += len(idle)
+	}
+	return n
+}
+
+// dropPooledKernel takes one idle pooled kernel out of its pool to make room
+// for a context and returns its shutdown, or nil when no kernel is pooled.
+// The pool is not refilled until a kernel is handed out again. Callers must
+// hold kernelSlotMu.
+func (c *Controller) dropPooledKernel() func() {
+	c.poolMu.Lock()
+	var pooled *pooledKernel
+	for language, idle := range c.pooledKernels {
+		if len(idle) > 0 {
+			pooled = idle[len(idle)-1]
+			c.pooledKernels[language] = idle[:len(idle)-1]
+			break
+		}
+	}
+	c.poolMu.Unlock()
+
+	if pooled == nil {
+		return nil
+	}
+	limit := c.maxKernels
+	return func() {
+		if err := c.jupyterClient().DeleteSession(pooled.session); err != nil {
+			log.Warning("failed to shut down pooled kernel %s: %v", pooled.session, err)
+		}
+		log.Info("dropped pooled %s kernel %s to stay within %d kernels", pooled.kernel.language, pooled.session, limit)
+	}
+}
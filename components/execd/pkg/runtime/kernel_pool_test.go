@@ -0,0 +1,188 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeJupyter serves the session and kernel endpoints CreateContext uses and
+// records which kernels were started and shut down.
+type fakeJupyter struct {
+	mu       sync.Mutex
+	started  []string
+	sessions map[string]string // session ID -> kernel ID
+}
+
+func newFakeJupyter(t *testing.T) (*fakeJupyter, string) {
+	t.Helper()
+
+	f := &fakeJupyter{sessions: make(map[string]string)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/kernelspecs":
+			_, _ = w.Write([]byte(`{"default":"python3","kernelspecs":{"py":{"name":"py","spec":{"language":"python"}}}}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/sessions":
+			n := len(f.started) + 1
+			session, kernel := fmt.Sprintf("session-%d", n), fmt.Sprintf("kernel-%d", n)
+			f.started = append(f.started, session)
+			f.sessions[session] = kernel
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": session, "kernel": map[string]string{"id": kernel}})
+		case r.Method == http.MethodGet && r.URL.Path == "/api/kernels":
+			kernels := make([]map[string]string, 0, len(f.sessions))
+			for _, kernel := range f.sessions {
+				kernels = append(kernels, map[string]string{"id": kernel})
+			}
+			_ = json.NewEncoder(w).Encode(kernels)
+		case r.Method == http.MethodDelete:
+			delete(f.sessions, path.Base(r.URL.Path))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return f, server.URL
+}
+
+func (f *fakeJupyter) startedSessions() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.started...)
+}
+
+func (c *Controller) idleKernelSessions(language Language) []string {
+	c.poolMu.Lock()
+	defer c.poolMu.Unlock()
+
+	var sessions []string
+	for _, pooled := range c.pooledKernels[language] {
+		sessions = append(sessions, pooled.session)
+	}
+	return sessions
+}
+
+func waitForPool(t *testing.T, c *Controller, language Language, size int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sessions := c.idleKernelSessions(language)
+		if len(sessions) == size {
+			return sessions
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d pooled %s kernels, have %v", size, language, sessions)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestKernelPool_HandsOutFreshKernelsAndReplenishes(t *testing.T) {
+	jupyter, url := newFakeJupyter(t)
+	c := NewController(url, "token")
+	c.SetKernelPool(map[string]int{"python": 2, "command": 3})
+
+	pooled := waitForPool(t, c, Python, 2)
+	if got := jupyter.startedSessions(); len(got) != 2 {
+		t.Fatalf("expected only the python pool to start kernels, started %v", got)
+	}
+
+	session, err := c.CreateContext(&CreateContextRequest{Language: Python})
+	if err != nil {
+		t.Fatalf("CreateContext: %v", err)
+	}
+	if session != pooled[0] {
+		t.Fatalf("expected pooled session %s to be handed out, got %s", pooled[0], session)
+	}
+	if kernel := c.getJupyterKernel(session); kernel == nil || kernel.kernelID == "" {
+		t.Fatalf("expected the handed-out kernel to be registered as a context")
+	}
+
+	refilled := waitForPool(t, c, Python, 2)
+	if got := jupyter.startedSessions(); len(got) != 3 || refilled[1] != got[2] {
+		t.Fatalf("expected one new kernel to refill the pool, started %v, pool %v", got, refilled)
+	}
+
+	// A deleted context is shut down, never returned to the pool, so the
+	// next caller cannot see state left behind by the previous one.
+	if err := c.DeleteContext(session); err != nil {
+		t.Fatalf("DeleteContext: %v", err)
+	}
+	next, err := c.CreateContext(&CreateContextRequest{Language: Python})
+	if err != nil {
+		t.Fatalf("CreateContext: %v", err)
+	}
+	if next == session {
+		t.Fatalf("expected a fresh kernel, got the deleted context %s again", session)
+	}
+	for _, idle := range waitForPool(t, c, Python, 2) {
+		if idle == session || idle == next {
+			t.Fatalf("pool holds a kernel that was already handed out: %s", idle)
+		}
+	}
+}
+
+func TestKernelPool_SkippedForContextsWithCwd(t *testing.T) {
+	jupyter, url := newFakeJupyter(t)
+	c := NewController(url, "token")
+	c.SetKernelPool(map[string]int{"python": 1})
+	pooled := waitForPool(t, c, Python, 1)
+
+	session, err := c.CreateContext(&CreateContextRequest{Language: Python, Cwd: t.TempDir()})
+	if err != nil {
+		t.Fatalf("CreateContext: %v", err)
+	}
+	if session == pooled[0] || len(jupyter.startedSessions()) != 2 {
+		t.Fatalf("expected a context with its own cwd to start a new kernel")
+	}
+	if idle := c.idleKernelSessions(Python); len(idle) != 1 || idle[0] != pooled[0] {
+		t.Fatalf("expected the pooled kernel to stay idle, pool %v", idle)
+	}
+}
+
+func TestKernelPool_RespectsKernelLimit(t *testing.T) {
+	_, url := newFakeJupyter(t)
+	c := NewController(url, "token")
+	c.SetMaxKernels(2)
+	addKernel(c, "user", time.Now())
+
+	// Filling the pool must not evict the user's idle context.
+	c.SetKernelPool(map[string]int{"python": 3})
+	waitForPool(t, c, Python, 1)
+	time.Sleep(20 * time.Millisecond)
+	if idle := c.idleKernelSessions(Python); len(idle) != 1 || c.getJupyterKernel("user") == nil {
+		t.Fatalf("expected one pooled kernel beside the user context, pool %v", idle)
+	}
+
+	// A new context with a cwd needs a slot: the pooled kernel gives way first.
+	if _, err := c.CreateContext(&CreateContextRequest{Language: Python, Cwd: t.TempDir()}); err != nil {
+		t.Fatalf("CreateContext: %v", err)
+	}
+	if idle := c.idleKernelSessions(Python); len(idle) != 0 || c.getJupyterKernel("user") == nil {
+		t.Fatalf("expected the pooled kernel to be dropped before any context, pool %v", idle)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"container/list"
+	"context"
+)
+
+// defaultCommandsPerLane keeps a lane's commands in order, like a shell.
+const defaultCommandsPerLane = 1
+
+// laneQueue orders the executions of one lane: up to the lane's limit run at
+// once and the rest wait in arrival order. A code context is a lane of its own.
+type laneQueue struct {
+	running int
+	waiters list.List // of *commandWaiter
+}
+
+// SetMaxCommandsPerLane caps how many commands sharing a Lane run at once;
+// later ones wait their turn in arrival order. Zero or less restores the
+// default of one, which runs a lane's commands one after another. Commands
+// without a Lane are not limited. Code contexts always run one
+// execution at a time, so interleaved executions cannot corrupt their state.
+func (c *Controller) SetMaxCommandsPerLane(limit int) {
+	c.laneSlotMu.Lock()
+	defer c.laneSlotMu.Unlock()
+
+	c.maxLaneCommands = limit
+}
+
+// laneSlot returns the queue key and limit request is run under, or an
+// empty key when it is not limited.
+func (c *Controller) laneSlot(request *ExecuteCodeRequest) (string, int) {
+	switch request.Language {
+	case Command:
+		if request.Lane == "" || request.DryRun {
+			return "", 0
+		}
+		c.laneSlotMu.Lock()
+		defer c.laneSlotMu.Unlock()
+		if c.maxLaneCommands <= 0 {
+			return "command:" + request.Lane, defaultCommandsPerLane
+		}
+		return "command:" + request.Lane, c.maxLaneCommands
+	case Bash, Python, Java, JavaScript, TypeScript, Go:
+		if request.Context == "" {
+			// requests without a context share their language's default one
+			return "default:" + string(request.Language), 1
+		}
+		return "context:" + request.Context, 1
+	default:
+		return "", 0
+	}
+}
+
+// acquireLaneSlot blocks until one more execution may run under key, or
+// ctx is done. A request cancelled while queued leaves the queue without
+// running. The returned release must be called once the execution is over.
+func (c *Controller) acquireLaneSlot(ctx context.Context, key string, limit int) (func(), error) {
+	if key == "" {
+		return func() {}, nil
+	}
+	release := func() { c.releaseLaneSlot(key) }
+
+	c.laneSlotMu.Lock()
+	if err := ctx.Err(); err != nil {
+		c.laneSlotMu.Unlock()
+		return nil, err
+	}
+	if c.laneQueues == nil {
+		c.laneQueues = make(map[string]*laneQueue)
+	}
+	q, ok := c.laneQueues[key]
+	if !ok {
+		q = &laneQueue{}
+		c.laneQueues[key] = q
+	}
+	if q.running < limit && q.waiters.Len() == 0 {
+		q.running++
+		c.laneSlotMu.Unlock()
+		return release, nil
+	}
+	waiter := &commandWaiter{ready: make(chan struct{})}
+	elem := q.waiters.PushBack(waiter)
+	c.laneSlotMu.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return release, nil
+	case <-ctx.Done():
+	}
+
+	c.laneSlotMu.Lock()
+	if waiter.granted {
+		// the slot was handed over while ctx fired; pass it on.
+		c.laneSlotMu.Unlock()
+		release()
+	} else {
+		q.waiters.Remove(elem)
+		c.laneSlotMu.Unlock()
+	}
+	return nil, ctx.Err()
+}
+
+// releaseLaneSlot hands key's slot to its oldest waiter, or frees it.
+func (c *Controller) releaseLaneSlot(key string) {
+	c.laneSlotMu.Lock()
+	defer c.laneSlotMu.Unlock()
+
+	q := c.laneQueues[key]
+	if q == nil {
+		return
+	}
+	if front := q.waiters.Front(); front != nil {
+		waiter := q.waiters.Remove(front).(*commandWaiter)
+		waiter.granted = true
+		close(waiter.ready)
+		return
+	}
+	q.running--
+	if q.running <= 0 {
+		delete(c.laneQueues, key)
+	}
+}
+
+// queuedInLane reports how many executions wait under key.
+func (c *Controller) queuedInLane(key string) int {
+	c.laneSlotMu.Lock()
+	defer c.laneSlotMu.Unlock()
+
+	if q := c.laneQueues[key]; q != nil {
+		return q.waiters.Len()
+	}
+	return 0
+}
+
+// waitLaneSlot queues request behind the running executions of its lane
+// until either caller or the execution context is done.
+func (c *Controller) waitLaneSlot(caller, ctx context.Context, request *ExecuteCodeRequest) (func(), error) {
+	key, limit := c.laneSlot(request)
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(caller, cancel)
+	defer stop()
+
+	release, err := c.acquireLaneSlot(waitCtx, key, limit)
+	if err != nil && caller.Err() != nil {
+		err = caller.Err()
+	}
+	return release, err
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func waitForLaneQueue(t *testing.T, c *Controller, key string, want int) {
+	t.Helper()
+	require.Eventually(t, func() bool { return c.queuedInLane(key) == want }, 5*time.Second, 5*time.Millisecond)
+}
+
+func TestAcquireLaneSlot_HandsOverInArrivalOrder(t *testing.T) {
+	c := NewController("", "")
+	holder, err := c.acquireLaneSlot(context.Background(), "context:a", 1)
+	require.NoError(t, err)
+
+	// another lane is not held up
+	other, err := c.acquireLaneSlot(context.Background(), "context:b", 1)
+	require.NoError(t, err)
+	other()
+
+	order := make(chan int, 3)
+	for i := range 3 {
+		go func() {
+			release, err := c.acquireLaneSlot(context.Background(), "context:a", 1)
+			if !assert.NoError(t, err) {
+				return
+			}
+			order <- i
+			release()
+		}()
+		waitForLaneQueue(t, c, "context:a", i+1)
+	}
+
+	holder()
+	for want := range 3 {
+		assert.Equal(t, want, <-order)
+	}
+	waitForLaneQueue(t, c, "context:a", 0)
+	c.laneSlotMu.Lock()
+	defer c.laneSlotMu.Unlock()
+	assert.Empty(t, c.laneQueues, "idle lanes are forgotten")
+}
+
+func TestAcquireLaneSlot_CancelledWaiterLeavesQueue(t *testing.T) {
+	c := NewController("", "")
+	holder, err := c.acquireLaneSlot(context.Background(), "command:s", 1)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := c.acquireLaneSlot(ctx, "command:s", 1)
+		errCh <- err
+	}()
+	waitForLaneQueue(t, c, "command:s", 1)
+	cancel()
+	assert.ErrorIs(t, <-errCh, context.Canceled)
+	assert.Equal(t, 0, c.queuedInLane("command:s"))
+
+	holder()
+	release, err := c.acquireLaneSlot(context.Background(), "command:s", 1)
+	require.NoError(t, err, "the slot is free again")
+	release()
+}
+
+func TestLaneSlot(t *testing.T) {
+	c := NewController("", "")
+	cases := []struct {
+		req     ExecuteCodeRequest
+		key     string
+		limit   int
+		perLane int
+	}{
+		{ExecuteCodeRequest{Language: Python, Context: "ctx"}, "context:ctx", 1, 4},
+		{ExecuteCodeRequest{Language: Python}, "default:python", 1, 4},
+		{ExecuteCodeRequest{Language: Command, Lane: "s"}, "command:s", 1, 0},
+		{ExecuteCodeRequest{Language: Command, Lane: "s"}, "command:s", 4, 4},
+		{ExecuteCodeRequest{Language: Command}, "", 0, 4},
+		{ExecuteCodeRequest{Language: Command, Lane: "s", DryRun: true}, "", 0, 4},
+		{ExecuteCodeRequest{Language: BackgroundCommand, Lane: "s"}, "", 0, 4},
+	}
+	for _, tc := range cases {
+		c.SetMaxCommandsPerLane(tc.perLane)
+		key, limit := c.laneSlot(&tc.req)
+		assert.Equal(t, tc.key, key, "%+v", tc.req)
+		assert.Equal(t, tc.limit, limit, "%+v", tc.req)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// localePattern matches names like "C", "C.UTF-8" or "de_DE.UTF-8@euro".
+	localePattern = regexp.MustCompile(`^[A-Za-z0-9_.@-]{1,64}$`)
+	// timezonePattern matches IANA names like "Asia/Shanghai" and POSIX
+	// rules like "EST5EDT,M3.2.0,M11.1.0"; ".." is rejected separately.
+	timezonePattern = regexp.MustCompile(`^:?[A-Za-z0-9_+\-/.,:<>]{1,128}$`)
+)
+
+// CheckLocale reports whether a command's locale and timezone can be set, so
+// callers can reject the request before streaming starts. Whether the locale
+// is installed is only known to the programs that use it.
+func (c *Controller) CheckLocale(locale, timezone string) error {
+	if locale != "" && !localePattern.MatchString(locale) {
+		return fmt.Errorf("%w: locale %q is not a locale name such as C.UTF-8", ErrLocale, locale)
+	}
+	if timezone != "" && (!timezonePattern.MatchString(timezone) || strings.Contains(timezone, "..")) {
+		return fmt.Errorf("%w: timezone %q is not a zone name such as UTC or Asia/Shanghai", ErrLocale, timezone)
+	}
+	return nil
+}
+
+// localeEnv returns the variables that apply request's locale and timezone:
+// LC_ALL overrides every LC_* category the host sets, and LANG covers
+// programs that only look at it.
+func localeEnv(request *ExecuteCodeRequest) map[string]string {
+	env := make(map[string]string, 3)
+	if request.Locale != "" {
+		env["LC_ALL"] = request.Locale
+		env["LANG"] = request.Locale
+	}
+	if request.Timezone != "" {
+		env["TZ"] = request.Timezone
+	}
+	return env
+}
@@ -0,0 +1,69 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecute_LocaleAndTimezoneReachTheCommand(t *testing.T) {
+	skipWithoutBash(t)
+	t.Setenv("LC_ALL", "de_DE.UTF-8")
+	t.Setenv("LANG", "de_DE.UTF-8")
+	t.Setenv("TZ", "Europe/Berlin")
+
+	run := func(locale, timezone string) []string {
+		var stdout []string
+		req := &ExecuteCodeRequest{
+			Language: Command,
+			// a POSIX TZ rule needs no zoneinfo files, so date can show its name anywhere
+			Code:     `echo "$LC_ALL|$LANG|$TZ"; date +%Z`,
+			Timeout:  5 * time.Second,
+			Locale:   locale,
+			Timezone: timezone,
+			Hooks:    noopHooks(),
+		}
+		req.Hooks.OnExecuteStdout = func(s string) { stdout = append(stdout, s) }
+		require.NoError(t, NewController("", "").Execute(req))
+		return stdout
+	}
+
+	assert.Equal(t, []string{"C.UTF-8|C.UTF-8|XYZ-9", "XYZ"}, run("C.UTF-8", "XYZ-9"))
+	assert.Equal(t, "de_DE.UTF-8|de_DE.UTF-8|Europe/Berlin", run("", "")[0], "empty settings inherit execd's environment")
+}
+
+func TestResolveCommand_LocaleOverridesExtraEnv(t *testing.T) {
+	resolved := resolveCommand("linux", &ExecuteCodeRequest{Code: "date", Timezone: "UTC"}, map[string]string{"TZ": "Asia/Shanghai"})
+	assert.Contains(t, resolved.env, "TZ=UTC")
+	assert.NotContains(t, resolved.env, "TZ=Asia/Shanghai")
+	assert.Equal(t, "UTC", resolved.Timezone)
+	assert.Empty(t, resolved.Locale)
+}
+
+func TestCheckLocale(t *testing.T) {
+	c := NewController("", "")
+	for _, ok := range [][2]string{{"", ""}, {"C", "UTC"}, {"en_US.UTF-8", "America/Argentina/Buenos_Aires"}, {"de_DE.UTF-8@euro", "EST5EDT,M3.2.0,M11.1.0"}, {"C.UTF-8", "<+0330>-3:30"}} {
+		assert.NoError(t, c.CheckLocale(ok[0], ok[1]), "locale %q timezone %q", ok[0], ok[1])
+	}
+	for _, bad := range [][2]string{{"en US", ""}, {"C;rm -rf /", ""}, {"", "../../etc/passwd"}, {"", "Asia/Shanghai\n"}, {"", "UTC\nX=1"}} {
+		err := c.CheckLocale(bad[0], bad[1])
+		assert.True(t, errors.Is(err, ErrLocale), "locale %q timezone %q: got %v", bad[0], bad[1], err)
+	}
+}
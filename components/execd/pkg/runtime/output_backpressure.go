@@ -0,0 +1,235 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+)
+
+// outputDrainIdle is how long a stream's pipe may stay silent after the
+// command exits before it is closed on processes the command left behind.
+const outputDrainIdle = time.Second
+
+// outputGate holds writes to the log file of one of a command's streams back
+// while more than highWatermark bytes of it have not been delivered by the
+// tailer. Bytes the tailer passed on to a buffer, such as an outputCoalescer,
+// do not count as delivered until that buffer lets go of them.
+type outputGate struct {
+	file          io.Writer
+	highWatermark int64
+
+	mu        sync.Mutex
+	room      *sync.Cond
+	written   int64
+	delivered int64
+	held      int64
+	// notify wakes the tailer once more output is in the file, so it does
+	// not wait for its next tick to make room again.
+	notify chan struct{}
+}
+
+// newOutputGate returns nil, i.e. no backpressure, for a non-positive watermark.
+// The watermark is raised to utf8.UTFMax, so a line cut on a rune boundary
+// always has a whole rune to deliver.
+func newOutputGate(file io.Writer, highWatermark int) *outputGate {
+	if highWatermark <= 0 {
+		return nil
+	}
+	g := &outputGate{file: file, highWatermark: int64(max(highWatermark, utf8.UTFMax)), notify: make(chan struct{}, 1)}
+	g.room = sync.NewCond(&g.mu)
+	return g
+}
+
+// Write passes p to the file in pieces that fit under the watermark, waiting
+// for the tailer to catch up before each.
+func (g *outputGate) Write(p []byte) (int, error) {
+	total := 0
+	for len(p) > 0 {
+		g.mu.Lock()
+		for g.pendingLocked() >= g.highWatermark {
+			g.room.Wait()
+		}
+		room := g.highWatermark - g.pendingLocked()
+		g.mu.Unlock()
+
+		chunk := p[:min(int64(len(p)), room)]
+		n, err := g.file.Write(chunk)
+		total += n
+		g.mu.Lock()
+		g.written += int64(n)
+		g.mu.Unlock()
+		select {
+		case g.notify <- struct{}{}:
+		default:
+		}
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+	}
+	return total, nil
+}
+
+// advance records that the tailer has delivered the file up to pos.
+func (g *outputGate) advance(pos int64) {
+	if g == nil || pos < 0 {
+		return
+	}
+	g.mu.Lock()
+	if pos > g.delivered {
+		g.delivered = pos
+		g.room.Broadcast()
+	}
+	g.mu.Unlock()
+}
+
+// hold records that n bytes the tailer delivered are still buffered on their
+// way out; release(n) once they are out.
+func (g *outputGate) hold(n int) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	g.held += int64(n)
+	g.mu.Unlock()
+}
+
+func (g *outputGate) release(n int) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	g.held -= int64(n)
+	g.room.Broadcast()
+	g.mu.Unlock()
+}
+
+// pendingLocked is the number of bytes written but not yet delivered.
+func (g *outputGate) pendingLocked() int64 {
+	return g.written - g.delivered + g.held
+}
+
+// writes is ready once output was written since the tailer last read it;
+// never for a nil gate.
+func (g *outputGate) writes() <-chan struct{} {
+	if g == nil {
+		return nil
+	}
+	return g.notify
+}
+
+// maxLine is the longest line the tailer may hold back waiting for its end.
+// A longer one would never be delivered while its start blocks the writer,
+// so it is delivered in pieces instead. Zero is unlimited.
+func (g *outputGate) maxLine() int {
+	if g == nil {
+		return 0
+	}
+	return int(g.highWatermark)
+}
+
+// outputPipe feeds one stream of a command through a pipe and an outputGate
+// into its log file. While the gate is closed nothing reads the pipe, so once
+// the pipe buffer is full the command blocks in its own write.
+type outputPipe struct {
+	gate   *outputGate
+	r, w   *os.File
+	exited atomic.Bool
+	done   chan struct{}
+}
+
+// newOutputPipe returns nil, i.e. the command writes file directly, for a
+// non-positive watermark.
+func newOutputPipe(file io.Writer, highWatermark int) (*outputPipe, error) {
+	gate := newOutputGate(file, highWatermark)
+	if gate == nil {
+		return nil, nil
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	p := &outputPipe{gate: gate, r: r, w: w, done: make(chan struct{})}
+	go p.copy()
+	return p, nil
+}
+
+// writer is what the command's stream is attached to: the pipe, or file
+// itself when p is nil.
+func (p *outputPipe) writer(file io.Writer) io.Writer {
+	if p == nil {
+		return file
+	}
+	return p.w
+}
+
+// gateOrNil is the gate the stream's tailer reports delivery to.
+func (p *outputPipe) gateOrNil() *outputGate {
+	if p == nil {
+		return nil
+	}
+	return p.gate
+}
+
+// started closes execd's copy of the pipe's write end once the command holds it.
+func (p *outputPipe) started() {
+	if p != nil {
+		_ = p.w.Close()
+	}
+}
+
+// finish waits, after the command has exited, until everything it wrote is
+// in the log file. Processes it left behind that still hold the stream are
+// cut off once the pipe has been idle for outputDrainIdle.
+func (p *outputPipe) finish() {
+	if p == nil {
+		return
+	}
+	_ = p.w.Close()
+	p.exited.Store(true)
+	_ = p.r.SetReadDeadline(time.Now().Add(outputDrainIdle))
+	<-p.done
+}
+
+func (p *outputPipe) copy() {
+	defer close(p.done)
+	defer p.r.Close()
+	buf := make([]byte, 32<<10)
+	for {
+		if p.exited.Load() {
+			_ = p.r.SetReadDeadline(time.Now().Add(outputDrainIdle))
+		}
+		n, err := p.r.Read(buf)
+		if n > 0 {
+			if _, werr := p.gate.Write(buf[:n]); werr != nil {
+				return
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, os.ErrDeadlineExceeded) {
+				log.Warning("output pipe read failed: %v", err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lockedBuffer lets the test read what the gate wrote while a blocked
+// writer is still inside it.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestOutputGate_BlocksAtHighWatermark(t *testing.T) {
+	var file lockedBuffer
+	gate := newOutputGate(&file, 10)
+
+	n, err := gate.Write([]byte("0123456789"))
+	require.NoError(t, err)
+	assert.Equal(t, 10, n)
+
+	wrote := make(chan struct{})
+	go func() {
+		defer close(wrote)
+		_, _ = gate.Write([]byte("abcdef"))
+	}()
+	select {
+	case <-wrote:
+		t.Fatal("write went through above the high watermark")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	gate.advance(4)
+	select {
+	case <-wrote:
+		t.Fatal("write finished before there was room for all of it")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, "0123456789abcd", file.String(), "the part that fits is written")
+
+	gate.advance(12)
+	<-wrote
+	assert.Equal(t, "0123456789abcdef", file.String())
+}
+
+// A consumer slower than the command holds the command back instead of
+// letting its output pile up, and still receives every line in order.
+func TestRunCommand_HighWatermarkSlowConsumer(t *testing.T) {
+	skipWithoutBash(t)
+	const (
+		watermark = 16 << 10
+		lines     = 3000
+		line      = "0123456789012345678901234567890123456789012345678901234567890123456789012345678901234567890123456"
+	)
+	c := NewController("", "")
+	var (
+		mu        sync.Mutex
+		got       []string
+		delivered int64
+		logPath   string
+		backlog   int64
+	)
+	req := &ExecuteCodeRequest{
+		Language:            Command,
+		Code:                fmt.Sprintf("for i in $(seq 1 %d); do printf '%%05d %s\\n' $i; done", lines, line),
+		OutputHighWatermark: watermark,
+		Hooks:               noopHooks(),
+	}
+	req.Hooks.OnExecuteInit = func(session string) {
+		mu.Lock()
+		defer mu.Unlock()
+		logPath = c.stdoutFileName(session)
+	}
+	req.Hooks.OnExecuteStdout = func(s string) {
+		time.Sleep(50 * time.Microsecond)
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, s)
+		delivered += int64(len(s)) + 1
+		if logPath == "" {
+			return
+		}
+		if info, err := os.Stat(logPath); err == nil {
+			backlog = max(backlog, info.Size()-delivered)
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- c.Execute(req) }()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(60 * time.Second):
+		t.Fatal("command did not finish")
+	}
+
+	require.Len(t, got, lines)
+	for i, s := range got {
+		require.Equal(t, fmt.Sprintf("%05d %s", i+1, line), s)
+	}
+	assert.LessOrEqual(t, backlog, int64(watermark), "undelivered output exceeded the high watermark")
+	assert.Positive(t, backlog)
+}
+
+// A line longer than the watermark cannot wait for its end without blocking
+// its own writer, so it arrives in pieces.
+func TestRunCommand_HighWatermarkLongLine(t *testing.T) {
+	skipWithoutBash(t)
+	const watermark = 4 << 10
+	var pieces []string
+	req := &ExecuteCodeRequest{
+		Language:            Command,
+		Code:                "head -c 50000 /dev/zero | tr '\\0' x; echo; echo after",
+		OutputHighWatermark: watermark,
+		Hooks:               noopHooks(),
+	}
+	req.Hooks.OnExecuteStdout = func(s string) { pieces = append(pieces, s) }
+
+	require.NoError(t, NewController("", "").Execute(req))
+
+	require.NotEmpty(t, pieces)
+	assert.Equal(t, "after", pieces[len(pieces)-1])
+	long := strings.Join(pieces[:len(pieces)-1], "")
+	assert.Equal(t, strings.Repeat("x", 50000), long)
+	for _, p := range pieces {
+		assert.LessOrEqual(t, len(p), watermark)
+	}
+}
+
+// A background process that keeps the stream open does not hold the command
+// past its own exit for longer than the idle drain.
+func TestRunCommand_HighWatermarkLeftoverProcess(t *testing.T) {
+	skipWithoutBash(t)
+	var got []string
+	req := &ExecuteCodeRequest{
+		Language:            Command,
+		Code:                "sleep 30 & echo started",
+		OutputHighWatermark: 1 << 10,
+		Hooks:               noopHooks(),
+	}
+	req.Hooks.OnExecuteStdout = func(s string) { got = append(got, s) }
+
+	start := time.Now()
+	require.NoError(t, NewController("", "").Execute(req))
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.Equal(t, []string{"started"}, got)
+}
+
+// A line split at the watermark is cut between runes, never inside one.
+func TestRunCommand_HighWatermarkSplitsOnRuneBoundary(t *testing.T) {
+	skipWithoutBash(t)
+	const watermark = 4 << 10
+	var pieces []string
+	req := &ExecuteCodeRequest{
+		Language:            Command,
+		Code:                "for i in $(seq 1 5000); do printf '€'; done; echo",
+		OutputHighWatermark: watermark,
+		Hooks:               noopHooks(),
+	}
+	req.Hooks.OnExecuteStdout = func(s string) { pieces = append(pieces, s) }
+
+	require.NoError(t, NewController("", "").Execute(req))
+
+	require.Greater(t, len(pieces), 1)
+	for _, p := range pieces {
+		assert.True(t, utf8.ValidString(p), "piece of %d bytes cut inside a rune", len(p))
+	}
+	assert.Equal(t, strings.Repeat("€", 5000), strings.Join(pieces, ""))
+}
+
+// The tailer makes room again as soon as it delivered, instead of once per
+// tick, so a small watermark does not cap the output at one watermark per tick.
+func TestRunCommand_HighWatermarkKeepsUp(t *testing.T) {
+	skipWithoutBash(t)
+	const lines = 2000
+	count := 0
+	req := &ExecuteCodeRequest{
+		Language:            Command,
+		Code:                fmt.Sprintf("for i in $(seq 1 %d); do printf '%%099d\\n' $i; done", lines),
+		OutputHighWatermark: 1 << 10,
+		Hooks:               noopHooks(),
+	}
+	req.Hooks.OnExecuteStdout = func(string) { count++ }
+
+	start := time.Now()
+	require.NoError(t, NewController("", "").Execute(req))
+	assert.Equal(t, lines, count)
+	// 200 KB through a 1 KB watermark would take 20s at one watermark per tick
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestCompleteRunes(t *testing.T) {
+	euro := []byte("€")
+	assert.Equal(t, 2, completeRunes([]byte("ab")))
+	assert.Equal(t, 5, completeRunes(append([]byte("ab"), euro...)))
+	assert.Equal(t, 2, completeRunes(append([]byte("ab"), euro[:2]...)))
+	assert.Equal(t, 0, completeRunes(euro[:1]))
+	assert.Equal(t, 3, completeRunes([]byte{'a', 0xff, 0xfe}), "invalid bytes are not held back")
+}
@@ -0,0 +1,159 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCoalesceMaxBytes    = 64 << 10
+	defaultCoalesceMinInterval = 100 * time.Millisecond
+	defaultCoalesceMaxInterval = time.Second
+)
+
+// OutputCoalesce batches the output of a stream into fewer, larger stdout and
+// stderr events. Output is buffered as written, line terminators included,
+// and delivered once it reaches MaxBytes or once the flush interval has
+// passed since the oldest buffered byte, whichever comes first. The interval starts at MinInterval and doubles with
+// every timed flush while output keeps arriving, up to MaxInterval; it falls
+// back to MinInterval after the stream has been quiet for an interval.
+// Whatever is buffered when the command ends is delivered before completion.
+type OutputCoalesce struct {
+	// MaxBytes flushes the buffer once it holds this many bytes; zero means 64 KiB.
+	MaxBytes int `json:"max_bytes"`
+	// MinInterval is the first flush delay; zero means 100ms.
+	MinInterval time.Duration `json:"min_interval"`
+	// MaxInterval caps the flush delay, bounding how late output can be; zero means 1s.
+	MaxInterval time.Duration `json:"max_interval"`
+}
+
+// outputCoalescer buffers the output of one stream for an OutputCoalesce.
+type outputCoalescer struct {
+	deliver     func(string)
+	gate        *outputGate
+	maxBytes    int
+	minInterval time.Duration
+	maxInterval time.Duration
+
+	mu        sync.Mutex
+	buf       strings.Builder
+	interval  time.Duration
+	lastFlush time.Time
+	timer     *time.Timer
+	timerGen  int // identifies the armed timer, so a stale one that fires late does nothing
+	closed    bool
+	now       func() time.Time
+}
+
+// coalescedOutput wraps deliver per cfg. It returns the function to hand the
+// stream's output to, verbatim, and one that flushes what is left at the end;
+// with a nil cfg, output passes straight through and the flush does nothing.
+// What is buffered counts against gate's watermark until it is delivered.
+func coalescedOutput(cfg *OutputCoalesce, deliver func(string), gate *outputGate) (func(string), func()) {
+	if cfg == nil {
+		return deliver, func() {}
+	}
+	c := newOutputCoalescer(cfg, deliver, gate)
+	return c.write, c.close
+}
+
+// newOutputCoalescer applies the defaults for the unset fields of cfg. A
+// batch never outgrows gate's watermark, which would hold the writer back
+// until the next timed flush.
+func newOutputCoalescer(cfg *OutputCoalesce, deliver func(string), gate *outputGate) *outputCoalescer {
+	c := &outputCoalescer{
+		deliver:     deliver,
+		gate:        gate,
+		maxBytes:    cfg.MaxBytes,
+		minInterval: cfg.MinInterval,
+		maxInterval: cfg.MaxInterval,
+		now:         time.Now,
+	}
+	if c.maxBytes <= 0 {
+		c.maxBytes = defaultCoalesceMaxBytes
+	}
+	if limit := gate.maxLine(); limit > 0 {
+		c.maxBytes = min(c.maxBytes, limit)
+	}
+	if c.minInterval <= 0 {
+		c.minInterval = defaultCoalesceMinInterval
+	}
+	if c.maxInterval <= 0 {
+		c.maxInterval = max(defaultCoalesceMaxInterval, c.minInterval)
+	}
+	c.maxInterval = max(c.maxInterval, c.minInterval)
+	c.interval = c.minInterval
+	return c
+}
+
+func (c *outputCoalescer) write(text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		c.deliver(text)
+		return
+	}
+	if c.buf.Len() == 0 {
+		if c.now().Sub(c.lastFlush) > c.interval {
+			c.interval = c.minInterval
+		}
+		c.timerGen++
+		gen := c.timerGen
+		c.timer = time.AfterFunc(c.interval, func() { c.timedFlush(gen) })
+	}
+	c.buf.WriteString(text)
+	c.gate.hold(len(text))
+	if c.buf.Len() >= c.maxBytes {
+		c.flushLocked()
+	}
+}
+
+func (c *outputCoalescer) timedFlush(gen int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || gen != c.timerGen || c.buf.Len() == 0 {
+		return
+	}
+	c.flushLocked()
+	c.interval = min(2*c.interval, c.maxInterval)
+}
+
+// flushLocked delivers the buffer while holding mu, so events keep the
+// order their output was written in, and gives its bytes back to the gate
+// once they are out.
+func (c *outputCoalescer) flushLocked() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if c.buf.Len() == 0 {
+		return
+	}
+	text := c.buf.String()
+	c.buf.Reset()
+	c.lastFlush = c.now()
+	c.deliver(text)
+	c.gate.release(len(text))
+}
+
+func (c *outputCoalescer) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushLocked()
+	c.closed = true
+}
@@ -0,0 +1,185 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+// eventLog collects delivered events; timed flushes deliver from their own goroutine.
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) deliver(text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, text)
+}
+
+func (l *eventLog) snapshot() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.events...)
+}
+
+func TestCoalescedOutput_Passthrough(t *testing.T) {
+	log := &eventLog{}
+	write, flush := coalescedOutput(nil, log.deliver, nil)
+	write("a")
+	write("b")
+	flush()
+	assert.Equal(t, []string{"a", "b"}, log.snapshot())
+}
+
+func TestCoalescedOutput_FlushesOnSizeAndAtClose(t *testing.T) {
+	log := &eventLog{}
+	write, flush := coalescedOutput(&OutputCoalesce{MaxBytes: 12, MinInterval: time.Hour}, log.deliver, nil)
+	for _, chunk := range []string{"aaa\n", "bbb\n", "cc", "c\n", "d"} {
+		write(chunk)
+	}
+	assert.Equal(t, []string{"aaa\nbbb\nccc\n"}, log.snapshot(), "the size threshold flushes without waiting for the interval")
+
+	flush()
+	assert.Equal(t, []string{"aaa\nbbb\nccc\n", "d"}, log.snapshot(), "the final partial batch is flushed")
+	write("late")
+	assert.Equal(t, "late", log.snapshot()[2], "output after close passes straight through")
+}
+
+func TestCoalescedOutput_FlushesOnInterval(t *testing.T) {
+	log := &eventLog{}
+	write, flush := coalescedOutput(&OutputCoalesce{MinInterval: 20 * time.Millisecond, MaxInterval: 20 * time.Millisecond}, log.deliver, nil)
+	defer flush()
+	write("one\n")
+	write("two")
+	require.Eventually(t, func() bool { return len(log.snapshot()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"one\ntwo"}, log.snapshot())
+}
+
+func TestCoalescedOutput_IntervalBacksOffWhileBusy(t *testing.T) {
+	c := newOutputCoalescer(&OutputCoalesce{MinInterval: 10 * time.Millisecond, MaxInterval: 40 * time.Millisecond}, func(string) {}, nil)
+	defer c.close()
+	write := c.write
+	interval := func() time.Duration {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.interval
+	}
+
+	// Keep writing so every timed flush finds the stream busy.
+	for _, want := range []time.Duration{20 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond} {
+		deadline := time.Now().Add(time.Second)
+		for interval() != want && time.Now().Before(deadline) {
+			write("tick")
+			time.Sleep(2 * time.Millisecond)
+		}
+		require.Equal(t, want, interval())
+		// let the armed timer fire so the next one starts from the new interval
+		time.Sleep(want + 10*time.Millisecond)
+	}
+
+	// A quiet stream starts over at the minimum.
+	time.Sleep(60 * time.Millisecond)
+	write("after a pause")
+	assert.Equal(t, 10*time.Millisecond, interval())
+}
+
+func TestRunCommand_OutputCoalesceReducesEvents(t *testing.T) {
+	skipWithoutBash(t)
+	run := func(coalesce *OutputCoalesce) []string {
+		var events []string
+		req := &ExecuteCodeRequest{
+			Language:       Command,
+			Code:           "for i in $(seq 1 2000); do echo line-$i; done",
+			OutputCoalesce: coalesce,
+			Hooks:          noopHooks(),
+		}
+		req.Hooks.OnExecuteStdout = func(s string) { events = append(events, s) }
+		req.Hooks.OnExecuteError = func(err *execute.ErrorOutput) { t.Errorf("unexpected error %s: %s", err.EName, err.EValue) }
+		require.NoError(t, NewController("", "").Execute(req))
+		return events
+	}
+
+	lines := make([]string, 0, 2000)
+	for i := 1; i <= 2000; i++ {
+		lines = append(lines, "line-"+strconv.Itoa(i))
+	}
+	want := strings.Join(lines, "\n")
+
+	plain := run(nil)
+	coalesced := run(&OutputCoalesce{MaxBytes: 4096, MinInterval: 50 * time.Millisecond, MaxInterval: 200 * time.Millisecond})
+
+	assert.Len(t, plain, 2000)
+	assert.Less(t, len(coalesced), len(plain)/10, "coalescing should batch the lines into far fewer events")
+	assert.Equal(t, want, strings.Join(plain, "\n"))
+	assert.Equal(t, want+"\n", strings.Join(coalesced, ""), "coalesced events are the output as written")
+	for _, event := range coalesced {
+		assert.LessOrEqual(t, len(event), 4096+len("line-2000\n"), "a batch only exceeds max bytes by its last line")
+	}
+}
+
+func TestRunCommand_OutputCoalesceKeepsSplitLinesIntact(t *testing.T) {
+	skipWithoutBash(t)
+	var events []string
+	req := &ExecuteCodeRequest{
+		Language: Command,
+		// a line longer than the watermark reaches the coalescer in pieces
+		Code:                "printf 'x%.0s' $(seq 1 100); printf '\\n'; printf 'no newline'",
+		OutputHighWatermark: 16,
+		OutputCoalesce:      &OutputCoalesce{MinInterval: time.Hour},
+		Hooks:               noopHooks(),
+	}
+	req.Hooks.OnExecuteStdout = func(s string) { events = append(events, s) }
+	req.Hooks.OnExecuteError = func(err *execute.ErrorOutput) { t.Errorf("unexpected error %s: %s", err.EName, err.EValue) }
+	require.NoError(t, NewController("", "").Execute(req))
+
+	assert.Equal(t, strings.Repeat("x", 100)+"\nno newline", strings.Join(events, ""))
+	for _, event := range events {
+		assert.LessOrEqual(t, len(event), 16, "a batch stays under the watermark")
+	}
+}
+
+func TestOutputCoalescer_BufferedBytesAreNotDelivered(t *testing.T) {
+	gate := newOutputGate(io.Discard, 8)
+	pending := func() int64 {
+		gate.mu.Lock()
+		defer gate.mu.Unlock()
+		return gate.pendingLocked()
+	}
+	log := &eventLog{}
+	c := newOutputCoalescer(&OutputCoalesce{MaxBytes: 1 << 20, MinInterval: time.Hour}, log.deliver, gate)
+	assert.Equal(t, 8, c.maxBytes, "a batch is capped at the watermark")
+
+	_, err := gate.Write([]byte("hello"))
+	require.NoError(t, err)
+	c.write("hello")
+	gate.advance(5)
+	assert.EqualValues(t, 5, pending(), "bytes still in the buffer keep holding the writer back")
+
+	c.close()
+	assert.Equal(t, []string{"hello"}, log.snapshot())
+	assert.EqualValues(t, 0, pending(), "delivered bytes make room")
+}
@@ -0,0 +1,170 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+const outputDigestPrefix = "sha256:"
+
+var errOutputDigestBackground = errors.New("output digest is only supported for foreground commands")
+
+// OutputDigest hashes what an execution wrote to stdout and stderr, so a
+// caller re-running it can tell whether the output changed without keeping
+// and diffing it. Output is hashed line by line as delivered, so it does
+// not depend on how lines were batched into events.
+type OutputDigest struct {
+	// Normalize ignores line endings, trailing whitespace on each line and
+	// blank lines at the end of each stream.
+	Normalize bool `json:"normalize"`
+	// Ignore removes the matches of these patterns from every line before
+	// hashing, e.g. timestamps or durations that differ between runs.
+	Ignore []*regexp.Regexp `json:"-"`
+	// Previous is the digest of an earlier run; when set, the result tells
+	// whether this run's differs.
+	Previous string `json:"previous,omitempty"`
+}
+
+// OutputDigestResult is the digest of an execution's output.
+type OutputDigestResult struct {
+	// Digest is "sha256:" followed by the hex hash of both streams.
+	Digest string `json:"digest"`
+	// Changed compares Digest to OutputDigest.Previous; nil without one.
+	Changed *bool `json:"changed,omitempty"`
+}
+
+// CompileOutputDigestIgnore compiles patterns for OutputDigest.Ignore.
+func CompileOutputDigestIgnore(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output digest ignore pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// digestStream hashes one output stream.
+type digestStream struct {
+	h hash.Hash
+	// blank counts the empty lines not hashed yet; under Normalize they are
+	// dropped if nothing follows them.
+	blank int
+}
+
+// outputDigester computes the OutputDigestResult of one execution.
+type outputDigester struct {
+	cfg *OutputDigest
+
+	mu       sync.Mutex
+	stdout   digestStream
+	stderr   digestStream
+	reported bool
+}
+
+// newOutputDigester returns nil when cfg is nil.
+func newOutputDigester(cfg *OutputDigest) *outputDigester {
+	if cfg == nil {
+		return nil
+	}
+	return &outputDigester{
+		cfg:    cfg,
+		stdout: digestStream{h: sha256.New()},
+		stderr: digestStream{h: sha256.New()},
+	}
+}
+
+// write hashes text, one or more lines joined by "\n".
+func (d *outputDigester) write(s *digestStream, text string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, line := range strings.Split(text, "\n") {
+		for _, re := range d.cfg.Ignore {
+			line = re.ReplaceAllString(line, "")
+		}
+		if d.cfg.Normalize {
+			line = strings.TrimRight(line, " \t\r")
+			if line == "" {
+				s.blank++
+				continue
+			}
+			for ; s.blank > 0; s.blank-- {
+				fmt.Fprint(s.h, "0:\n")
+			}
+		}
+		// length-prefixed, so line boundaries cannot be shifted without changing the hash
+		fmt.Fprintf(s.h, "%d:%s\n", len(line), line)
+	}
+}
+
+// result finishes the digest; it is only computed once.
+func (d *outputDigester) result() *OutputDigestResult {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.reported {
+		return nil
+	}
+	d.reported = true
+	h := sha256.New()
+	h.Write(d.stdout.h.Sum(nil))
+	h.Write(d.stderr.h.Sum(nil))
+	result := &OutputDigestResult{Digest: outputDigestPrefix + hex.EncodeToString(h.Sum(nil))}
+	if d.cfg.Previous != "" {
+		changed := !strings.EqualFold(strings.TrimPrefix(d.cfg.Previous, outputDigestPrefix), strings.TrimPrefix(result.Digest, outputDigestPrefix))
+		result.Changed = &changed
+	}
+	return result
+}
+
+// wrap feeds the output hooks into the digest and reports it through
+// OnExecuteOutputDigest right before the execution's final event.
+func (d *outputDigester) wrap(hooks ExecuteResultHook) ExecuteResultHook {
+	wrapped := hooks
+	report := func() {
+		if result := d.result(); result != nil {
+			callHook(hooks.OnExecuteOutputDigest, result)
+		}
+	}
+	wrapped.OnExecuteStdout = func(text string) {
+		d.write(&d.stdout, text)
+		callHook(hooks.OnExecuteStdout, text)
+	}
+	wrapped.OnExecuteStderr = func(text string) {
+		d.write(&d.stderr, text)
+		callHook(hooks.OnExecuteStderr, text)
+	}
+	wrapped.OnExecuteError = func(err *execute.ErrorOutput) {
+		report()
+		callHook(hooks.OnExecuteError, err)
+	}
+	wrapped.OnExecuteComplete = func(elapsed time.Duration) {
+		report()
+		callHook(hooks.OnExecuteComplete, elapsed)
+	}
+	return wrapped
+}
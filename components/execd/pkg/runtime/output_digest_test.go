@@ -0,0 +1,130 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+// digestOf feeds stdout and stderr chunks through a digester for cfg.
+func digestOf(cfg *OutputDigest, stdout, stderr []string) *OutputDigestResult {
+	var result *OutputDigestResult
+	hooks := newOutputDigester(cfg).wrap(ExecuteResultHook{
+		OnExecuteOutputDigest: func(r *OutputDigestResult) { result = r },
+	})
+	for _, text := range stdout {
+		hooks.OnExecuteStdout(text)
+	}
+	for _, text := range stderr {
+		hooks.OnExecuteStderr(text)
+	}
+	hooks.OnExecuteComplete(time.Second)
+	return result
+}
+
+func TestOutputDigest_StableForIdenticalOutput(t *testing.T) {
+	first := digestOf(&OutputDigest{}, []string{"ok", "3 items"}, []string{"warn"})
+	require.NotNil(t, first)
+	assert.Regexp(t, `^sha256:[0-9a-f]{64}$`, first.Digest)
+	assert.Nil(t, first.Changed, "nothing to compare without a previous digest")
+
+	// the same lines, batched into events differently
+	again := digestOf(&OutputDigest{Previous: first.Digest}, []string{"ok\n3 items"}, []string{"warn"})
+	assert.Equal(t, first.Digest, again.Digest)
+	require.NotNil(t, again.Changed)
+	assert.False(t, *again.Changed)
+}
+
+func TestOutputDigest_DetectsChanges(t *testing.T) {
+	base := digestOf(&OutputDigest{}, []string{"ok", "3 items"}, nil)
+	for name, stdout := range map[string][]string{
+		"different line":   {"ok", "4 items"},
+		"joined lines":     {"ok3 items"},
+		"missing line":     {"ok"},
+		"trailing newline": {"ok", "3 items", ""},
+	} {
+		got := digestOf(&OutputDigest{Previous: base.Digest}, stdout, nil)
+		require.NotNil(t, got.Changed, name)
+		assert.True(t, *got.Changed, name)
+	}
+	// the same text on the other stream is a change too
+	moved := digestOf(&OutputDigest{Previous: base.Digest}, nil, []string{"ok", "3 items"})
+	assert.True(t, *moved.Changed)
+}
+
+func TestOutputDigest_Normalize(t *testing.T) {
+	ignore := []*regexp.Regexp{regexp.MustCompile(`\d+ms`)}
+	base := digestOf(&OutputDigest{Normalize: true, Ignore: ignore}, []string{"status: up", "", "took 12ms"}, nil)
+	noisy := digestOf(&OutputDigest{Normalize: true, Ignore: ignore, Previous: base.Digest},
+		[]string{"status: up  \r", "\r", "took 873ms", "", "  "}, nil)
+	assert.False(t, *noisy.Changed, "line endings, trailing blanks and ignored matches do not count")
+
+	// blank lines inside the output still do
+	inner := digestOf(&OutputDigest{Normalize: true, Ignore: ignore, Previous: base.Digest}, []string{"status: up", "took 1ms"}, nil)
+	assert.True(t, *inner.Changed)
+
+	plain := digestOf(&OutputDigest{Previous: base.Digest}, []string{"status: up", "", "took 12ms"}, nil)
+	assert.NotEqual(t, base.Digest, plain.Digest, "options are part of the digest's meaning, not its input")
+}
+
+func TestRunCommand_OutputDigest(t *testing.T) {
+	skipWithoutBash(t)
+	run := func(code, previous string) (*OutputDigestResult, []string) {
+		var result *OutputDigestResult
+		var events []string
+		req := &ExecuteCodeRequest{
+			Language:     Command,
+			Code:         code,
+			OutputDigest: &OutputDigest{Previous: previous},
+			Hooks:        noopHooks(),
+		}
+		req.Hooks.OnExecuteOutputDigest = func(r *OutputDigestResult) {
+			events = append(events, "digest")
+			result = r
+		}
+		req.Hooks.OnExecuteComplete = func(time.Duration) { events = append(events, "complete") }
+		req.Hooks.OnExecuteError = func(*execute.ErrorOutput) { events = append(events, "error") }
+		require.NoError(t, NewController("", "").Execute(req))
+		return result, events
+	}
+
+	first, events := run("echo up; echo degraded >&2", "")
+	assert.Equal(t, []string{"digest", "complete"}, events)
+	require.NotNil(t, first)
+	same, _ := run("echo up; echo degraded >&2", first.Digest)
+	assert.False(t, *same.Changed)
+	changed, events := run("echo down; exit 1", first.Digest)
+	assert.Equal(t, []string{"digest", "error"}, events, "a failed run still reports its digest")
+	assert.True(t, *changed.Changed)
+}
+
+func TestRunBackgroundCommand_RejectsOutputDigest(t *testing.T) {
+	skipWithoutBash(t)
+	err := NewController("", "").Execute(&ExecuteCodeRequest{
+		Language:     BackgroundCommand,
+		Code:         "true",
+		OutputDigest: &OutputDigest{},
+		Hooks:        noopHooks(),
+	})
+	assert.True(t, errors.Is(err, errOutputDigestBackground), "got %v", err)
+}
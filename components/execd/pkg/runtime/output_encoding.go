@@ -0,0 +1,110 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+// outputEncoding resolves an encoding label such as "gbk" or "shift_jis" to
+// the encoding command output is converted from. Empty and UTF-8 labels
+// return nil: the output is passed through as is.
+//
+// The tailer splits output into lines at '\n' and '\r' bytes before it is
+// decoded, which is only right as long as those bytes always stand for
+// themselves. UTF-16 and similar encodings, where they do not, are rejected.
+func outputEncoding(label string) (encoding.Encoding, error) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return nil, nil
+	}
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		return nil, fmt.Errorf("unknown output encoding %q", label)
+	}
+	name, _ := htmlindex.Name(enc)
+	switch {
+	case name == "utf-8":
+		return nil, nil
+	case strings.HasPrefix(name, "utf-16"), name == "replacement":
+		return nil, fmt.Errorf("output encoding %q is not supported: line breaks cannot be found in it byte by byte", label)
+	}
+	return enc, nil
+}
+
+// ValidateOutputEncoding checks that label names an encoding command output
+// can be converted from; empty is valid and means passthrough.
+func ValidateOutputEncoding(label string) error {
+	_, err := outputEncoding(label)
+	return err
+}
+
+// decodedOutput wraps onExecute so the output it is given is converted from
+// enc to UTF-8 first, with invalid sequences replaced by U+FFFD. A multi-byte
+// sequence cut between two calls, as where a long line is split or a
+// coalesced chunk ends, is held back until the call that completes it; flush
+// decodes what is still held once the stream has ended. A nil enc returns
+// onExecute unchanged.
+func decodedOutput(onExecute func(string), enc encoding.Encoding) (func(string), func()) {
+	if enc == nil {
+		return onExecute, func() {}
+	}
+	// the decoder holds back bytes and, for stateful encodings, its shift
+	// state between calls, so every stream needs its own
+	d := &streamDecoder{decoder: enc.NewDecoder()}
+	deliver := func(chunk string, atEOF bool) {
+		if decoded := d.decode(chunk, atEOF); decoded != "" {
+			onExecute(decoded)
+		}
+	}
+	return func(chunk string) { deliver(chunk, false) }, func() { deliver("", true) }
+}
+
+// streamDecoder decodes a stream given in chunks that may split its
+// multi-byte sequences.
+type streamDecoder struct {
+	decoder transform.Transformer
+	held    []byte // an incomplete sequence at the end of the last chunk
+}
+
+// decode converts the held bytes followed by chunk and holds back an
+// incomplete sequence at its end, unless atEOF.
+func (d *streamDecoder) decode(chunk string, atEOF bool) string {
+	src := append(d.held, chunk...)
+	var out strings.Builder
+	buf := make([]byte, len(src)*utf8.UTFMax+utf8.UTFMax)
+	for {
+		nDst, nSrc, err := d.decoder.Transform(buf, src, atEOF)
+		out.Write(buf[:nDst])
+		src = src[nSrc:]
+		if err == transform.ErrShortDst && nDst+nSrc > 0 {
+			continue
+		}
+		if err != nil && err != transform.ErrShortSrc {
+			// not expected of a decoder that replaces invalid input; pass the rest through
+			out.Write(src)
+			src = nil
+		}
+		break
+	}
+	d.held = append(d.held[:0], src...)
+	return out.String()
+}
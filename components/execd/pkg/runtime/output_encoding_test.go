@@ -0,0 +1,150 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// "你好，世界" and "中文" in GBK.
+var (
+	gbkHelloWorld = []byte{0xc4, 0xe3, 0xba, 0xc3, 0xa3, 0xac, 0xca, 0xc0, 0xbd, 0xe7}
+	gbkChinese    = []byte{0xd6, 0xd0, 0xce, 0xc4}
+)
+
+func TestOutputEncoding(t *testing.T) {
+	for _, label := range []string{"", "utf-8", " UTF8 "} {
+		enc, err := outputEncoding(label)
+		require.NoError(t, err, label)
+		assert.Nil(t, enc, "%q should pass output through", label)
+	}
+	for _, label := range []string{"gbk", "GB2312", "gb18030", "shift_jis", "euc-kr", "windows-1252"} {
+		enc, err := outputEncoding(label)
+		require.NoError(t, err, label)
+		assert.NotNil(t, enc, label)
+	}
+	for _, label := range []string{"klingon", "utf-16le", "utf-16"} {
+		assert.Error(t, ValidateOutputEncoding(label), label)
+	}
+}
+
+func TestDecodedOutput_GBK(t *testing.T) {
+	enc, err := outputEncoding("gbk")
+	require.NoError(t, err)
+	var got []string
+	onStdout, flush := decodedOutput(func(s string) { got = append(got, s) }, enc)
+
+	onStdout(string(gbkHelloWorld))
+	onStdout(string(gbkChinese) + " ok")
+	onStdout(string([]byte{0xc4}))
+	assert.Equal(t, []string{"你好，世界", "中文 ok"}, got, "a lone lead byte waits for its trail byte")
+
+	flush()
+	assert.Equal(t, []string{"你好，世界", "中文 ok", "�"}, got)
+}
+
+func TestDecodedOutput_Passthrough(t *testing.T) {
+	var got []string
+	onStdout, flush := decodedOutput(func(s string) { got = append(got, s) }, nil)
+	onStdout(string(gbkChinese))
+	flush()
+	assert.Equal(t, []string{string(gbkChinese)}, got)
+}
+
+// A character split between two deliveries, as where a long line is cut, is
+// decoded whole with the delivery that completes it.
+func TestDecodedOutput_SequenceSplitBetweenCalls(t *testing.T) {
+	enc, err := outputEncoding("gbk")
+	require.NoError(t, err)
+	var got []string
+	onStdout, flush := decodedOutput(func(s string) { got = append(got, s) }, enc)
+
+	onStdout(string(gbkHelloWorld[:3])) // "你" and the lead byte of "好"
+	onStdout(string(gbkHelloWorld[3:4]))
+	onStdout(string(gbkHelloWorld[4:]))
+	flush()
+	assert.Equal(t, []string{"你", "好", "，世界"}, got)
+}
+
+// The tailer cuts a line reaching the gate's maxLine between UTF-8 runes,
+// which can fall inside a GBK character; the decoder puts it back together.
+func TestDecodedOutput_LongLineCutInsideSequence(t *testing.T) {
+	enc, err := outputEncoding("gbk")
+	require.NoError(t, err)
+	logFile := filepath.Join(t.TempDir(), "stdout.log")
+	require.NoError(t, os.WriteFile(logFile, append(slices.Clone(gbkHelloWorld), '\n'), 0o644))
+	var got []string
+	onStdout, flush := decodedOutput(func(s string) { got = append(got, s) }, enc)
+	c := &Controller{}
+	gate := newOutputGate(io.Discard, 4)
+	c.readFromPosLimited(&sync.Mutex{}, logFile, 0, onStdout, true, nil, gate, false)
+	flush()
+
+	assert.Greater(t, len(got), 1, "the line is delivered in pieces")
+	assert.Equal(t, "你好，世界", strings.Join(got, ""))
+	for _, piece := range got {
+		assert.NotContains(t, piece, "\uFFFD")
+	}
+}
+
+// A character whose bytes land in the log file across two tailer reads is
+// delivered whole once its line is complete.
+func TestDecodedOutput_SequenceSplitAcrossReads(t *testing.T) {
+	enc, err := outputEncoding("gbk")
+	require.NoError(t, err)
+	logFile := filepath.Join(t.TempDir(), "stdout.log")
+	var got []string
+	onStdout, _ := decodedOutput(func(s string) { got = append(got, s) }, enc)
+	c := &Controller{}
+	mutex := &sync.Mutex{}
+
+	require.NoError(t, os.WriteFile(logFile, gbkHelloWorld[:3], 0o644))
+	pos := c.readFromPos(mutex, logFile, 0, onStdout, false)
+	assert.Empty(t, got)
+	assert.Equal(t, int64(0), pos, "the incomplete line is read again")
+
+	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write(append(append(gbkHelloWorld[3:], '\n'), gbkChinese...))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	c.readFromPos(mutex, logFile, pos, onStdout, true)
+
+	assert.Equal(t, []string{"你好，世界", "中文"}, got)
+}
+
+// Shift-JIS trail bytes overlap ASCII ("表" ends in a backslash), but never
+// '\n' or '\r', so line splitting leaves them alone.
+func TestDecodedOutput_ShiftJISTrailBytes(t *testing.T) {
+	enc, err := outputEncoding("shift_jis")
+	require.NoError(t, err)
+	logFile := filepath.Join(t.TempDir(), "stdout.log")
+	require.NoError(t, os.WriteFile(logFile, []byte{0x95, 0x5c, 0x8e, 0xa6, '\r', '\n'}, 0o644))
+	var got []string
+	c := &Controller{}
+	onStdout, _ := decodedOutput(func(s string) { got = append(got, s) }, enc)
+	c.readFromPos(&sync.Mutex{}, logFile, 0, onStdout, true)
+
+	assert.Equal(t, []string{"表示"}, got)
+}
@@ -0,0 +1,309 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+)
+
+// outputSinkPartSize is the multipart upload part size. Object stores reject
+// parts below 5 MiB except the last one.
+var outputSinkPartSize = 8 << 20
+
+var outputSinkClient = &http.Client{Timeout: 2 * time.Minute}
+
+var errOutputSinkBackground = errors.New("output sink is only supported for foreground commands")
+
+// OutputSink streams a command's stdout and stderr to an S3-compatible object
+// store (S3, OSS, MinIO) instead of, or in addition to, the hooks.
+type OutputSink struct {
+	// URL is the object URL prefix; output is stored at URL/stdout and URL/stderr.
+	URL string `json:"url"`
+	// Headers are sent with every storage request, e.g. a gateway token.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Tee also delivers output through OnExecuteStdout/OnExecuteStderr.
+	Tee bool `json:"tee,omitempty"`
+}
+
+// OutputSinkResult reports where a command's output was stored.
+type OutputSinkResult struct {
+	StdoutURL   string `json:"stdout_url"`
+	StderrURL   string `json:"stderr_url"`
+	StdoutBytes int64  `json:"stdout_bytes"`
+	StderrBytes int64  `json:"stderr_bytes"`
+}
+
+// commandOutputSink uploads both streams of one command while it runs.
+type commandOutputSink struct {
+	stdout, stderr *objectUpload
+	wg             sync.WaitGroup
+	pumpErrs       [2]error
+}
+
+// startOutputSink begins tailing the output files into object uploads until
+// done is closed, masking secrets with redactor when it is set. It returns
+// nil when the request has no sink.
+func startOutputSink(sink *OutputSink, redactor *outputRedactor, stdoutPath, stderrPath string, done <-chan struct{}) *commandOutputSink {
+	if sink == nil {
+		return nil
+	}
+	base := strings.TrimSuffix(sink.URL, "/")
+	s := &commandOutputSink{
+		stdout: &objectUpload{url: base + "/stdout", headers: sink.Headers},
+		stderr: &objectUpload{url: base + "/stderr", headers: sink.Headers},
+	}
+	pump := func(path string, upload *objectUpload) error {
+		w, flush := redactor.writer(upload)
+		if err := pumpFile(path, w, done); err != nil {
+			return err
+		}
+		return flush()
+	}
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+		s.pumpErrs[0] = pump(stdoutPath, s.stdout)
+	}()
+	go func() {
+		defer s.wg.Done()
+		s.pumpErrs[1] = pump(stderrPath, s.stderr)
+	}()
+	return s
+}
+
+// finish waits for the remaining output and completes both uploads.
+func (s *commandOutputSink) finish() (*OutputSinkResult, error) {
+	s.wg.Wait()
+	var errs []error
+	for i, upload := range []*objectUpload{s.stdout, s.stderr} {
+		err := s.pumpErrs[i]
+		if err == nil {
+			err = upload.Close()
+		} else if upload.err == nil {
+			// reading the output file failed; drop the partial upload
+			upload.fail(err)
+		}
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOutputSink, err)
+	}
+	return &OutputSinkResult{
+		StdoutURL:   s.stdout.url,
+		StderrURL:   s.stderr.url,
+		StdoutBytes: s.stdout.size,
+		StderrBytes: s.stderr.size,
+	}, nil
+}
+
+// finishOutputSink completes the uploads of sink, if any, and reports where
+// the output was stored, however the command ended. A failure is logged and
+// returned for the caller to report along with the command's own outcome.
+func finishOutputSink(request *ExecuteCodeRequest, sink *commandOutputSink) error {
+	if sink == nil {
+		return nil
+	}
+	result, err := sink.finish()
+	if err != nil {
+		log.Error("OutputSinkError: %v", err)
+		return err
+	}
+	request.Hooks.OnExecuteOutputSink(result)
+	return nil
+}
+
+// pumpFile copies everything appended to path into w until done is closed,
+// then copies what is left.
+func pumpFile(path string, w io.Writer, done <-chan struct{}) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	var f *os.File
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+	for {
+		final := false
+		select {
+		case <-done:
+			final = true
+		case <-ticker.C:
+		}
+		if f == nil {
+			var err error
+			if f, err = os.Open(path); err != nil && !final {
+				continue
+			} else if err != nil {
+				return err
+			}
+		}
+		if _, err := io.Copy(w, f); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+// objectUpload writes one object. Output that fits in a single part is sent
+// with a plain PUT; anything larger uses a multipart upload, started once the
+// first part is full, so memory stays bounded by the part size.
+type objectUpload struct {
+	url      string
+	headers  map[string]string
+	buf      bytes.Buffer
+	size     int64
+	uploadID string
+	parts    []completedPart
+	err      error
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (u *objectUpload) Write(p []byte) (int, error) {
+	if u.err != nil {
+		return 0, u.err
+	}
+	u.buf.Write(p)
+	u.size += int64(len(p))
+	for u.buf.Len() >= outputSinkPartSize {
+		if err := u.uploadPart(u.buf.Next(outputSinkPartSize)); err != nil {
+			u.fail(err)
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close uploads what is buffered and completes the object.
+func (u *objectUpload) Close() error {
+	if u.err != nil {
+		return u.err
+	}
+	if u.uploadID == "" {
+		_, _, err := u.do(http.MethodPut, "", u.buf.Bytes())
+		return err
+	}
+	if u.buf.Len() > 0 {
+		if err := u.uploadPart(u.buf.Bytes()); err != nil {
+			u.fail(err)
+			return err
+		}
+	}
+	body, _ := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: u.parts})
+	if _, _, err := u.do(http.MethodPost, "uploadId="+url.QueryEscape(u.uploadID), body); err != nil {
+		u.fail(err)
+		return err
+	}
+	return nil
+}
+
+func (u *objectUpload) uploadPart(part []byte) error {
+	if u.uploadID == "" {
+		resp, _, err := u.do(http.MethodPost, "uploads", nil)
+		if err != nil {
+			return err
+		}
+		var initiated struct {
+			UploadID string `xml:"UploadId"`
+		}
+		if err := xml.Unmarshal(resp, &initiated); err != nil || initiated.UploadID == "" {
+			return fmt.Errorf("initiate multipart upload for %s: no upload id in %q", u.url, resp)
+		}
+		u.uploadID = initiated.UploadID
+	}
+	number := len(u.parts) + 1
+	query := "partNumber=" + strconv.Itoa(number) + "&uploadId=" + url.QueryEscape(u.uploadID)
+	_, header, err := u.do(http.MethodPut, query, part)
+	if err != nil {
+		return err
+	}
+	u.parts = append(u.parts, completedPart{PartNumber: number, ETag: header.Get("ETag")})
+	return nil
+}
+
+// fail records err and aborts the multipart upload so the store does not keep
+// orphaned parts around.
+func (u *objectUpload) fail(err error) {
+	u.err = err
+	if u.uploadID != "" {
+		if _, _, abortErr := u.do(http.MethodDelete, "uploadId="+url.QueryEscape(u.uploadID), nil); abortErr != nil {
+			log.Warning("abort multipart upload of %s: %v", u.url, abortErr)
+		}
+	}
+}
+
+func (u *objectUpload) request(method, query string, body []byte) (*http.Request, error) {
+	target, err := url.Parse(u.url)
+	if err != nil {
+		return nil, err
+	}
+	if query != "" {
+		if target.RawQuery != "" {
+			query = target.RawQuery + "&" + query
+		}
+		target.RawQuery = query
+	}
+	req, err := http.NewRequest(method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range u.headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+func (u *objectUpload) do(method, query string, body []byte) ([]byte, http.Header, error) {
+	req, err := u.request(method, query, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := outputSinkClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, nil, fmt.Errorf("%s %s?%s: %s: %s", method, u.url, query, resp.Status, bytes.TrimSpace(data[:min(len(data), 512)]))
+	}
+	return data, resp.Header, nil
+}
@@ -0,0 +1,266 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	goruntime "runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+// stubObjectStore implements the S3 PUT and multipart upload calls execd uses.
+type stubObjectStore struct {
+	mu         sync.Mutex
+	objects    map[string]string
+	uploads    map[string]map[int]string
+	partCount  map[string]int
+	failParts  bool
+	aborted    int
+	authHeader string
+}
+
+func newStubObjectStore(t *testing.T) (*stubObjectStore, *httptest.Server) {
+	t.Helper()
+	s := &stubObjectStore{objects: map[string]string{}, uploads: map[string]map[int]string{}, partCount: map[string]int{}}
+	server := httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(server.Close)
+	return s, server
+}
+
+func (s *stubObjectStore) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authHeader = r.Header.Get("X-Sink-Token")
+	body, _ := io.ReadAll(r.Body)
+	key, q := r.URL.Path, r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		id := fmt.Sprintf("upload-%d", len(s.uploads)+1)
+		s.uploads[id] = map[int]string{}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", key, id)
+	case r.Method == http.MethodPut && q.Has("partNumber"):
+		if s.failParts {
+			http.Error(w, "SlowDown", http.StatusServiceUnavailable)
+			return
+		}
+		var n int
+		fmt.Sscan(q.Get("partNumber"), &n)
+		s.uploads[q.Get("uploadId")][n] = string(body)
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, n))
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		var done struct {
+			Parts []completedPart `xml:"Part"`
+		}
+		_ = xml.Unmarshal(body, &done)
+		var sb strings.Builder
+		for i, part := range done.Parts {
+			if part.PartNumber != i+1 || part.ETag != fmt.Sprintf(`"etag-%d"`, i+1) {
+				http.Error(w, "InvalidPart", http.StatusBadRequest)
+				return
+			}
+			sb.WriteString(s.uploads[q.Get("uploadId")][part.PartNumber])
+		}
+		s.objects[key] = sb.String()
+		s.partCount[key] = len(done.Parts)
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		s.aborted++
+	case r.Method == http.MethodPut:
+		s.objects[key] = string(body)
+		s.partCount[key] = 0
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func runSinkCommand(t *testing.T, code string, sink *OutputSink) (stdout []string, result *OutputSinkResult, execErr *execute.ErrorOutput, completed bool) {
+	t.Helper()
+	req := &ExecuteCodeRequest{
+		Language:   Command,
+		Code:       code,
+		Timeout:    10 * time.Second,
+		OutputSink: sink,
+		Hooks:      noopHooks(),
+	}
+	req.Hooks.OnExecuteStdout = func(s string) { stdout = append(stdout, s) }
+	req.Hooks.OnExecuteError = func(e *execute.ErrorOutput) { execErr = e }
+	req.Hooks.OnExecuteOutputSink = func(r *OutputSinkResult) { result = r }
+	req.Hooks.OnExecuteComplete = func(time.Duration) { completed = true }
+	if err := NewController("", "").Execute(req); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	return stdout, result, execErr, completed
+}
+
+func skipWithoutBash(t *testing.T) {
+	t.Helper()
+	if goruntime.GOOS == "windows" {
+		t.Skip("bash not available on windows")
+	}
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found in PATH")
+	}
+}
+
+func TestOutputSink_UploadsStreamsInParts(t *testing.T) {
+	skipWithoutBash(t)
+	old := outputSinkPartSize
+	outputSinkPartSize = 1024
+	t.Cleanup(func() { outputSinkPartSize = old })
+
+	store, server := newStubObjectStore(t)
+	stdout, result, execErr, completed := runSinkCommand(t, "seq 1 2000; echo oops >&2", &OutputSink{
+		URL:     server.URL + "/bucket/runs/42/",
+		Headers: map[string]string{"X-Sink-Token": "t0ken"},
+	})
+	if execErr != nil || !completed {
+		t.Fatalf("expected completion, got error %+v", execErr)
+	}
+	if len(stdout) != 0 {
+		t.Fatalf("expected no stdout events without tee, got %d", len(stdout))
+	}
+
+	var want strings.Builder
+	for i := 1; i <= 2000; i++ {
+		fmt.Fprintf(&want, "%d\n", i)
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if got := store.objects["/bucket/runs/42/stdout"]; got != want.String() {
+		t.Fatalf("stdout object mismatch: %d bytes, want %d", len(got), want.Len())
+	}
+	if store.partCount["/bucket/runs/42/stdout"] < 2 {
+		t.Fatalf("expected a multipart upload for large stdout, got %d parts", store.partCount["/bucket/runs/42/stdout"])
+	}
+	if store.objects["/bucket/runs/42/stderr"] != "oops\n" || store.partCount["/bucket/runs/42/stderr"] != 0 {
+		t.Fatalf("expected small stderr as a single PUT, got %q", store.objects["/bucket/runs/42/stderr"])
+	}
+	if store.authHeader != "t0ken" {
+		t.Fatalf("expected sink headers on storage requests")
+	}
+	if result == nil || result.StdoutURL != server.URL+"/bucket/runs/42/stdout" || result.StdoutBytes != int64(want.Len()) || result.StderrBytes != 5 {
+		t.Fatalf("unexpected sink result: %+v", result)
+	}
+}
+
+func TestOutputSink_TeeKeepsStreaming(t *testing.T) {
+	skipWithoutBash(t)
+	store, server := newStubObjectStore(t)
+	stdout, result, _, completed := runSinkCommand(t, "echo hello", &OutputSink{URL: server.URL + "/b/tee", Tee: true})
+	if !completed || result == nil {
+		t.Fatalf("expected completion with a sink result")
+	}
+	if strings.Join(stdout, ",") != "hello" {
+		t.Fatalf("expected stdout events with tee, got %v", stdout)
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.objects["/b/tee/stdout"] != "hello\n" {
+		t.Fatalf("unexpected stdout object %q", store.objects["/b/tee/stdout"])
+	}
+}
+
+func TestOutputSink_UploadFailureSurfacesError(t *testing.T) {
+	skipWithoutBash(t)
+	old := outputSinkPartSize
+	outputSinkPartSize = 1024
+	t.Cleanup(func() { outputSinkPartSize = old })
+
+	store, server := newStubObjectStore(t)
+	store.failParts = true
+	_, result, execErr, completed := runSinkCommand(t, "seq 1 2000", &OutputSink{URL: server.URL + "/b/fail"})
+	if completed || result != nil {
+		t.Fatalf("expected no completion after a failed upload")
+	}
+	if execErr == nil || execErr.EName != "OutputSinkError" || !strings.Contains(execErr.EValue, "503") {
+		t.Fatalf("expected OutputSinkError, got %+v", execErr)
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.aborted != 1 {
+		t.Fatalf("expected the multipart upload to be aborted, got %d aborts", store.aborted)
+	}
+}
+
+func TestOutputSink_RejectedForBackgroundCommands(t *testing.T) {
+	skipWithoutBash(t)
+	err := NewController("", "").Execute(&ExecuteCodeRequest{
+		Language:   BackgroundCommand,
+		Code:       "true",
+		OutputSink: &OutputSink{URL: "http://127.0.0.1:1/b"},
+		Hooks:      noopHooks(),
+	})
+	if !errors.Is(err, errOutputSinkBackground) {
+		t.Fatalf("expected errOutputSinkBackground, got %v", err)
+	}
+}
+
+func TestOutputSink_ReportedWhenCommandFails(t *testing.T) {
+	skipWithoutBash(t)
+	store, server := newStubObjectStore(t)
+	_, result, execErr, completed := runSinkCommand(t, "echo partial; echo broke >&2; exit 3", &OutputSink{URL: server.URL + "/b/failed"})
+	if completed || execErr == nil || execErr.EValue != "3" {
+		t.Fatalf("expected the exit code as an error, got %+v", execErr)
+	}
+	if result == nil || result.StderrURL != server.URL+"/b/failed/stderr" || result.StderrBytes != 6 {
+		t.Fatalf("expected the sink result of a failed command, got %+v", result)
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.objects["/b/failed/stdout"] != "partial\n" {
+		t.Fatalf("unexpected stdout object %q", store.objects["/b/failed/stdout"])
+	}
+}
+
+func TestOutputSink_FinishedWhenCommandFailsToStart(t *testing.T) {
+	skipWithoutBash(t)
+	store, server := newStubObjectStore(t)
+	req := &ExecuteCodeRequest{
+		Language:   Command,
+		Code:       "echo never",
+		Cwd:        "/nonexistent/execd-output-sink",
+		Timeout:    10 * time.Second,
+		OutputSink: &OutputSink{URL: server.URL + "/b/unstarted"},
+		Hooks:      noopHooks(),
+	}
+	var result *OutputSinkResult
+	var execErr *execute.ErrorOutput
+	req.Hooks.OnExecuteError = func(e *execute.ErrorOutput) { execErr = e }
+	req.Hooks.OnExecuteOutputSink = func(r *OutputSinkResult) { result = r }
+	if err := NewController("", "").Execute(req); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if execErr == nil || execErr.EName != "CommandExecError" {
+		t.Fatalf("expected a start failure, got %+v", execErr)
+	}
+	if result == nil || result.StdoutURL != server.URL+"/b/unstarted/stdout" {
+		t.Fatalf("expected the sink to be finished, got %+v", result)
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, ok := store.objects["/b/unstarted/stdout"]; !ok {
+		t.Fatalf("expected the stdout object to be completed")
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// outputThrottleBacklogSeconds is how far, in seconds of output at the
+	// limited rate, a log file may run ahead of delivery before the tailer
+	// skips ahead.
+	outputThrottleBacklogSeconds = 10
+	// minOutputThrottleBacklog keeps very low limits from dropping every line.
+	minOutputThrottleBacklog = 64 << 10
+)
+
+// outputThrottle paces the stdout and stderr events of one command to a
+// shared byte rate. Waiting blocks the tailers, so a chatty command's output
+// queues up in its log files rather than in the event transport.
+type outputThrottle struct {
+	rate float64 // bytes per second; also the burst size
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	sleep  func(time.Duration)
+	// dropped is set once any tailer skipped output.
+	dropped bool
+}
+
+// newOutputThrottle returns nil, i.e. no throttling, for a non-positive rate.
+func newOutputThrottle(bytesPerSecond int) *outputThrottle {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &outputThrottle{
+		rate:   float64(bytesPerSecond),
+		tokens: float64(bytesPerSecond),
+		last:   time.Now(),
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+// wait blocks until n more bytes fit the rate. Up to one second's worth of
+// output passes without waiting.
+func (t *outputThrottle) wait(n int) {
+	t.mu.Lock()
+	now := t.now()
+	t.tokens = min(t.rate, t.tokens+now.Sub(t.last).Seconds()*t.rate)
+	t.last = now
+	t.tokens -= float64(n)
+	var delay time.Duration
+	if t.tokens < 0 {
+		delay = time.Duration(-t.tokens / t.rate * float64(time.Second))
+	}
+	t.mu.Unlock()
+	if delay > 0 {
+		t.sleep(delay)
+	}
+}
+
+// truncated reports whether any output was dropped; false for a nil t.
+func (t *outputThrottle) truncated() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.dropped
+}
+
+// warnTruncated warns request when its output was cut by the rate limit.
+func (t *outputThrottle) warnTruncated(request *ExecuteCodeRequest) {
+	if t.truncated() {
+		request.Warn(WarningOutputTruncated, "output exceeded the rate limit of %d bytes/s and was partly dropped", request.OutputRateLimit)
+	}
+}
+
+// maxBacklog is the number of undelivered bytes a log file may hold.
+func (t *outputThrottle) maxBacklog() int64 {
+	return max(int64(t.rate)*outputThrottleBacklogSeconds, minOutputThrottleBacklog)
+}
+
+// tail returns the per-stream state for one tailer, nil when t is nil. With
+// verbatim, the tailer delivers lines with their terminators, and so is the
+// drop marker.
+func (t *outputThrottle) tail(verbatim bool) *throttledTail {
+	if t == nil {
+		return nil
+	}
+	return &throttledTail{throttle: t, verbatim: verbatim}
+}
+
+// throttledTail tracks what one tailer dropped across reads. All methods are
+// no-ops on a nil tail, which delivers every line as it is read.
+type throttledTail struct {
+	throttle *outputThrottle
+	// dropped counts bytes skipped since the last drop marker was delivered.
+	dropped int64
+	// inLine is set while skipping the rest of the line a skip landed in.
+	inLine bool
+	// verbatim ends the drop marker with a newline, like the lines around it.
+	verbatim bool
+}
+
+func (t *throttledTail) deliver(onExecute func(string), line string) {
+	if t == nil {
+		onExecute(line)
+		return
+	}
+	t.flushDropped(onExecute)
+	t.throttle.wait(t.size(line))
+	onExecute(line)
+}
+
+// flushDropped reports skipped output once, in place of the skipped lines.
+func (t *throttledTail) flushDropped(onExecute func(string)) {
+	if t == nil || t.dropped == 0 {
+		return
+	}
+	marker := fmt.Sprintf("[execd: output rate limit exceeded, %d bytes dropped]", t.dropped)
+	if t.verbatim {
+		marker += "\n"
+	}
+	t.dropped = 0
+	t.throttle.wait(t.size(marker))
+	onExecute(marker)
+}
+
+// size is the number of bytes text stands for in the output, counting the
+// terminator a line was delivered without.
+func (t *throttledTail) size(text string) int {
+	if t.verbatim {
+		return len(text)
+	}
+	return len(text) + 1
+}
+
+// skipBacklog is called with a complete line ending just before pos. When
+// file has grown more than the allowed backlog past pos, it drops that line
+// and everything up to the most recent half backlog, and returns the offset
+// reading resumes from with file seeked there.
+func (t *throttledTail) skipBacklog(file *os.File, pos int64, lineLen int) (int64, bool) {
+	if t == nil {
+		return pos, false
+	}
+	info, err := file.Stat()
+	if err != nil || info.Size()-pos <= t.throttle.maxBacklog() {
+		return pos, false
+	}
+	resume := info.Size() - t.throttle.maxBacklog()/2
+	if _, err := file.Seek(resume, io.SeekStart); err != nil {
+		return pos, false
+	}
+	t.dropped += int64(lineLen) + 1 + resume - pos
+	t.throttle.mu.Lock()
+	t.throttle.dropped = true
+	t.throttle.mu.Unlock()
+	// Resuming mid-line would deliver a fragment; drop up to the next terminator.
+	prev := make([]byte, 1)
+	if _, err := file.ReadAt(prev, resume-1); err == nil && prev[0] != '\n' && prev[0] != '\r' {
+		t.inLine = true
+	}
+	return resume, true
+}
+
+func (t *throttledTail) skipping() bool {
+	return t != nil && t.inLine
+}
+
+func (t *throttledTail) drop(n int) {
+	t.dropped += int64(n)
+}
+
+func (t *throttledTail) endSkip() {
+	t.inLine = false
+	t.dropped++
+}
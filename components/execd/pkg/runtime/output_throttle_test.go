@@ -0,0 +1,91 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputThrottle_BoundsDeliveryRate(t *testing.T) {
+	skipWithoutBash(t)
+	const rate = 40_000
+	const lines = 2000 // 41 bytes each with the newline: one second past the burst
+	var mu sync.Mutex
+	var delivered int
+	req := &ExecuteCodeRequest{
+		Language:        Command,
+		Code:            fmt.Sprintf("for i in $(seq 1 %d); do echo 0123456789012345678901234567890123456789; done", lines),
+		OutputRateLimit: rate,
+		Hooks:           noopHooks(),
+	}
+	req.Hooks.OnExecuteStdout = func(s string) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered += len(s) + 1
+	}
+
+	start := time.Now()
+	if err := NewController("", "").Execute(req); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	// Nothing is dropped below the backlog, and no more than one second's
+	// burst arrives ahead of the rate.
+	assert.Equal(t, lines*41, delivered)
+	assert.LessOrEqual(t, float64(delivered), rate*(elapsed.Seconds()+1))
+	assert.Greater(t, elapsed, time.Second)
+}
+
+func TestOutputThrottle_DropsBacklogWithMarker(t *testing.T) {
+	const rate = 5_000 // backlog limit is the 64 KiB minimum
+	line := strings.Repeat("x", 99)
+	file := filepath.Join(t.TempDir(), "stdout")
+	if err := os.WriteFile(file, []byte(strings.Repeat(line+"\n", 5000)), 0o644); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+
+	now := time.Unix(0, 0)
+	throttle := newOutputThrottle(rate)
+	throttle.last = now
+	throttle.now = func() time.Time { return now }
+	throttle.sleep = func(d time.Duration) { now = now.Add(d) }
+
+	var got []string
+	c := &Controller{}
+	c.readFromPosThrottled(&sync.Mutex{}, file, 0, func(s string) { got = append(got, s) }, true, throttle.tail(false))
+
+	if len(got) == 0 {
+		t.Fatal("expected output")
+	}
+	assert.Contains(t, got[0], "output rate limit exceeded")
+	assert.True(t, throttle.truncated())
+	var delivered int
+	for _, s := range got[1:] {
+		assert.Equal(t, line, s, "only whole lines follow the skip")
+		delivered += len(s) + 1
+	}
+	assert.InDelta(t, minOutputThrottleBacklog/2, delivered, 100)
+	// Paced at the rate after the first second's burst.
+	assert.InDelta(t, float64(delivered+len(got[0])+1-rate)/rate, now.Sub(time.Unix(0, 0)).Seconds(), 0.01)
+}
@@ -0,0 +1,143 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	goruntime "runtime"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+)
+
+var errPostCommandBackground = errors.New("post command is only supported for foreground commands")
+
+const (
+	// defaultPostCommandTimeout bounds a post command that sets no timeout of
+	// its own, so a hanging cleanup cannot hold the request forever.
+	defaultPostCommandTimeout = 30 * time.Second
+	// postCommandOutputBytes caps each captured stream of a post command.
+	postCommandOutputBytes = 64 << 10
+	// postCommandWaitDelay is how long a killed post command's children may
+	// keep its output pipes open before they are closed on them.
+	postCommandWaitDelay = time.Second
+)
+
+// PostCommand is a cleanup step run after a command ends, whether it
+// succeeded, failed, timed out or was interrupted.
+type PostCommand struct {
+	// Code is run by the same shell, in the same directory and environment
+	// as the main command.
+	Code string
+	// Timeout bounds the post command independently of the main command's;
+	// zero uses 30s.
+	Timeout time.Duration
+}
+
+// PostCommandResult is what a post command produced.
+type PostCommandResult struct {
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+	// ExitCode is the post command's exit code, -1 when it was killed or
+	// could not be started.
+	ExitCode int `json:"exit_code"`
+	// TimedOut is set when the post command was killed at its timeout.
+	TimedOut bool `json:"timed_out,omitempty"`
+	// Error describes why the post command could not be started.
+	Error string `json:"error,omitempty"`
+	// ExecutionTime is the post command's run time in milliseconds.
+	ExecutionTime int64 `json:"execution_time"`
+	// Truncated is set when a stream exceeded 64 KiB and was cut.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// cappedBuffer keeps the first limit bytes written to it and discards the rest.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); len(p) > room {
+		b.buf.Write(p[:max(room, 0)])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// runPostCommand runs request.PostCommand, if any, and reports its result
+// through OnExecutePostCommand. It is called once the main command is done,
+// with a context of its own so the main command's timeout or cancellation
+// does not cut the cleanup short.
+func (c *Controller) runPostCommand(request *ExecuteCodeRequest, main *ResolvedCommand) {
+	post := request.PostCommand
+	if post == nil || post.Code == "" {
+		return
+	}
+	timeout := post.Timeout
+	if timeout <= 0 {
+		timeout = defaultPostCommandTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cleanup := *request
+	cleanup.Code = post.Code
+	argv := resolveCommand(goruntime.GOOS, &cleanup, nil).Argv
+
+	var stdout, stderr cappedBuffer
+	stdout.limit = postCommandOutputBytes
+	stderr.limit = postCommandOutputBytes
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = main.Cwd
+	cmd.Env = main.env
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = postCommandWaitDelay
+
+	log.Info("running post command: %v", post.Code)
+	startAt := time.Now()
+	err := cmd.Run()
+	result := &PostCommandResult{
+		Stdout:        stdout.buf.String(),
+		Stderr:        stderr.buf.String(),
+		ExecutionTime: time.Since(startAt).Milliseconds(),
+		Truncated:     stdout.truncated || stderr.truncated,
+	}
+	var exitError *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitError):
+		result.ExitCode = exitError.ExitCode()
+	case cmd.ProcessState == nil:
+		result.ExitCode = -1
+		result.Error = err.Error()
+	default:
+		// the post command exited, but its children held the pipes past the wait delay
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	if ctx.Err() != nil {
+		result.TimedOut = true
+	}
+	if err != nil {
+		log.Warning("post command failed: %v", err)
+	}
+	request.Hooks.OnExecutePostCommand(result)
+}
@@ -0,0 +1,149 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+// postCommandRequest runs code with post as its cleanup and records the
+// order of the events that matter here.
+func postCommandRequest(code string, post *PostCommand) (*ExecuteCodeRequest, *[]string, **PostCommandResult) {
+	var events []string
+	var result *PostCommandResult
+	req := &ExecuteCodeRequest{
+		Language:    Command,
+		Code:        code,
+		PostCommand: post,
+		Hooks:       noopHooks(),
+	}
+	req.Hooks.OnExecuteStdout = func(s string) { events = append(events, "stdout:"+s) }
+	req.Hooks.OnExecutePostCommand = func(r *PostCommandResult) {
+		events = append(events, "post")
+		result = r
+	}
+	req.Hooks.OnExecuteError = func(err *execute.ErrorOutput) { events = append(events, "error:"+err.EName) }
+	req.Hooks.OnExecuteComplete = func(time.Duration) { events = append(events, "complete") }
+	return req, &events, &result
+}
+
+func TestRunCommand_PostCommandAfterSuccess(t *testing.T) {
+	skipWithoutBash(t)
+	dir := t.TempDir()
+	req, events, result := postCommandRequest("echo main > work.tmp; echo done", &PostCommand{
+		Code: "cat work.tmp; rm work.tmp; echo oops >&2; exit 3",
+	})
+	req.Cwd = dir
+
+	require.NoError(t, NewController("", "").Execute(req))
+
+	assert.Equal(t, []string{"stdout:done", "post", "complete"}, *events)
+	require.NotNil(t, *result)
+	assert.Equal(t, "main\n", (*result).Stdout)
+	assert.Equal(t, "oops\n", (*result).Stderr)
+	assert.Equal(t, 3, (*result).ExitCode, "a failing cleanup does not fail the command")
+	assert.NoFileExists(t, filepath.Join(dir, "work.tmp"))
+}
+
+func TestRunCommand_PostCommandAfterTimeout(t *testing.T) {
+	skipWithoutBash(t)
+	marker := filepath.Join(t.TempDir(), "cleaned")
+	req, events, result := postCommandRequest("echo started; sleep 30", &PostCommand{
+		Code: "sleep 0.2; touch " + marker,
+	})
+	req.Timeout = 300 * time.Millisecond
+
+	start := time.Now()
+	require.NoError(t, NewController("", "").Execute(req))
+	assert.Less(t, time.Since(start), 10*time.Second)
+
+	assert.Equal(t, []string{"stdout:started", "post", "error:CommandExecError"}, *events)
+	require.NotNil(t, *result)
+	assert.Zero(t, (*result).ExitCode, "the main command's timeout does not cut the cleanup short")
+	assert.False(t, (*result).TimedOut)
+	assert.FileExists(t, marker)
+}
+
+func TestRunCommand_PostCommandAfterInterrupt(t *testing.T) {
+	skipWithoutBash(t)
+	marker := filepath.Join(t.TempDir(), "cleaned")
+	c := NewController("", "")
+	req, events, result := postCommandRequest("sleep 30", &PostCommand{Code: "touch " + marker})
+	sessions := make(chan string, 1)
+	req.Hooks.OnExecuteInit = func(session string) { sessions <- session }
+
+	done := make(chan error, 1)
+	go func() { done <- c.Execute(req) }()
+	require.NoError(t, c.Interrupt(<-sessions))
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("command did not finish after interrupt")
+	}
+
+	assert.Equal(t, []string{"post", "error:CommandExecError"}, *events)
+	require.NotNil(t, *result)
+	assert.FileExists(t, marker)
+}
+
+func TestRunCommand_PostCommandTimeout(t *testing.T) {
+	skipWithoutBash(t)
+	req, events, result := postCommandRequest("true", &PostCommand{
+		Code:    "echo cleaning; sleep 30",
+		Timeout: 200 * time.Millisecond,
+	})
+
+	start := time.Now()
+	require.NoError(t, NewController("", "").Execute(req))
+	assert.Less(t, time.Since(start), 10*time.Second)
+
+	assert.Equal(t, []string{"post", "complete"}, *events)
+	require.NotNil(t, *result)
+	assert.True(t, (*result).TimedOut)
+	assert.Equal(t, -1, (*result).ExitCode)
+	assert.Equal(t, "cleaning\n", (*result).Stdout)
+}
+
+func TestRunCommand_PostCommandRejectedForBackground(t *testing.T) {
+	req := &ExecuteCodeRequest{
+		Language:    BackgroundCommand,
+		Code:        "true",
+		PostCommand: &PostCommand{Code: "true"},
+		Hooks:       noopHooks(),
+	}
+	err := NewController("", "").Execute(req)
+	assert.True(t, errors.Is(err, errPostCommandBackground), "got %v", err)
+}
+
+func TestCappedBuffer(t *testing.T) {
+	b := &cappedBuffer{limit: 4}
+	n, err := b.Write([]byte("abc"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	n, err = b.Write([]byte("def"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n, "the excess is dropped without failing the writer")
+	assert.Equal(t, "abcd", b.buf.String())
+	assert.True(t, b.truncated)
+}
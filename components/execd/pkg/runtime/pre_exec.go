@@ -0,0 +1,58 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+)
+
+// PreExecHook prepares a command right before it is launched, e.g. vends
+// short-lived credentials into request.Envs, which is never nil, or sets up
+// a mount and points request.Cwd at it. ctx ends with the execution's
+// timeout or cancellation. Returning an error aborts the execution: the
+// command is not started and the error is reported as a PreExecHookError.
+type PreExecHook func(ctx context.Context, request *ExecuteCodeRequest) error
+
+// AddPreExecHook registers hook to run before every command, foreground or
+// background, after the hooks added before it. Dry runs start nothing and
+// skip the hooks. Hooks are part of the Controller's setup: add them before
+// it executes anything.
+func (c *Controller) AddPreExecHook(hook PreExecHook) {
+	c.preExecHooks = append(c.preExecHooks, hook)
+}
+
+// runPreExecHooks runs the pre-exec hooks on request and reports whether the
+// command may start; when it may not, the error has been reported.
+func (c *Controller) runPreExecHooks(ctx context.Context, request *ExecuteCodeRequest) bool {
+	if len(c.preExecHooks) == 0 || request.DryRun {
+		return true
+	}
+	if request.Envs == nil {
+		request.Envs = map[string]string{}
+	}
+	for _, hook := range c.preExecHooks {
+		if err := hook(ctx, request); err != nil {
+			log.Warning("pre-exec hook aborted the command: %v", err)
+			request.Hooks.OnExecuteError(&execute.ErrorOutput{EName: "PreExecHookError", EValue: err.Error()})
+			return false
+		}
+	}
+	// hooks may have vended secrets
+	request.redactor.addEnv(request.Envs)
+	return true
+}
@@ -0,0 +1,102 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+func TestExecute_PreExecHookInjectsEnv(t *testing.T) {
+	skipWithoutBash(t)
+	dir := t.TempDir()
+	c := NewController("", "")
+	var seen context.Context
+	c.AddPreExecHook(func(ctx context.Context, request *ExecuteCodeRequest) error {
+		seen = ctx
+		request.Envs["VENDED_TOKEN"] = "s3cr3t-" + request.Envs["TENANT"]
+		request.Cwd = dir
+		return nil
+	})
+	c.AddPreExecHook(func(_ context.Context, request *ExecuteCodeRequest) error {
+		// later hooks see what earlier ones set up
+		request.Envs["TOKEN_LEN"] = string(rune('0' + len(request.Envs["VENDED_TOKEN"])))
+		return nil
+	})
+
+	var stdout []string
+	req := &ExecuteCodeRequest{
+		Language: Command,
+		Code:     `echo "$VENDED_TOKEN $TOKEN_LEN $(pwd)"`,
+		Envs:     map[string]string{"TENANT": "a"},
+		Timeout:  10 * time.Second,
+		Hooks:    noopHooks(),
+	}
+	req.Hooks.OnExecuteStdout = func(s string) { stdout = append(stdout, s) }
+	req.Hooks.OnExecuteError = func(err *execute.ErrorOutput) { t.Errorf("unexpected error %s: %s", err.EName, err.EValue) }
+	require.NoError(t, c.Execute(req))
+
+	resolved, err := filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"s3cr3t-a 8 " + resolved}, stdout)
+	require.NotNil(t, seen)
+	_, hasDeadline := seen.Deadline()
+	assert.True(t, hasDeadline, "hooks get the execution's context")
+}
+
+func TestExecute_PreExecHookAbortsCommand(t *testing.T) {
+	skipWithoutBash(t)
+	marker := filepath.Join(t.TempDir(), "launched")
+	c := NewController("", "")
+	c.AddPreExecHook(func(context.Context, *ExecuteCodeRequest) error {
+		return errors.New("credential service unavailable")
+	})
+	second := false
+	c.AddPreExecHook(func(context.Context, *ExecuteCodeRequest) error {
+		second = true
+		return nil
+	})
+
+	for _, language := range []Language{Command, BackgroundCommand} {
+		var events []string
+		var failure *execute.ErrorOutput
+		req := &ExecuteCodeRequest{Language: language, Code: "touch " + marker, Hooks: noopHooks()}
+		req.Hooks.OnExecuteInit = func(string) { events = append(events, "init") }
+		req.Hooks.OnExecuteComplete = func(time.Duration) { events = append(events, "complete") }
+		req.Hooks.OnExecuteError = func(err *execute.ErrorOutput) {
+			events = append(events, "error")
+			failure = err
+		}
+		require.NoError(t, c.Execute(req))
+
+		assert.Equal(t, []string{"error"}, events, language)
+		require.NotNil(t, failure)
+		assert.Equal(t, "PreExecHookError", failure.EName)
+		assert.Equal(t, "credential service unavailable", failure.EValue)
+	}
+	time.Sleep(100 * time.Millisecond)
+	_, err := os.Stat(marker)
+	assert.True(t, os.IsNotExist(err), "the command must not be launched")
+	assert.False(t, second, "hooks after a failing one are skipped")
+}
@@ -0,0 +1,73 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// I/O scheduling classes, as understood by ioprio_set(2).
+const (
+	ioClassRealtime   = 1
+	ioClassBestEffort = 2
+	ioClassIdle       = 3
+)
+
+// parseIOPriority parses "class[:level]", where class is realtime,
+// best-effort or idle and level is 0 (highest) to 7. The level defaults to 4
+// and is not accepted for idle. An empty string inherits execd's priority
+// and yields class 0.
+func parseIOPriority(value string) (class, level int, err error) {
+	if value == "" {
+		return 0, 0, nil
+	}
+	name, rawLevel, hasLevel := strings.Cut(strings.ToLower(strings.TrimSpace(value)), ":")
+	switch name {
+	case "realtime", "rt":
+		class = ioClassRealtime
+	case "best-effort", "be":
+		class = ioClassBestEffort
+	case "idle":
+		class = ioClassIdle
+	default:
+		return 0, 0, fmt.Errorf("%w: unknown io priority class %q", ErrPriority, name)
+	}
+	level = 4
+	if hasLevel {
+		if class == ioClassIdle {
+			return 0, 0, fmt.Errorf("%w: the idle io priority class takes no level", ErrPriority)
+		}
+		level, err = strconv.Atoi(rawLevel)
+		if err != nil || level < 0 || level > 7 {
+			return 0, 0, fmt.Errorf("%w: io priority level %q must be between 0 and 7", ErrPriority, rawLevel)
+		}
+	} else if class == ioClassIdle {
+		level = 0
+	}
+	return class, level, nil
+}
+
+// CheckPriority validates a command's nice value and I/O priority so callers
+// can reject the request before streaming starts. Whether they can be applied
+// is only known once the process exists.
+func (c *Controller) CheckPriority(nice int, ioPriority string) error {
+	if nice < -20 || nice > 19 {
+		return fmt.Errorf("%w: nice %d must be between -20 and 19", ErrPriority, nice)
+	}
+	_, _, err := parseIOPriority(ioPriority)
+	return err
+}
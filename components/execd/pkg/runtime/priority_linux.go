@@ -0,0 +1,47 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package runtime
+
+import "syscall"
+
+const (
+	ioprioWhoProcess = 1
+	ioprioWhoPgrp    = 2
+	ioprioClassShift = 13
+)
+
+// applyPriority lowers (or, with CAP_SYS_NICE / CAP_SYS_ADMIN, raises) the
+// CPU and I/O priority of the process group led by pid. It runs right after
+// the command starts; targeting the group also covers any child forked
+// before then. Failures are warned about and the command keeps running with
+// the inherited priority.
+func applyPriority(pid int, request *ExecuteCodeRequest) {
+	if request.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PGRP, pid, request.Nice); err != nil {
+			request.Warn(WarningPriorityIgnored, "failed to set nice %d for command pid %d, keeping inherited priority: %v", request.Nice, pid, err)
+		}
+	}
+	class, level, err := parseIOPriority(request.IOPriority)
+	if err != nil || class == 0 {
+		return
+	}
+	prio := class<<ioprioClassShift | level
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoPgrp, uintptr(pid), uintptr(prio)); errno != 0 {
+		request.Warn(WarningPriorityIgnored, "failed to set io priority %q for command pid %d, keeping inherited priority: %v", request.IOPriority, pid, errno)
+	}
+}
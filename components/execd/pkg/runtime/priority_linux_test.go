@@ -0,0 +1,82 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package runtime
+
+import (
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExecute_NiceApplied(t *testing.T) {
+	skipWithoutBash(t)
+	current, err := syscall.Getpriority(syscall.PRIO_PROCESS, 0)
+	if err != nil {
+		t.Fatalf("getpriority: %v", err)
+	}
+	// the raw syscall returns 20 - nice
+	if 20-current > 10 {
+		t.Skip("execd already runs above nice 10")
+	}
+
+	var stdout []string
+	req := &ExecuteCodeRequest{
+		Language: Command,
+		// field 19 of /proc/<pid>/stat is the nice value
+		Code:    "sleep 0.2; read -a stat < /proc/$$/stat; echo ${stat[18]}",
+		Timeout: 5 * time.Second,
+		Nice:    10,
+		Hooks:   noopHooks(),
+	}
+	req.Hooks.OnExecuteStdout = func(s string) { stdout = append(stdout, s) }
+	if err := NewController("", "").Execute(req); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if strings.Join(stdout, ",") != "10" {
+		t.Fatalf("expected nice 10, got %v", stdout)
+	}
+}
+
+func TestApplyPriority_IOPriority(t *testing.T) {
+	cmd := exec.Command("sleep", "5")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("sleep not available: %v", err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	applyPriority(cmd.Process.Pid, &ExecuteCodeRequest{IOPriority: "best-effort:6"})
+	prio, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(cmd.Process.Pid), 0)
+	if errno != 0 {
+		t.Skipf("ioprio_get unavailable: %v", errno)
+	}
+	if want := uintptr(ioClassBestEffort<<ioprioClassShift | 6); prio != want {
+		t.Fatalf("expected io priority %#x, got %#x", want, prio)
+	}
+
+	// A negative nice needs CAP_SYS_NICE; without it the process keeps running.
+	applyPriority(cmd.Process.Pid, &ExecuteCodeRequest{Nice: -5})
+	if err := cmd.Process.Signal(syscall.Signal(0)); err != nil {
+		t.Fatalf("expected the process to survive, got %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+// +build !linux
+
+package runtime
+
+// applyPriority is a no-op outside Linux; the command keeps execd's priority.
+func applyPriority(pid int, request *ExecuteCodeRequest) {
+	if request.Nice != 0 || request.IOPriority != "" {
+		request.Warn(WarningPriorityIgnored, "nice and io priority are only supported on linux, ignoring them for command pid %d", pid)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseIOPriority(t *testing.T) {
+	cases := []struct {
+		value        string
+		class, level int
+		wantErr      bool
+	}{
+		{value: "", class: 0, level: 0},
+		{value: "idle", class: ioClassIdle, level: 0},
+		{value: "best-effort", class: ioClassBestEffort, level: 4},
+		{value: "BE:7", class: ioClassBestEffort, level: 7},
+		{value: "realtime:0", class: ioClassRealtime, level: 0},
+		{value: "idle:3", wantErr: true},
+		{value: "best-effort:8", wantErr: true},
+		{value: "best-effort:x", wantErr: true},
+		{value: "low", wantErr: true},
+	}
+	for _, c := range cases {
+		class, level, err := parseIOPriority(c.value)
+		if c.wantErr {
+			if !errors.Is(err, ErrPriority) {
+				t.Fatalf("parseIOPriority(%q): expected ErrPriority, got %v", c.value, err)
+			}
+			continue
+		}
+		if err != nil || class != c.class || level != c.level {
+			t.Fatalf("parseIOPriority(%q) = %d, %d, %v; want %d, %d", c.value, class, level, err, c.class, c.level)
+		}
+	}
+}
+
+func TestCheckPriority_NiceRange(t *testing.T) {
+	c := NewController("", "")
+	if err := c.CheckPriority(19, "idle"); err != nil {
+		t.Fatalf("expected nice 19 to be accepted, got %v", err)
+	}
+	if err := c.CheckPriority(-21, ""); !errors.Is(err, ErrPriority) {
+		t.Fatalf("expected ErrPriority for nice -21, got %v", err)
+	}
+}
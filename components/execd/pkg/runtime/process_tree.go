@@ -0,0 +1,55 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"strings"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+	"github.com/alibaba/opensandbox/execd/pkg/util/safego"
+)
+
+// processTreePollInterval is how often a process tree a command left behind
+// is checked for having emptied, so it can be released.
+const processTreePollInterval = time.Second
+
+// SetCommandCgroup sets the cgroup v2 directory under which every command
+// gets a cgroup of its own, so daemons it double-forks stay tracked and are
+// killed when it is stopped. Empty disables it. It only applies on linux;
+// on windows commands always run in a job object.
+func (c *Controller) SetCommandCgroup(dir string) {
+	c.commandCgroup = strings.TrimSpace(dir)
+}
+
+// settleProcessTree releases tree once the command that owns it has exited.
+// Processes that outlived the command, such as a daemon it started, keep the
+// tree until they exit; until then they can be stopped through session.
+func (c *Controller) settleProcessTree(session string, tree *processTree) {
+	if tree == nil {
+		return
+	}
+	if !tree.populated() {
+		tree.release()
+		return
+	}
+	log.Warning("command %s exited but left processes running; stopping the session kills them", session)
+	safego.Go(func() {
+		for tree.populated() {
+			time.Sleep(processTreePollInterval)
+		}
+		tree.release()
+	})
+}
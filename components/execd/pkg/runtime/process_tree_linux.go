@@ -0,0 +1,158 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package runtime
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+)
+
+// processTree is the cgroup one command runs in. The command is cloned
+// straight into it, and cgroup membership survives setsid and double forks,
+// so it holds everything the command started.
+type processTree struct {
+	dir string
+	fd  int // open until the command has started
+}
+
+// newProcessTree creates the command's cgroup under the configured parent.
+// Without one, or when it cannot be created, the command runs untracked and
+// only its process group can be stopped.
+func (c *Controller) newProcessTree(session string) *processTree {
+	if c.commandCgroup == "" {
+		return nil
+	}
+	dir := filepath.Join(c.commandCgroup, "execd-"+session)
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		log.Warning("failed to create cgroup for command %s, its daemons will not be tracked: %v", session, err)
+		return nil
+	}
+	fd, err := unix.Open(dir, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		_ = os.Remove(dir)
+		log.Warning("failed to open cgroup for command %s, its daemons will not be tracked: %v", session, err)
+		return nil
+	}
+	return &processTree{dir: dir, fd: fd}
+}
+
+// attach makes cmd start inside the cgroup (CLONE_INTO_CGROUP, linux 5.7+),
+// so not even its first fork can escape.
+func (t *processTree) attach(cmd *exec.Cmd) {
+	if t == nil {
+		return
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = t.fd
+}
+
+// started is called once cmd has been started, or failed to.
+func (t *processTree) started(*exec.Cmd) {
+	if t == nil || t.fd < 0 {
+		return
+	}
+	_ = unix.Close(t.fd)
+	t.fd = -1
+}
+
+// populated reports whether any process is left in the cgroup.
+func (t *processTree) populated() bool {
+	if t == nil {
+		return false
+	}
+	events, err := os.ReadFile(filepath.Join(t.dir, "cgroup.events"))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(events), "\n") {
+		if line == "populated 1" {
+			return true
+		}
+	}
+	return false
+}
+
+// terminate sends SIGTERM to every process in the cgroup.
+func (t *processTree) terminate() error {
+	return t.signal(syscall.SIGTERM)
+}
+
+// kill kills every process in the cgroup at once through cgroup.kill
+// (linux 5.14+), or one by one on older kernels.
+func (t *processTree) kill() error {
+	if t == nil {
+		return nil
+	}
+	err := os.WriteFile(filepath.Join(t.dir, "cgroup.kill"), []byte("1"), 0)
+	if err == nil || (errors.Is(err, os.ErrNotExist) && !dirExists(t.dir)) {
+		return nil
+	}
+	return t.signal(syscall.SIGKILL)
+}
+
+// signal sends sig to every process listed in cgroup.procs. A process that
+// forks meanwhile may be missed, which kill's cgroup.kill does not suffer.
+func (t *processTree) signal(sig syscall.Signal) error {
+	if t == nil {
+		return nil
+	}
+	procs, err := os.ReadFile(filepath.Join(t.dir, "cgroup.procs"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, field := range bytes.Fields(procs) {
+		pid, err := strconv.Atoi(string(field))
+		if err != nil {
+			continue
+		}
+		if err := syscall.Kill(pid, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// release removes the cgroup, which only succeeds once it is empty.
+func (t *processTree) release() {
+	if t == nil {
+		return
+	}
+	t.started(nil)
+	if err := os.Remove(t.dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warning("failed to remove cgroup %s: %v", t.dir, err)
+	}
+}
+
+func dirExists(dir string) bool {
+	_, err := os.Stat(dir)
+	return err == nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package runtime
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writableCgroup returns a fresh directory in a cgroup v2 hierarchy the test
+// may create cgroups in, skipping the test when there is none.
+func writableCgroup(t *testing.T) string {
+	t.Helper()
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		t.Skipf("no mountinfo: %v", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		mount, fstype, ok := strings.Cut(scanner.Text(), " - ")
+		fields := strings.Fields(mount)
+		if !ok || len(fields) < 5 || !strings.HasPrefix(fstype, "cgroup2 ") {
+			continue
+		}
+		dir, err := os.MkdirTemp(unescapeMountPath(fields[4]), "execd-test-")
+		if err != nil {
+			continue
+		}
+		t.Cleanup(func() { _ = os.Remove(dir) })
+		return dir
+	}
+	t.Skip("no writable cgroup v2 hierarchy")
+	return ""
+}
+
+func TestStopSessions_KillsDoubleForkedDaemon(t *testing.T) {
+	skipWithoutBash(t)
+	parent := writableCgroup(t)
+	c := NewController("", "")
+	c.SetCommandCgroup(parent)
+
+	// the daemon leaves the command's process group and ignores SIGTERM
+	var session string
+	req := &ExecuteCodeRequest{
+		Language: BackgroundCommand,
+		Code:     `(setsid sh -c "trap '' TERM; sleep 300" >/dev/null 2>&1 </dev/null &)`,
+		Hooks:    noopHooks(),
+	}
+	req.Hooks.OnExecuteInit = func(s string) { session = s }
+	require.NoError(t, c.runBackgroundCommand(context.Background(), req))
+	require.Eventually(t, func() bool {
+		status, err := c.GetCommandStatus(session)
+		return err == nil && !status.Running
+	}, 5*time.Second, 10*time.Millisecond, "the command itself exits right away")
+
+	tree := c.commandSnapshot(session).tree
+	require.NotNil(t, tree)
+	assert.Equal(t, filepath.Join(parent, "execd-"+session), tree.dir)
+	require.True(t, tree.populated(), "the daemon outlives the command in its cgroup")
+
+	outcomes := c.StopSessions([]string{session}, 200*time.Millisecond)
+	assert.Equal(t, []StopOutcome{{Session: session, Result: StopKilled}}, outcomes)
+	assert.Eventually(t, func() bool { return !tree.populated() }, 5*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(tree.dir)
+		return os.IsNotExist(err)
+	}, 5*time.Second, 50*time.Millisecond, "the emptied cgroup is removed")
+	assert.Equal(t, []StopOutcome{{Session: session, Result: StopFinished}}, c.StopSessions([]string{session}, time.Second))
+}
+
+func TestRunCommand_ReleasesCgroupOfCommandWithoutDaemons(t *testing.T) {
+	skipWithoutBash(t)
+	parent := writableCgroup(t)
+	c := NewController("", "")
+	c.SetCommandCgroup(parent)
+
+	var session string
+	var cgroup []string
+	req := &ExecuteCodeRequest{Language: Command, Code: "grep '^0::' /proc/self/cgroup", Hooks: noopHooks()}
+	req.Hooks.OnExecuteInit = func(s string) { session = s }
+	req.Hooks.OnExecuteStdout = func(s string) { cgroup = append(cgroup, s) }
+	require.NoError(t, c.runCommand(context.Background(), req))
+
+	require.Len(t, cgroup, 1)
+	assert.True(t, strings.HasSuffix(cgroup[0], "/"+filepath.Base(parent)+"/execd-"+session), "the command runs in a cgroup of its own, got %s", cgroup[0])
+	entries, err := os.ReadDir(parent)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.False(t, entry.IsDir() && strings.HasPrefix(entry.Name(), "execd-"), "cgroup %s was left behind", entry.Name())
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package runtime
+
+import "os/exec"
+
+// processTree is never created here: without cgroups or job objects, only a
+// command's process group is tracked, and a daemon that double-forks out of
+// it cannot be stopped through execd.
+type processTree struct{}
+
+func (c *Controller) newProcessTree(string) *processTree { return nil }
+
+func (t *processTree) attach(*exec.Cmd) {}
+
+func (t *processTree) started(*exec.Cmd) {}
+
+func (t *processTree) populated() bool { return false }
+
+func (t *processTree) terminate() error { return nil }
+
+func (t *processTree) kill() error { return nil }
+
+func (t *processTree) release() {}
@@ -0,0 +1,127 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+// +build windows
+
+package runtime
+
+import (
+	"os/exec"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+)
+
+// processTree is the job object one command runs in. Processes a job member
+// creates join the job too, however they detach from their parent.
+type processTree struct {
+	// mu keeps the handle from being closed, and perhaps reused, while in use.
+	mu     sync.Mutex
+	job    windows.Handle
+	closed bool
+}
+
+// jobObjectBasicAccountingInformation is JOBOBJECT_BASIC_ACCOUNTING_INFORMATION.
+type jobObjectBasicAccountingInformation struct {
+	TotalUserTime             int64
+	TotalKernelTime           int64
+	ThisPeriodTotalUserTime   int64
+	ThisPeriodTotalKernelTime int64
+	TotalPageFaultCount       uint32
+	TotalProcesses            uint32
+	ActiveProcesses           uint32
+	TotalTerminatedProcesses  uint32
+}
+
+// newProcessTree creates the command's job object. When that fails the
+// command runs untracked and only its own process can be stopped.
+func (c *Controller) newProcessTree(session string) *processTree {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		log.Warning("failed to create job object for command %s, its children will not be tracked: %v", session, err)
+		return nil
+	}
+	return &processTree{job: job}
+}
+
+// attach does nothing: a process can only join a job once it exists.
+func (t *processTree) attach(*exec.Cmd) {}
+
+// started assigns cmd's process to the job. Children it creates before that
+// are not in the job, so a command that detaches a child right away can
+// still escape it.
+func (t *processTree) started(cmd *exec.Cmd) {
+	if t == nil || cmd == nil || cmd.Process == nil {
+		return
+	}
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(cmd.Process.Pid))
+	if err == nil {
+		err = windows.AssignProcessToJobObject(t.job, process)
+		_ = windows.CloseHandle(process)
+	}
+	if err != nil {
+		log.Warning("failed to assign process %d to its job object: %v", cmd.Process.Pid, err)
+	}
+}
+
+// populated reports whether any process is left in the job.
+func (t *processTree) populated() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return false
+	}
+	var info jobObjectBasicAccountingInformation
+	err := windows.QueryInformationJobObject(t.job, windows.JobObjectBasicAccountingInformation,
+		uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)), nil)
+	return err == nil && info.ActiveProcesses > 0
+}
+
+// terminate kills the job; Windows has no SIGTERM to send first.
+func (t *processTree) terminate() error {
+	return t.kill()
+}
+
+// kill terminates every process in the job.
+func (t *processTree) kill() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil
+	}
+	return windows.TerminateJobObject(t.job, 1)
+}
+
+// release closes the job handle; processes still in the job keep running.
+func (t *processTree) release() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.closed {
+		_ = windows.CloseHandle(t.job)
+		t.closed = true
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+)
+
+// CheckReadOnlyFS reports whether commands can be confined to a read-only
+// filesystem here, so callers can reject the request before streaming starts.
+func (c *Controller) CheckReadOnlyFS(enabled bool) error {
+	return checkReadOnlyFS(enabled)
+}
+
+// readOnlyFS confines one command to a private mount namespace in which every
+// mount is read-only except dir.
+type readOnlyFS struct {
+	dir string
+	// owned is set when dir was created for the command rather than being
+	// its scratch space, and is removed with it.
+	owned bool
+}
+
+// prepareReadOnlyFS picks the writable directory of a command run with
+// ReadOnlyFS: its scratch tmpfs if it has one, else a fresh directory under
+// the temp dir. Like scratch space, it becomes TMPDIR and the default Cwd.
+func (c *Controller) prepareReadOnlyFS(session string, request *ExecuteCodeRequest, resolved *ResolvedCommand, scratch *scratchSpace) (*readOnlyFS, error) {
+	if !request.ReadOnlyFS {
+		return nil, nil
+	}
+	if scratch != nil {
+		return &readOnlyFS{dir: scratch.dir}, nil
+	}
+
+	dir := filepath.Join(os.TempDir(), "execd-writable-"+session)
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create writable dir: %w", err)
+	}
+	resolved.env = mergeEnvs(resolved.env, map[string]string{"TMPDIR": dir})
+	if resolved.Cwd == "" {
+		resolved.Cwd = dir
+	}
+	return &readOnlyFS{dir: dir, owned: true}, nil
+}
+
+// start starts cmd, inside the read-only mount namespace if there is one.
+func (r *readOnlyFS) start(cmd *exec.Cmd) error {
+	if r == nil {
+		return cmd.Start()
+	}
+	return startReadOnly(cmd, r.dir)
+}
+
+// release removes the writable directory if it was created for the command.
+func (r *readOnlyFS) release() {
+	if r == nil || !r.owned {
+		return
+	}
+	if err := os.RemoveAll(r.dir); err != nil {
+		log.Error("failed to remove writable dir %s: %v", r.dir, err)
+	}
+}
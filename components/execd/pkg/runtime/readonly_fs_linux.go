@@ -0,0 +1,185 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package runtime
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	goruntime "runtime"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// checkReadOnlyFS creates and discards a mount namespace. That needs
+// CAP_SYS_ADMIN and a seccomp profile that allows unshare(2), which most
+// unprivileged containers lack.
+func checkReadOnlyFS(enabled bool) error {
+	if !enabled {
+		return nil
+	}
+	return inMountNamespace(func() error { return nil })
+}
+
+// startReadOnly starts cmd in a new mount namespace where every mount is
+// read-only except writable. The command starts without CAP_SYS_ADMIN, so it
+// cannot remount anything writable again.
+func startReadOnly(cmd *exec.Cmd, writable string) error {
+	return inMountNamespace(func() error {
+		// a bind mount copies the flags of its source, so it is made before
+		// anything is read-only
+		if err := unix.Mount(writable, writable, "", unix.MS_BIND, ""); err != nil {
+			return fmt.Errorf("%w: bind %s: %v", ErrReadOnlyFS, writable, err)
+		}
+		if err := remountReadOnly(writable); err != nil {
+			return err
+		}
+		if err := dropMountCapability(); err != nil {
+			return err
+		}
+		return cmd.Start()
+	})
+}
+
+// inMountNamespace runs fn on an OS thread of its own that has been moved to
+// a private copy of execd's mount namespace. Namespaces and capabilities
+// belong to the thread, so processes fn starts inherit them while the rest of
+// execd is unaffected. The thread stays locked and exits with its goroutine
+// rather than going back to the scheduler.
+func inMountNamespace(fn func() error) error {
+	errCh := make(chan error, 1)
+	go func() {
+		goruntime.LockOSThread()
+		if err := unix.Unshare(unix.CLONE_NEWNS); err != nil {
+			errCh <- fmt.Errorf("%w: mount namespaces are not available: %v", ErrReadOnlyFS, err)
+			return
+		}
+		// keep the mounts made here from propagating to execd's namespace
+		if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+			errCh <- fmt.Errorf("%w: make mounts private: %v", ErrReadOnlyFS, err)
+			return
+		}
+		errCh <- fn()
+	}()
+	return <-errCh
+}
+
+// remountReadOnly marks every mount of the calling thread's namespace
+// read-only, except those on writable. A bind remount replaces the per-mount
+// flags, so the ones each mount already had are passed along.
+func remountReadOnly(writable string) error {
+	// /proc/self is the main thread, which is still in execd's namespace
+	f, err := os.Open("/proc/thread-self/mountinfo")
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrReadOnlyFS, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		target := unescapeMountPath(fields[4])
+		if target == writable {
+			continue
+		}
+		flags := unix.MS_BIND | unix.MS_REMOUNT | unix.MS_RDONLY | mountFlags(fields[5])
+		if err := unix.Mount("", target, "", uintptr(flags), ""); err != nil {
+			if errors.Is(err, unix.ENOENT) {
+				// the mount point was deleted, so the mount cannot be reached
+				continue
+			}
+			return fmt.Errorf("%w: remount %s: %v", ErrReadOnlyFS, target, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%w: %v", ErrReadOnlyFS, err)
+	}
+	return nil
+}
+
+// mountFlags maps the per-mount options of a mountinfo line to MS_* flags.
+func mountFlags(options string) int {
+	flags := 0
+	for _, option := range strings.Split(options, ",") {
+		switch option {
+		case "nosuid":
+			flags |= unix.MS_NOSUID
+		case "nodev":
+			flags |= unix.MS_NODEV
+		case "noexec":
+			flags |= unix.MS_NOEXEC
+		case "noatime":
+			flags |= unix.MS_NOATIME
+		case "nodiratime":
+			flags |= unix.MS_NODIRATIME
+		case "relatime":
+			flags |= unix.MS_RELATIME
+		case "strictatime":
+			flags |= unix.MS_STRICTATIME
+		}
+	}
+	return flags
+}
+
+// unescapeMountPath decodes the \ooo octal escapes mountinfo uses for
+// spaces, tabs, newlines and backslashes in paths.
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+4 <= len(path) {
+			if c, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+// dropMountCapability removes CAP_SYS_ADMIN from the calling thread and its
+// bounding set, so neither the command nor a setuid binary it runs regains it.
+func dropMountCapability() error {
+	if err := unix.Prctl(unix.PR_CAPBSET_DROP, unix.CAP_SYS_ADMIN, 0, 0, 0); err != nil {
+		return fmt.Errorf("%w: drop CAP_SYS_ADMIN from the bounding set: %v", ErrReadOnlyFS, err)
+	}
+	header := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&header, &data[0]); err != nil {
+		return fmt.Errorf("%w: read capabilities: %v", ErrReadOnlyFS, err)
+	}
+	mask := ^uint32(1 << unix.CAP_SYS_ADMIN)
+	data[0].Effective &= mask
+	data[0].Permitted &= mask
+	data[0].Inheritable &= mask
+	if err := unix.Capset(&header, &data[0]); err != nil {
+		return fmt.Errorf("%w: drop CAP_SYS_ADMIN: %v", ErrReadOnlyFS, err)
+	}
+	return nil
+}
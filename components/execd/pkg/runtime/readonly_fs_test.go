@@ -0,0 +1,94 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+func TestReadOnlyFS_OnlyWritableDirAcceptsWrites(t *testing.T) {
+	if goruntime.GOOS != "linux" || os.Geteuid() != 0 {
+		t.Skip("read-only filesystem needs root on linux")
+	}
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found in PATH")
+	}
+	if err := checkReadOnlyFS(true); err != nil {
+		t.Skipf("mount namespaces unavailable here: %v", err)
+	}
+	outside := filepath.Join(t.TempDir(), "outside")
+
+	var stdout []string
+	var execErr *execute.ErrorOutput
+	req := &ExecuteCodeRequest{
+		Language:   Command,
+		Code:       `echo "$TMPDIR"; pwd; echo ok > "$TMPDIR/inside" && cat "$TMPDIR/inside"; echo > /dev/null && echo devnull; mount -o remount,rw / 2>/dev/null && echo remounted; echo no > ` + outside,
+		Timeout:    10 * time.Second,
+		ReadOnlyFS: true,
+		Hooks:      noopHooks(),
+	}
+	req.Hooks.OnExecuteStdout = func(s string) { stdout = append(stdout, s) }
+	req.Hooks.OnExecuteError = func(e *execute.ErrorOutput) { execErr = e }
+	if err := NewController("", "").Execute(req); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	if len(stdout) != 4 {
+		t.Fatalf("unexpected stdout: %q", stdout)
+	}
+	writable := stdout[0]
+	if !strings.Contains(writable, "execd-writable-") || stdout[1] != writable {
+		t.Fatalf("expected TMPDIR and cwd in the writable dir, got %q", stdout)
+	}
+	if stdout[2] != "ok" || stdout[3] != "devnull" {
+		t.Fatalf("expected writes to the writable dir and /dev/null to work, got %q", stdout)
+	}
+	if execErr == nil {
+		t.Fatalf("expected the command to fail writing outside the writable dir")
+	}
+	if _, err := os.Stat(outside); !os.IsNotExist(err) {
+		t.Fatalf("expected %s not to be created, stat err=%v", outside, err)
+	}
+	if _, err := os.Stat(writable); !os.IsNotExist(err) {
+		t.Fatalf("expected writable dir %s removed after completion, stat err=%v", writable, err)
+	}
+
+	// execd's own namespace stays writable
+	if err := os.WriteFile(outside, []byte("yes"), 0o600); err != nil {
+		t.Fatalf("execd lost write access: %v", err)
+	}
+}
+
+func TestCheckReadOnlyFS(t *testing.T) {
+	c := NewController("", "")
+	if err := c.CheckReadOnlyFS(false); err != nil {
+		t.Fatalf("disabled read-only filesystem should always be allowed: %v", err)
+	}
+	if goruntime.GOOS == "linux" && os.Geteuid() == 0 {
+		return
+	}
+	if err := c.CheckReadOnlyFS(true); !errors.Is(err, ErrReadOnlyFS) {
+		t.Fatalf("expected ErrReadOnlyFS without privileges, got %v", err)
+	}
+}
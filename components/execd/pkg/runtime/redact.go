@@ -0,0 +1,243 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+const (
+	redactedValue = "***"
+	// minRedactedLen keeps short values such as "1" or "true" from masking
+	// every occurrence of them in the output.
+	minRedactedLen = 4
+)
+
+// errRedactHighWatermark rejects redaction of a command whose lines may be
+// cut at the high watermark, since a secret crossing the cut would be
+// delivered unmasked.
+var errRedactHighWatermark = errors.New("redacting secrets is not supported together with an output high watermark")
+
+// outputRedactor masks the values of secret env vars execd itself passes to
+// an execution wherever the output echoes them. It is best-effort: only exact
+// values are caught, not encoded or transformed ones.
+type outputRedactor struct {
+	lineEvents bool
+
+	mu sync.Mutex
+	// secrets are sorted longest first, so one secret containing another is
+	// fully hidden.
+	secrets []string
+	// stdout and stderr hold the end of the last chunk that may begin a
+	// secret the next chunk completes; commands deliver whole lines, as
+	// runCommand refuses a high watermark that would cut them, and hold
+	// nothing.
+	stdout, stderr string
+}
+
+// newOutputRedactor returns nil unless request asked for redaction. The
+// values of request.Envs and extraEnv whose names look secret are masked.
+func newOutputRedactor(request *ExecuteCodeRequest, extraEnv map[string]string) *outputRedactor {
+	if !request.RedactSecrets {
+		return nil
+	}
+	r := &outputRedactor{lineEvents: request.Language == Command}
+	r.addEnv(extraEnv)
+	r.addEnv(request.Envs)
+	return r
+}
+
+// addEnv learns the secret-looking values of env, e.g. ones a pre-exec hook
+// vended.
+func (r *outputRedactor) addEnv(env map[string]string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, v := range env {
+		if secretKeyPattern.MatchString(k) && len(v) >= minRedactedLen && !slices.Contains(r.secrets, v) {
+			r.secrets = append(r.secrets, v)
+		}
+	}
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+}
+
+func (r *outputRedactor) mask(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redactedValue)
+	}
+	return s
+}
+
+// pending returns how many bytes at the end of s begin a secret without
+// completing it.
+func (r *outputRedactor) pending(s string) int {
+	longest := 0
+	for _, secret := range r.secrets {
+		for n := min(len(secret)-1, len(s)); n > longest; n-- {
+			if strings.HasSuffix(s, secret[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
+
+// feed masks a stream's next event and returns what can be delivered now.
+func (r *outputRedactor) feed(held *string, text string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lineEvents {
+		return r.mask(text)
+	}
+	return r.maskPartial(held, text)
+}
+
+// maskPartial masks text following what held kept of the stream so far, and
+// keeps back the end that may begin a secret. r.mu must be held.
+func (r *outputRedactor) maskPartial(held *string, text string) string {
+	out := r.mask(*held + text)
+	keep := r.pending(out)
+	*held = out[len(out)-keep:]
+	return out[:len(out)-keep]
+}
+
+// writer returns a writer masking what goes through it to w, and a flush
+// that writes what it held back once the stream ends. Without redaction w
+// itself is returned.
+func (r *outputRedactor) writer(w io.Writer) (io.Writer, func() error) {
+	if r == nil {
+		return w, func() error { return nil }
+	}
+	rw := &redactingWriter{r: r, w: w}
+	return rw, rw.flush
+}
+
+// redactingWriter masks a byte stream that may split secrets anywhere, such
+// as an output file uploaded to a sink.
+type redactingWriter struct {
+	r    *outputRedactor
+	w    io.Writer
+	held string
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	w.r.mu.Lock()
+	out := w.r.maskPartial(&w.held, string(p))
+	w.r.mu.Unlock()
+	if _, err := io.WriteString(w.w, out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *redactingWriter) flush() error {
+	_, err := io.WriteString(w.w, w.r.flush(&w.held))
+	return err
+}
+
+// flush returns what a stream still holds.
+func (r *outputRedactor) flush(held *string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := *held
+	*held = ""
+	return out
+}
+
+// wrap masks the output, results and errors before any other hook sees
+// them. What a stream holds back is delivered before the final event.
+func (r *outputRedactor) wrap(hooks ExecuteResultHook) ExecuteResultHook {
+	wrapped := hooks
+	// commands deliver empty lines too; a chunk that was all held back is
+	// not delivered
+	deliver := func(hook func(string), text string) {
+		if text != "" || r.lineEvents {
+			callHook(hook, text)
+		}
+	}
+	flush := func() {
+		if text := r.flush(&r.stdout); text != "" {
+			callHook(hooks.OnExecuteStdout, text)
+		}
+		if text := r.flush(&r.stderr); text != "" {
+			callHook(hooks.OnExecuteStderr, text)
+		}
+	}
+	maskString := func(s string) string {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.mask(s)
+	}
+	wrapped.OnExecuteStdout = func(text string) {
+		deliver(hooks.OnExecuteStdout, r.feed(&r.stdout, text))
+	}
+	wrapped.OnExecuteStderr = func(text string) {
+		deliver(hooks.OnExecuteStderr, r.feed(&r.stderr, text))
+	}
+	wrapped.OnExecuteResult = func(result map[string]any, count int) {
+		flush()
+		if hooks.OnExecuteResult != nil {
+			hooks.OnExecuteResult(maskValue(result, maskString).(map[string]any), count)
+		}
+	}
+	wrapped.OnExecuteValue = func(value *execute.ExecuteResult) {
+		if hooks.OnExecuteValue == nil {
+			return
+		}
+		masked := *value
+		masked.Data, _ = maskValue(value.Data, maskString).(map[string]any)
+		masked.Metadata, _ = maskValue(value.Metadata, maskString).(map[string]any)
+		hooks.OnExecuteValue(&masked)
+	}
+	wrapped.OnExecuteError = func(err *execute.ErrorOutput) {
+		flush()
+		if err != nil {
+			masked := *err
+			masked.EValue = maskString(err.EValue)
+			masked.Traceback = make([]string, len(err.Traceback))
+			for i, line := range err.Traceback {
+				masked.Traceback[i] = maskString(line)
+			}
+			err = &masked
+		}
+		callHook(hooks.OnExecuteError, err)
+	}
+	wrapped.OnExecutePostCommand = func(result *PostCommandResult) {
+		if result != nil {
+			masked := *result
+			masked.Stdout = maskString(result.Stdout)
+			masked.Stderr = maskString(result.Stderr)
+			masked.Error = maskString(result.Error)
+			result = &masked
+		}
+		callHook(hooks.OnExecutePostCommand, result)
+	}
+	wrapped.OnExecuteComplete = func(elapsed time.Duration) {
+		flush()
+		callHook(hooks.OnExecuteComplete, elapsed)
+	}
+	return wrapped
+}
@@ -0,0 +1,164 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+func TestExecute_RedactSecretsMasksInjectedValues(t *testing.T) {
+	skipWithoutBash(t)
+	c := NewController("", "")
+	c.AddPreExecHook(func(_ context.Context, request *ExecuteCodeRequest) error {
+		request.Envs["VENDED_TOKEN"] = "vended-" + request.Envs["API_KEY"]
+		return nil
+	})
+
+	var stdout, stderr []string
+	req := &ExecuteCodeRequest{
+		Language:      Command,
+		Code:          `echo "key=$API_KEY"; echo "$VENDED_TOKEN" >&2; echo "$REGION"`,
+		Envs:          map[string]string{"API_KEY": "sk-live-1234", "REGION": "us-east-1"},
+		RedactSecrets: true,
+		Timeout:       10 * time.Second,
+		Hooks:         noopHooks(),
+	}
+	req.Hooks.OnExecuteStdout = func(s string) { stdout = append(stdout, s) }
+	req.Hooks.OnExecuteStderr = func(s string) { stderr = append(stderr, s) }
+	req.Hooks.OnExecuteError = func(err *execute.ErrorOutput) { t.Errorf("unexpected error %s: %s", err.EName, err.EValue) }
+	require.NoError(t, c.Execute(req))
+
+	assert.Equal(t, []string{"key=***", "us-east-1"}, stdout, "only secret-looking env values are masked")
+	assert.Equal(t, []string{"***"}, stderr, "values vended by pre-exec hooks are masked too")
+}
+
+// A line cut at the watermark could split a secret between two events, and
+// command events are masked one at a time.
+func TestExecute_RedactSecretsRejectsHighWatermark(t *testing.T) {
+	req := &ExecuteCodeRequest{
+		Language:            Command,
+		Code:                "env",
+		Envs:                map[string]string{"API_KEY": "sk-live-1234"},
+		RedactSecrets:       true,
+		OutputHighWatermark: 4096,
+		Hooks:               noopHooks(),
+	}
+	require.ErrorIs(t, NewController("", "").Execute(req), errRedactHighWatermark)
+}
+
+func TestExecute_RedactSecretsMasksOutputSink(t *testing.T) {
+	skipWithoutBash(t)
+	store, server := newStubObjectStore(t)
+	req := &ExecuteCodeRequest{
+		Language:      Command,
+		Code:          `printf 'key=%s\n' "$API_KEY"; printf '%s' "$API_KEY" >&2`,
+		Envs:          map[string]string{"API_KEY": "sk-live-1234"},
+		RedactSecrets: true,
+		OutputSink:    &OutputSink{URL: server.URL + "/b/run"},
+		Timeout:       10 * time.Second,
+		Hooks:         noopHooks(),
+	}
+	req.Hooks.OnExecuteError = func(err *execute.ErrorOutput) { t.Errorf("unexpected error %s: %s", err.EName, err.EValue) }
+	req.Hooks.OnExecuteOutputSink = func(*OutputSinkResult) {}
+	require.NoError(t, NewController("", "").Execute(req))
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.Equal(t, "key=***\n", store.objects["/b/run/stdout"])
+	assert.Equal(t, "***", store.objects["/b/run/stderr"], "a secret at the very end is masked too")
+}
+
+func TestExecute_RedactSecretsMasksPostCommand(t *testing.T) {
+	skipWithoutBash(t)
+	req, _, result := postCommandRequest("true", &PostCommand{Code: `echo "$API_KEY"; echo "$API_KEY" >&2`})
+	req.Envs = map[string]string{"API_KEY": "sk-live-1234"}
+	req.RedactSecrets = true
+	require.NoError(t, NewController("", "").Execute(req))
+
+	require.NotNil(t, *result)
+	assert.Equal(t, "***\n", (*result).Stdout)
+	assert.Equal(t, "***\n", (*result).Stderr)
+}
+
+func TestRedactingWriter_SecretSplitAcrossWrites(t *testing.T) {
+	r := newOutputRedactor(&ExecuteCodeRequest{
+		Language:      Command,
+		RedactSecrets: true,
+		Envs:          map[string]string{"DB_PASSWORD": "hunter2-xyz"},
+	}, nil)
+	var out strings.Builder
+	w, flush := r.writer(&out)
+	for _, chunk := range []string{"pw=hun", "ter2", "-xyz ok hunt"} {
+		_, err := w.Write([]byte(chunk))
+		require.NoError(t, err)
+	}
+	require.NoError(t, flush())
+	assert.Equal(t, "pw=*** ok hunt", out.String())
+}
+
+func TestOutputRedactor_SecretSplitAcrossChunks(t *testing.T) {
+	r := newOutputRedactor(&ExecuteCodeRequest{
+		Language:      Python,
+		RedactSecrets: true,
+		Envs:          map[string]string{"DB_PASSWORD": "hunter2-xyz", "SHORT_TOKEN": "abc"},
+	}, map[string]string{"EXTRA_SECRET": "from-execd-envs"})
+	var stdout strings.Builder
+	var completed bool
+	hooks := r.wrap(ExecuteResultHook{
+		OnExecuteStdout:   func(s string) { stdout.WriteString(s) },
+		OnExecuteComplete: func(time.Duration) { completed = true },
+	})
+
+	for _, chunk := range []string{"password: hun", "ter", "2-xyz\nextra: from-exec", "d-envs abc", " trailing hunt"} {
+		hooks.OnExecuteStdout(chunk)
+		assert.NotContains(t, stdout.String(), "hunter2-xyz")
+	}
+	assert.Equal(t, "password: ***\nextra: *** abc trailing ", stdout.String(), "a possible start of a secret is held back")
+	hooks.OnExecuteComplete(time.Second)
+	assert.True(t, completed)
+	assert.Equal(t, "password: ***\nextra: *** abc trailing hunt", stdout.String(), "held output is delivered before the final event")
+}
+
+func TestOutputRedactor_MasksCellValue(t *testing.T) {
+	r := newOutputRedactor(&ExecuteCodeRequest{
+		Language:      Python,
+		RedactSecrets: true,
+		Envs:          map[string]string{"DB_PASSWORD": "hunter2-xyz"},
+	}, nil)
+	var got *execute.ExecuteResult
+	hooks := r.wrap(ExecuteResultHook{OnExecuteValue: func(v *execute.ExecuteResult) { got = v }})
+
+	hooks.OnExecuteValue(&execute.ExecuteResult{
+		ExecutionCount: 3,
+		Data:           map[string]any{"text/plain": "'hunter2-xyz'"},
+		Metadata:       map[string]any{"note": "from hunter2-xyz"},
+	})
+	require.NotNil(t, got)
+	assert.Equal(t, 3, got.ExecutionCount)
+	assert.Equal(t, map[string]any{"text/plain": "'***'"}, got.Data)
+	assert.Equal(t, map[string]any{"note": "from ***"}, got.Metadata)
+}
+
+func TestOutputRedactor_Disabled(t *testing.T) {
+	assert.Nil(t, newOutputRedactor(&ExecuteCodeRequest{Envs: map[string]string{"TOKEN": "secret-value"}}, nil))
+}
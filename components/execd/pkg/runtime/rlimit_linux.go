@@ -0,0 +1,48 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// checkNofileLimit reports whether limit can be applied to a child of execd:
+// raising it past execd's hard limit needs root, and nothing may exceed
+// fs.nr_open. Zero means the child inherits execd's limit.
+func checkNofileLimit(limit uint64) error {
+	if limit == 0 {
+		return nil
+	}
+	var current syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &current); err != nil {
+		return fmt.Errorf("%w: read current limit: %v", ErrRLimitNofile, err)
+	}
+	if limit > current.Max && os.Geteuid() != 0 {
+		return fmt.Errorf("%w: %d exceeds the hard limit %d", ErrRLimitNofile, limit, current.Max)
+	}
+	if raw, err := os.ReadFile("/proc/sys/fs/nr_open"); err == nil {
+		if nrOpen, err := strconv.ParseUint(strings.TrimSpace(string(raw)), 10, 64); err == nil && limit > nrOpen {
+			return fmt.Errorf("%w: %d exceeds fs.nr_open %d", ErrRLimitNofile, limit, nrOpen)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package runtime
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExecute_RLimitNofileAppliesSoftAndHardLimit(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found in PATH")
+	}
+
+	var stdout []string
+	req := &ExecuteCodeRequest{
+		Language:     Command,
+		Code:         "ulimit -n; ulimit -Hn; ulimit -n 128 2>/dev/null || echo denied",
+		Timeout:      5 * time.Second,
+		RLimitNofile: 64,
+		Hooks:        noopHooks(),
+	}
+	req.Hooks.OnExecuteStdout = func(s string) { stdout = append(stdout, s) }
+	if err := NewController("", "").Execute(req); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if strings.Join(stdout, ",") != "64,64,denied" {
+		t.Fatalf("expected soft and hard limit 64 that cannot be raised, got %v", stdout)
+	}
+}
+
+func TestCheckNofileLimit_AboveHardLimit(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root may raise the hard limit")
+	}
+	var current syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &current); err != nil {
+		t.Fatalf("getrlimit: %v", err)
+	}
+	if current.Max == ^uint64(0) {
+		t.Skip("hard limit is unlimited")
+	}
+
+	err := checkNofileLimit(current.Max + 1)
+	if !errors.Is(err, ErrRLimitNofile) {
+		t.Fatalf("expected ErrRLimitNofile, got %v", err)
+	}
+	if err := checkNofileLimit(0); err != nil {
+		t.Fatalf("expected zero to inherit, got %v", err)
+	}
+}
+
+func TestResolveCommand_RLimitNofilePrelude(t *testing.T) {
+	resolved := resolveCommand("linux", &ExecuteCodeRequest{Code: "echo hi", RLimitNofile: 256}, nil)
+	script := resolved.Argv[len(resolved.Argv)-1]
+	if !strings.HasPrefix(script, "ulimit -n 256 ||") || !strings.HasSuffix(script, "\necho hi") {
+		t.Fatalf("unexpected script: %q", script)
+	}
+	if resolved.RLimitNofile != 256 {
+		t.Fatalf("expected resolved limit 256, got %d", resolved.RLimitNofile)
+	}
+
+	resolved = resolveCommand("linux", &ExecuteCodeRequest{Code: "echo hi"}, nil)
+	if resolved.Argv[len(resolved.Argv)-1] != "echo hi" || resolved.RLimitNofile != 0 {
+		t.Fatalf("expected no prelude without a limit, got %v", resolved.Argv)
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package runtimetest provides a scripted runtime backend for tests that
+// exercise execd's scheduling, hooks and streaming without starting
+// processes or Jupyter kernels.
+package runtimetest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+	"github.com/alibaba/opensandbox/execd/pkg/runtime"
+)
+
+// Script is the scripted outcome of one execution.
+type Script struct {
+	// Stdout and Stderr are reported line by line, stdout first.
+	Stdout []string
+	Stderr []string
+	// Results are reported through OnExecuteResult with counts 1, 2, ...
+	// and, for code, through OnExecuteValue as a Jupyter kernel would.
+	Results []map[string]any
+	// Delay is waited before any output; the execution fails with a timeout
+	// error if the request's context ends first.
+	Delay time.Duration
+	// ExitCode other than zero fails a command the way a real non-zero exit does.
+	ExitCode int
+	// Signal names the signal that killed a command, e.g. "SIGKILL"; it is
+	// reported with exit code -1 and fails the command.
+	Signal string
+	// Error, if set, is reported instead of completion, e.g. a Python exception.
+	Error *execute.ErrorOutput
+	// StartErr is returned from Run without calling any hook.
+	StartErr error
+}
+
+// Backend answers every request with the Script its handler returns and
+// records the requests it saw. It is safe for concurrent use.
+type Backend struct {
+	handler func(*runtime.ExecuteCodeRequest) Script
+
+	mu       sync.Mutex
+	requests []runtime.ExecuteCodeRequest
+	seq      int
+	running  int
+	peak     int
+}
+
+// NewBackend returns a backend scripted by handler.
+func NewBackend(handler func(*runtime.ExecuteCodeRequest) Script) *Backend {
+	return &Backend{handler: handler}
+}
+
+// Scripts returns a backend answering each request by its exact Code, and
+// with an empty successful run for code it does not know.
+func Scripts(byCode map[string]Script) *Backend {
+	return NewBackend(func(req *runtime.ExecuteCodeRequest) Script {
+		return byCode[req.Code]
+	})
+}
+
+// NewController returns a runtime controller whose executions are served
+// by b. Everything else, such as command slots and transcripts, is real.
+func NewController(b *Backend) *runtime.Controller {
+	c := runtime.NewController("", "")
+	c.SetBackend(b)
+	return c
+}
+
+// Requests returns copies of the requests run so far, in arrival order.
+func (b *Backend) Requests() []runtime.ExecuteCodeRequest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]runtime.ExecuteCodeRequest(nil), b.requests...)
+}
+
+// MaxConcurrent returns the largest number of executions that ran at once.
+func (b *Backend) MaxConcurrent() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.peak
+}
+
+// Run implements runtime.Backend.
+func (b *Backend) Run(ctx context.Context, request *runtime.ExecuteCodeRequest) error {
+	script := b.handler(request)
+	if script.StartErr != nil {
+		return script.StartErr
+	}
+
+	b.mu.Lock()
+	b.requests = append(b.requests, *request)
+	b.seq++
+	session := fmt.Sprintf("fake-%d", b.seq)
+	b.running++
+	b.peak = max(b.peak, b.running)
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		b.running--
+		b.mu.Unlock()
+	}()
+
+	hooks := request.Hooks
+	if request.Language == runtime.Command && request.DryRun {
+		hooks.OnExecuteDryRun(&runtime.ResolvedCommand{Argv: []string{"bash", "-c", request.Code}, Cwd: request.Cwd})
+		return nil
+	}
+
+	start := time.Now()
+	hooks.OnExecuteInit(session)
+	if request.Language == runtime.BackgroundCommand {
+		// background commands complete once started; their output is not scripted
+		hooks.OnExecuteComplete(time.Since(start))
+		return nil
+	}
+	if script.Delay > 0 {
+		timer := time.NewTimer(script.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			request.ReportExit(runtime.ExitOutcome{ExitCode: -1, Signal: "SIGKILL"})
+			hooks.OnExecuteError(&execute.ErrorOutput{EName: "CommandExecError", EValue: ctx.Err().Error(), Traceback: []string{ctx.Err().Error()}})
+			return nil
+		}
+	}
+	for _, line := range script.Stdout {
+		hooks.OnExecuteStdout(line)
+	}
+	for _, line := range script.Stderr {
+		hooks.OnExecuteStderr(line)
+	}
+	for i, result := range script.Results {
+		hooks.OnExecuteResult(result, i+1)
+		if request.Language != runtime.Command && hooks.OnExecuteValue != nil {
+			hooks.OnExecuteValue(&execute.ExecuteResult{ExecutionCount: i + 1, Data: result})
+		}
+	}
+	if request.Language == runtime.Command {
+		outcome := runtime.ExitOutcome{ExitCode: script.ExitCode, Signal: script.Signal, Stderr: strings.Join(script.Stderr, "\n")}
+		if script.Signal != "" {
+			outcome.ExitCode = -1
+		}
+		request.ReportExit(outcome)
+	}
+	switch {
+	case script.Signal != "":
+		hooks.OnExecuteError(&execute.ErrorOutput{
+			EName:     "CommandExecError",
+			EValue:    "-1",
+			Traceback: []string{"signal: " + script.Signal},
+		})
+	case script.ExitCode != 0:
+		hooks.OnExecuteError(&execute.ErrorOutput{
+			EName:     "CommandExecError",
+			EValue:    strconv.Itoa(script.ExitCode),
+			Traceback: []string{fmt.Sprintf("exit status %d", script.ExitCode)},
+		})
+	case script.Error != nil:
+		hooks.OnExecuteError(script.Error)
+	default:
+		hooks.OnExecuteComplete(time.Since(start))
+	}
+	return nil
+}
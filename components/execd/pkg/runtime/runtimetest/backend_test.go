@@ -0,0 +1,187 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtimetest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+	"github.com/alibaba/opensandbox/execd/pkg/runtime"
+)
+
+// recordHooks returns hooks appending "event:detail" to the returned log.
+func recordHooks() (runtime.ExecuteResultHook, func() []string) {
+	var mu sync.Mutex
+	var events []string
+	add := func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, fmt.Sprintf(format, args...))
+	}
+	hooks := runtime.ExecuteResultHook{
+		OnExecuteInit:     func(session string) { add("init") },
+		OnExecuteResult:   func(result map[string]any, count int) { add("result:%d:%v", count, result["text/plain"]) },
+		OnExecuteStatus:   func(status string) { add("status:%s", status) },
+		OnExecuteStdout:   func(s string) { add("stdout:%s", s) },
+		OnExecuteStderr:   func(s string) { add("stderr:%s", s) },
+		OnExecuteError:    func(err *execute.ErrorOutput) { add("error:%s:%s", err.EName, err.EValue) },
+		OnExecuteComplete: func(time.Duration) { add("complete") },
+		OnExecuteDryRun:   func(r *runtime.ResolvedCommand) { add("dryrun:%s", strings.Join(r.Argv, " ")) },
+	}
+	return hooks, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), events...)
+	}
+}
+
+func TestBackend_HookSequence(t *testing.T) {
+	backend := Scripts(map[string]Script{
+		"make":        {Stdout: []string{"cc main.c"}, Stderr: []string{"warning: unused"}},
+		"make check":  {Stdout: []string{"FAIL"}, ExitCode: 2},
+		"1/0":         {Error: &execute.ErrorOutput{EName: "ZeroDivisionError", EValue: "division by zero"}},
+		"1+1":         {Results: []map[string]any{{"text/plain": "2"}}},
+		"make deploy": {StartErr: errors.New("no kernel")},
+	})
+	c := NewController(backend)
+
+	cases := []struct {
+		req  runtime.ExecuteCodeRequest
+		want []string
+	}{
+		{runtime.ExecuteCodeRequest{Language: runtime.Command, Code: "make"},
+			[]string{"init", "stdout:cc main.c", "stderr:warning: unused", "complete"}},
+		{runtime.ExecuteCodeRequest{Language: runtime.Command, Code: "make check"},
+			[]string{"init", "stdout:FAIL", "error:CommandExecError:2"}},
+		{runtime.ExecuteCodeRequest{Language: runtime.Python, Code: "1/0"},
+			[]string{"init", "error:ZeroDivisionError:division by zero"}},
+		{runtime.ExecuteCodeRequest{Language: runtime.Python, Code: "1+1"},
+			[]string{"init", "result:1:2", "complete"}},
+		{runtime.ExecuteCodeRequest{Language: runtime.Command, Code: "make", DryRun: true},
+			[]string{"dryrun:bash -c make"}},
+		{runtime.ExecuteCodeRequest{Language: runtime.BackgroundCommand, Code: "make check"},
+			[]string{"init", "complete"}},
+	}
+	for _, tc := range cases {
+		req := tc.req
+		hooks, events := recordHooks()
+		req.Hooks = hooks
+		assert.NoError(t, c.Execute(&req), tc.req.Code)
+		assert.Equal(t, tc.want, events(), tc.req.Code)
+	}
+
+	req := &runtime.ExecuteCodeRequest{Language: runtime.Command, Code: "make deploy"}
+	req.Hooks, _ = recordHooks()
+	assert.EqualError(t, c.Execute(req), "no kernel")
+	assert.Len(t, backend.Requests(), len(cases))
+}
+
+func TestBackend_CommandSlotsSerializeExecutions(t *testing.T) {
+	backend := NewBackend(func(*runtime.ExecuteCodeRequest) Script {
+		return Script{Delay: 20 * time.Millisecond}
+	})
+	c := NewController(backend)
+	c.SetMaxConcurrentCommands(1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := &runtime.ExecuteCodeRequest{Language: runtime.Command, Code: "sleep"}
+			req.Hooks, _ = recordHooks()
+			assert.NoError(t, c.Execute(req))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, backend.MaxConcurrent())
+	assert.Len(t, backend.Requests(), 3)
+}
+
+func TestBackend_LanesSerializeWithinAndRunInParallelAcross(t *testing.T) {
+	backend := Scripts(map[string]Script{"step": {Delay: 50 * time.Millisecond}})
+	c := NewController(backend)
+
+	var mu sync.Mutex
+	running := map[string]int{}
+	peak := map[string]int{}
+	var wg sync.WaitGroup
+	run := func(req *runtime.ExecuteCodeRequest, lane string) {
+		defer wg.Done()
+		req.Code = "step"
+		req.Hooks, _ = recordHooks()
+		req.Hooks.OnExecuteInit = func(string) {
+			mu.Lock()
+			defer mu.Unlock()
+			running[lane]++
+			peak[lane] = max(peak[lane], running[lane])
+		}
+		req.Hooks.OnExecuteComplete = func(time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			running[lane]--
+		}
+		assert.NoError(t, c.Execute(req))
+	}
+	for i := 0; i < 3; i++ {
+		wg.Add(4)
+		go run(&runtime.ExecuteCodeRequest{Language: runtime.Python, Context: "a"}, "context a")
+		go run(&runtime.ExecuteCodeRequest{Language: runtime.Python, Context: "b"}, "context b")
+		go run(&runtime.ExecuteCodeRequest{Language: runtime.Python}, "default python")
+		go run(&runtime.ExecuteCodeRequest{Language: runtime.Command, Lane: "shell"}, "command lane")
+	}
+	wg.Wait()
+
+	assert.Equal(t, map[string]int{"context a": 1, "context b": 1, "default python": 1, "command lane": 1}, peak,
+		"executions of one lane must not overlap")
+	assert.Equal(t, 4, backend.MaxConcurrent(), "different lanes run in parallel")
+	assert.Len(t, backend.Requests(), 12)
+}
+
+func TestBackend_CommandLaneLimitIsConfigurable(t *testing.T) {
+	backend := Scripts(map[string]Script{"step": {Delay: 50 * time.Millisecond}})
+	c := NewController(backend)
+	c.SetMaxCommandsPerLane(2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := &runtime.ExecuteCodeRequest{Language: runtime.Command, Code: "step", Lane: "build"}
+			req.Hooks, _ = recordHooks()
+			assert.NoError(t, c.Execute(req))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 2, backend.MaxConcurrent())
+}
+
+func TestBackend_TimeoutEndsDelayedRun(t *testing.T) {
+	c := NewController(Scripts(map[string]Script{"sleep 60": {Delay: time.Minute, Stdout: []string{"late"}}}))
+	req := &runtime.ExecuteCodeRequest{Language: runtime.Command, Code: "sleep 60", Timeout: 10 * time.Millisecond}
+	hooks, events := recordHooks()
+	req.Hooks = hooks
+
+	assert.NoError(t, c.Execute(req))
+	assert.Equal(t, []string{"init", "error:CommandExecError:context deadline exceeded"}, events())
+}
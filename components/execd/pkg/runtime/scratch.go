@@ -0,0 +1,90 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+)
+
+// SetMaxScratchMB caps the scratch tmpfs a single command may ask for;
+// zero or less disables scratch space.
+func (c *Controller) SetMaxScratchMB(limit int) {
+	if limit < 0 {
+		limit = 0
+	}
+	c.maxScratchMB = limit
+}
+
+// CheckScratchSize reports whether a command may request sizeMB of scratch space.
+func (c *Controller) CheckScratchSize(sizeMB int) error {
+	if sizeMB <= 0 {
+		return nil
+	}
+	if sizeMB > c.maxScratchMB {
+		return fmt.Errorf("%w: requested %d MiB, maximum %d MiB", ErrScratchTooLarge, sizeMB, c.maxScratchMB)
+	}
+	return nil
+}
+
+// scratchSpace is a size-limited tmpfs mounted for one command.
+type scratchSpace struct {
+	dir string
+}
+
+// prepareScratch mounts the scratch space requested by request, if any, and
+// points resolved at it. Where tmpfs cannot be mounted (no privileges, not
+// Linux) the command runs without it and nil is returned.
+func (c *Controller) prepareScratch(session string, request *ExecuteCodeRequest, resolved *ResolvedCommand) (*scratchSpace, error) {
+	if request.ScratchMB <= 0 {
+		return nil, nil
+	}
+	if err := c.CheckScratchSize(request.ScratchMB); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(os.TempDir(), "execd-scratch-"+session)
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create scratch dir: %w", err)
+	}
+	if err := mountTmpfs(dir, request.ScratchMB); err != nil {
+		_ = os.Remove(dir)
+		request.Warn(WarningScratchUnavailable, "scratch tmpfs unavailable for %s, running without it: %v", session, err)
+		return nil, nil
+	}
+
+	resolved.env = mergeEnvs(resolved.env, map[string]string{"TMPDIR": dir})
+	if resolved.Cwd == "" {
+		resolved.Cwd = dir
+	}
+	return &scratchSpace{dir: dir}, nil
+}
+
+// release unmounts the tmpfs, dropping its contents, and removes the mount point.
+func (s *scratchSpace) release() {
+	if s == nil {
+		return
+	}
+	if err := unmountTmpfs(s.dir); err != nil {
+		log.Error("failed to unmount scratch %s: %v", s.dir, err)
+		return
+	}
+	if err := os.Remove(s.dir); err != nil {
+		log.Error("failed to remove scratch dir %s: %v", s.dir, err)
+	}
+}
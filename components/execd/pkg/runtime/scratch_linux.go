@@ -0,0 +1,35 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package runtime
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// mountTmpfs mounts a tmpfs of sizeMB MiB on dir. The kernel enforces the
+// size, so writes past it fail with ENOSPC instead of consuming host memory.
+func mountTmpfs(dir string, sizeMB int) error {
+	opts := fmt.Sprintf("size=%dm,mode=0700", sizeMB)
+	return syscall.Mount("tmpfs", dir, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, opts)
+}
+
+// unmountTmpfs detaches the mount even if a straggling child still holds it busy.
+func unmountTmpfs(dir string) error {
+	return syscall.Unmount(dir, syscall.MNT_DETACH)
+}
@@ -0,0 +1,114 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	goruntime "runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+func runScratchCommand(t *testing.T, c *Controller, code string, scratchMB int) (stdout []string, execErr *execute.ErrorOutput) {
+	t.Helper()
+	req := &ExecuteCodeRequest{
+		Language:  Command,
+		Code:      code,
+		Timeout:   10 * time.Second,
+		ScratchMB: scratchMB,
+		Hooks:     noopHooks(),
+	}
+	req.Hooks.OnExecuteStdout = func(s string) { stdout = append(stdout, s) }
+	req.Hooks.OnExecuteError = func(e *execute.ErrorOutput) { execErr = e }
+	if err := c.Execute(req); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	return stdout, execErr
+}
+
+func TestScratch_MountsSizeLimitedTmpfs(t *testing.T) {
+	if goruntime.GOOS != "linux" || os.Geteuid() != 0 {
+		t.Skip("tmpfs scratch needs root on linux")
+	}
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found in PATH")
+	}
+	probe := t.TempDir()
+	if err := mountTmpfs(probe, 1); err != nil {
+		t.Skipf("cannot mount tmpfs here: %v", err)
+	}
+	_ = unmountTmpfs(probe)
+
+	c := NewController("", "")
+	c.SetMaxScratchMB(4)
+
+	stdout, execErr := runScratchCommand(t, c,
+		`echo "$TMPDIR"; pwd; stat -f -c %T "$TMPDIR"; head -c 8M /dev/zero > "$TMPDIR/big"`, 2)
+	if len(stdout) != 3 {
+		t.Fatalf("unexpected stdout: %q", stdout)
+	}
+	scratch := stdout[0]
+	if !strings.Contains(scratch, "execd-scratch-") || stdout[1] != scratch || stdout[2] != "tmpfs" {
+		t.Fatalf("expected TMPDIR and cwd on a tmpfs scratch, got %q", stdout)
+	}
+	if execErr == nil {
+		t.Fatalf("expected writing past the size limit to fail")
+	}
+	if _, err := os.Stat(scratch); !os.IsNotExist(err) {
+		t.Fatalf("expected scratch %s removed after completion, stat err=%v", scratch, err)
+	}
+}
+
+func TestScratch_RunsWithoutTmpfsWhenUnprivileged(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("bash not available on windows")
+	}
+	if goruntime.GOOS == "linux" && os.Geteuid() == 0 {
+		t.Skip("root can mount tmpfs")
+	}
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found in PATH")
+	}
+	t.Setenv("TMPDIR", t.TempDir())
+
+	c := NewController("", "")
+	c.SetMaxScratchMB(4)
+	stdout, execErr := runScratchCommand(t, c, `echo "$TMPDIR"`, 2)
+	if execErr != nil || len(stdout) != 1 || strings.Contains(stdout[0], "execd-scratch-") {
+		t.Fatalf("expected the command to run without scratch, stdout=%q err=%+v", stdout, execErr)
+	}
+}
+
+func TestCheckScratchSize(t *testing.T) {
+	c := NewController("", "")
+	if err := c.CheckScratchSize(0); err != nil {
+		t.Fatalf("no scratch should always be allowed: %v", err)
+	}
+	if err := c.CheckScratchSize(1); !errors.Is(err, ErrScratchTooLarge) {
+		t.Fatalf("expected scratch disabled by default, got %v", err)
+	}
+	c.SetMaxScratchMB(64)
+	if err := c.CheckScratchSize(64); err != nil {
+		t.Fatalf("expected 64 MiB within the limit: %v", err)
+	}
+	if err := c.CheckScratchSize(65); !errors.Is(err, ErrScratchTooLarge) {
+		t.Fatalf("expected 65 MiB rejected, got %v", err)
+	}
+}
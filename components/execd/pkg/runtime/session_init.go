@@ -0,0 +1,74 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+)
+
+// initScriptTimeout bounds a context's init script, e.g. a slow venv activation.
+const initScriptTimeout = 5 * time.Minute
+
+// runInitScript executes req.InitScript in the freshly created session. On
+// failure the session is deleted so callers never see a half-initialized context.
+func (c *Controller) runInitScript(session string, kernel *jupyterKernel, req *CreateContextRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), initScriptTimeout)
+	defer cancel()
+
+	var (
+		stdout, stderr strings.Builder
+		output         = &SessionInitOutput{}
+	)
+	run := &ExecuteCodeRequest{
+		Language: req.Language,
+		Context:  session,
+		Code:     req.InitScript,
+		Hooks: ExecuteResultHook{
+			OnExecuteResult: func(result map[string]any, _ int) {
+				output.Results = append(output.Results, result)
+			},
+			OnExecuteStatus:   func(string) {},
+			OnExecuteStdout:   func(text string) { stdout.WriteString(text) },
+			OnExecuteStderr:   func(text string) { stderr.WriteString(text) },
+			OnExecuteError:    func(err *execute.ErrorOutput) { output.Error = err },
+			OnExecuteComplete: func(time.Duration) {},
+		},
+	}
+
+	log.Info("running init script for context %s", session)
+	err := c.runJupyterCode(ctx, kernel, run)
+	output.Stdout = stdout.String()
+	output.Stderr = stderr.String()
+	if req.OnInitOutput != nil {
+		req.OnInitOutput(output)
+	}
+	if err == nil && output.Error == nil {
+		return nil
+	}
+
+	if cleanupErr := c.deleteSessionAndCleanup(session); cleanupErr != nil {
+		log.Warning("failed to remove context %s after init failure: %v", session, cleanupErr)
+	}
+	if output.Error != nil {
+		return fmt.Errorf("%w: %s: %s", ErrSessionInitFailed, output.Error.EName, output.Error.EValue)
+	}
+	return fmt.Errorf("%w: %v", ErrSessionInitFailed, err)
+}
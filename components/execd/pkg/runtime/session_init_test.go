@@ -0,0 +1,225 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter"
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+// fakeStatefulKernel understands `name = value`, `print(name)`, `raise` and a
+// bare `name`, whose value it reports as an execute_result, keeping variables
+// across connections like a real kernel would.
+type fakeStatefulKernel struct {
+	mu       sync.Mutex
+	vars     map[string]string
+	executed []string
+	deleted  []string
+}
+
+func newFakeStatefulKernel(t *testing.T) (*fakeStatefulKernel, *httptest.Server) {
+	t.Helper()
+
+	k := &fakeStatefulKernel{vars: map[string]string{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/api/sessions/") {
+			k.mu.Lock()
+			k.deleted = append(k.deleted, path.Base(r.URL.Path))
+			k.mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+		for {
+			var req execute.Message
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			var content execute.ExecuteRequest
+			_ = json.Unmarshal(req.Content, &content)
+			k.run(conn, req.Header, content.Code)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return k, server
+}
+
+func (k *fakeStatefulKernel) run(conn *websocket.Conn, parent execute.Header, code string) {
+	k.mu.Lock()
+	k.executed = append(k.executed, code)
+	count := len(k.executed)
+	reply := execute.ExecuteReply{ExecutionCount: count, Status: "ok"}
+	var stdout []string
+	var result *execute.ExecuteResult
+	for _, line := range strings.Split(code, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "raise":
+			reply.Status = "error"
+			reply.ErrorOutput = execute.ErrorOutput{EName: "RuntimeError", EValue: "init broke"}
+		case strings.HasPrefix(line, "print(") && strings.HasSuffix(line, ")"):
+			stdout = append(stdout, k.vars[strings.TrimSuffix(strings.TrimPrefix(line, "print("), ")")])
+		case strings.Contains(line, "="):
+			name, value, _ := strings.Cut(line, "=")
+			k.vars[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		case k.vars[line] != "":
+			result = &execute.ExecuteResult{
+				ExecutionCount: count,
+				Data:           map[string]any{"text/plain": "'" + k.vars[line] + "'"},
+				Metadata:       map[string]any{"source": line},
+			}
+		}
+	}
+	k.mu.Unlock()
+
+	send := func(msgType execute.MessageType, content any) {
+		raw, _ := json.Marshal(content)
+		_ = conn.WriteJSON(execute.Message{
+			Header:       execute.Header{MessageType: string(msgType), Session: parent.Session},
+			ParentHeader: parent,
+			Content:      raw,
+		})
+	}
+	for _, text := range stdout {
+		send(execute.MsgStream, execute.StreamOutput{Name: execute.StreamStdout, Text: text})
+	}
+	if result != nil {
+		send(execute.MsgExecuteResult, result)
+	}
+	if reply.EName != "" {
+		send(execute.MsgError, reply.ErrorOutput)
+	}
+	send(execute.MsgExecuteReply, reply)
+	send(execute.MsgStatus, execute.StatusUpdate{ExecutionState: execute.StateIdle})
+}
+
+func (k *fakeStatefulKernel) snapshot() (executed, deleted []string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return append([]string(nil), k.executed...), append([]string(nil), k.deleted...)
+}
+
+func addFakeKernel(c *Controller, server *httptest.Server, session string) *jupyterKernel {
+	kernel := &jupyterKernel{
+		kernelID: "kernel-" + session,
+		client:   jupyter.NewClient(server.URL, jupyter.WithToken("token")),
+		language: Python,
+	}
+	kernel.touch()
+	c.storeJupyterKernel(session, kernel)
+	return kernel
+}
+
+func TestRunInitScript_StatePersistsForLaterExecutions(t *testing.T) {
+	fake, server := newFakeStatefulKernel(t)
+	c := NewController(server.URL, "token")
+	kernel := addFakeKernel(c, server, "ctx-init")
+
+	var outputs []*SessionInitOutput
+	req := &CreateContextRequest{
+		Language:     Python,
+		InitScript:   "greeting = hello\nprint(greeting)",
+		OnInitOutput: func(o *SessionInitOutput) { outputs = append(outputs, o) },
+	}
+	if err := c.runInitScript("ctx-init", kernel, req); err != nil {
+		t.Fatalf("runInitScript: %v", err)
+	}
+	if len(outputs) != 1 || outputs[0].Stdout != "hello" || outputs[0].Error != nil {
+		t.Fatalf("unexpected init output: %+v", outputs)
+	}
+
+	var stdout []string
+	hooks := noopHooks()
+	hooks.OnExecuteStdout = func(s string) { stdout = append(stdout, s) }
+	hooks.OnExecuteResult = func(map[string]any, int) {}
+	hooks.OnExecuteStatus = func(string) {}
+	for i := 0; i < 2; i++ {
+		err := c.runJupyterCode(t.Context(), kernel, &ExecuteCodeRequest{
+			Language: Python,
+			Context:  "ctx-init",
+			Code:     "print(greeting)",
+			Hooks:    hooks,
+		})
+		if err != nil {
+			t.Fatalf("runJupyterCode: %v", err)
+		}
+	}
+	if strings.Join(stdout, ",") != "hello,hello" {
+		t.Fatalf("expected init state visible to later executions, got %v", stdout)
+	}
+
+	executed, deleted := fake.snapshot()
+	if len(executed) != 3 || executed[0] != req.InitScript {
+		t.Fatalf("expected init script to run exactly once before user code, got %q", executed)
+	}
+	if len(deleted) != 0 || c.getJupyterKernel("ctx-init") == nil {
+		t.Fatalf("expected context to survive a successful init, deleted=%v", deleted)
+	}
+}
+
+func TestRunInitScript_FailureRemovesContext(t *testing.T) {
+	fake, server := newFakeStatefulKernel(t)
+	c := NewController(server.URL, "token")
+	kernel := addFakeKernel(c, server, "ctx-broken")
+
+	var output *SessionInitOutput
+	err := c.runInitScript("ctx-broken", kernel, &CreateContextRequest{
+		Language:     Python,
+		InitScript:   "x = 1\nraise",
+		OnInitOutput: func(o *SessionInitOutput) { output = o },
+	})
+	if !errors.Is(err, ErrSessionInitFailed) || !strings.Contains(err.Error(), "RuntimeError: init broke") {
+		t.Fatalf("expected ErrSessionInitFailed with the kernel error, got %v", err)
+	}
+	if output == nil || output.Error == nil || output.Error.EName != "RuntimeError" {
+		t.Fatalf("expected init output to carry the error, got %+v", output)
+	}
+
+	_, deleted := fake.snapshot()
+	if len(deleted) != 1 || deleted[0] != "ctx-broken" {
+		t.Fatalf("expected failed context to be shut down, got %v", deleted)
+	}
+	if c.getJupyterKernel("ctx-broken") != nil {
+		t.Fatalf("expected failed context to be removed from cache")
+	}
+}
+
+func TestSessionInitOutput_OmitsEmptyFields(t *testing.T) {
+	raw, err := json.Marshal(&SessionInitOutput{Stdout: "ok"})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(raw) != `{"stdout":"ok"}` {
+		t.Fatalf("unexpected encoding: %s", raw)
+	}
+}
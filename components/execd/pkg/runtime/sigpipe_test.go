@@ -0,0 +1,49 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package runtime
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+	"github.com/stretchr/testify/assert"
+)
+
+// A client going away mid-stream raises SIGPIPE in execd, which must not
+// reach the command.
+func TestRunCommand_DoesNotForwardSIGPIPE(t *testing.T) {
+	skipWithoutBash(t)
+	var stdout []string
+	req := &ExecuteCodeRequest{
+		Language: Command,
+		Code:     "sleep 0.5; echo alive",
+		Hooks:    noopHooks(),
+	}
+	req.Hooks.OnExecuteInit = func(string) {
+		_ = syscall.Kill(os.Getpid(), syscall.SIGPIPE)
+	}
+	req.Hooks.OnExecuteStdout = func(s string) { stdout = append(stdout, s) }
+	req.Hooks.OnExecuteError = func(err *execute.ErrorOutput) { t.Errorf("unexpected error hook: %+v", err) }
+
+	if err := NewController("", "").Execute(req); err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	assert.Equal(t, []string{"alive"}, stdout)
+}
@@ -0,0 +1,106 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// stdinWriteTimeout bounds how long WriteStdin waits for a process to drain
+// its stdin pipe before reporting ErrStdinBusy.
+var stdinWriteTimeout = 5 * time.Second
+
+// commandStdin serializes writes to a background command's stdin pipe.
+type commandStdin struct {
+	mu     sync.Mutex
+	w      *os.File
+	closed bool
+}
+
+func newCommandStdin() (*commandStdin, *os.File, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("create stdin pipe: %w", err)
+	}
+	return &commandStdin{w: w}, r, nil
+}
+
+func (s *commandStdin) write(data []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, ErrStdinClosed
+	}
+	if err := s.w.SetWriteDeadline(time.Now().Add(stdinWriteTimeout)); err != nil {
+		return 0, fmt.Errorf("set stdin deadline: %w", err)
+	}
+	n, err := s.w.Write(data)
+	switch {
+	case err == nil:
+		return n, nil
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return n, fmt.Errorf("%w: wrote %d of %d bytes", ErrStdinBusy, n, len(data))
+	case errors.Is(err, syscall.EPIPE), errors.Is(err, os.ErrClosed):
+		return n, ErrStdinClosed
+	default:
+		return n, err
+	}
+}
+
+func (s *commandStdin) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.w.Close()
+}
+
+// WriteStdin writes data to the stdin of a background command started with
+// Stdin set. It returns the number of bytes written; on ErrStdinBusy the
+// process stopped reading and the rest may be retried later.
+func (c *Controller) WriteStdin(session string, data []byte) (int, error) {
+	stdin, err := c.commandStdin(session)
+	if err != nil {
+		return 0, err
+	}
+	return stdin.write(data)
+}
+
+// CloseStdin closes a background command's stdin so it reads EOF.
+func (c *Controller) CloseStdin(session string) error {
+	stdin, err := c.commandStdin(session)
+	if err != nil {
+		return err
+	}
+	return stdin.close()
+}
+
+func (c *Controller) commandStdin(session string) (*commandStdin, error) {
+	kernel := c.commandSnapshot(session)
+	if kernel == nil {
+		return nil, fmt.Errorf("%w: %s", ErrCommandNotFound, session)
+	}
+	if kernel.stdin == nil {
+		return nil, ErrStdinUnavailable
+	}
+	return kernel.stdin, nil
+}
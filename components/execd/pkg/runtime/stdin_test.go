@@ -0,0 +1,133 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	goruntime "runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startStdinCommand(t *testing.T, c *Controller, code string, stdin bool) string {
+	t.Helper()
+	if goruntime.GOOS == "windows" {
+		t.Skip("bash not available on windows")
+	}
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found in PATH")
+	}
+	var session string
+	req := &ExecuteCodeRequest{
+		Language: BackgroundCommand,
+		Code:     code,
+		Stdin:    stdin,
+		Hooks:    noopHooks(),
+	}
+	req.Hooks.OnExecuteInit = func(id string) { session = id }
+	if err := c.runBackgroundCommand(context.Background(), req); err != nil {
+		t.Fatalf("runBackgroundCommand: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Interrupt(session) })
+	return session
+}
+
+func waitForOutput(t *testing.T, c *Controller, session, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		output, _, err := c.SeekBackgroundCommandOutput(session, 0)
+		if err == nil && strings.Contains(string(output), want) {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	output, _, _ := c.SeekBackgroundCommandOutput(session, 0)
+	t.Fatalf("timed out waiting for %q, output so far %q", want, output)
+}
+
+func TestWriteStdin_EchoesThroughBackgroundCommand(t *testing.T) {
+	c := NewController("", "")
+	session := startStdinCommand(t, c, `while read -r line; do echo "got:$line"; done; echo bye`, true)
+
+	for _, chunk := range []string{"hel", "lo\n", "world\n"} {
+		if n, err := c.WriteStdin(session, []byte(chunk)); err != nil || n != len(chunk) {
+			t.Fatalf("WriteStdin(%q) = %d, %v", chunk, n, err)
+		}
+	}
+	waitForOutput(t, c, session, "got:hello\ngot:world\n")
+
+	if err := c.CloseStdin(session); err != nil {
+		t.Fatalf("CloseStdin: %v", err)
+	}
+	waitForOutput(t, c, session, "bye")
+	if _, err := c.WriteStdin(session, []byte("late\n")); !errors.Is(err, ErrStdinClosed) {
+		t.Fatalf("expected ErrStdinClosed after close, got %v", err)
+	}
+}
+
+func TestWriteStdin_ProcessExitClosesStdin(t *testing.T) {
+	c := NewController("", "")
+	session := startStdinCommand(t, c, `read -r line; echo "once:$line"`, true)
+
+	if _, err := c.WriteStdin(session, []byte("a\n")); err != nil {
+		t.Fatalf("WriteStdin: %v", err)
+	}
+	waitForOutput(t, c, session, "once:a")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err := c.WriteStdin(session, []byte("b\n"))
+		if errors.Is(err, ErrStdinClosed) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected ErrStdinClosed once the process exited, got %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestWriteStdin_BackpressureWhenNotRead(t *testing.T) {
+	prev := stdinWriteTimeout
+	stdinWriteTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { stdinWriteTimeout = prev })
+
+	c := NewController("", "")
+	session := startStdinCommand(t, c, `sleep 30`, true)
+
+	chunk := make([]byte, 4<<20)
+	n, err := c.WriteStdin(session, chunk)
+	if !errors.Is(err, ErrStdinBusy) {
+		t.Fatalf("expected ErrStdinBusy when the pipe fills, got n=%d err=%v", n, err)
+	}
+	if n <= 0 || n >= len(chunk) {
+		t.Fatalf("expected a partial write up to the pipe buffer, got %d", n)
+	}
+}
+
+func TestWriteStdin_Unavailable(t *testing.T) {
+	c := NewController("", "")
+	session := startStdinCommand(t, c, `sleep 1`, false)
+	if _, err := c.WriteStdin(session, []byte("x")); !errors.Is(err, ErrStdinUnavailable) {
+		t.Fatalf("expected ErrStdinUnavailable, got %v", err)
+	}
+	if _, err := c.WriteStdin("missing", []byte("x")); !errors.Is(err, ErrCommandNotFound) {
+		t.Fatalf("expected ErrCommandNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+)
+
+const (
+	// defaultStopGracePeriod is how long a stopped command gets to exit after
+	// SIGTERM before it is killed.
+	defaultStopGracePeriod = 3 * time.Second
+	// stopPollInterval is how often stopped commands are checked for exit.
+	stopPollInterval = 20 * time.Millisecond
+)
+
+// StopResult is how stopping one session ended.
+type StopResult string
+
+const (
+	// StopTerminated means the command exited within the grace period.
+	StopTerminated StopResult = "terminated"
+	// StopKilled means the command outlived the grace period and got SIGKILL.
+	StopKilled StopResult = "killed"
+	// StopFinished means the command had already exited.
+	StopFinished StopResult = "finished"
+	// StopInterrupted means a code context's kernel was sent an interrupt.
+	StopInterrupted StopResult = "interrupted"
+	// StopNotFound means there is no such session.
+	StopNotFound StopResult = "not_found"
+	// StopFailed means the session could not be signalled; see Error.
+	StopFailed StopResult = "failed"
+)
+
+// StopOutcome reports what stopping one session did.
+type StopOutcome struct {
+	Session string     `json:"session"`
+	Result  StopResult `json:"result"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// SetStopGracePeriod sets how long stopped commands get to exit after
+// SIGTERM before they are killed. Zero or less restores the 3s default.
+func (c *Controller) SetStopGracePeriod(grace time.Duration) {
+	c.stopMu.Lock()
+	defer c.stopMu.Unlock()
+
+	c.stopGrace = grace
+}
+
+func (c *Controller) stopGracePeriod() time.Duration {
+	c.stopMu.Lock()
+	defer c.stopMu.Unlock()
+
+	if c.stopGrace <= 0 {
+		return defaultStopGracePeriod
+	}
+	return c.stopGrace
+}
+
+// StopSessions stops sessions all at once: every command's process group,
+// and its process tree if it has one, gets SIGTERM, then one grace period
+// shared by all of them passes, or less if they all exit sooner, and the
+// survivors get SIGKILL. A finished command whose tree still holds processes,
+// such as a daemon it double-forked, is stopped the same way. Stopping N commands
+// therefore takes about one grace period rather than N. Code contexts are
+// interrupted instead. A grace of zero or less uses SetStopGracePeriod's.
+// Outcomes are in the order of sessions.
+func (c *Controller) StopSessions(sessions []string, grace time.Duration) []StopOutcome {
+	if grace <= 0 {
+		grace = c.stopGracePeriod()
+	}
+	outcomes := make([]StopOutcome, len(sessions))
+	// pending maps a signalled command's pid to what is being stopped
+	pending := make(map[int]*stopTarget)
+	var wg sync.WaitGroup
+	for i, session := range sessions {
+		outcomes[i] = StopOutcome{Session: session}
+		if kernel := c.getJupyterKernel(session); kernel != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := kernel.client.InterruptKernel(kernel.kernelID); err != nil {
+					outcomes[i].Result, outcomes[i].Error = StopFailed, err.Error()
+					return
+				}
+				outcomes[i].Result = StopInterrupted
+			}()
+			continue
+		}
+		kernel := c.commandSnapshot(session)
+		switch {
+		case kernel == nil:
+			outcomes[i].Result = StopNotFound
+		case pending[kernel.pid] != nil:
+			pending[kernel.pid].indexes = append(pending[kernel.pid].indexes, i)
+		// a finished command may have left daemons behind in its tree
+		case (!kernel.running || kernel.pid <= 0) && !kernel.tree.populated():
+			outcomes[i].Result = StopFinished
+		default:
+			target := &stopTarget{pid: kernel.pid, running: kernel.running && kernel.pid > 0, tree: kernel.tree, indexes: []int{i}}
+			if err := target.terminate(); err != nil {
+				if !target.alive() {
+					outcomes[i].Result = StopFinished
+				} else {
+					outcomes[i].Result, outcomes[i].Error = StopFailed, err.Error()
+				}
+				continue
+			}
+			pending[kernel.pid] = target
+		}
+	}
+
+	if len(pending) > 0 {
+		log.Warning("stopping %d commands with a shared grace period of %s", len(pending), grace)
+	}
+	deadline := time.Now().Add(grace)
+	for len(pending) > 0 {
+		for pid, target := range pending {
+			if target.alive() {
+				continue
+			}
+			for _, i := range target.indexes {
+				outcomes[i].Result = StopTerminated
+			}
+			delete(pending, pid)
+		}
+		if len(pending) == 0 || !time.Now().Before(deadline) {
+			break
+		}
+		time.Sleep(min(stopPollInterval, time.Until(deadline)))
+	}
+	for _, target := range pending {
+		result, errMsg := StopKilled, ""
+		if err := target.kill(); err != nil && target.alive() {
+			result, errMsg = StopFailed, err.Error()
+		}
+		for _, i := range target.indexes {
+			outcomes[i].Result, outcomes[i].Error = result, errMsg
+		}
+	}
+	wg.Wait()
+	return outcomes
+}
+
+// stopTarget is one command being stopped, with the sessions it answers.
+// Signals go to its process group while it runs and to its process tree, if
+// it has one, which also holds the processes that left the group.
+type stopTarget struct {
+	pid     int
+	running bool
+	tree    *processTree
+	indexes []int
+}
+
+func (t *stopTarget) terminate() error {
+	return t.signal(terminateProcessGroup, (*processTree).terminate)
+}
+
+func (t *stopTarget) kill() error {
+	return t.signal(killProcessGroup, (*processTree).kill)
+}
+
+// signal reports only the failures that left something running.
+func (t *stopTarget) signal(group func(int) error, tree func(*processTree) error) error {
+	var errs []error
+	if t.running {
+		if err := group(t.pid); err != nil && processAlive(t.pid) {
+			errs = append(errs, err)
+		}
+	}
+	if err := tree(t.tree); err != nil && t.tree.populated() {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (t *stopTarget) alive() bool {
+	return (t.running && processAlive(t.pid)) || t.tree.populated()
+}
+
+// StopGroup stops every running command that was started with GroupID
+// group, as StopSessions does.
+func (c *Controller) StopGroup(group string, grace time.Duration) []StopOutcome {
+	return c.StopSessions(c.GroupCommands(group), grace)
+}
+
+// GroupCommands returns the sessions of the running commands that were
+// started with GroupID group, sorted.
+func (c *Controller) GroupCommands(group string) []string {
+	c.mu.RLock()
+	var sessions []string
+	for session, kernel := range c.commandClientMap {
+		if kernel != nil && kernel.running && kernel.group == group {
+			sessions = append(sessions, session)
+		}
+	}
+	c.mu.RUnlock()
+	sort.Strings(sessions)
+	return sessions
+}
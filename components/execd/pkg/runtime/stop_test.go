@@ -0,0 +1,87 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startGroup starts n background commands running code under group and
+// waits until they are all registered.
+func startGroup(t *testing.T, c *Controller, group, code string, n int) []string {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		req := &ExecuteCodeRequest{Language: BackgroundCommand, Code: code, GroupID: group, Hooks: noopHooks()}
+		require.NoError(t, c.runBackgroundCommand(context.Background(), req))
+	}
+	require.Eventually(t, func() bool { return len(c.GroupCommands(group)) == n }, 5*time.Second, 10*time.Millisecond)
+	// give the shells time to install their traps
+	time.Sleep(200 * time.Millisecond)
+	return c.GroupCommands(group)
+}
+
+func TestStopGroup_SharesOneGracePeriod(t *testing.T) {
+	skipWithoutBash(t)
+	c := NewController("", "")
+	startGroup(t, c, "stubborn", "trap '' TERM; sleep 30", 8)
+
+	grace := 300 * time.Millisecond
+	start := time.Now()
+	outcomes := c.StopGroup("stubborn", grace)
+	elapsed := time.Since(start)
+
+	require.Len(t, outcomes, 8)
+	for _, outcome := range outcomes {
+		assert.Equal(t, StopKilled, outcome.Result, outcome.Session)
+	}
+	assert.GreaterOrEqual(t, elapsed, grace)
+	assert.Less(t, elapsed, 3*grace, "commands must be stopped together, not one grace period each")
+	assert.Eventually(t, func() bool { return len(c.GroupCommands("stubborn")) == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestStopSessions_ReportsEachSession(t *testing.T) {
+	skipWithoutBash(t)
+	c := NewController("", "")
+	polite := startGroup(t, c, "polite", "sleep 30", 2)
+
+	start := time.Now()
+	outcomes := c.StopSessions(append(polite, "missing"), 5*time.Second)
+	assert.Less(t, time.Since(start), 2*time.Second, "commands that exit on SIGTERM are not waited for")
+	assert.Equal(t, []StopOutcome{
+		{Session: polite[0], Result: StopTerminated},
+		{Session: polite[1], Result: StopTerminated},
+		{Session: "missing", Result: StopNotFound},
+	}, outcomes)
+
+	require.Eventually(t, func() bool {
+		status, err := c.GetCommandStatus(polite[0])
+		return err == nil && !status.Running
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []StopOutcome{{Session: polite[0], Result: StopFinished}}, c.StopSessions(polite[:1], time.Second))
+}
+
+func TestSetStopGracePeriod(t *testing.T) {
+	c := NewController("", "")
+	assert.Equal(t, defaultStopGracePeriod, c.stopGracePeriod())
+	c.SetStopGracePeriod(time.Second)
+	assert.Equal(t, time.Second, c.stopGracePeriod())
+	c.SetStopGracePeriod(0)
+	assert.Equal(t, defaultStopGracePeriod, c.stopGracePeriod())
+}
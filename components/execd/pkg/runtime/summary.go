@@ -0,0 +1,229 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+var errSummaryBackground = errors.New("execution summary is only supported for foreground commands")
+
+// ExecutionSummary aggregates what an execution produced, so consumers need
+// not tally its events themselves.
+type ExecutionSummary struct {
+	// StdoutBytes and StderrBytes count what was written to each stream,
+	// line terminators included. For commands they are taken from the
+	// command's own output, so they hold however the output was coalesced
+	// or cut for delivery.
+	StdoutBytes int64 `json:"stdout_bytes"`
+	StderrBytes int64 `json:"stderr_bytes"`
+	// StdoutLines and StderrLines count lines, a final one without a
+	// terminator included.
+	StdoutLines int64 `json:"stdout_lines"`
+	StderrLines int64 `json:"stderr_lines"`
+	// Truncated is set when some of the output was not delivered, e.g.
+	// dropped by OutputRateLimit.
+	Truncated bool `json:"truncated,omitempty"`
+	// ExitCode and Signal are those of a command that was waited for.
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Signal   string `json:"signal,omitempty"`
+	// DurationMs is how long the execution ran.
+	DurationMs int64 `json:"duration_ms"`
+	// Usage is the command's resource usage, its waited-for children included.
+	Usage *ResourceUsage `json:"usage,omitempty"`
+}
+
+// ResourceUsage is the CPU and memory a command used.
+type ResourceUsage struct {
+	UserCPUMs   int64 `json:"user_cpu_ms"`
+	SystemCPUMs int64 `json:"system_cpu_ms"`
+	// MaxRSSBytes is the peak resident set size, where the platform reports it.
+	MaxRSSBytes int64 `json:"max_rss_bytes,omitempty"`
+}
+
+// reportCommandSummary hands a finished foreground command's figures to
+// OnExecuteSummary when the request asked for a summary. Backends call it
+// once, before the completion or error event; the summary wrapper completes
+// and forwards it.
+func reportCommandSummary(request *ExecuteCodeRequest, outcome ExitOutcome, state *os.ProcessState, stdoutPath, stderrPath string, truncated bool) {
+	if !request.Summary {
+		return
+	}
+	code := outcome.ExitCode
+	summary := &ExecutionSummary{
+		Truncated: truncated,
+		ExitCode:  &code,
+		Signal:    outcome.Signal,
+	}
+	summary.StdoutBytes, summary.StdoutLines = countOutputFile(stdoutPath)
+	summary.StderrBytes, summary.StderrLines = countOutputFile(stderrPath)
+	if state != nil {
+		summary.Usage = &ResourceUsage{
+			UserCPUMs:   state.UserTime().Milliseconds(),
+			SystemCPUMs: state.SystemTime().Milliseconds(),
+			MaxRSSBytes: maxRSSBytes(state),
+		}
+	}
+	callHook(request.Hooks.OnExecuteSummary, summary)
+}
+
+// countOutputFile counts the bytes and lines of a command's log file.
+func countOutputFile(path string) (int64, int64) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0
+	}
+	defer file.Close()
+	var size, lines int64
+	var last byte
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			size += int64(n)
+			lines += int64(bytes.Count(buf[:n], []byte{'\n'}))
+			last = buf[n-1]
+		}
+		if err != nil {
+			break
+		}
+	}
+	if size > 0 && last != '\n' {
+		lines++
+	}
+	return size, lines
+}
+
+// summaryStream tallies the output delivered on one stream.
+type summaryStream struct {
+	bytes, lines int64
+	// open is set while the last chunk left a line unterminated.
+	open bool
+}
+
+// add counts one event. Commands deliver one or more whole lines per event
+// without their terminators; other executions deliver raw chunks.
+func (s *summaryStream) add(text string, lineEvents bool) {
+	if lineEvents {
+		s.bytes += int64(len(text)) + 1
+		s.lines += int64(strings.Count(text, "\n")) + 1
+		return
+	}
+	if text == "" {
+		return
+	}
+	s.bytes += int64(len(text))
+	s.lines += int64(strings.Count(text, "\n"))
+	s.open = !strings.HasSuffix(text, "\n")
+}
+
+func (s *summaryStream) total() (int64, int64) {
+	if s.open {
+		return s.bytes, s.lines + 1
+	}
+	return s.bytes, s.lines
+}
+
+// executionSummarizer builds the ExecutionSummary of one execution.
+type executionSummarizer struct {
+	lineEvents bool
+
+	mu       sync.Mutex
+	started  time.Time
+	stdout   summaryStream
+	stderr   summaryStream
+	command  *ExecutionSummary // reported by the command backend
+	reported bool
+}
+
+// newExecutionSummarizer returns nil unless request asked for a summary.
+func newExecutionSummarizer(request *ExecuteCodeRequest) *executionSummarizer {
+	if !request.Summary {
+		return nil
+	}
+	return &executionSummarizer{lineEvents: request.Language == Command, started: time.Now()}
+}
+
+// result finishes the summary; it is only computed once. Delivered output
+// is only counted when the backend did not report the command's own.
+func (s *executionSummarizer) result(elapsed time.Duration) *ExecutionSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reported {
+		return nil
+	}
+	s.reported = true
+	summary := s.command
+	if summary == nil {
+		summary = &ExecutionSummary{}
+		summary.StdoutBytes, summary.StdoutLines = s.stdout.total()
+		summary.StderrBytes, summary.StderrLines = s.stderr.total()
+	}
+	summary.DurationMs = elapsed.Milliseconds()
+	return summary
+}
+
+// wrap tallies the output hooks and reports the summary through
+// OnExecuteSummary right before the execution's final event.
+func (s *executionSummarizer) wrap(hooks ExecuteResultHook) ExecuteResultHook {
+	wrapped := hooks
+	report := func(elapsed time.Duration) {
+		if summary := s.result(elapsed); summary != nil {
+			callHook(hooks.OnExecuteSummary, summary)
+		}
+	}
+	wrapped.OnExecuteInit = func(session string) {
+		s.mu.Lock()
+		s.started = time.Now()
+		s.mu.Unlock()
+		callHook(hooks.OnExecuteInit, session)
+	}
+	wrapped.OnExecuteStdout = func(text string) {
+		s.mu.Lock()
+		s.stdout.add(text, s.lineEvents)
+		s.mu.Unlock()
+		callHook(hooks.OnExecuteStdout, text)
+	}
+	wrapped.OnExecuteStderr = func(text string) {
+		s.mu.Lock()
+		s.stderr.add(text, s.lineEvents)
+		s.mu.Unlock()
+		callHook(hooks.OnExecuteStderr, text)
+	}
+	wrapped.OnExecuteSummary = func(summary *ExecutionSummary) {
+		s.mu.Lock()
+		s.command = summary
+		s.mu.Unlock()
+	}
+	wrapped.OnExecuteError = func(err *execute.ErrorOutput) {
+		s.mu.Lock()
+		elapsed := time.Since(s.started)
+		s.mu.Unlock()
+		report(elapsed)
+		callHook(hooks.OnExecuteError, err)
+	}
+	wrapped.OnExecuteComplete = func(elapsed time.Duration) {
+		report(elapsed)
+		callHook(hooks.OnExecuteComplete, elapsed)
+	}
+	return wrapped
+}
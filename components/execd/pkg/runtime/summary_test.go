@@ -0,0 +1,120 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+func TestExecutionSummary_CountsDeliveredChunks(t *testing.T) {
+	var summary *ExecutionSummary
+	s := newExecutionSummarizer(&ExecuteCodeRequest{Language: Python, Summary: true})
+	hooks := s.wrap(ExecuteResultHook{OnExecuteSummary: func(r *ExecutionSummary) { summary = r }})
+	// a kernel streams raw chunks that need not end at a line
+	for _, chunk := range []string{"hel", "lo\nwor", "ld\n", "", "tail"} {
+		hooks.OnExecuteStdout(chunk)
+	}
+	hooks.OnExecuteStderr("warn\n")
+	hooks.OnExecuteComplete(1500 * time.Millisecond)
+	hooks.OnExecuteComplete(time.Second)
+
+	require.NotNil(t, summary)
+	assert.Equal(t, &ExecutionSummary{
+		StdoutBytes: int64(len("hello\nworld\ntail")),
+		StdoutLines: 3,
+		StderrBytes: 5,
+		StderrLines: 1,
+		DurationMs:  1500,
+	}, summary)
+}
+
+func TestCountOutputFile(t *testing.T) {
+	dir := t.TempDir()
+	for content, lines := range map[string]int64{"": 0, "a\n\nb\n": 3, "a\nb": 2} {
+		path := filepath.Join(dir, "log")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		size, got := countOutputFile(path)
+		assert.Equal(t, int64(len(content)), size, "%q", content)
+		assert.Equal(t, lines, got, "%q", content)
+	}
+	size, lines := countOutputFile(filepath.Join(dir, "missing"))
+	assert.Zero(t, size+lines)
+}
+
+func TestRunCommand_Summary(t *testing.T) {
+	skipWithoutBash(t)
+	run := func(code string) (*ExecutionSummary, []string, int64) {
+		var summary *ExecutionSummary
+		var events []string
+		var delivered int64
+		req := &ExecuteCodeRequest{
+			Language: Command,
+			Code:     code,
+			Summary:  true,
+			// batching changes the events, not the totals
+			OutputCoalesce: &OutputCoalesce{MinInterval: time.Hour, MaxInterval: time.Hour},
+			Hooks:          noopHooks(),
+		}
+		req.Hooks.OnExecuteStdout = func(text string) { delivered += int64(len(text)) }
+		req.Hooks.OnExecuteSummary = func(r *ExecutionSummary) {
+			events = append(events, "summary")
+			summary = r
+		}
+		req.Hooks.OnExecuteComplete = func(time.Duration) { events = append(events, "complete") }
+		req.Hooks.OnExecuteError = func(*execute.ErrorOutput) { events = append(events, "error") }
+		require.NoError(t, NewController("", "").Execute(req))
+		return summary, events, delivered
+	}
+
+	// coalesced output is delivered as written, empty line included
+	summary, events, delivered := run(`printf 'one\n\nthree\n'; printf 'partial'; printf 'oops\n' >&2`)
+	assert.Equal(t, []string{"summary", "complete"}, events)
+	require.NotNil(t, summary)
+	assert.Equal(t, int64(len("one\n\nthree\npartial")), summary.StdoutBytes)
+	assert.Equal(t, int64(4), summary.StdoutLines)
+	assert.Equal(t, int64(5), summary.StderrBytes)
+	assert.Equal(t, int64(1), summary.StderrLines)
+	assert.Equal(t, summary.StdoutBytes, delivered)
+	assert.False(t, summary.Truncated)
+	require.NotNil(t, summary.ExitCode)
+	assert.Equal(t, 0, *summary.ExitCode)
+	assert.NotNil(t, summary.Usage)
+
+	summary, events, _ = run("echo failing; exit 3")
+	assert.Equal(t, []string{"summary", "error"}, events, "a failed run still reports its summary")
+	require.NotNil(t, summary.ExitCode)
+	assert.Equal(t, 3, *summary.ExitCode)
+	assert.Equal(t, int64(8), summary.StdoutBytes)
+}
+
+func TestRunBackgroundCommand_RejectsSummary(t *testing.T) {
+	skipWithoutBash(t)
+	err := NewController("", "").Execute(&ExecuteCodeRequest{
+		Language: BackgroundCommand,
+		Code:     "true",
+		Summary:  true,
+		Hooks:    noopHooks(),
+	})
+	assert.True(t, errors.Is(err, errSummaryBackground), "got %v", err)
+}
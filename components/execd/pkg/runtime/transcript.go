@@ -0,0 +1,374 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	goruntime "runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+)
+
+const (
+	// maxTranscriptStream bounds each recorded output stream.
+	maxTranscriptStream = 1 << 20
+
+	maskedValue = "******"
+)
+
+// secretKeyPattern matches env names whose values are masked in transcripts.
+var secretKeyPattern = regexp.MustCompile(`(?i)(secret|token|passw(or)?d|api_?key|access_?key|private_?key|credential)`)
+
+// Transcript is a self-contained record of one execution.
+type Transcript struct {
+	ID              string               `json:"id"`
+	Session         string               `json:"session,omitempty"`
+	Language        Language             `json:"language"`
+	Code            string               `json:"code"`
+	Cwd             string               `json:"cwd,omitempty"`
+	Env             TranscriptEnv        `json:"env"`
+	Stdout          string               `json:"stdout"`
+	Stderr          string               `json:"stderr"`
+	Results         []map[string]any     `json:"results,omitempty"`
+	OutputTruncated bool                 `json:"output_truncated,omitempty"`
+	ExitCode        *int                 `json:"exit_code,omitempty"`
+	Error           *execute.ErrorOutput `json:"error,omitempty"`
+	StartedAt       time.Time            `json:"started_at"`
+	FinishedAt      time.Time            `json:"finished_at"`
+	DurationMs      int64                `json:"duration_ms"`
+	Metadata        map[string]string    `json:"metadata,omitempty"`
+	DryRun          *ResolvedCommand     `json:"dry_run,omitempty"`
+}
+
+// TranscriptEnv summarizes the environment by name only; values are never recorded.
+type TranscriptEnv struct {
+	RequestKeys  []string `json:"request_keys,omitempty"`
+	ExtraEnvKeys []string `json:"extra_env_keys,omitempty"`
+	MaskedKeys   []string `json:"masked_keys,omitempty"`
+}
+
+// SetTranscriptDir writes a JSON transcript of every execution into dir.
+// Empty disables writing.
+func (c *Controller) SetTranscriptDir(dir string) {
+	c.transcriptMu.Lock()
+	defer c.transcriptMu.Unlock()
+
+	c.transcriptDir = dir
+}
+
+// SetTranscriptHandler registers a callback receiving each finished transcript.
+func (c *Controller) SetTranscriptHandler(fn func(*Transcript)) {
+	c.transcriptMu.Lock()
+	defer c.transcriptMu.Unlock()
+
+	c.onTranscript = fn
+}
+
+type transcriptRecorder struct {
+	mu     sync.Mutex
+	t      *Transcript
+	stdout strings.Builder
+	stderr strings.Builder
+	// lines is set for commands, whose output arrives one line at a time
+	// with the terminator stripped.
+	lines   bool
+	secrets []string
+	dir     string
+	handler func(*Transcript)
+}
+
+// newTranscriptRecorder returns nil when transcripts are disabled.
+func (c *Controller) newTranscriptRecorder(request *ExecuteCodeRequest) *transcriptRecorder {
+	c.transcriptMu.Lock()
+	dir, handler := c.transcriptDir, c.onTranscript
+	c.transcriptMu.Unlock()
+	if dir == "" && handler == nil {
+		return nil
+	}
+
+	extraEnv := loadExtraEnvFromFile()
+	rec := &transcriptRecorder{
+		t: &Transcript{
+			ID:        c.newContextID(),
+			Session:   request.Context,
+			Language:  request.Language,
+			Code:      request.Code,
+			Cwd:       request.Cwd,
+			StartedAt: time.Now(),
+			Metadata: map[string]string{
+				"os":   goruntime.GOOS,
+				"arch": goruntime.GOARCH,
+			},
+		},
+		lines:   request.Language == Command || request.Language == BackgroundCommand,
+		dir:     dir,
+		handler: handler,
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		rec.t.Metadata["hostname"] = hostname
+	}
+	if request.Timeout > 0 {
+		rec.t.Metadata["timeout"] = request.Timeout.String()
+	}
+
+	rec.t.Env.RequestKeys = rec.collectEnv(request.Envs)
+	rec.t.Env.ExtraEnvKeys = rec.collectEnv(extraEnv)
+	sort.Strings(rec.t.Env.MaskedKeys)
+	return rec
+}
+
+// collectEnv returns the sorted names of env and remembers secret-looking values for masking.
+func (r *transcriptRecorder) collectEnv(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k, v := range env {
+		keys = append(keys, k)
+		if secretKeyPattern.MatchString(k) && v != "" {
+			r.secrets = append(r.secrets, v)
+			r.t.Env.MaskedKeys = append(r.t.Env.MaskedKeys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// wrap tees the request hooks into the recorder.
+func (r *transcriptRecorder) wrap(hooks ExecuteResultHook) ExecuteResultHook {
+	wrapped := hooks
+	wrapped.OnExecuteInit = func(session string) {
+		r.mu.Lock()
+		r.t.Session = session
+		r.mu.Unlock()
+		if hooks.OnExecuteInit != nil {
+			hooks.OnExecuteInit(session)
+		}
+	}
+	wrapped.OnExecuteStdout = func(text string) {
+		r.appendStream(&r.stdout, text)
+		if hooks.OnExecuteStdout != nil {
+			hooks.OnExecuteStdout(text)
+		}
+	}
+	wrapped.OnExecuteStderr = func(text string) {
+		r.appendStream(&r.stderr, text)
+		if hooks.OnExecuteStderr != nil {
+			hooks.OnExecuteStderr(text)
+		}
+	}
+	wrapped.OnExecuteResult = func(result map[string]any, count int) {
+		r.mu.Lock()
+		r.t.Results = append(r.t.Results, result)
+		r.mu.Unlock()
+		if hooks.OnExecuteResult != nil {
+			hooks.OnExecuteResult(result, count)
+		}
+	}
+	wrapped.OnExecuteError = func(err *execute.ErrorOutput) {
+		r.mu.Lock()
+		r.t.Error = err
+		r.mu.Unlock()
+		if hooks.OnExecuteError != nil {
+			hooks.OnExecuteError(err)
+		}
+	}
+	wrapped.OnExecuteDryRun = func(resolved *ResolvedCommand) {
+		r.mu.Lock()
+		r.t.DryRun = resolved
+		r.mu.Unlock()
+		if hooks.OnExecuteDryRun != nil {
+			hooks.OnExecuteDryRun(resolved)
+		}
+	}
+	return wrapped
+}
+
+// appendStream records a chunk of output as it arrived; a command's line
+// gets back its terminator.
+func (r *transcriptRecorder) appendStream(b *strings.Builder, text string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.lines {
+		text += "\n"
+	}
+	if room := maxTranscriptStream - b.Len(); len(text) > room {
+		text = text[:runeBoundary(text, max(room, 0))]
+		r.t.OutputTruncated = true
+	}
+	b.WriteString(text)
+}
+
+// runeBoundary returns the largest index not above n at which s can be cut
+// without splitting a UTF-8 sequence.
+func runeBoundary(s string, n int) int {
+	if n >= len(s) {
+		return len(s)
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return n
+}
+
+// recordBackgroundOutput fills the transcript of a background command from
+// the file its output goes to instead of the hooks. Stdout and stderr are
+// interleaved there, so both end up in Stdout.
+func (c *Controller) recordBackgroundOutput(r *transcriptRecorder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.t.Language != BackgroundCommand || r.t.Session == "" || r.stdout.Len() > 0 {
+		return
+	}
+	file, err := os.Open(c.combinedOutputFileName(r.t.Session))
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxTranscriptStream+1))
+	if err != nil {
+		return
+	}
+	text := string(data)
+	if len(text) > maxTranscriptStream {
+		text = text[:runeBoundary(text, maxTranscriptStream)]
+		r.t.OutputTruncated = true
+	}
+	r.stdout.WriteString(text)
+}
+
+// finishTranscript completes the transcript and delivers it. For a background
+// command it runs once the process has exited.
+func (c *Controller) finishTranscript(r *transcriptRecorder, execErr error) {
+	c.recordBackgroundOutput(r)
+	r.mu.Lock()
+	t := r.t
+	t.FinishedAt = time.Now()
+	t.DurationMs = t.FinishedAt.Sub(t.StartedAt).Milliseconds()
+	t.Stdout = r.stdout.String()
+	t.Stderr = r.stderr.String()
+	if execErr != nil {
+		t.Metadata["executeError"] = execErr.Error()
+	}
+	r.mu.Unlock()
+
+	if t.Language == Command || t.Language == BackgroundCommand {
+		if kernel := c.commandSnapshot(t.Session); kernel != nil && kernel.exitCode != nil {
+			code := *kernel.exitCode
+			t.ExitCode = &code
+		}
+	}
+	r.mask(t)
+
+	if r.dir != "" {
+		if err := writeTranscript(r.dir, t); err != nil {
+			log.Warning("failed to write transcript %s: %v", t.ID, err)
+		}
+	}
+	if r.handler != nil {
+		r.handler(t)
+	}
+}
+
+// mask replaces secret env values anywhere they were echoed.
+func (r *transcriptRecorder) mask(t *Transcript) {
+	if len(r.secrets) == 0 {
+		return
+	}
+	// replace longer values first so one secret containing another is fully hidden.
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+	maskString := func(s string) string {
+		for _, secret := range r.secrets {
+			s = strings.ReplaceAll(s, secret, maskedValue)
+		}
+		return s
+	}
+
+	t.Code = maskString(t.Code)
+	t.Stdout = maskString(t.Stdout)
+	t.Stderr = maskString(t.Stderr)
+	if msg, ok := t.Metadata["executeError"]; ok {
+		t.Metadata["executeError"] = maskString(msg)
+	}
+	for i, result := range t.Results {
+		t.Results[i] = maskValue(result, maskString).(map[string]any)
+	}
+	if t.Error != nil {
+		masked := *t.Error
+		masked.EValue = maskString(masked.EValue)
+		masked.Traceback = make([]string, len(t.Error.Traceback))
+		for i, line := range t.Error.Traceback {
+			masked.Traceback[i] = maskString(line)
+		}
+		t.Error = &masked
+	}
+	if t.DryRun != nil {
+		masked := *t.DryRun
+		masked.Argv = make([]string, len(t.DryRun.Argv))
+		for i, arg := range t.DryRun.Argv {
+			masked.Argv[i] = maskString(arg)
+		}
+		t.DryRun = &masked
+	}
+}
+
+// maskValue returns a copy of v with every string masked.
+func maskValue(v any, maskString func(string) string) any {
+	switch val := v.(type) {
+	case string:
+		return maskString(val)
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			out[k] = maskValue(item, maskString)
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = maskValue(item, maskString)
+		}
+		return out
+	case []string:
+		out := make([]string, len(val))
+		for i, item := range val {
+			out[i] = maskString(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func writeTranscript(dir string, t *Transcript) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal transcript: %w", err)
+	}
+	return os.WriteFile(filepath.Join(dir, t.ID+".json"), data, 0o600)
+}
@@ -0,0 +1,261 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+func noopHooks() ExecuteResultHook {
+	return ExecuteResultHook{
+		OnExecuteInit:     func(string) {},
+		OnExecuteStdout:   func(string) {},
+		OnExecuteStderr:   func(string) {},
+		OnExecuteError:    func(*execute.ErrorOutput) {},
+		OnExecuteComplete: func(time.Duration) {},
+		OnExecuteDryRun:   func(*ResolvedCommand) {},
+	}
+}
+
+func TestTranscript_CommandIsCompleteAndMasked(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("bash not available on windows")
+	}
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found in PATH")
+	}
+
+	const secret = "s3cr3t-value-123"
+	envFile := filepath.Join(t.TempDir(), "env")
+	if err := os.WriteFile(envFile, []byte("API_TOKEN="+secret+"\nREGION=cn-hangzhou\n"), 0o644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	t.Setenv("EXECD_ENVS", envFile)
+
+	dir := filepath.Join(t.TempDir(), "transcripts")
+	c := NewController("", "")
+	c.SetTranscriptDir(dir)
+	var delivered *Transcript
+	c.SetTranscriptHandler(func(tr *Transcript) { delivered = tr })
+
+	cwd := t.TempDir()
+	req := &ExecuteCodeRequest{
+		Language: Command,
+		Code:     `echo "token=$API_TOKEN"; echo "warn" 1>&2; exit 3`,
+		Cwd:      cwd,
+		Timeout:  5 * time.Second,
+		Hooks:    noopHooks(),
+	}
+	var streamed []string
+	req.Hooks.OnExecuteStdout = func(s string) { streamed = append(streamed, s) }
+
+	if err := c.Execute(req); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	// The caller still sees the real output; only the transcript is masked.
+	if len(streamed) != 1 || streamed[0] != "token="+secret {
+		t.Fatalf("unexpected streamed stdout: %#v", streamed)
+	}
+	if delivered == nil {
+		t.Fatalf("expected transcript handler to be called")
+	}
+
+	path := filepath.Join(dir, delivered.ID+".json")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("transcript file: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected 0600 transcript, got %v", info.Mode().Perm())
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read transcript: %v", err)
+	}
+	if strings.Contains(string(raw), secret) {
+		t.Fatalf("transcript leaks the secret value:\n%s", raw)
+	}
+
+	var got Transcript
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("decode transcript: %v", err)
+	}
+	if got.Language != Command || got.Code != req.Code || got.Cwd != cwd || got.Session == "" {
+		t.Fatalf("missing request details: %+v", got)
+	}
+	if got.Stdout != "token="+maskedValue+"\n" || got.Stderr != "warn\n" {
+		t.Fatalf("unexpected output stdout=%q stderr=%q", got.Stdout, got.Stderr)
+	}
+	if got.ExitCode == nil || *got.ExitCode != 3 {
+		t.Fatalf("expected exit code 3, got %v", got.ExitCode)
+	}
+	if got.Error == nil || got.Error.EName != "CommandExecError" {
+		t.Fatalf("expected command error, got %+v", got.Error)
+	}
+	if strings.Join(got.Env.ExtraEnvKeys, ",") != "API_TOKEN,REGION" || strings.Join(got.Env.MaskedKeys, ",") != "API_TOKEN" {
+		t.Fatalf("unexpected env summary: %+v", got.Env)
+	}
+	if got.StartedAt.IsZero() || got.FinishedAt.Before(got.StartedAt) || got.DurationMs < 0 {
+		t.Fatalf("bad timing: start=%v finish=%v duration=%d", got.StartedAt, got.FinishedAt, got.DurationMs)
+	}
+	if got.Metadata["os"] != goruntime.GOOS || got.Metadata["timeout"] != "5s" {
+		t.Fatalf("unexpected metadata: %v", got.Metadata)
+	}
+}
+
+func TestTranscript_DisabledByDefault(t *testing.T) {
+	c := NewController("", "")
+	if rec := c.newTranscriptRecorder(&ExecuteCodeRequest{Language: Command}); rec != nil {
+		t.Fatalf("expected no recorder without a dir or handler")
+	}
+}
+
+func TestTranscript_MasksResultsAndTraceback(t *testing.T) {
+	c := NewController("", "")
+	c.SetTranscriptHandler(func(*Transcript) {})
+
+	req := &ExecuteCodeRequest{
+		Language: Python,
+		Code:     "print(os.environ['DB_PASSWORD'])",
+		Envs:     map[string]string{"DB_PASSWORD": "hunter2hunter2", "HOME": "/root"},
+	}
+	rec := c.newTranscriptRecorder(req)
+	hooks := rec.wrap(ExecuteResultHook{})
+	hooks.OnExecuteInit("ctx-1")
+	hooks.OnExecuteResult(map[string]any{"text/plain": "'hunter2hunter2'"}, 1)
+	hooks.OnExecuteError(&execute.ErrorOutput{
+		EName:     "ValueError",
+		EValue:    "bad hunter2hunter2",
+		Traceback: []string{"ValueError: bad hunter2hunter2"},
+	})
+
+	var got *Transcript
+	rec.handler = func(tr *Transcript) { got = tr }
+	c.finishTranscript(rec, nil)
+
+	if got.Session != "ctx-1" || got.ExitCode != nil {
+		t.Fatalf("unexpected session/exit code: %q %v", got.Session, got.ExitCode)
+	}
+	if got.Results[0]["text/plain"] != "'"+maskedValue+"'" {
+		t.Fatalf("result not masked: %v", got.Results)
+	}
+	if got.Error.EValue != "bad "+maskedValue || got.Error.Traceback[0] != "ValueError: bad "+maskedValue {
+		t.Fatalf("error not masked: %+v", got.Error)
+	}
+	if strings.Join(got.Env.RequestKeys, ",") != "DB_PASSWORD,HOME" {
+		t.Fatalf("unexpected request env keys: %v", got.Env.RequestKeys)
+	}
+}
+
+func TestTranscript_TruncatesLargeOutput(t *testing.T) {
+	c := NewController("", "")
+	c.SetTranscriptHandler(func(*Transcript) {})
+	rec := c.newTranscriptRecorder(&ExecuteCodeRequest{Language: Command})
+	hooks := rec.wrap(ExecuteResultHook{})
+
+	line := strings.Repeat("x", 64*1024)
+	for i := 0; i < 20; i++ {
+		hooks.OnExecuteStdout(line)
+	}
+
+	var got *Transcript
+	rec.handler = func(tr *Transcript) { got = tr }
+	c.finishTranscript(rec, nil)
+	if !got.OutputTruncated || len(got.Stdout) != maxTranscriptStream {
+		t.Fatalf("expected stdout capped at %d bytes, got %d (truncated=%v)", maxTranscriptStream, len(got.Stdout), got.OutputTruncated)
+	}
+}
+
+func TestTranscript_KeepsChunksVerbatim(t *testing.T) {
+	c := NewController("", "")
+	c.SetTranscriptHandler(func(*Transcript) {})
+	rec := c.newTranscriptRecorder(&ExecuteCodeRequest{Language: Python})
+	hooks := rec.wrap(ExecuteResultHook{})
+	hooks.OnExecuteStdout("progress: 10%")
+	hooks.OnExecuteStdout(" 20%\n")
+	hooks.OnExecuteStdout("done\n")
+
+	var got *Transcript
+	rec.handler = func(tr *Transcript) { got = tr }
+	c.finishTranscript(rec, nil)
+	if got.Stdout != "progress: 10% 20%\ndone\n" {
+		t.Fatalf("unexpected stdout %q", got.Stdout)
+	}
+}
+
+func TestTranscript_TruncatesOnRuneBoundary(t *testing.T) {
+	c := NewController("", "")
+	c.SetTranscriptHandler(func(*Transcript) {})
+	rec := c.newTranscriptRecorder(&ExecuteCodeRequest{Language: Python})
+	hooks := rec.wrap(ExecuteResultHook{})
+	hooks.OnExecuteStdout(strings.Repeat("x", maxTranscriptStream-1))
+	hooks.OnExecuteStdout("€uro")
+
+	var got *Transcript
+	rec.handler = func(tr *Transcript) { got = tr }
+	c.finishTranscript(rec, nil)
+	if !got.OutputTruncated || len(got.Stdout) != maxTranscriptStream-1 || !utf8.ValidString(got.Stdout) {
+		t.Fatalf("expected stdout cut before the multi-byte rune, got %d bytes (truncated=%v)", len(got.Stdout), got.OutputTruncated)
+	}
+}
+
+func TestTranscript_BackgroundCommandFinishesOnExit(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("bash not available on windows")
+	}
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found in PATH")
+	}
+
+	c := NewController("", "")
+	delivered := make(chan *Transcript, 1)
+	c.SetTranscriptHandler(func(tr *Transcript) { delivered <- tr })
+	req := &ExecuteCodeRequest{
+		Language: BackgroundCommand,
+		Code:     "echo started; sleep 0.3; echo finished; exit 4",
+		Hooks:    noopHooks(),
+	}
+	if err := c.Execute(req); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	select {
+	case <-delivered:
+		t.Fatalf("transcript delivered before the process exited")
+	default:
+	}
+
+	select {
+	case got := <-delivered:
+		if got.Stdout != "started\nfinished\n" {
+			t.Fatalf("unexpected stdout %q", got.Stdout)
+		}
+		if got.ExitCode == nil || *got.ExitCode != 4 {
+			t.Fatalf("expected exit code 4, got %v", got.ExitCode)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no transcript after the process exited")
+	}
+}
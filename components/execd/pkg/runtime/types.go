@@ -30,6 +30,36 @@ type ExecuteResultHook struct {
 	OnExecuteStderr   func(stderr string) //nolint:predeclared
 	OnExecuteError    func(err *execute.ErrorOutput)
 	OnExecuteComplete func(executionTime time.Duration)
+	OnExecuteDryRun   func(resolved *ResolvedCommand)
+	// OnExecuteOutputSink reports where output was stored, right before OnExecuteComplete.
+	OnExecuteOutputSink func(result *OutputSinkResult)
+	// OnExecuteArtifacts reports the artifacts manifest, right before
+	// OnExecuteComplete or OnExecuteError.
+	OnExecuteArtifacts func(manifest *ArtifactManifest)
+	// OnExecuteExitCategory reports the request's Classify verdict, right
+	// before OnExecuteComplete or OnExecuteError.
+	OnExecuteExitCategory func(category ExitCategory)
+	// OnExecutePostCommand reports the post command's result, right before
+	// OnExecuteComplete or OnExecuteError.
+	OnExecutePostCommand func(result *PostCommandResult)
+	// OnExecuteValue reports the value of a Jupyter cell's last expression,
+	// from its execute_result, with the cell's execution count and the value's
+	// MIME bundle and metadata. It is kept apart from what the cell printed,
+	// and is called right after OnExecuteResult reports the same bundle.
+	OnExecuteValue func(value *execute.ExecuteResult)
+	// OnExecuteOutputDigest reports the request's OutputDigest result, right
+	// before OnExecuteComplete or OnExecuteError.
+	OnExecuteOutputDigest func(result *OutputDigestResult)
+	// OnExecuteSummary reports the request's ExecutionSummary, right before
+	// OnExecuteComplete or OnExecuteError.
+	OnExecuteSummary func(summary *ExecutionSummary)
+	// OnExecuteWorkdirDiff reports the request's WorkdirDiff changes, right
+	// before OnExecuteComplete or OnExecuteError.
+	OnExecuteWorkdirDiff func(changes *WorkdirChanges)
+	// OnExecuteWarnings reports the non-fatal conditions met while running,
+	// right before OnExecuteComplete or OnExecuteError; not called when
+	// there are none.
+	OnExecuteWarnings func(warnings []ExecutionWarning)
 }
 
 // ExecuteCodeRequest represents a code execution request with context and hooks.
@@ -40,7 +70,135 @@ type ExecuteCodeRequest struct {
 	Timeout  time.Duration     `json:"timeout"`
 	Cwd      string            `json:"cwd"`
 	Envs     map[string]string `json:"envs"`
-	Hooks    ExecuteResultHook
+	// DryRun resolves the command invocation and reports it through
+	// OnExecuteDryRun without starting a process. Only honored for commands.
+	DryRun bool `json:"dry_run"`
+	// GroupID is the tenant a command is queued under when the concurrency
+	// limit is reached; groups share slots by weight. Empty is a group of its own.
+	GroupID string `json:"group_id"`
+	// Lane names the command lane a command belongs to: commands of one
+	// lane run at most SetMaxCommandsPerLane at a time, the rest waiting in
+	// order. Empty runs the command on its own. Foreground commands only.
+	Lane string `json:"lane,omitempty"`
+	// ScratchMB mounts a private tmpfs of this many MiB for the command,
+	// exported as TMPDIR and used as Cwd when none is given. Linux only.
+	ScratchMB int `json:"scratch_mb"`
+	// ReadOnlyFS runs the command in a private mount namespace where every
+	// mount is read-only except its scratch space, or a temporary directory
+	// exported as TMPDIR when it has none. Linux only; needs CAP_SYS_ADMIN.
+	ReadOnlyFS bool `json:"read_only_fs"`
+	// Stdin keeps a background command's stdin open for WriteStdin instead
+	// of attaching it to /dev/null.
+	Stdin bool `json:"stdin"`
+	// RLimitNofile caps the command's open file descriptors (soft and hard
+	// limit). Zero inherits execd's limit. Linux only.
+	RLimitNofile uint64 `json:"rlimit_nofile"`
+	// Nice sets the command's CPU scheduling niceness (-20 to 19);
+	// zero inherits execd's. Linux only, ignored elsewhere.
+	Nice int `json:"nice"`
+	// IOPriority sets the command's I/O scheduling class and level, e.g.
+	// "idle" or "best-effort:7"; empty inherits execd's. Linux only.
+	IOPriority string `json:"io_priority"`
+	// Umask is the octal file mode creation mask, e.g. "077", set in the
+	// shell before Code runs; empty inherits execd's. Ignored on Windows.
+	Umask string `json:"umask"`
+	// Locale sets LC_ALL and LANG, e.g. "C.UTF-8", and Timezone sets TZ,
+	// e.g. "UTC", in the command's environment; empty inherits execd's.
+	// Only honored for commands.
+	Locale   string `json:"locale,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+	// OutputSink uploads stdout and stderr to object storage. Foreground
+	// commands only.
+	OutputSink *OutputSink `json:"output_sink,omitempty"`
+	// Wasm runs Code, a WebAssembly module path followed by its arguments,
+	// under the WASI runtime instead of a shell.
+	Wasm bool `json:"wasm"`
+	// OutputRateLimit paces stdout and stderr events to this many bytes per
+	// second. Output further behind than a few seconds is dropped with a
+	// marker line. Zero is unlimited. Foreground commands only.
+	OutputRateLimit int `json:"output_rate_limit"`
+	// Artifacts lists the files a successful command produced under its
+	// working directory. Foreground commands only.
+	Artifacts *ArtifactScan `json:"artifacts,omitempty"`
+	// CACerts are PEM certificates trusted by the command in addition to
+	// the system store: they are written with it to a temp file that
+	// SSL_CERT_FILE, REQUESTS_CA_BUNDLE and NODE_EXTRA_CA_CERTS point at,
+	// removed when the command ends. Only honored for commands.
+	CACerts string `json:"ca_certs,omitempty"`
+	// OutputHighWatermark applies backpressure: once this many bytes of a
+	// stream are written but not yet delivered to the hooks, the command
+	// blocks in its writes until delivery catches up. Lines longer than it
+	// are delivered in pieces. Zero buffers without limit in the log files.
+	// Foreground commands only; not supported on Windows.
+	OutputHighWatermark int `json:"output_high_watermark"`
+	// OutputEncoding names the encoding stdout and stderr are written in,
+	// e.g. "gbk" or "shift_jis"; lines are converted to UTF-8 before
+	// delivery. Empty passes output through. Foreground commands only.
+	OutputEncoding string `json:"output_encoding,omitempty"`
+	// Classify, if set, categorizes how the command ended for
+	// OnExecuteExitCategory. Foreground commands only; not called when the
+	// command fails to start.
+	Classify Classifier `json:"-"`
+	// PostCommand runs after the command ends, however it ends, including
+	// at Timeout or on Interrupt. Foreground commands only.
+	PostCommand *PostCommand `json:"post_command,omitempty"`
+	// OutputCoalesce batches stdout and stderr lines into fewer events;
+	// nil delivers every line as its own event. Foreground commands only.
+	OutputCoalesce *OutputCoalesce `json:"output_coalesce,omitempty"`
+	// OutputDigest hashes stdout and stderr for OnExecuteOutputDigest.
+	// Foreground executions only.
+	OutputDigest *OutputDigest `json:"output_digest,omitempty"`
+	// CancelOnDisconnect stops the execution, as its Timeout would, once the
+	// context passed to ExecuteContext is done, e.g. because the client
+	// streaming its output went away. Otherwise the execution runs to its
+	// end with nobody listening. Foreground executions only.
+	CancelOnDisconnect bool `json:"cancel_on_disconnect"`
+	// Summary reports output, exit and resource figures through
+	// OnExecuteSummary. Foreground executions only.
+	Summary bool `json:"summary"`
+	// WorkdirDiff snapshots the working directory before the command starts
+	// and reports what it changed through OnExecuteWorkdirDiff, however it
+	// ends. Foreground commands only.
+	WorkdirDiff *WorkdirDiff `json:"workdir_diff,omitempty"`
+	// RedactSecrets replaces the values of secret-looking env vars, from
+	// Envs and EXECD_ENVS, with "***" wherever stdout, stderr, results or
+	// errors echo them. Best-effort: encoded or transformed values are not
+	// caught. Foreground executions only.
+	RedactSecrets bool              `json:"redact_secrets"`
+	Hooks         ExecuteResultHook `json:"-"`
+
+	// warnings collects what Warn reports; nil outside ExecuteContext.
+	warnings *executionWarnings
+	// redactor masks secrets in the output; nil unless RedactSecrets.
+	redactor *outputRedactor
+	// silent runs the code without recording it in the kernel's history,
+	// for execd's own housekeeping cells.
+	silent bool
+	// exited is closed once a background command's process has exited; nil
+	// unless runBackgroundCommand started one.
+	exited chan struct{}
+}
+
+// ResolvedCommand describes the process execd would start for a command request.
+type ResolvedCommand struct {
+	// Argv is the full argument vector after shell wrapping.
+	Argv []string `json:"argv"`
+	// Cwd is the working directory the process would start in; empty means execd's own.
+	Cwd string `json:"cwd,omitempty"`
+	// EnvCount is the number of variables in the merged environment.
+	EnvCount int `json:"env_count"`
+	// RLimitNofile is the open file limit applied before the code runs, 0 if inherited.
+	RLimitNofile uint64 `json:"rlimit_nofile,omitempty"`
+	// Umask is the file mode creation mask set before the code runs, empty if inherited.
+	Umask string `json:"umask,omitempty"`
+	// Locale and Timezone are the LC_ALL/LANG and TZ values set for the code, empty if inherited.
+	Locale   string `json:"locale,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+	// ExtraEnvKeys lists the variables overlaid from EXECD_ENVS, sorted.
+	// Values are omitted so secrets are not echoed back.
+	ExtraEnvKeys []string `json:"extra_env_keys,omitempty"`
+
+	env []string
 }
 
 // SetDefaultHooks installs stdout logging fallbacks for unset hooks.
@@ -65,6 +223,33 @@ func (req *ExecuteCodeRequest) SetDefaultHooks() {
 			fmt.Printf("OnExecuteComplete: %v\n", executionTime)
 		}
 	}
+	if req.Hooks.OnExecuteDryRun == nil {
+		req.Hooks.OnExecuteDryRun = func(resolved *ResolvedCommand) { fmt.Printf("OnExecuteDryRun: %++v\n", resolved) }
+	}
+	if req.Hooks.OnExecuteOutputSink == nil {
+		req.Hooks.OnExecuteOutputSink = func(result *OutputSinkResult) { fmt.Printf("OnExecuteOutputSink: %++v\n", result) }
+	}
+	if req.Hooks.OnExecuteArtifacts == nil {
+		req.Hooks.OnExecuteArtifacts = func(manifest *ArtifactManifest) { fmt.Printf("OnExecuteArtifacts: %++v\n", manifest) }
+	}
+	if req.Hooks.OnExecuteExitCategory == nil {
+		req.Hooks.OnExecuteExitCategory = func(category ExitCategory) { fmt.Printf("OnExecuteExitCategory: %s\n", category) }
+	}
+	if req.Hooks.OnExecutePostCommand == nil {
+		req.Hooks.OnExecutePostCommand = func(result *PostCommandResult) { fmt.Printf("OnExecutePostCommand: %++v\n", result) }
+	}
+	if req.Hooks.OnExecuteOutputDigest == nil {
+		req.Hooks.OnExecuteOutputDigest = func(result *OutputDigestResult) { fmt.Printf("OnExecuteOutputDigest: %++v\n", result) }
+	}
+	if req.Hooks.OnExecuteSummary == nil {
+		req.Hooks.OnExecuteSummary = func(summary *ExecutionSummary) { fmt.Printf("OnExecuteSummary: %++v\n", summary) }
+	}
+	if req.Hooks.OnExecuteWorkdirDiff == nil {
+		req.Hooks.OnExecuteWorkdirDiff = func(changes *WorkdirChanges) { fmt.Printf("OnExecuteWorkdirDiff: %++v\n", changes) }
+	}
+	if req.Hooks.OnExecuteWarnings == nil {
+		req.Hooks.OnExecuteWarnings = func(warnings []ExecutionWarning) { fmt.Printf("OnExecuteWarnings: %++v\n", warnings) }
+	}
 	if req.Hooks.OnExecuteInit == nil {
 		req.Hooks.OnExecuteInit = func(session string) { fmt.Printf("OnExecuteInit: %s\n", session) }
 	}
@@ -74,6 +259,18 @@ func (req *ExecuteCodeRequest) SetDefaultHooks() {
 type CreateContextRequest struct {
 	Language Language `json:"language"`
 	Cwd      string   `json:"cwd"`
+	// InitScript runs once in the new session before any user code.
+	InitScript string `json:"init_script,omitempty"`
+	// OnInitOutput receives what InitScript produced, including on failure.
+	OnInitOutput func(output *SessionInitOutput) `json:"-"`
+}
+
+// SessionInitOutput is the output of a context's init script.
+type SessionInitOutput struct {
+	Stdout  string               `json:"stdout,omitempty"`
+	Stderr  string               `json:"stderr,omitempty"`
+	Results []map[string]any     `json:"results,omitempty"`
+	Error   *execute.ErrorOutput `json:"error,omitempty"`
 }
 
 type CodeContext struct {
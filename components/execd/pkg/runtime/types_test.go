@@ -30,7 +30,7 @@ func TestExecuteCodeRequest_SetDefaultHooks(t *testing.T) {
 
 	req.SetDefaultHooks()
 
-	if req.Hooks.OnExecuteStdout == nil || req.Hooks.OnExecuteStderr == nil || req.Hooks.OnExecuteError == nil {
+	if req.Hooks.OnExecuteStdout == nil || req.Hooks.OnExecuteStderr == nil || req.Hooks.OnExecuteError == nil || req.Hooks.OnExecuteDryRun == nil {
 		t.Fatalf("expected default hooks to be populated")
 	}
 	if req.Hooks.OnExecuteResult == nil {
@@ -0,0 +1,73 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package runtime
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExecute_UmaskRestrictsCreatedFiles(t *testing.T) {
+	skipWithoutBash(t)
+	cwd := t.TempDir()
+	req := &ExecuteCodeRequest{
+		Language: Command,
+		Code:     "echo secret > out.txt && mkdir out.d && bash -c 'touch out.d/child'",
+		Cwd:      cwd,
+		Timeout:  5 * time.Second,
+		Umask:    "077",
+		Hooks:    noopHooks(),
+	}
+	if err := NewController("", "").Execute(req); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	for name, want := range map[string]os.FileMode{"out.txt": 0o600, "out.d": 0o700 | os.ModeDir, "out.d/child": 0o600} {
+		info, err := os.Stat(filepath.Join(cwd, name))
+		if err != nil {
+			t.Fatalf("stat %s: %v", name, err)
+		}
+		if info.Mode() != want {
+			t.Fatalf("%s: expected mode %v, got %v", name, want, info.Mode())
+		}
+	}
+}
+
+func TestResolveCommand_UmaskPrelude(t *testing.T) {
+	resolved := resolveCommand("linux", &ExecuteCodeRequest{Code: "make", Umask: "27"}, nil)
+	if script := resolved.Argv[len(resolved.Argv)-1]; script != "umask 0027\nmake" || resolved.Umask != "0027" {
+		t.Fatalf("unexpected script %q with umask %q", script, resolved.Umask)
+	}
+	resolved = resolveCommand("windows", &ExecuteCodeRequest{Code: "make", Umask: "077"}, nil)
+	if resolved.Argv[len(resolved.Argv)-1] != "make" || resolved.Umask != "" {
+		t.Fatalf("expected the umask to be ignored on windows, got %v", resolved.Argv)
+	}
+
+	c := NewController("", "")
+	for _, bad := range []string{"8", "0o77", "-1", "1000", "00777"} {
+		if err := c.CheckUmask(bad); !errors.Is(err, ErrUmask) {
+			t.Fatalf("CheckUmask(%q): expected ErrUmask, got %v", bad, err)
+		}
+	}
+	if err := c.CheckUmask("0777"); err != nil {
+		t.Fatalf("expected 0777 to be accepted, got %v", err)
+	}
+}
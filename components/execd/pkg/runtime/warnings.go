@@ -0,0 +1,120 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+)
+
+// WarningCode identifies the kind of an ExecutionWarning.
+type WarningCode string
+
+const (
+	// WarningOutputTruncated: some output was dropped before delivery.
+	WarningOutputTruncated WarningCode = "output_truncated"
+	// WarningEnvDropped: a variable was left out of the environment.
+	WarningEnvDropped WarningCode = "env_dropped"
+	// WarningCABundle: the CA bundle was applied only in part.
+	WarningCABundle WarningCode = "ca_bundle"
+	// WarningScratchUnavailable: the command ran without its scratch tmpfs.
+	WarningScratchUnavailable WarningCode = "scratch_unavailable"
+	// WarningPriorityIgnored: the command kept execd's CPU or I/O priority.
+	WarningPriorityIgnored WarningCode = "priority_ignored"
+)
+
+// maxExecutionWarnings bounds the warnings kept for one execution.
+const maxExecutionWarnings = 32
+
+// ExecutionWarning is a condition met while running an execution that did
+// not fail it, but that the caller may want to show.
+type ExecutionWarning struct {
+	Code    WarningCode `json:"code"`
+	Message string      `json:"message"`
+}
+
+// executionWarnings collects the warnings of one execution.
+type executionWarnings struct {
+	mu       sync.Mutex
+	list     []ExecutionWarning
+	reported bool // sent through OnExecuteWarnings
+	finished bool
+}
+
+// Warn logs a non-fatal condition of the execution and, unless it already
+// finished, adds it to its warnings. Backends may call it from any goroutine.
+func (req *ExecuteCodeRequest) Warn(code WarningCode, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	log.Warning("%s", message)
+	w := req.warnings
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.finished || len(w.list) >= maxExecutionWarnings {
+		return
+	}
+	for _, seen := range w.list {
+		if seen.Code == code && seen.Message == message {
+			return
+		}
+	}
+	w.list = append(w.list, ExecutionWarning{Code: code, Message: message})
+}
+
+// wrap reports the warnings collected so far through OnExecuteWarnings,
+// once and only if there are any, right before the execution's final event.
+// A background command's final event comes as it launches; what it warns
+// about later is only returned by finish.
+func (w *executionWarnings) wrap(hooks ExecuteResultHook) ExecuteResultHook {
+	wrapped := hooks
+	report := func() {
+		w.mu.Lock()
+		list, already := slices.Clone(w.list), w.reported
+		w.reported = true
+		w.mu.Unlock()
+		if !already && len(list) > 0 {
+			callHook(hooks.OnExecuteWarnings, list)
+		}
+	}
+	wrapped.OnExecuteError = func(err *execute.ErrorOutput) {
+		report()
+		callHook(hooks.OnExecuteError, err)
+	}
+	wrapped.OnExecuteComplete = func(elapsed time.Duration) {
+		report()
+		callHook(hooks.OnExecuteComplete, elapsed)
+	}
+	return wrapped
+}
+
+// finish stops collecting and returns every warning of the execution. It is
+// called once the execution is over, for a background command when its
+// process has exited; w may be nil.
+func (w *executionWarnings) finish() []ExecutionWarning {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.finished = true
+	return slices.Clone(w.list)
+}
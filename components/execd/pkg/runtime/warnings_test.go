@@ -0,0 +1,154 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+// warnedRequest is a request collecting warnings as ExecuteContext sets it
+// up, recording the order of its final hooks.
+func warnedRequest(events *[]string, got *[]ExecutionWarning) *ExecuteCodeRequest {
+	req := &ExecuteCodeRequest{Language: Command, Hooks: noopHooks()}
+	req.Hooks.OnExecuteWarnings = func(w []ExecutionWarning) {
+		*events = append(*events, "warnings")
+		*got = w
+	}
+	req.Hooks.OnExecuteComplete = func(time.Duration) { *events = append(*events, "complete") }
+	req.Hooks.OnExecuteError = func(*execute.ErrorOutput) { *events = append(*events, "error") }
+	req.warnings = &executionWarnings{}
+	req.Hooks = req.warnings.wrap(req.Hooks)
+	return req
+}
+
+func TestExecutionWarnings_OutputTruncated(t *testing.T) {
+	var events []string
+	var got []ExecutionWarning
+	req := warnedRequest(&events, &got)
+	req.OutputRateLimit = 1000
+
+	throttle := newOutputThrottle(req.OutputRateLimit)
+	throttle.warnTruncated(req)
+	throttle.dropped = true
+	throttle.warnTruncated(req)
+	throttle.warnTruncated(req)
+	req.Hooks.OnExecuteComplete(time.Second)
+
+	assert.Equal(t, []string{"warnings", "complete"}, events)
+	require.Len(t, got, 1, "the same warning is reported once")
+	assert.Equal(t, WarningOutputTruncated, got[0].Code)
+	assert.Contains(t, got[0].Message, "1000 bytes/s")
+
+	// once reported, later warnings are kept for finish but not sent again
+	req.Warn(WarningCABundle, "late")
+	req.Hooks.OnExecuteError(&execute.ErrorOutput{})
+	assert.Equal(t, []string{"warnings", "complete", "error"}, events)
+	all := req.warnings.finish()
+	require.Len(t, all, 2)
+	assert.Equal(t, WarningCABundle, all[1].Code)
+
+	// once finished, warnings are only logged
+	req.Warn(WarningEnvDropped, "after the end")
+	assert.Len(t, req.warnings.finish(), 2)
+}
+
+func TestExecutionWarnings_NoneReported(t *testing.T) {
+	var events []string
+	var got []ExecutionWarning
+	req := warnedRequest(&events, &got)
+	req.Hooks.OnExecuteError(&execute.ErrorOutput{})
+	assert.Equal(t, []string{"error"}, events)
+
+	// outside ExecuteContext a warning is only logged
+	(&ExecuteCodeRequest{}).Warn(WarningEnvDropped, "dropped")
+}
+
+func TestRunCommand_WarnsAboutDroppedEnv(t *testing.T) {
+	skipWithoutBash(t)
+	envFile := filepath.Join(t.TempDir(), "env")
+	require.NoError(t, os.WriteFile(envFile, []byte("FOO=bar\nBROKEN secret-value\n"), 0o644))
+	t.Setenv("EXECD_ENVS", envFile)
+
+	var events []string
+	var got []ExecutionWarning
+	var stdout []string
+	req := &ExecuteCodeRequest{Language: Command, Code: "echo $FOO", Hooks: noopHooks()}
+	req.Hooks.OnExecuteStdout = func(s string) { stdout = append(stdout, s) }
+	req.Hooks.OnExecuteWarnings = func(w []ExecutionWarning) {
+		events = append(events, "warnings")
+		got = w
+	}
+	req.Hooks.OnExecuteComplete = func(time.Duration) { events = append(events, "complete") }
+	require.NoError(t, NewController("", "").Execute(req))
+
+	assert.Equal(t, []string{"bar"}, stdout, "the other variables still apply")
+	assert.Equal(t, []string{"warnings", "complete"}, events)
+	require.Len(t, got, 1)
+	assert.Equal(t, WarningEnvDropped, got[0].Code)
+	assert.Contains(t, got[0].Message, `"BROKEN"`)
+	assert.False(t, strings.Contains(got[0].Message, "secret-value"), "values stay out of the warning")
+}
+
+func TestBackgroundCommand_WarningsRaisedUntilExit(t *testing.T) {
+	skipWithoutBash(t)
+	receiver, url := newEventReceiver(t, http.StatusAccepted)
+	envFile := filepath.Join(t.TempDir(), "env")
+	require.NoError(t, os.WriteFile(envFile, []byte("BROKEN\n"), 0o644))
+	t.Setenv("EXECD_ENVS", envFile)
+	c := NewController("", "")
+	c.SetCloudEventSink(url)
+
+	release := filepath.Join(t.TempDir(), "release")
+	var session string
+	var atLaunch []ExecutionWarning
+	req := &ExecuteCodeRequest{
+		Language: BackgroundCommand,
+		Code:     "while [ ! -e " + release + " ]; do sleep 0.05; done",
+		Hooks:    noopHooks(),
+	}
+	req.Hooks.OnExecuteInit = func(s string) { session = s }
+	req.Hooks.OnExecuteWarnings = func(w []ExecutionWarning) { atLaunch = w }
+	require.NoError(t, c.Execute(req))
+	require.Len(t, atLaunch, 1, "the final event carries what was raised while launching")
+	assert.Equal(t, WarningEnvDropped, atLaunch[0].Code)
+
+	// raised while the process runs, after the final event
+	req.Warn(WarningOutputTruncated, "dropped while running")
+	require.NoError(t, os.WriteFile(release, nil, 0o644))
+
+	var status *CommandStatus
+	require.Eventually(t, func() bool {
+		var err error
+		status, err = c.GetCommandStatus(session)
+		return err == nil && !status.Running
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Len(t, status.Warnings, 2)
+	assert.Equal(t, WarningOutputTruncated, status.Warnings[1].Code)
+
+	require.Eventually(t, func() bool { return len(receiver.snapshot()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	data := receiver.snapshot()[1]["data"].(map[string]any)
+	require.Len(t, data["warnings"], 2, "the completion event is emitted at exit with every warning")
+	assert.Equal(t, "output_truncated", data["warnings"].([]any)[1].(map[string]any)["code"])
+}
@@ -0,0 +1,130 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// WasmRunArg, as execd's first argument, makes it run the WASI module that
+// follows with the embedded runtime instead of serving. Wasm commands re-exec
+// execd this way, so a module gets the same process handling as native ones.
+const WasmRunArg = "__wasm-run"
+
+// SetWasmRuntime sets an external WASI runtime binary for wasm commands,
+// invoked as `<runtime> run <module> [args...]`; empty restores the embedded
+// runtime.
+func (c *Controller) SetWasmRuntime(path string) {
+	c.wasmRuntime = strings.TrimSpace(path)
+}
+
+// CheckWasm reports whether a wasm command with these options can be run,
+// so callers can reject the request before streaming starts.
+func (c *Controller) CheckWasm(request *ExecuteCodeRequest) error {
+	if !request.Wasm {
+		return nil
+	}
+	if request.RLimitNofile > 0 {
+		return fmt.Errorf("%w: open file limits need a shell", ErrWasm)
+	}
+	if request.Umask != "" {
+		return fmt.Errorf("%w: a umask needs a shell", ErrWasm)
+	}
+	if len(strings.Fields(request.Code)) == 0 {
+		return fmt.Errorf("%w: no module given", ErrWasm)
+	}
+	if _, err := c.wasmLauncher(); err != nil {
+		return fmt.Errorf("%w: runtime unavailable: %v", ErrWasm, err)
+	}
+	return nil
+}
+
+// wasmLauncher returns the argv that a module path and its arguments follow:
+// execd itself with WasmRunArg, or `<runtime> run` when one is configured.
+func (c *Controller) wasmLauncher() ([]string, error) {
+	if c.wasmRuntime == "" {
+		self, err := os.Executable()
+		if err != nil {
+			return nil, err
+		}
+		return []string{self, WasmRunArg}, nil
+	}
+	if _, err := exec.LookPath(c.wasmRuntime); err != nil {
+		return nil, err
+	}
+	return []string{c.wasmRuntime, "run"}, nil
+}
+
+// resolveWasm replaces the shell invocation with the WASI runtime for wasm
+// commands. No directories are preopened and no variables are passed, so only
+// the module's arguments, stdio and exit code cross the boundary.
+func (c *Controller) resolveWasm(request *ExecuteCodeRequest, resolved *ResolvedCommand) error {
+	if !request.Wasm {
+		return nil
+	}
+	if err := c.CheckWasm(request); err != nil {
+		return err
+	}
+	launcher, err := c.wasmLauncher()
+	if err != nil {
+		return fmt.Errorf("%w: runtime unavailable: %v", ErrWasm, err)
+	}
+	resolved.Argv = append(launcher, strings.Fields(request.Code)...)
+	return nil
+}
+
+// RunWasmModule runs the WASI module at args[0] with the embedded runtime,
+// passing args as its arguments and the process's stdio, and returns its exit
+// code. A module that cannot be loaded or traps exits with 1.
+func RunWasmModule(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: execd "+WasmRunArg+" <module> [args...]")
+		return 2
+	}
+	code, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: %v\n", ErrWasm, err)
+		return 1
+	}
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+
+	config := wazero.NewModuleConfig().
+		WithArgs(args...).
+		WithStdin(os.Stdin).
+		WithStdout(os.Stdout).
+		WithStderr(os.Stderr)
+	_, err = r.InstantiateWithConfig(ctx, code, config)
+	var exitErr *sys.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		return int(exitErr.ExitCode())
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "%v: %v\n", ErrWasm, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,192 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+// helloWasm is the binary encoding of
+//
+//	(module
+//	  (import "wasi_snapshot_preview1" "fd_write" (func $fd_write (param i32 i32 i32 i32) (result i32)))
+//	  (import "wasi_snapshot_preview1" "proc_exit" (func $proc_exit (param i32)))
+//	  (memory (export "memory") 1)
+//	  (data (i32.const 16) "hello wasm\n")
+//	  (func (export "_start")
+//	    (i32.store (i32.const 0) (i32.const 16))
+//	    (i32.store (i32.const 4) (i32.const 11))
+//	    (drop (call $fd_write (i32.const 1) (i32.const 0) (i32.const 1) (i32.const 8)))
+//	    (call $proc_exit (i32.const 7))))
+var helloWasm = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x01, 0x10, 0x03, 0x60,
+	0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7f, 0x60, 0x01, 0x7f, 0x00, 0x60,
+	0x00, 0x00, 0x02, 0x46, 0x02, 0x16, 0x77, 0x61, 0x73, 0x69, 0x5f, 0x73,
+	0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x5f, 0x70, 0x72, 0x65, 0x76,
+	0x69, 0x65, 0x77, 0x31, 0x08, 0x66, 0x64, 0x5f, 0x77, 0x72, 0x69, 0x74,
+	0x65, 0x00, 0x00, 0x16, 0x77, 0x61, 0x73, 0x69, 0x5f, 0x73, 0x6e, 0x61,
+	0x70, 0x73, 0x68, 0x6f, 0x74, 0x5f, 0x70, 0x72, 0x65, 0x76, 0x69, 0x65,
+	0x77, 0x31, 0x09, 0x70, 0x72, 0x6f, 0x63, 0x5f, 0x65, 0x78, 0x69, 0x74,
+	0x00, 0x01, 0x03, 0x02, 0x01, 0x02, 0x05, 0x03, 0x01, 0x00, 0x01, 0x07,
+	0x13, 0x02, 0x06, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x02, 0x00, 0x06,
+	0x5f, 0x73, 0x74, 0x61, 0x72, 0x74, 0x00, 0x02, 0x0a, 0x21, 0x01, 0x1f,
+	0x00, 0x41, 0x00, 0x41, 0x10, 0x36, 0x02, 0x00, 0x41, 0x04, 0x41, 0x0b,
+	0x36, 0x02, 0x00, 0x41, 0x01, 0x41, 0x00, 0x41, 0x01, 0x41, 0x08, 0x10,
+	0x00, 0x1a, 0x41, 0x07, 0x10, 0x01, 0x0b, 0x0b, 0x11, 0x01, 0x00, 0x41,
+	0x10, 0x0b, 0x0b, 0x68, 0x65, 0x6c, 0x6c, 0x6f, 0x20, 0x77, 0x61, 0x73,
+	0x6d, 0x0a,
+}
+
+// nodeWasiShim runs `<shim> run <module>` through Node's WASI implementation,
+// standing in for wasmtime on hosts that only have node.
+const nodeWasiShim = `#!/usr/bin/env node
+const { WASI } = require('node:wasi');
+const fs = require('node:fs');
+const [, , sub, wasmPath, ...args] = process.argv;
+if (sub !== 'run') process.exit(2);
+const wasi = new WASI({ version: 'preview1', args: [wasmPath, ...args], returnOnExit: true });
+WebAssembly.instantiate(fs.readFileSync(wasmPath), wasi.getImportObject())
+  .then(({ instance }) => process.exit(wasi.start(instance)));
+`
+
+// testWasmRuntime returns wasmtime if installed, else a node shim, else skips.
+func testWasmRuntime(t *testing.T) string {
+	t.Helper()
+	if goruntime.GOOS == "windows" {
+		t.Skip("wasm runtime shim needs a unix shell")
+	}
+	if path, err := exec.LookPath("wasmtime"); err == nil {
+		return path
+	}
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("neither wasmtime nor node found in PATH")
+	}
+	shim := filepath.Join(t.TempDir(), "wasi-run.cjs")
+	if err := os.WriteFile(shim, []byte(nodeWasiShim), 0o755); err != nil {
+		t.Fatalf("write shim: %v", err)
+	}
+	return shim
+}
+
+// TestMain lets the test binary stand in for execd when a wasm command
+// re-execs it to run a module with the embedded runtime.
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == WasmRunArg {
+		os.Exit(RunWasmModule(os.Args[2:]))
+	}
+	os.Exit(m.Run())
+}
+
+func TestRunCommand_WasmModuleStreamsOutputAndExitCode(t *testing.T) {
+	runHelloWasm(t, NewController("", ""))
+}
+
+func TestRunCommand_WasmModuleUnderExternalRuntime(t *testing.T) {
+	c := NewController("", "")
+	c.SetWasmRuntime(testWasmRuntime(t))
+	runHelloWasm(t, c)
+}
+
+// runHelloWasm runs helloWasm through c and checks its output and exit code.
+func runHelloWasm(t *testing.T, c *Controller) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hello.wasm"), helloWasm, 0o644); err != nil {
+		t.Fatalf("write module: %v", err)
+	}
+
+	var (
+		stdoutLines []string
+		gotErr      *execute.ErrorOutput
+	)
+	hooks := noopHooks()
+	hooks.OnExecuteStdout = func(s string) { stdoutLines = append(stdoutLines, s) }
+	hooks.OnExecuteError = func(err *execute.ErrorOutput) { gotErr = err }
+	req := &ExecuteCodeRequest{
+		Language: Command,
+		Code:     "hello.wasm",
+		Cwd:      dir,
+		Wasm:     true,
+		Hooks:    hooks,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	if err := c.runCommand(ctx, req); err != nil {
+		t.Fatalf("runCommand: %v", err)
+	}
+
+	if len(stdoutLines) != 1 || stdoutLines[0] != "hello wasm" {
+		t.Fatalf("unexpected stdout: %#v", stdoutLines)
+	}
+	if gotErr == nil || gotErr.EValue != "7" {
+		t.Fatalf("expected exit code 7 from proc_exit, got %+v", gotErr)
+	}
+}
+
+func TestResolveWasm(t *testing.T) {
+	c := NewController("", "")
+	resolved := resolveCommand("linux", &ExecuteCodeRequest{Code: "app.wasm x"}, nil)
+	if err := c.resolveWasm(&ExecuteCodeRequest{Code: "app.wasm x", Wasm: true}, resolved); err != nil {
+		t.Fatalf("resolveWasm: %v", err)
+	}
+	self, _ := os.Executable()
+	if want := []string{self, WasmRunArg, "app.wasm", "x"}; !slices.Equal(resolved.Argv, want) {
+		t.Fatalf("argv = %q, want %q", resolved.Argv, want)
+	}
+
+	c.SetWasmRuntime("/bin/sh")
+	resolved = resolveCommand("linux", &ExecuteCodeRequest{Code: " app.wasm --flag  x "}, nil)
+	if err := c.resolveWasm(&ExecuteCodeRequest{Code: " app.wasm --flag  x ", Wasm: true}, resolved); err != nil {
+		t.Fatalf("resolveWasm: %v", err)
+	}
+	want := []string{"/bin/sh", "run", "app.wasm", "--flag", "x"}
+	if len(resolved.Argv) != len(want) {
+		t.Fatalf("argv = %q, want %q", resolved.Argv, want)
+	}
+	for i := range want {
+		if resolved.Argv[i] != want[i] {
+			t.Fatalf("argv = %q, want %q", resolved.Argv, want)
+		}
+	}
+
+	for name, req := range map[string]*ExecuteCodeRequest{
+		"rlimit":    {Code: "app.wasm", Wasm: true, RLimitNofile: 64},
+		"no module": {Code: "  ", Wasm: true},
+	} {
+		if err := c.CheckWasm(req); !errors.Is(err, ErrWasm) {
+			t.Fatalf("%s: expected ErrWasm, got %v", name, err)
+		}
+	}
+
+	c.SetWasmRuntime("/nonexistent/wasm-runtime")
+	if err := c.CheckWasm(&ExecuteCodeRequest{Code: "app.wasm", Wasm: true}); !errors.Is(err, ErrWasm) {
+		t.Fatalf("expected ErrWasm for a missing runtime, got %v", err)
+	}
+	if err := c.CheckWasm(&ExecuteCodeRequest{Code: "echo hi"}); err != nil {
+		t.Fatalf("native commands must not be checked: %v", err)
+	}
+}
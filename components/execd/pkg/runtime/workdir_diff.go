@@ -0,0 +1,561 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alibaba/opensandbox/execd/pkg/log"
+)
+
+const (
+	// defaultWorkdirDiffMaxBytes and maxWorkdirDiffMaxBytes bound the contents
+	// and patches returned, summed over all files.
+	defaultWorkdirDiffMaxBytes = 1 << 20
+	maxWorkdirDiffMaxBytes     = 16 << 20
+	// maxWorkdirTextBytes is the largest file whose text is kept in the
+	// snapshot and returned; bigger files are reported by metadata only.
+	maxWorkdirTextBytes = 1 << 20
+	// maxWorkdirSnapshotText caps the text held for one snapshot.
+	maxWorkdirSnapshotText = 64 << 20
+	// maxWorkdirDiffEdits gives up on a patch for files that changed too much
+	// to diff cheaply; the file is still listed.
+	maxWorkdirDiffEdits = 4000
+	// workdirDiffContext is the number of unchanged lines around a hunk.
+	workdirDiffContext = 3
+)
+
+// maxWorkdirWalkEntries caps the entries one snapshot walks, so a huge
+// working directory cannot stall the command before and after it runs.
+var maxWorkdirWalkEntries = 100000
+
+var errWorkdirDiffBackground = errors.New("working directory diff is only supported for foreground commands")
+
+// WorkdirDiff asks for the changes a command made to its working directory,
+// from a snapshot taken before it starts.
+type WorkdirDiff struct {
+	// Include keeps only files matching one of these globs; empty keeps all.
+	Include []string `json:"include,omitempty"`
+	// Exclude drops files and directories matching one of these globs.
+	Exclude []string `json:"exclude,omitempty"`
+	// MaxFiles caps the number of changed files listed; 0 means 1000.
+	MaxFiles int `json:"max_files,omitempty"`
+	// MaxBytes caps the contents and patches returned, summed over all
+	// files; 0 means 1 MiB. Files past it are listed without them.
+	MaxBytes int `json:"max_bytes,omitempty"`
+}
+
+// Workdir change operations.
+const (
+	WorkdirAdded    = "added"
+	WorkdirModified = "modified"
+	WorkdirDeleted  = "deleted"
+)
+
+// WorkdirChanges lists the files a command added, modified or deleted.
+type WorkdirChanges struct {
+	// Root is the absolute working directory; paths are relative to it.
+	Root  string          `json:"root"`
+	Files []WorkdirChange `json:"files"`
+	// Truncated is set when more files changed than MaxFiles, or when the
+	// working directory had too many entries to walk in full.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// WorkdirChange describes one changed file.
+type WorkdirChange struct {
+	Path string `json:"path"`
+	// Op is added, modified or deleted.
+	Op string `json:"op"`
+	// Size is the file's size after the command, or before it when deleted.
+	Size int64 `json:"size"`
+	// Binary files are reported by metadata only.
+	Binary bool `json:"binary,omitempty"`
+	// Content is the text of an added file.
+	Content string `json:"content,omitempty"`
+	// Patch is a unified diff of a modified text file.
+	Patch string `json:"patch,omitempty"`
+	// Omitted is set when a text file's content or patch was left out: the
+	// file is above 1 MiB, changed too much to diff, or MaxBytes ran out.
+	Omitted bool `json:"omitted,omitempty"`
+}
+
+// Validate checks the diff options before the command starts.
+func (d *WorkdirDiff) Validate() error {
+	if d.MaxFiles < 0 || d.MaxFiles > maxArtifactMaxFiles {
+		return fmt.Errorf("workdir diff max files must be between 0 and %d", maxArtifactMaxFiles)
+	}
+	if d.MaxBytes < 0 || d.MaxBytes > maxWorkdirDiffMaxBytes {
+		return fmt.Errorf("workdir diff max bytes must be between 0 and %d", maxWorkdirDiffMaxBytes)
+	}
+	for _, pattern := range append(append([]string{}, d.Include...), d.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid workdir diff glob %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// workdirFile is one file of a snapshot.
+type workdirFile struct {
+	size   int64
+	sha256 string
+	binary bool
+	// text is the file's content when it is text of at most 1 MiB and the
+	// snapshot had room for it.
+	text    string
+	hasText bool
+}
+
+// workdirSnapshot is the state of a working directory's files.
+type workdirSnapshot struct {
+	root  string
+	files map[string]*workdirFile
+	// truncated is set when the walk stopped at maxWorkdirWalkEntries, so
+	// files may be missing.
+	truncated bool
+}
+
+// snapshotWorkdir records the files under cwd that d selects; nil without d.
+func snapshotWorkdir(d *WorkdirDiff, cwd string) (*workdirSnapshot, error) {
+	if d == nil {
+		return nil, nil
+	}
+	if cwd == "" {
+		var err error
+		if cwd, err = os.Getwd(); err != nil {
+			return nil, err
+		}
+	}
+	root, err := filepath.Abs(cwd)
+	if err != nil {
+		return nil, err
+	}
+	snap := &workdirSnapshot{root: root, files: map[string]*workdirFile{}}
+	textBudget := maxWorkdirSnapshotText
+	entries := 0
+	err = filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			return nil // unreadable entries are skipped
+		}
+		if p == root {
+			return nil
+		}
+		if entries++; entries > maxWorkdirWalkEntries {
+			snap.truncated = true
+			return filepath.SkipAll
+		}
+		rel := filepath.ToSlash(strings.TrimPrefix(p, root+string(filepath.Separator)))
+		if matchesAnyGlob(d.Exclude, rel) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || (len(d.Include) > 0 && !matchesAnyGlob(d.Include, rel)) {
+			return nil
+		}
+		file, err := readWorkdirFile(p, textBudget)
+		if err != nil {
+			return nil
+		}
+		if file.hasText {
+			textBudget -= len(file.text)
+		}
+		snap.files[rel] = file
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("snapshot working directory %s: %w", root, err)
+	}
+	return snap, nil
+}
+
+// readWorkdirFile hashes the file at p and keeps its text when it is text
+// of at most 1 MiB and within textBudget.
+func readWorkdirFile(p string, textBudget int) (*workdirFile, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	file := &workdirFile{size: info.Size()}
+	if info.Size() > maxWorkdirTextBytes {
+		file.sha256, _ = hashFile(p)
+		file.binary = isBinaryPrefix(p)
+		return file, nil
+	}
+	content, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	file.size = int64(len(content))
+	sum := sha256.Sum256(content)
+	file.sha256 = hex.EncodeToString(sum[:])
+	file.binary = bytes.IndexByte(content[:min(len(content), 8000)], 0) >= 0 || !utf8.Valid(content)
+	if !file.binary && len(content) <= textBudget {
+		file.text, file.hasText = string(content), true
+	}
+	return file, nil
+}
+
+// isBinaryPrefix looks for a NUL byte in the first 8000 bytes of p, as git does.
+func isBinaryPrefix(p string) bool {
+	f, err := os.Open(p)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	buf := make([]byte, 8000)
+	n, _ := f.Read(buf)
+	return bytes.IndexByte(buf[:n], 0) >= 0
+}
+
+// diff compares the working directory with the snapshot, listing changed
+// files by path.
+func (s *workdirSnapshot) diff(d *WorkdirDiff) (*WorkdirChanges, error) {
+	current, err := snapshotWorkdir(d, s.root)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(current.files))
+	for rel := range current.files {
+		paths = append(paths, rel)
+	}
+	for rel := range s.files {
+		if _, ok := current.files[rel]; !ok {
+			paths = append(paths, rel)
+		}
+	}
+	sort.Strings(paths)
+
+	maxFiles := d.MaxFiles
+	if maxFiles == 0 {
+		maxFiles = defaultArtifactMaxFiles
+	}
+	budget := d.MaxBytes
+	if budget == 0 {
+		budget = defaultWorkdirDiffMaxBytes
+	}
+	changes := &WorkdirChanges{Root: s.root, Files: []WorkdirChange{}, Truncated: s.truncated || current.truncated}
+	for _, rel := range paths {
+		before, after := s.files[rel], current.files[rel]
+		var change WorkdirChange
+		switch {
+		case (before == nil && s.truncated) || (after == nil && current.truncated):
+			// a file the other walk did not reach is not known to have changed
+			continue
+		case before == nil:
+			change = WorkdirChange{Path: rel, Op: WorkdirAdded, Size: after.size, Binary: after.binary}
+			if !after.binary {
+				change.Content, change.Omitted = fitBudget(after.text, after.hasText, &budget)
+			}
+		case after == nil:
+			change = WorkdirChange{Path: rel, Op: WorkdirDeleted, Size: before.size, Binary: before.binary}
+		case before.sha256 != after.sha256 || before.size != after.size:
+			change = WorkdirChange{Path: rel, Op: WorkdirModified, Size: after.size, Binary: before.binary || after.binary}
+			if !change.Binary {
+				patch, ok := "", before.hasText && after.hasText
+				if ok {
+					patch, ok = unifiedDiff(rel, before.text, after.text)
+				}
+				change.Patch, change.Omitted = fitBudget(patch, ok, &budget)
+			}
+		default:
+			continue
+		}
+		if len(changes.Files) == maxFiles {
+			changes.Truncated = true
+			break
+		}
+		changes.Files = append(changes.Files, change)
+	}
+	return changes, nil
+}
+
+// fitBudget returns text when it is available and fits the remaining budget,
+// which it spends, and otherwise reports it omitted.
+func fitBudget(text string, ok bool, budget *int) (string, bool) {
+	if !ok || len(text) > *budget {
+		return "", true
+	}
+	*budget -= len(text)
+	return text, false
+}
+
+// reportWorkdirDiff passes the changes since snap to OnExecuteWorkdirDiff.
+// A failed comparison is only logged: the command has already run.
+func reportWorkdirDiff(request *ExecuteCodeRequest, snap *workdirSnapshot) {
+	if snap == nil {
+		return
+	}
+	changes, err := snap.diff(request.WorkdirDiff)
+	if err != nil {
+		log.Error("WorkdirDiffError: %v", err)
+		return
+	}
+	callHook(request.Hooks.OnExecuteWorkdirDiff, changes)
+}
+
+// unifiedDiff renders the line diff of a and b as a unified diff of rel. It
+// gives up, returning false, on files that changed too much.
+func unifiedDiff(rel, a, b string) (string, bool) {
+	ops, ok := diffLines(splitLines(a), splitLines(b))
+	if !ok {
+		return "", false
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", rel, rel)
+	for start := 0; start < len(ops); {
+		// find the next change and the hunk around it
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		from := max(first-workdirDiffContext, start)
+		to := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				to = i + 1
+			} else if i-to >= 2*workdirDiffContext {
+				break
+			}
+		}
+		to = min(to+workdirDiffContext, len(ops))
+		writeHunk(&out, ops, from, to)
+		start = to
+	}
+	return out.String(), true
+}
+
+// diffOp is one line of a line diff: ' ' kept, '-' removed or '+' added.
+type diffOp struct {
+	kind     byte
+	line     string
+	old, new int // 1-based line numbers before and after
+}
+
+func writeHunk(out *strings.Builder, ops []diffOp, from, to int) {
+	oldStart, newStart := ops[from].old, ops[from].new
+	var oldLines, newLines int
+	for _, op := range ops[from:to] {
+		if op.kind != '+' {
+			oldLines++
+		}
+		if op.kind != '-' {
+			newLines++
+		}
+	}
+	// an empty side is numbered after the line it follows
+	if oldLines == 0 {
+		oldStart--
+	}
+	if newLines == 0 {
+		newStart--
+	}
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", oldStart, oldLines, newStart, newLines)
+	for _, op := range ops[from:to] {
+		out.WriteByte(op.kind)
+		out.WriteString(op.line)
+		if !strings.HasSuffix(op.line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// splitLines splits s after every newline, keeping them, so a last line
+// without one differs from the same line with one.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes a shortest edit script from a to b with the linear
+// space variant of Myers' algorithm, splitting both sides at the middle of
+// the edit path and recursing. It gives up past maxWorkdirDiffEdits edits.
+func diffLines(a, b []string) ([]diffOp, bool) {
+	d := lineDiff{a: a, b: b, budget: maxWorkdirDiffEdits}
+	if !d.diff(0, len(a), 0, len(b)) {
+		return nil, false
+	}
+	return d.ops, true
+}
+
+// lineDiff accumulates the edit script of a to b in order.
+type lineDiff struct {
+	a, b []string
+	ops  []diffOp
+	// budget is how many more edits may be made before giving up.
+	budget int
+}
+
+// emit appends n ops of kind starting at a[x] and b[y], 0-based.
+func (d *lineDiff) emit(kind byte, x, y, n int) bool {
+	for i := 0; i < n; i++ {
+		op := diffOp{kind: kind, old: x + 1, new: y + 1}
+		switch kind {
+		case ' ':
+			op.line = d.a[x]
+			x++
+			y++
+		case '-':
+			op.line = d.a[x]
+			x++
+		case '+':
+			op.line = d.b[y]
+			y++
+		}
+		d.ops = append(d.ops, op)
+	}
+	if kind != ' ' {
+		d.budget -= n
+	}
+	return d.budget >= 0
+}
+
+// diff appends the edit script of a[x0:x1] to b[y0:y1].
+func (d *lineDiff) diff(x0, x1, y0, y1 int) bool {
+	prefix := 0
+	for x0+prefix < x1 && y0+prefix < y1 && d.a[x0+prefix] == d.b[y0+prefix] {
+		prefix++
+	}
+	d.emit(' ', x0, y0, prefix)
+	x0, y0 = x0+prefix, y0+prefix
+	suffix := 0
+	for x1-suffix > x0 && y1-suffix > y0 && d.a[x1-suffix-1] == d.b[y1-suffix-1] {
+		suffix++
+	}
+	x1, y1 = x1-suffix, y1-suffix
+
+	switch {
+	case x0 == x1:
+		if !d.emit('+', x0, y0, y1-y0) {
+			return false
+		}
+	case y0 == y1:
+		if !d.emit('-', x0, y0, x1-x0) {
+			return false
+		}
+	default:
+		x, y, ok := d.split(x0, x1, y0, y1)
+		if !ok || !d.diff(x0, x, y0, y) || !d.diff(x, x1, y, y1) {
+			return false
+		}
+	}
+	d.emit(' ', x1, y1, suffix)
+	return true
+}
+
+// split finds where a shortest edit path of a[x0:x1] to b[y0:y1] crosses its
+// middle by searching from both ends at once, in space linear in the input.
+// Both ranges are non-empty and differ in their first and last lines. It
+// fails when the path needs more edits than the budget allows.
+func (d *lineDiff) split(x0, x1, y0, y1 int) (int, int, bool) {
+	n, m := x1-x0, y1-y0
+	full := (n + m + 1) / 2
+	maxD := min(full, d.budget/2+1)
+	offset := maxD + 1
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+	delta := n - m
+	// with an odd delta the forward search meets the backward one, else
+	// the other way round
+	odd := delta%2 != 0
+	var kStart1, kEnd1, kStart2, kEnd2 int
+	for step := 0; step < maxD; step++ {
+		for k := -step + kStart1; k <= step-kEnd1; k += 2 {
+			var x int
+			if k == -step || (k != step && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[x0+x] == d.b[y0+y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			switch {
+			case x > n:
+				kEnd1 += 2
+			case y > m:
+				kStart1 += 2
+			case odd:
+				if j := offset + delta - k; j >= 0 && j < len(backward) && backward[j] != -1 && x >= n-backward[j] {
+					return x0 + x, y0 + y, true
+				}
+			}
+		}
+		for k := -step + kStart2; k <= step-kEnd2; k += 2 {
+			var x int
+			if k == -step || (k != step && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[x1-x-1] == d.b[y1-y-1] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+			switch {
+			case x > n:
+				kEnd2 += 2
+			case y > m:
+				kStart2 += 2
+			case !odd:
+				if j := offset + delta - k; j >= 0 && j < len(forward) && forward[j] != -1 {
+					fx := forward[j]
+					if fy := fx - (j - offset); fx >= n-x {
+						return x0 + fx, y0 + fy, true
+					}
+				}
+			}
+		}
+	}
+	if maxD == full {
+		// no line in common: delete all of a, then insert all of b
+		return x1, y0, true
+	}
+	return 0, 0, false
+}
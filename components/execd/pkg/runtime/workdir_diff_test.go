@@ -0,0 +1,246 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	b := "one\nTWO\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven"
+	patch, ok := unifiedDiff("n.txt", a, b)
+	require.True(t, ok)
+	assert.Equal(t, `--- a/n.txt
++++ b/n.txt
+@@ -1,5 +1,5 @@
+ one
+-two
++TWO
+ three
+ four
+ five
+@@ -8,3 +8,4 @@
+ eight
+ nine
+ ten
++eleven
+\ No newline at end of file
+`, patch)
+
+	patch, ok = unifiedDiff("empty", "", "x\n")
+	require.True(t, ok)
+	assert.Equal(t, "--- a/empty\n+++ b/empty\n@@ -0,0 +1,1 @@\n+x\n", patch)
+}
+
+func TestDiffLines_GivesUpOnLargeRewrites(t *testing.T) {
+	a := make([]string, maxWorkdirDiffEdits)
+	b := make([]string, maxWorkdirDiffEdits)
+	for i := range a {
+		a[i], b[i] = "a\n", "b\n"
+	}
+	_, ok := diffLines(a, b)
+	assert.False(t, ok)
+}
+
+func TestDiffLines_ShortestEditScript(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	lines := func() []string {
+		out := make([]string, rng.Intn(30))
+		for i := range out {
+			out[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return out
+	}
+	for i := 0; i < 500; i++ {
+		a, b := lines(), lines()
+		ops, ok := diffLines(a, b)
+		require.True(t, ok)
+		var gotA, gotB []string
+		edits := 0
+		for _, op := range ops {
+			if op.kind != '+' {
+				require.Equal(t, len(gotA)+1, op.old)
+				gotA = append(gotA, op.line)
+			}
+			if op.kind != '-' {
+				require.Equal(t, len(gotB)+1, op.new)
+				gotB = append(gotB, op.line)
+			}
+			if op.kind != ' ' {
+				edits++
+			}
+		}
+		require.Equal(t, len(a), len(gotA))
+		require.Equal(t, len(b), len(gotB))
+		if len(a) > 0 {
+			require.Equal(t, a, gotA)
+		}
+		if len(b) > 0 {
+			require.Equal(t, b, gotB)
+		}
+		require.Equal(t, editDistance(a, b), edits, "a=%q b=%q", a, b)
+	}
+}
+
+// editDistance is the insert/delete distance of a and b by dynamic programming.
+func editDistance(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				cur[j] = prev[j-1]
+			} else {
+				cur[j] = min(prev[j], cur[j-1]) + 1
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func TestWorkdirSnapshot_TruncatesLargeWalks(t *testing.T) {
+	old := maxWorkdirWalkEntries
+	maxWorkdirWalkEntries = 3
+	t.Cleanup(func() { maxWorkdirWalkEntries = old })
+
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name+"\n"), 0o644))
+	}
+	d := &WorkdirDiff{}
+	snap, err := snapshotWorkdir(d, dir)
+	require.NoError(t, err)
+	assert.True(t, snap.truncated)
+	assert.Len(t, snap.files, 3)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b"), []byte("B\n"), 0o644))
+	require.NoError(t, os.Remove(filepath.Join(dir, "a")))
+
+	changes, err := snap.diff(d)
+	require.NoError(t, err)
+	assert.True(t, changes.Truncated)
+	// neither walk saw every file, so the deleted a and the unseen d are left out
+	assert.Equal(t, []WorkdirChange{
+		{Path: "b", Op: WorkdirModified, Size: 2, Patch: "--- a/b\n+++ b/b\n@@ -1,1 +1,1 @@\n-b\n+B\n"},
+	}, changes.Files)
+}
+
+func TestWorkdirSnapshot_Diff(t *testing.T) {
+	dir := t.TempDir()
+	write := func(rel, content string) {
+		p := filepath.Join(dir, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+	write("keep.txt", "same\n")
+	write("edit.txt", "old\n")
+	write("gone.txt", "bye\n")
+	write("blob.bin", "\x00\x01")
+	write(".git/HEAD", "ref\n")
+
+	d := &WorkdirDiff{Exclude: []string{".git"}}
+	snap, err := snapshotWorkdir(d, dir)
+	require.NoError(t, err)
+
+	write("edit.txt", "new\n")
+	require.NoError(t, os.Remove(filepath.Join(dir, "gone.txt")))
+	write("blob.bin", "\x00\x02\x03")
+	write("out/new.txt", "hello\n")
+	write(".git/HEAD", "other\n")
+
+	changes, err := snap.diff(d)
+	require.NoError(t, err)
+	assert.Equal(t, dir, changes.Root)
+	assert.False(t, changes.Truncated)
+	assert.Equal(t, []WorkdirChange{
+		{Path: "blob.bin", Op: WorkdirModified, Size: 3, Binary: true},
+		{Path: "edit.txt", Op: WorkdirModified, Size: 4, Patch: "--- a/edit.txt\n+++ b/edit.txt\n@@ -1,1 +1,1 @@\n-old\n+new\n"},
+		{Path: "gone.txt", Op: WorkdirDeleted, Size: 4},
+		{Path: "out/new.txt", Op: WorkdirAdded, Size: 6, Content: "hello\n"},
+	}, changes.Files)
+
+	// past the byte budget files are still listed, without their text
+	changes, err = snap.diff(&WorkdirDiff{Exclude: []string{".git"}, MaxFiles: 2, MaxBytes: 4})
+	require.NoError(t, err)
+	assert.True(t, changes.Truncated)
+	require.Len(t, changes.Files, 2)
+	assert.True(t, changes.Files[1].Omitted)
+	assert.Empty(t, changes.Files[1].Patch)
+}
+
+func TestWorkdirDiff_Validate(t *testing.T) {
+	assert.NoError(t, (&WorkdirDiff{Include: []string{"*.go"}}).Validate())
+	assert.Error(t, (&WorkdirDiff{MaxBytes: maxWorkdirDiffMaxBytes + 1}).Validate())
+	assert.Error(t, (&WorkdirDiff{MaxFiles: -1}).Validate())
+	assert.Error(t, (&WorkdirDiff{Exclude: []string{"["}}).Validate())
+}
+
+func TestRunCommand_WorkdirDiff(t *testing.T) {
+	skipWithoutBash(t)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config"), []byte("debug=false\n"), 0o644))
+
+	var changes *WorkdirChanges
+	var events []string
+	req := &ExecuteCodeRequest{
+		Language:    Command,
+		Code:        "sed -i 's/false/true/' config; echo result > out.txt; exit 2",
+		Cwd:         dir,
+		WorkdirDiff: &WorkdirDiff{},
+		Hooks:       noopHooks(),
+	}
+	req.Hooks.OnExecuteWorkdirDiff = func(r *WorkdirChanges) {
+		events = append(events, "diff")
+		changes = r
+	}
+	req.Hooks.OnExecuteComplete = func(_ time.Duration) { events = append(events, "complete") }
+	req.Hooks.OnExecuteError = func(*execute.ErrorOutput) { events = append(events, "error") }
+	require.NoError(t, NewController("", "").Execute(req))
+
+	assert.Equal(t, []string{"diff", "error"}, events, "a failed run still reports its changes")
+	require.NotNil(t, changes)
+	require.Len(t, changes.Files, 2)
+	assert.Equal(t, WorkdirModified, changes.Files[0].Op)
+	assert.True(t, strings.Contains(changes.Files[0].Patch, "-debug=false\n+debug=true\n"), changes.Files[0].Patch)
+	assert.Equal(t, WorkdirChange{Path: "out.txt", Op: WorkdirAdded, Size: 7, Content: "result\n"}, changes.Files[1])
+}
+
+func TestRunBackgroundCommand_RejectsWorkdirDiff(t *testing.T) {
+	skipWithoutBash(t)
+	err := NewController("", "").Execute(&ExecuteCodeRequest{
+		Language:    BackgroundCommand,
+		Code:        "true",
+		WorkdirDiff: &WorkdirDiff{},
+		Hooks:       noopHooks(),
+	})
+	assert.True(t, errors.Is(err, errWorkdirDiffBackground), "got %v", err)
+}
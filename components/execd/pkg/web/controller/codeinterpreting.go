@@ -25,14 +25,41 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/alibaba/opensandbox/execd/pkg/flag"
+	"github.com/alibaba/opensandbox/execd/pkg/log"
 	"github.com/alibaba/opensandbox/execd/pkg/runtime"
+	"github.com/alibaba/opensandbox/execd/pkg/util/safego"
 	"github.com/alibaba/opensandbox/execd/pkg/web/model"
 )
 
 var codeRunner *runtime.Controller
 
+// newCodeRunner builds the runner InitCodeRunner configures. Tests replace
+// it with runtimetest.NewController to serve executions from scripts.
+var newCodeRunner = func() *runtime.Controller {
+	return runtime.NewController(flag.JupyterServerHost, flag.JupyterServerToken)
+}
+
 func InitCodeRunner() {
-	codeRunner = runtime.NewController(flag.JupyterServerHost, flag.JupyterServerToken)
+	codeRunner = newCodeRunner()
+	codeRunner.SetMaxKernels(flag.MaxKernels)
+	codeRunner.SetMaxConcurrentCommands(flag.MaxConcurrentCommands)
+	codeRunner.SetMaxCommandsPerLane(flag.MaxCommandsPerLane)
+	codeRunner.SetCommandGroupWeights(flag.CommandGroupWeights)
+	codeRunner.SetMaxScratchMB(flag.MaxScratchMB)
+	codeRunner.SetWasmRuntime(flag.WasmRuntime)
+	codeRunner.SetTranscriptDir(flag.TranscriptDir)
+	codeRunner.SetCloudEventSink(flag.CloudEventSink)
+	codeRunner.SetStopGracePeriod(flag.StopGracePeriod)
+	codeRunner.SetCommandCgroup(flag.CommandCgroup)
+	codeRunner.SetDuplicateWindow(flag.DuplicateWindow, runtime.DuplicateAction(flag.DuplicateAction))
+	codeRunner.SetKernelEvictionHandler(func(e runtime.KernelEviction) {
+		log.Warning("context %s (%s) evicted, idle since %s", e.Session, e.Language, e.LastUsed.Format(time.RFC3339))
+	})
+	codeRunner.SetKernelPool(flag.KernelPool)
+	if flag.EnvFileWatchInterval > 0 {
+		runner := codeRunner
+		safego.Go(func() { runner.WatchExtraEnvFile(context.Background(), flag.EnvFileWatchInterval) })
+	}
 }
 
 // CodeInterpretingController handles code execution entrypoints.
@@ -41,6 +68,9 @@ type CodeInterpretingController struct {
 
 	// chunkWriter serializes SSE event writes to prevent interleaved output.
 	chunkWriter sync.Mutex
+	// disconnect is called once the client is found gone; later events are dropped.
+	disconnect   context.CancelFunc
+	disconnected bool
 }
 
 func NewCodeInterpretingController(ctx *gin.Context) *CodeInterpretingController {
@@ -61,11 +91,30 @@ func (c *CodeInterpretingController) CreateContext() {
 		return
 	}
 
+	var initOutput *runtime.SessionInitOutput
 	session, err := codeRunner.CreateContext(&runtime.CreateContextRequest{
-		Language: runtime.Language(request.Language),
-		Cwd:      request.Cwd,
+		Language:     runtime.Language(request.Language),
+		Cwd:          request.Cwd,
+		InitScript:   request.InitScript,
+		OnInitOutput: func(output *runtime.SessionInitOutput) { initOutput = output },
 	})
 	if err != nil {
+		if errors.Is(err, runtime.ErrKernelLimitReached) {
+			c.RespondError(
+				http.StatusTooManyRequests,
+				model.ErrorCodeKernelLimitReached,
+				fmt.Sprintf("error creating code context. %v", err),
+			)
+			return
+		}
+		if errors.Is(err, runtime.ErrSessionInitFailed) {
+			c.RespondError(
+				http.StatusUnprocessableEntity,
+				model.ErrorCodeSessionInitFailed,
+				fmt.Sprintf("error creating code context. %v", err),
+			)
+			return
+		}
 		c.RespondError(
 			http.StatusInternalServerError,
 			model.ErrorCodeRuntimeError,
@@ -77,6 +126,7 @@ func (c *CodeInterpretingController) CreateContext() {
 	resp := model.CodeContext{
 		ID:                 session,
 		CodeContextRequest: request,
+		Init:               initOutput,
 	}
 	c.RespondSuccess(resp)
 }
@@ -110,6 +160,7 @@ func (c *CodeInterpretingController) RunCode() {
 
 	ctx, cancel := context.WithCancel(c.ctx.Request.Context())
 	defer cancel()
+	c.disconnect = cancel
 	runCodeRequest := c.buildExecuteCodeRequest(request)
 	eventsHandler := c.setServerEventsHandler(ctx)
 	runCodeRequest.Hooks = eventsHandler
@@ -117,11 +168,22 @@ func (c *CodeInterpretingController) RunCode() {
 	c.setupSSEResponse()
 	err = codeRunner.Execute(runCodeRequest)
 	if err != nil {
-		c.RespondError(
-			http.StatusInternalServerError,
-			model.ErrorCodeRuntimeError,
-			fmt.Sprintf("error running codes %v", err),
-		)
+		switch {
+		case errors.Is(err, runtime.ErrKernelLimitReached):
+			c.RespondError(
+				http.StatusTooManyRequests,
+				model.ErrorCodeKernelLimitReached,
+				fmt.Sprintf("error running codes %v", err),
+			)
+		case errors.Is(err, runtime.ErrDuplicateExecution):
+			c.RespondError(http.StatusTooManyRequests, model.ErrorCodeDuplicateExecution, err.Error())
+		default:
+			c.RespondError(
+				http.StatusInternalServerError,
+				model.ErrorCodeRuntimeError,
+				fmt.Sprintf("error running codes %v", err),
+			)
+		}
 		return
 	}
 
@@ -15,9 +15,12 @@
 package controller
 
 import (
+	"encoding/json"
+	"net/http"
 	"testing"
 
 	"github.com/alibaba/opensandbox/execd/pkg/runtime"
+	"github.com/alibaba/opensandbox/execd/pkg/runtime/runtimetest"
 	"github.com/alibaba/opensandbox/execd/pkg/web/model"
 )
 
@@ -59,3 +62,40 @@ func TestBuildExecuteCodeRequestRespectsLanguage(t *testing.T) {
 		t.Fatalf("expected python language, got %s", execReq.Language)
 	}
 }
+
+func TestRunCode_ResultEventCarriesExecutionCount(t *testing.T) {
+	useFakeRunner(t, runtimetest.Scripts(map[string]runtimetest.Script{
+		"print(1)\n2": {
+			Stdout:  []string{"1"},
+			Results: []map[string]any{{"text/plain": "2", "text/html": "<b>2</b>"}},
+		},
+	}))
+
+	body, _ := json.Marshal(model.RunCodeRequest{
+		Code:    "print(1)\n2",
+		Context: model.CodeContext{CodeContextRequest: model.CodeContextRequest{Language: "python"}},
+	})
+	ctx, w := newTestContext(http.MethodPost, "/code", body)
+	NewCodeInterpretingController(ctx).RunCode()
+
+	var stdout, result, value *model.ServerStreamEvent
+	for _, ev := range streamEvents(t, w.Body.Bytes()) {
+		switch ev.Type {
+		case model.StreamEventTypeStdout:
+			stdout = &ev
+		case model.StreamEventTypeResult:
+			result = &ev
+		case model.StreamEventTypeValue:
+			value = &ev
+		}
+	}
+	if stdout == nil || stdout.Text != "1" || stdout.Results != nil {
+		t.Fatalf("expected printed output on its own stdout event, got %+v", stdout)
+	}
+	if result == nil || result.ExecutionCount != 1 || result.Results["text"] != "2" || result.Results["text/html"] != "<b>2</b>" {
+		t.Fatalf("expected the value with its execution count on the result event, got %+v", result)
+	}
+	if value == nil || value.ExecutionCount != 1 || value.Results["text"] != "2" {
+		t.Fatalf("expected the value on its own value event, got %+v", value)
+	}
+}
@@ -16,8 +16,11 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
 	"strconv"
 	"time"
 
@@ -26,6 +29,9 @@ import (
 	"github.com/alibaba/opensandbox/execd/pkg/web/model"
 )
 
+// maxStdinChunkBytes caps a single stdin write request.
+const maxStdinChunkBytes = 1 << 20
+
 // RunCommand executes a shell command and streams the output via SSE.
 func (c *CodeInterpretingController) RunCommand() {
 	var request model.RunCommandRequest
@@ -48,16 +54,72 @@ func (c *CodeInterpretingController) RunCommand() {
 		return
 	}
 
+	if err := codeRunner.CheckScratchSize(request.ScratchMB); err != nil {
+		c.RespondError(http.StatusBadRequest, model.ErrorCodeInvalidRequest, err.Error())
+		return
+	}
+	if err := codeRunner.CheckRLimitNofile(request.RLimitNofile); err != nil {
+		c.RespondError(http.StatusBadRequest, model.ErrorCodeInvalidRequest, err.Error())
+		return
+	}
+	if err := codeRunner.CheckReadOnlyFS(request.ReadOnlyFS); err != nil {
+		c.RespondError(http.StatusBadRequest, model.ErrorCodeInvalidRequest, err.Error())
+		return
+	}
+	if err := codeRunner.CheckUmask(request.Umask); err != nil {
+		c.RespondError(http.StatusBadRequest, model.ErrorCodeInvalidRequest, err.Error())
+		return
+	}
+	if err := codeRunner.CheckLocale(request.Locale, request.Timezone); err != nil {
+		c.RespondError(http.StatusBadRequest, model.ErrorCodeInvalidRequest, err.Error())
+		return
+	}
+	if err := codeRunner.CheckPriority(request.Nice, request.IOPriority); err != nil {
+		c.RespondError(http.StatusBadRequest, model.ErrorCodeInvalidRequest, err.Error())
+		return
+	}
+
+	runCodeRequest := c.buildExecuteCommandRequest(request)
+	if err := codeRunner.CheckWasm(runCodeRequest); err != nil {
+		c.RespondError(http.StatusBadRequest, model.ErrorCodeInvalidRequest, err.Error())
+		return
+	}
+	if runCodeRequest.Artifacts != nil {
+		if err := runCodeRequest.Artifacts.Validate(); err != nil {
+			c.RespondError(http.StatusBadRequest, model.ErrorCodeInvalidRequest, err.Error())
+			return
+		}
+	}
+	if runCodeRequest.WorkdirDiff != nil {
+		if err := runCodeRequest.WorkdirDiff.Validate(); err != nil {
+			c.RespondError(http.StatusBadRequest, model.ErrorCodeInvalidRequest, err.Error())
+			return
+		}
+	}
+
+	if err := runtime.ValidateCACerts(runCodeRequest.CACerts); err != nil {
+		c.RespondError(http.StatusBadRequest, model.ErrorCodeInvalidRequest, err.Error())
+		return
+	}
+	if err := runtime.ValidateOutputEncoding(runCodeRequest.OutputEncoding); err != nil {
+		c.RespondError(http.StatusBadRequest, model.ErrorCodeInvalidRequest, err.Error())
+		return
+	}
+
 	ctx, cancel := context.WithCancel(c.ctx.Request.Context())
 	defer cancel()
+	c.disconnect = cancel
 
-	runCodeRequest := c.buildExecuteCommandRequest(request)
 	eventsHandler := c.setServerEventsHandler(ctx)
 	runCodeRequest.Hooks = eventsHandler
 
 	c.setupSSEResponse()
-	err = codeRunner.Execute(runCodeRequest)
+	err = codeRunner.ExecuteContext(ctx, runCodeRequest)
 	if err != nil {
+		if errors.Is(err, runtime.ErrDuplicateExecution) {
+			c.RespondError(http.StatusTooManyRequests, model.ErrorCodeDuplicateExecution, err.Error())
+			return
+		}
 		c.RespondError(
 			http.StatusInternalServerError,
 			model.ErrorCodeRuntimeError,
@@ -74,6 +136,31 @@ func (c *CodeInterpretingController) InterruptCommand() {
 	c.interrupt()
 }
 
+// StopCommands stops the listed commands and those of a group together,
+// sharing one grace period between SIGTERM and SIGKILL.
+func (c *CodeInterpretingController) StopCommands() {
+	var request model.StopCommandsRequest
+	if err := c.bindJSON(&request); err != nil {
+		c.RespondError(http.StatusBadRequest, model.ErrorCodeInvalidRequest, fmt.Sprintf("error parsing request, MAYBE invalid body format. %v", err))
+		return
+	}
+	if err := request.Validate(); err != nil {
+		c.RespondError(http.StatusBadRequest, model.ErrorCodeInvalidRequest, fmt.Sprintf("invalid request, validation error %v", err))
+		return
+	}
+
+	sessions := request.Sessions
+	if request.GroupID != "" {
+		for _, session := range codeRunner.GroupCommands(request.GroupID) {
+			if !slices.Contains(sessions, session) {
+				sessions = append(sessions, session)
+			}
+		}
+	}
+	grace := time.Duration(request.GracePeriodMs) * time.Millisecond
+	c.RespondSuccess(model.StopCommandsResponse{Outcomes: codeRunner.StopSessions(sessions, grace)})
+}
+
 // GetCommandStatus returns command status by id.
 func (c *CodeInterpretingController) GetCommandStatus() {
 	commandID := c.ctx.Param("id")
@@ -89,11 +176,14 @@ func (c *CodeInterpretingController) GetCommandStatus() {
 	}
 
 	resp := model.CommandStatusResponse{
-		ID:       status.Session,
-		Running:  status.Running,
-		ExitCode: status.ExitCode,
-		Error:    status.Error,
-		Content:  status.Content,
+		ID:         status.Session,
+		State:      string(status.State),
+		Running:    status.Running,
+		ExitCode:   status.ExitCode,
+		Error:      status.Error,
+		Content:    status.Content,
+		OutputTail: status.OutputTail,
+		Warnings:   status.Warnings,
 	}
 	if !status.StartedAt.IsZero() {
 		resp.StartedAt = status.StartedAt
@@ -125,18 +215,186 @@ func (c *CodeInterpretingController) GetBackgroundCommandOutput() {
 	c.ctx.String(http.StatusOK, "%s", output)
 }
 
+// GetCapabilities reports which command options work on this execd.
+func (c *CodeInterpretingController) GetCapabilities() {
+	c.RespondSuccess(model.NewCapabilitiesResponse(codeRunner.Capabilities()))
+}
+
+// WriteCommandStdin forwards the request body to a background command's stdin.
+func (c *CodeInterpretingController) WriteCommandStdin() {
+	id := c.ctx.Param("id")
+	data, err := io.ReadAll(io.LimitReader(c.ctx.Request.Body, maxStdinChunkBytes+1))
+	if err != nil {
+		c.RespondError(http.StatusBadRequest, model.ErrorCodeInvalidRequest, fmt.Sprintf("error reading stdin body: %v", err))
+		return
+	}
+	if len(data) > maxStdinChunkBytes {
+		c.RespondError(http.StatusRequestEntityTooLarge, model.ErrorCodeInvalidRequest,
+			fmt.Sprintf("stdin chunk exceeds %d bytes", maxStdinChunkBytes))
+		return
+	}
+
+	written, err := codeRunner.WriteStdin(id, data)
+	if err != nil {
+		c.respondStdinError(err, written)
+		return
+	}
+	c.RespondSuccess(model.CommandStdinResponse{Written: written})
+}
+
+// CloseCommandStdin closes a background command's stdin so it reads EOF.
+func (c *CodeInterpretingController) CloseCommandStdin() {
+	if err := codeRunner.CloseStdin(c.ctx.Param("id")); err != nil {
+		c.respondStdinError(err, 0)
+		return
+	}
+	c.RespondSuccess(nil)
+}
+
+func (c *CodeInterpretingController) respondStdinError(err error, written int) {
+	switch {
+	case errors.Is(err, runtime.ErrStdinUnavailable):
+		c.RespondError(http.StatusConflict, model.ErrorCodeStdinUnavailable, err.Error())
+	case errors.Is(err, runtime.ErrStdinClosed):
+		c.RespondError(http.StatusConflict, model.ErrorCodeStdinClosed, err.Error())
+	case errors.Is(err, runtime.ErrStdinBusy):
+		c.ctx.Header("EXECD-STDIN-WRITTEN", strconv.Itoa(written))
+		c.RespondError(http.StatusServiceUnavailable, model.ErrorCodeStdinBusy, err.Error())
+	case errors.Is(err, runtime.ErrCommandNotFound):
+		c.RespondError(http.StatusNotFound, model.ErrorCodeInvalidRequest, err.Error())
+	default:
+		c.RespondError(http.StatusInternalServerError, model.ErrorCodeRuntimeError, err.Error())
+	}
+}
+
+func outputSink(sink *model.OutputSinkRequest) *runtime.OutputSink {
+	if sink == nil {
+		return nil
+	}
+	return &runtime.OutputSink{URL: sink.URL, Headers: sink.Headers, Tee: sink.Tee}
+}
+
+func artifactScan(artifacts *model.ArtifactsRequest) *runtime.ArtifactScan {
+	if artifacts == nil {
+		return nil
+	}
+	return &runtime.ArtifactScan{
+		Dir:      artifacts.Dir,
+		Include:  artifacts.Include,
+		Exclude:  artifacts.Exclude,
+		MaxFiles: artifacts.MaxFiles,
+	}
+}
+
+func workdirDiff(diff *model.WorkdirDiffRequest) *runtime.WorkdirDiff {
+	if diff == nil {
+		return nil
+	}
+	return &runtime.WorkdirDiff{
+		Include:  diff.Include,
+		Exclude:  diff.Exclude,
+		MaxFiles: diff.MaxFiles,
+		MaxBytes: diff.MaxBytes,
+	}
+}
+
+func exitClassifier(classify *model.ExitClassifyRequest) runtime.Classifier {
+	if classify == nil {
+		return nil
+	}
+	rules := &runtime.ExitRules{
+		Success:          classify.Success,
+		Retryable:        classify.Retryable,
+		RetryableSignals: classify.RetryableSignals,
+		RetryableStderr:  classify.RetryableStderr,
+	}
+	return rules.Classify
+}
+
+func postCommand(post *model.PostCommandRequest) *runtime.PostCommand {
+	if post == nil {
+		return nil
+	}
+	return &runtime.PostCommand{
+		Code:    post.Command,
+		Timeout: time.Duration(post.TimeoutMs) * time.Millisecond,
+	}
+}
+
+func outputCoalesce(coalesce *model.OutputCoalesceRequest) *runtime.OutputCoalesce {
+	if coalesce == nil {
+		return nil
+	}
+	return &runtime.OutputCoalesce{
+		MaxBytes:    coalesce.MaxBytes,
+		MinInterval: time.Duration(coalesce.MinIntervalMs) * time.Millisecond,
+		MaxInterval: time.Duration(coalesce.MaxIntervalMs) * time.Millisecond,
+	}
+}
+
+// outputDigest expects the ignore patterns to have passed Validate.
+func outputDigest(digest *model.OutputDigestRequest) *runtime.OutputDigest {
+	if digest == nil {
+		return nil
+	}
+	ignore, _ := runtime.CompileOutputDigestIgnore(digest.Ignore)
+	return &runtime.OutputDigest{
+		Normalize: digest.Normalize,
+		Ignore:    ignore,
+		Previous:  digest.Previous,
+	}
+}
+
 func (c *CodeInterpretingController) buildExecuteCommandRequest(request model.RunCommandRequest) *runtime.ExecuteCodeRequest {
 	if request.Background {
 		return &runtime.ExecuteCodeRequest{
-			Language: runtime.BackgroundCommand,
-			Code:     request.Command,
-			Cwd:      request.Cwd,
+			Language:     runtime.BackgroundCommand,
+			Code:         request.Command,
+			Cwd:          request.Cwd,
+			DryRun:       request.DryRun,
+			ScratchMB:    request.ScratchMB,
+			ReadOnlyFS:   request.ReadOnlyFS,
+			Stdin:        request.Stdin,
+			RLimitNofile: request.RLimitNofile,
+			Umask:        request.Umask,
+			Locale:       request.Locale,
+			Timezone:     request.Timezone,
+			Nice:         request.Nice,
+			IOPriority:   request.IOPriority,
+			Wasm:         request.Wasm,
+			CACerts:      request.CACerts,
 		}
 	} else {
 		return &runtime.ExecuteCodeRequest{
-			Language: runtime.Command,
-			Code:     request.Command,
-			Cwd:      request.Cwd,
+			Language:            runtime.Command,
+			Code:                request.Command,
+			Cwd:                 request.Cwd,
+			DryRun:              request.DryRun,
+			GroupID:             request.GroupID,
+			Lane:                request.Lane,
+			ScratchMB:           request.ScratchMB,
+			ReadOnlyFS:          request.ReadOnlyFS,
+			RLimitNofile:        request.RLimitNofile,
+			Umask:               request.Umask,
+			Locale:              request.Locale,
+			Timezone:            request.Timezone,
+			Nice:                request.Nice,
+			IOPriority:          request.IOPriority,
+			OutputSink:          outputSink(request.OutputSink),
+			Wasm:                request.Wasm,
+			OutputRateLimit:     request.OutputRateLimit,
+			Artifacts:           artifactScan(request.Artifacts),
+			Classify:            exitClassifier(request.Classify),
+			CACerts:             request.CACerts,
+			OutputEncoding:      request.OutputEncoding,
+			OutputHighWatermark: request.OutputHighWatermark,
+			PostCommand:         postCommand(request.PostCommand),
+			OutputCoalesce:      outputCoalesce(request.OutputCoalesce),
+			OutputDigest:        outputDigest(request.OutputDigest),
+			CancelOnDisconnect:  request.CancelOnDisconnect,
+			Summary:             request.Summary,
+			WorkdirDiff:         workdirDiff(request.WorkdirDiff),
+			RedactSecrets:       request.RedactSecrets,
 		}
 	}
 }
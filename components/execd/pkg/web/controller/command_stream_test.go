@@ -0,0 +1,261 @@
+// Copyright 2026 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alibaba/opensandbox/execd/pkg/runtime"
+	"github.com/alibaba/opensandbox/execd/pkg/runtime/runtimetest"
+	"github.com/alibaba/opensandbox/execd/pkg/web/model"
+)
+
+// useFakeRunner serves executions from backend for the rest of the test.
+func useFakeRunner(t *testing.T, backend *runtimetest.Backend) {
+	t.Helper()
+	origFactory, origRunner := newCodeRunner, codeRunner
+	newCodeRunner = func() *runtime.Controller { return runtimetest.NewController(backend) }
+	InitCodeRunner()
+	t.Cleanup(func() { newCodeRunner, codeRunner = origFactory, origRunner })
+}
+
+// streamEvents splits an SSE response body into its events.
+func streamEvents(t *testing.T, body []byte) []model.ServerStreamEvent {
+	t.Helper()
+	var events []model.ServerStreamEvent
+	for _, frame := range bytes.Split(bytes.TrimSpace(body), []byte("\n\n")) {
+		var ev model.ServerStreamEvent
+		if err := json.Unmarshal(frame, &ev); err != nil {
+			t.Fatalf("invalid event %q: %v", frame, err)
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestRunCommand_StreamsScriptedExecution(t *testing.T) {
+	backend := runtimetest.Scripts(map[string]runtimetest.Script{
+		"pytest": {Stdout: []string{"collected 3 items", "3 passed"}, Stderr: []string{"deprecated"}},
+		"false":  {ExitCode: 1},
+	})
+	useFakeRunner(t, backend)
+
+	cases := map[string][]model.ServerStreamEventType{
+		"pytest": {model.StreamEventTypeInit, model.StreamEventTypeStdout, model.StreamEventTypeStdout, model.StreamEventTypeStderr, model.StreamEventTypeComplete},
+		"false":  {model.StreamEventTypeInit, model.StreamEventTypeError},
+	}
+	for command, want := range cases {
+		body, _ := json.Marshal(model.RunCommandRequest{Command: command, Cwd: "/workspace"})
+		ctx, w := newTestContext(http.MethodPost, "/command", body)
+		NewCodeInterpretingController(ctx).RunCommand()
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", command, w.Code, w.Body.String())
+		}
+		var got []model.ServerStreamEventType
+		for _, ev := range streamEvents(t, w.Body.Bytes()) {
+			got = append(got, ev.Type)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s: expected events %v, got %v", command, want, got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%s: expected events %v, got %v", command, want, got)
+			}
+		}
+	}
+
+	requests := backend.Requests()
+	if len(requests) != 2 || requests[0].Cwd != "/workspace" || requests[0].Language != runtime.Command {
+		t.Fatalf("unexpected requests reaching the runtime: %+v", requests)
+	}
+}
+
+func TestRunCommand_EveryEventCarriesSession(t *testing.T) {
+	useFakeRunner(t, runtimetest.Scripts(map[string]runtimetest.Script{
+		"build": {Stdout: []string{"compiling", "linking"}, Stderr: []string{"warning"}},
+		"false": {ExitCode: 1},
+	}))
+
+	seen := map[string]string{}
+	for _, command := range []string{"build", "build", "false"} {
+		body, _ := json.Marshal(model.RunCommandRequest{Command: command})
+		ctx, w := newTestContext(http.MethodPost, "/command", body)
+		NewCodeInterpretingController(ctx).RunCommand()
+
+		events := streamEvents(t, w.Body.Bytes())
+		if len(events) < 2 || events[0].Type != model.StreamEventTypeInit || events[0].Text == "" {
+			t.Fatalf("%s: expected an init event first, got %+v", command, events)
+		}
+		session := events[0].Text
+		for _, ev := range events {
+			if ev.Session != session {
+				t.Fatalf("%s: %s event carries session %q, want %q", command, ev.Type, ev.Session, session)
+			}
+		}
+		if prev, ok := seen[session]; ok {
+			t.Fatalf("%s reused the session of %s", command, prev)
+		}
+		seen[session] = command
+	}
+}
+
+func TestRunCommand_ClassifiesExitOnFinalEvent(t *testing.T) {
+	useFakeRunner(t, runtimetest.Scripts(map[string]runtimetest.Script{
+		"ok":      {},
+		"flaky":   {ExitCode: 2},
+		"broken":  {ExitCode: 3},
+		"network": {ExitCode: 1, Stderr: []string{"error: connection reset by peer"}},
+		"oom":     {Signal: "SIGKILL"},
+	}))
+	classify := &model.ExitClassifyRequest{
+		Retryable:        []int{2},
+		RetryableSignals: []string{"KILL"},
+		RetryableStderr:  []string{"connection reset"},
+	}
+
+	cases := map[string]struct {
+		last model.ServerStreamEventType
+		want runtime.ExitCategory
+	}{
+		"ok":      {model.StreamEventTypeComplete, runtime.ExitSuccess},
+		"flaky":   {model.StreamEventTypeError, runtime.ExitRetryable},
+		"broken":  {model.StreamEventTypeError, runtime.ExitFatal},
+		"network": {model.StreamEventTypeError, runtime.ExitRetryable},
+		"oom":     {model.StreamEventTypeError, runtime.ExitRetryable},
+	}
+	for command, tc := range cases {
+		body, _ := json.Marshal(model.RunCommandRequest{Command: command, Classify: classify})
+		ctx, w := newTestContext(http.MethodPost, "/command", body)
+		NewCodeInterpretingController(ctx).RunCommand()
+
+		events := streamEvents(t, w.Body.Bytes())
+		last := events[len(events)-1]
+		if last.Type != tc.last || last.ExitCategory != tc.want {
+			t.Fatalf("%s: expected a %s event with category %s, got %+v", command, tc.last, tc.want, last)
+		}
+		for _, ev := range events[:len(events)-1] {
+			if ev.ExitCategory != "" {
+				t.Fatalf("%s: category set on a %s event", command, ev.Type)
+			}
+		}
+	}
+
+	// without classify no category is reported
+	body, _ := json.Marshal(model.RunCommandRequest{Command: "flaky"})
+	ctx, w := newTestContext(http.MethodPost, "/command", body)
+	NewCodeInterpretingController(ctx).RunCommand()
+	if events := streamEvents(t, w.Body.Bytes()); events[len(events)-1].ExitCategory != "" {
+		t.Fatalf("unexpected category without classify: %+v", events[len(events)-1])
+	}
+}
+
+// brokenPipeWriter lets the first ok writes through, then fails the way
+// writing to a connection the client closed does.
+type brokenPipeWriter struct {
+	gin.ResponseWriter
+	ok     int
+	writes int
+}
+
+func (w *brokenPipeWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes > w.ok {
+		return 0, &net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// lastTranscript records the transcript of each execution for the rest of the test.
+func lastTranscript(t *testing.T) func() *runtime.Transcript {
+	t.Helper()
+	var mu sync.Mutex
+	var last *runtime.Transcript
+	codeRunner.SetTranscriptHandler(func(tr *runtime.Transcript) {
+		mu.Lock()
+		last = tr
+		mu.Unlock()
+	})
+	return func() *runtime.Transcript {
+		mu.Lock()
+		defer mu.Unlock()
+		return last
+	}
+}
+
+func TestRunCommand_ClientGoneMidStream(t *testing.T) {
+	useFakeRunner(t, runtimetest.Scripts(map[string]runtimetest.Script{
+		"tail": {Stdout: []string{"one", "two", "three", "four"}},
+	}))
+	transcript := lastTranscript(t)
+
+	body, _ := json.Marshal(model.RunCommandRequest{Command: "tail"})
+	ctx, _ := newTestContext(http.MethodPost, "/command", body)
+	writer := &brokenPipeWriter{ResponseWriter: ctx.Writer, ok: 2}
+	ctx.Writer = writer
+	NewCodeInterpretingController(ctx).RunCommand()
+
+	if writer.writes != 3 {
+		t.Fatalf("expected streaming to stop at the broken pipe, got %d writes", writer.writes)
+	}
+	tr := transcript()
+	if tr == nil || tr.Error != nil || tr.Metadata["executeError"] != "" {
+		t.Fatalf("expected the execution to end cleanly, got %+v", tr)
+	}
+	if tr.Stdout != "one\ntwo\nthree\nfour\n" {
+		t.Fatalf("expected the command to run to its end, got stdout %q", tr.Stdout)
+	}
+}
+
+func TestRunCommand_CancelOnDisconnect(t *testing.T) {
+	useFakeRunner(t, runtimetest.Scripts(map[string]runtimetest.Script{
+		"watch": {Delay: 300 * time.Millisecond, Stdout: []string{"done"}},
+	}))
+	transcript := lastTranscript(t)
+
+	run := func(cancelOnDisconnect bool) (*runtime.Transcript, time.Duration) {
+		body, _ := json.Marshal(model.RunCommandRequest{Command: "watch", CancelOnDisconnect: cancelOnDisconnect})
+		ctx, _ := newTestContext(http.MethodPost, "/command", body)
+		reqCtx, hangUp := context.WithCancel(ctx.Request.Context())
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+		timer := time.AfterFunc(50*time.Millisecond, hangUp)
+		defer timer.Stop()
+
+		start := time.Now()
+		NewCodeInterpretingController(ctx).RunCommand()
+		return transcript(), time.Since(start)
+	}
+
+	tr, elapsed := run(false)
+	if tr.Error != nil || tr.Stdout != "done\n" || elapsed < 300*time.Millisecond {
+		t.Fatalf("expected the command to outlive its client by default, got %+v after %v", tr, elapsed)
+	}
+	tr, elapsed = run(true)
+	if tr.Error == nil || tr.Stdout != "" || elapsed >= 300*time.Millisecond {
+		t.Fatalf("expected the command to be stopped with its client, got %+v after %v", tr, elapsed)
+	}
+}
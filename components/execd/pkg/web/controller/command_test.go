@@ -18,8 +18,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	goruntime "runtime"
 	"testing"
 
+	"github.com/alibaba/opensandbox/execd/pkg/runtime/runtimetest"
 	"github.com/alibaba/opensandbox/execd/pkg/web/model"
 )
 
@@ -70,3 +72,30 @@ func TestGetBackgroundCommandOutput_MissingID(t *testing.T) {
 		t.Fatalf("unexpected message: %s", resp.Message)
 	}
 }
+
+func TestGetCapabilities(t *testing.T) {
+	useFakeRunner(t, runtimetest.Scripts(nil))
+	ctrl, w := setupCommandController(http.MethodGet, "/capabilities")
+
+	ctrl.GetCapabilities()
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var resp model.CapabilitiesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if resp.OS != goruntime.GOOS || resp.Arch != goruntime.GOARCH {
+		t.Fatalf("expected the platform of execd, got %s/%s", resp.OS, resp.Arch)
+	}
+	for _, field := range []string{"stdin", "wasm", "read_only_fs", "scratch_mb", "rlimit_nofile", "nice", "io_priority", "daemon_tracking"} {
+		c, ok := resp.Features[field]
+		if !ok {
+			t.Fatalf("expected feature %s to be reported, got %v", field, resp.Features)
+		}
+		if !c.Available && c.Reason == "" {
+			t.Fatalf("expected a reason for the unavailable feature %s", field)
+		}
+	}
+}
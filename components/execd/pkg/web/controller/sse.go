@@ -16,8 +16,12 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"io"
+	"net"
 	"net/http"
+	"sync"
+	"syscall"
 	"time"
 
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -47,77 +51,154 @@ func (c *basicController) setupSSEResponse() {
 
 // setServerEventsHandler adapts runtime callbacks to SSE events.
 func (c *CodeInterpretingController) setServerEventsHandler(ctx context.Context) runtime.ExecuteResultHook {
+	// the sink result, artifacts manifest, exit category, output digest, summary, workdir diff and warnings ride on the complete or error event that follows them
+	var output *runtime.OutputSinkResult
+	var artifacts *runtime.ArtifactManifest
+	var category runtime.ExitCategory
+	var digest *runtime.OutputDigestResult
+	var summary *runtime.ExecutionSummary
+	var changes *runtime.WorkdirChanges
+	var warnings []runtime.ExecutionWarning
+	// the session announced by init is stamped on every later event so clients
+	// multiplexing executions can demux them
+	var sessionMu sync.RWMutex
+	var session string
+	stamp := func(event model.ServerStreamEvent) []byte {
+		sessionMu.RLock()
+		event.Session = session
+		sessionMu.RUnlock()
+		return event.ToJSON()
+	}
 	return runtime.ExecuteResultHook{
-		OnExecuteInit: func(session string) {
-			payload := model.ServerStreamEvent{
+		OnExecuteInit: func(id string) {
+			sessionMu.Lock()
+			session = id
+			sessionMu.Unlock()
+			payload := stamp(model.ServerStreamEvent{
 				Type:      model.StreamEventTypeInit,
-				Text:      session,
+				Text:      id,
 				Timestamp: time.Now().UnixMilli(),
-			}.ToJSON()
+			})
 
 			c.writeSingleEvent("OnExecuteInit", payload, true)
 
-			safego.Go(func() { c.ping(ctx) })
+			safego.Go(func() { c.ping(ctx, stamp) })
 		},
 		OnExecuteResult: func(result map[string]any, count int) {
-			var mutated map[string]any
-			if len(result) > 0 {
-				mutated = make(map[string]any)
-				for k, v := range result {
-					switch k {
-					case "text/plain":
-						mutated["text"] = v
-					default:
-						mutated[k] = v
-					}
-				}
-			}
+			mutated := streamResults(result)
 
 			if count > 0 {
-				payload := model.ServerStreamEvent{
+				payload := stamp(model.ServerStreamEvent{
 					Type:           model.StreamEventTypeCount,
 					ExecutionCount: count,
 					Timestamp:      time.Now().UnixMilli(),
-				}.ToJSON()
+				})
 				c.writeSingleEvent("OnExecuteResult", payload, true)
 			}
 			if len(mutated) > 0 {
-				payload := model.ServerStreamEvent{
-					Type:      model.StreamEventTypeResult,
-					Results:   mutated,
-					Timestamp: time.Now().UnixMilli(),
-				}.ToJSON()
+				// the value carries its cell's count, so a client collecting
+				// results does not have to pair it with the count event
+				payload := stamp(model.ServerStreamEvent{
+					Type:           model.StreamEventTypeResult,
+					ExecutionCount: count,
+					Results:        mutated,
+					Timestamp:      time.Now().UnixMilli(),
+				})
 				c.writeSingleEvent("OnExecuteResult", payload, true)
 			}
 		},
+		OnExecuteOutputSink: func(result *runtime.OutputSinkResult) {
+			output = result
+		},
+		OnExecuteArtifacts: func(manifest *runtime.ArtifactManifest) {
+			artifacts = manifest
+		},
+		OnExecuteExitCategory: func(verdict runtime.ExitCategory) {
+			category = verdict
+		},
+		OnExecuteOutputDigest: func(result *runtime.OutputDigestResult) {
+			digest = result
+		},
+		OnExecuteSummary: func(result *runtime.ExecutionSummary) {
+			summary = result
+		},
+		OnExecuteWorkdirDiff: func(result *runtime.WorkdirChanges) {
+			changes = result
+		},
+		OnExecuteWarnings: func(result []runtime.ExecutionWarning) {
+			warnings = result
+		},
 		OnExecuteComplete: func(executionTime time.Duration) {
-			payload := model.ServerStreamEvent{
+			payload := stamp(model.ServerStreamEvent{
 				Type:          model.StreamEventTypeComplete,
 				ExecutionTime: executionTime.Milliseconds(),
 				Timestamp:     time.Now().UnixMilli(),
-			}.ToJSON()
+				Output:        output,
+				Artifacts:     artifacts,
+				ExitCategory:  category,
+				OutputDigest:  digest,
+				Summary:       summary,
+				WorkdirDiff:   changes,
+				Warnings:      warnings,
+			})
 
 			c.writeSingleEvent("OnExecuteComplete", payload, true)
 		},
+		OnExecuteValue: func(value *execute.ExecuteResult) {
+			payload := stamp(model.ServerStreamEvent{
+				Type:           model.StreamEventTypeValue,
+				ExecutionCount: value.ExecutionCount,
+				Results:        streamResults(value.Data),
+				Metadata:       value.Metadata,
+				Timestamp:      time.Now().UnixMilli(),
+			})
+
+			c.writeSingleEvent("OnExecuteValue", payload, true)
+		},
+		OnExecutePostCommand: func(result *runtime.PostCommandResult) {
+			payload := stamp(model.ServerStreamEvent{
+				Type:        model.StreamEventTypePostCommand,
+				PostCommand: result,
+				Timestamp:   time.Now().UnixMilli(),
+			})
+
+			c.writeSingleEvent("OnExecutePostCommand", payload, true)
+		},
+		OnExecuteDryRun: func(resolved *runtime.ResolvedCommand) {
+			payload := stamp(model.ServerStreamEvent{
+				Type:      model.StreamEventTypeDryRun,
+				Command:   resolved,
+				Timestamp: time.Now().UnixMilli(),
+			})
+
+			c.writeSingleEvent("OnExecuteDryRun", payload, true)
+		},
 		OnExecuteError: func(err *execute.ErrorOutput) {
 			if err == nil {
 				return
 			}
 
-			payload := model.ServerStreamEvent{
-				Type:      model.StreamEventTypeError,
-				Error:     err,
-				Timestamp: time.Now().UnixMilli(),
-			}.ToJSON()
+			payload := stamp(model.ServerStreamEvent{
+				Type:         model.StreamEventTypeError,
+				Error:        err,
+				Timestamp:    time.Now().UnixMilli(),
+				Output:       output,
+				Artifacts:    artifacts,
+				ExitCategory: category,
+				OutputDigest: digest,
+				Summary:      summary,
+				WorkdirDiff:  changes,
+				Warnings:     warnings,
+			})
 
 			c.writeSingleEvent("OnExecuteError", payload, true)
 		},
 		OnExecuteStatus: func(status string) {
-			payload := model.ServerStreamEvent{
+			payload := stamp(model.ServerStreamEvent{
 				Type:      model.StreamEventTypeStatus,
 				Text:      status,
 				Timestamp: time.Now().UnixMilli(),
-			}.ToJSON()
+			})
 
 			c.writeSingleEvent("OnExecuteStatus", payload, true)
 		},
@@ -126,11 +207,11 @@ func (c *CodeInterpretingController) setServerEventsHandler(ctx context.Context)
 				return
 			}
 
-			payload := model.ServerStreamEvent{
+			payload := stamp(model.ServerStreamEvent{
 				Type:      model.StreamEventTypeStdout,
 				Text:      text,
 				Timestamp: time.Now().UnixMilli(),
-			}.ToJSON()
+			})
 
 			c.writeSingleEvent("OnExecuteStdout", payload, true)
 		},
@@ -139,32 +220,52 @@ func (c *CodeInterpretingController) setServerEventsHandler(ctx context.Context)
 				return
 			}
 
-			payload := model.ServerStreamEvent{
+			payload := stamp(model.ServerStreamEvent{
 				Type:      model.StreamEventTypeStderr,
 				Text:      text,
 				Timestamp: time.Now().UnixMilli(),
-			}.ToJSON()
+			})
 
 			c.writeSingleEvent("OnExecuteStderr", payload, true)
 		},
 	}
 }
 
+// streamResults renames a MIME bundle's text/plain entry to text, as clients
+// of the result and value events expect.
+func streamResults(result map[string]any) map[string]any {
+	if len(result) == 0 {
+		return nil
+	}
+	mutated := make(map[string]any, len(result))
+	for k, v := range result {
+		switch k {
+		case "text/plain":
+			mutated["text"] = v
+		default:
+			mutated[k] = v
+		}
+	}
+	return mutated
+}
+
 // writeSingleEvent serializes one SSE frame.
 func (c *CodeInterpretingController) writeSingleEvent(handler string, data []byte, verbose bool) {
 	if c == nil || c.ctx == nil || c.ctx.Writer == nil {
 		return
 	}
 
+	c.chunkWriter.Lock()
+	defer c.chunkWriter.Unlock()
+	if c.disconnected {
+		return
+	}
 	select {
 	case <-c.ctx.Request.Context().Done():
-		log.Error("StreamEvent.%s: client disconnected", handler)
+		c.clientGone(handler, c.ctx.Request.Context().Err())
 		return
 	default:
 	}
-
-	c.chunkWriter.Lock()
-	defer c.chunkWriter.Unlock()
 	defer func() {
 		if flusher, ok := c.ctx.Writer.(http.Flusher); ok {
 			flusher.Flush()
@@ -178,6 +279,10 @@ func (c *CodeInterpretingController) writeSingleEvent(handler string, data []byt
 	}
 
 	if err != nil {
+		if isClientGone(err) {
+			c.clientGone(handler, err)
+			return
+		}
 		log.Error("StreamEvent.%s write data %s error: %v", handler, string(data), err)
 	} else {
 		if verbose {
@@ -186,17 +291,35 @@ func (c *CodeInterpretingController) writeSingleEvent(handler string, data []byt
 	}
 }
 
+// clientGone stops streaming to a client that went away. The execution is
+// not failed for it: it carries on unless the request asked to be cancelled
+// on disconnect, which the cancelled request context takes care of. Called
+// with chunkWriter held.
+func (c *CodeInterpretingController) clientGone(handler string, err error) {
+	c.disconnected = true
+	log.Warning("StreamEvent.%s: client disconnected (%v), dropping the rest of the stream", handler, err)
+	if c.disconnect != nil {
+		c.disconnect()
+	}
+}
+
+// isClientGone tells write errors of a closed connection from real failures.
+func isClientGone(err error) bool {
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrClosedPipe)
+}
+
 // ping periodically keeps the SSE connection alive.
-func (c *CodeInterpretingController) ping(ctx context.Context) {
+func (c *CodeInterpretingController) ping(ctx context.Context, stamp func(model.ServerStreamEvent) []byte) {
 	wait.Until(func() {
 		if c.ctx.Writer == nil {
 			return
 		}
-		payload := model.ServerStreamEvent{
+		payload := stamp(model.ServerStreamEvent{
 			Type:      model.StreamEventTypePing,
 			Text:      "pong",
 			Timestamp: time.Now().UnixMilli(),
-		}.ToJSON()
+		})
 		c.writeSingleEvent("Ping", payload, false)
 	}, 3*time.Second, ctx.Done())
 }
@@ -16,10 +16,12 @@ package model
 
 import (
 	"encoding/json"
+	"errors"
 
 	"github.com/go-playground/validator/v10"
 
 	"github.com/alibaba/opensandbox/execd/pkg/jupyter/execute"
+	"github.com/alibaba/opensandbox/execd/pkg/runtime"
 )
 
 // RunCodeRequest represents a code execution request.
@@ -37,11 +39,14 @@ func (r *RunCodeRequest) Validate() error {
 type CodeContext struct {
 	ID                 string `json:"id,omitempty"`
 	CodeContextRequest `json:",inline"`
+	// Init carries the init script output; only set when creating a context.
+	Init *runtime.SessionInitOutput `json:"init,omitempty"`
 }
 
 type CodeContextRequest struct {
-	Language string `json:"language,omitempty"`
-	Cwd      string `json:"cwd,omitempty"`
+	Language   string `json:"language,omitempty"`
+	Cwd        string `json:"cwd,omitempty"`
+	InitScript string `json:"init_script,omitempty"`
 }
 
 // RunCommandRequest represents a shell command execution request.
@@ -49,9 +54,189 @@ type RunCommandRequest struct {
 	Command    string `json:"command" validate:"required"`
 	Cwd        string `json:"cwd,omitempty"`
 	Background bool   `json:"background,omitempty"`
+	DryRun     bool   `json:"dry_run,omitempty"`
+	// GroupID is the tenant the command is queued under at the concurrency limit.
+	GroupID string `json:"group_id,omitempty"`
+	// Lane queues the command behind running commands of the same lane, up to the per-lane limit.
+	Lane string `json:"lane,omitempty"`
+	// ScratchMB mounts a private tmpfs of this size in MiB as TMPDIR for the command.
+	ScratchMB int `json:"scratch_mb,omitempty" validate:"gte=0"`
+	// ReadOnlyFS runs the command with every mount read-only except its scratch space or a temp dir.
+	ReadOnlyFS bool `json:"read_only_fs,omitempty"`
+	// Stdin keeps a background command's stdin open for POST /command/{id}/stdin.
+	Stdin bool `json:"stdin,omitempty"`
+	// RLimitNofile caps the command's open file descriptors; 0 inherits execd's limit.
+	RLimitNofile uint64 `json:"rlimit_nofile,omitempty"`
+	// Umask is the octal file mode creation mask, e.g. "077"; empty inherits execd's.
+	Umask string `json:"umask,omitempty"`
+	// Locale sets LC_ALL and LANG, e.g. "C.UTF-8"; empty inherits execd's.
+	Locale string `json:"locale,omitempty"`
+	// Timezone sets TZ, e.g. "UTC" or "Asia/Shanghai"; empty inherits execd's.
+	Timezone string `json:"timezone,omitempty"`
+	// Nice sets the command's CPU niceness (-20 to 19); 0 inherits execd's.
+	Nice int `json:"nice,omitempty" validate:"gte=-20,lte=19"`
+	// IOPriority sets the command's I/O class and level, e.g. "idle" or "best-effort:7".
+	IOPriority string `json:"io_priority,omitempty"`
+	// OutputSink uploads stdout/stderr to object storage instead of streaming them.
+	OutputSink *OutputSinkRequest `json:"output_sink,omitempty"`
+	// Wasm runs Command as a WebAssembly module path plus arguments under the WASI runtime.
+	Wasm bool `json:"wasm,omitempty"`
+	// OutputRateLimit paces stdout/stderr events to this many bytes per second; 0 is unlimited.
+	OutputRateLimit int `json:"output_rate_limit,omitempty" validate:"gte=0"`
+	// Artifacts returns a manifest of files the command produced under its working directory.
+	Artifacts *ArtifactsRequest `json:"artifacts,omitempty"`
+	// Classify categorizes how the command ended on its final event.
+	Classify *ExitClassifyRequest `json:"classify,omitempty"`
+	// CACerts are PEM certificates the command trusts on top of the system store.
+	CACerts string `json:"ca_certs,omitempty"`
+	// OutputEncoding is the encoding the command writes, e.g. "gbk"; output is converted to UTF-8.
+	OutputEncoding string `json:"output_encoding,omitempty"`
+	// OutputHighWatermark pauses the command while this many bytes of a stream are undelivered; 0 is unbounded.
+	OutputHighWatermark int `json:"output_high_watermark,omitempty" validate:"gte=0"`
+	// PostCommand runs after the command ends, even when it fails, times out or is interrupted.
+	PostCommand *PostCommandRequest `json:"post_command,omitempty"`
+	// OutputCoalesce batches many small stdout/stderr lines into fewer, larger events.
+	OutputCoalesce *OutputCoalesceRequest `json:"output_coalesce,omitempty"`
+	// OutputDigest hashes the command's output onto its final event, to tell whether it changed since an earlier run.
+	OutputDigest *OutputDigestRequest `json:"output_digest,omitempty"`
+	// CancelOnDisconnect stops the command when the client streaming its output goes away, instead of letting it finish.
+	CancelOnDisconnect bool `json:"cancel_on_disconnect,omitempty"`
+	// Summary adds output, exit and resource totals to the command's final event.
+	Summary bool `json:"summary,omitempty"`
+	// WorkdirDiff returns the files the command added, modified or deleted under its working directory.
+	WorkdirDiff *WorkdirDiffRequest `json:"workdir_diff,omitempty"`
+	// RedactSecrets masks the values of secret-looking variables of EXECD_ENVS, and ones vended by pre-exec hooks, in the output.
+	RedactSecrets bool `json:"redact_secrets,omitempty"`
+}
+
+// WorkdirDiffRequest selects the files compared before and after a command.
+type WorkdirDiffRequest struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	// MaxFiles caps the changed files listed; 0 means 1000.
+	MaxFiles int `json:"max_files,omitempty"`
+	// MaxBytes caps the contents and patches returned over all files; 0 means 1 MiB.
+	MaxBytes int `json:"max_bytes,omitempty"`
+}
+
+// OutputDigestRequest selects how a command's output is hashed.
+type OutputDigestRequest struct {
+	// Normalize ignores line endings, trailing whitespace and trailing blank lines.
+	Normalize bool `json:"normalize,omitempty"`
+	// Ignore lists regular expressions removed from each line before hashing.
+	Ignore []string `json:"ignore,omitempty"`
+	// Previous is the digest of an earlier run to compare against.
+	Previous string `json:"previous,omitempty"`
+}
+
+// OutputCoalesceRequest tunes how output lines are batched into events.
+type OutputCoalesceRequest struct {
+	// MaxBytes flushes a batch once it holds this many bytes; 0 means 64 KiB.
+	MaxBytes int `json:"max_bytes,omitempty" validate:"gte=0"`
+	// MinIntervalMs is the first flush delay, doubled while output keeps coming; 0 means 100.
+	MinIntervalMs int64 `json:"min_interval_ms,omitempty" validate:"gte=0"`
+	// MaxIntervalMs caps the flush delay; 0 means 1000.
+	MaxIntervalMs int64 `json:"max_interval_ms,omitempty" validate:"gte=0"`
+}
+
+// PostCommandRequest is a cleanup command run after the main command.
+type PostCommandRequest struct {
+	Command string `json:"command" validate:"required"`
+	// TimeoutMs bounds the post command on its own; 0 means 30 seconds.
+	TimeoutMs int64 `json:"timeout_ms,omitempty" validate:"gte=0"`
+}
+
+// ExitClassifyRequest maps exit outcomes to categories. Exit code 0 and the
+// Success codes are "success", matches of the retryable lists "retryable",
+// everything else "fatal".
+type ExitClassifyRequest struct {
+	Success   []int `json:"success,omitempty"`
+	Retryable []int `json:"retryable,omitempty"`
+	// RetryableSignals lists signals such as "SIGKILL" or "TERM" that killed the process.
+	RetryableSignals []string `json:"retryable_signals,omitempty"`
+	// RetryableStderr lists substrings searched for in the last 4 KiB of stderr.
+	RetryableStderr []string `json:"retryable_stderr,omitempty"`
+}
+
+// ArtifactsRequest selects the files listed in a command's artifacts manifest.
+type ArtifactsRequest struct {
+	// Dir is scanned instead of the working directory; it must be relative to it.
+	Dir      string   `json:"dir,omitempty"`
+	Include  []string `json:"include,omitempty"`
+	Exclude  []string `json:"exclude,omitempty"`
+	MaxFiles int      `json:"max_files,omitempty"`
+}
+
+// OutputSinkRequest points a command's output at an S3-compatible object URL prefix.
+type OutputSinkRequest struct {
+	URL     string            `json:"url" validate:"required,url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Tee keeps streaming stdout/stderr events in addition to uploading.
+	Tee bool `json:"tee,omitempty"`
 }
 
 func (r *RunCommandRequest) Validate() error {
+	if r.Stdin && !r.Background {
+		return errors.New("stdin is only supported for background commands")
+	}
+	if r.OutputSink != nil && r.Background {
+		return errors.New("output_sink is only supported for foreground commands")
+	}
+	if r.Artifacts != nil && r.Background {
+		return errors.New("artifacts are only supported for foreground commands")
+	}
+	if r.Classify != nil && r.Background {
+		return errors.New("classify is only supported for foreground commands")
+	}
+	if r.PostCommand != nil && r.Background {
+		return errors.New("post_command is only supported for foreground commands")
+	}
+	if r.CancelOnDisconnect && r.Background {
+		return errors.New("cancel_on_disconnect is only supported for foreground commands")
+	}
+	if r.Summary && r.Background {
+		return errors.New("summary is only supported for foreground commands")
+	}
+	if r.WorkdirDiff != nil && r.Background {
+		return errors.New("workdir_diff is only supported for foreground commands")
+	}
+	if r.RedactSecrets && r.Background {
+		return errors.New("redact_secrets is only supported for foreground commands")
+	}
+	if r.RedactSecrets && r.OutputHighWatermark > 0 {
+		return errors.New("redact_secrets cannot be combined with output_high_watermark")
+	}
+	if r.Lane != "" && r.Background {
+		return errors.New("lane is only supported for foreground commands")
+	}
+	if r.OutputRateLimit > 0 && r.Background {
+		return errors.New("output_rate_limit is only supported for foreground commands")
+	}
+	if r.OutputCoalesce != nil {
+		if r.Background {
+			return errors.New("output_coalesce is only supported for foreground commands")
+		}
+		if c := r.OutputCoalesce; c.MinIntervalMs > 0 && c.MaxIntervalMs > 0 && c.MaxIntervalMs < c.MinIntervalMs {
+			return errors.New("output_coalesce max_interval_ms must not be less than min_interval_ms")
+		}
+	}
+	if r.OutputDigest != nil {
+		if r.Background {
+			return errors.New("output_digest is only supported for foreground commands")
+		}
+		if _, err := runtime.CompileOutputDigestIgnore(r.OutputDigest.Ignore); err != nil {
+			return err
+		}
+	}
+	if r.Wasm && r.RLimitNofile > 0 {
+		return errors.New("rlimit_nofile is not supported for wasm modules")
+	}
+	if r.Wasm && r.Umask != "" {
+		return errors.New("umask is not supported for wasm modules")
+	}
+	if r.Wasm && (r.Locale != "" || r.Timezone != "") {
+		return errors.New("locale and timezone are not supported for wasm modules")
+	}
 	validate := validator.New()
 	return validate.Struct(r)
 }
@@ -59,26 +244,56 @@ func (r *RunCommandRequest) Validate() error {
 type ServerStreamEventType string
 
 const (
-	StreamEventTypeInit     ServerStreamEventType = "init"
-	StreamEventTypeStatus   ServerStreamEventType = "status"
-	StreamEventTypeError    ServerStreamEventType = "error"
-	StreamEventTypeStdout   ServerStreamEventType = "stdout"
-	StreamEventTypeStderr   ServerStreamEventType = "stderr"
-	StreamEventTypeResult   ServerStreamEventType = "result"
-	StreamEventTypeComplete ServerStreamEventType = "execution_complete"
-	StreamEventTypeCount    ServerStreamEventType = "execution_count"
-	StreamEventTypePing     ServerStreamEventType = "ping"
+	StreamEventTypeInit        ServerStreamEventType = "init"
+	StreamEventTypeStatus      ServerStreamEventType = "status"
+	StreamEventTypeError       ServerStreamEventType = "error"
+	StreamEventTypeStdout      ServerStreamEventType = "stdout"
+	StreamEventTypeStderr      ServerStreamEventType = "stderr"
+	StreamEventTypeResult      ServerStreamEventType = "result"
+	StreamEventTypeComplete    ServerStreamEventType = "execution_complete"
+	StreamEventTypeCount       ServerStreamEventType = "execution_count"
+	StreamEventTypePing        ServerStreamEventType = "ping"
+	StreamEventTypeDryRun      ServerStreamEventType = "dry_run"
+	StreamEventTypePostCommand ServerStreamEventType = "post_command"
+	StreamEventTypeValue       ServerStreamEventType = "value"
 )
 
 // ServerStreamEvent is emitted to clients over SSE.
 type ServerStreamEvent struct {
-	Type           ServerStreamEventType `json:"type,omitempty"`
-	Text           string                `json:"text,omitempty"`
-	ExecutionCount int                   `json:"execution_count,omitempty"`
-	ExecutionTime  int64                 `json:"execution_time,omitempty"`
-	Timestamp      int64                 `json:"timestamp,omitempty"`
-	Results        map[string]any        `json:"results,omitempty"`
-	Error          *execute.ErrorOutput  `json:"error,omitempty"`
+	Type ServerStreamEventType `json:"type,omitempty"`
+	// Session identifies the execution the event belongs to; it is set on
+	// every event from init on.
+	Session        string         `json:"session,omitempty"`
+	Text           string         `json:"text,omitempty"`
+	ExecutionCount int            `json:"execution_count,omitempty"`
+	ExecutionTime  int64          `json:"execution_time,omitempty"`
+	Timestamp      int64          `json:"timestamp,omitempty"`
+	Results        map[string]any `json:"results,omitempty"`
+	// Metadata is what Jupyter sent along with a cell's value, set on its
+	// value event.
+	Metadata  map[string]any            `json:"metadata,omitempty"`
+	Error     *execute.ErrorOutput      `json:"error,omitempty"`
+	Command   *runtime.ResolvedCommand  `json:"command,omitempty"`
+	Output    *runtime.OutputSinkResult `json:"output,omitempty"`
+	Artifacts *runtime.ArtifactManifest `json:"artifacts,omitempty"`
+	// ExitCategory is the requested classification of how a command ended,
+	// set on its execution_complete or error event.
+	ExitCategory runtime.ExitCategory `json:"exit_category,omitempty"`
+	// PostCommand is the result of the request's post command, set on its
+	// post_command event.
+	PostCommand *runtime.PostCommandResult `json:"post_command,omitempty"`
+	// OutputDigest is the hash of the output the request asked for, set on
+	// its execution_complete or error event.
+	OutputDigest *runtime.OutputDigestResult `json:"output_digest,omitempty"`
+	// Summary holds the output, exit and resource totals the request asked
+	// for, set on its execution_complete or error event.
+	Summary *runtime.ExecutionSummary `json:"summary,omitempty"`
+	// WorkdirDiff lists the files the command changed under its working
+	// directory, set on its execution_complete or error event.
+	WorkdirDiff *runtime.WorkdirChanges `json:"workdir_diff,omitempty"`
+	// Warnings lists the non-fatal conditions the execution met, such as
+	// dropped output, set on its execution_complete or error event.
+	Warnings []runtime.ExecutionWarning `json:"warnings,omitempty"`
 }
 
 // ToJSON serializes the event for streaming.
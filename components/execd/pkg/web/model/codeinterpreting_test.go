@@ -43,6 +43,122 @@ func TestRunCommandRequestValidate(t *testing.T) {
 	if err := req.Validate(); err == nil {
 		t.Fatalf("expected validation error when command is empty")
 	}
+
+	req = RunCommandRequest{Command: "ls", OutputSink: &OutputSinkRequest{URL: "not a url"}}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for an invalid output sink url")
+	}
+	req.OutputSink.URL = "https://bucket.example.com/runs/1"
+	req.Background = true
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for an output sink on a background command")
+	}
+
+	req = RunCommandRequest{Command: "app.wasm", Wasm: true, RLimitNofile: 64}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for rlimit_nofile on a wasm module")
+	}
+	req = RunCommandRequest{Command: "app.wasm", Wasm: true, Umask: "077"}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for umask on a wasm module")
+	}
+	req = RunCommandRequest{Command: "app.wasm", Wasm: true, Timezone: "UTC"}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for timezone on a wasm module")
+	}
+
+	req = RunCommandRequest{Command: "yes", OutputRateLimit: -1}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for a negative output rate limit")
+	}
+	req = RunCommandRequest{Command: "yes", OutputRateLimit: 1024, Background: true}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for an output rate limit on a background command")
+	}
+	req = RunCommandRequest{Command: "make", Artifacts: &ArtifactsRequest{Include: []string{"*.bin"}}, Background: true}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for artifacts on a background command")
+	}
+	req = RunCommandRequest{Command: "make", Classify: &ExitClassifyRequest{Retryable: []int{2}}, Background: true}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for classify on a background command")
+	}
+	req = RunCommandRequest{Command: "make", PostCommand: &PostCommandRequest{Command: "make clean"}, Background: true}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for a post command on a background command")
+	}
+	req = RunCommandRequest{Command: "make", PostCommand: &PostCommandRequest{TimeoutMs: 1000}}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for a post command without a command")
+	}
+	req = RunCommandRequest{Command: "make", PostCommand: &PostCommandRequest{Command: "make clean", TimeoutMs: -1}}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for a negative post command timeout")
+	}
+	req = RunCommandRequest{Command: "make", Lane: "build", Background: true}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for a lane on a background command")
+	}
+	req = RunCommandRequest{Command: "yes", OutputCoalesce: &OutputCoalesceRequest{}}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("expected output coalescing with defaults to be valid, got %v", err)
+	}
+	req = RunCommandRequest{Command: "yes", OutputCoalesce: &OutputCoalesceRequest{}, Background: true}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for output coalescing on a background command")
+	}
+	req = RunCommandRequest{Command: "yes", OutputCoalesce: &OutputCoalesceRequest{MinIntervalMs: 500, MaxIntervalMs: 100}}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for a max interval below the min interval")
+	}
+	req = RunCommandRequest{Command: "yes", OutputCoalesce: &OutputCoalesceRequest{MaxBytes: -1}}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for negative coalescing max bytes")
+	}
+	req = RunCommandRequest{Command: "uptime", OutputDigest: &OutputDigestRequest{Normalize: true, Ignore: []string{`\d+ users?`}}}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("expected output digest to be valid, got %v", err)
+	}
+	req = RunCommandRequest{Command: "uptime", OutputDigest: &OutputDigestRequest{}, Background: true}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for output digest on a background command")
+	}
+	req = RunCommandRequest{Command: "uptime", OutputDigest: &OutputDigestRequest{Ignore: []string{"("}}}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for an invalid ignore pattern")
+	}
+	req = RunCommandRequest{Command: "sleep 60", CancelOnDisconnect: true, Background: true}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for cancel_on_disconnect on a background command")
+	}
+	req = RunCommandRequest{Command: "ls", Summary: true, Background: true}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for summary on a background command")
+	}
+	req = RunCommandRequest{Command: "make", WorkdirDiff: &WorkdirDiffRequest{Exclude: []string{".git"}}, Background: true}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for workdir_diff on a background command")
+	}
+	req = RunCommandRequest{Command: "env", RedactSecrets: true, Background: true}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for redact_secrets on a background command")
+	}
+	req = RunCommandRequest{Command: "env", RedactSecrets: true, OutputHighWatermark: 4096}
+	if err := req.Validate(); err == nil {
+		t.Fatalf("expected validation error for redact_secrets with output_high_watermark")
+	}
+}
+
+func TestStopCommandsRequestValidate(t *testing.T) {
+	if err := (&StopCommandsRequest{}).Validate(); err == nil {
+		t.Fatalf("expected validation error without sessions or a group")
+	}
+	if err := (&StopCommandsRequest{GroupID: "team-a", GracePeriodMs: -1}).Validate(); err == nil {
+		t.Fatalf("expected validation error for a negative grace period")
+	}
+	if err := (&StopCommandsRequest{Sessions: []string{"cmd-1"}}).Validate(); err != nil {
+		t.Fatalf("expected sessions alone to be valid, got %v", err)
+	}
 }
 
 func TestServerStreamEventToJSON(t *testing.T) {
@@ -14,15 +14,91 @@
 
 package model
 
-import "time"
+import (
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/alibaba/opensandbox/execd/pkg/runtime"
+)
+
+// CommandStdinResponse reports how much of a stdin write reached the process.
+type CommandStdinResponse struct {
+	Written int `json:"written"`
+}
 
 // CommandStatusResponse represents command status for REST APIs.
 type CommandStatusResponse struct {
 	ID         string     `json:"id"`
 	Content    string     `json:"content,omitempty"`
+	State      string     `json:"state,omitempty"`
 	Running    bool       `json:"running"`
 	ExitCode   *int       `json:"exit_code,omitempty"`
 	Error      string     `json:"error,omitempty"`
 	StartedAt  time.Time  `json:"started_at,omitempty"`
 	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	OutputTail string     `json:"output_tail,omitempty"`
+	// Warnings are the non-fatal conditions of a background command, set once it exits.
+	Warnings []runtime.ExecutionWarning `json:"warnings,omitempty"`
+}
+
+// StopCommandsRequest stops several commands at once: the listed sessions,
+// the running commands of a group, or both.
+type StopCommandsRequest struct {
+	Sessions []string `json:"sessions,omitempty"`
+	GroupID  string   `json:"group_id,omitempty"`
+	// GracePeriodMs is how long the commands get to exit after SIGTERM
+	// before SIGKILL, shared by all of them; 0 uses the server's default.
+	GracePeriodMs int64 `json:"grace_period_ms,omitempty" validate:"gte=0"`
+}
+
+func (r *StopCommandsRequest) Validate() error {
+	if len(r.Sessions) == 0 && r.GroupID == "" {
+		return errors.New("sessions or group_id is required")
+	}
+	return validator.New().Struct(r)
+}
+
+// StopCommandsResponse reports what stopping did to each command.
+type StopCommandsResponse struct {
+	Outcomes []runtime.StopOutcome `json:"outcomes"`
+}
+
+// Capability reports whether a command option works on this execd.
+type Capability struct {
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// CapabilitiesResponse lists the command options whose support depends on
+// the platform, privileges and configuration, keyed by their request field.
+// Daemon tracking has no field; it applies to every command when available.
+type CapabilitiesResponse struct {
+	OS         string                `json:"os"`
+	Arch       string                `json:"arch"`
+	Privileged bool                  `json:"privileged"`
+	Features   map[string]Capability `json:"features"`
+}
+
+// NewCapabilitiesResponse maps runtime capabilities to the REST shape.
+func NewCapabilitiesResponse(caps runtime.Capabilities) CapabilitiesResponse {
+	feature := func(c runtime.Capability) Capability {
+		return Capability{Available: c.Available, Reason: c.Reason}
+	}
+	return CapabilitiesResponse{
+		OS:         caps.OS,
+		Arch:       caps.Arch,
+		Privileged: caps.Privileged,
+		Features: map[string]Capability{
+			"stdin":           feature(caps.Stdin),
+			"wasm":            feature(caps.Wasm),
+			"read_only_fs":    feature(caps.ReadOnlyFS),
+			"scratch_mb":      feature(caps.Scratch),
+			"rlimit_nofile":   feature(caps.RLimitNofile),
+			"nice":            feature(caps.Priority),
+			"io_priority":     feature(caps.Priority),
+			"daemon_tracking": feature(caps.ProcessTree),
+		},
+	}
 }
@@ -26,6 +26,12 @@ const (
 	ErrorCodeFileNotFound        ErrorCode = "FILE_NOT_FOUND"
 	ErrorCodeUnknown             ErrorCode = "UNKNOWN"
 	ErrorCodeContextNotFound     ErrorCode = "CONTEXT_NOT_FOUND"
+	ErrorCodeKernelLimitReached  ErrorCode = "KERNEL_LIMIT_REACHED"
+	ErrorCodeSessionInitFailed   ErrorCode = "SESSION_INIT_FAILED"
+	ErrorCodeStdinUnavailable    ErrorCode = "STDIN_UNAVAILABLE"
+	ErrorCodeStdinClosed         ErrorCode = "STDIN_CLOSED"
+	ErrorCodeStdinBusy           ErrorCode = "STDIN_BUSY"
+	ErrorCodeDuplicateExecution  ErrorCode = "DUPLICATE_EXECUTION"
 )
 
 type ErrorResponse struct {
@@ -32,6 +32,7 @@ func NewRouter(accessToken string) *gin.Engine {
 	r.Use(logMiddleware(), accessTokenMiddleware(accessToken), ProxyMiddleware())
 
 	r.GET("/ping", controller.PingHandler)
+	r.GET("/capabilities", withCode(func(c *controller.CodeInterpretingController) { c.GetCapabilities() }))
 
 	files := r.Group("/files")
 	{
@@ -66,8 +67,11 @@ func NewRouter(accessToken string) *gin.Engine {
 	{
 		command.POST("", withCode(func(c *controller.CodeInterpretingController) { c.RunCommand() }))
 		command.DELETE("", withCode(func(c *controller.CodeInterpretingController) { c.InterruptCommand() }))
+		command.POST("/stop", withCode(func(c *controller.CodeInterpretingController) { c.StopCommands() }))
 		command.GET("/status/:id", withCode(func(c *controller.CodeInterpretingController) { c.GetCommandStatus() }))
 		command.GET("/:id/logs", withCode(func(c *controller.CodeInterpretingController) { c.GetBackgroundCommandOutput() }))
+		command.POST("/:id/stdin", withCode(func(c *controller.CodeInterpretingController) { c.WriteCommandStdin() }))
+		command.DELETE("/:id/stdin", withCode(func(c *controller.CodeInterpretingController) { c.CloseCommandStdin() }))
 	}
 
 	metric := r.Group("/metrics")
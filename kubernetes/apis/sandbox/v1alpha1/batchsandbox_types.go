@@ -18,6 +18,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // BatchSandboxSpec defines the desired state of BatchSandbox.
@@ -44,6 +45,46 @@ type BatchSandboxSpec struct {
 	// +optional
 	// +kubebuilder:validation:Optional
 	ShardPatches []runtime.RawExtension `json:"shardPatches,omitempty"`
+	// VolumeClaimTemplates are claims every shard gets its own copy of, like a StatefulSet's.
+	// Shard i's claim from template "data" is named "data-<batchsandbox>-i" and replaces the
+	// Template volume called "data". Containers mount it through their own volumeMounts, and a
+	// template that no container or init container of Template mounts is rejected.
+	// Claims are created on scale-up and kept when the shard's Pod goes away. Not supported with PoolRef.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	// +optional
+	// +kubebuilder:validation:Optional
+	VolumeClaimTemplates []corev1.PersistentVolumeClaim `json:"volumeClaimTemplates,omitempty"`
+	// VolumeClaimRetentionPolicy decides what happens to the shard claims when the BatchSandbox is deleted.
+	// - Delete: the claims are owned by the BatchSandbox and garbage-collected with it.
+	// - Retain: the claims are left behind, and a BatchSandbox recreated with the same name reuses them.
+	// +optional
+	// +kubebuilder:default=Delete
+	// +kubebuilder:validation:Enum=Delete;Retain
+	// +kubebuilder:validation:Optional
+	VolumeClaimRetentionPolicy VolumeClaimRetentionPolicy `json:"volumeClaimRetentionPolicy,omitempty"`
+	// DisruptionBudget makes the controller maintain a PodDisruptionBudget over the shard Pods,
+	// so voluntary disruptions such as node drains evict only a bounded number of shards at a time.
+	// Not supported with PoolRef or an IndexedJob task.
+	// +optional
+	// +kubebuilder:validation:Optional
+	DisruptionBudget *DisruptionBudgetSpec `json:"disruptionBudget,omitempty"`
+	// QuotaPolicy decides how shard Pods are created when the namespace's ResourceQuotas
+	// cannot admit all of them yet.
+	// - Backfill: the shards that fit are created in index order, the rest as quota frees up.
+	// - AllOrNothing: no shard is created until all missing ones fit.
+	// The QuotaAvailable condition reports the shortage. Not used with PoolRef.
+	// +optional
+	// +kubebuilder:default=Backfill
+	// +kubebuilder:validation:Enum=Backfill;AllOrNothing
+	// +kubebuilder:validation:Optional
+	QuotaPolicy QuotaPolicy `json:"quotaPolicy,omitempty"`
+	// ImagePrePull pulls the shard images onto every node the shards can run on before
+	// the first shard Pod is created, so the shards start together once placed instead of
+	// each waiting for its own pull. Not supported with PoolRef or an IndexedJob task.
+	// +optional
+	// +kubebuilder:validation:Optional
+	ImagePrePull *ImagePrePullSpec `json:"imagePrePull,omitempty"`
 	// ExpireTime - Absolute time when the batch-sandbox is deleted.
 	// If a time in the past is provided, the batch-sandbox will be deleted immediately.
 	// +optional
@@ -57,12 +98,95 @@ type BatchSandboxSpec struct {
 	// +kubebuilder:validation:Schemaless
 	// +kubebuilder:validation:Optional
 	TaskTemplate *TaskTemplateSpec `json:"taskTemplate,omitempty"`
+	// TaskUpdateStrategy decides how running shards pick up an edited TaskTemplate.
+	// Shards assigned after the edit always get the new task. Not supported with an IndexedJob task.
+	// +optional
+	// +kubebuilder:validation:Optional
+	TaskUpdateStrategy *TaskUpdateStrategy `json:"taskUpdateStrategy,omitempty"`
+	// ShardIndexBase is the number of the first shard in task names, SHARD_INDEX and the
+	// GoTemplate .Index: 1 numbers the tasks of a BatchSandbox "name" name-1 to name-<replicas>.
+	// Entries of ShardTaskPatches, ShardTaskValues and TaskMatrix combinations are still
+	// addressed from 0, as are shard Pods. Not supported with an IndexedJob task.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=1
+	// +kubebuilder:validation:Optional
+	ShardIndexBase int32 `json:"shardIndexBase,omitempty"`
 	// ShardTaskPatches indicates patching to the TaskTemplate for individual Task.
 	// +kubebuilder:pruning:PreserveUnknownFields
 	// +kubebuilder:validation:Schemaless
 	// +optional
 	// +kubebuilder:validation:Optional
 	ShardTaskPatches []runtime.RawExtension `json:"shardTaskPatches,omitempty"`
+	// ShardTaskPatchPolicy decides what happens when a ShardTaskPatch leaves a shard's task
+	// without a command, e.g. by setting process or process.command to null.
+	// - Fail: the task template is rejected with an error naming the shard.
+	// - UseTemplateCommand: the shard runs the TaskTemplate's command, or its whole process
+	//   when the patch removed the process.
+	// +optional
+	// +kubebuilder:default=Fail
+	// +kubebuilder:validation:Enum=Fail;UseTemplateCommand
+	// +kubebuilder:validation:Optional
+	ShardTaskPatchPolicy ShardTaskPatchPolicy `json:"shardTaskPatchPolicy,omitempty"`
+	// TaskTemplateEngine selects how placeholders in TaskTemplate are rendered for each shard.
+	// - None: string fields are used verbatim.
+	// - GoTemplate: after ShardTaskPatches are applied, every string field is rendered as a Go
+	//   template with .Index, .Replicas, .Name, .Namespace and .Values, e.g. "--shard={{.Index}}".
+	// +optional
+	// +kubebuilder:validation:Enum=None;GoTemplate
+	// +kubebuilder:validation:Optional
+	TaskTemplateEngine TaskTemplateEngine `json:"taskTemplateEngine,omitempty"`
+	// TaskTemplateValues are exposed to every shard's template as .Values.
+	// +optional
+	// +kubebuilder:validation:Optional
+	TaskTemplateValues map[string]string `json:"taskTemplateValues,omitempty"`
+	// ShardTaskValues overlay TaskTemplateValues for individual shards; entry i applies to shard i.
+	// +optional
+	// +kubebuilder:validation:Optional
+	ShardTaskValues []map[string]string `json:"shardTaskValues,omitempty"`
+	// TaskMatrix expands a parameter sweep into shards: one shard per combination of the
+	// dimensions' values, so the product of their lengths replaces Replicas. Shard i gets its
+	// combination as .Values for the GoTemplate engine and as MATRIX_<NAME> env vars, overlaid
+	// on TaskTemplateValues and overlaid in turn by ShardTaskValues. Combinations are numbered
+	// like nested loops over the dimensions in order, the last one varying fastest.
+	// +optional
+	// +kubebuilder:validation:Optional
+	TaskMatrix []TaskMatrixDimension `json:"taskMatrix,omitempty"`
+	// TaskParameters reads per-shard parameters from a CSV or JSON file, one row per shard, so
+	// Replicas is set to the number of rows. Shard i gets row i as env vars and args, and as
+	// .Values for the GoTemplate engine, overlaid on TaskMatrix values and overlaid in turn by
+	// ShardTaskValues. It cannot be combined with TaskMatrix.
+	// +optional
+	// +kubebuilder:validation:Optional
+	TaskParameters *TaskParameterSource `json:"taskParameters,omitempty"`
+	// TaskCanary schedules shard 0 on its own first. The remaining shards are scheduled only
+	// after it succeeds, or, when the task has a StartupProbe, as soon as it is ready; if it
+	// fails or times out, the whole batch is aborted.
+	// +optional
+	// +kubebuilder:validation:Optional
+	TaskCanary *TaskCanarySpec `json:"taskCanary,omitempty"`
+	// TaskCompletionPolicy decides when the batch's tasks are complete and whether they succeeded.
+	// Once the outcome is decided, shards that are still pending or running are stopped.
+	// Without it, every task simply runs to its own end.
+	// +optional
+	// +kubebuilder:validation:Optional
+	TaskCompletionPolicy *TaskCompletionPolicySpec `json:"taskCompletionPolicy,omitempty"`
+	// TaskOutput selects how the generated task specs are run.
+	// - Task: each shard's task is handed to the task executor of a Pod created from Template.
+	// - IndexedJob: the controller creates one Indexed Job named after the BatchSandbox, with a
+	//   completion per replica. Every shard must run the same process apart from SHARD_INDEX,
+	//   which is read from the Job's completion index.
+	// +optional
+	// +kubebuilder:default=Task
+	// +kubebuilder:validation:Enum=Task;IndexedJob
+	// +kubebuilder:validation:Optional
+	TaskOutput TaskOutput `json:"taskOutput,omitempty"`
+	// Suspend stops tasks from being assigned to Pods while true. Tasks already assigned keep
+	// running; the rest stay pending until Suspend is set back to false. Pods are still created,
+	// so a resumed batch starts without waiting for them.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Suspend bool `json:"suspend,omitempty"`
 	// TaskResourcePolicyWhenCompleted specifies how resources should be handled once a task reaches a completed state (SUCCEEDED or FAILED).
 	// - Retain: Keep the resources until the BatchSandbox is deleted.
 	// - Release: Free the resources immediately when the task completes.
@@ -70,8 +194,272 @@ type BatchSandboxSpec struct {
 	// +kubebuilder:default=Retain
 	// +kubebuilder:validation:Optional
 	TaskResourcePolicyWhenCompleted *TaskResourcePolicy `json:"taskResourcePolicyWhenCompleted,omitempty"`
+	// TaskFailureLogs keeps the tail of a failed task's output for post-mortem. It is fetched
+	// from the task executor before the task is released, and stored in a ConfigMap owned by
+	// the BatchSandbox, named in the shard's status.
+	// +optional
+	// +kubebuilder:validation:Optional
+	TaskFailureLogs *TaskFailureLogsSpec `json:"taskFailureLogs,omitempty"`
+}
+
+// TaskFailureLogsSpec bounds the logs kept for a failed task.
+type TaskFailureLogsSpec struct {
+	// TailLines is the number of last lines kept of stdout and of stderr. Defaults to 100.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10000
+	TailLines int32 `json:"tailLines,omitempty"`
+	// LimitBytes caps what is kept of each stream, whole lines only. Defaults to 16384.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=262144
+	LimitBytes int32 `json:"limitBytes,omitempty"`
+}
+
+type TaskOutput string
+
+const (
+	TaskOutputTask       TaskOutput = "Task"
+	TaskOutputIndexedJob TaskOutput = "IndexedJob"
+)
+
+type ShardTaskPatchPolicy string
+
+const (
+	ShardTaskPatchPolicyFail               ShardTaskPatchPolicy = "Fail"
+	ShardTaskPatchPolicyUseTemplateCommand ShardTaskPatchPolicy = "UseTemplateCommand"
+)
+
+type TaskTemplateEngine string
+
+const (
+	TaskTemplateEngineNone       TaskTemplateEngine = "None"
+	TaskTemplateEngineGoTemplate TaskTemplateEngine = "GoTemplate"
+)
+
+// TaskCanarySpec configures the canary shard of a BatchSandbox.
+type TaskCanarySpec struct {
+	// TimeoutSeconds bounds how long the canary may take to succeed, counted from
+	// when it is assigned to a Pod. 0 means no timeout.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	TimeoutSeconds int64 `json:"timeoutSeconds,omitempty"`
+}
+
+type TaskUpdateStrategyType string
+
+const (
+	TaskUpdateStrategyOnDelete      TaskUpdateStrategyType = "OnDelete"
+	TaskUpdateStrategyRollingUpdate TaskUpdateStrategyType = "RollingUpdate"
+)
+
+// TaskUpdateStrategy configures how running shards are replaced when the TaskTemplate changes.
+type TaskUpdateStrategy struct {
+	// Type of the update.
+	// - OnDelete: running shards keep their task; a shard only gets the new one once it is
+	//   assigned again, e.g. after its Pod was drained or recreated.
+	// - RollingUpdate: the controller stops the outdated tasks a few at a time and submits
+	//   the new task to the same Pods, bounded by RollingUpdate.MaxUnavailable.
+	// +optional
+	// +kubebuilder:default=OnDelete
+	// +kubebuilder:validation:Enum=OnDelete;RollingUpdate
+	Type TaskUpdateStrategyType `json:"type,omitempty"`
+	// RollingUpdate bounds a RollingUpdate.
+	// +optional
+	RollingUpdate *RollingUpdateTaskStrategy `json:"rollingUpdate,omitempty"`
+}
+
+// RollingUpdateTaskStrategy bounds how many shards a RollingUpdate replaces at a time.
+type RollingUpdateTaskStrategy struct {
+	// MaxUnavailable is the number, or percentage of Replicas rounded down, of assigned shards
+	// that may be unavailable during the update: being replaced, or not ready yet. Defaults
+	// to 1; a percentage that rounds down to 0 still allows 1.
+	// +optional
+	// +kubebuilder:validation:XIntOrString
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// DisruptionBudgetSpec is the budget of the PodDisruptionBudget generated for a BatchSandbox.
+// Exactly one of MinAvailable and MaxUnavailable is set.
+type DisruptionBudgetSpec struct {
+	// MinAvailable is the number, or percentage of Replicas, of shard Pods that must stay
+	// available during an eviction.
+	// +optional
+	// +kubebuilder:validation:XIntOrString
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+	// MaxUnavailable is the number, or percentage of Replicas, of shard Pods that may be
+	// unavailable after an eviction.
+	// +optional
+	// +kubebuilder:validation:XIntOrString
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// ImagePrePullSpec configures the DaemonSet that pulls the shard images ahead of the shards.
+type ImagePrePullSpec struct {
+	// TimeoutSeconds bounds how long shard creation waits for the pulls; once it passes the
+	// shards are created anyway. Defaults to 600.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+	// Command is run in each image once it is pulled and must exit 0. Defaults to
+	// ["sh", "-c", "true"]; images without a shell need one of their own.
+	// +optional
+	Command []string `json:"command,omitempty"`
+	// PauseImage keeps the pull Pods running while the controller waits for every node.
+	// Defaults to registry.k8s.io/pause:3.10.
+	// +optional
+	PauseImage string `json:"pauseImage,omitempty"`
+}
+
+// TaskMatrixDimension is one parameter of a TaskMatrix.
+type TaskMatrixDimension struct {
+	// Name is the key of the value in .Values; upper-cased, it names the MATRIX_ env var.
+	// It starts with a letter or underscore, followed by letters, digits or underscores.
+	// +kubebuilder:validation:Pattern=`^[A-Za-z_][A-Za-z0-9_]*$`
+	Name string `json:"name"`
+	// Values are the values the parameter takes, in order; they must be distinct.
+	// +kubebuilder:validation:MinItems=1
+	Values []string `json:"values"`
+}
+
+type TaskParameterFormat string
+
+const (
+	TaskParameterFormatCSV  TaskParameterFormat = "CSV"
+	TaskParameterFormatJSON TaskParameterFormat = "JSON"
+)
+
+// TaskParameterSource is a parameter file with one row per shard.
+type TaskParameterSource struct {
+	// ConfigMapRef names the ConfigMap key, in the BatchSandbox's namespace, holding the file.
+	ConfigMapRef TaskParameterConfigMapRef `json:"configMapRef"`
+	// Format of the file.
+	// - CSV: a header row naming the columns, then one record per shard.
+	// - JSON: an array with one object per shard, whose values are strings, numbers or booleans.
+	// Defaults to JSON for keys ending in ".json" and to CSV otherwise.
+	// +optional
+	// +kubebuilder:validation:Enum=CSV;JSON
+	Format TaskParameterFormat `json:"format,omitempty"`
+	// Env sets env vars from the fields of a shard's row. Without it, every field is set as
+	// PARAM_<FIELD>, upper-cased, which requires field names to be valid env var names.
+	// +optional
+	Env []TaskParameterEnv `json:"env,omitempty"`
+	// Args are the fields whose values are appended, in order, to the args of a shard's process.
+	// +optional
+	Args []string `json:"args,omitempty"`
+}
+
+// TaskParameterConfigMapRef selects a key of a ConfigMap.
+type TaskParameterConfigMapRef struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+// TaskParameterEnv maps a field of the parameter file to an env var.
+type TaskParameterEnv struct {
+	// Name of the env var.
+	Name string `json:"name"`
+	// Field is the CSV column or JSON field whose value is set.
+	Field string `json:"field"`
+}
+
+type TaskCompletionPolicyType string
+
+const (
+	TaskCompletionPolicyAll      TaskCompletionPolicyType = "All"
+	TaskCompletionPolicyAny      TaskCompletionPolicyType = "Any"
+	TaskCompletionPolicyAtLeastN TaskCompletionPolicyType = "AtLeastN"
+)
+
+// TaskCompletionPolicySpec configures when a BatchSandbox's tasks are complete.
+type TaskCompletionPolicySpec struct {
+	// Type is how many shards have to succeed.
+	// - All: every shard; the batch fails as soon as one shard fails.
+	// - Any: one shard.
+	// - AtLeastN: MinSucceeded shards; the batch fails once too many have failed to get there.
+	// +optional
+	// +kubebuilder:default=All
+	// +kubebuilder:validation:Enum=All;Any;AtLeastN
+	Type TaskCompletionPolicyType `json:"type,omitempty"`
+	// MinSucceeded is the number of shards that have to succeed with AtLeastN,
+	// between 1 and Replicas.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MinSucceeded int32 `json:"minSucceeded,omitempty"`
+}
+
+type TaskCompletionPhase string
+
+const (
+	TaskCompletionRunning   TaskCompletionPhase = "Running"
+	TaskCompletionSucceeded TaskCompletionPhase = "Succeeded"
+	TaskCompletionFailed    TaskCompletionPhase = "Failed"
+)
+
+// TaskCompletionStatus is the outcome of the task completion policy.
+type TaskCompletionStatus struct {
+	// Phase is Running until the policy is met, Succeeded, or can no longer be met,
+	// Failed. Succeeded and Failed are final.
+	Phase TaskCompletionPhase `json:"phase"`
+	// CompletionTime is when the phase became final.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Message explains the outcome.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+type TaskCanaryPhase string
+
+const (
+	TaskCanaryPending   TaskCanaryPhase = "Pending"
+	TaskCanaryRunning   TaskCanaryPhase = "Running"
+	TaskCanarySucceeded TaskCanaryPhase = "Succeeded"
+	TaskCanaryFailed    TaskCanaryPhase = "Failed"
+)
+
+// TaskCanaryStatus is the observed state of the canary shard.
+type TaskCanaryStatus struct {
+	// Phase is Pending until the canary is assigned to a Pod, then Running until
+	// it reaches Succeeded or Failed. Succeeded and Failed are final.
+	Phase TaskCanaryPhase `json:"phase"`
+	// StartTime is when the canary was first seen assigned to a Pod.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// Message explains why the canary failed.
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
+type VolumeClaimRetentionPolicy string
+
+const (
+	VolumeClaimRetentionPolicyDelete VolumeClaimRetentionPolicy = "Delete"
+	VolumeClaimRetentionPolicyRetain VolumeClaimRetentionPolicy = "Retain"
+)
+
+type QuotaPolicy string
+
+const (
+	QuotaPolicyBackfill     QuotaPolicy = "Backfill"
+	QuotaPolicyAllOrNothing QuotaPolicy = "AllOrNothing"
+)
+
+// BatchSandboxConditionQuotaAvailable is false while shard Pods wait for namespace quota.
+const BatchSandboxConditionQuotaAvailable = "QuotaAvailable"
+
+// BatchSandboxConditionImagesPrePulled is false while the first shard Pods wait for
+// ImagePrePull, and true once the images were pulled on every candidate node.
+const BatchSandboxConditionImagesPrePulled = "ImagesPrePulled"
+
+// BatchSandboxConditionTaskParametersLoaded is false while the TaskParameters file is missing
+// or cannot be parsed, and true once its rows were loaded.
+const BatchSandboxConditionTaskParametersLoaded = "TaskParametersLoaded"
+
+// BatchSandboxConditionSpecValid is false while the spec cannot be acted on, with the
+// reason of the warning event raised for it, and true once it was fixed.
+const BatchSandboxConditionSpecValid = "SpecValid"
+
 type TaskResourcePolicy string
 
 const (
@@ -100,6 +488,79 @@ type BatchSandboxStatus struct {
 	TaskPending int32 `json:"taskPending"`
 	// TaskUnknown is the number of Unknown task
 	TaskUnknown int32 `json:"taskUnknown"`
+	// TaskReady is the number of running tasks that passed their startup probe,
+	// or of running tasks when no probe is configured.
+	TaskReady int32 `json:"taskReady"`
+	// TaskCanary is the state of the canary shard, set only when Spec.TaskCanary is.
+	// +optional
+	TaskCanary *TaskCanaryStatus `json:"taskCanary,omitempty"`
+	// TaskCompletion is the outcome of Spec.TaskCompletionPolicy, set only when it is.
+	// +optional
+	TaskCompletion *TaskCompletionStatus `json:"taskCompletion,omitempty"`
+	// TaskSuspended is true while Spec.Suspend holds back unassigned tasks.
+	// +optional
+	TaskSuspended bool `json:"taskSuspended,omitempty"`
+	// TaskUpdated is the number of shards running, or about to get, the current TaskTemplate.
+	// It falls short of the shard count while running shards still have an edited template's
+	// previous task.
+	// +optional
+	TaskUpdated int32 `json:"taskUpdated,omitempty"`
+	// TaskShards is the observed state of each shard's task, ordered by shard index.
+	// +optional
+	TaskShards []TaskShardStatus `json:"taskShards,omitempty"`
+	// TaskStartTime is when the first shard's task was seen assigned to a Pod.
+	// +optional
+	TaskStartTime *metav1.Time `json:"taskStartTime,omitempty"`
+	// TaskFinishTime is when every shard's task was first seen succeeded or failed.
+	// +optional
+	TaskFinishTime *metav1.Time `json:"taskFinishTime,omitempty"`
+	// Conditions are the latest observations of the BatchSandbox's state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// MaxTaskShardTimestamps is the number of leading shards whose TaskShardStatus
+// carries start and finish times, keeping the status of large batches small.
+const MaxTaskShardTimestamps = 1000
+
+// TaskShardStatus is the observed state of one shard's task.
+type TaskShardStatus struct {
+	// Index is the shard index.
+	Index int32 `json:"index"`
+	// PodName is the Pod the task is assigned to; empty while the shard is pending.
+	// +optional
+	PodName string `json:"podName,omitempty"`
+	// State is the task state reported by the task executor: RUNNING, SUCCEED, FAILED
+	// or UNKNOWN; empty while the shard is pending.
+	// +optional
+	State string `json:"state,omitempty"`
+	// Ready is true while the task runs and its startup probe has passed, or, without a
+	// probe, while it runs.
+	Ready bool `json:"ready"`
+	// Draining is true while the task is being stopped because its Pod was marked
+	// for draining; the shard then goes back to pending and is assigned again.
+	// +optional
+	Draining bool `json:"draining,omitempty"`
+	// Outdated is true while the task runs a TaskTemplate that has since been edited.
+	// +optional
+	Outdated bool `json:"outdated,omitempty"`
+	// Updating is true while an outdated task is being stopped to be replaced by the
+	// current one on the same Pod.
+	// +optional
+	Updating bool `json:"updating,omitempty"`
+	// StartTime is when the task was first seen assigned to PodName. Only the first
+	// MaxTaskShardTimestamps shards record it.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// FinishTime is when the task was first seen succeeded or failed. Only the first
+	// MaxTaskShardTimestamps shards record it.
+	// +optional
+	FinishTime *metav1.Time `json:"finishTime,omitempty"`
+	// FailureLogs is the ConfigMap holding the logs captured when the task failed.
+	// +optional
+	FailureLogs string `json:"failureLogs,omitempty"`
 }
 
 // +genclient
@@ -114,6 +575,7 @@ type BatchSandboxStatus struct {
 // +kubebuilder:printcolumn:name="TASK_RUNNING",type="integer",priority=1,JSONPath=".status.taskRunning",description="The number of currently all running tasks."
 // +kubebuilder:printcolumn:name="TASK_SUCCEED",type="integer",priority=1,JSONPath=".status.taskSucceed",description="The number of currently all succeed tasks."
 // +kubebuilder:printcolumn:name="TASK_FAILED",type="integer",priority=1,JSONPath=".status.taskFailed",description="The number of currently all failed tasks."
+// +kubebuilder:printcolumn:name="TASK_READY",type="integer",priority=1,JSONPath=".status.taskReady",description="The number of currently all ready tasks."
 // +kubebuilder:printcolumn:name="TASK_UNKNOWN",type="integer",priority=1,JSONPath=".status.taskUnknown",description="The number of currently all unknown tasks."
 // +kubebuilder:printcolumn:name="EXPIRE",type="string",JSONPath=".spec.expireTime",description="sandbox expire time"
 // +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp",description="CreationTimestamp is a timestamp representing the server time when this object was created. It is not guaranteed to be set in happens-before order across separate operations. Clients may not set this value. It is represented in RFC3339 form and is in UTC."
@@ -152,6 +614,58 @@ type TaskSpec struct {
 	// If exceeded, the task executor should terminate the task.
 	// +optional
 	TimeoutSeconds *int64 `json:"timeoutSeconds,omitempty"`
+	// ServiceAccountName is the name of the ServiceAccount the task runs as.
+	// Defaults to the serviceAccountName of the BatchSandbox pod template when empty.
+	// It is applied to the shard's Pod, or to the Pod template of an IndexedJob task,
+	// so it only takes effect for Pods created after it is set. Not supported with PoolRef,
+	// whose Pods already run as the Pool's ServiceAccount.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// PriorityClassName is the PriorityClass the task's shard is scheduled at.
+	// Defaults to the priorityClassName of the BatchSandbox pod template when empty.
+	// An explicit value is also set on the shard's Pod, so higher-priority batches
+	// can preempt lower ones. Not supported with PoolRef, whose Pods already run.
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// StartupProbe is run by the task executor once the process has started. The shard
+	// counts as ready only after it passes; if it still fails after FailureThreshold
+	// attempts, the task is stopped and fails. Only exec and httpGet (with a numeric port)
+	// handlers are supported; httpGet defaults to 127.0.0.1, the sandbox itself.
+	// +optional
+	StartupProbe *corev1.Probe `json:"startupProbe,omitempty"`
+	// Sidecars are helper processes, such as log shippers or metrics agents, that run
+	// next to the task's process. They are started before the process and stopped once
+	// it exits; their own exit codes do not affect the task's result. Shard patches
+	// merge sidecars by name. Requires process.
+	// +optional
+	// +patchMergeKey=name
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=name
+	Sidecars []SidecarProcess `json:"sidecars,omitempty" patchStrategy:"merge" patchMergeKey:"name"`
+}
+
+// SidecarProcess is a helper process run alongside a task's process.
+type SidecarProcess struct {
+	// Name identifies the sidecar within the task and names its log files.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+	// Command command
+	// +kubebuilder:validation:Required
+	Command []string `json:"command"`
+	// Arguments to the entrypoint.
+	// +optional
+	Args []string `json:"args,omitempty"`
+	// Environment variables set in the sidecar on top of the task's environment.
+	// +optional
+	// +patchMergeKey=name
+	// +patchStrategy=merge
+	Env []corev1.EnvVar `json:"env,omitempty"`
+	// WorkingDir sidecar working directory.
+	// +optional
+	WorkingDir string `json:"workingDir,omitempty"`
 }
 
 type ProcessTask struct {
@@ -20,7 +20,9 @@ package v1alpha1
 
 import (
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -29,7 +31,7 @@ func (in *BatchSandbox) DeepCopyInto(out *BatchSandbox) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BatchSandbox.
@@ -102,6 +104,23 @@ func (in *BatchSandboxSpec) DeepCopyInto(out *BatchSandboxSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.VolumeClaimTemplates != nil {
+		in, out := &in.VolumeClaimTemplates, &out.VolumeClaimTemplates
+		*out = make([]v1.PersistentVolumeClaim, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DisruptionBudget != nil {
+		in, out := &in.DisruptionBudget, &out.DisruptionBudget
+		*out = new(DisruptionBudgetSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImagePrePull != nil {
+		in, out := &in.ImagePrePull, &out.ImagePrePull
+		*out = new(ImagePrePullSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.ExpireTime != nil {
 		in, out := &in.ExpireTime, &out.ExpireTime
 		*out = (*in).DeepCopy()
@@ -111,6 +130,11 @@ func (in *BatchSandboxSpec) DeepCopyInto(out *BatchSandboxSpec) {
 		*out = new(TaskTemplateSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.TaskUpdateStrategy != nil {
+		in, out := &in.TaskUpdateStrategy, &out.TaskUpdateStrategy
+		*out = new(TaskUpdateStrategy)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.ShardTaskPatches != nil {
 		in, out := &in.ShardTaskPatches, &out.ShardTaskPatches
 		*out = make([]runtime.RawExtension, len(*in))
@@ -118,11 +142,58 @@ func (in *BatchSandboxSpec) DeepCopyInto(out *BatchSandboxSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.TaskTemplateValues != nil {
+		in, out := &in.TaskTemplateValues, &out.TaskTemplateValues
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ShardTaskValues != nil {
+		in, out := &in.ShardTaskValues, &out.ShardTaskValues
+		*out = make([]map[string]string, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = make(map[string]string, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+		}
+	}
+	if in.TaskMatrix != nil {
+		in, out := &in.TaskMatrix, &out.TaskMatrix
+		*out = make([]TaskMatrixDimension, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TaskParameters != nil {
+		in, out := &in.TaskParameters, &out.TaskParameters
+		*out = new(TaskParameterSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TaskCanary != nil {
+		in, out := &in.TaskCanary, &out.TaskCanary
+		*out = new(TaskCanarySpec)
+		**out = **in
+	}
+	if in.TaskCompletionPolicy != nil {
+		in, out := &in.TaskCompletionPolicy, &out.TaskCompletionPolicy
+		*out = new(TaskCompletionPolicySpec)
+		**out = **in
+	}
 	if in.TaskResourcePolicyWhenCompleted != nil {
 		in, out := &in.TaskResourcePolicyWhenCompleted, &out.TaskResourcePolicyWhenCompleted
 		*out = new(TaskResourcePolicy)
 		**out = **in
 	}
+	if in.TaskFailureLogs != nil {
+		in, out := &in.TaskFailureLogs, &out.TaskFailureLogs
+		*out = new(TaskFailureLogsSpec)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BatchSandboxSpec.
@@ -138,6 +209,38 @@ func (in *BatchSandboxSpec) DeepCopy() *BatchSandboxSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BatchSandboxStatus) DeepCopyInto(out *BatchSandboxStatus) {
 	*out = *in
+	if in.TaskCanary != nil {
+		in, out := &in.TaskCanary, &out.TaskCanary
+		*out = new(TaskCanaryStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TaskCompletion != nil {
+		in, out := &in.TaskCompletion, &out.TaskCompletion
+		*out = new(TaskCompletionStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TaskShards != nil {
+		in, out := &in.TaskShards, &out.TaskShards
+		*out = make([]TaskShardStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TaskStartTime != nil {
+		in, out := &in.TaskStartTime, &out.TaskStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.TaskFinishTime != nil {
+		in, out := &in.TaskFinishTime, &out.TaskFinishTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BatchSandboxStatus.
@@ -165,6 +268,56 @@ func (in *CapacitySpec) DeepCopy() *CapacitySpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DisruptionBudgetSpec) DeepCopyInto(out *DisruptionBudgetSpec) {
+	*out = *in
+	if in.MinAvailable != nil {
+		in, out := &in.MinAvailable, &out.MinAvailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DisruptionBudgetSpec.
+func (in *DisruptionBudgetSpec) DeepCopy() *DisruptionBudgetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DisruptionBudgetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImagePrePullSpec) DeepCopyInto(out *ImagePrePullSpec) {
+	*out = *in
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImagePrePullSpec.
+func (in *ImagePrePullSpec) DeepCopy() *ImagePrePullSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ImagePrePullSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Pool) DeepCopyInto(out *Pool) {
 	*out = *in
@@ -292,6 +445,240 @@ func (in *ProcessTask) DeepCopy() *ProcessTask {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RollingUpdateTaskStrategy) DeepCopyInto(out *RollingUpdateTaskStrategy) {
+	*out = *in
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RollingUpdateTaskStrategy.
+func (in *RollingUpdateTaskStrategy) DeepCopy() *RollingUpdateTaskStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(RollingUpdateTaskStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SidecarProcess) DeepCopyInto(out *SidecarProcess) {
+	*out = *in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]v1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SidecarProcess.
+func (in *SidecarProcess) DeepCopy() *SidecarProcess {
+	if in == nil {
+		return nil
+	}
+	out := new(SidecarProcess)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TaskCanarySpec) DeepCopyInto(out *TaskCanarySpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TaskCanarySpec.
+func (in *TaskCanarySpec) DeepCopy() *TaskCanarySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TaskCanarySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TaskCanaryStatus) DeepCopyInto(out *TaskCanaryStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TaskCanaryStatus.
+func (in *TaskCanaryStatus) DeepCopy() *TaskCanaryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TaskCanaryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TaskCompletionPolicySpec) DeepCopyInto(out *TaskCompletionPolicySpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TaskCompletionPolicySpec.
+func (in *TaskCompletionPolicySpec) DeepCopy() *TaskCompletionPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TaskCompletionPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TaskCompletionStatus) DeepCopyInto(out *TaskCompletionStatus) {
+	*out = *in
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TaskCompletionStatus.
+func (in *TaskCompletionStatus) DeepCopy() *TaskCompletionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TaskCompletionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TaskFailureLogsSpec) DeepCopyInto(out *TaskFailureLogsSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TaskFailureLogsSpec.
+func (in *TaskFailureLogsSpec) DeepCopy() *TaskFailureLogsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TaskFailureLogsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TaskMatrixDimension) DeepCopyInto(out *TaskMatrixDimension) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TaskMatrixDimension.
+func (in *TaskMatrixDimension) DeepCopy() *TaskMatrixDimension {
+	if in == nil {
+		return nil
+	}
+	out := new(TaskMatrixDimension)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TaskParameterConfigMapRef) DeepCopyInto(out *TaskParameterConfigMapRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TaskParameterConfigMapRef.
+func (in *TaskParameterConfigMapRef) DeepCopy() *TaskParameterConfigMapRef {
+	if in == nil {
+		return nil
+	}
+	out := new(TaskParameterConfigMapRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TaskParameterEnv) DeepCopyInto(out *TaskParameterEnv) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TaskParameterEnv.
+func (in *TaskParameterEnv) DeepCopy() *TaskParameterEnv {
+	if in == nil {
+		return nil
+	}
+	out := new(TaskParameterEnv)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TaskParameterSource) DeepCopyInto(out *TaskParameterSource) {
+	*out = *in
+	out.ConfigMapRef = in.ConfigMapRef
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]TaskParameterEnv, len(*in))
+		copy(*out, *in)
+	}
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TaskParameterSource.
+func (in *TaskParameterSource) DeepCopy() *TaskParameterSource {
+	if in == nil {
+		return nil
+	}
+	out := new(TaskParameterSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TaskShardStatus) DeepCopyInto(out *TaskShardStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.FinishTime != nil {
+		in, out := &in.FinishTime, &out.FinishTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TaskShardStatus.
+func (in *TaskShardStatus) DeepCopy() *TaskShardStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TaskShardStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TaskSpec) DeepCopyInto(out *TaskSpec) {
 	*out = *in
@@ -305,6 +692,18 @@ func (in *TaskSpec) DeepCopyInto(out *TaskSpec) {
 		*out = new(int64)
 		**out = **in
 	}
+	if in.StartupProbe != nil {
+		in, out := &in.StartupProbe, &out.StartupProbe
+		*out = new(v1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Sidecars != nil {
+		in, out := &in.Sidecars, &out.Sidecars
+		*out = make([]SidecarProcess, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TaskSpec.
@@ -427,3 +826,23 @@ func (in *TaskTemplateSpec) DeepCopy() *TaskTemplateSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TaskUpdateStrategy) DeepCopyInto(out *TaskUpdateStrategy) {
+	*out = *in
+	if in.RollingUpdate != nil {
+		in, out := &in.RollingUpdate, &out.RollingUpdate
+		*out = new(RollingUpdateTaskStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TaskUpdateStrategy.
+func (in *TaskUpdateStrategy) DeepCopy() *TaskUpdateStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(TaskUpdateStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
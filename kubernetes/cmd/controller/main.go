@@ -63,6 +63,9 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var watchNamespaces, watchNamespaceSelector string
+	var batchSandboxConcurrentReconciles int
+	var imageRewrites string
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -81,6 +84,17 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"Comma-separated namespaces to reconcile. Combined with --watch-namespace-selector, a namespace "+
+			"matching either is reconciled. Leave both empty to reconcile all namespaces.")
+	flag.StringVar(&watchNamespaceSelector, "watch-namespace-selector", "",
+		"Label selector of namespaces to reconcile, e.g. opensandbox.io/tenant=team-a.")
+	flag.IntVar(&batchSandboxConcurrentReconciles, "batchsandbox-concurrent-reconciles",
+		controller.DefaultBatchSandboxConcurrentReconciles,
+		"The number of BatchSandboxes reconciled in parallel. Raise it when many BatchSandboxes queue up behind slow task executors.")
+	flag.StringVar(&imageRewrites, "image-rewrites", "",
+		"Comma-separated <prefix>=<replacement> rules applied to the images of BatchSandbox Templates, "+
+			"e.g. docker.io/=mirror.example.com/dockerhub/. Images set by shardPatches are not rewritten.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -209,19 +223,39 @@ func main() {
 		setupLog.Error(err, "failed to register field index")
 		os.Exit(1)
 	}
+	namespaceFilter, err := controller.NewNamespaceFilter(mgr.GetClient(), watchNamespaces, watchNamespaceSelector)
+	if err != nil {
+		setupLog.Error(err, "invalid namespace scope")
+		os.Exit(1)
+	}
+	if batchSandboxConcurrentReconciles < 1 {
+		setupLog.Error(nil, "--batchsandbox-concurrent-reconciles must be at least 1", "value", batchSandboxConcurrentReconciles)
+		os.Exit(1)
+	}
+	imageRewriter, err := controller.NewImageRewriter(imageRewrites)
+	if err != nil {
+		setupLog.Error(err, "invalid --image-rewrites")
+		os.Exit(1)
+	}
+	setupLog.Info("reconciling namespaces", "scope", namespaceFilter.String())
+	setupLog.Info("rewriting template images", "rules", imageRewriter.String())
 	if err := (&controller.BatchSandboxReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("batchsandbox-controller"),
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("batchsandbox-controller"),
+		NamespaceFilter:         namespaceFilter,
+		MaxConcurrentReconciles: batchSandboxConcurrentReconciles,
+		ImageRewriter:           imageRewriter,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "BatchSandbox")
 		os.Exit(1)
 	}
 	if err := (&controller.PoolReconciler{
-		Client:    mgr.GetClient(),
-		Scheme:    mgr.GetScheme(),
-		Recorder:  mgr.GetEventRecorderFor("pool-controller"),
-		Allocator: controller.NewDefaultAllocator(mgr.GetClient()),
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Recorder:        mgr.GetEventRecorderFor("pool-controller"),
+		Allocator:       controller.NewDefaultAllocator(mgr.GetClient()),
+		NamespaceFilter: namespaceFilter,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Pool")
 		os.Exit(1)
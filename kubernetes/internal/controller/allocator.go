@@ -269,7 +269,7 @@ func (allocator *defaultAllocator) allocate(ctx context.Context, status *AllocSt
 	dirtySandboxes := make([]string, 0)
 	poolDirty := false
 	for _, sbx := range sandboxes {
-		alloc, remainAvailablePods, sandboxDirty, poolAllocate, err := allocator.doAllocate(ctx, status, sandboxToPods, availablePods, sbx, *sbx.Spec.Replicas)
+		alloc, remainAvailablePods, sandboxDirty, poolAllocate, err := allocator.doAllocate(ctx, status, sandboxToPods, availablePods, sbx, desiredReplicas(sbx))
 		availablePods = remainAvailablePods
 		if err != nil {
 			errs = append(errs, err)
@@ -27,6 +27,11 @@ const (
 	AnnoAllocStatusKey           = "sandbox.opensandbox.io/alloc-status"
 	AnnoAllocReleaseKey          = "sandbox.opensandbox.io/alloc-release"
 	LabelBatchSandboxPodIndexKey = "batch-sandbox.sandbox.opensandbox.io/pod-index"
+	// LabelBatchSandboxNameKey selects the shard Pods of a BatchSandbox with a DisruptionBudget.
+	LabelBatchSandboxNameKey = "batch-sandbox.sandbox.opensandbox.io/name"
+	// AnnoShardDrainKey marks a shard Pod to be drained: its task is stopped and
+	// the Pod recreated. The value is an optional grace period in seconds.
+	AnnoShardDrainKey = "batch-sandbox.sandbox.opensandbox.io/drain"
 
 	AnnoPoolAllocStatusKey     = "pool.opensandbox.io/alloc-status"
 	AnnoPoolAllocGenerationKey = "pool.opensandbox.io/alloc-generation"
@@ -0,0 +1,178 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/utils/fieldindex"
+)
+
+// slowClient adds a fixed round trip to every API call, standing in for the
+// API server so parallel reconciles have something to overlap.
+type slowClient struct {
+	client.Client
+	latency time.Duration
+}
+
+func (c *slowClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	time.Sleep(c.latency)
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func (c *slowClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	time.Sleep(c.latency)
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c *slowClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	time.Sleep(c.latency)
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *slowClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	time.Sleep(c.latency)
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+// newConcurrencyFixture returns a reconciler over n BatchSandboxes of two
+// replicas each; every other one carries a task template.
+func newConcurrencyFixture(n int, latency time.Duration) (*BatchSandboxReconciler, []types.NamespacedName) {
+	builder := fake.NewClientBuilder().WithScheme(testscheme).
+		WithIndex(&corev1.Pod{}, fieldindex.IndexNameForOwnerRefUID, fieldindex.OwnerIndexFunc).
+		WithStatusSubresource(&sandboxv1alpha1.BatchSandbox{})
+	keys := make([]types.NamespacedName, 0, n)
+	for i := 0; i < n; i++ {
+		bs := &sandboxv1alpha1.BatchSandbox{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: fmt.Sprintf("concurrent-%d", i), UID: types.UID(fmt.Sprintf("uid-%d", i))},
+			Spec: sandboxv1alpha1.BatchSandboxSpec{
+				Replicas: ptr.To[int32](2),
+				Template: &corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: "busybox"}}}},
+			},
+		}
+		if i%2 == 0 {
+			bs.Spec.TaskTemplate = &sandboxv1alpha1.TaskTemplateSpec{Spec: sandboxv1alpha1.TaskSpec{
+				Process: &sandboxv1alpha1.ProcessTask{Command: []string{"echo", "hello"}},
+			}}
+		}
+		builder = builder.WithObjects(bs)
+		keys = append(keys, client.ObjectKeyFromObject(bs))
+	}
+	r := &BatchSandboxReconciler{
+		Client:   &slowClient{Client: builder.Build(), latency: latency},
+		Scheme:   testscheme,
+		Recorder: record.NewFakeRecorder(10 * n),
+	}
+	return r, keys
+}
+
+// reconcileRounds reconciles every key rounds times with workers goroutines.
+// Like the controller's workqueue, a key is handed to one worker at a time.
+func reconcileRounds(r *BatchSandboxReconciler, keys []types.NamespacedName, workers, rounds int) error {
+	var (
+		mu    sync.Mutex
+		first error
+	)
+	for round := 0; round < rounds; round++ {
+		queue := make(chan types.NamespacedName, len(keys))
+		for _, key := range keys {
+			queue <- key
+		}
+		close(queue)
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for key := range queue {
+					if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+						mu.Lock()
+						if first == nil {
+							first = fmt.Errorf("reconcile %s: %w", key, err)
+						}
+						mu.Unlock()
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	return first
+}
+
+// Run with -race: many objects reconciling in parallel share the reconciler's
+// task schedulers and the package-level expectations and requeue durations.
+func TestBatchSandboxReconciler_ConcurrentReconcile(t *testing.T) {
+	r, keys := newConcurrencyFixture(40, 0)
+	// finalizer, pods and task scheduler, then status from the created pods
+	if err := reconcileRounds(r, keys, 8, 3); err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range keys {
+		bs := &sandboxv1alpha1.BatchSandbox{}
+		if err := r.Get(context.Background(), key, bs); err != nil {
+			t.Fatal(err)
+		}
+		if bs.Status.Replicas != 2 {
+			t.Errorf("%s: expected 2 replicas in status, got %d", key, bs.Status.Replicas)
+		}
+		_, scheduled := r.taskSchedulers.Load(key.String())
+		if scheduled != (i%2 == 0) {
+			t.Errorf("%s: task scheduler present = %t, want %t", key, scheduled, i%2 == 0)
+		}
+	}
+	pods := &corev1.PodList{}
+	if err := r.List(context.Background(), pods); err != nil {
+		t.Fatal(err)
+	}
+	if len(pods.Items) != 2*len(keys) {
+		t.Fatalf("expected %d pods, got %d", 2*len(keys), len(pods.Items))
+	}
+}
+
+// BenchmarkBatchSandboxReconcile reports BatchSandboxes brought up per second
+// with a 2ms API round trip, by worker count.
+func BenchmarkBatchSandboxReconcile(b *testing.B) {
+	for _, workers := range []int{1, 8, 32} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			objects := 0
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				r, keys := newConcurrencyFixture(64, 2*time.Millisecond)
+				b.StartTimer()
+				if err := reconcileRounds(r, keys, workers, 3); err != nil {
+					b.Fatal(err)
+				}
+				objects += len(keys)
+			}
+			b.ReportMetric(float64(objects)/time.Since(start).Seconds(), "objects/s")
+		})
+	}
+}
@@ -26,8 +26,12 @@ import (
 	"sync"
 	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -51,22 +55,49 @@ import (
 	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/utils/expectations"
 	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/utils/fieldindex"
 	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/utils/requeueduration"
+	api "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
 )
 
 var (
 	BatchSandboxScaleExpectations = expectations.NewScaleExpectations()
 	DurationStore                 = requeueduration.DurationStore{}
+	// timeNow is the clock task transitions are stamped with; tests replace it.
+	timeNow = time.Now
 )
 
 // BatchSandboxReconciler reconciles a BatchSandbox object
 type BatchSandboxReconciler struct {
 	client.Client
-	Scheme         *runtime.Scheme
-	Recorder       record.EventRecorder
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// NamespaceFilter, when set, restricts reconciliation to matching namespaces.
+	NamespaceFilter *NamespaceFilter
+	// MaxConcurrentReconciles is the number of BatchSandboxes reconciled in
+	// parallel; 0 means DefaultBatchSandboxConcurrentReconciles. A single
+	// BatchSandbox is never reconciled by two workers at once, and state shared
+	// across objects (task schedulers, scale expectations, requeue durations)
+	// is keyed by object and safe for concurrent use.
+	MaxConcurrentReconciles int
+	// ImageRewriter, when set, rewrites the registry prefixes of Template
+	// images in the Pods and Jobs created for BatchSandboxes.
+	ImageRewriter  *ImageRewriter
 	taskSchedulers sync.Map
+	// taskSpecGenerations holds the generation each task scheduler's specs were
+	// generated from.
+	taskSpecGenerations sync.Map
 }
 
+// DefaultBatchSandboxConcurrentReconciles is the BatchSandbox worker count
+// used when none is configured.
+const DefaultBatchSandboxConcurrentReconciles = 32
+
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups=core,resources=resourcequotas,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;patch
+// +kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=sandbox.opensandbox.io,resources=batchsandboxes,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=sandbox.opensandbox.io,resources=batchsandboxes/status,verbs=get;update;patch
@@ -114,8 +145,23 @@ func (r *BatchSandboxReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		}
 	}
 
+	params, stop, err := r.syncTaskParameters(ctx, batchSbx)
+	if stop {
+		return ctrl.Result{RequeueAfter: DurationStore.Pop(req.String())}, err
+	}
+	if stop, err := r.applyTaskMatrixReplicas(batchSbx); stop {
+		return ctrl.Result{}, err
+	}
+	if stop, err := r.syncDisruptionBudget(ctx, batchSbx); stop || err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if batchSbx.Spec.TaskOutput == sandboxv1alpha1.TaskOutputIndexedJob {
+		return r.reconcileIndexedJob(ctx, batchSbx, params)
+	}
+
 	// task schedule
-	taskStrategy := strategy.NewTaskSchedulingStrategy(batchSbx)
+	taskStrategy := strategy.NewTaskSchedulingStrategyWithParameters(batchSbx, params)
 
 	// pool strategy
 	poolStrategy := strategy.NewPoolStrategy(batchSbx)
@@ -123,6 +169,13 @@ func (r *BatchSandboxReconciler) Reconcile(ctx context.Context, req ctrl.Request
 	// handle finalizers
 	if batchSbx.DeletionTimestamp == nil {
 		if taskStrategy.NeedTaskScheduling() {
+			// There is no admission webhook, so a task template that cannot be
+			// generated stops the BatchSandbox here, before any pod is created.
+			// Fixing the spec triggers the next reconcile.
+			if err := taskStrategy.ValidateTaskTemplate(); err != nil {
+				klog.Errorf("batchsandbox %s has an invalid task template: %v", klog.KObj(batchSbx), err)
+				return ctrl.Result{}, r.rejectSpec(batchSbx, "InvalidTaskTemplate", fmt.Sprintf("invalid task template: %v", err))
+			}
 			if !controllerutil.ContainsFinalizer(batchSbx, FinalizerTaskCleanup) {
 				err := utils.UpdateFinalizer(r.Client, batchSbx, utils.AddFinalizerOpType, FinalizerTaskCleanup)
 				if err != nil {
@@ -151,12 +204,23 @@ func (r *BatchSandboxReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		utils.WithPodIndexSorter(podIndex),
 		utils.PodNameSorter,
 	}).Sort)
+	if batchSbx.DeletionTimestamp == nil {
+		if err := validateVolumeClaimTemplates(batchSbx); err != nil {
+			klog.Errorf("batchsandbox %s has invalid volume claim templates: %v", klog.KObj(batchSbx), err)
+			return ctrl.Result{}, r.rejectSpec(batchSbx, "InvalidVolumeClaimTemplates", fmt.Sprintf("invalid volume claim templates: %v", err))
+		}
+		if err := r.acceptSpec(batchSbx); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
 	// Normal Mode need scale Pods
+	var quotaCond *metav1.Condition
 	if !poolStrategy.IsPooledMode() {
-		err := r.scaleBatchSandbox(ctx, batchSbx, batchSbx.Spec.Template, pods)
+		cond, err := r.scaleBatchSandbox(ctx, batchSbx, batchSbx.Spec.Template, taskStrategy, pods)
 		if err != nil {
 			return ctrl.Result{}, fmt.Errorf("failed to scale batch sandbox %w", err)
 		}
+		quotaCond = cond
 	}
 
 	// TODO merge task status update
@@ -165,6 +229,10 @@ func (r *BatchSandboxReconciler) Reconcile(ctx context.Context, req ctrl.Request
 	newStatus.Replicas = 0
 	newStatus.Allocated = 0
 	newStatus.Ready = 0
+	if quotaCond != nil {
+		quotaCond.ObservedGeneration = batchSbx.Generation
+		meta.SetStatusCondition(&newStatus.Conditions, *quotaCond)
+	}
 	ipList := make([]string, len(pods))
 	for i, pod := range pods {
 		newStatus.Replicas++
@@ -195,13 +263,16 @@ func (r *BatchSandboxReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		klog.Infof("To update BatchSandbox status for %s, replicas=%d allocated=%d ready=%d", klog.KObj(batchSbx), newStatus.Replicas, newStatus.Allocated, newStatus.Ready)
 		if err := r.updateStatus(batchSbx, newStatus); err != nil {
 			aggErrors = append(aggErrors, err)
+		} else {
+			// scheduleTasks starts from this status; a stale copy would reset the replica counts
+			batchSbx.Status = *newStatus
 		}
 	}
 
 	if taskStrategy.NeedTaskScheduling() {
 		// Because tasks are in-memory and there is no event mechanism, periodic reconciliation is required.
 		DurationStore.Push(types.NamespacedName{Namespace: batchSbx.Namespace, Name: batchSbx.Name}.String(), 3*time.Second)
-		sch, err := r.getTaskScheduler(batchSbx, pods)
+		sch, err := r.getTaskScheduler(batchSbx, taskStrategy, pods)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
@@ -210,9 +281,17 @@ func (r *BatchSandboxReconciler) Reconcile(ctx context.Context, req ctrl.Request
 			if len(stoppingTasks) > 0 {
 				klog.Infof("BatchSandbox %s is stopping %d tasks this round", klog.KObj(batchSbx), len(stoppingTasks))
 			}
+		} else {
+			if err := r.syncTaskSpecs(batchSbx, sch, taskStrategy); err != nil {
+				aggErrors = append(aggErrors, err)
+			}
+			if err := r.drainShards(ctx, batchSbx, sch, pods, poolStrategy.IsPooledMode()); err != nil {
+				aggErrors = append(aggErrors, err)
+			}
+			r.rollOutTaskUpdates(batchSbx, sch)
 		}
 		now := time.Now()
-		if err = r.scheduleTasks(ctx, sch, batchSbx); err != nil {
+		if err = r.scheduleTasks(ctx, sch, batchSbx, taskStrategy); err != nil {
 			return ctrl.Result{}, fmt.Errorf("failed to schedule tasks, err %w", err)
 		} else {
 			klog.Infof("BatchSandbox %s schedule tasks cost %d ms", klog.KObj(batchSbx), time.Since(now).Milliseconds())
@@ -316,7 +395,7 @@ func (r *BatchSandboxReconciler) listPods(ctx context.Context, poolStrategy stra
 	return ret, nil
 }
 
-func (r *BatchSandboxReconciler) getTaskScheduler(batchSbx *sandboxv1alpha1.BatchSandbox, pods []*corev1.Pod) (taskscheduler.TaskScheduler, error) {
+func (r *BatchSandboxReconciler) getTaskScheduler(batchSbx *sandboxv1alpha1.BatchSandbox, taskStrategy strategy.TaskSchedulingStrategy, pods []*corev1.Pod) (taskscheduler.TaskScheduler, error) {
 	var tSch taskscheduler.TaskScheduler
 	key := types.NamespacedName{Namespace: batchSbx.Namespace, Name: batchSbx.Name}.String()
 	val, ok := r.taskSchedulers.Load(key)
@@ -326,18 +405,25 @@ func (r *BatchSandboxReconciler) getTaskScheduler(batchSbx *sandboxv1alpha1.Batc
 		if batchSbx.Spec.TaskResourcePolicyWhenCompleted != nil {
 			policy = *batchSbx.Spec.TaskResourcePolicyWhenCompleted
 		}
-		taskStrategy := strategy.NewTaskSchedulingStrategy(batchSbx)
 		taskSpecs, err := taskStrategy.GenerateTaskSpecs()
 		if err != nil {
 			return nil, err
 		}
-		sc, err := taskscheduler.NewTaskScheduler(key, taskSpecs, pods, policy)
+		sc, err := taskscheduler.NewTaskScheduler(key, taskSpecs, pods, policy, failureLogCapture(batchSbx))
 		if err != nil {
 			return nil, fmt.Errorf("new task scheduler err %w", err)
 		}
 		klog.Infof("successfully new task scheduler for batch sandbox %s", klog.KObj(batchSbx))
 		tSch = sc
-		r.taskSchedulers.Store(key, sc)
+		r.taskSpecGenerations.Store(key, batchSbx.Generation)
+		if actual, loaded := r.taskSchedulers.LoadOrStore(key, sc); loaded {
+			// unreachable while the workqueue serializes each key; keep the first
+			// scheduler so two never drive the same tasks
+			tSch, ok = actual.(taskscheduler.TaskScheduler)
+			if !ok {
+				return nil, gerrors.New("invalid scheduler type stored")
+			}
+		}
 	} else {
 		tSch, ok = (val.(taskscheduler.TaskScheduler))
 		if !ok {
@@ -353,9 +439,30 @@ func (r *BatchSandboxReconciler) deleteTaskScheduler(batchSbx *sandboxv1alpha1.B
 	klog.Infof("delete task scheduler for batch sandbox %s", klog.KObj(batchSbx))
 	key := types.NamespacedName{Namespace: batchSbx.Namespace, Name: batchSbx.Name}.String()
 	r.taskSchedulers.Delete(key)
+	r.taskSpecGenerations.Delete(key)
 }
 
-func (r *BatchSandboxReconciler) scheduleTasks(ctx context.Context, tSch taskscheduler.TaskScheduler, batchSbx *sandboxv1alpha1.BatchSandbox) error {
+func (r *BatchSandboxReconciler) scheduleTasks(ctx context.Context, tSch taskscheduler.TaskScheduler, batchSbx *sandboxv1alpha1.BatchSandbox, taskStrategy strategy.TaskSchedulingStrategy) error {
+	now := timeNow()
+	current := tSch.ListTask()
+	admission := taskStrategy.AdmitTasks(current, now)
+	completion := strategy.EvaluateTaskCompletion(batchSbx, current, admission.Abort, now)
+	completed := completion != nil && completion.Phase != sandboxv1alpha1.TaskCompletionRunning
+	// a suspended batch assigns no further tasks; an abort still stops the running ones
+	suspended := batchSbx.Spec.Suspend && !admission.Abort && !completed
+	if suspended || completed {
+		admission.Admitted = 0
+	}
+	tSch.SetAdmitted(admission.Admitted)
+	if admission.Abort && batchSbx.DeletionTimestamp == nil {
+		klog.Infof("BatchSandbox %s aborts its tasks: %s", klog.KObj(batchSbx), admission.Canary.Message)
+		tSch.StopTask()
+	}
+	if completed && batchSbx.DeletionTimestamp == nil {
+		if stopped := tSch.StopUnfinishedTask(); len(stopped) > 0 {
+			klog.Infof("BatchSandbox %s is complete (%s), stopping %d unfinished tasks", klog.KObj(batchSbx), completion.Message, len(stopped))
+		}
+	}
 	if err := tSch.Schedule(); err != nil {
 		return err
 	}
@@ -363,14 +470,28 @@ func (r *BatchSandboxReconciler) scheduleTasks(ctx context.Context, tSch tasksch
 	toReleasedPods := []string{}
 	var (
 		running, failed, succeed, unknown int32
-		pending                           int32
+		pending, ready, outdated          int32
 	)
+	shards := make([]sandboxv1alpha1.TaskShardStatus, len(tasks))
+	stamp := metav1.NewTime(now)
 	for i := range len(tasks) {
 		task := tasks[i]
+		shards[i] = sandboxv1alpha1.TaskShardStatus{Index: int32(i), PodName: task.GetPodName()}
 		if task.GetPodName() == "" {
 			pending++
 		} else {
 			state := task.GetState()
+			shards[i].State = string(state)
+			if task.IsReady() {
+				shards[i].Ready = true
+				ready++
+			}
+			shards[i].Draining = task.IsDraining()
+			shards[i].Outdated = task.IsOutdated()
+			shards[i].Updating = task.IsUpdating()
+			if shards[i].Outdated {
+				outdated++
+			}
 			if task.IsResourceReleased() {
 				toReleasedPods = append(toReleasedPods, task.GetPodName())
 			}
@@ -385,6 +506,8 @@ func (r *BatchSandboxReconciler) scheduleTasks(ctx context.Context, tSch tasksch
 				unknown++
 			}
 		}
+		stampShardTimes(&shards[i], batchSbx.Status.TaskShards, stamp)
+		r.syncShardFailureLogs(ctx, batchSbx, &shards[i], task)
 	}
 	if len(toReleasedPods) > 0 {
 		klog.Infof("batch sandbox %s try to release %d Pod", klog.KObj(batchSbx), len(toReleasedPods))
@@ -401,9 +524,24 @@ func (r *BatchSandboxReconciler) scheduleTasks(ctx context.Context, tSch tasksch
 	newStatus.TaskSucceed = succeed
 	newStatus.TaskUnknown = unknown
 	newStatus.TaskPending = pending
+	newStatus.TaskReady = ready
+	newStatus.TaskUpdated = int32(len(tasks)) - outdated
+	newStatus.TaskShards = shards
+	if newStatus.TaskStartTime == nil && pending < int32(len(tasks)) {
+		newStatus.TaskStartTime = &stamp
+	}
+	if newStatus.TaskFinishTime == nil && len(tasks) > 0 && succeed+failed == int32(len(tasks)) {
+		newStatus.TaskFinishTime = &stamp
+	}
+	newStatus.TaskCanary = admission.Canary
+	r.recordCanaryTransition(batchSbx, oldStatus.TaskCanary, newStatus.TaskCanary)
+	newStatus.TaskCompletion = completion
+	r.recordCompletionTransition(batchSbx, oldStatus.TaskCompletion, newStatus.TaskCompletion)
+	newStatus.TaskSuspended = suspended
+	r.recordSuspendTransition(batchSbx, oldStatus.TaskSuspended, newStatus.TaskSuspended)
 	if !reflect.DeepEqual(newStatus, oldStatus) {
-		klog.Infof("To update BatchSandbox status for %s, replicas=%d task_running=%d task_succeed=%d, task_failed=%d, task_unknown=%d, task_pending=%d", klog.KObj(batchSbx), newStatus.Replicas,
-			newStatus.TaskRunning, newStatus.TaskSucceed, newStatus.TaskFailed, newStatus.TaskUnknown, newStatus.TaskPending)
+		klog.Infof("To update BatchSandbox status for %s, replicas=%d task_running=%d task_succeed=%d, task_failed=%d, task_unknown=%d, task_pending=%d, task_ready=%d", klog.KObj(batchSbx), newStatus.Replicas,
+			newStatus.TaskRunning, newStatus.TaskSucceed, newStatus.TaskFailed, newStatus.TaskUnknown, newStatus.TaskPending, newStatus.TaskReady)
 		if err := r.updateStatus(batchSbx, newStatus); err != nil {
 			return err
 		}
@@ -411,6 +549,68 @@ func (r *BatchSandboxReconciler) scheduleTasks(ctx context.Context, tSch tasksch
 	return nil
 }
 
+// stampShardTimes carries a shard's start and finish times over from the last
+// status and sets each the first time the shard is seen assigned or finished.
+func stampShardTimes(shard *sandboxv1alpha1.TaskShardStatus, last []sandboxv1alpha1.TaskShardStatus, now metav1.Time) {
+	idx := int(shard.Index)
+	if idx >= sandboxv1alpha1.MaxTaskShardTimestamps {
+		return
+	}
+	// a drained shard starts over on its next Pod, a replaced one with its new task
+	if idx < len(last) {
+		restarted := last[idx].Draining && !shard.Draining || last[idx].Updating && !shard.Updating
+		if !restarted {
+			shard.StartTime, shard.FinishTime = last[idx].StartTime, last[idx].FinishTime
+		}
+	}
+	if shard.PodName == "" {
+		return
+	}
+	if shard.StartTime == nil {
+		shard.StartTime = now.DeepCopy()
+	}
+	finished := shard.State == string(taskscheduler.SucceedTaskState) || shard.State == string(taskscheduler.FailedTaskState)
+	if finished && shard.FinishTime == nil {
+		shard.FinishTime = now.DeepCopy()
+	}
+}
+
+// recordCanaryTransition emits an event when the canary reaches a final phase.
+func (r *BatchSandboxReconciler) recordCanaryTransition(batchSbx *sandboxv1alpha1.BatchSandbox, oldStatus, newStatus *sandboxv1alpha1.TaskCanaryStatus) {
+	if newStatus == nil || (oldStatus != nil && oldStatus.Phase == newStatus.Phase) {
+		return
+	}
+	switch newStatus.Phase {
+	case sandboxv1alpha1.TaskCanarySucceeded:
+		r.Recorder.Event(batchSbx, corev1.EventTypeNormal, "TaskCanarySucceeded", "canary task succeeded, scheduling the remaining tasks")
+	case sandboxv1alpha1.TaskCanaryFailed:
+		r.Recorder.Eventf(batchSbx, corev1.EventTypeWarning, "TaskCanaryFailed", "%s, aborting the remaining tasks", newStatus.Message)
+	}
+}
+
+// recordCompletionTransition emits an event when the completion policy reaches a final phase.
+func (r *BatchSandboxReconciler) recordCompletionTransition(batchSbx *sandboxv1alpha1.BatchSandbox, oldStatus, newStatus *sandboxv1alpha1.TaskCompletionStatus) {
+	if newStatus == nil || (oldStatus != nil && oldStatus.Phase == newStatus.Phase) {
+		return
+	}
+	switch newStatus.Phase {
+	case sandboxv1alpha1.TaskCompletionSucceeded:
+		r.Recorder.Eventf(batchSbx, corev1.EventTypeNormal, "TaskCompletionSucceeded", "%s, stopping the unfinished tasks", newStatus.Message)
+	case sandboxv1alpha1.TaskCompletionFailed:
+		r.Recorder.Eventf(batchSbx, corev1.EventTypeWarning, "TaskCompletionFailed", "%s, stopping the unfinished tasks", newStatus.Message)
+	}
+}
+
+// recordSuspendTransition emits an event when task assignment is suspended or resumed.
+func (r *BatchSandboxReconciler) recordSuspendTransition(batchSbx *sandboxv1alpha1.BatchSandbox, oldSuspended, newSuspended bool) {
+	switch {
+	case newSuspended && !oldSuspended:
+		r.Recorder.Event(batchSbx, corev1.EventTypeNormal, "TaskSuspended", "suspended, pending tasks will not be scheduled")
+	case oldSuspended && !newSuspended:
+		r.Recorder.Event(batchSbx, corev1.EventTypeNormal, "TaskResumed", "resumed, scheduling the pending tasks")
+	}
+}
+
 func (r *BatchSandboxReconciler) getTasksCleanupUnfinished(batchSbx *sandboxv1alpha1.BatchSandbox, tSch taskscheduler.TaskScheduler) []taskscheduler.Task {
 	var notReleased []taskscheduler.Task
 	for _, task := range tSch.ListTask() {
@@ -455,7 +655,7 @@ func (r *BatchSandboxReconciler) releasePods(ctx context.Context, batchSbx *sand
 }
 
 // Normal Mode
-func (r *BatchSandboxReconciler) scaleBatchSandbox(ctx context.Context, batchSandbox *sandboxv1alpha1.BatchSandbox, podTemplateSpec *corev1.PodTemplateSpec, pods []*corev1.Pod) error {
+func (r *BatchSandboxReconciler) scaleBatchSandbox(ctx context.Context, batchSandbox *sandboxv1alpha1.BatchSandbox, podTemplateSpec *corev1.PodTemplateSpec, taskStrategy strategy.TaskSchedulingStrategy, pods []*corev1.Pod) (*metav1.Condition, error) {
 	indexedPodMap := map[int]*corev1.Pod{}
 	for i := range pods {
 		pod := pods[i]
@@ -463,14 +663,14 @@ func (r *BatchSandboxReconciler) scaleBatchSandbox(ctx context.Context, batchSan
 		pods = append(pods, pod)
 		idx, err := parseIndex(pod)
 		if err != nil {
-			return fmt.Errorf("failed to parse idx Pod %s, err %w", pod.Name, err)
+			return nil, fmt.Errorf("failed to parse idx Pod %s, err %w", pod.Name, err)
 		}
 		indexedPodMap[idx] = pod
 	}
 	if satisfied, unsatisfiedDuration, dirtyPods := BatchSandboxScaleExpectations.SatisfiedExpectations(controllerutils.GetControllerKey(batchSandbox)); !satisfied {
 		klog.Infof("BatchSandbox %s scale expectation is not satisfied overtime=%v, dirty pods=%v", klog.KObj(batchSandbox), unsatisfiedDuration, dirtyPods)
 		DurationStore.Push(types.NamespacedName{Namespace: batchSandbox.Namespace, Name: batchSandbox.Name}.String(), expectations.ExpectationTimeout-unsatisfiedDuration)
-		return nil
+		return nil, nil
 	}
 	// TODO consider supply Pods if Pods is deleted unexpectedly
 	var needCreateIndex []int
@@ -485,41 +685,118 @@ func (r *BatchSandboxReconciler) scaleBatchSandbox(ctx context.Context, batchSan
 	if len(needCreateIndex) > 0 {
 		klog.Infof("BatchSandbox %s try to create %d Pod, idx %v", klog.KObj(batchSandbox), len(needCreateIndex), needCreateIndex)
 	}
+	var taskSpecs []*api.Task
+	if len(needCreateIndex) > 0 && taskStrategy.NeedTaskScheduling() {
+		specs, err := taskStrategy.GenerateTaskSpecs()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate task specs: %w", err)
+		}
+		taskSpecs = specs
+	}
+	// rewritten before the shard patches, so an image a patch sets is kept
+	rewritten := r.ImageRewriter.RewriteTemplate(podTemplateSpec)
+	newPods := make([]*corev1.Pod, 0, len(needCreateIndex))
 	for _, idx := range needCreateIndex {
-		pod, err := utils.GetPodFromTemplate(podTemplateSpec, batchSandbox, metav1.NewControllerRef(batchSandbox, sandboxv1alpha1.SchemeBuilder.GroupVersion.WithKind("BatchSandbox")))
+		pod, err := utils.GetPodFromTemplate(rewritten, batchSandbox, metav1.NewControllerRef(batchSandbox, sandboxv1alpha1.SchemeBuilder.GroupVersion.WithKind("BatchSandbox")))
 		if err != nil {
-			return err
+			return nil, err
 		}
 		// Apply shard patch if available for this index
 		if len(batchSandbox.Spec.ShardPatches) > 0 && idx < len(batchSandbox.Spec.ShardPatches) {
 			podBytes, err := json.Marshal(pod)
 			if err != nil {
-				return fmt.Errorf("failed to marshal pod: %w", err)
+				return nil, fmt.Errorf("failed to marshal pod: %w", err)
 			}
 			patch := batchSandbox.Spec.ShardPatches[idx]
 			modifiedPodBytes, err := strategicpatch.StrategicMergePatch(podBytes, patch.Raw, &corev1.Pod{})
 			if err != nil {
-				return fmt.Errorf("failed to apply shard patch for index %d: %w", idx, err)
+				return nil, fmt.Errorf("failed to apply shard patch for index %d: %w", idx, err)
 			}
 			if err := json.Unmarshal(modifiedPodBytes, pod); err != nil {
-				return fmt.Errorf("failed to unmarshal patched pod for index %d: %w", idx, err)
+				return nil, fmt.Errorf("failed to unmarshal patched pod for index %d: %w", idx, err)
 			}
 		}
+		if idx < len(taskSpecs) {
+			applyTaskPriorityClass(pod, podTemplateSpec, taskSpecs[idx])
+			applyTaskServiceAccount(pod, podTemplateSpec, taskSpecs[idx])
+		}
+		applyShardClaims(pod, batchSandbox, idx)
 		if err := ctrl.SetControllerReference(pod, batchSandbox, r.Scheme); err != nil {
-			return err
+			return nil, err
 		}
 		pod.Labels[LabelBatchSandboxPodIndexKey] = strconv.Itoa(idx)
+		if batchSandbox.Spec.DisruptionBudget != nil {
+			pod.Labels[LabelBatchSandboxNameKey] = batchSandbox.Name
+		}
 		pod.Namespace = batchSandbox.Namespace
 		pod.Name = fmt.Sprintf("%s-%d", batchSandbox.Name, idx)
+		newPods = append(newPods, pod)
+	}
+	if ready, err := r.prePullImages(ctx, batchSandbox, len(indexedPodMap), newPods); err != nil || !ready {
+		return nil, err
+	}
+	admitted, shortage, err := r.admitShards(ctx, batchSandbox, newPods)
+	if err != nil {
+		return nil, err
+	}
+	pending := len(newPods) - len(admitted)
+	for i, pod := range admitted {
+		idx, _ := strconv.Atoi(pod.Labels[LabelBatchSandboxPodIndexKey])
+		if err := r.ensureShardClaims(ctx, batchSandbox, idx); err != nil {
+			return nil, fmt.Errorf("failed to create volume claims for index %d: %w", idx, err)
+		}
 		BatchSandboxScaleExpectations.ExpectScale(controllerutils.GetControllerKey(batchSandbox), expectations.Create, pod.Name)
 		if err := r.Create(ctx, pod); err != nil {
 			BatchSandboxScaleExpectations.ObserveScale(controllerutils.GetControllerKey(batchSandbox), expectations.Create, pod.Name)
+			if isQuotaExceeded(err) {
+				shortage = &quotaShortage{rejected: err}
+				pending += len(admitted) - i
+				break
+			}
 			r.Recorder.Eventf(batchSandbox, corev1.EventTypeWarning, "FailedCreate", "failed to create pod: %v, pod: %v", err, utils.DumpJSON(pod))
-			return err
+			return nil, err
 		}
 		r.Recorder.Eventf(batchSandbox, corev1.EventTypeNormal, "SuccessfulCreate", "succeed to create pod %s", pod.Name)
 	}
-	return nil
+	if shortage != nil {
+		r.Recorder.Eventf(batchSandbox, corev1.EventTypeWarning, reasonInsufficientQuota, "%d shard(s) waiting for quota: %s", pending, shortage)
+		DurationStore.Push(types.NamespacedName{Namespace: batchSandbox.Namespace, Name: batchSandbox.Name}.String(), quotaRetryInterval)
+		return quotaCondition(batchSandbox, shortage, pending), nil
+	}
+	if meta.FindStatusCondition(batchSandbox.Status.Conditions, sandboxv1alpha1.BatchSandboxConditionQuotaAvailable) != nil {
+		return quotaCondition(batchSandbox, nil, 0), nil
+	}
+	return nil, nil
+}
+
+// applyTaskPriorityClass schedules a shard's Pod at its task's PriorityClass.
+// Tasks inherit the pod template's class when unset, so only a class set on the
+// task template or a shardTaskPatch overrides the Pod, including shardPatches.
+func applyTaskPriorityClass(pod *corev1.Pod, podTemplateSpec *corev1.PodTemplateSpec, task *api.Task) {
+	if task == nil || task.PriorityClassName == "" {
+		return
+	}
+	if podTemplateSpec != nil && task.PriorityClassName == podTemplateSpec.Spec.PriorityClassName {
+		return
+	}
+	pod.Spec.PriorityClassName = task.PriorityClassName
+	// admission resolves the numeric priority from the class; a stale value is rejected.
+	pod.Spec.Priority = nil
+}
+
+// applyTaskServiceAccount runs a shard's Pod as its task's ServiceAccount, so
+// the task's process gets that identity. Like the PriorityClass, only an
+// account set on the task template or a shardTaskPatch overrides the Pod.
+func applyTaskServiceAccount(pod *corev1.Pod, podTemplateSpec *corev1.PodTemplateSpec, task *api.Task) {
+	if task == nil || task.ServiceAccountName == "" {
+		return
+	}
+	if podTemplateSpec != nil && task.ServiceAccountName == podTemplateSpec.Spec.ServiceAccountName {
+		return
+	}
+	pod.Spec.ServiceAccountName = task.ServiceAccountName
+	// the deprecated alias would otherwise keep naming the old account
+	pod.Spec.DeprecatedServiceAccount = ""
 }
 
 func parseIndex(pod *corev1.Pod) (int, error) {
@@ -544,12 +821,66 @@ func (r *BatchSandboxReconciler) updateStatus(batchSandbox *sandboxv1alpha1.Batc
 	})
 }
 
+// reasonSpecValid is the SpecValid condition's reason once a rejected spec was fixed.
+const reasonSpecValid = "SpecValid"
+
+// setCondition records cond on the BatchSandbox when it changed.
+func (r *BatchSandboxReconciler) setCondition(batchSbx *sandboxv1alpha1.BatchSandbox, cond metav1.Condition) error {
+	cond.ObservedGeneration = batchSbx.Generation
+	newStatus := batchSbx.Status.DeepCopy()
+	if !meta.SetStatusCondition(&newStatus.Conditions, cond) {
+		return nil
+	}
+	if err := r.updateStatus(batchSbx, newStatus); err != nil {
+		return err
+	}
+	batchSbx.Status = *newStatus
+	return nil
+}
+
+// rejectSpec reports why the spec cannot be acted on as a warning event and on
+// the SpecValid condition, where it stays until the spec is fixed.
+func (r *BatchSandboxReconciler) rejectSpec(batchSbx *sandboxv1alpha1.BatchSandbox, reason, message string) error {
+	r.Recorder.Event(batchSbx, corev1.EventTypeWarning, reason, message)
+	return r.setCondition(batchSbx, metav1.Condition{
+		Type:    sandboxv1alpha1.BatchSandboxConditionSpecValid,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// acceptSpec clears what rejectSpec reported once the spec passed validation.
+// A BatchSandbox whose spec was never rejected gets no SpecValid condition.
+func (r *BatchSandboxReconciler) acceptSpec(batchSbx *sandboxv1alpha1.BatchSandbox) error {
+	if meta.FindStatusCondition(batchSbx.Status.Conditions, sandboxv1alpha1.BatchSandboxConditionSpecValid) == nil {
+		return nil
+	}
+	return r.setCondition(batchSbx, metav1.Condition{
+		Type:    sandboxv1alpha1.BatchSandboxConditionSpecValid,
+		Status:  metav1.ConditionTrue,
+		Reason:  reasonSpecValid,
+		Message: "the spec is valid",
+	})
+}
+
+func (r *BatchSandboxReconciler) maxConcurrentReconciles() int {
+	if r.MaxConcurrentReconciles > 0 {
+		return r.MaxConcurrentReconciles
+	}
+	return DefaultBatchSandboxConcurrentReconciles
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *BatchSandboxReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&sandboxv1alpha1.BatchSandbox{}).
 		Named("batchsandbox").
 		Owns(&corev1.Pod{}).
-		WithOptions(controller.Options{MaxConcurrentReconciles: 32}).
+		Owns(&batchv1.Job{}).
+		Owns(&policyv1.PodDisruptionBudget{}).
+		Owns(&appsv1.DaemonSet{}).
+		WithEventFilter(r.NamespaceFilter.Predicate()).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.maxConcurrentReconciles()}).
 		Complete(r)
 }
@@ -22,6 +22,7 @@ import (
 	"net"
 	"reflect"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -29,8 +30,10 @@ import (
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
@@ -41,6 +44,7 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
+	testingclock "k8s.io/utils/clock/testing"
 	"k8s.io/utils/ptr"
 	"k8s.io/utils/set"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -52,11 +56,14 @@ import (
 	taskscheduler "github.com/alibaba/OpenSandbox/sandbox-k8s/internal/scheduler"
 	mock_scheduler "github.com/alibaba/OpenSandbox/sandbox-k8s/internal/scheduler/mock"
 	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/utils/fieldindex"
+	api "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
 )
 
 func init() {
 	testscheme = k8sruntime.NewScheme()
 	utilruntime.Must(corev1.AddToScheme(testscheme))
+	utilruntime.Must(policyv1.AddToScheme(testscheme))
+	utilruntime.Must(appsv1.AddToScheme(testscheme))
 	utilruntime.Must(sandboxv1alpha1.AddToScheme(testscheme))
 }
 
@@ -605,6 +612,24 @@ func TestBatchSandboxReconciler_scheduleTasks(t *testing.T) {
 			Spec:   sandboxv1alpha1.BatchSandboxSpec{},
 			Status: sandboxv1alpha1.BatchSandboxStatus{},
 		}
+		canaryBatchSandbox = &sandboxv1alpha1.BatchSandbox{
+			TypeMeta: fakeBatchSandbox.TypeMeta,
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-canary-batch-sandbox",
+			},
+			Spec: sandboxv1alpha1.BatchSandboxSpec{
+				TaskCanary: &sandboxv1alpha1.TaskCanarySpec{},
+			},
+		}
+		anyBatchSandbox = &sandboxv1alpha1.BatchSandbox{
+			TypeMeta: fakeBatchSandbox.TypeMeta,
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-any-batch-sandbox",
+			},
+			Spec: sandboxv1alpha1.BatchSandboxSpec{
+				TaskCompletionPolicy: &sandboxv1alpha1.TaskCompletionPolicySpec{Type: sandboxv1alpha1.TaskCompletionPolicyAny},
+			},
+		}
 	)
 	type fields struct {
 		Client         client.Client
@@ -613,9 +638,10 @@ func TestBatchSandboxReconciler_scheduleTasks(t *testing.T) {
 		taskSchedulers sync.Map
 	}
 	type args struct {
-		ctx      context.Context
-		tSch     taskscheduler.TaskScheduler
-		batchSbx *sandboxv1alpha1.BatchSandbox
+		ctx          context.Context
+		tSch         taskscheduler.TaskScheduler
+		batchSbx     *sandboxv1alpha1.BatchSandbox
+		taskStrategy strategy.TaskSchedulingStrategy
 	}
 	tests := []struct {
 		name                string
@@ -629,9 +655,13 @@ func TestBatchSandboxReconciler_scheduleTasks(t *testing.T) {
 			args: args{
 				tSch: func() taskscheduler.TaskScheduler {
 					mockSche := mock_scheduler.NewMockTaskScheduler(ctrl)
+					mockSche.EXPECT().ListTask().Return(nil).Times(1)
+					mockSche.EXPECT().SetAdmitted(-1).Times(1)
 					mockSche.EXPECT().Schedule().Return(gerrors.New("err")).Times(1)
 					return mockSche
 				}(),
+				batchSbx:     fakeBatchSandbox.DeepCopy(),
+				taskStrategy: strategy.NewDefaultTaskSchedulingStrategy(fakeBatchSandbox),
 			},
 			wantErr: true,
 		},
@@ -643,15 +673,21 @@ func TestBatchSandboxReconciler_scheduleTasks(t *testing.T) {
 			args: args{
 				tSch: func() taskscheduler.TaskScheduler {
 					mockSche := mock_scheduler.NewMockTaskScheduler(ctrl)
+					mockSche.EXPECT().SetAdmitted(-1).Times(1)
 					mockSche.EXPECT().Schedule().Return(nil).Times(1)
 					mockTask := mock_scheduler.NewMockTask(ctrl)
 					mockTask.EXPECT().GetState().Return(taskscheduler.SucceedTaskState).Times(1)
 					mockTask.EXPECT().IsResourceReleased().Return(true).Times(1)
+					mockTask.EXPECT().IsReady().Return(false).AnyTimes()
+					mockTask.EXPECT().IsDraining().Return(false).AnyTimes()
+					mockTask.EXPECT().IsOutdated().Return(false).AnyTimes()
+					mockTask.EXPECT().IsUpdating().Return(false).AnyTimes()
 					mockTask.EXPECT().GetPodName().Return("pod-0").AnyTimes()
-					mockSche.EXPECT().ListTask().Return([]taskscheduler.Task{mockTask}).Times(1)
+					mockSche.EXPECT().ListTask().Return([]taskscheduler.Task{mockTask}).Times(2)
 					return mockSche
 				}(),
-				batchSbx: fakeBatchSandbox.DeepCopy(),
+				batchSbx:     fakeBatchSandbox.DeepCopy(),
+				taskStrategy: strategy.NewDefaultTaskSchedulingStrategy(fakeBatchSandbox),
 			},
 			batchSandboxChecker: func(bsbx *sandboxv1alpha1.BatchSandbox) error {
 				release, err := parseSandboxReleased(bsbx)
@@ -671,6 +707,133 @@ func TestBatchSandboxReconciler_scheduleTasks(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			name: "tasks, running=2; ready=1",
+			fields: fields{
+				Client: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(fakeBatchSandbox).WithStatusSubresource(fakeBatchSandbox).Build(),
+			},
+			args: args{
+				tSch: func() taskscheduler.TaskScheduler {
+					mockSche := mock_scheduler.NewMockTaskScheduler(ctrl)
+					mockSche.EXPECT().SetAdmitted(-1).Times(1)
+					mockSche.EXPECT().Schedule().Return(nil).Times(1)
+					var tasks []taskscheduler.Task
+					for i, ready := range []bool{true, false} {
+						mockTask := mock_scheduler.NewMockTask(ctrl)
+						mockTask.EXPECT().GetState().Return(taskscheduler.RunningTaskState).AnyTimes()
+						mockTask.EXPECT().IsResourceReleased().Return(false).AnyTimes()
+						mockTask.EXPECT().IsReady().Return(ready).AnyTimes()
+						mockTask.EXPECT().IsDraining().Return(false).AnyTimes()
+						mockTask.EXPECT().IsOutdated().Return(false).AnyTimes()
+						mockTask.EXPECT().IsUpdating().Return(false).AnyTimes()
+						mockTask.EXPECT().GetPodName().Return(fmt.Sprintf("pod-%d", i)).AnyTimes()
+						tasks = append(tasks, mockTask)
+					}
+					mockSche.EXPECT().ListTask().Return(tasks).Times(2)
+					return mockSche
+				}(),
+				batchSbx:     fakeBatchSandbox.DeepCopy(),
+				taskStrategy: strategy.NewDefaultTaskSchedulingStrategy(fakeBatchSandbox),
+			},
+			batchSandboxChecker: func(bsbx *sandboxv1alpha1.BatchSandbox) error {
+				if bsbx.Status.TaskRunning != 2 || bsbx.Status.TaskReady != 1 {
+					return fmt.Errorf("expect status.running=2,ready=1, actual %v", bsbx.Status)
+				}
+				shards := bsbx.Status.TaskShards
+				if len(shards) != 2 || !shards[0].Ready || shards[1].Ready || shards[1].Index != 1 || shards[1].PodName != "pod-1" {
+					return fmt.Errorf("expect shard 0 ready and shard 1 not, actual %+v", shards)
+				}
+				return nil
+			},
+		},
+		{
+			name: "canary failed, remaining tasks are aborted",
+			fields: fields{
+				Client:   fake.NewClientBuilder().WithScheme(testscheme).WithObjects(canaryBatchSandbox).WithStatusSubresource(canaryBatchSandbox).Build(),
+				Recorder: record.NewFakeRecorder(10),
+			},
+			args: args{
+				tSch: func() taskscheduler.TaskScheduler {
+					mockSche := mock_scheduler.NewMockTaskScheduler(ctrl)
+					canaryTask := mock_scheduler.NewMockTask(ctrl)
+					canaryTask.EXPECT().GetName().Return("test-canary-batch-sandbox-0").AnyTimes()
+					canaryTask.EXPECT().GetPodName().Return("pod-0").AnyTimes()
+					canaryTask.EXPECT().GetState().Return(taskscheduler.FailedTaskState).AnyTimes()
+					canaryTask.EXPECT().IsResourceReleased().Return(false).AnyTimes()
+					canaryTask.EXPECT().IsReady().Return(false).AnyTimes()
+					canaryTask.EXPECT().IsDraining().Return(false).AnyTimes()
+					canaryTask.EXPECT().IsOutdated().Return(false).AnyTimes()
+					canaryTask.EXPECT().IsUpdating().Return(false).AnyTimes()
+					pendingTask := mock_scheduler.NewMockTask(ctrl)
+					pendingTask.EXPECT().GetPodName().Return("").AnyTimes()
+					mockSche.EXPECT().ListTask().Return([]taskscheduler.Task{canaryTask, pendingTask}).Times(2)
+					mockSche.EXPECT().SetAdmitted(1).Times(1)
+					mockSche.EXPECT().StopTask().Times(1)
+					mockSche.EXPECT().Schedule().Return(nil).Times(1)
+					return mockSche
+				}(),
+				batchSbx:     canaryBatchSandbox.DeepCopy(),
+				taskStrategy: strategy.NewTaskSchedulingStrategy(canaryBatchSandbox),
+			},
+			batchSandboxChecker: func(bsbx *sandboxv1alpha1.BatchSandbox) error {
+				canary := bsbx.Status.TaskCanary
+				if canary == nil || canary.Phase != sandboxv1alpha1.TaskCanaryFailed || canary.StartTime == nil {
+					return fmt.Errorf("expect failed canary with start time, actual %+v", canary)
+				}
+				if bsbx.Status.TaskFailed != 1 || bsbx.Status.TaskPending != 1 {
+					return fmt.Errorf("expect status.failed=1,pending=1, actual %v", bsbx.Status)
+				}
+				shards := bsbx.Status.TaskShards
+				if len(shards) != 2 || shards[0].PodName != "pod-0" || shards[0].State != string(taskscheduler.FailedTaskState) || shards[1].State != "" {
+					return fmt.Errorf("expect per-shard status for the failed canary and the pending shard, actual %+v", shards)
+				}
+				return nil
+			},
+		},
+		{
+			name: "any policy met, unfinished tasks are stopped",
+			fields: fields{
+				Client:   fake.NewClientBuilder().WithScheme(testscheme).WithObjects(anyBatchSandbox).WithStatusSubresource(anyBatchSandbox).Build(),
+				Recorder: record.NewFakeRecorder(10),
+			},
+			args: args{
+				tSch: func() taskscheduler.TaskScheduler {
+					mockSche := mock_scheduler.NewMockTaskScheduler(ctrl)
+					var tasks []taskscheduler.Task
+					for i, state := range []taskscheduler.TaskState{taskscheduler.FailedTaskState, taskscheduler.SucceedTaskState, taskscheduler.RunningTaskState} {
+						mockTask := mock_scheduler.NewMockTask(ctrl)
+						mockTask.EXPECT().GetPodName().Return(fmt.Sprintf("pod-%d", i)).AnyTimes()
+						mockTask.EXPECT().GetState().Return(state).AnyTimes()
+						mockTask.EXPECT().IsResourceReleased().Return(false).AnyTimes()
+						mockTask.EXPECT().IsReady().Return(state == taskscheduler.RunningTaskState).AnyTimes()
+						mockTask.EXPECT().IsDraining().Return(false).AnyTimes()
+						mockTask.EXPECT().IsOutdated().Return(false).AnyTimes()
+						mockTask.EXPECT().IsUpdating().Return(false).AnyTimes()
+						tasks = append(tasks, mockTask)
+					}
+					pendingTask := mock_scheduler.NewMockTask(ctrl)
+					pendingTask.EXPECT().GetPodName().Return("").AnyTimes()
+					tasks = append(tasks, pendingTask)
+					mockSche.EXPECT().ListTask().Return(tasks).Times(2)
+					mockSche.EXPECT().SetAdmitted(0).Times(1)
+					mockSche.EXPECT().StopUnfinishedTask().Return(tasks[2:]).Times(1)
+					mockSche.EXPECT().Schedule().Return(nil).Times(1)
+					return mockSche
+				}(),
+				batchSbx:     anyBatchSandbox.DeepCopy(),
+				taskStrategy: strategy.NewTaskSchedulingStrategy(anyBatchSandbox),
+			},
+			batchSandboxChecker: func(bsbx *sandboxv1alpha1.BatchSandbox) error {
+				completion := bsbx.Status.TaskCompletion
+				if completion == nil || completion.Phase != sandboxv1alpha1.TaskCompletionSucceeded || completion.CompletionTime == nil {
+					return fmt.Errorf("expect a succeeded completion with a time, actual %+v", completion)
+				}
+				if bsbx.Status.TaskSucceed != 1 || bsbx.Status.TaskFailed != 1 || bsbx.Status.TaskRunning != 1 || bsbx.Status.TaskPending != 1 {
+					return fmt.Errorf("expect status.succeed=1,failed=1,running=1,pending=1, actual %v", bsbx.Status)
+				}
+				return nil
+			},
+		},
 	}
 	for i := range tests {
 		tt := &tests[i]
@@ -680,7 +843,7 @@ func TestBatchSandboxReconciler_scheduleTasks(t *testing.T) {
 				Scheme:   tt.fields.Scheme,
 				Recorder: tt.fields.Recorder,
 			}
-			if err := r.scheduleTasks(tt.args.ctx, tt.args.tSch, tt.args.batchSbx); (err != nil) != tt.wantErr {
+			if err := r.scheduleTasks(tt.args.ctx, tt.args.tSch, tt.args.batchSbx, tt.args.taskStrategy); (err != nil) != tt.wantErr {
 				t.Errorf("BatchSandboxReconciler.scheduleTasks() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			if tt.batchSandboxChecker != nil {
@@ -696,6 +859,188 @@ func TestBatchSandboxReconciler_scheduleTasks(t *testing.T) {
 	}
 }
 
+func TestBatchSandboxReconciler_scheduleTasksSuspend(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	batchSbx := &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-suspend-batch-sandbox"},
+		Spec:       sandboxv1alpha1.BatchSandboxSpec{Suspend: true},
+	}
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(batchSbx).WithStatusSubresource(batchSbx).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &BatchSandboxReconciler{Client: c, Recorder: recorder}
+
+	newTask := func(podName string, state taskscheduler.TaskState) taskscheduler.Task {
+		task := mock_scheduler.NewMockTask(ctrl)
+		task.EXPECT().GetPodName().Return(podName).AnyTimes()
+		task.EXPECT().GetState().Return(state).AnyTimes()
+		task.EXPECT().IsReady().Return(state == taskscheduler.RunningTaskState).AnyTimes()
+		task.EXPECT().IsDraining().Return(false).AnyTimes()
+		task.EXPECT().IsOutdated().Return(false).AnyTimes()
+		task.EXPECT().IsUpdating().Return(false).AnyTimes()
+		task.EXPECT().IsResourceReleased().Return(false).AnyTimes()
+		return task
+	}
+	// round runs one scheduling round over tasks, expecting admitted to be passed
+	// to the scheduler, and returns the stored BatchSandbox.
+	round := func(suspend bool, admitted int, tasks ...taskscheduler.Task) *sandboxv1alpha1.BatchSandbox {
+		t.Helper()
+		current := &sandboxv1alpha1.BatchSandbox{}
+		if err := c.Get(context.Background(), client.ObjectKeyFromObject(batchSbx), current); err != nil {
+			t.Fatalf("get BatchSandbox: %v", err)
+		}
+		current.Spec.Suspend = suspend
+		sch := mock_scheduler.NewMockTaskScheduler(ctrl)
+		sch.EXPECT().ListTask().Return(tasks).Times(2)
+		sch.EXPECT().SetAdmitted(admitted).Times(1)
+		sch.EXPECT().Schedule().Return(nil).Times(1)
+		if err := r.scheduleTasks(context.Background(), sch, current, strategy.NewTaskSchedulingStrategy(current)); err != nil {
+			t.Fatalf("scheduleTasks: %v", err)
+		}
+		if err := c.Get(context.Background(), client.ObjectKeyFromObject(batchSbx), current); err != nil {
+			t.Fatalf("get BatchSandbox: %v", err)
+		}
+		return current
+	}
+	expectEvent := func(reason string) {
+		t.Helper()
+		select {
+		case ev := <-recorder.Events:
+			if !strings.Contains(ev, reason) {
+				t.Fatalf("expected a %s event, got %q", reason, ev)
+			}
+		default:
+			t.Fatalf("expected a %s event", reason)
+		}
+	}
+
+	// created suspended: nothing is assigned
+	got := round(true, 0, newTask("", ""), newTask("", ""))
+	if !got.Status.TaskSuspended || got.Status.TaskPending != 2 {
+		t.Fatalf("expected 2 pending tasks held back, got %+v", got.Status)
+	}
+	expectEvent("TaskSuspended")
+
+	// resumed: every task is admitted
+	got = round(false, -1, newTask("pod-0", taskscheduler.RunningTaskState), newTask("", ""))
+	if got.Status.TaskSuspended || got.Status.TaskRunning != 1 || got.Status.TaskPending != 1 {
+		t.Fatalf("expected a resumed batch, got %+v", got.Status)
+	}
+	expectEvent("TaskResumed")
+
+	// suspended mid-run: the running task is kept, the pending one held back
+	got = round(true, 0, newTask("pod-0", taskscheduler.RunningTaskState), newTask("", ""))
+	if !got.Status.TaskSuspended || got.Status.TaskRunning != 1 || got.Status.TaskPending != 1 {
+		t.Fatalf("expected the running task kept while suspended, got %+v", got.Status)
+	}
+	expectEvent("TaskSuspended")
+}
+func TestBatchSandboxReconciler_scheduleTasksShardTimes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	fakeClock := testingclock.NewFakeClock(start)
+	defer func(old func() time.Time) { timeNow = old }(timeNow)
+	timeNow = fakeClock.Now
+
+	batchSbx := &sandboxv1alpha1.BatchSandbox{ObjectMeta: metav1.ObjectMeta{Name: "test-timed-batch-sandbox"}}
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(batchSbx).WithStatusSubresource(batchSbx).Build()
+	r := &BatchSandboxReconciler{Client: c, Recorder: record.NewFakeRecorder(10)}
+
+	newTask := func(podName string, state taskscheduler.TaskState) taskscheduler.Task {
+		task := mock_scheduler.NewMockTask(ctrl)
+		task.EXPECT().GetPodName().Return(podName).AnyTimes()
+		task.EXPECT().GetState().Return(state).AnyTimes()
+		task.EXPECT().IsReady().Return(false).AnyTimes()
+		task.EXPECT().IsDraining().Return(false).AnyTimes()
+		task.EXPECT().IsOutdated().Return(false).AnyTimes()
+		task.EXPECT().IsUpdating().Return(false).AnyTimes()
+		task.EXPECT().IsResourceReleased().Return(false).AnyTimes()
+		return task
+	}
+	// round runs one scheduling round at the fake clock's time and returns the stored status.
+	round := func(tasks ...taskscheduler.Task) sandboxv1alpha1.BatchSandboxStatus {
+		t.Helper()
+		current := &sandboxv1alpha1.BatchSandbox{}
+		if err := c.Get(context.Background(), client.ObjectKeyFromObject(batchSbx), current); err != nil {
+			t.Fatalf("get BatchSandbox: %v", err)
+		}
+		sch := mock_scheduler.NewMockTaskScheduler(ctrl)
+		sch.EXPECT().ListTask().Return(tasks).Times(2)
+		sch.EXPECT().SetAdmitted(-1).Times(1)
+		sch.EXPECT().Schedule().Return(nil).Times(1)
+		if err := r.scheduleTasks(context.Background(), sch, current, strategy.NewTaskSchedulingStrategy(current)); err != nil {
+			t.Fatalf("scheduleTasks: %v", err)
+		}
+		if err := c.Get(context.Background(), client.ObjectKeyFromObject(batchSbx), current); err != nil {
+			t.Fatalf("get BatchSandbox: %v", err)
+		}
+		return current.Status
+	}
+	at := func(offset time.Duration) *metav1.Time { return &metav1.Time{Time: start.Add(offset)} }
+	expectTime := func(what string, got, want *metav1.Time) {
+		t.Helper()
+		if (got == nil) != (want == nil) || (got != nil && !got.Equal(want)) {
+			t.Fatalf("%s = %v, want %v", what, got, want)
+		}
+	}
+
+	status := round(newTask("", ""), newTask("", ""))
+	expectTime("pending shard 0 start", status.TaskShards[0].StartTime, nil)
+	expectTime("batch start", status.TaskStartTime, nil)
+
+	fakeClock.Step(10 * time.Second)
+	status = round(newTask("pod-0", taskscheduler.RunningTaskState), newTask("", ""))
+	expectTime("shard 0 start", status.TaskShards[0].StartTime, at(10*time.Second))
+	expectTime("shard 1 start", status.TaskShards[1].StartTime, nil)
+	expectTime("batch start", status.TaskStartTime, at(10*time.Second))
+
+	fakeClock.Step(20 * time.Second)
+	status = round(newTask("pod-0", taskscheduler.SucceedTaskState), newTask("pod-1", taskscheduler.RunningTaskState))
+	expectTime("shard 0 start", status.TaskShards[0].StartTime, at(10*time.Second))
+	expectTime("shard 0 finish", status.TaskShards[0].FinishTime, at(30*time.Second))
+	expectTime("shard 1 start", status.TaskShards[1].StartTime, at(30*time.Second))
+	expectTime("batch finish", status.TaskFinishTime, nil)
+
+	fakeClock.Step(time.Minute)
+	status = round(newTask("pod-0", taskscheduler.SucceedTaskState), newTask("pod-1", taskscheduler.FailedTaskState))
+	expectTime("shard 0 finish", status.TaskShards[0].FinishTime, at(30*time.Second))
+	expectTime("shard 1 finish", status.TaskShards[1].FinishTime, at(90*time.Second))
+	expectTime("batch start", status.TaskStartTime, at(10*time.Second))
+	expectTime("batch finish", status.TaskFinishTime, at(90*time.Second))
+
+	// later rounds keep the first observation
+	fakeClock.Step(time.Hour)
+	status = round(newTask("pod-0", taskscheduler.SucceedTaskState), newTask("pod-1", taskscheduler.FailedTaskState))
+	expectTime("shard 1 finish", status.TaskShards[1].FinishTime, at(90*time.Second))
+	expectTime("batch finish", status.TaskFinishTime, at(90*time.Second))
+}
+
+func Test_stampShardTimes(t *testing.T) {
+	now := metav1.NewTime(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	shard := sandboxv1alpha1.TaskShardStatus{Index: sandboxv1alpha1.MaxTaskShardTimestamps, PodName: "pod", State: string(taskscheduler.SucceedTaskState)}
+	stampShardTimes(&shard, nil, now)
+	if shard.StartTime != nil || shard.FinishTime != nil {
+		t.Fatalf("expected no timestamps beyond the first %d shards, got %+v", sandboxv1alpha1.MaxTaskShardTimestamps, shard)
+	}
+}
+
+func Test_stampShardTimes_Drained(t *testing.T) {
+	before := metav1.NewTime(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	now := metav1.NewTime(before.Add(time.Hour))
+	last := []sandboxv1alpha1.TaskShardStatus{{Index: 0, PodName: "pod-0", Draining: true, StartTime: &before}}
+	shard := sandboxv1alpha1.TaskShardStatus{Index: 0}
+	stampShardTimes(&shard, last, now)
+	if shard.StartTime != nil {
+		t.Fatalf("a drained shard should start over, got start time %v", shard.StartTime)
+	}
+	shard = sandboxv1alpha1.TaskShardStatus{Index: 0, PodName: "pod-0", Draining: true}
+	stampShardTimes(&shard, last, now)
+	if shard.StartTime == nil || !shard.StartTime.Equal(&before) {
+		t.Fatalf("a draining shard should keep its start time, got %v", shard.StartTime)
+	}
+}
+
 func Test_parseIndex(t *testing.T) {
 	type args struct {
 		pod *corev1.Pod
@@ -744,6 +1089,88 @@ func Test_parseIndex(t *testing.T) {
 	}
 }
 
+func Test_applyTaskPriorityClass(t *testing.T) {
+	template := &corev1.PodTemplateSpec{Spec: corev1.PodSpec{PriorityClassName: "pod-priority"}}
+	tests := []struct {
+		name     string
+		pod      *corev1.Pod
+		task     *api.Task
+		want     string
+		wantNilP bool
+	}{
+		{
+			name:     "task class overrides pod",
+			pod:      &corev1.Pod{Spec: corev1.PodSpec{PriorityClassName: "pod-priority", Priority: ptr.To[int32](100)}},
+			task:     &api.Task{PriorityClassName: "batch-high"},
+			want:     "batch-high",
+			wantNilP: true,
+		},
+		{
+			name: "inherited class keeps shard pod patch",
+			pod:  &corev1.Pod{Spec: corev1.PodSpec{PriorityClassName: "shard-patched"}},
+			task: &api.Task{PriorityClassName: "pod-priority"},
+			want: "shard-patched",
+		},
+		{
+			name: "no task class",
+			pod:  &corev1.Pod{Spec: corev1.PodSpec{PriorityClassName: "pod-priority"}},
+			task: &api.Task{},
+			want: "pod-priority",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applyTaskPriorityClass(tt.pod, template, tt.task)
+			if tt.pod.Spec.PriorityClassName != tt.want {
+				t.Errorf("applyTaskPriorityClass() priorityClassName = %q, want %q", tt.pod.Spec.PriorityClassName, tt.want)
+			}
+			if tt.wantNilP && tt.pod.Spec.Priority != nil {
+				t.Errorf("applyTaskPriorityClass() expected priority to be cleared, got %d", *tt.pod.Spec.Priority)
+			}
+		})
+	}
+}
+
+func Test_applyTaskServiceAccount(t *testing.T) {
+	template := &corev1.PodTemplateSpec{Spec: corev1.PodSpec{ServiceAccountName: "default-sa"}}
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+		task *api.Task
+		want string
+	}{
+		{
+			name: "task account overrides pod",
+			pod:  &corev1.Pod{Spec: corev1.PodSpec{ServiceAccountName: "default-sa", DeprecatedServiceAccount: "default-sa"}},
+			task: &api.Task{ServiceAccountName: "shard-1-sa"},
+			want: "shard-1-sa",
+		},
+		{
+			name: "inherited account keeps shard pod patch",
+			pod:  &corev1.Pod{Spec: corev1.PodSpec{ServiceAccountName: "shard-patched"}},
+			task: &api.Task{ServiceAccountName: "default-sa"},
+			want: "shard-patched",
+		},
+		{
+			name: "no task account",
+			pod:  &corev1.Pod{Spec: corev1.PodSpec{ServiceAccountName: "default-sa"}},
+			task: &api.Task{},
+			want: "default-sa",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applyTaskServiceAccount(tt.pod, template, tt.task)
+			if tt.pod.Spec.ServiceAccountName != tt.want {
+				t.Errorf("applyTaskServiceAccount() serviceAccountName = %q, want %q", tt.pod.Spec.ServiceAccountName, tt.want)
+			}
+			if tt.pod.Spec.DeprecatedServiceAccount != "" && tt.pod.Spec.DeprecatedServiceAccount != tt.want {
+				t.Errorf("applyTaskServiceAccount() left serviceAccount = %q", tt.pod.Spec.DeprecatedServiceAccount)
+			}
+		})
+	}
+}
+
 func Test_calPodIndex(t *testing.T) {
 	type args struct {
 		batchSbx *sandboxv1alpha1.BatchSandbox
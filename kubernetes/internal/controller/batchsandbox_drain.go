@@ -0,0 +1,101 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	taskscheduler "github.com/alibaba/OpenSandbox/sandbox-k8s/internal/scheduler"
+)
+
+const (
+	// defaultShardDrainGracePeriod is how long a drain waits for the shard's
+	// task to stop before the Pod is deleted regardless.
+	defaultShardDrainGracePeriod = 30 * time.Second
+
+	reasonShardDrained      = "ShardDrained"
+	reasonInvalidShardDrain = "InvalidShardDrain"
+	reasonShardDrainSkipped = "ShardDrainSkipped"
+)
+
+// parseShardDrainGracePeriod reads the value of AnnoShardDrainKey: empty for
+// the default grace period, or a number of seconds.
+func parseShardDrainGracePeriod(raw string) (time.Duration, error) {
+	if raw == "" {
+		return defaultShardDrainGracePeriod, nil
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("%s must be a number of seconds, got %q", AnnoShardDrainKey, raw)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// shardDrainSkipReason tells why the shards of batchSbx cannot be drained, or
+// returns "" when they can. A pooled Pod belongs to its pool and is not
+// recreated under its index, and a Pod template that never restarts asks for
+// each shard's task to run only once, so a stopped task is not run again.
+func shardDrainSkipReason(batchSbx *sandboxv1alpha1.BatchSandbox, pooled bool) string {
+	if pooled {
+		return "shards of a pooled BatchSandbox are not recreated under their index"
+	}
+	if batchSbx.Spec.Template != nil && batchSbx.Spec.Template.Spec.RestartPolicy == corev1.RestartPolicyNever {
+		return "the Pod template's restartPolicy is Never, so a stopped task is not run again"
+	}
+	return ""
+}
+
+// drainShards drains the shard Pods marked with AnnoShardDrainKey. The task
+// scheduler stops each one's task and puts it back to pending; once it has
+// stopped, or the grace period has passed, the Pod is deleted. scaleBatchSandbox
+// then recreates the Pod under the same index, with its shard patch, and the
+// task is assigned again. The Pod is best cordoned off its node first, so its
+// replacement lands elsewhere. When shardDrainSkipReason rules the drain out,
+// marked Pods are left running and a ShardDrainSkipped event says why.
+func (r *BatchSandboxReconciler) drainShards(ctx context.Context, batchSbx *sandboxv1alpha1.BatchSandbox, tSch taskscheduler.TaskScheduler, pods []*corev1.Pod, pooled bool) error {
+	skip := shardDrainSkipReason(batchSbx, pooled)
+	for _, pod := range pods {
+		raw, ok := pod.Annotations[AnnoShardDrainKey]
+		if !ok || pod.DeletionTimestamp != nil {
+			continue
+		}
+		if skip != "" {
+			r.Recorder.Eventf(batchSbx, corev1.EventTypeWarning, reasonShardDrainSkipped, "pod %s is not drained: %s", pod.Name, skip)
+			continue
+		}
+		grace, err := parseShardDrainGracePeriod(raw)
+		if err != nil {
+			r.Recorder.Eventf(batchSbx, corev1.EventTypeWarning, reasonInvalidShardDrain, "pod %s: %v", pod.Name, err)
+			continue
+		}
+		if !tSch.DrainPod(pod.Name, grace) {
+			klog.Infof("BatchSandbox %s is draining Pod %s, waiting for its task to stop", klog.KObj(batchSbx), pod.Name)
+			continue
+		}
+		if err := r.Delete(ctx, pod); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete drained pod %s: %w", pod.Name, err)
+		}
+		r.Recorder.Eventf(batchSbx, corev1.EventTypeNormal, reasonShardDrained, "drained pod %s, its shard is recreated under the same index", pod.Name)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/controller/strategy"
+	mock_scheduler "github.com/alibaba/OpenSandbox/sandbox-k8s/internal/scheduler/mock"
+)
+
+func TestDrainShards_RecreatesTheShardUnderItsIndex(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	batchSbx := &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "drain", Name: "drain", UID: types.UID("drain-uid")},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			Replicas: ptr.To[int32](2),
+			Template: &corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: "busybox"}}},
+			},
+			ShardPatches: []runtime.RawExtension{
+				{Raw: []byte(`{}`)},
+				{Raw: []byte(`{"metadata":{"labels":{"zone":"b"}}}`)},
+			},
+		},
+	}
+	shard := func(idx string, annotations map[string]string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "drain",
+			Name:        "drain-" + idx,
+			Labels:      map[string]string{LabelBatchSandboxPodIndexKey: idx, "zone": "a"},
+			Annotations: annotations,
+		}}
+	}
+	pod0 := shard("0", nil)
+	pod1 := shard("1", map[string]string{AnnoShardDrainKey: "10"})
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(batchSbx, pod0, pod1).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &BatchSandboxReconciler{Client: c, Scheme: testscheme, Recorder: recorder}
+	defer DurationStore.Pop(types.NamespacedName{Namespace: "drain", Name: "drain"}.String())
+
+	sch := mock_scheduler.NewMockTaskScheduler(ctrl)
+	gomock.InOrder(
+		sch.EXPECT().DrainPod("drain-1", 10*time.Second).Return(false),
+		sch.EXPECT().DrainPod("drain-1", 10*time.Second).Return(true),
+	)
+	pods := []*corev1.Pod{pod0, pod1}
+	require.NoError(t, r.drainShards(context.Background(), batchSbx, sch, pods, false))
+	assert.ElementsMatch(t, []string{"drain-0", "drain-1"}, drainShardNames(t, c), "the Pod is kept while its task stops")
+
+	require.NoError(t, r.drainShards(context.Background(), batchSbx, sch, pods, false))
+	assert.ElementsMatch(t, []string{"drain-0"}, drainShardNames(t, c))
+	require.Len(t, recorder.Events, 1)
+	assert.Equal(t, "Normal ShardDrained drained pod drain-1, its shard is recreated under the same index", <-recorder.Events)
+
+	_, err := r.scaleBatchSandbox(context.Background(), batchSbx, batchSbx.Spec.Template, strategy.NewTaskSchedulingStrategy(batchSbx), []*corev1.Pod{pod0})
+	require.NoError(t, err)
+	recreated := &corev1.Pod{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "drain", Name: "drain-1"}, recreated))
+	assert.Equal(t, "1", recreated.Labels[LabelBatchSandboxPodIndexKey])
+	assert.Equal(t, "b", recreated.Labels["zone"], "the shard patch applies to the recreated Pod")
+	assert.NotContains(t, recreated.Annotations, AnnoShardDrainKey)
+}
+
+func TestDrainShards_InvalidGracePeriod(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	batchSbx := &sandboxv1alpha1.BatchSandbox{ObjectMeta: metav1.ObjectMeta{Namespace: "drain", Name: "invalid"}}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "drain",
+		Name:        "invalid-0",
+		Annotations: map[string]string{AnnoShardDrainKey: "soon"},
+	}}
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(batchSbx, pod).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &BatchSandboxReconciler{Client: c, Recorder: recorder}
+
+	// the mock fails the test on any DrainPod call
+	require.NoError(t, r.drainShards(context.Background(), batchSbx, mock_scheduler.NewMockTaskScheduler(ctrl), []*corev1.Pod{pod}, false))
+	assert.ElementsMatch(t, []string{"invalid-0"}, drainShardNames(t, c))
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Warning InvalidShardDrain pod invalid-0")
+}
+
+func TestDrainShards_SkippedWhenThePolicyForbidsIt(t *testing.T) {
+	cases := []struct {
+		name     string
+		spec     sandboxv1alpha1.BatchSandboxSpec
+		pooled   bool
+		wantSkip string
+	}{
+		{
+			name:     "pooled",
+			spec:     sandboxv1alpha1.BatchSandboxSpec{PoolRef: "pool"},
+			pooled:   true,
+			wantSkip: "not recreated under their index",
+		},
+		{
+			name: "restart policy never",
+			spec: sandboxv1alpha1.BatchSandboxSpec{Template: &corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{RestartPolicy: corev1.RestartPolicyNever},
+			}},
+			wantSkip: "restartPolicy is Never",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			batchSbx := &sandboxv1alpha1.BatchSandbox{ObjectMeta: metav1.ObjectMeta{Namespace: "drain", Name: "skipped"}, Spec: tc.spec}
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "drain",
+				Name:        "skipped-0",
+				Annotations: map[string]string{AnnoShardDrainKey: ""},
+			}}
+			c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(batchSbx, pod).Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &BatchSandboxReconciler{Client: c, Recorder: recorder}
+
+			// the mock fails the test on any DrainPod call
+			require.NoError(t, r.drainShards(context.Background(), batchSbx, mock_scheduler.NewMockTaskScheduler(ctrl), []*corev1.Pod{pod}, tc.pooled))
+			assert.ElementsMatch(t, []string{"skipped-0"}, drainShardNames(t, c), "the Pod is left running")
+			require.Len(t, recorder.Events, 1)
+			event := <-recorder.Events
+			assert.Contains(t, event, "Warning ShardDrainSkipped pod skipped-0 is not drained")
+			assert.Contains(t, event, tc.wantSkip)
+		})
+	}
+}
+
+func TestParseShardDrainGracePeriod(t *testing.T) {
+	grace, err := parseShardDrainGracePeriod("")
+	require.NoError(t, err)
+	assert.Equal(t, defaultShardDrainGracePeriod, grace)
+	grace, err = parseShardDrainGracePeriod("0")
+	require.NoError(t, err)
+	assert.Zero(t, grace)
+	_, err = parseShardDrainGracePeriod("-5")
+	assert.Error(t, err)
+}
+
+func drainShardNames(t *testing.T, c client.Client) []string {
+	pods := &corev1.PodList{}
+	require.NoError(t, c.List(context.Background(), pods, client.InNamespace("drain")))
+	var names []string
+	for _, pod := range pods.Items {
+		names = append(names, pod.Name)
+	}
+	return names
+}
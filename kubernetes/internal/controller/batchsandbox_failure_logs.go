@@ -0,0 +1,118 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	taskscheduler "github.com/alibaba/OpenSandbox/sandbox-k8s/internal/scheduler"
+)
+
+const (
+	defaultFailureLogTailLines  = 100
+	defaultFailureLogLimitBytes = 16384
+
+	// failureLogsSuffix ends the name of the ConfigMap holding a failed task's logs.
+	failureLogsSuffix = "-failure-logs"
+
+	reasonTaskFailureLogs = "TaskFailureLogs"
+)
+
+// failureLogCapture returns what the task scheduler fetches of failed tasks'
+// logs, nil when the BatchSandbox keeps none.
+func failureLogCapture(batchSbx *sandboxv1alpha1.BatchSandbox) *taskscheduler.FailureLogCapture {
+	spec := batchSbx.Spec.TaskFailureLogs
+	if spec == nil {
+		return nil
+	}
+	capture := &taskscheduler.FailureLogCapture{TailLines: defaultFailureLogTailLines, LimitBytes: defaultFailureLogLimitBytes}
+	if spec.TailLines > 0 {
+		capture.TailLines = int(spec.TailLines)
+	}
+	if spec.LimitBytes > 0 {
+		capture.LimitBytes = int(spec.LimitBytes)
+	}
+	return capture
+}
+
+// syncShardFailureLogs carries the shard's failure logs ConfigMap over from the
+// last status, or stores the logs the scheduler captured for the task in it.
+// The ConfigMap is named after the task, so one left by an earlier failure of
+// the shard is overwritten rather than reported with stale logs. A ConfigMap
+// that cannot be written is retried on the next reconcile, while the scheduler
+// still holds the logs.
+func (r *BatchSandboxReconciler) syncShardFailureLogs(ctx context.Context, batchSbx *sandboxv1alpha1.BatchSandbox, shard *sandboxv1alpha1.TaskShardStatus, task taskscheduler.Task) {
+	if batchSbx.Spec.TaskFailureLogs == nil || shard.PodName == "" {
+		return
+	}
+	last := batchSbx.Status.TaskShards
+	if idx := int(shard.Index); idx < len(last) && last[idx].PodName == shard.PodName && last[idx].FailureLogs != "" {
+		shard.FailureLogs = last[idx].FailureLogs
+		return
+	}
+	logs := task.GetFailureLogs()
+	if logs == nil {
+		return
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: batchSbx.Namespace,
+			Name:      task.GetName() + failureLogsSuffix,
+			Labels:    map[string]string{LabelBatchSandboxNameKey: batchSbx.Name},
+		},
+		Data: map[string]string{"stdout": logs.Stdout, "stderr": logs.Stderr},
+	}
+	if logs.Truncated {
+		cm.Data["truncated"] = "true"
+	}
+	if err := ctrl.SetControllerReference(batchSbx, cm, r.Scheme); err != nil {
+		klog.Errorf("failed to own failure logs configmap of task %s: %v", task.GetName(), err)
+		return
+	}
+	if err := r.storeFailureLogs(ctx, batchSbx, cm); err != nil {
+		klog.Errorf("failed to store the failure logs of task %s of batchsandbox %s: %v", task.GetName(), klog.KObj(batchSbx), err)
+		return
+	}
+	shard.FailureLogs = cm.Name
+	r.Recorder.Eventf(batchSbx, corev1.EventTypeWarning, reasonTaskFailureLogs,
+		"shard %d failed on pod %s, its logs are in configmap %s", shard.Index, shard.PodName, cm.Name)
+}
+
+// storeFailureLogs creates cm, or replaces the data of the ConfigMap of that
+// name the BatchSandbox already owns.
+func (r *BatchSandboxReconciler) storeFailureLogs(ctx context.Context, batchSbx *sandboxv1alpha1.BatchSandbox, cm *corev1.ConfigMap) error {
+	err := r.Create(ctx, cm)
+	if !errors.IsAlreadyExists(err) {
+		return err
+	}
+	existing := &corev1.ConfigMap{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(cm), existing); err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(existing, batchSbx) {
+		return fmt.Errorf("configmap %s exists and is not owned by the batchsandbox", cm.Name)
+	}
+	existing.Data = cm.Data
+	return r.Update(ctx, existing)
+}
@@ -0,0 +1,131 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	mock_scheduler "github.com/alibaba/OpenSandbox/sandbox-k8s/internal/scheduler/mock"
+	api "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
+)
+
+func TestSyncShardFailureLogs(t *testing.T) {
+	batchSbx := &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "train", UID: "uid"},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			TaskFailureLogs: &sandboxv1alpha1.TaskFailureLogsSpec{},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(testscheme).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &BatchSandboxReconciler{Client: c, Scheme: testscheme, Recorder: recorder}
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	task := mock_scheduler.NewMockTask(ctrl)
+	task.EXPECT().GetName().Return("train-1").AnyTimes()
+	task.EXPECT().GetFailureLogs().Return(&api.TaskLogs{Stdout: "step 9\n", Stderr: "out of memory\n"}).AnyTimes()
+
+	shard := sandboxv1alpha1.TaskShardStatus{Index: 1, PodName: "train-1", State: "FAILED"}
+	r.syncShardFailureLogs(ctx, batchSbx, &shard, task)
+	assert.Equal(t, "train-1-failure-logs", shard.FailureLogs)
+	assert.Contains(t, <-recorder.Events, reasonTaskFailureLogs)
+
+	cm := &corev1.ConfigMap{}
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: shard.FailureLogs}, cm))
+	assert.Equal(t, "out of memory\n", cm.Data["stderr"])
+	assert.Equal(t, "step 9\n", cm.Data["stdout"])
+	require.Len(t, cm.OwnerReferences, 1)
+	assert.Equal(t, "train", cm.OwnerReferences[0].Name)
+
+	// the next round carries the ConfigMap over without storing the logs again
+	batchSbx.Status.TaskShards = []sandboxv1alpha1.TaskShardStatus{{Index: 0}, shard}
+	next := sandboxv1alpha1.TaskShardStatus{Index: 1, PodName: "train-1", State: "FAILED"}
+	r.syncShardFailureLogs(ctx, batchSbx, &next, task)
+	assert.Equal(t, shard.FailureLogs, next.FailureLogs)
+	assert.Empty(t, recorder.Events)
+}
+
+func TestSyncShardFailureLogs_OverwritesEarlierFailure(t *testing.T) {
+	batchSbx := &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "train", UID: "uid"},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			TaskFailureLogs: &sandboxv1alpha1.TaskFailureLogsSpec{},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(testscheme).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &BatchSandboxReconciler{Client: c, Scheme: testscheme, Recorder: recorder}
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	first := mock_scheduler.NewMockTask(ctrl)
+	first.EXPECT().GetName().Return("train-1").AnyTimes()
+	first.EXPECT().GetFailureLogs().Return(&api.TaskLogs{Stderr: "out of memory\n", Truncated: true}).AnyTimes()
+	shard := sandboxv1alpha1.TaskShardStatus{Index: 1, PodName: "train-1", State: "FAILED"}
+	r.syncShardFailureLogs(ctx, batchSbx, &shard, first)
+	<-recorder.Events
+
+	// the shard ran again on another Pod and failed there too
+	batchSbx.Status.TaskShards = []sandboxv1alpha1.TaskShardStatus{{Index: 0}, shard}
+	second := mock_scheduler.NewMockTask(ctrl)
+	second.EXPECT().GetName().Return("train-1").AnyTimes()
+	second.EXPECT().GetFailureLogs().Return(&api.TaskLogs{Stderr: "disk full\n"}).AnyTimes()
+	next := sandboxv1alpha1.TaskShardStatus{Index: 1, PodName: "train-1-b", State: "FAILED"}
+	r.syncShardFailureLogs(ctx, batchSbx, &next, second)
+	assert.Equal(t, "train-1-failure-logs", next.FailureLogs)
+	assert.Contains(t, <-recorder.Events, "train-1-b")
+
+	cm := &corev1.ConfigMap{}
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: next.FailureLogs}, cm))
+	assert.Equal(t, map[string]string{"stdout": "", "stderr": "disk full\n"}, cm.Data)
+
+	// a ConfigMap of that name the BatchSandbox does not own is left alone
+	foreign := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "train-2-failure-logs"},
+		Data:       map[string]string{"keep": "me"},
+	}
+	require.NoError(t, c.Create(ctx, foreign))
+	other := mock_scheduler.NewMockTask(ctrl)
+	other.EXPECT().GetName().Return("train-2").AnyTimes()
+	other.EXPECT().GetFailureLogs().Return(&api.TaskLogs{Stderr: "boom\n"}).AnyTimes()
+	shard2 := sandboxv1alpha1.TaskShardStatus{Index: 2, PodName: "train-2", State: "FAILED"}
+	r.syncShardFailureLogs(ctx, batchSbx, &shard2, other)
+	assert.Empty(t, shard2.FailureLogs)
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: foreign.Name}, cm))
+	assert.Equal(t, map[string]string{"keep": "me"}, cm.Data)
+}
+
+func TestFailureLogCapture(t *testing.T) {
+	batchSbx := &sandboxv1alpha1.BatchSandbox{}
+	assert.Nil(t, failureLogCapture(batchSbx))
+
+	batchSbx.Spec.TaskFailureLogs = &sandboxv1alpha1.TaskFailureLogsSpec{TailLines: 20}
+	capture := failureLogCapture(batchSbx)
+	require.NotNil(t, capture)
+	assert.Equal(t, 20, capture.TailLines)
+	assert.Equal(t, defaultFailureLogLimitBytes, capture.LimitBytes)
+}
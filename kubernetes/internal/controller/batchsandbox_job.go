@@ -0,0 +1,133 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/controller/strategy"
+)
+
+// reconcileIndexedJob runs the BatchSandbox's tasks as an Indexed Job instead
+// of Pods with a task executor. The Job is owned by the BatchSandbox and
+// garbage collected with it, so no finalizer is needed.
+func (r *BatchSandboxReconciler) reconcileIndexedJob(ctx context.Context, batchSbx *sandboxv1alpha1.BatchSandbox, params *strategy.TaskParameters) (ctrl.Result, error) {
+	if batchSbx.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+	taskStrategy := strategy.NewTaskSchedulingStrategyWithParameters(batchSbx, params)
+	if !taskStrategy.NeedTaskScheduling() {
+		return ctrl.Result{}, r.rejectSpec(batchSbx, "InvalidTaskTemplate", "IndexedJob task output needs a task template")
+	}
+	if err := taskStrategy.ValidateTaskTemplate(); err != nil {
+		klog.Errorf("batchsandbox %s has an invalid task template: %v", klog.KObj(batchSbx), err)
+		return ctrl.Result{}, r.rejectSpec(batchSbx, "InvalidTaskTemplate", fmt.Sprintf("invalid task template: %v", err))
+	}
+	tasks, err := taskStrategy.GenerateTaskSpecs()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	desired, err := strategy.RenderIndexedJob(batchSbx, tasks)
+	if err != nil {
+		klog.Errorf("batchsandbox %s cannot be rendered as an Indexed Job: %v", klog.KObj(batchSbx), err)
+		return ctrl.Result{}, r.rejectSpec(batchSbx, "InvalidTaskOutput", fmt.Sprintf("cannot render an Indexed Job: %v", err))
+	}
+	desired.Spec.Template = *r.ImageRewriter.RewriteTemplate(&desired.Spec.Template)
+	if err := ctrl.SetControllerReference(batchSbx, desired, r.Scheme); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	job := &batchv1.Job{}
+	err = r.Get(ctx, client.ObjectKeyFromObject(desired), job)
+	switch {
+	case errors.IsNotFound(err):
+		if err := r.Create(ctx, desired); err != nil {
+			r.Recorder.Eventf(batchSbx, corev1.EventTypeWarning, "FailedCreate", "failed to create job %s: %v", desired.Name, err)
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Eventf(batchSbx, corev1.EventTypeNormal, "SuccessfulCreate", "succeed to create job %s", desired.Name)
+		job = desired
+	case err != nil:
+		return ctrl.Result{}, err
+	case !metav1.IsControlledBy(job, batchSbx):
+		return ctrl.Result{}, r.rejectSpec(batchSbx, "InvalidTaskOutput", fmt.Sprintf("job %s exists and is not owned by this BatchSandbox", job.Name))
+	case ptr.Deref(job.Spec.Suspend, false) != batchSbx.Spec.Suspend:
+		// the rest of a Job's template is immutable; suspension is all that follows the spec
+		patch, _ := json.Marshal(map[string]any{"spec": map[string]any{"suspend": batchSbx.Spec.Suspend}})
+		if err := r.Patch(ctx, job, client.RawPatch(types.MergePatchType, patch)); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to patch job suspend %w", err)
+		}
+	}
+
+	if err := r.acceptSpec(batchSbx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	completions := ptr.Deref(job.Spec.Completions, 0)
+	newStatus := batchSbx.Status.DeepCopy()
+	newStatus.ObservedGeneration = batchSbx.Generation
+	newStatus.Replicas = job.Status.Active
+	newStatus.Ready = ptr.Deref(job.Status.Ready, 0)
+	newStatus.TaskRunning = job.Status.Active
+	newStatus.TaskSucceed = job.Status.Succeeded
+	newStatus.TaskFailed = job.Status.Failed
+	newStatus.TaskPending = max(completions-job.Status.Active-job.Status.Succeeded-job.Status.Failed, 0)
+	newStatus.TaskSuspended = batchSbx.Spec.Suspend
+	if batchSbx.Spec.TaskCompletionPolicy != nil {
+		newStatus.TaskCompletion = jobCompletion(job, newStatus.TaskCompletion)
+	}
+	if !reflect.DeepEqual(*newStatus, batchSbx.Status) {
+		klog.Infof("To update BatchSandbox status for %s from job %s, task_running=%d task_succeed=%d, task_failed=%d, task_pending=%d", klog.KObj(batchSbx), job.Name,
+			newStatus.TaskRunning, newStatus.TaskSucceed, newStatus.TaskFailed, newStatus.TaskPending)
+		if err := r.updateStatus(batchSbx, newStatus); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// jobCompletion maps the Job's own outcome to the All completion policy, the
+// only one an Indexed Job runs under.
+func jobCompletion(job *batchv1.Job, last *sandboxv1alpha1.TaskCompletionStatus) *sandboxv1alpha1.TaskCompletionStatus {
+	if last != nil && last.Phase != sandboxv1alpha1.TaskCompletionRunning {
+		return last
+	}
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			return &sandboxv1alpha1.TaskCompletionStatus{Phase: sandboxv1alpha1.TaskCompletionSucceeded, CompletionTime: ptr.To(cond.LastTransitionTime), Message: fmt.Sprintf("job %s completed", job.Name)}
+		case batchv1.JobFailed:
+			return &sandboxv1alpha1.TaskCompletionStatus{Phase: sandboxv1alpha1.TaskCompletionFailed, CompletionTime: ptr.To(cond.LastTransitionTime), Message: fmt.Sprintf("job %s failed: %s", job.Name, cond.Message)}
+		}
+	}
+	return &sandboxv1alpha1.TaskCompletionStatus{Phase: sandboxv1alpha1.TaskCompletionRunning}
+}
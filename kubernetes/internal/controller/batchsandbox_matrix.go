@@ -0,0 +1,54 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/controller/strategy"
+)
+
+// applyTaskMatrixReplicas sets Replicas on the in-memory BatchSandbox to the
+// number of combinations of its TaskMatrix. The spec is not written back, so
+// tools owning it see no drift; whatever Replicas it holds is ignored. It
+// returns true when the matrix is invalid and the reconcile has to stop until
+// the spec is fixed.
+func (r *BatchSandboxReconciler) applyTaskMatrixReplicas(batchSbx *sandboxv1alpha1.BatchSandbox) (bool, error) {
+	if len(batchSbx.Spec.TaskMatrix) == 0 || batchSbx.DeletionTimestamp != nil {
+		return false, nil
+	}
+	replicas, err := strategy.TaskMatrixReplicas(batchSbx.Spec.TaskMatrix)
+	if err != nil {
+		klog.Errorf("batchsandbox %s has an invalid task matrix: %v", klog.KObj(batchSbx), err)
+		return true, r.rejectSpec(batchSbx, "InvalidTaskMatrix", fmt.Sprintf("invalid task matrix: %v", err))
+	}
+	batchSbx.Spec.Replicas = ptr.To(replicas)
+	return false, nil
+}
+
+// desiredReplicas is the number of shards batchSbx asks for: the combinations
+// of its TaskMatrix when it has a valid one, its Replicas otherwise.
+func desiredReplicas(batchSbx *sandboxv1alpha1.BatchSandbox) int32 {
+	if len(batchSbx.Spec.TaskMatrix) > 0 {
+		if replicas, err := strategy.TaskMatrixReplicas(batchSbx.Spec.TaskMatrix); err == nil {
+			return replicas
+		}
+	}
+	return ptr.Deref(batchSbx.Spec.Replicas, 0)
+}
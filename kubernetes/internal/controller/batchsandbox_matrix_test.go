@@ -0,0 +1,73 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+)
+
+func TestApplyTaskMatrixReplicas(t *testing.T) {
+	batchSbx := &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "sweep"},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			Replicas: ptr.To[int32](1),
+			TaskMatrix: []sandboxv1alpha1.TaskMatrixDimension{
+				{Name: "lr", Values: []string{"0.1", "0.01"}},
+				{Name: "batch", Values: []string{"16", "32"}},
+			},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(batchSbx).WithStatusSubresource(batchSbx).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &BatchSandboxReconciler{Client: c, Scheme: testscheme, Recorder: recorder}
+	ctx := context.Background()
+
+	current := &sandboxv1alpha1.BatchSandbox{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(batchSbx), current))
+	stop, err := r.applyTaskMatrixReplicas(current)
+	require.NoError(t, err)
+	assert.False(t, stop)
+	assert.Equal(t, int32(4), *current.Spec.Replicas)
+	assert.Equal(t, int32(4), desiredReplicas(batchSbx))
+	stored := &sandboxv1alpha1.BatchSandbox{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(batchSbx), stored))
+	assert.Equal(t, int32(1), *stored.Spec.Replicas, "the stored spec is left alone")
+
+	current.Spec.TaskMatrix = append(current.Spec.TaskMatrix, sandboxv1alpha1.TaskMatrixDimension{Name: "seed"})
+	current.Spec.Replicas = ptr.To[int32](1)
+	stop, err = r.applyTaskMatrixReplicas(current)
+	require.NoError(t, err)
+	assert.True(t, stop)
+	assert.Equal(t, int32(1), desiredReplicas(current), "an invalid matrix falls back to replicas")
+	assert.Contains(t, <-recorder.Events, "InvalidTaskMatrix")
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(batchSbx), stored))
+	cond := meta.FindStatusCondition(stored.Status.Conditions, sandboxv1alpha1.BatchSandboxConditionSpecValid)
+	require.NotNil(t, cond, "an invalid matrix is reported in status")
+	assert.Equal(t, metav1.ConditionFalse, cond.Status)
+	assert.Equal(t, "InvalidTaskMatrix", cond.Reason)
+	assert.Contains(t, cond.Message, "seed")
+}
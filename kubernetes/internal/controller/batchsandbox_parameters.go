@@ -0,0 +1,119 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/controller/strategy"
+)
+
+const (
+	// taskParametersRetryInterval is how soon a missing or invalid parameter
+	// file is read again. ConfigMaps are not watched, so fixing one raises no
+	// event on the BatchSandbox.
+	taskParametersRetryInterval = 15 * time.Second
+
+	reasonTaskParametersLoaded   = "TaskParametersLoaded"
+	reasonTaskParametersNotFound = "TaskParametersNotFound"
+	reasonInvalidTaskParameters  = "InvalidTaskParameters"
+)
+
+// syncTaskParameters loads the BatchSandbox's TaskParameters file and sets
+// Replicas to its number of rows. It returns true when the reconcile has to
+// stop here: the spec was updated, or the file is
+// missing or invalid, which is reported on the TaskParametersLoaded condition
+// and retried until it is fixed. Deleted BatchSandboxes are not loaded, so a
+// ConfigMap removed along with them does not hold up their task cleanup.
+func (r *BatchSandboxReconciler) syncTaskParameters(ctx context.Context, batchSbx *sandboxv1alpha1.BatchSandbox) (*strategy.TaskParameters, bool, error) {
+	source := batchSbx.Spec.TaskParameters
+	if source == nil || batchSbx.DeletionTimestamp != nil {
+		return nil, false, nil
+	}
+	ref := fmt.Sprintf("configmap %s key %s", source.ConfigMapRef.Name, source.ConfigMapRef.Key)
+	params, reason, err := r.loadTaskParameters(ctx, batchSbx.Namespace, source)
+	if err != nil && reason == "" {
+		return nil, true, fmt.Errorf("failed to load task parameters: %w", err)
+	}
+	if err == nil && len(batchSbx.Spec.TaskMatrix) > 0 {
+		reason, err = reasonInvalidTaskParameters, fmt.Errorf("taskParameters cannot be combined with taskMatrix")
+	}
+	if err != nil {
+		klog.Errorf("batchsandbox %s has invalid task parameters in %s: %v", klog.KObj(batchSbx), ref, err)
+		r.Recorder.Eventf(batchSbx, corev1.EventTypeWarning, reason, "task parameters in %s: %v", ref, err)
+		cond := metav1.Condition{
+			Type:    sandboxv1alpha1.BatchSandboxConditionTaskParametersLoaded,
+			Status:  metav1.ConditionFalse,
+			Reason:  reason,
+			Message: fmt.Sprintf("%s: %v", ref, err),
+		}
+		DurationStore.Push(types.NamespacedName{Namespace: batchSbx.Namespace, Name: batchSbx.Name}.String(), taskParametersRetryInterval)
+		return nil, true, r.setCondition(batchSbx, cond)
+	}
+	cond := metav1.Condition{
+		Type:    sandboxv1alpha1.BatchSandboxConditionTaskParametersLoaded,
+		Status:  metav1.ConditionTrue,
+		Reason:  reasonTaskParametersLoaded,
+		Message: fmt.Sprintf("%d rows loaded from %s", len(params.Rows), ref),
+	}
+	if err := r.setCondition(batchSbx, cond); err != nil {
+		return nil, true, err
+	}
+	replicas := int32(len(params.Rows))
+	if batchSbx.Spec.Replicas != nil && *batchSbx.Spec.Replicas == replicas {
+		return params, false, nil
+	}
+	patch := []byte(fmt.Sprintf(`{"spec":{"replicas":%d}}`, replicas))
+	if err := r.Patch(ctx, batchSbx, client.RawPatch(types.MergePatchType, patch)); err != nil {
+		return nil, true, fmt.Errorf("failed to set replicas from task parameters: %w", err)
+	}
+	klog.Infof("batchsandbox %s replicas set to %d from its task parameters", klog.KObj(batchSbx), replicas)
+	return nil, true, nil
+}
+
+// loadTaskParameters reads and parses the parameter file of source. Errors
+// the user has to fix come with the condition reason to report them under;
+// others have none and are retried.
+func (r *BatchSandboxReconciler) loadTaskParameters(ctx context.Context, namespace string, source *sandboxv1alpha1.TaskParameterSource) (*strategy.TaskParameters, string, error) {
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: source.ConfigMapRef.Name}, cm); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, reasonTaskParametersNotFound, fmt.Errorf("configmap not found")
+		}
+		return nil, "", err
+	}
+	data, ok := cm.Data[source.ConfigMapRef.Key]
+	raw := []byte(data)
+	if !ok {
+		if raw, ok = cm.BinaryData[source.ConfigMapRef.Key]; !ok {
+			return nil, reasonTaskParametersNotFound, fmt.Errorf("key not found")
+		}
+	}
+	params, err := strategy.ParseTaskParameters(source, raw)
+	if err != nil {
+		return nil, reasonInvalidTaskParameters, err
+	}
+	return params, "", nil
+}
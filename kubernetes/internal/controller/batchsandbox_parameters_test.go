@@ -0,0 +1,89 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+)
+
+func TestSyncTaskParameters(t *testing.T) {
+	batchSbx := &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ingest"},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			Replicas: ptr.To[int32](1),
+			TaskParameters: &sandboxv1alpha1.TaskParameterSource{
+				ConfigMapRef: sandboxv1alpha1.TaskParameterConfigMapRef{Name: "shards", Key: "shards.csv"},
+			},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(batchSbx).WithStatusSubresource(batchSbx).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &BatchSandboxReconciler{Client: c, Scheme: testscheme, Recorder: recorder}
+	ctx := context.Background()
+	loaded := func() *metav1.Condition {
+		updated := &sandboxv1alpha1.BatchSandbox{}
+		require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(batchSbx), updated))
+		return meta.FindStatusCondition(updated.Status.Conditions, sandboxv1alpha1.BatchSandboxConditionTaskParametersLoaded)
+	}
+
+	_, stop, err := r.syncTaskParameters(ctx, batchSbx)
+	require.NoError(t, err)
+	assert.True(t, stop, "nothing is scheduled without the parameter file")
+	assert.Contains(t, <-recorder.Events, reasonTaskParametersNotFound)
+	require.NotNil(t, loaded())
+	assert.Equal(t, metav1.ConditionFalse, loaded().Status)
+	assert.Equal(t, reasonTaskParametersNotFound, loaded().Reason)
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "shards"},
+		Data:       map[string]string{"shards.csv": "seed,out\n1,2\n3\n"},
+	}
+	require.NoError(t, c.Create(ctx, cm))
+	_, stop, err = r.syncTaskParameters(ctx, batchSbx)
+	require.NoError(t, err)
+	assert.True(t, stop)
+	assert.Contains(t, <-recorder.Events, reasonInvalidTaskParameters)
+	assert.Equal(t, reasonInvalidTaskParameters, loaded().Reason)
+	assert.Contains(t, loaded().Message, "wrong number of fields")
+
+	cm.Data["shards.csv"] = "seed,out\n1,/a\n2,/b\n3,/c\n"
+	require.NoError(t, c.Update(ctx, cm))
+	_, stop, err = r.syncTaskParameters(ctx, batchSbx)
+	require.NoError(t, err)
+	assert.True(t, stop, "the reconcile continues with the updated spec")
+	assert.Equal(t, metav1.ConditionTrue, loaded().Status)
+	updated := &sandboxv1alpha1.BatchSandbox{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(batchSbx), updated))
+	assert.Equal(t, int32(3), *updated.Spec.Replicas)
+
+	params, stop, err := r.syncTaskParameters(ctx, updated)
+	require.NoError(t, err)
+	assert.False(t, stop, "replicas already match the rows")
+	require.NotNil(t, params)
+	assert.Equal(t, "/b", params.Rows[1]["out"])
+}
@@ -0,0 +1,165 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/utils/fieldindex"
+)
+
+// validateDisruptionBudget checks what the CRD schema cannot: the budget has
+// exactly one bound, the shards are Pods this controller creates, and the
+// BatchSandbox name can be used as the value of the selector label.
+func validateDisruptionBudget(batchSbx *sandboxv1alpha1.BatchSandbox) error {
+	budget := batchSbx.Spec.DisruptionBudget
+	if (budget.MinAvailable == nil) == (budget.MaxUnavailable == nil) {
+		return fmt.Errorf("exactly one of minAvailable and maxUnavailable must be set")
+	}
+	if batchSbx.Spec.PoolRef != "" {
+		return fmt.Errorf("not supported with poolRef")
+	}
+	if batchSbx.Spec.TaskOutput == sandboxv1alpha1.TaskOutputIndexedJob {
+		return fmt.Errorf("not supported with the IndexedJob task output")
+	}
+	for name, value := range map[string]*intstr.IntOrString{"minAvailable": budget.MinAvailable, "maxUnavailable": budget.MaxUnavailable} {
+		if value == nil {
+			continue
+		}
+		scaled, err := intstr.GetScaledValueFromIntOrPercent(value, 100, false)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if scaled < 0 {
+			return fmt.Errorf("%s must not be negative", name)
+		}
+	}
+	if errs := validation.IsValidLabelValue(batchSbx.Name); len(errs) > 0 {
+		return fmt.Errorf("the name cannot be used as the %s label: %v", LabelBatchSandboxNameKey, errs)
+	}
+	return nil
+}
+
+// syncDisruptionBudget keeps a PodDisruptionBudget named after the
+// BatchSandbox over its shard Pods, or deletes it once DisruptionBudget is
+// unset or the BatchSandbox is being deleted. The budget is owned by the
+// BatchSandbox, so garbage collection removes it too. It returns true when the
+// reconcile has to stop here because the budget is invalid, which is reported
+// as an event until the spec is fixed.
+func (r *BatchSandboxReconciler) syncDisruptionBudget(ctx context.Context, batchSbx *sandboxv1alpha1.BatchSandbox) (bool, error) {
+	current := &policyv1.PodDisruptionBudget{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: batchSbx.Namespace, Name: batchSbx.Name}, current)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	found := err == nil
+	exists := found && metav1.IsControlledBy(current, batchSbx)
+
+	if batchSbx.Spec.DisruptionBudget == nil || batchSbx.DeletionTimestamp != nil {
+		if !exists {
+			return false, nil
+		}
+		if err := r.Delete(ctx, current); err != nil && !errors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to delete pod disruption budget: %w", err)
+		}
+		klog.Infof("batchsandbox %s deleted its pod disruption budget", klog.KObj(batchSbx))
+		return false, nil
+	}
+	if err := validateDisruptionBudget(batchSbx); err != nil {
+		klog.Errorf("batchsandbox %s has an invalid disruption budget: %v", klog.KObj(batchSbx), err)
+		return true, r.rejectSpec(batchSbx, "InvalidDisruptionBudget", fmt.Sprintf("invalid disruption budget: %v", err))
+	}
+	if found && !exists {
+		return true, r.rejectSpec(batchSbx, "InvalidDisruptionBudget", fmt.Sprintf("pod disruption budget %s exists and is not owned by this BatchSandbox", batchSbx.Name))
+	}
+
+	// shards created before the budget was set do not have the selector label yet
+	if err := r.labelShardPods(ctx, batchSbx); err != nil {
+		return false, err
+	}
+	spec := policyv1.PodDisruptionBudgetSpec{
+		MinAvailable:   batchSbx.Spec.DisruptionBudget.MinAvailable,
+		MaxUnavailable: batchSbx.Spec.DisruptionBudget.MaxUnavailable,
+		Selector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{LabelBatchSandboxNameKey: batchSbx.Name},
+		},
+	}
+	if !exists {
+		pdb := &policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Namespace: batchSbx.Namespace, Name: batchSbx.Name},
+			Spec:       spec,
+		}
+		if err := ctrl.SetControllerReference(batchSbx, pdb, r.Scheme); err != nil {
+			return false, err
+		}
+		if err := r.Create(ctx, pdb); err != nil {
+			return false, fmt.Errorf("failed to create pod disruption budget: %w", err)
+		}
+		klog.Infof("batchsandbox %s created its pod disruption budget", klog.KObj(batchSbx))
+		return false, nil
+	}
+	if reflect.DeepEqual(current.Spec.MinAvailable, spec.MinAvailable) &&
+		reflect.DeepEqual(current.Spec.MaxUnavailable, spec.MaxUnavailable) &&
+		reflect.DeepEqual(current.Spec.Selector, spec.Selector) {
+		return false, nil
+	}
+	current.Spec.MinAvailable, current.Spec.MaxUnavailable, current.Spec.Selector = spec.MinAvailable, spec.MaxUnavailable, spec.Selector
+	if err := r.Update(ctx, current); err != nil {
+		return false, fmt.Errorf("failed to update pod disruption budget: %w", err)
+	}
+	return false, nil
+}
+
+// labelShardPods adds LabelBatchSandboxNameKey to the BatchSandbox's Pods
+// that lack it.
+func (r *BatchSandboxReconciler) labelShardPods(ctx context.Context, batchSbx *sandboxv1alpha1.BatchSandbox) error {
+	podList := &corev1.PodList{}
+	if err := r.List(ctx, podList, &client.ListOptions{
+		Namespace:     batchSbx.Namespace,
+		FieldSelector: fields.SelectorFromSet(fields.Set{fieldindex.IndexNameForOwnerRefUID: string(batchSbx.UID)}),
+	}); err != nil {
+		return err
+	}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Labels[LabelBatchSandboxNameKey] == batchSbx.Name {
+			continue
+		}
+		patch := client.MergeFrom(pod.DeepCopy())
+		if pod.Labels == nil {
+			pod.Labels = map[string]string{}
+		}
+		pod.Labels[LabelBatchSandboxNameKey] = batchSbx.Name
+		if err := r.Patch(ctx, pod, patch); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to label pod %s: %w", pod.Name, err)
+		}
+	}
+	return nil
+}
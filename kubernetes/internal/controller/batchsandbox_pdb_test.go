@@ -0,0 +1,184 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/utils/fieldindex"
+)
+
+func newDisruptionBudgetBatchSandbox() *sandboxv1alpha1.BatchSandbox {
+	return &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "budget", UID: "budget-uid"},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			Replicas: ptr.To[int32](3),
+			Template: &corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "budget"}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: "busybox"}}},
+			},
+			DisruptionBudget: &sandboxv1alpha1.DisruptionBudgetSpec{MaxUnavailable: ptr.To(intstr.FromInt32(1))},
+		},
+	}
+}
+
+func TestSyncDisruptionBudget(t *testing.T) {
+	batchSbx := newDisruptionBudgetBatchSandbox()
+	// a shard from before the budget was set, without the selector label
+	older := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "budget-0", Labels: map[string]string{"app": "budget"}}}
+	require.NoError(t, ctrl.SetControllerReference(batchSbx, older, testscheme))
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(batchSbx, older).WithStatusSubresource(batchSbx).
+		WithIndex(&corev1.Pod{}, fieldindex.IndexNameForOwnerRefUID, fieldindex.OwnerIndexFunc).Build()
+	r := &BatchSandboxReconciler{Client: c, Scheme: testscheme, Recorder: record.NewFakeRecorder(10)}
+	ctx := context.Background()
+
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(batchSbx)})
+	require.NoError(t, err)
+
+	pdb := &policyv1.PodDisruptionBudget{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(batchSbx), pdb))
+	assert.Equal(t, ptr.To(intstr.FromInt32(1)), pdb.Spec.MaxUnavailable)
+	assert.Nil(t, pdb.Spec.MinAvailable)
+	assert.True(t, metav1.IsControlledBy(pdb, batchSbx), "the budget is garbage-collected with the BatchSandbox")
+	selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+	require.NoError(t, err)
+
+	pods := &corev1.PodList{}
+	require.NoError(t, c.List(ctx, pods, client.InNamespace("default")))
+	require.Len(t, pods.Items, 3)
+	for _, pod := range pods.Items {
+		assert.True(t, selector.Matches(labels.Set(pod.Labels)), "pod %s is covered by the budget", pod.Name)
+	}
+	// the selector is per BatchSandbox, not per template
+	other := labels.Set{"app": "budget", LabelBatchSandboxPodIndexKey: "0"}
+	assert.False(t, selector.Matches(other), "pods of another BatchSandbox with the same template are not covered")
+
+	// a changed budget is applied in place
+	batchSbx.Spec.DisruptionBudget = &sandboxv1alpha1.DisruptionBudgetSpec{MinAvailable: ptr.To(intstr.FromString("50%"))}
+	_, err = r.syncDisruptionBudget(ctx, batchSbx)
+	require.NoError(t, err)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(batchSbx), pdb))
+	assert.Equal(t, ptr.To(intstr.FromString("50%")), pdb.Spec.MinAvailable)
+	assert.Nil(t, pdb.Spec.MaxUnavailable)
+
+	// deleting the BatchSandbox deletes the budget
+	batchSbx.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	_, err = r.syncDisruptionBudget(ctx, batchSbx)
+	require.NoError(t, err)
+	assert.True(t, errors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(batchSbx), pdb)))
+}
+
+func TestSyncDisruptionBudget_RemovedWithTheField(t *testing.T) {
+	batchSbx := newDisruptionBudgetBatchSandbox()
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(batchSbx).
+		WithIndex(&corev1.Pod{}, fieldindex.IndexNameForOwnerRefUID, fieldindex.OwnerIndexFunc).Build()
+	r := &BatchSandboxReconciler{Client: c, Scheme: testscheme, Recorder: record.NewFakeRecorder(10)}
+	ctx := context.Background()
+
+	_, err := r.syncDisruptionBudget(ctx, batchSbx)
+	require.NoError(t, err)
+	pdb := &policyv1.PodDisruptionBudget{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(batchSbx), pdb))
+
+	batchSbx.Spec.DisruptionBudget = nil
+	_, err = r.syncDisruptionBudget(ctx, batchSbx)
+	require.NoError(t, err)
+	assert.True(t, errors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(batchSbx), pdb)))
+}
+
+func TestSyncDisruptionBudget_Invalid(t *testing.T) {
+	valid := newDisruptionBudgetBatchSandbox()
+	assert.NoError(t, validateDisruptionBudget(valid))
+
+	both := valid.DeepCopy()
+	both.Spec.DisruptionBudget.MinAvailable = ptr.To(intstr.FromInt32(1))
+	pooled := valid.DeepCopy()
+	pooled.Spec.PoolRef = "pool"
+	indexedJob := valid.DeepCopy()
+	indexedJob.Spec.TaskOutput = sandboxv1alpha1.TaskOutputIndexedJob
+	percent := valid.DeepCopy()
+	percent.Spec.DisruptionBudget.MaxUnavailable = ptr.To(intstr.FromString("half"))
+	for name, batchSbx := range map[string]*sandboxv1alpha1.BatchSandbox{"both": both, "pooled": pooled, "indexedJob": indexedJob, "percent": percent} {
+		assert.Error(t, validateDisruptionBudget(batchSbx), name)
+	}
+
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(pooled).WithStatusSubresource(pooled).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &BatchSandboxReconciler{Client: c, Scheme: testscheme, Recorder: recorder}
+	stop, err := r.syncDisruptionBudget(context.Background(), pooled)
+	require.NoError(t, err)
+	assert.True(t, stop)
+	assert.Contains(t, <-recorder.Events, "InvalidDisruptionBudget")
+	assert.True(t, meta.IsStatusConditionFalse(pooled.Status.Conditions, sandboxv1alpha1.BatchSandboxConditionSpecValid))
+}
+
+func TestReconcile_InvalidSpecIsReportedInStatus(t *testing.T) {
+	batchSbx := newDisruptionBudgetBatchSandbox()
+	batchSbx.Spec.DisruptionBudget.MinAvailable = ptr.To(intstr.FromInt32(1))
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(batchSbx).WithStatusSubresource(batchSbx).
+		WithIndex(&corev1.Pod{}, fieldindex.IndexNameForOwnerRefUID, fieldindex.OwnerIndexFunc).Build()
+	r := &BatchSandboxReconciler{Client: c, Scheme: testscheme, Recorder: record.NewFakeRecorder(10)}
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(batchSbx)}
+	stored := func() (*sandboxv1alpha1.BatchSandbox, *metav1.Condition) {
+		updated := &sandboxv1alpha1.BatchSandbox{}
+		require.NoError(t, c.Get(ctx, req.NamespacedName, updated))
+		return updated, meta.FindStatusCondition(updated.Status.Conditions, sandboxv1alpha1.BatchSandboxConditionSpecValid)
+	}
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	_, cond := stored()
+	require.NotNil(t, cond, "the validation error is written to status")
+	assert.Equal(t, metav1.ConditionFalse, cond.Status)
+	assert.Equal(t, "InvalidDisruptionBudget", cond.Reason)
+	assert.Contains(t, cond.Message, "minAvailable")
+
+	updated, _ := stored()
+	updated.Spec.DisruptionBudget.MinAvailable = nil
+	require.NoError(t, c.Update(ctx, updated))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	_, cond = stored()
+	require.NotNil(t, cond)
+	assert.Equal(t, metav1.ConditionTrue, cond.Status, "the condition clears once the spec is fixed")
+
+	// a spec that was never rejected gets no condition
+	other := newDisruptionBudgetBatchSandbox()
+	other.Name, other.UID = "valid", "valid-uid"
+	require.NoError(t, c.Create(ctx, other))
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(other)})
+	require.NoError(t, err)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(other), other))
+	assert.Nil(t, meta.FindStatusCondition(other.Status.Conditions, sandboxv1alpha1.BatchSandboxConditionSpecValid))
+}
@@ -0,0 +1,245 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+)
+
+const (
+	// LabelBatchSandboxPrePullKey selects the pull Pods of a BatchSandbox by its UID.
+	LabelBatchSandboxPrePullKey = "batch-sandbox.sandbox.opensandbox.io/prepull"
+
+	defaultPrePullTimeout    = 10 * time.Minute
+	defaultPrePullPauseImage = "registry.k8s.io/pause:3.10"
+	// prePullPollInterval is how often a waiting BatchSandbox checks its
+	// DaemonSet, on top of the DaemonSet's own status updates.
+	prePullPollInterval = 5 * time.Second
+
+	reasonPrePulling       = "Pulling"
+	reasonImagesPrePulled  = "ImagesPulled"
+	reasonPrePullTimedOut  = "PrePullTimedOut"
+	reasonPrePullSkipped   = "ShardsExist"
+	eventImagesPrePulled   = "ImagesPrePulled"
+	eventImagePrePullStart = "ImagePrePullStarted"
+)
+
+var defaultPrePullCommand = []string{"sh", "-c", "true"}
+
+// prePullDaemonSetName names the DaemonSet pulling a BatchSandbox's images.
+func prePullDaemonSetName(batchSbx *sandboxv1alpha1.BatchSandbox) string {
+	return batchSbx.Name + "-prepull"
+}
+
+// prePullImages holds back the first shard Pods until their images are on
+// every node they can be scheduled to, so the shards start together. A
+// DaemonSet placed like the shards pulls each image in an init container;
+// once all its Pods are ready, or the timeout has passed, it is deleted and
+// the shards are created. Progress is kept in the ImagesPrePulled condition
+// of batchSbx's status, written as soon as it changes. Pods
+// holds the shards about to be created; existing is the number already
+// there. It returns whether they may be created now.
+func (r *BatchSandboxReconciler) prePullImages(ctx context.Context, batchSbx *sandboxv1alpha1.BatchSandbox, existing int, pods []*corev1.Pod) (bool, error) {
+	spec := batchSbx.Spec.ImagePrePull
+	if spec == nil || len(pods) == 0 {
+		return true, nil
+	}
+	cond := meta.FindStatusCondition(batchSbx.Status.Conditions, sandboxv1alpha1.BatchSandboxConditionImagesPrePulled)
+	if cond != nil && cond.Reason != reasonPrePulling {
+		// done once: shards recreated later are not held back
+		return true, r.deletePrePullDaemonSet(ctx, batchSbx)
+	}
+	if cond == nil && existing > 0 {
+		// enabled after the shards were created; there is nothing to hold back
+		return true, r.setPrePullCondition(batchSbx, metav1.ConditionFalse, reasonPrePullSkipped, "shards existed before imagePrePull was set")
+	}
+
+	key := types.NamespacedName{Namespace: batchSbx.Namespace, Name: prePullDaemonSetName(batchSbx)}
+	ds := &appsv1.DaemonSet{}
+	err := r.Get(ctx, key, ds)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	if errors.IsNotFound(err) {
+		ds, err = r.newPrePullDaemonSet(batchSbx, pods)
+		if err != nil {
+			return false, err
+		}
+		if err := r.Create(ctx, ds); err != nil {
+			return false, fmt.Errorf("failed to create image pre-pull daemonset: %w", err)
+		}
+		klog.Infof("batchsandbox %s pre-pulls %d image(s) before creating its shards", klog.KObj(batchSbx), len(ds.Spec.Template.Spec.InitContainers))
+		r.Recorder.Eventf(batchSbx, corev1.EventTypeNormal, eventImagePrePullStart, "pulling %d image(s) on the candidate nodes before creating %d shard(s)", len(ds.Spec.Template.Spec.InitContainers), len(pods))
+	} else if !metav1.IsControlledBy(ds, batchSbx) {
+		return false, fmt.Errorf("daemonset %s exists and is not owned by this BatchSandbox", key.Name)
+	}
+	if cond == nil {
+		if err := r.setPrePullCondition(batchSbx, metav1.ConditionFalse, reasonPrePulling, "pulling the shard images on the candidate nodes"); err != nil {
+			return false, err
+		}
+		cond = meta.FindStatusCondition(batchSbx.Status.Conditions, sandboxv1alpha1.BatchSandboxConditionImagesPrePulled)
+	}
+
+	if prePullDone(ds) {
+		message := fmt.Sprintf("images pulled on %d node(s)", ds.Status.DesiredNumberScheduled)
+		if err := r.setPrePullCondition(batchSbx, metav1.ConditionTrue, reasonImagesPrePulled, message); err != nil {
+			return false, err
+		}
+		r.Recorder.Eventf(batchSbx, corev1.EventTypeNormal, eventImagesPrePulled, "%s, creating the shards", message)
+		return true, r.deletePrePullDaemonSet(ctx, batchSbx)
+	}
+	timeout := defaultPrePullTimeout
+	if spec.TimeoutSeconds != nil {
+		timeout = time.Duration(*spec.TimeoutSeconds) * time.Second
+	}
+	if waited := timeNow().Sub(cond.LastTransitionTime.Time); waited >= timeout {
+		message := fmt.Sprintf("images pulled on %d of %d node(s) after %v", ds.Status.NumberReady, ds.Status.DesiredNumberScheduled, timeout)
+		if err := r.setPrePullCondition(batchSbx, metav1.ConditionFalse, reasonPrePullTimedOut, message); err != nil {
+			return false, err
+		}
+		r.Recorder.Eventf(batchSbx, corev1.EventTypeWarning, reasonPrePullTimedOut, "%s, creating the shards anyway", message)
+		return true, r.deletePrePullDaemonSet(ctx, batchSbx)
+	}
+	DurationStore.Push(types.NamespacedName{Namespace: batchSbx.Namespace, Name: batchSbx.Name}.String(), prePullPollInterval)
+	return false, nil
+}
+
+// prePullDone tells whether every node the DaemonSet was placed on has its
+// Pod ready, i.e. all init containers, one per image, ran.
+func prePullDone(ds *appsv1.DaemonSet) bool {
+	if ds.Status.ObservedGeneration < ds.Generation || ds.Status.ObservedGeneration == 0 {
+		return false
+	}
+	return ds.Status.NumberReady >= ds.Status.DesiredNumberScheduled
+}
+
+// setPrePullCondition stores the ImagesPrePulled condition right away: the
+// status Reconcile writes later is compared with batchSbx's own, which would
+// already hold the change.
+func (r *BatchSandboxReconciler) setPrePullCondition(batchSbx *sandboxv1alpha1.BatchSandbox, status metav1.ConditionStatus, reason, message string) error {
+	newStatus := batchSbx.Status.DeepCopy()
+	if !meta.SetStatusCondition(&newStatus.Conditions, metav1.Condition{
+		Type:               sandboxv1alpha1.BatchSandboxConditionImagesPrePulled,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: batchSbx.Generation,
+		LastTransitionTime: metav1.NewTime(timeNow()),
+	}) {
+		return nil
+	}
+	if err := r.updateStatus(batchSbx, newStatus); err != nil {
+		return err
+	}
+	batchSbx.Status = *newStatus
+	return nil
+}
+
+func (r *BatchSandboxReconciler) deletePrePullDaemonSet(ctx context.Context, batchSbx *sandboxv1alpha1.BatchSandbox) error {
+	ds := &appsv1.DaemonSet{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: batchSbx.Namespace, Name: prePullDaemonSetName(batchSbx)}, ds); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !metav1.IsControlledBy(ds, batchSbx) {
+		return nil
+	}
+	if err := r.Delete(ctx, ds); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete image pre-pull daemonset: %w", err)
+	}
+	return nil
+}
+
+// newPrePullDaemonSet builds the DaemonSet pulling the images of pods. Its
+// Pods are placed like the first of them: same node selector, node affinity,
+// tolerations and pull credentials. Pod affinities are left out, since they
+// would keep the pull Pods off nodes the shards themselves could use.
+func (r *BatchSandboxReconciler) newPrePullDaemonSet(batchSbx *sandboxv1alpha1.BatchSandbox, pods []*corev1.Pod) (*appsv1.DaemonSet, error) {
+	spec := batchSbx.Spec.ImagePrePull
+	command := defaultPrePullCommand
+	if len(spec.Command) > 0 {
+		command = spec.Command
+	}
+	pauseImage := defaultPrePullPauseImage
+	if spec.PauseImage != "" {
+		pauseImage = spec.PauseImage
+	}
+	seen := map[string]bool{}
+	var images []string
+	for _, pod := range pods {
+		for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+			for _, c := range containers {
+				if c.Image != "" && !seen[c.Image] {
+					seen[c.Image] = true
+					images = append(images, c.Image)
+				}
+			}
+		}
+	}
+	sort.Strings(images)
+	pulls := make([]corev1.Container, 0, len(images))
+	for i, image := range images {
+		pulls = append(pulls, corev1.Container{
+			Name:            fmt.Sprintf("pull-%d", i),
+			Image:           image,
+			Command:         command,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+		})
+	}
+
+	first := pods[0].Spec
+	podSpec := corev1.PodSpec{
+		InitContainers:     pulls,
+		Containers:         []corev1.Container{{Name: "pause", Image: pauseImage, ImagePullPolicy: corev1.PullIfNotPresent}},
+		NodeSelector:       first.NodeSelector,
+		Tolerations:        first.Tolerations,
+		ImagePullSecrets:   first.ImagePullSecrets,
+		ServiceAccountName: first.ServiceAccountName,
+	}
+	if first.Affinity != nil && first.Affinity.NodeAffinity != nil {
+		podSpec.Affinity = &corev1.Affinity{NodeAffinity: first.Affinity.NodeAffinity}
+	}
+	labels := map[string]string{LabelBatchSandboxPrePullKey: string(batchSbx.UID)}
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: batchSbx.Namespace, Name: prePullDaemonSetName(batchSbx), Labels: labels},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       podSpec,
+			},
+		},
+	}
+	if err := ctrl.SetControllerReference(batchSbx, ds, r.Scheme); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
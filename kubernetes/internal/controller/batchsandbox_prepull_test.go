@@ -0,0 +1,209 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	gerrors "errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/controller/strategy"
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/utils/fieldindex"
+)
+
+func newPrePullBatchSandbox(name string) *sandboxv1alpha1.BatchSandbox {
+	return &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "prepull", Name: name, UID: types.UID(name + "-uid")},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			Replicas:     ptr.To[int32](2),
+			ImagePrePull: &sandboxv1alpha1.ImagePrePullSpec{TimeoutSeconds: ptr.To[int32](60)},
+			Template: &corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					NodeSelector:   map[string]string{"pool": "gpu"},
+					Tolerations:    []corev1.Toleration{{Key: "gpu", Operator: corev1.TolerationOpExists}},
+					InitContainers: []corev1.Container{{Name: "init", Image: "busybox"}},
+					Containers: []corev1.Container{
+						{Name: "main", Image: "trainer:v1"},
+						{Name: "sidecar", Image: "busybox"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func prePullShards(t *testing.T, c client.Client) []string {
+	pods := &corev1.PodList{}
+	require.NoError(t, c.List(context.Background(), pods, client.InNamespace("prepull")))
+	var names []string
+	for _, pod := range pods.Items {
+		names = append(names, pod.Name)
+	}
+	return names
+}
+
+func TestScaleBatchSandbox_PrePullHoldsBackShards(t *testing.T) {
+	batchSbx := newPrePullBatchSandbox("train")
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(batchSbx).WithStatusSubresource(batchSbx).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &BatchSandboxReconciler{Client: c, Scheme: testscheme, Recorder: recorder}
+	key := types.NamespacedName{Namespace: "prepull", Name: "train"}
+	defer DurationStore.Pop(key.String())
+
+	_, err := r.scaleBatchSandbox(context.Background(), batchSbx, batchSbx.Spec.Template, strategy.NewTaskSchedulingStrategy(batchSbx), nil)
+	require.NoError(t, err)
+	assert.Empty(t, prePullShards(t, c), "no shard is created while the images are pulled")
+	cond := meta.FindStatusCondition(batchSbx.Status.Conditions, sandboxv1alpha1.BatchSandboxConditionImagesPrePulled)
+	require.NotNil(t, cond)
+	assert.Equal(t, metav1.ConditionFalse, cond.Status)
+	assert.Equal(t, reasonPrePulling, cond.Reason)
+
+	ds := &appsv1.DaemonSet{}
+	require.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: "prepull", Name: "train-prepull"}, ds))
+	assert.True(t, metav1.IsControlledBy(ds, batchSbx))
+	spec := ds.Spec.Template.Spec
+	require.Len(t, spec.InitContainers, 2, "one pull per distinct image")
+	assert.Equal(t, "busybox", spec.InitContainers[0].Image)
+	assert.Equal(t, "trainer:v1", spec.InitContainers[1].Image)
+	assert.Equal(t, defaultPrePullCommand, spec.InitContainers[0].Command)
+	assert.Equal(t, defaultPrePullPauseImage, spec.Containers[0].Image)
+	assert.Equal(t, map[string]string{"pool": "gpu"}, spec.NodeSelector)
+	assert.Len(t, spec.Tolerations, 1)
+	assert.Equal(t, map[string]string{LabelBatchSandboxPrePullKey: "train-uid"}, ds.Spec.Selector.MatchLabels)
+
+	// still pulling: nothing changes
+	_, err = r.scaleBatchSandbox(context.Background(), batchSbx, batchSbx.Spec.Template, strategy.NewTaskSchedulingStrategy(batchSbx), nil)
+	require.NoError(t, err)
+	assert.Empty(t, prePullShards(t, c))
+
+	ds.Generation = 1
+	require.NoError(t, c.Update(context.Background(), ds))
+	ds.Status = appsv1.DaemonSetStatus{ObservedGeneration: 1, DesiredNumberScheduled: 3, NumberReady: 3}
+	require.NoError(t, c.Status().Update(context.Background(), ds))
+	_, err = r.scaleBatchSandbox(context.Background(), batchSbx, batchSbx.Spec.Template, strategy.NewTaskSchedulingStrategy(batchSbx), nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"train-0", "train-1"}, prePullShards(t, c))
+	cond = meta.FindStatusCondition(batchSbx.Status.Conditions, sandboxv1alpha1.BatchSandboxConditionImagesPrePulled)
+	assert.Equal(t, metav1.ConditionTrue, cond.Status)
+	assert.Equal(t, reasonImagesPrePulled, cond.Reason)
+	assert.True(t, errors.IsNotFound(c.Get(context.Background(), client.ObjectKeyFromObject(ds), &appsv1.DaemonSet{})), "the DaemonSet is removed once done")
+}
+
+func TestScaleBatchSandbox_PrePullTimeout(t *testing.T) {
+	batchSbx := newPrePullBatchSandbox("slow")
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(batchSbx).WithStatusSubresource(batchSbx).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &BatchSandboxReconciler{Client: c, Scheme: testscheme, Recorder: recorder}
+	defer DurationStore.Pop(types.NamespacedName{Namespace: "prepull", Name: "slow"}.String())
+	start := time.Now()
+	defer func() { timeNow = time.Now }()
+	timeNow = func() time.Time { return start }
+
+	_, err := r.scaleBatchSandbox(context.Background(), batchSbx, batchSbx.Spec.Template, strategy.NewTaskSchedulingStrategy(batchSbx), nil)
+	require.NoError(t, err)
+	assert.Empty(t, prePullShards(t, c))
+
+	timeNow = func() time.Time { return start.Add(time.Minute) }
+	_, err = r.scaleBatchSandbox(context.Background(), batchSbx, batchSbx.Spec.Template, strategy.NewTaskSchedulingStrategy(batchSbx), nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"slow-0", "slow-1"}, prePullShards(t, c), "the shards are created once the pull timed out")
+	cond := meta.FindStatusCondition(batchSbx.Status.Conditions, sandboxv1alpha1.BatchSandboxConditionImagesPrePulled)
+	assert.Equal(t, reasonPrePullTimedOut, cond.Reason)
+	assert.True(t, errors.IsNotFound(c.Get(context.Background(), types.NamespacedName{Namespace: "prepull", Name: "slow-prepull"}, &appsv1.DaemonSet{})))
+
+	// a shard recreated later is not held back
+	ready, err := r.prePullImages(context.Background(), batchSbx, 1, []*corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "slow-1"}}})
+	require.NoError(t, err)
+	assert.True(t, ready)
+	assert.True(t, errors.IsNotFound(c.Get(context.Background(), types.NamespacedName{Namespace: "prepull", Name: "slow-prepull"}, &appsv1.DaemonSet{})))
+}
+
+func TestScaleBatchSandbox_PrePullSkippedForExistingShards(t *testing.T) {
+	batchSbx := newPrePullBatchSandbox("late")
+	pod0 := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "prepull", Name: "late-0", Labels: map[string]string{LabelBatchSandboxPodIndexKey: "0"}}}
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(batchSbx, pod0).WithStatusSubresource(batchSbx).Build()
+	r := &BatchSandboxReconciler{Client: c, Scheme: testscheme, Recorder: record.NewFakeRecorder(10)}
+
+	_, err := r.scaleBatchSandbox(context.Background(), batchSbx, batchSbx.Spec.Template, strategy.NewTaskSchedulingStrategy(batchSbx), []*corev1.Pod{pod0})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"late-0", "late-1"}, prePullShards(t, c))
+	cond := meta.FindStatusCondition(batchSbx.Status.Conditions, sandboxv1alpha1.BatchSandboxConditionImagesPrePulled)
+	require.NotNil(t, cond)
+	assert.Equal(t, reasonPrePullSkipped, cond.Reason)
+}
+
+func TestReconcile_PrePullConditionIsStored(t *testing.T) {
+	batchSbx := newPrePullBatchSandbox("stored")
+	failPods := false
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(batchSbx).WithStatusSubresource(batchSbx).
+		WithIndex(&corev1.Pod{}, fieldindex.IndexNameForOwnerRefUID, fieldindex.OwnerIndexFunc).
+		WithInterceptorFuncs(interceptor.Funcs{Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if _, ok := obj.(*corev1.Pod); ok && failPods {
+				return errors.NewInternalError(gerrors.New("etcd unavailable"))
+			}
+			return c.Create(ctx, obj, opts...)
+		}}).Build()
+	r := &BatchSandboxReconciler{Client: c, Scheme: testscheme, Recorder: record.NewFakeRecorder(10)}
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(batchSbx)}
+	stored := func() *metav1.Condition {
+		updated := &sandboxv1alpha1.BatchSandbox{}
+		require.NoError(t, c.Get(ctx, req.NamespacedName, updated))
+		return meta.FindStatusCondition(updated.Status.Conditions, sandboxv1alpha1.BatchSandboxConditionImagesPrePulled)
+	}
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Empty(t, prePullShards(t, c))
+	require.NotNil(t, stored(), "the condition is written to the API server")
+	assert.Equal(t, reasonPrePulling, stored().Reason)
+
+	ds := &appsv1.DaemonSet{}
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "prepull", Name: "stored-prepull"}, ds))
+	ds.Generation = 1
+	require.NoError(t, c.Update(ctx, ds))
+	ds.Status = appsv1.DaemonSetStatus{ObservedGeneration: 1, DesiredNumberScheduled: 1, NumberReady: 1}
+	require.NoError(t, c.Status().Update(ctx, ds))
+	// the shards fail to be created: the pull is still recorded as done
+	failPods = true
+	_, err = r.Reconcile(ctx, req)
+	require.Error(t, err)
+	assert.Equal(t, metav1.ConditionTrue, stored().Status)
+	assert.Equal(t, reasonImagesPrePulled, stored().Reason)
+	assert.True(t, errors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(ds), &appsv1.DaemonSet{})))
+
+	failPods = false
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"stored-0", "stored-1"}, prePullShards(t, c))
+	assert.True(t, errors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(ds), &appsv1.DaemonSet{})), "the images are not pulled again")
+}
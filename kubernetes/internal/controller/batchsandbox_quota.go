@@ -0,0 +1,245 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+)
+
+const (
+	// quotaRetryInterval is how soon shards held back by quota are retried.
+	// ResourceQuotas are not watched, and freed quota raises no event on the
+	// BatchSandbox unless one of its own Pods went away.
+	quotaRetryInterval = 15 * time.Second
+
+	reasonInsufficientQuota = "InsufficientQuota"
+	reasonQuotaSufficient   = "QuotaSufficient"
+)
+
+// quotaShortage describes the first quota that keeps a shard from being
+// created, or the API server's rejection when the controller's accounting
+// missed it.
+type quotaShortage struct {
+	quota     string
+	resource  corev1.ResourceName
+	needed    resource.Quantity
+	remaining resource.Quantity
+	rejected  error
+}
+
+func (s *quotaShortage) String() string {
+	if s.rejected != nil {
+		return s.rejected.Error()
+	}
+	return fmt.Sprintf("ResourceQuota %s has %s of %s left, a shard needs %s",
+		s.quota, s.remaining.String(), s.resource, s.needed.String())
+}
+
+// namespaceQuota is what the ResourceQuotas of a namespace have left, as the
+// BatchSandbox controller accounts it while creating shards.
+type namespaceQuota struct {
+	quotas []corev1.ResourceQuota
+	// remaining holds hard - used per quota and resource, spent as shards are admitted
+	remaining []corev1.ResourceList
+}
+
+// loadNamespaceQuota reads the ResourceQuotas of namespace. Scoped quotas are
+// skipped: whether they apply depends on the Pod's priority class, QoS class
+// or deadline, which the API server decides at admission.
+func (r *BatchSandboxReconciler) loadNamespaceQuota(ctx context.Context, namespace string) (*namespaceQuota, error) {
+	list := &corev1.ResourceQuotaList{}
+	if err := r.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	nq := &namespaceQuota{}
+	for _, quota := range list.Items {
+		if len(quota.Spec.Scopes) > 0 || quota.Spec.ScopeSelector != nil {
+			continue
+		}
+		remaining := corev1.ResourceList{}
+		for name, hard := range quota.Status.Hard {
+			left := hard.DeepCopy()
+			if used, ok := quota.Status.Used[name]; ok {
+				left.Sub(used)
+			}
+			remaining[name] = left
+		}
+		nq.quotas = append(nq.quotas, quota)
+		nq.remaining = append(nq.remaining, remaining)
+	}
+	return nq, nil
+}
+
+// admit spends the quota usage of pod when every quota has room for it, and
+// otherwise reports the first shortage without spending anything.
+func (nq *namespaceQuota) admit(pod *corev1.Pod) *quotaShortage {
+	usage := podQuotaUsage(pod)
+	if shortage := nq.check(usage); shortage != nil {
+		return shortage
+	}
+	for i := range nq.remaining {
+		for name, needed := range usage {
+			if left, ok := nq.remaining[i][name]; ok {
+				left.Sub(needed)
+				nq.remaining[i][name] = left
+			}
+		}
+	}
+	return nil
+}
+
+func (nq *namespaceQuota) check(usage corev1.ResourceList) *quotaShortage {
+	names := make([]string, 0, len(usage))
+	for name := range usage {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for i, quota := range nq.quotas {
+		for _, name := range names {
+			left, ok := nq.remaining[i][corev1.ResourceName(name)]
+			needed := usage[corev1.ResourceName(name)]
+			if ok && left.Cmp(needed) < 0 {
+				return &quotaShortage{quota: quota.Name, resource: corev1.ResourceName(name), needed: needed, remaining: left}
+			}
+		}
+	}
+	return nil
+}
+
+// podQuotaUsage is what a Pod counts against a ResourceQuota, per resource
+// name a quota can set: its object count and its requests and limits, the
+// larger of the sum over the containers and the largest init container, plus
+// the Pod overhead.
+func podQuotaUsage(pod *corev1.Pod) corev1.ResourceList {
+	one := resource.MustParse("1")
+	usage := corev1.ResourceList{
+		corev1.ResourcePods:               one,
+		corev1.ResourceName("count/pods"): one,
+	}
+	requests := podResources(pod, func(c *corev1.Container) corev1.ResourceList { return c.Resources.Requests })
+	limits := podResources(pod, func(c *corev1.Container) corev1.ResourceList { return c.Resources.Limits })
+	for name, q := range requests {
+		usage[corev1.ResourceName("requests."+string(name))] = q
+		// cpu, memory and ephemeral-storage also stand for their requests
+		switch name {
+		case corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourceEphemeralStorage:
+			usage[name] = q
+		}
+	}
+	for name, q := range limits {
+		if strings.Contains(string(name), "/") {
+			// extended resources are only quota'd by their requests
+			continue
+		}
+		usage[corev1.ResourceName("limits."+string(name))] = q
+	}
+	return usage
+}
+
+func podResources(pod *corev1.Pod, of func(*corev1.Container) corev1.ResourceList) corev1.ResourceList {
+	sum := corev1.ResourceList{}
+	for i := range pod.Spec.Containers {
+		addResources(sum, of(&pod.Spec.Containers[i]))
+	}
+	for i := range pod.Spec.InitContainers {
+		for name, q := range of(&pod.Spec.InitContainers[i]) {
+			if cur, ok := sum[name]; !ok || cur.Cmp(q) < 0 {
+				sum[name] = q.DeepCopy()
+			}
+		}
+	}
+	addResources(sum, pod.Spec.Overhead)
+	return sum
+}
+
+func addResources(sum, add corev1.ResourceList) {
+	for name, q := range add {
+		cur := sum[name]
+		cur.Add(q)
+		sum[name] = cur
+	}
+}
+
+// admitShards picks the shard Pods the namespace's ResourceQuotas have room
+// for, in index order. Under Backfill every Pod that fits is admitted; under
+// AllOrNothing either all are or none is. The first shortage is reported
+// along with the number of Pods held back.
+func (r *BatchSandboxReconciler) admitShards(ctx context.Context, batchSandbox *sandboxv1alpha1.BatchSandbox, pods []*corev1.Pod) ([]*corev1.Pod, *quotaShortage, error) {
+	if len(pods) == 0 {
+		return nil, nil, nil
+	}
+	nq, err := r.loadNamespaceQuota(ctx, batchSandbox.Namespace)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list resource quotas: %w", err)
+	}
+	if len(nq.quotas) == 0 {
+		return pods, nil, nil
+	}
+	var admitted []*corev1.Pod
+	var first *quotaShortage
+	for _, pod := range pods {
+		if shortage := nq.admit(pod); shortage != nil {
+			if first == nil {
+				first = shortage
+			}
+			continue
+		}
+		admitted = append(admitted, pod)
+	}
+	if first != nil && batchSandbox.Spec.QuotaPolicy == sandboxv1alpha1.QuotaPolicyAllOrNothing {
+		return nil, first, nil
+	}
+	return admitted, first, nil
+}
+
+// isQuotaExceeded tells a Pod rejected by ResourceQuota admission, which the
+// controller's own accounting can miss when other Pods raced it.
+func isQuotaExceeded(err error) bool {
+	return errors.IsForbidden(err) && strings.Contains(err.Error(), "exceeded quota")
+}
+
+// quotaCondition builds the QuotaAvailable condition of a BatchSandbox.
+func quotaCondition(batchSandbox *sandboxv1alpha1.BatchSandbox, shortage *quotaShortage, pending int) *metav1.Condition {
+	if shortage == nil {
+		return &metav1.Condition{
+			Type:    sandboxv1alpha1.BatchSandboxConditionQuotaAvailable,
+			Status:  metav1.ConditionTrue,
+			Reason:  reasonQuotaSufficient,
+			Message: "all shards fit in the namespace's ResourceQuotas",
+		}
+	}
+	message := fmt.Sprintf("%d shard(s) waiting for quota: %s", pending, shortage)
+	if batchSandbox.Spec.QuotaPolicy == sandboxv1alpha1.QuotaPolicyAllOrNothing {
+		message += "; quotaPolicy AllOrNothing holds back every missing shard"
+	}
+	return &metav1.Condition{
+		Type:    sandboxv1alpha1.BatchSandboxConditionQuotaAvailable,
+		Status:  metav1.ConditionFalse,
+		Reason:  reasonInsufficientQuota,
+		Message: message,
+	}
+}
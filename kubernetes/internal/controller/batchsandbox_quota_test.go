@@ -0,0 +1,173 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/controller/strategy"
+)
+
+func newQuotaBatchSandbox(name string, policy sandboxv1alpha1.QuotaPolicy) *sandboxv1alpha1.BatchSandbox {
+	return &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "quota", Name: name, UID: types.UID(name + "-uid")},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			Replicas:    ptr.To[int32](4),
+			QuotaPolicy: policy,
+			Template: &corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "main",
+						Image: "busybox",
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+						},
+					}},
+					// an init container asking for more than the main one sets the request
+					InitContainers: []corev1.Container{{
+						Name:  "init",
+						Image: "busybox",
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// cpuQuota leaves room for two of newQuotaBatchSandbox's shards.
+func cpuQuota() *corev1.ResourceQuota {
+	hard := corev1.ResourceList{
+		corev1.ResourcePods:        resource.MustParse("10"),
+		corev1.ResourceRequestsCPU: resource.MustParse("3"),
+	}
+	return &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "quota", Name: "compute"},
+		Spec:       corev1.ResourceQuotaSpec{Hard: hard},
+		Status: corev1.ResourceQuotaStatus{
+			Hard: hard,
+			Used: corev1.ResourceList{
+				corev1.ResourcePods:        resource.MustParse("1"),
+				corev1.ResourceRequestsCPU: resource.MustParse("1"),
+			},
+		},
+	}
+}
+
+func shardNames(t *testing.T, c client.Client) []string {
+	pods := &corev1.PodList{}
+	require.NoError(t, c.List(context.Background(), pods, client.InNamespace("quota")))
+	var names []string
+	for _, pod := range pods.Items {
+		names = append(names, pod.Name)
+	}
+	return names
+}
+
+func TestScaleBatchSandbox_QuotaBackfill(t *testing.T) {
+	batchSbx := newQuotaBatchSandbox("quota-backfill", sandboxv1alpha1.QuotaPolicyBackfill)
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(batchSbx, cpuQuota()).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &BatchSandboxReconciler{Client: c, Scheme: testscheme, Recorder: recorder}
+	key := types.NamespacedName{Namespace: "quota", Name: "quota-backfill"}.String()
+	defer DurationStore.Pop(key)
+
+	cond, err := r.scaleBatchSandbox(context.Background(), batchSbx, batchSbx.Spec.Template, strategy.NewTaskSchedulingStrategy(batchSbx), nil)
+	require.NoError(t, err, "a quota shortage backs off instead of failing the reconcile")
+	assert.ElementsMatch(t, []string{"quota-backfill-0", "quota-backfill-1"}, shardNames(t, c))
+	require.NotNil(t, cond)
+	assert.Equal(t, sandboxv1alpha1.BatchSandboxConditionQuotaAvailable, cond.Type)
+	assert.Equal(t, metav1.ConditionFalse, cond.Status)
+	assert.Equal(t, reasonInsufficientQuota, cond.Reason)
+	assert.Contains(t, cond.Message, "2 shard(s) waiting for quota")
+	assert.Contains(t, cond.Message, "ResourceQuota compute has 0 of requests.cpu left, a shard needs 1")
+	assert.Positive(t, DurationStore.Pop(key), "expected a retry to be scheduled")
+
+	var events []string
+	for len(recorder.Events) > 0 {
+		events = append(events, <-recorder.Events)
+	}
+	assert.Contains(t, events, "Warning InsufficientQuota 2 shard(s) waiting for quota: ResourceQuota compute has 0 of requests.cpu left, a shard needs 1")
+}
+
+func TestScaleBatchSandbox_QuotaAllOrNothing(t *testing.T) {
+	batchSbx := newQuotaBatchSandbox("quota-all", sandboxv1alpha1.QuotaPolicyAllOrNothing)
+	quota := cpuQuota()
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(batchSbx, quota).WithStatusSubresource(quota).Build()
+	r := &BatchSandboxReconciler{Client: c, Scheme: testscheme, Recorder: record.NewFakeRecorder(10)}
+	key := types.NamespacedName{Namespace: "quota", Name: "quota-all"}.String()
+	defer DurationStore.Pop(key)
+
+	cond, err := r.scaleBatchSandbox(context.Background(), batchSbx, batchSbx.Spec.Template, strategy.NewTaskSchedulingStrategy(batchSbx), nil)
+	require.NoError(t, err)
+	assert.Empty(t, shardNames(t, c), "no shard is created until all of them fit")
+	require.NotNil(t, cond)
+	assert.Equal(t, metav1.ConditionFalse, cond.Status)
+	assert.Contains(t, cond.Message, "4 shard(s) waiting for quota")
+
+	// once the quota is raised every shard is created and the condition clears
+	quota.Status.Hard[corev1.ResourceRequestsCPU] = resource.MustParse("8")
+	require.NoError(t, c.Status().Update(context.Background(), quota))
+	batchSbx.Status.Conditions = []metav1.Condition{*cond}
+	cond, err = r.scaleBatchSandbox(context.Background(), batchSbx, batchSbx.Spec.Template, strategy.NewTaskSchedulingStrategy(batchSbx), nil)
+	require.NoError(t, err)
+	assert.Len(t, shardNames(t, c), 4)
+	require.NotNil(t, cond)
+	assert.Equal(t, metav1.ConditionTrue, cond.Status)
+}
+
+func TestPodQuotaUsage(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m"), corev1.ResourceMemory: resource.MustParse("64Mi")},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi"), "nvidia.com/gpu": resource.MustParse("1")},
+			}},
+			{Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m"), "nvidia.com/gpu": resource.MustParse("1")},
+			}},
+		},
+		Overhead: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+	}}
+	usage := podQuotaUsage(pod)
+	for name, want := range map[corev1.ResourceName]string{
+		corev1.ResourcePods:           "1",
+		"count/pods":                  "1",
+		corev1.ResourceCPU:            "600m",
+		corev1.ResourceRequestsCPU:    "600m",
+		corev1.ResourceRequestsMemory: "64Mi",
+		corev1.ResourceLimitsMemory:   "128Mi",
+		"requests.nvidia.com/gpu":     "1",
+	} {
+		got := usage[name]
+		assert.Zero(t, got.Cmp(resource.MustParse(want)), "%s: got %s, want %s", name, got.String(), want)
+	}
+	assert.NotContains(t, usage, corev1.ResourceName("limits.nvidia.com/gpu"))
+}
@@ -0,0 +1,128 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/controller/strategy"
+	taskscheduler "github.com/alibaba/OpenSandbox/sandbox-k8s/internal/scheduler"
+)
+
+const (
+	reasonTaskUpdating              = "TaskUpdating"
+	reasonInvalidTaskUpdateStrategy = "InvalidTaskUpdateStrategy"
+)
+
+// syncTaskSpecs hands the task scheduler the task specs of the current spec
+// whenever the BatchSandbox's generation changed since they were generated.
+// Shards assigned afterwards get them; running shards whose task differs
+// report outdated until rollOutTaskUpdates, or a new Pod, replaces them.
+func (r *BatchSandboxReconciler) syncTaskSpecs(batchSbx *sandboxv1alpha1.BatchSandbox, tSch taskscheduler.TaskScheduler, taskStrategy strategy.TaskSchedulingStrategy) error {
+	key := types.NamespacedName{Namespace: batchSbx.Namespace, Name: batchSbx.Name}.String()
+	if generation, ok := r.taskSpecGenerations.Load(key); ok && generation == batchSbx.Generation {
+		return nil
+	}
+	taskSpecs, err := taskStrategy.GenerateTaskSpecs()
+	if err != nil {
+		return fmt.Errorf("failed to generate task specs: %w", err)
+	}
+	tSch.UpdateTaskSpecs(taskSpecs)
+	r.taskSpecGenerations.Store(key, batchSbx.Generation)
+	return nil
+}
+
+// taskMaxUnavailable resolves RollingUpdate.MaxUnavailable against replicas:
+// 1 when unset, at least 1 otherwise.
+func taskMaxUnavailable(update *sandboxv1alpha1.TaskUpdateStrategy, replicas int) (int, error) {
+	if update.RollingUpdate == nil || update.RollingUpdate.MaxUnavailable == nil {
+		return 1, nil
+	}
+	scaled, err := intstr.GetScaledValueFromIntOrPercent(update.RollingUpdate.MaxUnavailable, replicas, false)
+	if err != nil {
+		return 0, fmt.Errorf("maxUnavailable: %w", err)
+	}
+	if scaled < 0 {
+		return 0, fmt.Errorf("maxUnavailable must not be negative")
+	}
+	return max(scaled, 1), nil
+}
+
+// rollOutTaskUpdates replaces outdated shards under the RollingUpdate
+// strategy, in index order, keeping at most maxUnavailable assigned shards
+// unavailable: being replaced, draining, or running but not ready yet. An
+// outdated shard that is not ready is already unavailable and is replaced
+// without waiting. A suspended or completed batch is left alone.
+func (r *BatchSandboxReconciler) rollOutTaskUpdates(batchSbx *sandboxv1alpha1.BatchSandbox, tSch taskscheduler.TaskScheduler) {
+	update := batchSbx.Spec.TaskUpdateStrategy
+	if update == nil || update.Type != sandboxv1alpha1.TaskUpdateStrategyRollingUpdate || batchSbx.Spec.Suspend {
+		return
+	}
+	if completion := batchSbx.Status.TaskCompletion; completion != nil && completion.Phase != sandboxv1alpha1.TaskCompletionRunning {
+		return
+	}
+	tasks := tSch.ListTask()
+	maxUnavailable, err := taskMaxUnavailable(update, len(tasks))
+	if err != nil {
+		r.Recorder.Eventf(batchSbx, corev1.EventTypeWarning, reasonInvalidTaskUpdateStrategy, "invalid task update strategy: %v", err)
+		return
+	}
+	unavailable := 0
+	var outdated []int
+	for i, task := range tasks {
+		if task.GetPodName() == "" {
+			continue
+		}
+		state := task.GetState()
+		if state == taskscheduler.SucceedTaskState || state == taskscheduler.FailedTaskState {
+			continue
+		}
+		ready := task.IsReady() && !task.IsDraining() && !task.IsUpdating()
+		if !ready {
+			unavailable++
+		}
+		if !task.IsOutdated() || task.IsUpdating() || task.IsDraining() {
+			continue
+		}
+		if !ready {
+			r.replaceShardTask(batchSbx, tSch, i, task)
+			continue
+		}
+		outdated = append(outdated, i)
+	}
+	for n, i := range outdated {
+		if unavailable >= maxUnavailable {
+			klog.Infof("BatchSandbox %s has %d shards unavailable, %d outdated shards wait to be replaced", klog.KObj(batchSbx), unavailable, len(outdated)-n)
+			return
+		}
+		if r.replaceShardTask(batchSbx, tSch, i, tasks[i]) {
+			unavailable++
+		}
+	}
+}
+
+func (r *BatchSandboxReconciler) replaceShardTask(batchSbx *sandboxv1alpha1.BatchSandbox, tSch taskscheduler.TaskScheduler, idx int, task taskscheduler.Task) bool {
+	if !tSch.ReplaceTask(task.GetName()) {
+		return false
+	}
+	r.Recorder.Eventf(batchSbx, corev1.EventTypeNormal, reasonTaskUpdating, "replacing the outdated task of shard %d on pod %s", idx, task.GetPodName())
+	return true
+}
@@ -0,0 +1,171 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/controller/strategy"
+	taskscheduler "github.com/alibaba/OpenSandbox/sandbox-k8s/internal/scheduler"
+	mock_scheduler "github.com/alibaba/OpenSandbox/sandbox-k8s/internal/scheduler/mock"
+)
+
+// rolloutShard is the state of one shard's task in a rollout round.
+type rolloutShard struct {
+	ready, outdated, updating bool
+}
+
+func newRolloutTasks(ctrl *gomock.Controller, shards ...rolloutShard) []taskscheduler.Task {
+	tasks := make([]taskscheduler.Task, len(shards))
+	for i, shard := range shards {
+		task := mock_scheduler.NewMockTask(ctrl)
+		task.EXPECT().GetName().Return(fmt.Sprintf("rollout-%d", i)).AnyTimes()
+		task.EXPECT().GetPodName().Return(fmt.Sprintf("rollout-%d", i)).AnyTimes()
+		task.EXPECT().GetState().Return(taskscheduler.RunningTaskState).AnyTimes()
+		task.EXPECT().IsReady().Return(shard.ready).AnyTimes()
+		task.EXPECT().IsDraining().Return(false).AnyTimes()
+		task.EXPECT().IsOutdated().Return(shard.outdated).AnyTimes()
+		task.EXPECT().IsUpdating().Return(shard.updating).AnyTimes()
+		task.EXPECT().IsResourceReleased().Return(false).AnyTimes()
+		tasks[i] = task
+	}
+	return tasks
+}
+
+func TestRollOutTaskUpdates_ReplacesWithinMaxUnavailable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	batchSbx := &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "rollout", Name: "rollout"},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			Replicas: ptr.To[int32](5),
+			TaskUpdateStrategy: &sandboxv1alpha1.TaskUpdateStrategy{
+				Type:          sandboxv1alpha1.TaskUpdateStrategyRollingUpdate,
+				RollingUpdate: &sandboxv1alpha1.RollingUpdateTaskStrategy{MaxUnavailable: ptr.To(intstr.FromInt32(2))},
+			},
+		},
+	}
+	recorder := record.NewFakeRecorder(10)
+	r := &BatchSandboxReconciler{Recorder: recorder}
+	var (
+		current  = rolloutShard{ready: true}
+		outdated = rolloutShard{ready: true, outdated: true}
+		updating = rolloutShard{outdated: true, updating: true}
+		starting = rolloutShard{}
+		crashed  = rolloutShard{outdated: true}
+	)
+	// round runs rollOutTaskUpdates over shards, expecting exactly the named
+	// tasks to be replaced.
+	round := func(replaced []string, shards ...rolloutShard) {
+		t.Helper()
+		sch := mock_scheduler.NewMockTaskScheduler(ctrl)
+		sch.EXPECT().ListTask().Return(newRolloutTasks(ctrl, shards...)).Times(1)
+		for _, name := range replaced {
+			sch.EXPECT().ReplaceTask(name).Return(true).Times(1)
+		}
+		r.rollOutTaskUpdates(batchSbx, sch)
+		require.Len(t, recorder.Events, len(replaced))
+		for range replaced {
+			assert.Contains(t, <-recorder.Events, "Normal TaskUpdating")
+		}
+	}
+
+	// the template changed: two of the five shards are replaced first
+	round([]string{"rollout-0", "rollout-1"}, outdated, outdated, outdated, outdated, outdated)
+	// no further shard while both are being replaced
+	round(nil, updating, updating, outdated, outdated, outdated)
+	// one replaced shard is still starting, so one more may go
+	round([]string{"rollout-2"}, current, starting, outdated, outdated, outdated)
+	// an outdated shard that is unavailable anyway is replaced beyond the bound
+	round([]string{"rollout-4"}, current, starting, updating, outdated, crashed)
+	round([]string{"rollout-3"}, current, current, current, outdated, updating)
+	round(nil, current, current, current, current, current)
+}
+
+func TestRollOutTaskUpdates_OnDelete(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	r := &BatchSandboxReconciler{Recorder: record.NewFakeRecorder(10)}
+	for _, update := range []*sandboxv1alpha1.TaskUpdateStrategy{nil, {Type: sandboxv1alpha1.TaskUpdateStrategyOnDelete}} {
+		batchSbx := &sandboxv1alpha1.BatchSandbox{Spec: sandboxv1alpha1.BatchSandboxSpec{TaskUpdateStrategy: update}}
+		// the mock fails the test on any call
+		r.rollOutTaskUpdates(batchSbx, mock_scheduler.NewMockTaskScheduler(ctrl))
+	}
+}
+
+func TestTaskMaxUnavailable(t *testing.T) {
+	rolling := func(value intstr.IntOrString) *sandboxv1alpha1.TaskUpdateStrategy {
+		return &sandboxv1alpha1.TaskUpdateStrategy{
+			Type:          sandboxv1alpha1.TaskUpdateStrategyRollingUpdate,
+			RollingUpdate: &sandboxv1alpha1.RollingUpdateTaskStrategy{MaxUnavailable: &value},
+		}
+	}
+	for _, tc := range []struct {
+		update *sandboxv1alpha1.TaskUpdateStrategy
+		want   int
+	}{
+		{&sandboxv1alpha1.TaskUpdateStrategy{Type: sandboxv1alpha1.TaskUpdateStrategyRollingUpdate}, 1},
+		{rolling(intstr.FromInt32(3)), 3},
+		{rolling(intstr.FromString("50%")), 5},
+		{rolling(intstr.FromString("5%")), 1},
+		{rolling(intstr.FromInt32(0)), 1},
+	} {
+		got, err := taskMaxUnavailable(tc.update, 11)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, got)
+	}
+	_, err := taskMaxUnavailable(rolling(intstr.FromInt32(-1)), 11)
+	assert.Error(t, err)
+	_, err = taskMaxUnavailable(rolling(intstr.FromString("half")), 11)
+	assert.Error(t, err)
+}
+
+func TestScheduleTasks_ReportsRolloutProgress(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	batchSbx := &sandboxv1alpha1.BatchSandbox{ObjectMeta: metav1.ObjectMeta{Name: "rollout-status"}}
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(batchSbx).WithStatusSubresource(batchSbx).Build()
+	r := &BatchSandboxReconciler{Client: c, Recorder: record.NewFakeRecorder(10)}
+
+	tasks := newRolloutTasks(ctrl, rolloutShard{ready: true}, rolloutShard{outdated: true, updating: true}, rolloutShard{ready: true, outdated: true})
+	sch := mock_scheduler.NewMockTaskScheduler(ctrl)
+	sch.EXPECT().ListTask().Return(tasks).Times(2)
+	sch.EXPECT().SetAdmitted(-1).Times(1)
+	sch.EXPECT().Schedule().Return(nil).Times(1)
+	require.NoError(t, r.scheduleTasks(context.Background(), sch, batchSbx, strategy.NewTaskSchedulingStrategy(batchSbx)))
+
+	got := &sandboxv1alpha1.BatchSandbox{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(batchSbx), got))
+	assert.Equal(t, int32(1), got.Status.TaskUpdated)
+	require.Len(t, got.Status.TaskShards, 3)
+	assert.False(t, got.Status.TaskShards[0].Outdated)
+	assert.True(t, got.Status.TaskShards[1].Outdated)
+	assert.True(t, got.Status.TaskShards[1].Updating)
+	assert.True(t, got.Status.TaskShards[2].Outdated)
+	assert.False(t, got.Status.TaskShards[2].Updating)
+}
@@ -0,0 +1,157 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+)
+
+// shardClaimName names shard idx's claim from a volume claim template, the
+// same way a StatefulSet names its Pods' claims.
+func shardClaimName(template string, batchSandbox *sandboxv1alpha1.BatchSandbox, idx int) string {
+	return fmt.Sprintf("%s-%s-%d", template, batchSandbox.Name, idx)
+}
+
+// validateVolumeClaimTemplates rejects templates that cannot be instantiated
+// per shard, or that no container of the pod template mounts: the claim only
+// replaces the volume, so a template nobody mounts would go unused.
+func validateVolumeClaimTemplates(batchSandbox *sandboxv1alpha1.BatchSandbox) error {
+	templates := batchSandbox.Spec.VolumeClaimTemplates
+	if len(templates) == 0 {
+		return nil
+	}
+	if batchSandbox.Spec.PoolRef != "" {
+		return fmt.Errorf("volumeClaimTemplates are not supported with poolRef")
+	}
+	lastIdx := 0
+	if batchSandbox.Spec.Replicas != nil && *batchSandbox.Spec.Replicas > 0 {
+		lastIdx = int(*batchSandbox.Spec.Replicas) - 1
+	}
+	mounted := map[string]bool{}
+	if batchSandbox.Spec.Template != nil {
+		spec := &batchSandbox.Spec.Template.Spec
+		for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+			for _, container := range containers {
+				for _, mount := range container.VolumeMounts {
+					mounted[mount.Name] = true
+				}
+			}
+		}
+	}
+	seen := map[string]bool{}
+	for i := range templates {
+		name := templates[i].Name
+		if name == "" {
+			return fmt.Errorf("volumeClaimTemplates[%d] has no name", i)
+		}
+		if seen[name] {
+			return fmt.Errorf("volumeClaimTemplates has duplicate name %q", name)
+		}
+		seen[name] = true
+		if !mounted[name] {
+			return fmt.Errorf("volumeClaimTemplates[%d] (%s) is not mounted by any container of the pod template, add a volumeMount named %q", i, name, name)
+		}
+		// the highest index is the longest claim name
+		last := shardClaimName(name, batchSandbox, lastIdx)
+		if errs := validation.IsDNS1123Subdomain(last); len(errs) > 0 {
+			return fmt.Errorf("volumeClaimTemplates[%d] gives invalid claim name %q: %v", i, last, errs)
+		}
+	}
+	return nil
+}
+
+// newShardClaims instantiates the volume claim templates for shard idx. Under
+// the Delete retention policy the claims are owned by the BatchSandbox.
+func (r *BatchSandboxReconciler) newShardClaims(batchSandbox *sandboxv1alpha1.BatchSandbox, idx int) ([]*corev1.PersistentVolumeClaim, error) {
+	claims := make([]*corev1.PersistentVolumeClaim, 0, len(batchSandbox.Spec.VolumeClaimTemplates))
+	for i := range batchSandbox.Spec.VolumeClaimTemplates {
+		template := &batchSandbox.Spec.VolumeClaimTemplates[i]
+		claim := &corev1.PersistentVolumeClaim{}
+		claim.Name = shardClaimName(template.Name, batchSandbox, idx)
+		claim.Namespace = batchSandbox.Namespace
+		claim.Labels = map[string]string{}
+		for k, v := range template.Labels {
+			claim.Labels[k] = v
+		}
+		claim.Labels[LabelBatchSandboxPodIndexKey] = strconv.Itoa(idx)
+		if len(template.Annotations) > 0 {
+			claim.Annotations = map[string]string{}
+			for k, v := range template.Annotations {
+				claim.Annotations[k] = v
+			}
+		}
+		claim.Spec = *template.Spec.DeepCopy()
+		if batchSandbox.Spec.VolumeClaimRetentionPolicy != sandboxv1alpha1.VolumeClaimRetentionPolicyRetain {
+			if err := ctrl.SetControllerReference(batchSandbox, claim, r.Scheme); err != nil {
+				return nil, err
+			}
+		}
+		claims = append(claims, claim)
+	}
+	return claims, nil
+}
+
+// ensureShardClaims creates shard idx's claims. A claim that already exists,
+// left by an earlier Pod of the shard or a retained BatchSandbox, is reused.
+func (r *BatchSandboxReconciler) ensureShardClaims(ctx context.Context, batchSandbox *sandboxv1alpha1.BatchSandbox, idx int) error {
+	claims, err := r.newShardClaims(batchSandbox, idx)
+	if err != nil {
+		return err
+	}
+	for _, claim := range claims {
+		if err := r.Create(ctx, claim); err != nil {
+			if errors.IsAlreadyExists(err) {
+				continue
+			}
+			r.Recorder.Eventf(batchSandbox, corev1.EventTypeWarning, "FailedCreate", "failed to create persistent volume claim %s: %v", claim.Name, err)
+			return err
+		}
+		klog.Infof("BatchSandbox %s created persistent volume claim %s for shard %d", klog.KObj(batchSandbox), claim.Name, idx)
+	}
+	return nil
+}
+
+// applyShardClaims points the Pod's volumes named after a volume claim
+// template at shard idx's claim, adding the volume when the Pod template does
+// not declare it. Containers mount it through their own volumeMounts, which
+// validateVolumeClaimTemplates requires.
+func applyShardClaims(pod *corev1.Pod, batchSandbox *sandboxv1alpha1.BatchSandbox, idx int) {
+	for i := range batchSandbox.Spec.VolumeClaimTemplates {
+		name := batchSandbox.Spec.VolumeClaimTemplates[i].Name
+		source := corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: shardClaimName(name, batchSandbox, idx),
+		}}
+		replaced := false
+		for j := range pod.Spec.Volumes {
+			if pod.Spec.Volumes[j].Name == name {
+				pod.Spec.Volumes[j].VolumeSource = source
+				replaced = true
+			}
+		}
+		if !replaced {
+			pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{Name: name, VolumeSource: source})
+		}
+	}
+}
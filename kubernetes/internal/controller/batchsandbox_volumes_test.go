@@ -0,0 +1,153 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/controller/strategy"
+)
+
+func newVolumeClaimBatchSandbox(retention sandboxv1alpha1.VolumeClaimRetentionPolicy) *sandboxv1alpha1.BatchSandbox {
+	claimSpec := corev1.PersistentVolumeClaimSpec{
+		AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+		Resources: corev1.VolumeResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+		},
+	}
+	return &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "shards", UID: "shards-uid"},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			Replicas: ptr.To[int32](2),
+			Template: &corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "shards"}},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "main",
+						Image: "busybox",
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: "data", MountPath: "/data"},
+							{Name: "cache", MountPath: "/cache"},
+						},
+					}},
+					Volumes: []corev1.Volume{
+						{Name: "data", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+					},
+				},
+			},
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+				{ObjectMeta: metav1.ObjectMeta{Name: "data", Labels: map[string]string{"tier": "hot"}}, Spec: claimSpec},
+				{ObjectMeta: metav1.ObjectMeta{Name: "cache"}, Spec: claimSpec},
+			},
+			VolumeClaimRetentionPolicy: retention,
+		},
+	}
+}
+
+func TestScaleBatchSandbox_VolumeClaimTemplates(t *testing.T) {
+	batchSbx := newVolumeClaimBatchSandbox(sandboxv1alpha1.VolumeClaimRetentionPolicyDelete)
+	// shard 1's data claim survived an earlier Pod and must be reused
+	leftover := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "data-shards-1"}}
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(batchSbx, leftover).Build()
+	r := &BatchSandboxReconciler{Client: c, Scheme: testscheme, Recorder: record.NewFakeRecorder(10)}
+
+	_, err := r.scaleBatchSandbox(context.Background(), batchSbx, batchSbx.Spec.Template, strategy.NewTaskSchedulingStrategy(batchSbx), nil)
+	assert.NoError(t, err)
+
+	for idx, want := range map[string][]string{
+		"shards-0": {"data-shards-0", "cache-shards-0"},
+		"shards-1": {"data-shards-1", "cache-shards-1"},
+	} {
+		pod := &corev1.Pod{}
+		assert.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: idx}, pod))
+		claims := map[string]string{}
+		for _, v := range pod.Spec.Volumes {
+			if assert.NotNil(t, v.PersistentVolumeClaim, "volume %s of %s", v.Name, idx) {
+				claims[v.Name] = v.PersistentVolumeClaim.ClaimName
+			}
+		}
+		assert.Equal(t, map[string]string{"data": want[0], "cache": want[1]}, claims, "volumes of %s", idx)
+		assert.Equal(t, []corev1.VolumeMount{{Name: "data", MountPath: "/data"}, {Name: "cache", MountPath: "/cache"}}, pod.Spec.Containers[0].VolumeMounts)
+	}
+
+	claim := &corev1.PersistentVolumeClaim{}
+	assert.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "data-shards-0"}, claim))
+	assert.Equal(t, "hot", claim.Labels["tier"])
+	assert.Equal(t, "0", claim.Labels[LabelBatchSandboxPodIndexKey])
+	assert.Equal(t, resource.MustParse("1Gi"), claim.Spec.Resources.Requests[corev1.ResourceStorage])
+	if assert.Len(t, claim.OwnerReferences, 1) {
+		assert.Equal(t, batchSbx.UID, claim.OwnerReferences[0].UID)
+	}
+	assert.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "data-shards-1"}, claim))
+	assert.Empty(t, claim.OwnerReferences, "an existing claim is reused untouched")
+}
+
+func TestNewShardClaims_RetainPolicy(t *testing.T) {
+	batchSbx := newVolumeClaimBatchSandbox(sandboxv1alpha1.VolumeClaimRetentionPolicyRetain)
+	r := &BatchSandboxReconciler{Scheme: testscheme}
+	claims, err := r.newShardClaims(batchSbx, 3)
+	assert.NoError(t, err)
+	if assert.Len(t, claims, 2) {
+		assert.Equal(t, "data-shards-3", claims[0].Name)
+		assert.Equal(t, "cache-shards-3", claims[1].Name)
+		assert.Empty(t, claims[0].OwnerReferences)
+		assert.Empty(t, claims[1].OwnerReferences)
+	}
+}
+
+func TestValidateVolumeClaimTemplates(t *testing.T) {
+	valid := newVolumeClaimBatchSandbox("")
+	assert.NoError(t, validateVolumeClaimTemplates(valid))
+
+	pooled := valid.DeepCopy()
+	pooled.Spec.PoolRef = "pool"
+	assert.Error(t, validateVolumeClaimTemplates(pooled))
+
+	duplicate := valid.DeepCopy()
+	duplicate.Spec.VolumeClaimTemplates[1].Name = "data"
+	assert.Error(t, validateVolumeClaimTemplates(duplicate))
+
+	unnamed := valid.DeepCopy()
+	unnamed.Spec.VolumeClaimTemplates[0].Name = ""
+	assert.Error(t, validateVolumeClaimTemplates(unnamed))
+
+	invalid := valid.DeepCopy()
+	invalid.Spec.VolumeClaimTemplates[0].Name = "Data_Dir"
+	invalid.Spec.Template.Spec.Containers[0].VolumeMounts[0].Name = "Data_Dir"
+	assert.ErrorContains(t, validateVolumeClaimTemplates(invalid), "invalid claim name")
+
+	unmounted := valid.DeepCopy()
+	unmounted.Spec.Template.Spec.Containers[0].VolumeMounts = unmounted.Spec.Template.Spec.Containers[0].VolumeMounts[:1]
+	assert.ErrorContains(t, validateVolumeClaimTemplates(unmounted), "(cache) is not mounted")
+
+	initMounted := unmounted.DeepCopy()
+	initMounted.Spec.Template.Spec.InitContainers = []corev1.Container{{
+		Name:         "restore",
+		Image:        "busybox",
+		VolumeMounts: []corev1.VolumeMount{{Name: "cache", MountPath: "/cache"}},
+	}}
+	assert.NoError(t, validateVolumeClaimTemplates(initMounted), "an init container's mount counts")
+}
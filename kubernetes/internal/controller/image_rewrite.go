@@ -0,0 +1,114 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ImageRewriter replaces registry prefixes of the images in the Pods and Jobs
+// a BatchSandbox's Template produces, so one BatchSandbox can pull from a
+// different registry or mirror in each cluster. Only images that come from
+// the Template are rewritten: an image a ShardPatch sets is used as written.
+type ImageRewriter struct {
+	rules []imageRewriteRule // longest prefix first
+}
+
+type imageRewriteRule struct {
+	from, to string
+}
+
+// NewImageRewriter parses comma-separated from=to prefix rules, e.g.
+// "docker.io/=mirror.example.com/dockerhub/". Prefixes are matched against the
+// image as written, and the longest matching one wins. It returns nil, i.e.
+// no rewriting, when rules is empty.
+func NewImageRewriter(rules string) (*ImageRewriter, error) {
+	w := &ImageRewriter{}
+	seen := map[string]bool{}
+	for _, rule := range strings.Split(rules, ",") {
+		if rule = strings.TrimSpace(rule); rule == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(rule, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" {
+			return nil, fmt.Errorf("invalid image rewrite %q: want <prefix>=<replacement>", rule)
+		}
+		if seen[from] {
+			return nil, fmt.Errorf("duplicate image rewrite for prefix %q", from)
+		}
+		seen[from] = true
+		w.rules = append(w.rules, imageRewriteRule{from: from, to: to})
+	}
+	if len(w.rules) == 0 {
+		return nil, nil
+	}
+	sort.SliceStable(w.rules, func(i, j int) bool { return len(w.rules[i].from) > len(w.rules[j].from) })
+	return w, nil
+}
+
+// Rewrite returns image with its longest matching prefix replaced.
+func (w *ImageRewriter) Rewrite(image string) string {
+	if w == nil {
+		return image
+	}
+	for _, rule := range w.rules {
+		if strings.HasPrefix(image, rule.from) {
+			return rule.to + strings.TrimPrefix(image, rule.from)
+		}
+	}
+	return image
+}
+
+// RewriteTemplate returns template with the images of its containers and init
+// containers rewritten, copying it only when something changes.
+func (w *ImageRewriter) RewriteTemplate(template *corev1.PodTemplateSpec) *corev1.PodTemplateSpec {
+	if w == nil || template == nil {
+		return template
+	}
+	var out *corev1.PodTemplateSpec
+	rewrite := func(containers func(*corev1.PodTemplateSpec) []corev1.Container) {
+		for i, c := range containers(template) {
+			if image := w.Rewrite(c.Image); image != c.Image {
+				if out == nil {
+					out = template.DeepCopy()
+				}
+				containers(out)[i].Image = image
+			}
+		}
+	}
+	rewrite(func(t *corev1.PodTemplateSpec) []corev1.Container { return t.Spec.InitContainers })
+	rewrite(func(t *corev1.PodTemplateSpec) []corev1.Container { return t.Spec.Containers })
+	if out == nil {
+		return template
+	}
+	return out
+}
+
+// String describes the rules for logging.
+func (w *ImageRewriter) String() string {
+	if w == nil {
+		return "none"
+	}
+	parts := make([]string, 0, len(w.rules))
+	for _, rule := range w.rules {
+		parts = append(parts, rule.from+"="+rule.to)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,115 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/controller/strategy"
+)
+
+func TestImageRewriter_Rewrite(t *testing.T) {
+	w, err := NewImageRewriter(" docker.io/=mirror.example.com/hub/, docker.io/library/=mirror.example.com/official/,ghcr.io/org/=")
+	require.NoError(t, err)
+	for image, want := range map[string]string{
+		"docker.io/library/python:3.12": "mirror.example.com/official/python:3.12",
+		"docker.io/bitnami/redis:7":     "mirror.example.com/hub/bitnami/redis:7",
+		"ghcr.io/org/tool:v1":           "tool:v1",
+		"quay.io/other/img":             "quay.io/other/img",
+		"python:3.12":                   "python:3.12",
+	} {
+		assert.Equal(t, want, w.Rewrite(image), "image %s", image)
+	}
+
+	none, err := NewImageRewriter(" , ")
+	require.NoError(t, err)
+	assert.Nil(t, none)
+	assert.Equal(t, "busybox", none.Rewrite("busybox"))
+	assert.Equal(t, "none", none.String())
+
+	for _, bad := range []string{"docker.io/", "=mirror/", "a/=b/,a/=c/"} {
+		_, err := NewImageRewriter(bad)
+		assert.Error(t, err, "rules %q", bad)
+	}
+}
+
+func TestImageRewriter_RewriteTemplate(t *testing.T) {
+	w, err := NewImageRewriter("registry.dev/=registry.prod/")
+	require.NoError(t, err)
+	template := &corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "init", Image: "registry.dev/init:1"}},
+		Containers:     []corev1.Container{{Name: "main", Image: "registry.dev/app:1"}, {Name: "side", Image: "busybox"}},
+	}}
+
+	rewritten := w.RewriteTemplate(template)
+	assert.Equal(t, "registry.prod/init:1", rewritten.Spec.InitContainers[0].Image)
+	assert.Equal(t, "registry.prod/app:1", rewritten.Spec.Containers[0].Image)
+	assert.Equal(t, "busybox", rewritten.Spec.Containers[1].Image)
+	assert.Equal(t, "registry.dev/app:1", template.Spec.Containers[0].Image, "the BatchSandbox's own template is left alone")
+
+	untouched := &corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: "busybox"}}}}
+	assert.Same(t, untouched, w.RewriteTemplate(untouched))
+}
+
+func TestScaleBatchSandbox_ImageRewriteYieldsToShardPatches(t *testing.T) {
+	batchSbx := &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "images", UID: "images-uid"},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			Replicas: ptr.To[int32](3),
+			Template: &corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "images"}},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "main", Image: "registry.dev/app:1"},
+					{Name: "side", Image: "registry.dev/side:1"},
+				}},
+			},
+			ShardPatches: []runtime.RawExtension{
+				{Raw: []byte(`{}`)},
+				// an explicit image wins over the rewrite, even one the rewrite would match
+				{Raw: []byte(`{"spec":{"containers":[{"name":"main","image":"registry.dev/app:canary"}]}}`)},
+			},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(batchSbx).Build()
+	rewriter, err := NewImageRewriter("registry.dev/=registry.prod/")
+	require.NoError(t, err)
+	r := &BatchSandboxReconciler{Client: c, Scheme: testscheme, Recorder: record.NewFakeRecorder(10), ImageRewriter: rewriter}
+
+	_, err = r.scaleBatchSandbox(context.Background(), batchSbx, batchSbx.Spec.Template, strategy.NewTaskSchedulingStrategy(batchSbx), nil)
+	require.NoError(t, err)
+
+	for name, want := range map[string][]string{
+		"images-0": {"registry.prod/app:1", "registry.prod/side:1"},
+		"images-1": {"registry.dev/app:canary", "registry.prod/side:1"},
+		"images-2": {"registry.prod/app:1", "registry.prod/side:1"},
+	} {
+		pod := &corev1.Pod{}
+		require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: name}, pod))
+		assert.Equal(t, want, []string{pod.Spec.Containers[0].Image, pod.Spec.Containers[1].Image}, "images of %s", name)
+	}
+	assert.Equal(t, "registry.dev/app:1", batchSbx.Spec.Template.Spec.Containers[0].Image)
+}
@@ -0,0 +1,109 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+
+// NamespaceFilter limits a shared controller to the namespaces it is
+// responsible for: those listed by name, plus those whose labels match the
+// selector. Events for objects elsewhere are dropped before they are queued.
+type NamespaceFilter struct {
+	names    sets.Set[string]
+	selector labels.Selector
+	reader   client.Reader
+}
+
+// NewNamespaceFilter builds a filter from a comma-separated namespace list and
+// a label selector, reading namespace labels through reader. It returns nil,
+// i.e. every namespace, when both are empty.
+func NewNamespaceFilter(reader client.Reader, namespaces, selector string) (*NamespaceFilter, error) {
+	f := &NamespaceFilter{names: sets.New[string](), reader: reader}
+	for _, ns := range strings.Split(namespaces, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			f.names.Insert(ns)
+		}
+	}
+	if selector = strings.TrimSpace(selector); selector != "" {
+		parsed, err := labels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace selector %q: %w", selector, err)
+		}
+		f.selector = parsed
+	}
+	if f.names.Len() == 0 && f.selector == nil {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// Matches reports whether objects in namespace ns are reconciled. A namespace
+// that cannot be read is skipped; it is matched again on the object's next event.
+func (f *NamespaceFilter) Matches(ctx context.Context, ns string) bool {
+	if f == nil || f.names.Has(ns) {
+		return true
+	}
+	if f.selector == nil {
+		return false
+	}
+	namespace := &corev1.Namespace{}
+	if err := f.reader.Get(ctx, types.NamespacedName{Name: ns}, namespace); err != nil {
+		klog.ErrorS(err, "failed to get namespace for namespace filter", "namespace", ns)
+		return false
+	}
+	return f.selector.Matches(labels.Set(namespace.Labels))
+}
+
+// Predicate filters watch events by the namespace of their object. An object
+// being deleted passes anyway while it holds the task cleanup finalizer, so
+// one whose namespace left the scope is not stuck terminating on a finalizer
+// only this controller removes.
+func (f *NamespaceFilter) Predicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		if obj.GetDeletionTimestamp() != nil && controllerutil.ContainsFinalizer(obj, FinalizerTaskCleanup) {
+			return true
+		}
+		return f.Matches(context.TODO(), obj.GetNamespace())
+	})
+}
+
+// String describes the filter for logging.
+func (f *NamespaceFilter) String() string {
+	if f == nil {
+		return "all namespaces"
+	}
+	parts := []string{}
+	if f.names.Len() > 0 {
+		parts = append(parts, "namespaces="+strings.Join(sets.List(f.names), ","))
+	}
+	if f.selector != nil {
+		parts = append(parts, "selector="+f.selector.String())
+	}
+	return strings.Join(parts, " or ")
+}
@@ -0,0 +1,95 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaceFilter(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"opensandbox.io/tenant": "a"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b", Labels: map[string]string{"opensandbox.io/tenant": "b"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shared"}},
+	).Build()
+
+	tests := []struct {
+		name       string
+		namespaces string
+		selector   string
+		want       map[string]bool
+	}{
+		{
+			name: "no scope reconciles everything",
+			want: map[string]bool{"team-a": true, "team-b": true, "shared": true, "unknown": true},
+		},
+		{
+			name:       "allowlist",
+			namespaces: "shared, team-b",
+			want:       map[string]bool{"team-a": false, "team-b": true, "shared": true, "unknown": false},
+		},
+		{
+			name:     "label selector",
+			selector: "opensandbox.io/tenant in (a)",
+			want:     map[string]bool{"team-a": true, "team-b": false, "shared": false, "unknown": false},
+		},
+		{
+			name:       "allowlist or selector",
+			namespaces: "shared",
+			selector:   "opensandbox.io/tenant=a",
+			want:       map[string]bool{"team-a": true, "team-b": false, "shared": true, "unknown": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewNamespaceFilter(c, tt.namespaces, tt.selector)
+			assert.NoError(t, err)
+			pred := filter.Predicate()
+			for ns, want := range tt.want {
+				bs := &sandboxv1alpha1.BatchSandbox{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "bs"}}
+				pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "pod"}}
+				assert.Equal(t, want, pred.Create(event.CreateEvent{Object: bs}), "create BatchSandbox in %s", ns)
+				assert.Equal(t, want, pred.Update(event.UpdateEvent{ObjectOld: pod, ObjectNew: pod}), "update Pod in %s", ns)
+				assert.Equal(t, want, pred.Delete(event.DeleteEvent{Object: bs}), "delete BatchSandbox in %s", ns)
+			}
+		})
+	}
+
+	// a BatchSandbox deleted after its namespace left the scope still has its
+	// finalizer removed
+	filter, err := NewNamespaceFilter(c, "shared", "")
+	assert.NoError(t, err)
+	terminating := &sandboxv1alpha1.BatchSandbox{ObjectMeta: metav1.ObjectMeta{
+		Namespace:         "team-a",
+		Name:              "bs",
+		DeletionTimestamp: &metav1.Time{Time: time.Now()},
+		Finalizers:        []string{FinalizerTaskCleanup},
+	}}
+	assert.True(t, filter.Predicate().Update(event.UpdateEvent{ObjectOld: terminating, ObjectNew: terminating}))
+	terminating.Finalizers = nil
+	assert.False(t, filter.Predicate().Update(event.UpdateEvent{ObjectOld: terminating, ObjectNew: terminating}))
+
+	_, err = NewNamespaceFilter(c, "", "tenant in (")
+	assert.Error(t, err)
+}
@@ -68,6 +68,8 @@ type PoolReconciler struct {
 	Scheme    *runtime.Scheme
 	Recorder  record.EventRecorder
 	Allocator Allocator
+	// NamespaceFilter, when set, restricts reconciliation to matching namespaces.
+	NamespaceFilter *NamespaceFilter
 }
 
 // +kubebuilder:rbac:groups=sandbox.opensandbox.io,resources=pools,verbs=get;list;watch;create;update;patch;delete
@@ -291,6 +293,7 @@ func (r *PoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			handler.EnqueueRequestsFromMapFunc(findPoolForBatchSandbox),
 			builder.WithPredicates(filterBatchSandbox),
 		).
+		WithEventFilter(r.NamespaceFilter.Predicate()).
 		Named("pool").
 		Complete(r)
 }
@@ -0,0 +1,100 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package strategy
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	taskscheduler "github.com/alibaba/OpenSandbox/sandbox-k8s/internal/scheduler"
+)
+
+// EvaluateTaskCompletion applies the task completion policy of batchSbx to the
+// current state of its tasks, returning nil when no policy is set. A final
+// phase already recorded in the status is kept. Tasks of an aborted batch that
+// were never assigned will not run, so they count as failed.
+func EvaluateTaskCompletion(batchSbx *sandboxv1alpha1.BatchSandbox, tasks []taskscheduler.Task, aborted bool, now time.Time) *sandboxv1alpha1.TaskCompletionStatus {
+	policy := batchSbx.Spec.TaskCompletionPolicy
+	if policy == nil {
+		return nil
+	}
+	if last := batchSbx.Status.TaskCompletion; last != nil && last.Phase != sandboxv1alpha1.TaskCompletionRunning {
+		return last.DeepCopy()
+	}
+	status := &sandboxv1alpha1.TaskCompletionStatus{Phase: sandboxv1alpha1.TaskCompletionRunning}
+	if len(tasks) == 0 {
+		return status
+	}
+	var succeeded, failed int
+	for _, task := range tasks {
+		if task.GetPodName() == "" {
+			if aborted {
+				failed++
+			}
+			continue
+		}
+		switch task.GetState() {
+		case taskscheduler.SucceedTaskState:
+			succeeded++
+		case taskscheduler.FailedTaskState:
+			failed++
+		}
+	}
+	required := requiredSucceeded(policy, len(tasks))
+	switch {
+	case succeeded >= required:
+		status.Phase = sandboxv1alpha1.TaskCompletionSucceeded
+		status.Message = fmt.Sprintf("%d of %d tasks succeeded, %d required", succeeded, len(tasks), required)
+	case len(tasks)-failed < required:
+		status.Phase = sandboxv1alpha1.TaskCompletionFailed
+		status.Message = fmt.Sprintf("%d of %d tasks failed, %d required to succeed", failed, len(tasks), required)
+	default:
+		return status
+	}
+	status.CompletionTime = &metav1.Time{Time: now}
+	return status
+}
+
+// requiredSucceeded is the number of the total tasks that have to succeed.
+func requiredSucceeded(policy *sandboxv1alpha1.TaskCompletionPolicySpec, total int) int {
+	switch policy.Type {
+	case sandboxv1alpha1.TaskCompletionPolicyAny:
+		return 1
+	case sandboxv1alpha1.TaskCompletionPolicyAtLeastN:
+		return int(policy.MinSucceeded)
+	default:
+		return total
+	}
+}
+
+// validateTaskCompletionPolicy checks that the policy can be met by the batch's replicas.
+func validateTaskCompletionPolicy(policy *sandboxv1alpha1.TaskCompletionPolicySpec, replicas int32) error {
+	if policy == nil {
+		return nil
+	}
+	switch policy.Type {
+	case "", sandboxv1alpha1.TaskCompletionPolicyAll, sandboxv1alpha1.TaskCompletionPolicyAny:
+	case sandboxv1alpha1.TaskCompletionPolicyAtLeastN:
+		if policy.MinSucceeded < 1 || policy.MinSucceeded > replicas {
+			return fmt.Errorf("batchsandbox: AtLeastN task completion policy needs minSucceeded between 1 and %d replicas, got %d", replicas, policy.MinSucceeded)
+		}
+	default:
+		return fmt.Errorf("batchsandbox: unknown task completion policy %q", policy.Type)
+	}
+	return nil
+}
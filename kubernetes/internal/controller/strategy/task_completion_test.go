@@ -0,0 +1,132 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package strategy
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/utils/ptr"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	taskscheduler "github.com/alibaba/OpenSandbox/sandbox-k8s/internal/scheduler"
+)
+
+// shardTasks builds one task per state; an empty state is a pending task.
+func shardTasks(states ...taskscheduler.TaskState) []taskscheduler.Task {
+	tasks := make([]taskscheduler.Task, len(states))
+	for i, state := range states {
+		task := &fakeTask{name: "shard", state: state}
+		if state != "" {
+			task.podName = "pod"
+		}
+		tasks[i] = task
+	}
+	return tasks
+}
+
+func TestEvaluateTaskCompletion(t *testing.T) {
+	const (
+		succeed = taskscheduler.SucceedTaskState
+		failed  = taskscheduler.FailedTaskState
+		running = taskscheduler.RunningTaskState
+		unknown = taskscheduler.UnknownTaskState
+		pending = taskscheduler.TaskState("")
+	)
+	all := &sandboxv1alpha1.TaskCompletionPolicySpec{Type: sandboxv1alpha1.TaskCompletionPolicyAll}
+	anyOne := &sandboxv1alpha1.TaskCompletionPolicySpec{Type: sandboxv1alpha1.TaskCompletionPolicyAny}
+	atLeast2 := &sandboxv1alpha1.TaskCompletionPolicySpec{Type: sandboxv1alpha1.TaskCompletionPolicyAtLeastN, MinSucceeded: 2}
+	tests := []struct {
+		name    string
+		policy  *sandboxv1alpha1.TaskCompletionPolicySpec
+		tasks   []taskscheduler.Task
+		aborted bool
+		want    sandboxv1alpha1.TaskCompletionPhase
+	}{
+		{name: "all: some still running", policy: all, tasks: shardTasks(succeed, running, pending), want: sandboxv1alpha1.TaskCompletionRunning},
+		{name: "all: every shard succeeded", policy: all, tasks: shardTasks(succeed, succeed, succeed), want: sandboxv1alpha1.TaskCompletionSucceeded},
+		{name: "all: one failure fails the batch", policy: all, tasks: shardTasks(succeed, failed, running), want: sandboxv1alpha1.TaskCompletionFailed},
+		{name: "all: defaults to all", policy: &sandboxv1alpha1.TaskCompletionPolicySpec{}, tasks: shardTasks(succeed, succeed), want: sandboxv1alpha1.TaskCompletionSucceeded},
+		{name: "any: failures while others run", policy: anyOne, tasks: shardTasks(failed, failed, running), want: sandboxv1alpha1.TaskCompletionRunning},
+		{name: "any: one success among failures", policy: anyOne, tasks: shardTasks(failed, succeed, running, pending), want: sandboxv1alpha1.TaskCompletionSucceeded},
+		{name: "any: every shard failed", policy: anyOne, tasks: shardTasks(failed, failed, failed), want: sandboxv1alpha1.TaskCompletionFailed},
+		{name: "any: unknown is not final", policy: anyOne, tasks: shardTasks(failed, unknown), want: sandboxv1alpha1.TaskCompletionRunning},
+		{name: "at least 2: one success so far", policy: atLeast2, tasks: shardTasks(succeed, failed, running, pending), want: sandboxv1alpha1.TaskCompletionRunning},
+		{name: "at least 2: reached", policy: atLeast2, tasks: shardTasks(succeed, failed, succeed, running), want: sandboxv1alpha1.TaskCompletionSucceeded},
+		{name: "at least 2: still within reach", policy: atLeast2, tasks: shardTasks(succeed, failed, failed, running), want: sandboxv1alpha1.TaskCompletionRunning},
+		{name: "at least 2: out of reach", policy: atLeast2, tasks: shardTasks(failed, failed, failed, running), want: sandboxv1alpha1.TaskCompletionFailed},
+		{name: "aborted: pending shards will not run", policy: anyOne, tasks: shardTasks(failed, pending, pending), aborted: true, want: sandboxv1alpha1.TaskCompletionFailed},
+	}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bs := &sandboxv1alpha1.BatchSandbox{}
+			bs.Spec.TaskCompletionPolicy = tt.policy
+			got := EvaluateTaskCompletion(bs, tt.tasks, tt.aborted, now)
+			if got == nil || got.Phase != tt.want {
+				t.Fatalf("EvaluateTaskCompletion() = %+v, want phase %s", got, tt.want)
+			}
+			if final := got.Phase != sandboxv1alpha1.TaskCompletionRunning; final != (got.CompletionTime != nil) {
+				t.Errorf("completion time %v does not match phase %s", got.CompletionTime, got.Phase)
+			}
+		})
+	}
+}
+
+func TestEvaluateTaskCompletion_FinalPhaseIsKept(t *testing.T) {
+	bs := &sandboxv1alpha1.BatchSandbox{}
+	if got := EvaluateTaskCompletion(bs, shardTasks(taskscheduler.SucceedTaskState), false, time.Now()); got != nil {
+		t.Fatalf("expected no status without a policy, got %+v", got)
+	}
+
+	bs.Spec.TaskCompletionPolicy = &sandboxv1alpha1.TaskCompletionPolicySpec{Type: sandboxv1alpha1.TaskCompletionPolicyAny}
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	first := EvaluateTaskCompletion(bs, shardTasks(taskscheduler.SucceedTaskState, taskscheduler.RunningTaskState), false, start)
+	bs.Status.TaskCompletion = first
+
+	// the stopped shard may report a failure afterwards; the outcome stands
+	got := EvaluateTaskCompletion(bs, shardTasks(taskscheduler.SucceedTaskState, taskscheduler.FailedTaskState), false, start.Add(time.Minute))
+	if got.Phase != sandboxv1alpha1.TaskCompletionSucceeded || !got.CompletionTime.Equal(first.CompletionTime) {
+		t.Fatalf("expected the first outcome kept, got %+v", got)
+	}
+}
+
+func TestValidateTaskTemplate_CompletionPolicy(t *testing.T) {
+	newBatch := func(policy *sandboxv1alpha1.TaskCompletionPolicySpec) *sandboxv1alpha1.BatchSandbox {
+		bs := &sandboxv1alpha1.BatchSandbox{}
+		bs.Spec.Replicas = ptr.To[int32](3)
+		bs.Spec.TaskTemplate = &sandboxv1alpha1.TaskTemplateSpec{}
+		bs.Spec.TaskCompletionPolicy = policy
+		return bs
+	}
+	for _, policy := range []*sandboxv1alpha1.TaskCompletionPolicySpec{
+		nil,
+		{Type: sandboxv1alpha1.TaskCompletionPolicyAny},
+		{Type: sandboxv1alpha1.TaskCompletionPolicyAtLeastN, MinSucceeded: 3},
+	} {
+		if err := NewTaskSchedulingStrategy(newBatch(policy)).ValidateTaskTemplate(); err != nil {
+			t.Errorf("policy %+v: unexpected error %v", policy, err)
+		}
+	}
+	for _, policy := range []*sandboxv1alpha1.TaskCompletionPolicySpec{
+		{Type: sandboxv1alpha1.TaskCompletionPolicyAtLeastN},
+		{Type: sandboxv1alpha1.TaskCompletionPolicyAtLeastN, MinSucceeded: 4},
+		{Type: "Most"},
+	} {
+		if err := NewTaskSchedulingStrategy(newBatch(policy)).ValidateTaskTemplate(); err == nil {
+			t.Errorf("policy %+v: expected an error", policy)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package strategy
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Env vars added to every generated task so shard code can find out where it
+// runs. The fieldRef entries are resolved against the Pod a task is assigned
+// to when the task is submitted.
+const (
+	EnvPodName      = "POD_NAME"
+	EnvPodNamespace = "POD_NAMESPACE"
+	EnvNodeName     = "NODE_NAME"
+	// EnvShardIndex carries the shard index as a literal: tasks are assigned
+	// to any free Pod, so a Pod's own index label need not match the shard.
+	EnvShardIndex = "SHARD_INDEX"
+)
+
+// withDownwardAPIEnv returns env followed by the placement entries for shard
+// idx. Names the template already sets are left alone, and env itself is not
+// modified.
+func withDownwardAPIEnv(env []corev1.EnvVar, idx int) []corev1.EnvVar {
+	placement := []corev1.EnvVar{
+		fieldRefEnv(EnvPodName, "metadata.name"),
+		fieldRefEnv(EnvPodNamespace, "metadata.namespace"),
+		fieldRefEnv(EnvNodeName, "spec.nodeName"),
+		{Name: EnvShardIndex, Value: strconv.Itoa(idx)},
+	}
+	set := make(map[string]bool, len(env))
+	for _, e := range env {
+		set[e.Name] = true
+	}
+	out := make([]corev1.EnvVar, 0, len(env)+len(placement))
+	out = append(out, env...)
+	for _, e := range placement {
+		if !set[e.Name] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func fieldRefEnv(name, fieldPath string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{APIVersion: "v1", FieldPath: fieldPath},
+		},
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package strategy
+
+import (
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	api "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
+)
+
+// RenderIndexedJob renders the task specs generated for batchSbx into an
+// Indexed Job with a completion per task. The tasks run as the first container
+// of the Pod template; SHARD_INDEX comes from the Job's completion index, so
+// the tasks must not differ otherwise.
+func RenderIndexedJob(batchSbx *sandboxv1alpha1.BatchSandbox, tasks []*api.Task) (*batchv1.Job, error) {
+	if batchSbx.Spec.PoolRef != "" {
+		return nil, fmt.Errorf("batchsandbox: IndexedJob task output is not supported with poolRef")
+	}
+	if batchSbx.Spec.TaskCanary != nil {
+		return nil, fmt.Errorf("batchsandbox: IndexedJob task output is not supported with taskCanary")
+	}
+	if policy := batchSbx.Spec.TaskCompletionPolicy; policy != nil && policy.Type != "" && policy.Type != sandboxv1alpha1.TaskCompletionPolicyAll {
+		return nil, fmt.Errorf("batchsandbox: IndexedJob task output is not supported with the %s task completion policy", policy.Type)
+	}
+	if batchSbx.Spec.ShardIndexBase != 0 {
+		// the completion index SHARD_INDEX is read from always starts at 0
+		return nil, fmt.Errorf("batchsandbox: IndexedJob task output is not supported with shardIndexBase %d", batchSbx.Spec.ShardIndexBase)
+	}
+	if len(batchSbx.Spec.VolumeClaimTemplates) > 0 {
+		return nil, fmt.Errorf("batchsandbox: IndexedJob task output is not supported with volumeClaimTemplates")
+	}
+	if batchSbx.Spec.Template == nil || len(batchSbx.Spec.Template.Spec.Containers) == 0 {
+		return nil, fmt.Errorf("batchsandbox: IndexedJob task output needs a template with a container")
+	}
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("batchsandbox: IndexedJob task output needs at least one replica")
+	}
+	for idx, task := range tasks {
+		if task == nil || task.Process == nil {
+			return nil, fmt.Errorf("batchsandbox: IndexedJob task output needs a process task, idx %d", idx)
+		}
+		if len(task.Sidecars) > 0 {
+			return nil, fmt.Errorf("batchsandbox: IndexedJob task output does not support sidecars, idx %d", idx)
+		}
+		if idx > 0 && !sameIndexedTask(tasks[0], task) {
+			return nil, fmt.Errorf("batchsandbox: task of shard %d differs from shard 0 beyond %s, which an Indexed Job cannot express", idx, EnvShardIndex)
+		}
+	}
+
+	task := tasks[0]
+	template := batchSbx.Spec.Template.DeepCopy()
+	podSpec := &template.Spec
+	podSpec.RestartPolicy = corev1.RestartPolicyNever
+	if task.ServiceAccountName != "" {
+		podSpec.ServiceAccountName = task.ServiceAccountName
+	}
+	if task.PriorityClassName != "" {
+		podSpec.PriorityClassName = task.PriorityClassName
+	}
+	// the executor times out each process, the kubelet each Pod
+	if task.Process.TimeoutSeconds != nil {
+		podSpec.ActiveDeadlineSeconds = ptr.To(*task.Process.TimeoutSeconds)
+	}
+	main := &podSpec.Containers[0]
+	main.Command = task.Process.Command
+	main.Args = task.Process.Args
+	main.Env = completionIndexEnv(mergeEnv(main.Env, task.Process.Env))
+	if task.Process.WorkingDir != "" {
+		main.WorkingDir = task.Process.WorkingDir
+	}
+	if task.Process.StartupProbe != nil {
+		main.StartupProbe = task.Process.StartupProbe.DeepCopy()
+	}
+
+	job := &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{APIVersion: batchv1.SchemeGroupVersion.String(), Kind: "Job"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      batchSbx.Name,
+			Namespace: batchSbx.Namespace,
+			Labels:    template.Labels,
+		},
+		Spec: batchv1.JobSpec{
+			Completions:    ptr.To(int32(len(tasks))),
+			Parallelism:    ptr.To(int32(len(tasks))),
+			CompletionMode: ptr.To(batchv1.IndexedCompletion),
+			// shards are not retried, as with the task executor
+			BackoffLimit: ptr.To(int32(0)),
+			Suspend:      ptr.To(batchSbx.Spec.Suspend),
+			Template:     *template,
+		},
+	}
+	return job, nil
+}
+
+// sameIndexedTask reports whether a and b run the same process once their
+// shard indexes are set aside.
+func sameIndexedTask(a, b *api.Task) bool {
+	if a.ServiceAccountName != b.ServiceAccountName || a.PriorityClassName != b.PriorityClassName {
+		return false
+	}
+	pa, pb := *a.Process, *b.Process
+	pa.Env = withoutEnv(pa.Env, EnvShardIndex)
+	pb.Env = withoutEnv(pb.Env, EnvShardIndex)
+	return apiequality.Semantic.DeepEqual(pa, pb)
+}
+
+// mergeEnv returns the container's env with the task's env applied over it.
+func mergeEnv(container, task []corev1.EnvVar) []corev1.EnvVar {
+	out := make([]corev1.EnvVar, 0, len(container)+len(task))
+	for _, e := range container {
+		overridden := false
+		for _, t := range task {
+			if t.Name == e.Name {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			out = append(out, e)
+		}
+	}
+	return append(out, task...)
+}
+
+// completionIndexEnv points SHARD_INDEX at the Pod's completion index.
+func completionIndexEnv(env []corev1.EnvVar) []corev1.EnvVar {
+	out := withoutEnv(env, EnvShardIndex)
+	return append(out, corev1.EnvVar{
+		Name: EnvShardIndex,
+		ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{
+				APIVersion: "v1",
+				FieldPath:  fmt.Sprintf("metadata.annotations['%s']", batchv1.JobCompletionIndexAnnotation),
+			},
+		},
+	})
+}
+
+func withoutEnv(env []corev1.EnvVar, name string) []corev1.EnvVar {
+	out := make([]corev1.EnvVar, 0, len(env))
+	for _, e := range env {
+		if e.Name != name {
+			out = append(out, e)
+		}
+	}
+	return out
+}
@@ -0,0 +1,194 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package strategy
+
+import (
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+)
+
+func newIndexedJobBatchSandbox() *sandboxv1alpha1.BatchSandbox {
+	return &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{Name: "train", Namespace: "team-a", UID: "uid-1"},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			Replicas:   ptr.To[int32](3),
+			TaskOutput: sandboxv1alpha1.TaskOutputIndexedJob,
+			Template: &corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "train"}},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "main",
+						Image: "python:3.12",
+						Env:   []corev1.EnvVar{{Name: "MODE", Value: "pod"}, {Name: "KEEP", Value: "1"}},
+					}},
+					RestartPolicy: corev1.RestartPolicyAlways,
+				},
+			},
+			TaskTemplate: &sandboxv1alpha1.TaskTemplateSpec{Spec: sandboxv1alpha1.TaskSpec{
+				TimeoutSeconds: ptr.To[int64](600),
+				Process: &sandboxv1alpha1.ProcessTask{
+					Command:    []string{"python", "train.py"},
+					Args:       []string{"--epochs", "3"},
+					Env:        []corev1.EnvVar{{Name: "MODE", Value: "task"}},
+					WorkingDir: "/work",
+				},
+			}},
+		},
+	}
+}
+
+func TestRenderIndexedJob(t *testing.T) {
+	batchSbx := newIndexedJobBatchSandbox()
+	tasks, err := NewTaskSchedulingStrategy(batchSbx).GenerateTaskSpecs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := RenderIndexedJob(batchSbx, tasks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if job.Name != "train" || job.Namespace != "team-a" {
+		t.Errorf("job is %s/%s, want team-a/train", job.Namespace, job.Name)
+	}
+	if job.Labels["app"] != "train" {
+		t.Errorf("job labels = %v, want the template's", job.Labels)
+	}
+	if got := ptr.Deref(job.Spec.Completions, 0); got != 3 {
+		t.Errorf("completions = %d, want 3", got)
+	}
+	if got := ptr.Deref(job.Spec.Parallelism, 0); got != 3 {
+		t.Errorf("parallelism = %d, want 3", got)
+	}
+	if got := ptr.Deref(job.Spec.CompletionMode, ""); got != batchv1.IndexedCompletion {
+		t.Errorf("completion mode = %q, want Indexed", got)
+	}
+	if got := ptr.Deref(job.Spec.BackoffLimit, -1); got != 0 {
+		t.Errorf("backoff limit = %d, want 0", got)
+	}
+	if ptr.Deref(job.Spec.Suspend, true) {
+		t.Errorf("job is suspended")
+	}
+
+	podSpec := job.Spec.Template.Spec
+	if podSpec.RestartPolicy != corev1.RestartPolicyNever {
+		t.Errorf("restart policy = %q, want Never", podSpec.RestartPolicy)
+	}
+	if got := ptr.Deref(podSpec.ActiveDeadlineSeconds, 0); got != 600 {
+		t.Errorf("active deadline = %d, want the task timeout 600", got)
+	}
+	main := podSpec.Containers[0]
+	if strings.Join(main.Command, " ") != "python train.py" || strings.Join(main.Args, " ") != "--epochs 3" {
+		t.Errorf("container runs %v %v, want the task process", main.Command, main.Args)
+	}
+	if main.WorkingDir != "/work" {
+		t.Errorf("working dir = %q, want /work", main.WorkingDir)
+	}
+	env := map[string]corev1.EnvVar{}
+	for _, e := range main.Env {
+		if _, dup := env[e.Name]; dup {
+			t.Errorf("env %s is set twice", e.Name)
+		}
+		env[e.Name] = e
+	}
+	if env["MODE"].Value != "task" {
+		t.Errorf("MODE = %q, want the task's value to win", env["MODE"].Value)
+	}
+	if env["KEEP"].Value != "1" {
+		t.Errorf("container env KEEP was dropped")
+	}
+	if src := env[EnvPodName].ValueFrom; src == nil || src.FieldRef.FieldPath != "metadata.name" {
+		t.Errorf("%s = %+v, want the downward API", EnvPodName, env[EnvPodName])
+	}
+	shard := env[EnvShardIndex]
+	if shard.Value != "" || shard.ValueFrom == nil ||
+		shard.ValueFrom.FieldRef.FieldPath != "metadata.annotations['batch.kubernetes.io/job-completion-index']" {
+		t.Errorf("%s = %+v, want the completion index annotation", EnvShardIndex, shard)
+	}
+	if batchSbx.Spec.Template.Spec.RestartPolicy != corev1.RestartPolicyAlways || len(batchSbx.Spec.Template.Spec.Containers[0].Env) != 2 {
+		t.Errorf("rendering modified the BatchSandbox template")
+	}
+}
+
+func TestRenderIndexedJob_Rejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*sandboxv1alpha1.BatchSandbox)
+		want   string
+	}{
+		{
+			name: "shards differ beyond the index",
+			mutate: func(b *sandboxv1alpha1.BatchSandbox) {
+				b.Spec.ShardTaskPatches = []runtime.RawExtension{{}, {Raw: []byte(`{"spec":{"process":{"args":["--epochs","5"]}}}`)}}
+			},
+			want: "differs from shard 0",
+		},
+		{
+			name: "sidecars",
+			mutate: func(b *sandboxv1alpha1.BatchSandbox) {
+				b.Spec.TaskTemplate.Spec.Sidecars = []sandboxv1alpha1.SidecarProcess{{Name: "logs", Command: []string{"ship"}}}
+			},
+			want: "sidecars",
+		},
+		{
+			name:   "pooled",
+			mutate: func(b *sandboxv1alpha1.BatchSandbox) { b.Spec.PoolRef = "pool" },
+			want:   "poolRef",
+		},
+		{
+			name:   "canary",
+			mutate: func(b *sandboxv1alpha1.BatchSandbox) { b.Spec.TaskCanary = &sandboxv1alpha1.TaskCanarySpec{} },
+			want:   "taskCanary",
+		},
+		{
+			name: "any completion policy",
+			mutate: func(b *sandboxv1alpha1.BatchSandbox) {
+				b.Spec.TaskCompletionPolicy = &sandboxv1alpha1.TaskCompletionPolicySpec{Type: sandboxv1alpha1.TaskCompletionPolicyAny}
+			},
+			want: "Any task completion policy",
+		},
+		{
+			name:   "shard index base",
+			mutate: func(b *sandboxv1alpha1.BatchSandbox) { b.Spec.ShardIndexBase = 1 },
+			want:   "shardIndexBase",
+		},
+		{
+			name:   "no template",
+			mutate: func(b *sandboxv1alpha1.BatchSandbox) { b.Spec.Template = nil },
+			want:   "template",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batchSbx := newIndexedJobBatchSandbox()
+			tt.mutate(batchSbx)
+			tasks, err := NewTaskSchedulingStrategy(batchSbx).GenerateTaskSpecs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := RenderIndexedJob(batchSbx, tasks); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("RenderIndexedJob() error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package strategy
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+)
+
+// EnvMatrixPrefix starts the env var carrying each TaskMatrix dimension's
+// value, e.g. MATRIX_LR for dimension "lr".
+const EnvMatrixPrefix = "MATRIX_"
+
+var matrixNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// TaskMatrixReplicas validates matrix and returns the number of shards it
+// expands to, the product of its dimensions' lengths.
+func TaskMatrixReplicas(matrix []sandboxv1alpha1.TaskMatrixDimension) (int32, error) {
+	replicas := int64(1)
+	names := make(map[string]string, len(matrix))
+	for i, dim := range matrix {
+		if !matrixNamePattern.MatchString(dim.Name) {
+			return 0, fmt.Errorf("taskMatrix[%d]: name %q must start with a letter or underscore, followed by letters, digits or underscores", i, dim.Name)
+		}
+		// the env var is upper-cased, so names may not differ in case only
+		env := matrixEnvName(dim.Name)
+		if other, ok := names[env]; ok {
+			return 0, fmt.Errorf("taskMatrix[%d]: name %q clashes with %q", i, dim.Name, other)
+		}
+		names[env] = dim.Name
+		if len(dim.Values) == 0 {
+			return 0, fmt.Errorf("taskMatrix[%d] (%s) has no values", i, dim.Name)
+		}
+		seen := make(map[string]bool, len(dim.Values))
+		for _, v := range dim.Values {
+			if seen[v] {
+				return 0, fmt.Errorf("taskMatrix[%d] (%s) has duplicate value %q", i, dim.Name, v)
+			}
+			seen[v] = true
+		}
+		replicas *= int64(len(dim.Values))
+		if replicas > math.MaxInt32 {
+			return 0, fmt.Errorf("taskMatrix expands to more than %d shards", math.MaxInt32)
+		}
+	}
+	return int32(replicas), nil
+}
+
+// taskMatrixValues returns shard idx's combination, by dimension name. Shards
+// count through the combinations like nested loops over the dimensions in
+// order, so the last dimension varies fastest and the mapping only depends on
+// the matrix.
+func taskMatrixValues(matrix []sandboxv1alpha1.TaskMatrixDimension, idx int) map[string]string {
+	if len(matrix) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(matrix))
+	for i := len(matrix) - 1; i >= 0; i-- {
+		n := len(matrix[i].Values)
+		values[matrix[i].Name] = matrix[i].Values[idx%n]
+		idx /= n
+	}
+	return values
+}
+
+// withMatrixEnv returns env followed by a MATRIX_ entry per dimension of
+// values, in matrix order. Names the template already sets are left alone,
+// and env itself is not modified.
+func withMatrixEnv(env []corev1.EnvVar, matrix []sandboxv1alpha1.TaskMatrixDimension, values map[string]string) []corev1.EnvVar {
+	if len(values) == 0 {
+		return env
+	}
+	set := make(map[string]bool, len(env))
+	for _, e := range env {
+		set[e.Name] = true
+	}
+	out := make([]corev1.EnvVar, 0, len(env)+len(matrix))
+	out = append(out, env...)
+	for _, dim := range matrix {
+		if name := matrixEnvName(dim.Name); !set[name] {
+			out = append(out, corev1.EnvVar{Name: name, Value: values[dim.Name]})
+		}
+	}
+	return out
+}
+
+func matrixEnvName(name string) string {
+	return EnvMatrixPrefix + strings.ToUpper(name)
+}
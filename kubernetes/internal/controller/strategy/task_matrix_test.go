@@ -0,0 +1,140 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package strategy
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+)
+
+func sweepMatrix() []sandboxv1alpha1.TaskMatrixDimension {
+	return []sandboxv1alpha1.TaskMatrixDimension{
+		{Name: "lr", Values: []string{"0.1", "0.01"}},
+		{Name: "batch", Values: []string{"16", "32", "64"}},
+	}
+}
+
+func TestTaskMatrix_ExpandsTheProduct(t *testing.T) {
+	replicas, err := TaskMatrixReplicas(sweepMatrix())
+	if err != nil || replicas != 6 {
+		t.Fatalf("TaskMatrixReplicas() = %d, %v, want 6", replicas, err)
+	}
+	want := []map[string]string{
+		{"lr": "0.1", "batch": "16"},
+		{"lr": "0.1", "batch": "32"},
+		{"lr": "0.1", "batch": "64"},
+		{"lr": "0.01", "batch": "16"},
+		{"lr": "0.01", "batch": "32"},
+		{"lr": "0.01", "batch": "64"},
+	}
+	for idx := range want {
+		if got := taskMatrixValues(sweepMatrix(), idx); !reflect.DeepEqual(got, want[idx]) {
+			t.Errorf("shard %d = %v, want %v", idx, got, want[idx])
+		}
+	}
+}
+
+func TestTaskMatrixReplicas_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		matrix  []sandboxv1alpha1.TaskMatrixDimension
+		wantErr string
+	}{
+		{name: "bad name", matrix: []sandboxv1alpha1.TaskMatrixDimension{{Name: "learning-rate", Values: []string{"1"}}}, wantErr: "must start with"},
+		{name: "names differing in case", matrix: []sandboxv1alpha1.TaskMatrixDimension{{Name: "lr", Values: []string{"1"}}, {Name: "LR", Values: []string{"2"}}}, wantErr: "clashes"},
+		{name: "no values", matrix: []sandboxv1alpha1.TaskMatrixDimension{{Name: "lr"}}, wantErr: "no values"},
+		{name: "duplicate value", matrix: []sandboxv1alpha1.TaskMatrixDimension{{Name: "lr", Values: []string{"1", "1"}}}, wantErr: "duplicate value"},
+	}
+	big := make([]string, 1<<16)
+	for i := range big {
+		big[i] = strconv.Itoa(i)
+	}
+	tests = append(tests, struct {
+		name    string
+		matrix  []sandboxv1alpha1.TaskMatrixDimension
+		wantErr string
+	}{name: "too many shards", matrix: []sandboxv1alpha1.TaskMatrixDimension{{Name: "a", Values: big}, {Name: "b", Values: big}}, wantErr: "more than"})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := TaskMatrixReplicas(tt.matrix); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("TaskMatrixReplicas() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefaultTaskSchedulingStrategy_GenerateTaskSpecs_Matrix(t *testing.T) {
+	replicas := int32(6)
+	batchSbx := &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{Name: "sweep"},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			Replicas:           &replicas,
+			TaskMatrix:         sweepMatrix(),
+			TaskTemplateEngine: sandboxv1alpha1.TaskTemplateEngineGoTemplate,
+			TaskTemplateValues: map[string]string{"lr": "1.0", "epochs": "3"},
+			// shard values still win over the matrix
+			ShardTaskValues: []map[string]string{nil, {"epochs": "1", "batch": "8"}},
+			TaskTemplate: &sandboxv1alpha1.TaskTemplateSpec{
+				Spec: sandboxv1alpha1.TaskSpec{
+					Process: &sandboxv1alpha1.ProcessTask{
+						Command: []string{"python", "train.py"},
+						Args:    []string{"--lr={{.Values.lr}}", "--batch={{.Values.batch}}", "--epochs={{.Values.epochs}}"},
+						Env:     []corev1.EnvVar{{Name: "MATRIX_BATCH", Value: "pinned"}},
+					},
+				},
+			},
+		},
+	}
+	s := NewDefaultTaskSchedulingStrategy(batchSbx)
+	if err := s.ValidateTaskTemplate(); err != nil {
+		t.Fatalf("ValidateTaskTemplate() error = %v", err)
+	}
+	tasks, err := s.GenerateTaskSpecs()
+	if err != nil {
+		t.Fatalf("GenerateTaskSpecs() error = %v", err)
+	}
+	wantArgs := [][]string{
+		{"--lr=0.1", "--batch=16", "--epochs=3"},
+		{"--lr=0.1", "--batch=8", "--epochs=1"},
+		{"--lr=0.1", "--batch=64", "--epochs=3"},
+		{"--lr=0.01", "--batch=16", "--epochs=3"},
+		{"--lr=0.01", "--batch=32", "--epochs=3"},
+		{"--lr=0.01", "--batch=64", "--epochs=3"},
+	}
+	for idx, task := range tasks {
+		if !reflect.DeepEqual(task.Process.Args, wantArgs[idx]) {
+			t.Errorf("task %d args = %v, want %v", idx, task.Process.Args, wantArgs[idx])
+		}
+		env := map[string]string{}
+		for _, e := range task.Process.Env {
+			env[e.Name] = e.Value
+		}
+		if env["MATRIX_LR"] != taskMatrixValues(sweepMatrix(), idx)["lr"] || env["MATRIX_BATCH"] != "pinned" {
+			t.Errorf("task %d env = %v", idx, task.Process.Env)
+		}
+	}
+
+	replicas = 4
+	if err := s.ValidateTaskTemplate(); err == nil || !strings.Contains(err.Error(), "6 combinations") {
+		t.Fatalf("expected replicas that do not match the matrix to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package strategy
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+)
+
+// EnvParamPrefix starts the env var carrying each field of a shard's
+// parameter row when TaskParameters maps no env explicitly, e.g. PARAM_SEED.
+const EnvParamPrefix = "PARAM_"
+
+// TaskParameters are the rows of a parameter file, row i for shard i.
+type TaskParameters struct {
+	// Fields are the names of the fields, in CSV column order or, for JSON,
+	// sorted.
+	Fields []string
+	Rows   []map[string]string
+
+	source *sandboxv1alpha1.TaskParameterSource
+}
+
+// TaskParameterFormatOf returns the format source is parsed as.
+func TaskParameterFormatOf(source *sandboxv1alpha1.TaskParameterSource) sandboxv1alpha1.TaskParameterFormat {
+	if source.Format != "" {
+		return source.Format
+	}
+	if strings.HasSuffix(strings.ToLower(source.ConfigMapRef.Key), ".json") {
+		return sandboxv1alpha1.TaskParameterFormatJSON
+	}
+	return sandboxv1alpha1.TaskParameterFormatCSV
+}
+
+// ParseTaskParameters parses the parameter file of source and checks that
+// every row has the fields its env and args mapping reference.
+func ParseTaskParameters(source *sandboxv1alpha1.TaskParameterSource, data []byte) (*TaskParameters, error) {
+	var params *TaskParameters
+	var err error
+	switch format := TaskParameterFormatOf(source); format {
+	case sandboxv1alpha1.TaskParameterFormatCSV:
+		params, err = parseCSVParameters(data)
+	case sandboxv1alpha1.TaskParameterFormatJSON:
+		params, err = parseJSONParameters(data)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(params.Rows) == 0 {
+		return nil, errors.New("the parameter file has no rows")
+	}
+	params.source = source
+	if err := params.validateMapping(); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+func parseCSVParameters(data []byte) (*TaskParameters, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	// every record must have as many fields as the header
+	r.FieldsPerRecord = 0
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, errors.New("the CSV file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("CSV column %d has no name", i+1)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate CSV column %q", name)
+		}
+		seen[name] = true
+		header[i] = name
+	}
+	params := &TaskParameters{Fields: header}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			row[name] = record[i]
+		}
+		params.Rows = append(params.Rows, row)
+	}
+	return params, nil
+}
+
+func parseJSONParameters(data []byte) (*TaskParameters, error) {
+	var raw []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON, want an array of objects: %w", err)
+	}
+	params := &TaskParameters{}
+	fields := map[string]bool{}
+	for i, object := range raw {
+		row := make(map[string]string, len(object))
+		for name, value := range object {
+			v, err := jsonParameterValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("row %d field %q: %w", i, name, err)
+			}
+			row[name] = v
+			if !fields[name] {
+				fields[name] = true
+				params.Fields = append(params.Fields, name)
+			}
+		}
+		params.Rows = append(params.Rows, row)
+	}
+	sort.Strings(params.Fields)
+	return params, nil
+}
+
+// jsonParameterValue renders a JSON scalar as its text; numbers keep their
+// literal form, so 1e3 stays 1e3.
+func jsonParameterValue(raw json.RawMessage) (string, error) {
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return "", err
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "true", nil
+		}
+		return "false", nil
+	default:
+		return "", errors.New("must be a string, number or boolean")
+	}
+}
+
+func (p *TaskParameters) validateMapping() error {
+	if len(p.source.Env) == 0 {
+		names := make(map[string]string, len(p.Fields))
+		for _, field := range p.Fields {
+			if !matrixNamePattern.MatchString(field) {
+				return fmt.Errorf("field %q is not a valid env var name; map it with taskParameters.env", field)
+			}
+			env := paramEnvName(field)
+			if other, ok := names[env]; ok {
+				return fmt.Errorf("fields %q and %q both map to env var %s", other, field, env)
+			}
+			names[env] = field
+		}
+	}
+	referenced := make([]string, 0, len(p.source.Env)+len(p.source.Args))
+	for i, env := range p.source.Env {
+		if env.Name == "" {
+			return fmt.Errorf("taskParameters.env[%d] has no name", i)
+		}
+		referenced = append(referenced, env.Field)
+	}
+	referenced = append(referenced, p.source.Args...)
+	for _, field := range referenced {
+		for i, row := range p.Rows {
+			if _, ok := row[field]; !ok {
+				return fmt.Errorf("row %d has no field %q", i, field)
+			}
+		}
+	}
+	return nil
+}
+
+// row returns shard idx's parameters, nil past the last row.
+func (p *TaskParameters) row(idx int) map[string]string {
+	if p == nil || idx >= len(p.Rows) {
+		return nil
+	}
+	return p.Rows[idx]
+}
+
+// withParameterEnv returns env followed by the env vars of shard idx's row.
+// Names the template already sets are left alone, and env itself is not
+// modified.
+func (p *TaskParameters) withParameterEnv(env []corev1.EnvVar, idx int) []corev1.EnvVar {
+	row := p.row(idx)
+	if row == nil {
+		return env
+	}
+	set := make(map[string]bool, len(env))
+	for _, e := range env {
+		set[e.Name] = true
+	}
+	out := make([]corev1.EnvVar, 0, len(env)+len(row))
+	out = append(out, env...)
+	add := func(name, value string) {
+		if !set[name] {
+			set[name] = true
+			out = append(out, corev1.EnvVar{Name: name, Value: value})
+		}
+	}
+	if len(p.source.Env) > 0 {
+		for _, e := range p.source.Env {
+			add(e.Name, row[e.Field])
+		}
+		return out
+	}
+	for _, field := range p.Fields {
+		if value, ok := row[field]; ok {
+			add(paramEnvName(field), value)
+		}
+	}
+	return out
+}
+
+// withParameterArgs returns args followed by the Args fields of shard idx's
+// row; args itself is not modified.
+func (p *TaskParameters) withParameterArgs(args []string, idx int) []string {
+	row := p.row(idx)
+	if row == nil || len(p.source.Args) == 0 {
+		return args
+	}
+	out := make([]string, 0, len(args)+len(p.source.Args))
+	out = append(out, args...)
+	for _, field := range p.source.Args {
+		out = append(out, row[field])
+	}
+	return out
+}
+
+func paramEnvName(field string) string {
+	return EnvParamPrefix + strings.ToUpper(field)
+}
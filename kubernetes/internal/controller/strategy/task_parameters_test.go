@@ -0,0 +1,145 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package strategy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+)
+
+const sampleParametersCSV = `dataset,seed,out
+s3://bucket/part-0,7,"/out/a,b"
+s3://bucket/part-1,8,/out/c
+s3://bucket/part-2,9,/out/d
+`
+
+func csvSource() *sandboxv1alpha1.TaskParameterSource {
+	return &sandboxv1alpha1.TaskParameterSource{
+		ConfigMapRef: sandboxv1alpha1.TaskParameterConfigMapRef{Name: "shards", Key: "shards.csv"},
+		Args:         []string{"dataset"},
+	}
+}
+
+func TestParseTaskParameters_CSVToTaskSpecs(t *testing.T) {
+	params, err := ParseTaskParameters(csvSource(), []byte(sampleParametersCSV))
+	if err != nil {
+		t.Fatalf("ParseTaskParameters() error = %v", err)
+	}
+	if !reflect.DeepEqual(params.Fields, []string{"dataset", "seed", "out"}) || len(params.Rows) != 3 {
+		t.Fatalf("parsed fields %v and %d rows", params.Fields, len(params.Rows))
+	}
+
+	replicas := int32(3)
+	batchSbx := &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{Name: "ingest"},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			Replicas:           &replicas,
+			TaskParameters:     csvSource(),
+			TaskTemplateEngine: sandboxv1alpha1.TaskTemplateEngineGoTemplate,
+			TaskTemplate: &sandboxv1alpha1.TaskTemplateSpec{
+				Spec: sandboxv1alpha1.TaskSpec{
+					Process: &sandboxv1alpha1.ProcessTask{
+						Command: []string{"ingest"},
+						Args:    []string{"--out={{.Values.out}}"},
+						Env:     []corev1.EnvVar{{Name: "PARAM_SEED", Value: "pinned"}},
+					},
+				},
+			},
+		},
+	}
+	s := NewTaskSchedulingStrategyWithParameters(batchSbx, params)
+	if err := s.ValidateTaskTemplate(); err != nil {
+		t.Fatalf("ValidateTaskTemplate() error = %v", err)
+	}
+	tasks, err := s.GenerateTaskSpecs()
+	if err != nil {
+		t.Fatalf("GenerateTaskSpecs() error = %v", err)
+	}
+	wantArgs := [][]string{
+		{"--out=/out/a,b", "s3://bucket/part-0"},
+		{"--out=/out/c", "s3://bucket/part-1"},
+		{"--out=/out/d", "s3://bucket/part-2"},
+	}
+	for idx, task := range tasks {
+		if !reflect.DeepEqual(task.Process.Args, wantArgs[idx]) {
+			t.Errorf("task %d args = %v, want %v", idx, task.Process.Args, wantArgs[idx])
+		}
+		env := map[string]string{}
+		for _, e := range task.Process.Env {
+			env[e.Name] = e.Value
+		}
+		if env["PARAM_DATASET"] != params.Rows[idx]["dataset"] || env["PARAM_OUT"] != params.Rows[idx]["out"] || env["PARAM_SEED"] != "pinned" {
+			t.Errorf("task %d env = %v", idx, task.Process.Env)
+		}
+	}
+
+	replicas = 4
+	if err := s.ValidateTaskTemplate(); err == nil || !strings.Contains(err.Error(), "3 rows") {
+		t.Fatalf("expected replicas that do not match the rows to be rejected, got %v", err)
+	}
+}
+
+func TestParseTaskParameters_JSONWithEnvMapping(t *testing.T) {
+	source := &sandboxv1alpha1.TaskParameterSource{
+		ConfigMapRef: sandboxv1alpha1.TaskParameterConfigMapRef{Name: "shards", Key: "shards.json"},
+		Env:          []sandboxv1alpha1.TaskParameterEnv{{Name: "LEARNING_RATE", Field: "learning-rate"}},
+	}
+	params, err := ParseTaskParameters(source, []byte(`[{"learning-rate": 1e-3, "warmup": true}, {"learning-rate": "0.01"}]`))
+	if err != nil {
+		t.Fatalf("ParseTaskParameters() error = %v", err)
+	}
+	if !reflect.DeepEqual(params.Rows, []map[string]string{{"learning-rate": "1e-3", "warmup": "true"}, {"learning-rate": "0.01"}}) {
+		t.Fatalf("parsed rows %v", params.Rows)
+	}
+	env := params.withParameterEnv(nil, 0)
+	if !reflect.DeepEqual(env, []corev1.EnvVar{{Name: "LEARNING_RATE", Value: "1e-3"}}) {
+		t.Fatalf("env = %v", env)
+	}
+}
+
+func TestParseTaskParameters_Invalid(t *testing.T) {
+	csvKey := sandboxv1alpha1.TaskParameterConfigMapRef{Name: "shards", Key: "shards.csv"}
+	jsonKey := sandboxv1alpha1.TaskParameterConfigMapRef{Name: "shards", Key: "shards.json"}
+	tests := []struct {
+		name    string
+		source  sandboxv1alpha1.TaskParameterSource
+		data    string
+		wantErr string
+	}{
+		{name: "empty CSV", source: sandboxv1alpha1.TaskParameterSource{ConfigMapRef: csvKey}, data: "", wantErr: "empty"},
+		{name: "header only", source: sandboxv1alpha1.TaskParameterSource{ConfigMapRef: csvKey}, data: "seed\n", wantErr: "no rows"},
+		{name: "ragged CSV", source: sandboxv1alpha1.TaskParameterSource{ConfigMapRef: csvKey}, data: "a,b\n1,2\n3\n", wantErr: "wrong number of fields"},
+		{name: "duplicate column", source: sandboxv1alpha1.TaskParameterSource{ConfigMapRef: csvKey}, data: "a,a\n1,2\n", wantErr: "duplicate"},
+		{name: "column not an env name", source: sandboxv1alpha1.TaskParameterSource{ConfigMapRef: csvKey}, data: "learning-rate\n1\n", wantErr: "taskParameters.env"},
+		{name: "unknown args field", source: sandboxv1alpha1.TaskParameterSource{ConfigMapRef: csvKey, Args: []string{"seed"}}, data: "a\n1\n", wantErr: `no field "seed"`},
+		{name: "JSON object", source: sandboxv1alpha1.TaskParameterSource{ConfigMapRef: jsonKey}, data: `{"a": 1}`, wantErr: "array of objects"},
+		{name: "nested JSON value", source: sandboxv1alpha1.TaskParameterSource{ConfigMapRef: jsonKey}, data: `[{"a": [1]}]`, wantErr: "string, number or boolean"},
+		{name: "field missing from a JSON row", source: sandboxv1alpha1.TaskParameterSource{ConfigMapRef: jsonKey, Env: []sandboxv1alpha1.TaskParameterEnv{{Name: "A", Field: "a"}}}, data: `[{"a": 1}, {"b": 2}]`, wantErr: `row 1 has no field "a"`},
+		{name: "format overrides the key", source: sandboxv1alpha1.TaskParameterSource{ConfigMapRef: csvKey, Format: sandboxv1alpha1.TaskParameterFormatJSON}, data: "a\n1\n", wantErr: "invalid JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseTaskParameters(&tt.source, []byte(tt.data)); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ParseTaskParameters() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
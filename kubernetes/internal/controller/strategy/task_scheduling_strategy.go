@@ -15,6 +15,10 @@
 package strategy
 
 import (
+	"time"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	taskscheduler "github.com/alibaba/OpenSandbox/sandbox-k8s/internal/scheduler"
 	api "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
 )
 
@@ -27,4 +31,23 @@ type TaskSchedulingStrategy interface {
 
 	// GenerateTaskSpecs generates the complete list of task specifications for the BatchSandbox.
 	GenerateTaskSpecs() ([]*api.Task, error)
+
+	// ValidateTaskTemplate reports whether task specifications can be generated
+	// for every replica, before any of them is scheduled.
+	ValidateTaskTemplate() error
+
+	// AdmitTasks decides, from the current state of the generated tasks, how many
+	// of them may be scheduled this round. Tasks are in GenerateTaskSpecs order.
+	AdmitTasks(tasks []taskscheduler.Task, now time.Time) TaskAdmission
+}
+
+// TaskAdmission is the result of TaskSchedulingStrategy.AdmitTasks.
+type TaskAdmission struct {
+	// Admitted is the number of leading tasks that may be scheduled; negative admits all.
+	Admitted int
+	// Abort is set when none of the remaining tasks may ever run and the batch
+	// has to be stopped.
+	Abort bool
+	// Canary is the canary status to record, nil when no canary is configured.
+	Canary *sandboxv1alpha1.TaskCanaryStatus
 }
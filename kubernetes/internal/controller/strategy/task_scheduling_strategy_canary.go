@@ -0,0 +1,90 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package strategy
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	taskscheduler "github.com/alibaba/OpenSandbox/sandbox-k8s/internal/scheduler"
+)
+
+// CanaryTaskSchedulingStrategy schedules shard 0 alone and holds back the other
+// shards until it succeeds. Task specs are generated like the default strategy.
+type CanaryTaskSchedulingStrategy struct {
+	*DefaultTaskSchedulingStrategy
+}
+
+// NewCanaryTaskSchedulingStrategy creates a new canary task scheduling strategy.
+func NewCanaryTaskSchedulingStrategy(batchSbx *sandboxv1alpha1.BatchSandbox) *CanaryTaskSchedulingStrategy {
+	return &CanaryTaskSchedulingStrategy{
+		DefaultTaskSchedulingStrategy: NewDefaultTaskSchedulingStrategy(batchSbx),
+	}
+}
+
+// AdmitTasks admits only the canary until it succeeds, then every task. A
+// canary with a startup probe succeeds as soon as it is ready, since a
+// long-running service may never exit. The
+// outcome is read back from the recorded status first, so a controller restart
+// neither re-runs a finished canary nor releases shards after a failed one.
+func (s *CanaryTaskSchedulingStrategy) AdmitTasks(tasks []taskscheduler.Task, now time.Time) TaskAdmission {
+	status := &sandboxv1alpha1.TaskCanaryStatus{Phase: sandboxv1alpha1.TaskCanaryPending}
+	if s.Status.TaskCanary != nil {
+		status = s.Status.TaskCanary.DeepCopy()
+	}
+	switch status.Phase {
+	case sandboxv1alpha1.TaskCanarySucceeded:
+		return TaskAdmission{Admitted: -1, Canary: status}
+	case sandboxv1alpha1.TaskCanaryFailed:
+		return TaskAdmission{Admitted: 1, Abort: true, Canary: status}
+	}
+	if len(tasks) == 0 {
+		return TaskAdmission{Admitted: -1, Canary: status}
+	}
+
+	canary := tasks[0]
+	if canary.GetPodName() == "" {
+		return TaskAdmission{Admitted: 1, Canary: status}
+	}
+	if status.StartTime == nil {
+		status.StartTime = &metav1.Time{Time: now}
+	}
+	status.Phase = sandboxv1alpha1.TaskCanaryRunning
+	switch canary.GetState() {
+	case taskscheduler.SucceedTaskState:
+		status.Phase = sandboxv1alpha1.TaskCanarySucceeded
+		return TaskAdmission{Admitted: -1, Canary: status}
+	case taskscheduler.FailedTaskState:
+		status.Phase = sandboxv1alpha1.TaskCanaryFailed
+		status.Message = fmt.Sprintf("canary task %s failed", canary.GetName())
+		return TaskAdmission{Admitted: 1, Abort: true, Canary: status}
+	case taskscheduler.RunningTaskState:
+		if s.Spec.TaskTemplate.Spec.StartupProbe != nil && canary.IsReady() {
+			status.Phase = sandboxv1alpha1.TaskCanarySucceeded
+			return TaskAdmission{Admitted: -1, Canary: status}
+		}
+	}
+	if timeout := s.Spec.TaskCanary.TimeoutSeconds; timeout > 0 {
+		if elapsed := now.Sub(status.StartTime.Time); elapsed > time.Duration(timeout)*time.Second {
+			status.Phase = sandboxv1alpha1.TaskCanaryFailed
+			status.Message = fmt.Sprintf("canary task %s did not succeed within %ds", canary.GetName(), timeout)
+			return TaskAdmission{Admitted: 1, Abort: true, Canary: status}
+		}
+	}
+	return TaskAdmission{Admitted: 1, Canary: status}
+}
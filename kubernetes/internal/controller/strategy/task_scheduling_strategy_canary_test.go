@@ -0,0 +1,153 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package strategy
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	taskscheduler "github.com/alibaba/OpenSandbox/sandbox-k8s/internal/scheduler"
+	api "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
+)
+
+type fakeTask struct {
+	name    string
+	podName string
+	state   taskscheduler.TaskState
+	ready   bool
+}
+
+func (t *fakeTask) GetName() string                   { return t.name }
+func (t *fakeTask) GetState() taskscheduler.TaskState { return t.state }
+func (t *fakeTask) GetPodName() string                { return t.podName }
+func (t *fakeTask) IsResourceReleased() bool          { return false }
+func (t *fakeTask) IsReady() bool                     { return t.ready }
+func (t *fakeTask) IsDraining() bool                  { return false }
+func (t *fakeTask) IsOutdated() bool                  { return false }
+func (t *fakeTask) IsUpdating() bool                  { return false }
+func (t *fakeTask) GetFailureLogs() *api.TaskLogs     { return nil }
+
+func newCanaryBatchSandbox(timeoutSeconds int64) *sandboxv1alpha1.BatchSandbox {
+	bs := &sandboxv1alpha1.BatchSandbox{}
+	bs.Name = "canary"
+	bs.Spec.Replicas = ptr.To[int32](3)
+	bs.Spec.TaskTemplate = &sandboxv1alpha1.TaskTemplateSpec{}
+	bs.Spec.TaskCanary = &sandboxv1alpha1.TaskCanarySpec{TimeoutSeconds: timeoutSeconds}
+	return bs
+}
+
+// admitRound runs one reconcile round and records the canary status the way
+// the controller does.
+func admitRound(bs *sandboxv1alpha1.BatchSandbox, tasks []taskscheduler.Task, now time.Time) TaskAdmission {
+	admission := NewTaskSchedulingStrategy(bs).AdmitTasks(tasks, now)
+	bs.Status.TaskCanary = admission.Canary
+	return admission
+}
+
+func TestCanaryTaskSchedulingStrategy_SuccessThenRest(t *testing.T) {
+	bs := newCanaryBatchSandbox(60)
+	tasks := []taskscheduler.Task{&fakeTask{name: "canary-0"}, &fakeTask{name: "canary-1"}, &fakeTask{name: "canary-2"}}
+	canary := tasks[0].(*fakeTask)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	got := admitRound(bs, tasks, start)
+	if got.Admitted != 1 || got.Abort || got.Canary.Phase != sandboxv1alpha1.TaskCanaryPending {
+		t.Fatalf("expected only the canary admitted while pending, got %+v", got)
+	}
+
+	canary.podName, canary.state = "pod-0", taskscheduler.RunningTaskState
+	got = admitRound(bs, tasks, start.Add(time.Second))
+	if got.Admitted != 1 || got.Canary.Phase != sandboxv1alpha1.TaskCanaryRunning || !got.Canary.StartTime.Time.Equal(start.Add(time.Second)) {
+		t.Fatalf("expected running canary to hold back the rest, got %+v", got)
+	}
+
+	canary.state = taskscheduler.SucceedTaskState
+	got = admitRound(bs, tasks, start.Add(30*time.Second))
+	if got.Admitted >= 0 || got.Abort || got.Canary.Phase != sandboxv1alpha1.TaskCanarySucceeded {
+		t.Fatalf("expected every task admitted after canary success, got %+v", got)
+	}
+
+	// Succeeded is final: a later state of the canary task, e.g. after it is released, changes nothing.
+	canary.state = taskscheduler.UnknownTaskState
+	if got = admitRound(bs, tasks, start.Add(time.Hour)); got.Admitted >= 0 || got.Abort {
+		t.Fatalf("expected success to stick, got %+v", got)
+	}
+}
+
+func TestCanaryTaskSchedulingStrategy_ReadyWithStartupProbe(t *testing.T) {
+	bs := newCanaryBatchSandbox(0)
+	tasks := []taskscheduler.Task{&fakeTask{name: "canary-0", podName: "pod-0", state: taskscheduler.RunningTaskState, ready: true}, &fakeTask{name: "canary-1"}}
+
+	// Without a probe, a running canary holds back the rest even if reported ready.
+	if got := admitRound(bs, tasks, time.Now()); got.Admitted != 1 || got.Canary.Phase != sandboxv1alpha1.TaskCanaryRunning {
+		t.Fatalf("expected running canary without probe to hold back the rest, got %+v", got)
+	}
+
+	bs.Spec.TaskTemplate.Spec.StartupProbe = &corev1.Probe{ProbeHandler: corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"true"}}}}
+	tasks[0].(*fakeTask).ready = false
+	if got := admitRound(bs, tasks, time.Now()); got.Admitted != 1 || got.Canary.Phase != sandboxv1alpha1.TaskCanaryRunning {
+		t.Fatalf("expected canary to be held until ready, got %+v", got)
+	}
+	tasks[0].(*fakeTask).ready = true
+	if got := admitRound(bs, tasks, time.Now()); got.Admitted >= 0 || got.Canary.Phase != sandboxv1alpha1.TaskCanarySucceeded {
+		t.Fatalf("expected ready canary to release the rest, got %+v", got)
+	}
+}
+
+func TestCanaryTaskSchedulingStrategy_FailureAborts(t *testing.T) {
+	bs := newCanaryBatchSandbox(0)
+	tasks := []taskscheduler.Task{&fakeTask{name: "canary-0", podName: "pod-0", state: taskscheduler.FailedTaskState}, &fakeTask{name: "canary-1"}}
+
+	got := admitRound(bs, tasks, time.Now())
+	if got.Admitted != 1 || !got.Abort || got.Canary.Phase != sandboxv1alpha1.TaskCanaryFailed || got.Canary.Message == "" {
+		t.Fatalf("expected failed canary to abort the batch, got %+v", got)
+	}
+
+	// Failed is final, even for a scheduler rebuilt after a controller restart.
+	tasks[0] = &fakeTask{name: "canary-0"}
+	if got = admitRound(bs, tasks, time.Now()); got.Admitted != 1 || !got.Abort {
+		t.Fatalf("expected failure to stick, got %+v", got)
+	}
+}
+
+func TestCanaryTaskSchedulingStrategy_TimeoutAborts(t *testing.T) {
+	bs := newCanaryBatchSandbox(10)
+	tasks := []taskscheduler.Task{&fakeTask{name: "canary-0", podName: "pod-0", state: taskscheduler.RunningTaskState}}
+	start := time.Now()
+
+	if got := admitRound(bs, tasks, start); got.Abort {
+		t.Fatalf("expected no abort within the timeout, got %+v", got)
+	}
+	if got := admitRound(bs, tasks, start.Add(10*time.Second)); got.Abort {
+		t.Fatalf("expected no abort at the timeout, got %+v", got)
+	}
+	got := admitRound(bs, tasks, start.Add(11*time.Second))
+	if !got.Abort || got.Canary.Phase != sandboxv1alpha1.TaskCanaryFailed {
+		t.Fatalf("expected canary to time out, got %+v", got)
+	}
+}
+
+func TestDefaultTaskSchedulingStrategy_AdmitTasks(t *testing.T) {
+	bs := newCanaryBatchSandbox(0)
+	bs.Spec.TaskCanary = nil
+	got := NewTaskSchedulingStrategy(bs).AdmitTasks([]taskscheduler.Task{&fakeTask{name: "canary-0"}}, time.Now())
+	if got.Admitted >= 0 || got.Abort || got.Canary != nil {
+		t.Fatalf("expected every task admitted without a canary, got %+v", got)
+	}
+}
@@ -17,16 +17,23 @@ package strategy
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
 
 	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	taskscheduler "github.com/alibaba/OpenSandbox/sandbox-k8s/internal/scheduler"
 	api "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
 )
 
 // DefaultTaskSchedulingStrategy implements the default task scheduling strategy.
 type DefaultTaskSchedulingStrategy struct {
 	*sandboxv1alpha1.BatchSandbox
+	// parameters are the loaded rows of Spec.TaskParameters.
+	parameters *TaskParameters
 }
 
 // NewDefaultTaskSchedulingStrategy creates a new default task scheduling strategy.
@@ -55,14 +62,17 @@ func (s *DefaultTaskSchedulingStrategy) GenerateTaskSpecs() ([]*api.Task, error)
 }
 
 // getTaskSpec generates a single task specification for the given index.
-// It applies ShardTaskPatches if available, otherwise uses the base TaskTemplate.
+// It applies ShardTaskPatches if available, otherwise uses the base TaskTemplate,
+// and then renders placeholders when TaskTemplateEngine is GoTemplate. idx is
+// 0-based; only the task's name and injected index are shifted by ShardIndexBase.
 func (s *DefaultTaskSchedulingStrategy) getTaskSpec(idx int) (*api.Task, error) {
 	task := &api.Task{
-		Name: fmt.Sprintf("%s-%d", s.Name, idx),
+		Name:           fmt.Sprintf("%s-%d", s.Name, s.shardIndex(idx)),
+		IdempotencyKey: TaskIdempotencyKey(s.UID, idx),
 	}
+	taskTemplate := s.Spec.TaskTemplate
 	if len(s.Spec.ShardTaskPatches) > 0 && idx < len(s.Spec.ShardTaskPatches) {
-		taskTemplate := s.Spec.TaskTemplate.DeepCopy()
-		cloneBytes, _ := json.Marshal(taskTemplate)
+		cloneBytes, _ := json.Marshal(s.Spec.TaskTemplate.DeepCopy())
 		patch := s.Spec.ShardTaskPatches[idx]
 		modified, err := strategicpatch.StrategicMergePatch(cloneBytes, patch.Raw, &sandboxv1alpha1.TaskTemplateSpec{})
 		if err != nil {
@@ -72,21 +82,200 @@ func (s *DefaultTaskSchedulingStrategy) getTaskSpec(idx int) (*api.Task, error)
 		if err = json.Unmarshal(modified, newTaskTemplate); err != nil {
 			return nil, fmt.Errorf("batchsandbox: failed to unmarshal %s to TaskTemplateSpec, idx %d, err %w", modified, idx, err)
 		}
-		task.Process = &api.Process{
-			Command:        newTaskTemplate.Spec.Process.Command,
-			Args:           newTaskTemplate.Spec.Process.Args,
-			Env:            newTaskTemplate.Spec.Process.Env,
-			WorkingDir:     newTaskTemplate.Spec.Process.WorkingDir,
-			TimeoutSeconds: s.Spec.TaskTemplate.Spec.TimeoutSeconds,
+		if err = s.ensurePatchedCommand(idx, newTaskTemplate); err != nil {
+			return nil, err
 		}
-	} else if s.Spec.TaskTemplate != nil && s.Spec.TaskTemplate.Spec.Process != nil {
-		task.Process = &api.Process{
-			Command:        s.Spec.TaskTemplate.Spec.Process.Command,
-			Args:           s.Spec.TaskTemplate.Spec.Process.Args,
-			Env:            s.Spec.TaskTemplate.Spec.Process.Env,
-			WorkingDir:     s.Spec.TaskTemplate.Spec.Process.WorkingDir,
-			TimeoutSeconds: s.Spec.TaskTemplate.Spec.TimeoutSeconds,
+		taskTemplate = newTaskTemplate
+	}
+	if taskTemplate == nil || taskTemplate.Spec.Process == nil {
+		if taskTemplate != nil && len(taskTemplate.Spec.Sidecars) > 0 {
+			return nil, fmt.Errorf("batchsandbox: task template has sidecars but no process, idx %d", idx)
+		}
+		return task, nil
+	}
+	if s.Spec.TaskTemplateEngine == sandboxv1alpha1.TaskTemplateEngineGoTemplate {
+		rendered, err := renderTaskTemplate(taskTemplate, s.shardTemplateData(idx))
+		if err != nil {
+			return nil, fmt.Errorf("batchsandbox: failed to render task template, idx %d, err %w", idx, err)
 		}
+		taskTemplate = rendered
+	}
+	task.ServiceAccountName = s.resolveServiceAccountName(taskTemplate)
+	task.PriorityClassName = s.resolvePriorityClassName(taskTemplate)
+	task.Process = &api.Process{
+		Command:        taskTemplate.Spec.Process.Command,
+		Args:           s.parameters.withParameterArgs(taskTemplate.Spec.Process.Args, idx),
+		Env:            withDownwardAPIEnv(s.shardEnv(taskTemplate.Spec.Process.Env, idx), s.shardIndex(idx)),
+		WorkingDir:     taskTemplate.Spec.Process.WorkingDir,
+		TimeoutSeconds: s.Spec.TaskTemplate.Spec.TimeoutSeconds,
+		StartupProbe:   taskTemplate.Spec.StartupProbe,
+	}
+	task.Sidecars = sidecarTasks(taskTemplate.Spec.Sidecars)
+	if err := api.ValidateStartupProbe(task.Process.StartupProbe); err != nil {
+		return nil, fmt.Errorf("batchsandbox: invalid task template, idx %d, err %w", idx, err)
+	}
+	if err := api.ValidateSidecars(task.Sidecars, task.Process); err != nil {
+		return nil, fmt.Errorf("batchsandbox: invalid task template, idx %d, err %w", idx, err)
 	}
 	return task, nil
 }
+
+// shardEnv adds shard idx's TaskMatrix combination and parameter row to env.
+func (s *DefaultTaskSchedulingStrategy) shardEnv(env []corev1.EnvVar, idx int) []corev1.EnvVar {
+	env = withMatrixEnv(env, s.Spec.TaskMatrix, taskMatrixValues(s.Spec.TaskMatrix, idx))
+	return s.parameters.withParameterEnv(env, idx)
+}
+
+// shardIndex is the index shard idx is known by to its task and operators.
+func (s *DefaultTaskSchedulingStrategy) shardIndex(idx int) int {
+	return idx + int(s.Spec.ShardIndexBase)
+}
+
+// sidecarTasks maps the template's sidecars to the executor's. They inherit
+// the task's environment, shard index included, so nothing is added here.
+func sidecarTasks(sidecars []sandboxv1alpha1.SidecarProcess) []api.Sidecar {
+	if len(sidecars) == 0 {
+		return nil
+	}
+	out := make([]api.Sidecar, 0, len(sidecars))
+	for _, sidecar := range sidecars {
+		out = append(out, api.Sidecar{
+			Name:       sidecar.Name,
+			Command:    sidecar.Command,
+			Args:       sidecar.Args,
+			Env:        sidecar.Env,
+			WorkingDir: sidecar.WorkingDir,
+		})
+	}
+	return out
+}
+
+// ensurePatchedCommand handles a shard patch that left the task without a
+// command, following ShardTaskPatchPolicy: it fails, or puts back the
+// TaskTemplate's command, or its whole process if the patch removed that.
+// A patch is only held to this when the TaskTemplate has a process itself.
+func (s *DefaultTaskSchedulingStrategy) ensurePatchedCommand(idx int, patched *sandboxv1alpha1.TaskTemplateSpec) error {
+	base := s.Spec.TaskTemplate.Spec.Process
+	process := patched.Spec.Process
+	if base == nil || (process != nil && hasCommand(process.Command)) {
+		return nil
+	}
+	if s.Spec.ShardTaskPatchPolicy != sandboxv1alpha1.ShardTaskPatchPolicyUseTemplateCommand {
+		return fmt.Errorf("batchsandbox: shard task patch idx %d leaves the task without a command", idx)
+	}
+	if !hasCommand(base.Command) {
+		return fmt.Errorf("batchsandbox: shard task patch idx %d leaves the task without a command, and the task template has none to fall back to", idx)
+	}
+	if process == nil {
+		patched.Spec.Process = base.DeepCopy()
+		return nil
+	}
+	process.Command = slices.Clone(base.Command)
+	return nil
+}
+
+func hasCommand(command []string) bool {
+	return len(command) > 0 && command[0] != ""
+}
+
+// ValidateTaskTemplate generates every shard's task once, so broken patches,
+// template syntax or references to missing values are reported up front
+// instead of when a shard is scheduled.
+func (s *DefaultTaskSchedulingStrategy) ValidateTaskTemplate() error {
+	if !s.NeedTaskScheduling() || s.Spec.Replicas == nil {
+		return nil
+	}
+	switch s.Spec.TaskTemplateEngine {
+	case "", sandboxv1alpha1.TaskTemplateEngineNone, sandboxv1alpha1.TaskTemplateEngineGoTemplate:
+	default:
+		return fmt.Errorf("batchsandbox: unknown task template engine %q", s.Spec.TaskTemplateEngine)
+	}
+	if base := s.Spec.ShardIndexBase; base != 0 && base != 1 {
+		return fmt.Errorf("batchsandbox: shardIndexBase must be 0 or 1, got %d", base)
+	}
+	if err := validateTaskCompletionPolicy(s.Spec.TaskCompletionPolicy, *s.Spec.Replicas); err != nil {
+		return err
+	}
+	if len(s.Spec.TaskMatrix) > 0 {
+		replicas, err := TaskMatrixReplicas(s.Spec.TaskMatrix)
+		if err != nil {
+			return fmt.Errorf("batchsandbox: %w", err)
+		}
+		if replicas != *s.Spec.Replicas {
+			return fmt.Errorf("batchsandbox: replicas %d does not match the %d combinations of taskMatrix", *s.Spec.Replicas, replicas)
+		}
+	}
+	if s.Spec.TaskParameters != nil {
+		if len(s.Spec.TaskMatrix) > 0 {
+			return fmt.Errorf("batchsandbox: taskParameters cannot be combined with taskMatrix")
+		}
+		if s.parameters == nil {
+			return fmt.Errorf("batchsandbox: taskParameters have not been loaded")
+		}
+		if rows := len(s.parameters.Rows); int(*s.Spec.Replicas) != rows {
+			return fmt.Errorf("batchsandbox: replicas %d does not match the %d rows of taskParameters", *s.Spec.Replicas, rows)
+		}
+	}
+	tasks, err := s.GenerateTaskSpecs()
+	if err != nil {
+		return err
+	}
+	if s.Spec.PoolRef != "" {
+		// pooled Pods already exist, and neither a Pod's ServiceAccount nor
+		// its priority can change
+		inherited := s.resolveServiceAccountName(nil)
+		inheritedPriority := s.resolvePriorityClassName(nil)
+		for _, task := range tasks {
+			if task != nil && task.ServiceAccountName != inherited {
+				return fmt.Errorf("batchsandbox: task serviceAccountName is not supported with poolRef, task %s", task.Name)
+			}
+			if task != nil && task.PriorityClassName != inheritedPriority {
+				return fmt.Errorf("batchsandbox: task priorityClassName is not supported with poolRef, task %s", task.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// AdmitTasks admits every task at once.
+func (s *DefaultTaskSchedulingStrategy) AdmitTasks(_ []taskscheduler.Task, _ time.Time) TaskAdmission {
+	return TaskAdmission{Admitted: -1}
+}
+
+// TaskIdempotencyKey derives the key identifying a shard's task: the
+// BatchSandbox UID and the shard index. It stays stable across controller
+// restarts, so a task regenerated after a crash is recognized on the Pod it was
+// already submitted to. The scheduler appends the attempt when it submits the
+// task, so a drained or replaced shard is not taken for its previous
+// submission. It is empty for a BatchSandbox that has not been persisted yet.
+func TaskIdempotencyKey(uid types.UID, idx int) string {
+	if uid == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s-%d", uid, idx)
+}
+
+// resolveServiceAccountName returns the ServiceAccount a task should run as.
+// An explicit value on the (possibly patched) task template wins; otherwise the
+// ServiceAccount of the BatchSandbox pod template is inherited.
+func (s *DefaultTaskSchedulingStrategy) resolveServiceAccountName(taskTemplate *sandboxv1alpha1.TaskTemplateSpec) string {
+	if taskTemplate != nil && taskTemplate.Spec.ServiceAccountName != "" {
+		return taskTemplate.Spec.ServiceAccountName
+	}
+	if s.Spec.Template != nil {
+		return s.Spec.Template.Spec.ServiceAccountName
+	}
+	return ""
+}
+
+// resolvePriorityClassName returns the PriorityClass a task's shard runs at,
+// following the same precedence as resolveServiceAccountName.
+func (s *DefaultTaskSchedulingStrategy) resolvePriorityClassName(taskTemplate *sandboxv1alpha1.TaskTemplateSpec) string {
+	if taskTemplate != nil && taskTemplate.Spec.PriorityClassName != "" {
+		return taskTemplate.Spec.PriorityClassName
+	}
+	if s.Spec.Template != nil {
+		return s.Spec.Template.Spec.PriorityClassName
+	}
+	return ""
+}
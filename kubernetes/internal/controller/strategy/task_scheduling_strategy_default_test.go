@@ -15,11 +15,15 @@
 package strategy
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
 	api "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
@@ -95,6 +99,7 @@ func TestDefaultTaskSchedulingStrategy_getTaskSpec(t *testing.T) {
 				Name: "test-bs-0",
 				Process: &api.Process{
 					Command: []string{"echo", "hello"},
+					Env:     withDownwardAPIEnv(nil, 0),
 				},
 			},
 			wantErr: false,
@@ -128,6 +133,7 @@ func TestDefaultTaskSchedulingStrategy_getTaskSpec(t *testing.T) {
 				Name: "test-bs-0",
 				Process: &api.Process{
 					Command: []string{"echo", "world"},
+					Env:     withDownwardAPIEnv(nil, 0),
 				},
 			},
 			wantErr: false,
@@ -189,6 +195,7 @@ func TestDefaultTaskSchedulingStrategy_getTaskSpec(t *testing.T) {
 				Name: "test-bs-1",
 				Process: &api.Process{
 					Command: []string{"echo", "hello"},
+					Env:     withDownwardAPIEnv(nil, 1),
 				},
 			},
 			wantErr: false,
@@ -209,6 +216,660 @@ func TestDefaultTaskSchedulingStrategy_getTaskSpec(t *testing.T) {
 				if !reflect.DeepEqual(got.Process, tt.want.Process) {
 					t.Errorf("DefaultTaskSchedulingStrategy.getTaskSpec() spec = %v, want %v", got.Process, tt.want.Process)
 				}
+				if got.ServiceAccountName != tt.want.ServiceAccountName {
+					t.Errorf("DefaultTaskSchedulingStrategy.getTaskSpec() serviceAccountName = %v, want %v", got.ServiceAccountName, tt.want.ServiceAccountName)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultTaskSchedulingStrategy_GenerateTaskSpecs_ServiceAccountName(t *testing.T) {
+	replicas := int32(4)
+	batchSbx := &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-bs",
+			Namespace: "default",
+		},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			Replicas: &replicas,
+			Template: &corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					ServiceAccountName: "pod-sa",
+				},
+			},
+			TaskTemplate: &sandboxv1alpha1.TaskTemplateSpec{
+				Spec: sandboxv1alpha1.TaskSpec{
+					Process: &sandboxv1alpha1.ProcessTask{
+						Command: []string{"echo", "hello"},
+					},
+				},
+			},
+			ShardTaskPatches: []runtime.RawExtension{
+				{
+					Raw: []byte(`{"spec":{"serviceAccountName":"shard-0-sa"}}`),
+				},
+				{
+					Raw: []byte(`{"spec":{"process":{"command":["echo","world"]}}}`),
+				},
+				{
+					Raw: []byte(`{"spec":{"serviceAccountName":"shard-2-sa","process":{"command":["echo","two"]}}}`),
+				},
+			},
+		},
+	}
+	want := []string{"shard-0-sa", "pod-sa", "shard-2-sa", "pod-sa"}
+
+	tasks, err := NewDefaultTaskSchedulingStrategy(batchSbx).GenerateTaskSpecs()
+	if err != nil {
+		t.Fatalf("GenerateTaskSpecs() error = %v", err)
+	}
+	if len(tasks) != len(want) {
+		t.Fatalf("GenerateTaskSpecs() returned %d tasks, want %d", len(tasks), len(want))
+	}
+	for idx, task := range tasks {
+		if task.ServiceAccountName != want[idx] {
+			t.Errorf("task %d serviceAccountName = %q, want %q", idx, task.ServiceAccountName, want[idx])
+		}
+	}
+	if got := tasks[2].Process.Command; !reflect.DeepEqual(got, []string{"echo", "two"}) {
+		t.Errorf("task 2 command = %v, want [echo two]", got)
+	}
+
+	// An explicit ServiceAccount on the task template overrides the pod template.
+	batchSbx.Spec.TaskTemplate.Spec.ServiceAccountName = "task-sa"
+	tasks, err = NewDefaultTaskSchedulingStrategy(batchSbx).GenerateTaskSpecs()
+	if err != nil {
+		t.Fatalf("GenerateTaskSpecs() error = %v", err)
+	}
+	want = []string{"shard-0-sa", "task-sa", "shard-2-sa", "task-sa"}
+	for idx, task := range tasks {
+		if task.ServiceAccountName != want[idx] {
+			t.Errorf("task %d serviceAccountName = %q, want %q", idx, task.ServiceAccountName, want[idx])
+		}
+	}
+}
+
+func TestDefaultTaskSchedulingStrategy_GenerateTaskSpecs_PriorityClassName(t *testing.T) {
+	replicas := int32(3)
+	batchSbx := &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-bs",
+			Namespace: "default",
+		},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			Replicas: &replicas,
+			Template: &corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					PriorityClassName: "pod-priority",
+				},
+			},
+			TaskTemplate: &sandboxv1alpha1.TaskTemplateSpec{
+				Spec: sandboxv1alpha1.TaskSpec{
+					PriorityClassName: "batch-high",
+					Process: &sandboxv1alpha1.ProcessTask{
+						Command: []string{"echo", "hello"},
+					},
+				},
+			},
+			ShardTaskPatches: []runtime.RawExtension{
+				{
+					Raw: []byte(`{"spec":{"priorityClassName":"batch-critical"}}`),
+				},
+				{
+					Raw: []byte(`{"spec":{"process":{"command":["echo","world"]}}}`),
+				},
+			},
+		},
+	}
+	want := []string{"batch-critical", "batch-high", "batch-high"}
+
+	tasks, err := NewDefaultTaskSchedulingStrategy(batchSbx).GenerateTaskSpecs()
+	if err != nil {
+		t.Fatalf("GenerateTaskSpecs() error = %v", err)
+	}
+	for idx, task := range tasks {
+		if task.PriorityClassName != want[idx] {
+			t.Errorf("task %d priorityClassName = %q, want %q", idx, task.PriorityClassName, want[idx])
+		}
+	}
+
+	// Without a class on the task template, tasks inherit the pod template's.
+	batchSbx.Spec.TaskTemplate.Spec.PriorityClassName = ""
+	tasks, err = NewDefaultTaskSchedulingStrategy(batchSbx).GenerateTaskSpecs()
+	if err != nil {
+		t.Fatalf("GenerateTaskSpecs() error = %v", err)
+	}
+	want = []string{"batch-critical", "pod-priority", "pod-priority"}
+	for idx, task := range tasks {
+		if task.PriorityClassName != want[idx] {
+			t.Errorf("task %d priorityClassName = %q, want %q", idx, task.PriorityClassName, want[idx])
+		}
+	}
+}
+
+func TestDefaultTaskSchedulingStrategy_GenerateTaskSpecs_IdempotencyKey(t *testing.T) {
+	replicas := int32(2)
+	batchSbx := &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-bs",
+			Namespace: "default",
+			UID:       "4f2c1a",
+		},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			Replicas: &replicas,
+			TaskTemplate: &sandboxv1alpha1.TaskTemplateSpec{
+				Spec: sandboxv1alpha1.TaskSpec{
+					Process: &sandboxv1alpha1.ProcessTask{
+						Command: []string{"echo", "hello"},
+					},
+				},
+			},
+		},
+	}
+
+	first, err := NewDefaultTaskSchedulingStrategy(batchSbx).GenerateTaskSpecs()
+	if err != nil {
+		t.Fatalf("GenerateTaskSpecs() error = %v", err)
+	}
+	// A restarted controller regenerates the same keys.
+	second, err := NewDefaultTaskSchedulingStrategy(batchSbx.DeepCopy()).GenerateTaskSpecs()
+	if err != nil {
+		t.Fatalf("GenerateTaskSpecs() error = %v", err)
+	}
+	want := []string{"4f2c1a-0", "4f2c1a-1"}
+	for idx := range want {
+		if first[idx].IdempotencyKey != want[idx] || second[idx].IdempotencyKey != want[idx] {
+			t.Errorf("task %d idempotencyKey = %q/%q, want %q", idx, first[idx].IdempotencyKey, second[idx].IdempotencyKey, want[idx])
+		}
+	}
+
+	// An edited template rolled out to the shards keeps their keys.
+	edited := batchSbx.DeepCopy()
+	edited.Spec.TaskTemplate.Spec.Process.Command = []string{"echo", "bye"}
+	third, err := NewDefaultTaskSchedulingStrategy(edited).GenerateTaskSpecs()
+	if err != nil {
+		t.Fatalf("GenerateTaskSpecs() error = %v", err)
+	}
+	for idx := range want {
+		if third[idx].IdempotencyKey != want[idx] {
+			t.Errorf("edited task %d idempotencyKey = %q, want %q", idx, third[idx].IdempotencyKey, want[idx])
+		}
+	}
+
+	// A recreated BatchSandbox with the same name gets new keys.
+	batchSbx.UID = "9d8e7f"
+	tasks, err := NewDefaultTaskSchedulingStrategy(batchSbx).GenerateTaskSpecs()
+	if err != nil {
+		t.Fatalf("GenerateTaskSpecs() error = %v", err)
+	}
+	if tasks[0].IdempotencyKey != "9d8e7f-0" {
+		t.Errorf("idempotencyKey = %q, want key of the new UID", tasks[0].IdempotencyKey)
+	}
+}
+
+func TestDefaultTaskSchedulingStrategy_GenerateTaskSpecs_GoTemplate(t *testing.T) {
+	replicas := int32(3)
+	batchSbx := &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "train",
+			Namespace: "ml",
+		},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			Replicas:           &replicas,
+			TaskTemplateEngine: sandboxv1alpha1.TaskTemplateEngineGoTemplate,
+			TaskTemplateValues: map[string]string{"dataset": "s3://bucket/data", "lr": "0.1"},
+			ShardTaskValues:    []map[string]string{nil, {"lr": "0.01"}},
+			TaskTemplate: &sandboxv1alpha1.TaskTemplateSpec{
+				Spec: sandboxv1alpha1.TaskSpec{
+					Process: &sandboxv1alpha1.ProcessTask{
+						Command:    []string{"python", "train.py"},
+						Args:       []string{"--shard={{.Index}}/{{.Replicas}}", "--data={{.Values.dataset}}/part-{{printf \"%03d\" .Index}}", "--lr={{.Values.lr}}"},
+						Env:        []corev1.EnvVar{{Name: "JOB", Value: "{{.Namespace}}/{{.Name}}"}},
+						WorkingDir: "/work/{{.Index}}",
+					},
+				},
+			},
+			// patches are applied before rendering, so they may use placeholders too
+			ShardTaskPatches: []runtime.RawExtension{
+				{Raw: []byte(`{}`)},
+				{Raw: []byte(`{}`)},
+				{Raw: []byte(`{"spec":{"process":{"command":["python","eval.py","--shard={{.Index}}"]}}}`)},
+			},
+		},
+	}
+
+	tasks, err := NewDefaultTaskSchedulingStrategy(batchSbx).GenerateTaskSpecs()
+	if err != nil {
+		t.Fatalf("GenerateTaskSpecs() error = %v", err)
+	}
+	wantArgs := [][]string{
+		{"--shard=0/3", "--data=s3://bucket/data/part-000", "--lr=0.1"},
+		{"--shard=1/3", "--data=s3://bucket/data/part-001", "--lr=0.01"},
+		{"--shard=2/3", "--data=s3://bucket/data/part-002", "--lr=0.1"},
+	}
+	for idx, task := range tasks {
+		if !reflect.DeepEqual(task.Process.Args, wantArgs[idx]) {
+			t.Errorf("task %d args = %v, want %v", idx, task.Process.Args, wantArgs[idx])
+		}
+		if task.Process.Env[0].Value != "ml/train" {
+			t.Errorf("task %d env = %v, want ml/train", idx, task.Process.Env)
+		}
+		if want := fmt.Sprintf("/work/%d", idx); task.Process.WorkingDir != want {
+			t.Errorf("task %d workingDir = %q, want %q", idx, task.Process.WorkingDir, want)
+		}
+	}
+	if want := []string{"python", "eval.py", "--shard=2"}; !reflect.DeepEqual(tasks[2].Process.Command, want) {
+		t.Errorf("patched command = %v, want %v", tasks[2].Process.Command, want)
+	}
+	if batchSbx.Spec.TaskTemplate.Spec.Process.Args[0] != "--shard={{.Index}}/{{.Replicas}}" {
+		t.Errorf("rendering must not modify the BatchSandbox spec")
+	}
+
+	// Rendering is deterministic across reconciles.
+	again, err := NewDefaultTaskSchedulingStrategy(batchSbx.DeepCopy()).GenerateTaskSpecs()
+	if err != nil || !reflect.DeepEqual(tasks, again) {
+		t.Errorf("expected identical tasks on regeneration, err %v", err)
+	}
+}
+
+func TestDefaultTaskSchedulingStrategy_ValidateTaskTemplate(t *testing.T) {
+	newBatchSandbox := func(engine sandboxv1alpha1.TaskTemplateEngine, args ...string) *sandboxv1alpha1.BatchSandbox {
+		replicas := int32(2)
+		return &sandboxv1alpha1.BatchSandbox{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-bs"},
+			Spec: sandboxv1alpha1.BatchSandboxSpec{
+				Replicas:           &replicas,
+				TaskTemplateEngine: engine,
+				ShardTaskValues:    []map[string]string{{"input": "a.txt"}},
+				TaskTemplate: &sandboxv1alpha1.TaskTemplateSpec{
+					Spec: sandboxv1alpha1.TaskSpec{
+						Process: &sandboxv1alpha1.ProcessTask{Command: []string{"cat"}, Args: args},
+					},
+				},
+			},
+		}
+	}
+	tcpProbe := newBatchSandbox("")
+	tcpProbe.Spec.TaskTemplate.Spec.StartupProbe = &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(8080)}},
+	}
+	pooledServiceAccount := newBatchSandbox("")
+	pooledServiceAccount.Spec.PoolRef = "pool"
+	pooledServiceAccount.Spec.TaskTemplate.Spec.ServiceAccountName = "shard-sa"
+	pooledPriority := newBatchSandbox("")
+	pooledPriority.Spec.PoolRef = "pool"
+	pooledPriority.Spec.TaskTemplate.Spec.PriorityClassName = "batch-high"
+	tests := []struct {
+		name    string
+		bs      *sandboxv1alpha1.BatchSandbox
+		wantErr string
+	}{
+		{name: "valid template", bs: newBatchSandbox(sandboxv1alpha1.TaskTemplateEngineGoTemplate, "{{.Index}}")},
+		{name: "unsupported startup probe", bs: tcpProbe, wantErr: "exec or httpGet"},
+		{name: "placeholders are literal without an engine", bs: newBatchSandbox("", "{{.Values.missing}}")},
+		{name: "syntax error", bs: newBatchSandbox(sandboxv1alpha1.TaskTemplateEngineGoTemplate, "{{.Index"), wantErr: "taskTemplate.spec.process.args[0]"},
+		{name: "value missing for one shard", bs: newBatchSandbox(sandboxv1alpha1.TaskTemplateEngineGoTemplate, "{{.Values.input}}"), wantErr: "idx 1"},
+		{name: "unknown engine", bs: newBatchSandbox("Jsonnet"), wantErr: "unknown task template engine"},
+		{name: "service account with pool", bs: pooledServiceAccount, wantErr: "serviceAccountName is not supported with poolRef"},
+		{name: "priority class with pool", bs: pooledPriority, wantErr: "priorityClassName is not supported with poolRef"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewDefaultTaskSchedulingStrategy(tt.bs).ValidateTaskTemplate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateTaskTemplate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateTaskTemplate() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefaultTaskSchedulingStrategy_DownwardAPIEnv(t *testing.T) {
+	replicas := int32(2)
+	batchSbx := &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{Name: "shards", Namespace: "ml"},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			Replicas: &replicas,
+			TaskTemplate: &sandboxv1alpha1.TaskTemplateSpec{
+				Spec: sandboxv1alpha1.TaskSpec{
+					Process: &sandboxv1alpha1.ProcessTask{
+						Command: []string{"python", "shard.py"},
+						Env:     []corev1.EnvVar{{Name: "MODE", Value: "train"}},
+					},
+				},
+			},
+			// a shard that sets one of the names keeps its own value
+			ShardTaskPatches: []runtime.RawExtension{
+				{Raw: []byte(`{}`)},
+				{Raw: []byte(`{"spec":{"process":{"env":[{"name":"NODE_NAME","value":"pinned"}]}}}`)},
+			},
+		},
+	}
+
+	tasks, err := NewDefaultTaskSchedulingStrategy(batchSbx).GenerateTaskSpecs()
+	if err != nil {
+		t.Fatalf("GenerateTaskSpecs() error = %v", err)
+	}
+	fieldRef := func(name, path string) corev1.EnvVar {
+		return corev1.EnvVar{Name: name, ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{APIVersion: "v1", FieldPath: path}}}
+	}
+	want := [][]corev1.EnvVar{
+		{
+			{Name: "MODE", Value: "train"},
+			fieldRef("POD_NAME", "metadata.name"),
+			fieldRef("POD_NAMESPACE", "metadata.namespace"),
+			fieldRef("NODE_NAME", "spec.nodeName"),
+			{Name: "SHARD_INDEX", Value: "0"},
+		},
+		{
+			{Name: "NODE_NAME", Value: "pinned"},
+			fieldRef("POD_NAME", "metadata.name"),
+			fieldRef("POD_NAMESPACE", "metadata.namespace"),
+			{Name: "SHARD_INDEX", Value: "1"},
+		},
+	}
+	for idx, task := range tasks {
+		if !reflect.DeepEqual(task.Process.Env, want[idx]) {
+			t.Errorf("task %d env = %v, want %v", idx, task.Process.Env, want[idx])
+		}
+	}
+	if env := batchSbx.Spec.TaskTemplate.Spec.Process.Env; len(env) != 1 {
+		t.Errorf("injecting env must not modify the BatchSandbox spec, got %v", env)
+	}
+}
+
+func TestDefaultTaskSchedulingStrategy_ShardIndexBase(t *testing.T) {
+	replicas := int32(2)
+	batchSbx := &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{Name: "shards", Namespace: "ml", UID: "uid-1"},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			Replicas:           &replicas,
+			ShardIndexBase:     1,
+			TaskTemplateEngine: sandboxv1alpha1.TaskTemplateEngineGoTemplate,
+			TaskTemplate: &sandboxv1alpha1.TaskTemplateSpec{
+				Spec: sandboxv1alpha1.TaskSpec{
+					Process: &sandboxv1alpha1.ProcessTask{
+						Command:    []string{"python", "shard.py"},
+						Args:       []string{"--shard={{.Index}}"},
+						WorkingDir: "/{{.Values.role}}",
+					},
+				},
+			},
+			TaskTemplateValues: map[string]string{"role": "default"},
+			// patches and values stay addressed from 0
+			ShardTaskPatches: []runtime.RawExtension{
+				{Raw: []byte(`{"spec":{"process":{"workingDir":"/first"}}}`)},
+			},
+			ShardTaskValues: []map[string]string{{}, {"role": "second"}},
+		},
+	}
+
+	s := NewDefaultTaskSchedulingStrategy(batchSbx)
+	if err := s.ValidateTaskTemplate(); err != nil {
+		t.Fatalf("ValidateTaskTemplate() error = %v", err)
+	}
+	tasks, err := s.GenerateTaskSpecs()
+	if err != nil {
+		t.Fatalf("GenerateTaskSpecs() error = %v", err)
+	}
+	want := []struct {
+		name, shardIndex, arg, workingDir, key string
+	}{
+		{"shards-1", "1", "--shard=1", "/first", "uid-1-0"},
+		{"shards-2", "2", "--shard=2", "/second", "uid-1-1"},
+	}
+	for idx, task := range tasks {
+		w := want[idx]
+		if task.Name != w.name {
+			t.Errorf("task %d name = %q, want %q", idx, task.Name, w.name)
+		}
+		if got := task.Process.Env[len(task.Process.Env)-1]; got.Name != EnvShardIndex || got.Value != w.shardIndex {
+			t.Errorf("task %d %s = %v, want %q", idx, EnvShardIndex, got, w.shardIndex)
+		}
+		if !reflect.DeepEqual(task.Process.Args, []string{w.arg}) {
+			t.Errorf("task %d args = %v, want %q", idx, task.Process.Args, w.arg)
+		}
+		if task.Process.WorkingDir != w.workingDir {
+			t.Errorf("task %d workingDir = %q, want %q", idx, task.Process.WorkingDir, w.workingDir)
+		}
+		if task.IdempotencyKey != w.key {
+			t.Errorf("task %d idempotency key = %q, want %q", idx, task.IdempotencyKey, w.key)
+		}
+	}
+
+	batchSbx.Spec.ShardIndexBase = 2
+	if err := s.ValidateTaskTemplate(); err == nil || !strings.Contains(err.Error(), "shardIndexBase") {
+		t.Errorf("ValidateTaskTemplate() error = %v, want shardIndexBase rejected", err)
+	}
+}
+
+func TestDefaultTaskSchedulingStrategy_PatchRemovesCommand(t *testing.T) {
+	newBatch := func(policy sandboxv1alpha1.ShardTaskPatchPolicy, patch string) *sandboxv1alpha1.BatchSandbox {
+		replicas := int32(2)
+		return &sandboxv1alpha1.BatchSandbox{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-bs", Namespace: "default"},
+			Spec: sandboxv1alpha1.BatchSandboxSpec{
+				Replicas: &replicas,
+				TaskTemplate: &sandboxv1alpha1.TaskTemplateSpec{
+					Spec: sandboxv1alpha1.TaskSpec{
+						Process: &sandboxv1alpha1.ProcessTask{
+							Command:    []string{"python", "train.py"},
+							Args:       []string{"--epochs", "3"},
+							WorkingDir: "/workspace",
+						},
+					},
+				},
+				ShardTaskPatches:     []runtime.RawExtension{{}, {Raw: []byte(patch)}},
+				ShardTaskPatchPolicy: policy,
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		policy  sandboxv1alpha1.ShardTaskPatchPolicy
+		patch   string
+		want    *sandboxv1alpha1.ProcessTask
+		wantErr string
+	}{
+		{
+			name:    "null command fails by default",
+			patch:   `{"spec":{"process":{"command":null,"args":["--epochs","5"]}}}`,
+			wantErr: "shard task patch idx 1 leaves the task without a command",
+		},
+		{
+			name:    "null process fails",
+			policy:  sandboxv1alpha1.ShardTaskPatchPolicyFail,
+			patch:   `{"spec":{"process":null}}`,
+			wantErr: "shard task patch idx 1 leaves the task without a command",
+		},
+		{
+			name:    "empty command fails",
+			patch:   `{"spec":{"process":{"command":[]}}}`,
+			wantErr: "shard task patch idx 1 leaves the task without a command",
+		},
+		{
+			name:   "null command falls back to the template command",
+			policy: sandboxv1alpha1.ShardTaskPatchPolicyUseTemplateCommand,
+			patch:  `{"spec":{"process":{"command":null,"args":["--epochs","5"]}}}`,
+			want:   &sandboxv1alpha1.ProcessTask{Command: []string{"python", "train.py"}, Args: []string{"--epochs", "5"}, WorkingDir: "/workspace"},
+		},
+		{
+			name:   "null process falls back to the template process",
+			policy: sandboxv1alpha1.ShardTaskPatchPolicyUseTemplateCommand,
+			patch:  `{"spec":{"process":null}}`,
+			want:   &sandboxv1alpha1.ProcessTask{Command: []string{"python", "train.py"}, Args: []string{"--epochs", "3"}, WorkingDir: "/workspace"},
+		},
+		{
+			name:   "a patch that keeps a command is untouched",
+			policy: sandboxv1alpha1.ShardTaskPatchPolicyUseTemplateCommand,
+			patch:  `{"spec":{"process":{"command":["python","eval.py"]}}}`,
+			want:   &sandboxv1alpha1.ProcessTask{Command: []string{"python", "eval.py"}, Args: []string{"--epochs", "3"}, WorkingDir: "/workspace"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy := NewDefaultTaskSchedulingStrategy(newBatch(tt.policy, tt.patch))
+			err := strategy.ValidateTaskTemplate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ValidateTaskTemplate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateTaskTemplate() error = %v", err)
+			}
+			tasks, err := strategy.GenerateTaskSpecs()
+			if err != nil {
+				t.Fatalf("GenerateTaskSpecs() error = %v", err)
+			}
+			got := tasks[1].Process
+			if !reflect.DeepEqual(got.Command, tt.want.Command) || !reflect.DeepEqual(got.Args, tt.want.Args) || got.WorkingDir != tt.want.WorkingDir {
+				t.Errorf("shard 1 process = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(tasks[0].Process.Command, []string{"python", "train.py"}) {
+				t.Errorf("unpatched shard 0 command = %v", tasks[0].Process.Command)
+			}
+		})
+	}
+}
+
+func TestDefaultTaskSchedulingStrategy_GenerateTaskSpecs_Sidecars(t *testing.T) {
+	replicas := int32(3)
+	batch := &sandboxv1alpha1.BatchSandbox{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-bs", Namespace: "default"},
+		Spec: sandboxv1alpha1.BatchSandboxSpec{
+			Replicas: &replicas,
+			TaskTemplate: &sandboxv1alpha1.TaskTemplateSpec{
+				Spec: sandboxv1alpha1.TaskSpec{
+					Process: &sandboxv1alpha1.ProcessTask{Command: []string{"python", "train.py"}},
+					Sidecars: []sandboxv1alpha1.SidecarProcess{
+						{Name: "log-shipper", Command: []string{"fluent-bit"}, Args: []string{"-c", "/etc/fluent-bit.conf"}},
+						{Name: "metrics", Command: []string{"node-agent"}, Env: []corev1.EnvVar{{Name: "PORT", Value: "9100"}}, WorkingDir: "/opt/agent"},
+					},
+				},
+			},
+			ShardTaskPatches: []runtime.RawExtension{
+				{},
+				{Raw: []byte(`{"spec":{"sidecars":[{"name":"metrics","args":["--verbose"]}]}}`)},
+				{Raw: []byte(`{"spec":{"sidecars":[{"name":"metrics","$patch":"delete"}]}}`)},
+			},
+		},
+	}
+
+	tasks, err := NewDefaultTaskSchedulingStrategy(batch).GenerateTaskSpecs()
+	if err != nil {
+		t.Fatalf("GenerateTaskSpecs() error = %v", err)
+	}
+	shipper := api.Sidecar{Name: "log-shipper", Command: []string{"fluent-bit"}, Args: []string{"-c", "/etc/fluent-bit.conf"}}
+	metrics := api.Sidecar{Name: "metrics", Command: []string{"node-agent"}, Env: []corev1.EnvVar{{Name: "PORT", Value: "9100"}}, WorkingDir: "/opt/agent"}
+	verboseMetrics := metrics
+	verboseMetrics.Args = []string{"--verbose"}
+	want := [][]api.Sidecar{
+		{shipper, metrics},
+		{shipper, verboseMetrics},
+		{shipper},
+	}
+	for idx, task := range tasks {
+		if !reflect.DeepEqual(task.Sidecars, want[idx]) {
+			t.Errorf("shard %d sidecars = %+v, want %+v", idx, task.Sidecars, want[idx])
+		}
+	}
+}
+
+func TestDefaultTaskSchedulingStrategy_GenerateTaskSpecs_SpecHash(t *testing.T) {
+	generate := func(patch string) []*api.Task {
+		t.Helper()
+		replicas := int32(2)
+		batch := &sandboxv1alpha1.BatchSandbox{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-bs", Namespace: "default", UID: "uid"},
+			Spec: sandboxv1alpha1.BatchSandboxSpec{
+				Replicas: &replicas,
+				TaskTemplate: &sandboxv1alpha1.TaskTemplateSpec{
+					Spec: sandboxv1alpha1.TaskSpec{
+						Process: &sandboxv1alpha1.ProcessTask{Command: []string{"python", "train.py"}, Env: []corev1.EnvVar{{Name: "EPOCHS", Value: "10"}}},
+					},
+				},
+				ShardTaskPatches: []runtime.RawExtension{{}, {Raw: []byte(patch)}},
+			},
+		}
+		tasks, err := NewDefaultTaskSchedulingStrategy(batch).GenerateTaskSpecs()
+		if err != nil {
+			t.Fatalf("GenerateTaskSpecs() error = %v", err)
+		}
+		return tasks
+	}
+	patch := `{"spec":{"process":{"args":["--lr","0.1"]}}}`
+	first, second := generate(patch), generate(patch)
+	for idx := range first {
+		if got, want := api.SpecHash(second[idx]), api.SpecHash(first[idx]); got != want {
+			t.Errorf("shard %d: identical specs hash to %s and %s", idx, want, got)
+		}
+	}
+	changed := generate(`{"spec":{"process":{"args":["--lr","0.2"]}}}`)
+	if api.SpecHash(changed[0]) != api.SpecHash(first[0]) {
+		t.Errorf("shard 0 is not patched, its hash should not change")
+	}
+	if api.SpecHash(changed[1]) == api.SpecHash(first[1]) {
+		t.Errorf("a changed patch should change shard 1's hash")
+	}
+}
+
+func TestDefaultTaskSchedulingStrategy_ValidateTaskTemplate_Sidecars(t *testing.T) {
+	replicas := int32(1)
+	newBatch := func(spec sandboxv1alpha1.TaskSpec) *sandboxv1alpha1.BatchSandbox {
+		return &sandboxv1alpha1.BatchSandbox{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-bs", Namespace: "default"},
+			Spec: sandboxv1alpha1.BatchSandboxSpec{
+				Replicas:     &replicas,
+				TaskTemplate: &sandboxv1alpha1.TaskTemplateSpec{Spec: spec},
+			},
+		}
+	}
+	process := &sandboxv1alpha1.ProcessTask{Command: []string{"python", "train.py"}}
+	tests := []struct {
+		name    string
+		spec    sandboxv1alpha1.TaskSpec
+		wantErr string
+	}{
+		{
+			name:    "sidecars without a process",
+			spec:    sandboxv1alpha1.TaskSpec{Sidecars: []sandboxv1alpha1.SidecarProcess{{Name: "logs", Command: []string{"ship"}}}},
+			wantErr: "sidecars but no process",
+		},
+		{
+			name:    "duplicate names",
+			spec:    sandboxv1alpha1.TaskSpec{Process: process, Sidecars: []sandboxv1alpha1.SidecarProcess{{Name: "logs", Command: []string{"a"}}, {Name: "logs", Command: []string{"b"}}}},
+			wantErr: "not unique",
+		},
+		{
+			name:    "missing command",
+			spec:    sandboxv1alpha1.TaskSpec{Process: process, Sidecars: []sandboxv1alpha1.SidecarProcess{{Name: "logs"}}},
+			wantErr: "command is empty",
+		},
+		{
+			name: "valid",
+			spec: sandboxv1alpha1.TaskSpec{Process: process, Sidecars: []sandboxv1alpha1.SidecarProcess{{Name: "logs", Command: []string{"ship"}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewDefaultTaskSchedulingStrategy(newBatch(tt.spec)).ValidateTaskTemplate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateTaskTemplate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateTaskTemplate() error = %v, want %q", err, tt.wantErr)
 			}
 		})
 	}
@@ -21,5 +21,19 @@ import (
 // NewTaskSchedulingStrategy creates a task scheduling strategy based on BatchSandbox properties.
 // This function is designed to be easily customizable for different implementations:
 func NewTaskSchedulingStrategy(batchSbx *sandboxv1alpha1.BatchSandbox) TaskSchedulingStrategy {
-	return NewDefaultTaskSchedulingStrategy(batchSbx)
+	return NewTaskSchedulingStrategyWithParameters(batchSbx, nil)
+}
+
+// NewTaskSchedulingStrategyWithParameters creates a task scheduling strategy
+// that hands out the rows of params, loaded from Spec.TaskParameters, to the
+// shards.
+func NewTaskSchedulingStrategyWithParameters(batchSbx *sandboxv1alpha1.BatchSandbox, params *TaskParameters) TaskSchedulingStrategy {
+	if batchSbx.Spec.TaskCanary != nil {
+		s := NewCanaryTaskSchedulingStrategy(batchSbx)
+		s.parameters = params
+		return s
+	}
+	s := NewDefaultTaskSchedulingStrategy(batchSbx)
+	s.parameters = params
+	return s
 }
@@ -0,0 +1,141 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package strategy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+)
+
+// taskTemplateData is what a GoTemplate task template sees for one shard.
+type taskTemplateData struct {
+	Index     int
+	Replicas  int
+	Name      string
+	Namespace string
+	Values    map[string]string
+}
+
+// shardTemplateData builds the template data for shard idx, with its
+// TaskMatrix combination, its parameter row and then ShardTaskValues[idx] overlaid on
+// TaskTemplateValues. .Index starts at ShardIndexBase.
+func (s *DefaultTaskSchedulingStrategy) shardTemplateData(idx int) taskTemplateData {
+	values := make(map[string]string, len(s.Spec.TaskTemplateValues)+len(s.Spec.TaskMatrix))
+	for k, v := range s.Spec.TaskTemplateValues {
+		values[k] = v
+	}
+	for k, v := range taskMatrixValues(s.Spec.TaskMatrix, idx) {
+		values[k] = v
+	}
+	for k, v := range s.parameters.row(idx) {
+		values[k] = v
+	}
+	if idx < len(s.Spec.ShardTaskValues) {
+		for k, v := range s.Spec.ShardTaskValues[idx] {
+			values[k] = v
+		}
+	}
+	replicas := 0
+	if s.Spec.Replicas != nil {
+		replicas = int(*s.Spec.Replicas)
+	}
+	return taskTemplateData{
+		Index:     s.shardIndex(idx),
+		Replicas:  replicas,
+		Name:      s.Name,
+		Namespace: s.Namespace,
+		Values:    values,
+	}
+}
+
+// renderTaskTemplate renders every string field of taskTemplate as a Go
+// template. Referencing a missing value is an error, and only the builtin
+// template functions are available, so the output depends on data alone.
+func renderTaskTemplate(taskTemplate *sandboxv1alpha1.TaskTemplateSpec, data taskTemplateData) (*sandboxv1alpha1.TaskTemplateSpec, error) {
+	raw, err := json.Marshal(taskTemplate)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	doc, err = renderTemplateValue(doc, "taskTemplate", data)
+	if err != nil {
+		return nil, err
+	}
+	if raw, err = json.Marshal(doc); err != nil {
+		return nil, err
+	}
+	rendered := &sandboxv1alpha1.TaskTemplateSpec{}
+	if err := json.Unmarshal(raw, rendered); err != nil {
+		return nil, err
+	}
+	return rendered, nil
+}
+
+func renderTemplateValue(v any, path string, data taskTemplateData) (any, error) {
+	switch val := v.(type) {
+	case string:
+		return renderTemplateString(val, path, data)
+	case []any:
+		for i := range val {
+			item, err := renderTemplateValue(val[i], fmt.Sprintf("%s[%d]", path, i), data)
+			if err != nil {
+				return nil, err
+			}
+			val[i] = item
+		}
+		return val, nil
+	case map[string]any:
+		// walk keys in order so the first error reported is stable
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			item, err := renderTemplateValue(val[k], path+"."+k, data)
+			if err != nil {
+				return nil, err
+			}
+			val[k] = item
+		}
+		return val, nil
+	default:
+		return v, nil
+	}
+}
+
+func renderTemplateString(s, path string, data taskTemplateData) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	tmpl, err := template.New(path).Option("missingkey=error").Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return out.String(), nil
+}
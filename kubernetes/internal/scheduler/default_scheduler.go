@@ -17,6 +17,8 @@ package scheduler
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -39,8 +41,12 @@ var (
 )
 
 type taskSpec struct {
-	Process         *api.Process
-	PodTemplateSpec *corev1.PodTemplateSpec
+	IdempotencyKey     string
+	ServiceAccountName string
+	PriorityClassName  string
+	Process            *api.Process
+	PodTemplateSpec    *corev1.PodTemplateSpec
+	Sidecars           []api.Sidecar
 }
 
 type taskNode struct {
@@ -59,6 +65,24 @@ type taskNode struct {
 	// inner sch state
 	sStateLastTransTime *time.Time
 	sState              string
+	// draining is set while the task is stopped to drain its Pod; once released
+	// the node goes back to pending instead.
+	draining bool
+	// outdated is set while the executor runs a spec other than Spec, which
+	// UpdateTaskSpecs replaced; updating while that task is stopped so Spec can
+	// be submitted to the same Pod.
+	outdated bool
+	updating bool
+	// submittedHash is the spec hash last submitted to the executor, which
+	// stands in for the hash an executor that reports none was given.
+	submittedHash string
+	// attempt counts the submissions of Spec dropped so far: it is raised
+	// whenever the node's task is rescheduled or replaced, so the executor
+	// never takes the next submission for the previous one.
+	attempt int
+	// failureLogs are the logs captured when the task failed.
+	failureLogs        *api.TaskLogs
+	failureLogsFetched bool
 }
 
 func (t *taskNode) GetPodName() string {
@@ -73,6 +97,31 @@ func (t *taskNode) IsResourceReleased() bool {
 	return t.sState == stateReleased
 }
 
+func (t *taskNode) IsReady() bool {
+	if t.tState != RunningTaskState || t.Status == nil {
+		return false
+	}
+	// Without a probe there is nothing to wait for; this also covers executors
+	// that predate readiness reporting.
+	if t.Spec.Process == nil || t.Spec.Process.StartupProbe == nil {
+		return true
+	}
+	status := t.Status.ProcessStatus
+	return status != nil && status.Running != nil && status.Running.Ready
+}
+
+func (t *taskNode) IsDraining() bool {
+	return t.draining
+}
+
+func (t *taskNode) IsOutdated() bool {
+	return t.outdated
+}
+
+func (t *taskNode) IsUpdating() bool {
+	return t.updating
+}
+
 func (t *taskNode) isTaskCompleted() bool {
 	return t.tState == SucceedTaskState || t.tState == FailedTaskState
 }
@@ -81,6 +130,40 @@ func (t *taskNode) isTaskDeleted() bool {
 	return t.Status == nil
 }
 
+// ownsTask reports whether a task found on an executor is a submission of this
+// node, of any attempt. Tasks submitted before idempotency keys existed are
+// matched by name.
+func (t *taskNode) ownsTask(task *api.Task) bool {
+	if task.IdempotencyKey != "" || t.Spec.IdempotencyKey != "" {
+		key, _, ok := parseSubmissionKey(task.IdempotencyKey)
+		return ok && key == t.Spec.IdempotencyKey
+	}
+	return task.Name == t.Name
+}
+
+// submissionKey is the idempotency key the node's current attempt is
+// submitted with.
+func (t *taskNode) submissionKey() string {
+	if t.Spec.IdempotencyKey == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s-%d", t.Spec.IdempotencyKey, t.attempt)
+}
+
+// parseSubmissionKey splits a submitted idempotency key into the shard's key
+// and the attempt.
+func parseSubmissionKey(key string) (string, int, bool) {
+	i := strings.LastIndex(key, "-")
+	if i <= 0 {
+		return "", 0, false
+	}
+	attempt, err := strconv.Atoi(key[i+1:])
+	if err != nil || attempt < 0 {
+		return "", 0, false
+	}
+	return key[:i], attempt, true
+}
+
 func (t *taskNode) transSchState(to string) {
 	if t.sState == to {
 		return
@@ -96,6 +179,28 @@ func (t *taskNode) transSchState(to string) {
 	klog.Infof("task node %s trans sch state %s -> %s, latency=%dms", klog.KObj(t), from, to, lat.Milliseconds())
 }
 
+// unassign puts a drained node back to pending, forgetting the Pod and the
+// state its task reached there.
+func (t *taskNode) unassign() {
+	klog.Infof("task node %s leaves drained Pod %s:%s, back to pending", klog.KObj(t), t.PodName, t.IP)
+	t.IP, t.PodName, t.Status = "", "", nil
+	t.tState, t.tStateLastTransTime = "", nil
+	t.transSchState("")
+	t.draining, t.outdated, t.updating = false, false, false
+	t.attempt++
+}
+
+// restart keeps the node on its Pod after its outdated task stopped, so the
+// current spec is submitted there next.
+func (t *taskNode) restart() {
+	klog.Infof("task node %s replaced its outdated task on Pod %s:%s", klog.KObj(t), t.PodName, t.IP)
+	t.Status = nil
+	t.tState, t.tStateLastTransTime = "", nil
+	t.transSchState("")
+	t.outdated, t.updating = false, false
+	t.attempt++
+}
+
 func (t *taskNode) transTaskState(to TaskState) {
 	if t.tState == to {
 		return
@@ -122,9 +227,12 @@ const (
 	   assigned -- "when BatchSandbox's deletion timestamp != 0" --> releasing
 	   assigned -- "when task state is SUCCEED && policy is allowed" --> releasing
 	   assigned -- "when task state is FAILED && policy is allowed" --> releasing
+	   assigned -- "when its outdated task is replaced" --> releasing
 	   assigned -- "set Task"
 
 	   releasing -- "when endpoint returns nil task or endpoint lost too many times  (e.g., force-deleted), endpoint is nil(unassigned)" --> released
+	   releasing -- "when draining and endpoint returns nil task" --> pending
+	   releasing -- "when updating and endpoint returns nil task" --> assigned
 
 	   released --> $end
 	*/
@@ -138,6 +246,7 @@ const (
 type taskClient interface {
 	Set(ctx context.Context, task *api.Task) (*api.Task, error)
 	Get(ctx context.Context) (*api.Task, error)
+	Logs(ctx context.Context, name string, tailLines, limitBytes int) (*api.TaskLogs, error)
 }
 
 const (
@@ -163,20 +272,28 @@ type defaultTaskScheduler struct {
 
 	maxConcurrency int
 	once           sync.Once
+	// admitted is the number of leading task nodes that may be assigned; nil admits all.
+	admitted *int
+	// drainingPods holds when DrainPod was first called for each Pod; they are
+	// never handed out again.
+	drainingPods map[string]time.Time
 
 	taskStatusCollector       taskStatusCollector
 	taskClientCreator         taskClientCreator
 	resPolicyWhenTaskComplete sandboxv1alpha1.TaskResourcePolicy
-	name                      string
+	// failureLogs, when set, captures the logs of failed tasks.
+	failureLogs *FailureLogCapture
+	name        string
 }
 
-func newTaskScheduler(name string, tasks []*api.Task, pods []*corev1.Pod, resPolicyWhenTaskComplete sandboxv1alpha1.TaskResourcePolicy) (*defaultTaskScheduler, error) {
+func newTaskScheduler(name string, tasks []*api.Task, pods []*corev1.Pod, resPolicyWhenTaskComplete sandboxv1alpha1.TaskResourcePolicy, failureLogs *FailureLogCapture) (*defaultTaskScheduler, error) {
 	sch := &defaultTaskScheduler{
 		allPods:                   pods,
 		maxConcurrency:            defaultSchConcurrency,
 		taskClientCreator:         newTaskClient,
 		taskStatusCollector:       newTaskStatusCollector(newTaskClient),
 		resPolicyWhenTaskComplete: resPolicyWhenTaskComplete,
+		failureLogs:               failureLogs,
 		name:                      name,
 	}
 	taskNodes, err := initTaskNodes(tasks)
@@ -206,11 +323,133 @@ func indexByName(taskNodes []*taskNode) map[string]*taskNode {
 func (sch *defaultTaskScheduler) Schedule() error {
 	sch.refreshFreePods()
 	sch.collectTaskStatus(sch.taskNodes)
+	// before scheduleTaskNodes releases the failed tasks
+	sch.captureFailureLogs()
+	sch.adoptSubmittedTasks()
 	return sch.scheduleTaskNodes()
 }
 
+// adoptSubmittedTasks checks free Pods before any task is submitted to them.
+// A Pod already running an unassigned node's task (e.g. submitted right before a
+// controller restart and missed by recovery) is bound back to that node instead
+// of receiving another task; a Pod running any other task is never handed out,
+// since setting a task replaces whatever the executor runs.
+func (sch *defaultTaskScheduler) adoptSubmittedTasks() {
+	unassigned := make(map[string]*taskNode)
+	for _, tNode := range sch.taskNodes {
+		if tNode.IP == "" && tNode.DeletionTimestamp == nil {
+			unassigned[tNode.Name] = tNode
+		}
+	}
+	if len(unassigned) == 0 || len(sch.freePods) == 0 {
+		return
+	}
+	ips := make([]string, 0, len(sch.freePods))
+	for _, pod := range sch.freePods {
+		ips = append(ips, pod.Status.PodIP)
+	}
+	tasks := sch.taskStatusCollector.Collect(context.Background(), ips)
+	free := sch.freePods[:0]
+	for _, pod := range sch.freePods {
+		task := tasks[pod.Status.PodIP]
+		if task == nil {
+			free = append(free, pod)
+			continue
+		}
+		if tNode := unassigned[task.Name]; tNode != nil && tNode.ownsTask(task) {
+			klog.Infof("adopt task %s already submitted to Pod %s:%s", tNode.Name, klog.KObj(pod), pod.Status.PodIP)
+			recoverOneTaskNode(tNode, task, pod.Status.PodIP, pod.Name)
+			delete(unassigned, tNode.Name)
+			continue
+		}
+		klog.Warningf("Pod %s:%s runs task %s (key %q) not owned by any pending task node, skip it", klog.KObj(pod), pod.Status.PodIP, task.Name, task.IdempotencyKey)
+	}
+	sch.freePods = free
+}
+
 func (sch *defaultTaskScheduler) UpdatePods(pods []*corev1.Pod) {
 	sch.allPods = pods
+	if len(sch.drainingPods) == 0 {
+		return
+	}
+	// a drained Pod is recreated under the same name, which must be free again
+	present := make(map[string]bool, len(pods))
+	for _, pod := range pods {
+		present[pod.Name] = true
+	}
+	for name := range sch.drainingPods {
+		if !present[name] {
+			delete(sch.drainingPods, name)
+		}
+	}
+}
+
+func (sch *defaultTaskScheduler) DrainPod(podName string, grace time.Duration) bool {
+	if sch.drainingPods == nil {
+		sch.drainingPods = make(map[string]time.Time)
+	}
+	since, ok := sch.drainingPods[podName]
+	if !ok {
+		since = timeNow()
+		sch.drainingPods[podName] = since
+	}
+	for _, tNode := range sch.taskNodes {
+		if tNode.PodName != podName || tNode.IP == "" {
+			continue
+		}
+		// a finished task is not run again, and a stopped one is not rescheduled
+		if tNode.isTaskCompleted() || tNode.DeletionTimestamp != nil {
+			return true
+		}
+		if timeNow().Sub(since) >= grace {
+			klog.Warningf("task %s did not stop within %v of draining Pod %s, reschedule it anyway", klog.KObj(tNode), grace, podName)
+			tNode.unassign()
+			return true
+		}
+		if !tNode.draining {
+			klog.Infof("draining Pod %s, stopping task %s", podName, klog.KObj(tNode))
+			tNode.draining = true
+		}
+		return false
+	}
+	return true
+}
+
+func (sch *defaultTaskScheduler) UpdateTaskSpecs(tasks []*api.Task) {
+	for _, task := range tasks {
+		if tNode := sch.taskNodeByNameIndex[task.Name]; tNode != nil {
+			tNode.Spec = newTaskSpec(task)
+		}
+	}
+}
+
+func (sch *defaultTaskScheduler) ReplaceTask(name string) bool {
+	tNode := sch.taskNodeByNameIndex[name]
+	if tNode == nil || !tNode.outdated || tNode.updating || tNode.IP == "" {
+		return false
+	}
+	if tNode.isTaskCompleted() || tNode.DeletionTimestamp != nil || tNode.draining {
+		return false
+	}
+	klog.Infof("replacing outdated task %s on Pod %s", klog.KObj(tNode), tNode.PodName)
+	tNode.updating = true
+	return true
+}
+
+func (sch *defaultTaskScheduler) SetAdmitted(n int) {
+	if n < 0 {
+		sch.admitted = nil
+		return
+	}
+	sch.admitted = &n
+}
+
+// admittedTaskNodes returns the task nodes that may be assigned to a Pod.
+func (sch *defaultTaskScheduler) admittedTaskNodes() []*taskNode {
+	if sch.admitted == nil || *sch.admitted >= len(sch.taskNodes) {
+		return sch.taskNodes
+	}
+	return sch.taskNodes[:*sch.admitted]
 }
 
 func (sch *defaultTaskScheduler) ListTask() []Task {
@@ -233,6 +472,18 @@ func (sch *defaultTaskScheduler) StopTask() []Task {
 	return deletedTask
 }
 
+func (sch *defaultTaskScheduler) StopUnfinishedTask() []Task {
+	var stopped []Task
+	for _, tNode := range sch.taskNodes {
+		if tNode.DeletionTimestamp != nil || tNode.isTaskCompleted() {
+			continue
+		}
+		tNode.DeletionTimestamp = &metav1.Time{Time: timeNow()}
+		stopped = append(stopped, tNode)
+	}
+	return stopped
+}
+
 func initTaskNodes(tasks []*api.Task) ([]*taskNode, error) {
 	size := len(tasks)
 	taskNodes := make([]*taskNode, size)
@@ -242,16 +493,24 @@ func initTaskNodes(tasks []*api.Task) ([]*taskNode, error) {
 			ObjectMeta: metav1.ObjectMeta{
 				Name: task.Name,
 			},
-			Spec: taskSpec{
-				Process:         task.Process,
-				PodTemplateSpec: task.PodTemplateSpec,
-			},
+			Spec: newTaskSpec(task),
 		}
 		taskNodes[idx] = tNode
 	}
 	return taskNodes, nil
 }
 
+func newTaskSpec(task *api.Task) taskSpec {
+	return taskSpec{
+		IdempotencyKey:     task.IdempotencyKey,
+		ServiceAccountName: task.ServiceAccountName,
+		PriorityClassName:  task.PriorityClassName,
+		Process:            task.Process,
+		PodTemplateSpec:    task.PodTemplateSpec,
+		Sidecars:           task.Sidecars,
+	}
+}
+
 // collectTaskStatus from Pod via endpoint
 func (sch *defaultTaskScheduler) collectTaskStatus(taskNodes []*taskNode) {
 	ips := []string{}
@@ -269,7 +528,8 @@ func (sch *defaultTaskScheduler) collectTaskStatus(taskNodes []*taskNode) {
 	for _, tNode := range taskNodes {
 		task, ok := tasks[tNode.IP]
 		tNode.Status = task
-		if ok && task != nil {
+		// a task stopped for draining or updating is neither failed nor finished, it runs again
+		if ok && task != nil && !tNode.draining && !tNode.updating {
 			tNode.transTaskState(parseTaskState(task))
 		}
 	}
@@ -313,20 +573,24 @@ func parsePodTaskState(status *corev1.PodStatus) TaskState {
 }
 
 func (sch *defaultTaskScheduler) scheduleTaskNodes() error {
-	sch.freePods = assignTaskNodes(sch.taskNodes, sch.freePods)
+	sch.freePods = assignTaskNodes(sch.admittedTaskNodes(), sch.freePods)
+	podsByName := make(map[string]*corev1.Pod, len(sch.allPods))
+	for _, pod := range sch.allPods {
+		podsByName[pod.Name] = pod
+	}
 	semaphore := make(chan struct{}, sch.maxConcurrency)
 	var wg sync.WaitGroup
 	for idx := range sch.taskNodes {
 		tNode := sch.taskNodes[idx]
 		semaphore <- struct{}{}
 		wg.Add(1)
-		go func(node *taskNode) {
+		go func(node *taskNode, pod *corev1.Pod) {
 			defer func() {
 				<-semaphore
 				wg.Done()
 			}()
-			scheduleSingleTaskNode(node, sch.taskClientCreator, sch.resPolicyWhenTaskComplete)
-		}(tNode)
+			scheduleSingleTaskNode(node, pod, sch.taskClientCreator, sch.resPolicyWhenTaskComplete)
+		}(tNode, podsByName[tNode.PodName])
 	}
 	wg.Wait()
 	return nil
@@ -347,6 +611,9 @@ func (sch *defaultTaskScheduler) refreshFreePods() {
 	sch.freePods = make([]*corev1.Pod, 0, len(sch.allPods)/2)
 	for _, pod := range sch.allPods {
 		// Only consider pods with IP addresses as free for assignment
+		if _, draining := sch.drainingPods[pod.Name]; draining || pod.DeletionTimestamp != nil {
+			continue
+		}
 		if !assignedPods[pod.Name] && pod.Status.PodIP != "" {
 			sch.freePods = append(sch.freePods, pod)
 		}
@@ -372,7 +639,7 @@ func assignTaskNodes(taskNodes []*taskNode, freePods []*corev1.Pod) []*corev1.Po
 }
 
 func needRelease(tNode *taskNode, policy sandboxv1alpha1.TaskResourcePolicy) bool {
-	if tNode.DeletionTimestamp != nil {
+	if tNode.DeletionTimestamp != nil || tNode.draining || tNode.updating {
 		return true
 	}
 	if policy == sandboxv1alpha1.TaskResourcePolicyRelease && tNode.isTaskCompleted() {
@@ -381,8 +648,9 @@ func needRelease(tNode *taskNode, policy sandboxv1alpha1.TaskResourcePolicy) boo
 	return false
 }
 
-// scheduleSingleTaskNode handles scheduling for a single task node based on its state
-func scheduleSingleTaskNode(tNode *taskNode, taskClientCreator func(endpoint string) taskClient, resPolicyWhenTaskComplete sandboxv1alpha1.TaskResourcePolicy) {
+// scheduleSingleTaskNode handles scheduling for a single task node based on its state.
+// pod is the Pod the node is assigned to, used to resolve fieldRef env entries.
+func scheduleSingleTaskNode(tNode *taskNode, pod *corev1.Pod, taskClientCreator func(endpoint string) taskClient, resPolicyWhenTaskComplete sandboxv1alpha1.TaskResourcePolicy) {
 	// pending
 	if tNode.IP == "" {
 		if tNode.DeletionTimestamp != nil {
@@ -395,20 +663,55 @@ func scheduleSingleTaskNode(tNode *taskNode, taskClientCreator func(endpoint str
 		} else {
 			// no need to setTask if task is completed to avoid unnecessary network overhead
 			if !tNode.isTaskCompleted() {
+				process, err := resolveFieldRefs(tNode.Spec.Process, pod)
+				if err != nil {
+					klog.Warningf("Task %s on Pod %s: %v", klog.KObj(tNode), tNode.PodName, err)
+				}
+				sidecars, err := resolveSidecarFieldRefs(tNode.Spec.Sidecars, pod)
+				if err != nil {
+					klog.Warningf("Task %s on Pod %s: %v", klog.KObj(tNode), tNode.PodName, err)
+				}
 				task := &api.Task{
-					Name:            tNode.Name,
-					Process:         tNode.Spec.Process,
-					PodTemplateSpec: tNode.Spec.PodTemplateSpec,
+					Name:               tNode.Name,
+					IdempotencyKey:     tNode.submissionKey(),
+					ServiceAccountName: tNode.Spec.ServiceAccountName,
+					PriorityClassName:  tNode.Spec.PriorityClassName,
+					Process:            process,
+					PodTemplateSpec:    tNode.Spec.PodTemplateSpec,
+					Sidecars:           sidecars,
+				}
+				hash := api.SpecHash(task)
+				task.Annotations = map[string]string{api.AnnotationSpecHash: hash}
+				if status := tNode.Status; status != nil && status.Name == task.Name {
+					// the executor keeps the task it has, so resubmitting would change
+					// nothing; one whose spec changed, or whose spec is unknown, is
+					// replaced with ReplaceTask
+					reported := status.Annotations[api.AnnotationSpecHash]
+					if reported == "" {
+						reported = tNode.submittedHash
+					}
+					tNode.outdated = reported != hash
+					return
 				}
-				_, err := setTask(taskClientCreator(tNode.IP), task)
+				_, err = setTask(taskClientCreator(tNode.IP), task)
 				if err != nil {
 					klog.Errorf("Failed to set task %s, endpoint %s, err %v", klog.KObj(tNode), tNode.IP, err)
+				} else {
+					tNode.submittedHash = hash
 				}
 			}
 		}
 	}
 	if tNode.sState == stateReleasing {
 		if tNode.isTaskDeleted() {
+			if tNode.draining {
+				tNode.unassign()
+				return
+			}
+			if tNode.updating && tNode.DeletionTimestamp == nil {
+				tNode.restart()
+				return
+			}
 			tNode.transSchState(stateReleased)
 		} else {
 			_, err := setTask(taskClientCreator(tNode.IP), nil)
@@ -52,6 +52,21 @@ func (mr *MocktaskClientMockRecorder) Get(ctx interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MocktaskClient)(nil).Get), ctx)
 }
 
+// Logs mocks base method.
+func (m *MocktaskClient) Logs(ctx context.Context, name string, tailLines, limitBytes int) (*api.TaskLogs, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Logs", ctx, name, tailLines, limitBytes)
+	ret0, _ := ret[0].(*api.TaskLogs)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Logs indicates an expected call of Logs.
+func (mr *MocktaskClientMockRecorder) Logs(ctx, name, tailLines, limitBytes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logs", reflect.TypeOf((*MocktaskClient)(nil).Logs), ctx, name, tailLines, limitBytes)
+}
+
 // Set mocks base method.
 func (m *MocktaskClient) Set(ctx context.Context, task *api.Task) (*api.Task, error) {
 	m.ctrl.T.Helper()
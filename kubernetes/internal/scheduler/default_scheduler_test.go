@@ -133,12 +133,12 @@ func Test_scheduleSingleTaskNode(t *testing.T) {
 				},
 				taskClientCreator: func(endpoint string) taskClient {
 					mock := NewMocktaskClient(ctl)
-					mock.EXPECT().Set(gomock.Any(), &api.Task{
+					mock.EXPECT().Set(gomock.Any(), withSpecHash(&api.Task{
 						Name: "test-batch-sandbox-0",
 						Process: &api.Process{
 							Command: []string{"hello"},
 						},
-					}).Return(nil, nil).Times(1)
+					})).Return(nil, nil).Times(1)
 					return mock
 				},
 			},
@@ -159,7 +159,8 @@ func Test_scheduleSingleTaskNode(t *testing.T) {
 						},
 					},
 				},
-				tState: RunningTaskState,
+				tState:        RunningTaskState,
+				submittedHash: api.SpecHash(&api.Task{Name: "test-batch-sandbox-0", Process: &api.Process{Command: []string{"hello"}}}),
 			},
 		},
 		{
@@ -199,7 +200,7 @@ func Test_scheduleSingleTaskNode(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			scheduleSingleTaskNode(tt.args.tNode, tt.args.taskClientCreator, "")
+			scheduleSingleTaskNode(tt.args.tNode, nil, tt.args.taskClientCreator, "")
 			if !reflect.DeepEqual(tt.expectTaskNode, tt.args.tNode) {
 				t.Errorf("scheduleSingleTaskNode, want %+v, got %+v", tt.expectTaskNode, tt.args.tNode)
 			}
@@ -301,6 +302,74 @@ func Test_assignTaskNodes(t *testing.T) {
 	}
 }
 
+func Test_admittedTaskNodes(t *testing.T) {
+	nodes := []*taskNode{
+		{ObjectMeta: v1.ObjectMeta{Name: "test-0"}},
+		{ObjectMeta: v1.ObjectMeta{Name: "test-1"}},
+		{ObjectMeta: v1.ObjectMeta{Name: "test-2"}},
+	}
+	tests := []struct {
+		name     string
+		admitted int
+		want     int
+	}{
+		{name: "negative admits all", admitted: -1, want: 3},
+		{name: "canary only", admitted: 1, want: 1},
+		{name: "more than tasks admits all", admitted: 5, want: 3},
+		{name: "none", admitted: 0, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sch := &defaultTaskScheduler{taskNodes: nodes}
+			sch.SetAdmitted(tt.admitted)
+			if got := sch.admittedTaskNodes(); len(got) != tt.want {
+				t.Errorf("admittedTaskNodes() = %d nodes, want %d", len(got), tt.want)
+			}
+		})
+	}
+
+	// Tasks beyond the admitted prefix stay pending even with free Pods.
+	sch := &defaultTaskScheduler{taskNodes: nodes}
+	sch.SetAdmitted(1)
+	free := assignTaskNodes(sch.admittedTaskNodes(), []*corev1.Pod{
+		{ObjectMeta: v1.ObjectMeta{Name: "pod-0"}, Status: corev1.PodStatus{PodIP: "1.2.3.4"}},
+		{ObjectMeta: v1.ObjectMeta{Name: "pod-1"}, Status: corev1.PodStatus{PodIP: "1.2.3.5"}},
+	})
+	if nodes[0].PodName != "pod-0" || nodes[1].PodName != "" || len(free) != 1 {
+		t.Errorf("expected only test-0 assigned, got %q %q, free=%d", nodes[0].PodName, nodes[1].PodName, len(free))
+	}
+}
+
+func Test_StopUnfinishedTask(t *testing.T) {
+	stoppedBefore := &v1.Time{Time: time.Unix(0, 0)}
+	sch := &defaultTaskScheduler{taskNodes: []*taskNode{
+		{ObjectMeta: v1.ObjectMeta{Name: "succeeded"}, PodName: "pod-0", tState: SucceedTaskState},
+		{ObjectMeta: v1.ObjectMeta{Name: "failed"}, PodName: "pod-1", tState: FailedTaskState},
+		{ObjectMeta: v1.ObjectMeta{Name: "running"}, PodName: "pod-2", tState: RunningTaskState},
+		{ObjectMeta: v1.ObjectMeta{Name: "pending"}},
+		{ObjectMeta: v1.ObjectMeta{Name: "stopping", DeletionTimestamp: stoppedBefore}, PodName: "pod-3", tState: RunningTaskState},
+	}}
+
+	var names []string
+	for _, task := range sch.StopUnfinishedTask() {
+		names = append(names, task.GetName())
+	}
+	if !reflect.DeepEqual(names, []string{"running", "pending"}) {
+		t.Fatalf("StopUnfinishedTask() stopped %v, want [running pending]", names)
+	}
+	for _, tNode := range sch.taskNodes[:2] {
+		if tNode.DeletionTimestamp != nil {
+			t.Errorf("completed task %s must keep its resources", tNode.Name)
+		}
+	}
+	if sch.taskNodes[4].DeletionTimestamp != stoppedBefore {
+		t.Errorf("a task already stopping must keep its deletion time")
+	}
+	if again := sch.StopUnfinishedTask(); len(again) != 0 {
+		t.Errorf("second StopUnfinishedTask() stopped %d tasks, want 0", len(again))
+	}
+}
+
 func Test_refreshFreePods(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -1074,7 +1143,7 @@ func Test_scheduleTaskNodes(t *testing.T) {
 					mockClient = NewMocktaskClient(ctl)
 					mockClients[ip] = mockClient
 				}
-				mockClient.EXPECT().Set(gomock.Any(), expectedTask).Return(expectedTask, nil).Times(1)
+				mockClient.EXPECT().Set(gomock.Any(), withSpecHash(expectedTask)).Return(expectedTask, nil).Times(1)
 			}
 
 			// Create scheduler
@@ -1275,3 +1344,332 @@ func Test_initTaskNodes(t *testing.T) {
 		})
 	}
 }
+
+func Test_scheduleSingleTaskNode_ResolvesFieldRefs(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	fieldRef := func(name, path string) corev1.EnvVar {
+		return corev1.EnvVar{Name: name, ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: path}}}
+	}
+	spec := &api.Process{
+		Command: []string{"python", "shard.py"},
+		Env: []corev1.EnvVar{
+			{Name: "SHARD_INDEX", Value: "3"},
+			fieldRef("POD_NAME", "metadata.name"),
+			fieldRef("POD_NAMESPACE", "metadata.namespace"),
+			fieldRef("NODE_NAME", "spec.nodeName"),
+			fieldRef("POOL", "metadata.labels['pool']"),
+			fieldRef("BOGUS", "status.phase"),
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pool-abc", Namespace: "ml", Labels: map[string]string{"pool": "gpu"}},
+		Spec:       corev1.PodSpec{NodeName: "node-7"},
+		Status:     corev1.PodStatus{PodIP: "1.2.3.4"},
+	}
+	tNode := &taskNode{
+		ObjectMeta: v1.ObjectMeta{Name: "shards-3"},
+		Spec:       taskSpec{Process: spec},
+		IP:         "1.2.3.4",
+		PodName:    "pool-abc",
+	}
+
+	var sent *api.Task
+	client := NewMocktaskClient(ctl)
+	client.EXPECT().Set(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, task *api.Task) (*api.Task, error) {
+		sent = task
+		return nil, nil
+	}).Times(1)
+	scheduleSingleTaskNode(tNode, pod, func(string) taskClient { return client }, "")
+
+	want := []corev1.EnvVar{
+		{Name: "SHARD_INDEX", Value: "3"},
+		{Name: "POD_NAME", Value: "pool-abc"},
+		{Name: "POD_NAMESPACE", Value: "ml"},
+		{Name: "NODE_NAME", Value: "node-7"},
+		{Name: "POOL", Value: "gpu"},
+		{Name: "BOGUS", Value: ""},
+	}
+	if sent == nil || !reflect.DeepEqual(sent.Process.Env, want) {
+		t.Fatalf("submitted env = %+v, want %+v", sent, want)
+	}
+	if spec.Env[1].ValueFrom == nil {
+		t.Fatalf("resolving must not modify the task node's spec")
+	}
+}
+
+func Test_scheduleSingleTaskNode_SubmitsSidecars(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	podName := corev1.EnvVar{Name: "POD_NAME", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}}
+	sidecars := []api.Sidecar{
+		{Name: "log-shipper", Command: []string{"fluent-bit"}, Env: []corev1.EnvVar{podName}},
+		{Name: "metrics", Command: []string{"node-agent"}},
+	}
+	tNode := &taskNode{
+		ObjectMeta: v1.ObjectMeta{Name: "shards-0"},
+		Spec:       taskSpec{Process: &api.Process{Command: []string{"python", "shard.py"}}, Sidecars: sidecars},
+		IP:         "1.2.3.4",
+		PodName:    "pool-abc",
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pool-abc"}}
+
+	var sent *api.Task
+	client := NewMocktaskClient(ctl)
+	client.EXPECT().Set(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, task *api.Task) (*api.Task, error) {
+		sent = task
+		return nil, nil
+	}).Times(1)
+	scheduleSingleTaskNode(tNode, pod, func(string) taskClient { return client }, "")
+
+	want := []api.Sidecar{
+		{Name: "log-shipper", Command: []string{"fluent-bit"}, Env: []corev1.EnvVar{{Name: "POD_NAME", Value: "pool-abc"}}},
+		{Name: "metrics", Command: []string{"node-agent"}},
+	}
+	if sent == nil || !reflect.DeepEqual(sent.Sidecars, want) {
+		t.Fatalf("submitted sidecars = %+v, want %+v", sent, want)
+	}
+	if sidecars[0].Env[0].ValueFrom == nil {
+		t.Fatalf("resolving must not modify the task node's spec")
+	}
+}
+
+// withSpecHash returns task annotated with its spec hash, as the scheduler submits it.
+func withSpecHash(task *api.Task) *api.Task {
+	stamped := *task
+	stamped.Annotations = map[string]string{api.AnnotationSpecHash: api.SpecHash(task)}
+	return &stamped
+}
+
+func Test_scheduleSingleTaskNode_SkipsUnchangedSpec(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	spec := taskSpec{Process: &api.Process{Command: []string{"python", "shard.py"}, Env: []corev1.EnvVar{{Name: "B", Value: "2"}, {Name: "A", Value: "1"}}}}
+	submitted := withSpecHash(&api.Task{Name: "shards-0", Process: spec.Process})
+	newNode := func(status *api.Task) *taskNode {
+		return &taskNode{ObjectMeta: v1.ObjectMeta{Name: "shards-0"}, Spec: spec, IP: "1.2.3.4", Status: status, tState: RunningTaskState}
+	}
+
+	// the executor reports the hash it was given: nothing to resubmit
+	client := NewMocktaskClient(ctl)
+	scheduleSingleTaskNode(newNode(submitted), nil, func(string) taskClient { return client }, "")
+
+	// a changed spec, or a task submitted before specs were hashed, is
+	// outdated; the executor would keep it, so it is not resubmitted either
+	changed := *submitted
+	changed.Annotations = map[string]string{api.AnnotationSpecHash: "stale"}
+	for _, status := range []*api.Task{&changed, {Name: "shards-0"}} {
+		tNode := newNode(status)
+		scheduleSingleTaskNode(tNode, nil, func(string) taskClient { return NewMocktaskClient(ctl) }, "")
+		if !tNode.IsOutdated() {
+			t.Fatalf("a task with spec hash %q should be outdated", status.Annotations[api.AnnotationSpecHash])
+		}
+	}
+
+	// a task the executor does not have yet is submitted; an executor that
+	// reports no hash is then taken to run what it was given
+	tNode := newNode(nil)
+	client = NewMocktaskClient(ctl)
+	client.EXPECT().Set(gomock.Any(), submitted).Return(nil, nil).Times(1)
+	scheduleSingleTaskNode(tNode, nil, func(string) taskClient { return client }, "")
+	tNode.Status = &api.Task{Name: "shards-0"}
+	scheduleSingleTaskNode(tNode, nil, func(string) taskClient { return NewMocktaskClient(ctl) }, "")
+	if tNode.IsOutdated() {
+		t.Fatalf("a task submitted with the current spec should not be outdated")
+	}
+}
+
+func Test_DrainPod_ReschedulesTheShard(t *testing.T) {
+	executors := map[string]*fakeExecutor{"10.0.0.1": {}, "10.0.0.2": {}, "10.0.0.3": {}}
+	creator := func(ip string) taskClient { return executors[ip] }
+	pod := func(name, ip string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: name}, Status: corev1.PodStatus{PodIP: ip}}
+	}
+	tasks := []*api.Task{
+		{Name: "bsbx-0", IdempotencyKey: "uid-0", Process: &api.Process{Command: []string{"run", "0"}}},
+		{Name: "bsbx-1", IdempotencyKey: "uid-1", Process: &api.Process{Command: []string{"run", "1"}}},
+	}
+	taskNodes, err := initTaskNodes(tasks)
+	if err != nil {
+		t.Fatalf("initTaskNodes: %v", err)
+	}
+	sch := &defaultTaskScheduler{
+		allPods:             []*corev1.Pod{pod("bsbx-0", "10.0.0.1"), pod("bsbx-1", "10.0.0.2")},
+		taskNodes:           taskNodes,
+		taskNodeByNameIndex: indexByName(taskNodes),
+		maxConcurrency:      defaultSchConcurrency,
+		taskClientCreator:   creator,
+		taskStatusCollector: newTaskStatusCollector(creator),
+		name:                "default/bsbx",
+	}
+	schedule := func() {
+		t.Helper()
+		if err := sch.Schedule(); err != nil {
+			t.Fatalf("Schedule: %v", err)
+		}
+	}
+	schedule()
+	schedule()
+	drained := sch.taskNodes[1]
+	if drained.PodName != "bsbx-1" || drained.GetState() != RunningTaskState {
+		t.Fatalf("shard 1 should run on bsbx-1 before the drain, got pod=%q state=%q", drained.PodName, drained.GetState())
+	}
+
+	if sch.DrainPod("bsbx-1", time.Minute) {
+		t.Fatalf("DrainPod() = true while the task still runs")
+	}
+	if !drained.IsDraining() {
+		t.Fatalf("the drained shard's task should report draining")
+	}
+	schedule()
+	if executors["10.0.0.2"].current != nil {
+		t.Fatalf("the drained Pod's task should be stopped, executor runs %+v", executors["10.0.0.2"].current)
+	}
+	schedule()
+	if drained.PodName != "" || drained.IsDraining() || drained.GetState() != "" {
+		t.Fatalf("the drained shard should be pending again, got pod=%q draining=%v state=%q", drained.PodName, drained.IsDraining(), drained.GetState())
+	}
+	if !sch.DrainPod("bsbx-1", time.Minute) {
+		t.Fatalf("DrainPod() = false once the task has stopped")
+	}
+	schedule()
+	if drained.PodName != "" {
+		t.Fatalf("a draining Pod must not be handed out again, shard 1 got %q", drained.PodName)
+	}
+
+	// the controller deletes the Pod and recreates it under the same name
+	sch.UpdatePods([]*corev1.Pod{pod("bsbx-0", "10.0.0.1")})
+	sch.UpdatePods([]*corev1.Pod{pod("bsbx-0", "10.0.0.1"), pod("bsbx-1", "10.0.0.3")})
+	schedule()
+	schedule()
+	if drained.PodName != "bsbx-1" || drained.IP != "10.0.0.3" || drained.GetState() != RunningTaskState {
+		t.Fatalf("shard 1 should run on the recreated bsbx-1, got pod=%q ip=%q state=%q", drained.PodName, drained.IP, drained.GetState())
+	}
+	// the second attempt, which an executor still holding the first would not take for it
+	if got := executors["10.0.0.3"].created; !reflect.DeepEqual(got, []string{"uid-1-1"}) {
+		t.Fatalf("the recreated Pod should receive shard 1's second attempt, got %v", got)
+	}
+	if got := executors["10.0.0.1"].created; !reflect.DeepEqual(got, []string{"uid-0-0"}) || sch.taskNodes[0].PodName != "bsbx-0" {
+		t.Fatalf("shard 0 must not be disturbed, created %v on pod %q", got, sch.taskNodes[0].PodName)
+	}
+}
+
+func Test_DrainPod(t *testing.T) {
+	now := time.Unix(1000, 0)
+	o := timeNow
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = o }()
+
+	sch := &defaultTaskScheduler{taskNodes: []*taskNode{
+		{ObjectMeta: v1.ObjectMeta{Name: "running"}, IP: "10.0.0.1", PodName: "pod-0", tState: RunningTaskState},
+		{ObjectMeta: v1.ObjectMeta{Name: "succeeded"}, IP: "10.0.0.2", PodName: "pod-1", tState: SucceedTaskState},
+	}}
+	if !sch.DrainPod("pod-9", time.Minute) {
+		t.Errorf("a Pod without a task should be drained at once")
+	}
+	if !sch.DrainPod("pod-1", time.Minute) || sch.taskNodes[1].IsDraining() || sch.taskNodes[1].PodName != "pod-1" {
+		t.Errorf("a finished task should neither be stopped nor run again")
+	}
+	if sch.DrainPod("pod-0", 10*time.Second) {
+		t.Fatalf("DrainPod() = true before the grace period passed")
+	}
+	now = now.Add(5 * time.Second)
+	if sch.DrainPod("pod-0", 10*time.Second) {
+		t.Fatalf("DrainPod() = true before the grace period passed")
+	}
+	now = now.Add(5 * time.Second)
+	if !sch.DrainPod("pod-0", 10*time.Second) {
+		t.Fatalf("DrainPod() = false after the grace period")
+	}
+	if tNode := sch.taskNodes[0]; tNode.PodName != "" || tNode.IP != "" || tNode.IsDraining() {
+		t.Fatalf("a task not stopped in time should be pending again, got pod=%q ip=%q draining=%v", tNode.PodName, tNode.IP, tNode.IsDraining())
+	}
+}
+
+func Test_ReplaceTask_SubmitsTheUpdatedSpec(t *testing.T) {
+	executors := map[string]*fakeExecutor{"10.0.0.1": {}, "10.0.0.2": {}}
+	creator := func(ip string) taskClient { return executors[ip] }
+	pod := func(name, ip string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: name}, Status: corev1.PodStatus{PodIP: ip}}
+	}
+	specs := func(version string) []*api.Task {
+		return []*api.Task{
+			{Name: "bsbx-0", IdempotencyKey: "uid-0", Process: &api.Process{Command: []string{"run", version}}},
+			{Name: "bsbx-1", IdempotencyKey: "uid-1", Process: &api.Process{Command: []string{"run", version}}},
+		}
+	}
+	taskNodes, err := initTaskNodes(specs("v1"))
+	if err != nil {
+		t.Fatalf("initTaskNodes: %v", err)
+	}
+	sch := &defaultTaskScheduler{
+		allPods:             []*corev1.Pod{pod("bsbx-0", "10.0.0.1"), pod("bsbx-1", "10.0.0.2")},
+		taskNodes:           taskNodes,
+		taskNodeByNameIndex: indexByName(taskNodes),
+		maxConcurrency:      defaultSchConcurrency,
+		taskClientCreator:   creator,
+		taskStatusCollector: newTaskStatusCollector(creator),
+		name:                "default/bsbx",
+	}
+	schedule := func() {
+		t.Helper()
+		if err := sch.Schedule(); err != nil {
+			t.Fatalf("Schedule: %v", err)
+		}
+	}
+	command := func(ip string) []string {
+		if current := executors[ip].current; current != nil {
+			return current.Process.Command
+		}
+		return nil
+	}
+	schedule()
+	schedule()
+
+	sch.UpdateTaskSpecs(specs("v2"))
+	schedule()
+	replaced, kept := sch.taskNodes[0], sch.taskNodes[1]
+	if !replaced.IsOutdated() || !kept.IsOutdated() {
+		t.Fatalf("running tasks should be outdated after the update, got %v and %v", replaced.IsOutdated(), kept.IsOutdated())
+	}
+	if got := command("10.0.0.1"); !reflect.DeepEqual(got, []string{"run", "v1"}) {
+		t.Fatalf("an outdated task must keep running until replaced, executor runs %v", got)
+	}
+
+	submitted := executors["10.0.0.2"].sets
+	schedule()
+	schedule()
+	if executors["10.0.0.2"].sets != submitted {
+		t.Fatalf("an outdated task must not be resubmitted every round, got %d submissions after %d", executors["10.0.0.2"].sets, submitted)
+	}
+
+	if !sch.ReplaceTask("bsbx-0") || !replaced.IsUpdating() {
+		t.Fatalf("ReplaceTask should start replacing the outdated task")
+	}
+	if sch.ReplaceTask("bsbx-0") {
+		t.Fatalf("ReplaceTask() = true for a task already being replaced")
+	}
+	schedule()
+	if executors["10.0.0.1"].current != nil {
+		t.Fatalf("the outdated task should be stopped, executor runs %+v", executors["10.0.0.1"].current)
+	}
+	for range 3 {
+		schedule()
+	}
+	if replaced.PodName != "bsbx-0" || replaced.GetState() != RunningTaskState || replaced.IsOutdated() || replaced.IsUpdating() {
+		t.Fatalf("shard 0 should run its new task on the same Pod, got pod=%q state=%q outdated=%v updating=%v",
+			replaced.PodName, replaced.GetState(), replaced.IsOutdated(), replaced.IsUpdating())
+	}
+	if got := command("10.0.0.1"); !reflect.DeepEqual(got, []string{"run", "v2"}) {
+		t.Fatalf("shard 0 should run the updated spec, executor runs %v", got)
+	}
+	if got := command("10.0.0.2"); !reflect.DeepEqual(got, []string{"run", "v1"}) || !kept.IsOutdated() {
+		t.Fatalf("shard 1 must not be replaced, executor runs %v, outdated=%v", got, kept.IsOutdated())
+	}
+	if sch.ReplaceTask("bsbx-9") {
+		t.Fatalf("ReplaceTask() = true for an unknown task")
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	api "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
+)
+
+// resolveFieldRefs returns process with every fieldRef env entry replaced by
+// the referenced field of pod, the Pod the task is submitted to, the way the
+// kubelet fills them in for a container. The executor only sees plain values.
+// Entries that cannot be resolved, or all of them when pod is nil, become
+// empty. process itself is not modified.
+func resolveFieldRefs(process *api.Process, pod *corev1.Pod) (*api.Process, error) {
+	if process == nil {
+		return nil, nil
+	}
+	env, resolved, err := resolveEnvFieldRefs(process.Env, pod)
+	if !resolved {
+		return process, nil
+	}
+	copied := *process
+	copied.Env = env
+	return &copied, err
+}
+
+// resolveSidecarFieldRefs does for sidecars what resolveFieldRefs does for the
+// process. sidecars itself is not modified.
+func resolveSidecarFieldRefs(sidecars []api.Sidecar, pod *corev1.Pod) ([]api.Sidecar, error) {
+	var (
+		out  []api.Sidecar
+		errs []string
+	)
+	for i, sidecar := range sidecars {
+		env, resolved, err := resolveEnvFieldRefs(sidecar.Env, pod)
+		if !resolved {
+			continue
+		}
+		if out == nil {
+			out = append([]api.Sidecar(nil), sidecars...)
+		}
+		out[i].Env = env
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("sidecar %s: %v", sidecar.Name, err))
+		}
+	}
+	if out == nil {
+		return sidecars, nil
+	}
+	if len(errs) > 0 {
+		return out, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return out, nil
+}
+
+// resolveEnvFieldRefs returns a copy of env with its fieldRef entries resolved
+// against pod. It reports false, and returns env as is, when there are none.
+func resolveEnvFieldRefs(env []corev1.EnvVar, pod *corev1.Pod) ([]corev1.EnvVar, bool, error) {
+	var (
+		out  []corev1.EnvVar
+		errs []string
+	)
+	for i, e := range env {
+		if e.ValueFrom == nil || e.ValueFrom.FieldRef == nil {
+			continue
+		}
+		if out == nil {
+			out = append([]corev1.EnvVar(nil), env...)
+		}
+		value, err := podFieldValue(pod, e.ValueFrom.FieldRef.FieldPath)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("env %s: %v", e.Name, err))
+		}
+		out[i] = corev1.EnvVar{Name: e.Name, Value: value}
+	}
+	if out == nil {
+		return env, false, nil
+	}
+	if len(errs) > 0 {
+		return out, true, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return out, true, nil
+}
+
+// podFieldValue supports the fieldRef paths the kubelet accepts in env.
+func podFieldValue(pod *corev1.Pod, fieldPath string) (string, error) {
+	if pod == nil {
+		return "", nil
+	}
+	if key, ok := subscript(fieldPath, "metadata.labels"); ok {
+		return pod.Labels[key], nil
+	}
+	if key, ok := subscript(fieldPath, "metadata.annotations"); ok {
+		return pod.Annotations[key], nil
+	}
+	switch fieldPath {
+	case "metadata.name":
+		return pod.Name, nil
+	case "metadata.namespace":
+		return pod.Namespace, nil
+	case "metadata.uid":
+		return string(pod.UID), nil
+	case "spec.nodeName":
+		return pod.Spec.NodeName, nil
+	case "spec.serviceAccountName":
+		return pod.Spec.ServiceAccountName, nil
+	case "status.hostIP":
+		return pod.Status.HostIP, nil
+	case "status.podIP":
+		return pod.Status.PodIP, nil
+	default:
+		return "", fmt.Errorf("unsupported fieldPath %q", fieldPath)
+	}
+}
+
+// subscript extracts key from a path of the form prefix['key'].
+func subscript(fieldPath, prefix string) (string, bool) {
+	rest, ok := strings.CutPrefix(fieldPath, prefix+"['")
+	if !ok {
+		return "", false
+	}
+	return strings.CutSuffix(rest, "']")
+}
@@ -0,0 +1,70 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/klog/v2"
+
+	api "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
+)
+
+// FailureLogCapture has the scheduler fetch the tail of a failed task's logs
+// from its executor before the task is released, which may delete them.
+type FailureLogCapture struct {
+	// TailLines and LimitBytes bound what is fetched from each stream.
+	TailLines  int
+	LimitBytes int
+}
+
+func (t *taskNode) GetFailureLogs() *api.TaskLogs {
+	return t.failureLogs
+}
+
+// captureFailureLogs fetches the logs of tasks that failed since the last
+// round. Each task is asked once; a task the scheduler stopped itself is not
+// a failure of its own and is skipped.
+func (sch *defaultTaskScheduler) captureFailureLogs() {
+	if sch.failureLogs == nil {
+		return
+	}
+	semaphore := make(chan struct{}, sch.maxConcurrency)
+	var wg sync.WaitGroup
+	for _, tNode := range sch.taskNodes {
+		if tNode.tState != FailedTaskState || tNode.IP == "" || tNode.Status == nil || tNode.failureLogsFetched || tNode.DeletionTimestamp != nil {
+			continue
+		}
+		tNode.failureLogsFetched = true
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func(node *taskNode) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+			defer cancel()
+			logs, err := sch.taskClientCreator(node.IP).Logs(ctx, node.Name, sch.failureLogs.TailLines, sch.failureLogs.LimitBytes)
+			if err != nil {
+				klog.Warningf("failed to fetch the logs of failed task %s, endpoint %s, err %v", klog.KObj(node), node.IP, err)
+				return
+			}
+			node.failureLogs = logs
+		}(tNode)
+	}
+	wg.Wait()
+}
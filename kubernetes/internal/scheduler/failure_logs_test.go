@@ -0,0 +1,82 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
+	api "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
+)
+
+func Test_CaptureFailureLogs_BeforeRelease(t *testing.T) {
+	executors := map[string]*fakeExecutor{"10.0.0.1": {}, "10.0.0.2": {}}
+	creator := func(ip string) taskClient { return executors[ip] }
+	tasks := []*api.Task{
+		{Name: "bsbx-0", IdempotencyKey: "uid-0", Process: &api.Process{Command: []string{"run", "0"}}},
+		{Name: "bsbx-1", IdempotencyKey: "uid-1", Process: &api.Process{Command: []string{"run", "1"}}},
+	}
+	taskNodes, err := initTaskNodes(tasks)
+	if err != nil {
+		t.Fatalf("initTaskNodes: %v", err)
+	}
+	sch := &defaultTaskScheduler{
+		allPods: []*corev1.Pod{
+			{ObjectMeta: v1.ObjectMeta{Name: "bsbx-0"}, Status: corev1.PodStatus{PodIP: "10.0.0.1"}},
+			{ObjectMeta: v1.ObjectMeta{Name: "bsbx-1"}, Status: corev1.PodStatus{PodIP: "10.0.0.2"}},
+		},
+		taskNodes:                 taskNodes,
+		taskNodeByNameIndex:       indexByName(taskNodes),
+		maxConcurrency:            defaultSchConcurrency,
+		taskClientCreator:         creator,
+		taskStatusCollector:       newTaskStatusCollector(creator),
+		resPolicyWhenTaskComplete: sandboxv1alpha1.TaskResourcePolicyRelease,
+		failureLogs:               &FailureLogCapture{TailLines: 20, LimitBytes: 4096},
+		name:                      "default/bsbx",
+	}
+	schedule := func() {
+		t.Helper()
+		if err := sch.Schedule(); err != nil {
+			t.Fatalf("Schedule: %v", err)
+		}
+	}
+	schedule()
+
+	failed, succeeded := executors["10.0.0.1"], executors["10.0.0.2"]
+	failed.current.ProcessStatus = &api.ProcessStatus{Terminated: &api.Terminated{ExitCode: 1}}
+	failed.logs = &api.TaskLogs{Stderr: "ValueError: bad shard\n"}
+	succeeded.current.ProcessStatus = &api.ProcessStatus{Terminated: &api.Terminated{ExitCode: 0}}
+	succeeded.logs = &api.TaskLogs{Stdout: "done\n"}
+	schedule()
+
+	if failed.current != nil {
+		t.Fatalf("the failed task should be released under the Release policy")
+	}
+	logs := sch.taskNodes[0].GetFailureLogs()
+	if logs == nil || logs.Stderr != "ValueError: bad shard\n" {
+		t.Fatalf("failure logs = %+v, want the failed task's stderr", logs)
+	}
+	if sch.taskNodes[1].GetFailureLogs() != nil {
+		t.Fatalf("logs of a succeeded task should not be captured")
+	}
+
+	schedule()
+	if logs := sch.taskNodes[0].GetFailureLogs(); logs == nil {
+		t.Fatalf("captured logs should outlive the released task")
+	}
+}
@@ -15,6 +15,8 @@
 package scheduler
 
 import (
+	"time"
+
 	sandboxv1alpha1 "github.com/alibaba/OpenSandbox/sandbox-k8s/apis/sandbox/v1alpha1"
 	apis "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
 
@@ -26,8 +28,27 @@ type TaskScheduler interface {
 	UpdatePods(pod []*corev1.Pod)
 	ListTask() []Task
 	StopTask() []Task
+	// StopUnfinishedTask stops the tasks that have neither succeeded nor failed,
+	// pending ones included, and returns those it stopped.
+	StopUnfinishedTask() []Task
+	// SetAdmitted limits assignment to the first n tasks; the rest stay pending
+	// until admitted. A negative n admits every task.
+	SetAdmitted(n int)
+	// DrainPod stops the unfinished task assigned to the Pod and puts it back to
+	// pending, so it is assigned to another Pod; the Pod itself gets no further
+	// task. It reports whether the Pod runs none of the scheduler's tasks any
+	// more, which is also the case once grace has passed since the first call.
+	DrainPod(podName string, grace time.Duration) bool
+	// UpdateTaskSpecs replaces the specs of the tasks by name. Pending tasks are
+	// submitted with their new spec; assigned ones keep running the old one and
+	// are reported outdated until replaced with ReplaceTask or assigned again.
+	UpdateTaskSpecs(tasks []*apis.Task)
+	// ReplaceTask stops the outdated task and submits its current spec to the
+	// same Pod once it has stopped. It reports whether the replacement started.
+	ReplaceTask(name string) bool
 }
 
-func NewTaskScheduler(name string, tasks []*apis.Task, pods []*corev1.Pod, resPolicyWhenTaskCompleted sandboxv1alpha1.TaskResourcePolicy) (TaskScheduler, error) {
-	return newTaskScheduler(name, tasks, pods, resPolicyWhenTaskCompleted)
+// NewTaskScheduler creates a scheduler for tasks; failureLogs may be nil.
+func NewTaskScheduler(name string, tasks []*apis.Task, pods []*corev1.Pod, resPolicyWhenTaskCompleted sandboxv1alpha1.TaskResourcePolicy, failureLogs *FailureLogCapture) (TaskScheduler, error) {
+	return newTaskScheduler(name, tasks, pods, resPolicyWhenTaskCompleted, failureLogs)
 }
@@ -6,11 +6,13 @@ package mock_scheduler
 
 import (
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	v1 "k8s.io/api/core/v1"
 
 	scheduler "github.com/alibaba/OpenSandbox/sandbox-k8s/internal/scheduler"
+	task_executor "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
 )
 
 // MockTaskScheduler is a mock of TaskScheduler interface.
@@ -36,6 +38,20 @@ func (m *MockTaskScheduler) EXPECT() *MockTaskSchedulerMockRecorder {
 	return m.recorder
 }
 
+// DrainPod mocks base method.
+func (m *MockTaskScheduler) DrainPod(podName string, grace time.Duration) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DrainPod", podName, grace)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// DrainPod indicates an expected call of DrainPod.
+func (mr *MockTaskSchedulerMockRecorder) DrainPod(podName, grace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DrainPod", reflect.TypeOf((*MockTaskScheduler)(nil).DrainPod), podName, grace)
+}
+
 // ListTask mocks base method.
 func (m *MockTaskScheduler) ListTask() []scheduler.Task {
 	m.ctrl.T.Helper()
@@ -50,6 +66,20 @@ func (mr *MockTaskSchedulerMockRecorder) ListTask() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTask", reflect.TypeOf((*MockTaskScheduler)(nil).ListTask))
 }
 
+// ReplaceTask mocks base method.
+func (m *MockTaskScheduler) ReplaceTask(name string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceTask", name)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// ReplaceTask indicates an expected call of ReplaceTask.
+func (mr *MockTaskSchedulerMockRecorder) ReplaceTask(name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceTask", reflect.TypeOf((*MockTaskScheduler)(nil).ReplaceTask), name)
+}
+
 // Schedule mocks base method.
 func (m *MockTaskScheduler) Schedule() error {
 	m.ctrl.T.Helper()
@@ -64,6 +94,18 @@ func (mr *MockTaskSchedulerMockRecorder) Schedule() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Schedule", reflect.TypeOf((*MockTaskScheduler)(nil).Schedule))
 }
 
+// SetAdmitted mocks base method.
+func (m *MockTaskScheduler) SetAdmitted(n int) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetAdmitted", n)
+}
+
+// SetAdmitted indicates an expected call of SetAdmitted.
+func (mr *MockTaskSchedulerMockRecorder) SetAdmitted(n interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAdmitted", reflect.TypeOf((*MockTaskScheduler)(nil).SetAdmitted), n)
+}
+
 // StopTask mocks base method.
 func (m *MockTaskScheduler) StopTask() []scheduler.Task {
 	m.ctrl.T.Helper()
@@ -78,6 +120,20 @@ func (mr *MockTaskSchedulerMockRecorder) StopTask() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StopTask", reflect.TypeOf((*MockTaskScheduler)(nil).StopTask))
 }
 
+// StopUnfinishedTask mocks base method.
+func (m *MockTaskScheduler) StopUnfinishedTask() []scheduler.Task {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StopUnfinishedTask")
+	ret0, _ := ret[0].([]scheduler.Task)
+	return ret0
+}
+
+// StopUnfinishedTask indicates an expected call of StopUnfinishedTask.
+func (mr *MockTaskSchedulerMockRecorder) StopUnfinishedTask() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StopUnfinishedTask", reflect.TypeOf((*MockTaskScheduler)(nil).StopUnfinishedTask))
+}
+
 // UpdatePods mocks base method.
 func (m *MockTaskScheduler) UpdatePods(pod []*v1.Pod) {
 	m.ctrl.T.Helper()
@@ -89,3 +145,15 @@ func (mr *MockTaskSchedulerMockRecorder) UpdatePods(pod interface{}) *gomock.Cal
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePods", reflect.TypeOf((*MockTaskScheduler)(nil).UpdatePods), pod)
 }
+
+// UpdateTaskSpecs mocks base method.
+func (m *MockTaskScheduler) UpdateTaskSpecs(tasks []*task_executor.Task) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "UpdateTaskSpecs", tasks)
+}
+
+// UpdateTaskSpecs indicates an expected call of UpdateTaskSpecs.
+func (mr *MockTaskSchedulerMockRecorder) UpdateTaskSpecs(tasks interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTaskSpecs", reflect.TypeOf((*MockTaskScheduler)(nil).UpdateTaskSpecs), tasks)
+}
@@ -10,6 +10,7 @@ import (
 	gomock "github.com/golang/mock/gomock"
 
 	scheduler "github.com/alibaba/OpenSandbox/sandbox-k8s/internal/scheduler"
+	task_executor "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
 )
 
 // MockTask is a mock of Task interface.
@@ -35,6 +36,20 @@ func (m *MockTask) EXPECT() *MockTaskMockRecorder {
 	return m.recorder
 }
 
+// GetFailureLogs mocks base method.
+func (m *MockTask) GetFailureLogs() *task_executor.TaskLogs {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFailureLogs")
+	ret0, _ := ret[0].(*task_executor.TaskLogs)
+	return ret0
+}
+
+// GetFailureLogs indicates an expected call of GetFailureLogs.
+func (mr *MockTaskMockRecorder) GetFailureLogs() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFailureLogs", reflect.TypeOf((*MockTask)(nil).GetFailureLogs))
+}
+
 // GetName mocks base method.
 func (m *MockTask) GetName() string {
 	m.ctrl.T.Helper()
@@ -77,6 +92,48 @@ func (mr *MockTaskMockRecorder) GetState() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetState", reflect.TypeOf((*MockTask)(nil).GetState))
 }
 
+// IsDraining mocks base method.
+func (m *MockTask) IsDraining() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsDraining")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsDraining indicates an expected call of IsDraining.
+func (mr *MockTaskMockRecorder) IsDraining() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsDraining", reflect.TypeOf((*MockTask)(nil).IsDraining))
+}
+
+// IsOutdated mocks base method.
+func (m *MockTask) IsOutdated() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsOutdated")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsOutdated indicates an expected call of IsOutdated.
+func (mr *MockTaskMockRecorder) IsOutdated() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsOutdated", reflect.TypeOf((*MockTask)(nil).IsOutdated))
+}
+
+// IsReady mocks base method.
+func (m *MockTask) IsReady() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsReady")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsReady indicates an expected call of IsReady.
+func (mr *MockTaskMockRecorder) IsReady() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsReady", reflect.TypeOf((*MockTask)(nil).IsReady))
+}
+
 // IsResourceReleased mocks base method.
 func (m *MockTask) IsResourceReleased() bool {
 	m.ctrl.T.Helper()
@@ -90,3 +147,17 @@ func (mr *MockTaskMockRecorder) IsResourceReleased() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsResourceReleased", reflect.TypeOf((*MockTask)(nil).IsResourceReleased))
 }
+
+// IsUpdating mocks base method.
+func (m *MockTask) IsUpdating() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsUpdating")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsUpdating indicates an expected call of IsUpdating.
+func (mr *MockTaskMockRecorder) IsUpdating() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsUpdating", reflect.TypeOf((*MockTask)(nil).IsUpdating))
+}
@@ -60,9 +60,10 @@ func (sch *defaultTaskScheduler) recoverTaskNodesStatus() error {
 		if task == nil || pod == nil {
 			continue
 		}
-		if tNode := sch.taskNodeByNameIndex[task.Name]; tNode != nil {
+		if tNode := sch.taskNodeByNameIndex[task.Name]; tNode != nil && tNode.ownsTask(task) {
 			recoverOneTaskNode(tNode, task, pod.Status.PodIP, pod.Name)
 		} else {
+			klog.Warningf("task %s (key %q) on Pod %s is not owned by BatchSandbox %s", task.Name, task.IdempotencyKey, pod.Name, sch.name)
 		}
 		// TODO do we need to stop tasks not belong us? e.g users ScaleIn []*sandboxv1alpha1.Task
 	}
@@ -74,6 +75,10 @@ func recoverOneTaskNode(tNode *taskNode, currentTask *api.Task, ip string, podNa
 	tNode.transTaskState(parseTaskState(currentTask))
 	tNode.IP = ip
 	tNode.PodName = podName
+	if _, attempt, ok := parseSubmissionKey(currentTask.IdempotencyKey); ok {
+		// later submissions continue from the attempt found running
+		tNode.attempt = attempt
+	}
 	if currentTask.DeletionTimestamp != nil {
 		tNode.transSchState(stateReleasing)
 	}
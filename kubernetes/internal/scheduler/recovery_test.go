@@ -15,6 +15,8 @@
 package scheduler
 
 import (
+	"context"
+	"fmt"
 	"reflect"
 	"sync"
 	"testing"
@@ -284,3 +286,177 @@ func Test_defaultTaskScheduler_recoverTaskNodesStatus(t *testing.T) {
 		})
 	}
 }
+
+// fakeExecutor mimics the task-executor's setTasks semantics: setting a task
+// with the running task's name is a no-op, any other task replaces it.
+type fakeExecutor struct {
+	mu      sync.Mutex
+	current *api.Task
+	created []string
+	// sets counts the submissions, whether or not they created a task.
+	sets int
+	// logs are served for the current task and go away with it.
+	logs *api.TaskLogs
+}
+
+func (e *fakeExecutor) Set(_ context.Context, task *api.Task) (*api.Task, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if task == nil {
+		e.current = nil
+		return nil, nil
+	}
+	e.sets++
+	if e.current == nil || e.current.Name != task.Name {
+		cp := *task
+		cp.ProcessStatus = &api.ProcessStatus{Running: &api.Running{}}
+		e.current = &cp
+		e.created = append(e.created, task.IdempotencyKey)
+	}
+	return e.current, nil
+}
+
+func (e *fakeExecutor) Get(context.Context) (*api.Task, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.current, nil
+}
+
+func (e *fakeExecutor) Logs(_ context.Context, name string, _, _ int) (*api.TaskLogs, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.current == nil || e.current.Name != name {
+		return nil, fmt.Errorf("task %s not found", name)
+	}
+	return e.logs, nil
+}
+
+// flakyCollector drops the given IPs from the next Collect, like a Pod whose
+// executor times out once.
+type flakyCollector struct {
+	taskStatusCollector
+	drop map[string]bool
+}
+
+func (c *flakyCollector) Collect(ctx context.Context, ips []string) map[string]*api.Task {
+	ret := c.taskStatusCollector.Collect(ctx, ips)
+	for ip := range c.drop {
+		delete(ret, ip)
+	}
+	c.drop = nil
+	return ret
+}
+
+func Test_defaultTaskScheduler_restartBetweenGenerationAndSubmission(t *testing.T) {
+	executors := map[string]*fakeExecutor{"10.0.0.1": {}, "10.0.0.2": {}}
+	creator := func(ip string) taskClient { return executors[ip] }
+	pods := []*corev1.Pod{
+		{ObjectMeta: v1.ObjectMeta{Name: "bsbx-0"}, Status: corev1.PodStatus{PodIP: "10.0.0.1"}},
+		{ObjectMeta: v1.ObjectMeta{Name: "bsbx-1"}, Status: corev1.PodStatus{PodIP: "10.0.0.2"}},
+	}
+	tasks := []*api.Task{
+		{Name: "bsbx-0", IdempotencyKey: "uid-0", Process: &api.Process{Command: []string{"run", "0"}}},
+		{Name: "bsbx-1", IdempotencyKey: "uid-1", Process: &api.Process{Command: []string{"run", "1"}}},
+	}
+	newScheduler := func(collector taskStatusCollector) *defaultTaskScheduler {
+		taskNodes, err := initTaskNodes(tasks)
+		if err != nil {
+			t.Fatalf("initTaskNodes: %v", err)
+		}
+		sch := &defaultTaskScheduler{
+			allPods:             pods,
+			taskNodes:           taskNodes,
+			taskNodeByNameIndex: indexByName(taskNodes),
+			maxConcurrency:      defaultSchConcurrency,
+			taskClientCreator:   creator,
+			taskStatusCollector: collector,
+			name:                "default/bsbx",
+		}
+		if err := sch.recover(); err != nil {
+			t.Fatalf("recover: %v", err)
+		}
+		return sch
+	}
+
+	// The first controller generated both tasks, submitted bsbx-0 to the second
+	// Pod and crashed before submitting bsbx-1.
+	submitted := *tasks[0]
+	submitted.IdempotencyKey = "uid-0-0"
+	if _, err := executors["10.0.0.2"].Set(context.Background(), &submitted); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// After the restart the second Pod's executor misses the recovery round.
+	collector := &flakyCollector{
+		taskStatusCollector: newTaskStatusCollector(creator),
+		drop:                map[string]bool{"10.0.0.2": true},
+	}
+	sch := newScheduler(collector)
+	for i := 0; i < 3; i++ {
+		if err := sch.Schedule(); err != nil {
+			t.Fatalf("Schedule: %v", err)
+		}
+	}
+
+	// A second restart regenerates the same tasks and must not resubmit anything.
+	sch = newScheduler(newTaskStatusCollector(creator))
+	if err := sch.Schedule(); err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+
+	created := map[string]int{}
+	for _, e := range executors {
+		for _, key := range e.created {
+			created[key]++
+		}
+	}
+	if !reflect.DeepEqual(created, map[string]int{"uid-0-0": 1, "uid-1-0": 1}) {
+		t.Fatalf("expected every task created exactly once, got %v", created)
+	}
+	if got := executors["10.0.0.2"].current; got == nil || got.IdempotencyKey != "uid-0-0" {
+		t.Fatalf("expected the submitted task to keep running on its Pod, got %+v", got)
+	}
+	for _, tNode := range sch.taskNodes {
+		if tNode.PodName == "" || tNode.Status == nil || !tNode.ownsTask(tNode.Status) {
+			t.Fatalf("task node %s not bound to its running task: pod=%q status=%+v", tNode.Name, tNode.PodName, tNode.Status)
+		}
+	}
+}
+
+func Test_taskNode_ownsTask(t *testing.T) {
+	keyed := &taskNode{ObjectMeta: v1.ObjectMeta{Name: "bsbx-0"}, Spec: taskSpec{IdempotencyKey: "uid-0"}}
+	legacy := &taskNode{ObjectMeta: v1.ObjectMeta{Name: "bsbx-0"}}
+	tests := []struct {
+		name  string
+		tNode *taskNode
+		task  *api.Task
+		want  bool
+	}{
+		{name: "same key", tNode: keyed, task: &api.Task{Name: "bsbx-0", IdempotencyKey: "uid-0-0"}, want: true},
+		{name: "later attempt", tNode: keyed, task: &api.Task{Name: "bsbx-0", IdempotencyKey: "uid-0-2"}, want: true},
+		{name: "key without attempt", tNode: keyed, task: &api.Task{Name: "bsbx-0", IdempotencyKey: "uid-0"}, want: false},
+		{name: "same name from a recreated BatchSandbox", tNode: keyed, task: &api.Task{Name: "bsbx-0", IdempotencyKey: "old-0-0"}, want: false},
+		{name: "unkeyed task on executor", tNode: keyed, task: &api.Task{Name: "bsbx-0"}, want: false},
+		{name: "legacy match by name", tNode: legacy, task: &api.Task{Name: "bsbx-0"}, want: true},
+		{name: "legacy other name", tNode: legacy, task: &api.Task{Name: "bsbx-1"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.tNode.ownsTask(tt.task); got != tt.want {
+				t.Errorf("ownsTask() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_recoverOneTaskNode_ContinuesTheAttempt(t *testing.T) {
+	tNode := &taskNode{ObjectMeta: v1.ObjectMeta{Name: "bsbx-0"}, Spec: taskSpec{IdempotencyKey: "uid-0"}}
+	recoverOneTaskNode(tNode, &api.Task{Name: "bsbx-0", IdempotencyKey: "uid-0-2"}, "10.0.0.1", "bsbx-0")
+	if got := tNode.submissionKey(); got != "uid-0-2" {
+		t.Fatalf("submissionKey() = %q after recovery, want the attempt found running", got)
+	}
+	tNode.unassign()
+	if got := tNode.submissionKey(); got != "uid-0-3" {
+		t.Fatalf("submissionKey() = %q after a reschedule, want the next attempt", got)
+	}
+}
@@ -14,6 +14,10 @@
 
 package scheduler
 
+import (
+	api "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
+)
+
 type Task interface {
 	GetName() string
 	GetState() TaskState
@@ -21,6 +25,18 @@ type Task interface {
 	// IsResourceReleased task resource is released
 	// TODO func name is strange
 	IsResourceReleased() bool
+	// IsReady reports whether the task is running and its startup probe, if any, has passed.
+	IsReady() bool
+	// IsDraining reports whether the task is being stopped to drain its Pod.
+	IsDraining() bool
+	// IsOutdated reports whether the task runs a spec that has since been replaced
+	// by UpdateTaskSpecs.
+	IsOutdated() bool
+	// IsUpdating reports whether the outdated task is being stopped to be replaced.
+	IsUpdating() bool
+	// GetFailureLogs returns the logs captured when the task failed, nil
+	// unless FailureLogCapture is set and they could be fetched.
+	GetFailureLogs() *api.TaskLogs
 }
 
 type TaskState string
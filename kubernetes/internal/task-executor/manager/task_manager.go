@@ -112,8 +112,12 @@ func (m *taskManager) Create(ctx context.Context, task *types.Task) (*types.Task
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Check if task already exists
-	if _, exists := m.tasks[task.Name]; exists {
+	// Check if task already exists; re-submitting the same key is a no-op
+	if existing, exists := m.tasks[task.Name]; exists {
+		if task.IdempotencyKey != "" && existing.IdempotencyKey == task.IdempotencyKey {
+			klog.InfoS("task already submitted, skip", "name", task.Name, "idempotencyKey", task.IdempotencyKey)
+			return existing, nil
+		}
 		return nil, fmt.Errorf("task %s already exists", task.Name)
 	}
 
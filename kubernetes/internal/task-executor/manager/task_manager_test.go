@@ -217,6 +217,44 @@ func TestTaskManager_CreateDuplicate(t *testing.T) {
 	}
 }
 
+func TestTaskManager_CreateSameIdempotencyKey(t *testing.T) {
+	mgr, _ := setupTestManager(t)
+	mgr.Start(context.Background())
+	defer mgr.Stop()
+
+	ctx := context.Background()
+
+	newTask := func(key string) *types.Task {
+		return &types.Task{
+			Name:           "keyed-task",
+			IdempotencyKey: key,
+			Process: &api.Process{
+				Command: []string{"sleep", "1"},
+			},
+		}
+	}
+
+	first, err := mgr.Create(ctx, newTask("uid-0"))
+	if err != nil {
+		t.Fatalf("First Create() failed: %v", err)
+	}
+	defer cleanupTask(t, mgr, first.Name)
+
+	// Re-submitting the same key returns the existing task without starting another
+	again, err := mgr.Create(ctx, newTask("uid-0"))
+	if err != nil {
+		t.Fatalf("Create() with same key should be a no-op, got %v", err)
+	}
+	if again != first {
+		t.Errorf("Create() with same key returned a different task")
+	}
+
+	// Same name with another key is still a conflict
+	if _, err := mgr.Create(ctx, newTask("uid-0-1")); err == nil {
+		t.Error("Create() should fail for a different key with the same name")
+	}
+}
+
 func TestTaskManager_CreateMaxConcurrentTasks(t *testing.T) {
 	mgr, _ := setupTestManager(t)
 	mgr.Start(context.Background())
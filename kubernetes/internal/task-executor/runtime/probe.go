@@ -0,0 +1,203 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/task-executor/types"
+)
+
+const (
+	// ReadyFile is written once the startup probe passes.
+	ReadyFile = "ready"
+	// StartupFailedFile holds the reason the startup probe gave up.
+	StartupFailedFile = "startup-failed"
+
+	// StartupProbeFailedReason is reported for tasks stopped by their startup probe.
+	StartupProbeFailedReason = "StartupProbeFailed"
+)
+
+// Kubernetes defaults for unset probe fields.
+const (
+	defaultProbePeriodSeconds    = 10
+	defaultProbeTimeoutSeconds   = 1
+	defaultProbeFailureThreshold = 3
+)
+
+// startProber runs the task's startup probe in the background until it passes
+// or gives up, unless a prober for the task is already running or finished.
+// The outcome is persisted next to the pid and exit files so Inspect can read
+// it; a prober lost to an executor restart is resumed by the next Inspect.
+func (e *processExecutor) startProber(task *types.Task, taskDir string) {
+	if task.Process == nil || task.Process.StartupProbe == nil {
+		return
+	}
+	if fileExists(filepath.Join(taskDir, ReadyFile)) || fileExists(filepath.Join(taskDir, StartupFailedFile)) {
+		return
+	}
+	if _, running := e.probers.LoadOrStore(task.Name, struct{}{}); running {
+		return
+	}
+	go func() {
+		defer e.probers.Delete(task.Name)
+		e.runStartupProbe(task, taskDir)
+	}()
+}
+
+func (e *processExecutor) runStartupProbe(task *types.Task, taskDir string) {
+	probe := task.Process.StartupProbe
+	period := probeSeconds(probe.PeriodSeconds, defaultProbePeriodSeconds)
+	timeout := probeSeconds(probe.TimeoutSeconds, defaultProbeTimeoutSeconds)
+	threshold := int(probe.FailureThreshold)
+	if threshold <= 0 {
+		threshold = defaultProbeFailureThreshold
+	}
+	pidPath := filepath.Join(taskDir, PidFile)
+	exitPath := filepath.Join(taskDir, ExitFile)
+
+	time.Sleep(time.Duration(probe.InitialDelaySeconds) * time.Second)
+	var lastErr error
+	for attempt := 1; attempt <= threshold; attempt++ {
+		if fileExists(exitPath) || !fileExists(pidPath) {
+			return // finished or stopped; the exit code speaks for itself
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		lastErr = e.probeOnce(ctx, task)
+		cancel()
+		if lastErr == nil {
+			klog.InfoS("startup probe passed", "name", task.Name, "attempt", attempt)
+			if err := os.WriteFile(filepath.Join(taskDir, ReadyFile), []byte(time.Now().UTC().Format(time.RFC3339)), 0644); err != nil {
+				klog.ErrorS(err, "failed to write ready file", "name", task.Name)
+			}
+			return
+		}
+		klog.V(2).InfoS("startup probe failed", "name", task.Name, "attempt", attempt, "err", lastErr)
+		if attempt < threshold {
+			time.Sleep(period)
+		}
+	}
+
+	msg := fmt.Sprintf("startup probe failed %d times: %v", threshold, lastErr)
+	klog.InfoS("startup probe gave up", "name", task.Name, "message", msg)
+	if err := os.WriteFile(filepath.Join(taskDir, StartupFailedFile), []byte(msg), 0644); err != nil {
+		klog.ErrorS(err, "failed to write startup failure file", "name", task.Name)
+	}
+}
+
+func (e *processExecutor) probeOnce(ctx context.Context, task *types.Task) error {
+	probe := task.Process.StartupProbe
+	if probe.Exec != nil {
+		return e.probeExec(ctx, task, probe.Exec.Command)
+	}
+	if probe.HTTPGet != nil {
+		return probeHTTP(ctx, probe.HTTPGet)
+	}
+	return fmt.Errorf("startup probe needs an exec or httpGet handler")
+}
+
+// probeExec runs the probe command where the task runs: inside the main
+// container's namespaces in sidecar mode, next to the executor otherwise.
+func (e *processExecutor) probeExec(ctx context.Context, task *types.Task, command []string) error {
+	if len(command) == 0 {
+		return fmt.Errorf("startup probe exec command is empty")
+	}
+	var cmd *exec.Cmd
+	if e.config.EnableSidecarMode {
+		targetPID, err := e.findPidByEnvVar("SANDBOX_MAIN_CONTAINER", e.config.MainContainerName)
+		if err != nil {
+			return fmt.Errorf("failed to resolve target PID: %w", err)
+		}
+		args := append([]string{"-t", strconv.Itoa(targetPID), "--mount", "--uts", "--ipc", "--net", "--pid", "--"}, command...)
+		cmd = exec.CommandContext(ctx, "nsenter", args...)
+		if cmd.Env, err = getProcEnviron(targetPID); err != nil {
+			return fmt.Errorf("failed to read target process environment: %w", err)
+		}
+	} else {
+		cmd = exec.CommandContext(ctx, command[0], command[1:]...)
+		cmd.Env = os.Environ()
+	}
+	for _, env := range task.Process.Env {
+		if env.Name != "" {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", env.Name, env.Value))
+		}
+	}
+	cmd.Dir = task.Process.WorkingDir
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// probeHTTP passes on any 2xx or 3xx response, like the kubelet. The host
+// defaults to loopback, which is the task's network namespace in both modes.
+func probeHTTP(ctx context.Context, action *corev1.HTTPGetAction) error {
+	scheme := strings.ToLower(string(action.Scheme))
+	if scheme == "" {
+		scheme = "http"
+	}
+	host := action.Host
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	path := action.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	url := fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(host, strconv.Itoa(action.Port.IntValue())), path)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for _, h := range action.HTTPHeaders {
+		req.Header.Add(h.Name, h.Value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("GET %s returned %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+func probeSeconds(seconds int32, def int) time.Duration {
+	if seconds <= 0 {
+		return time.Duration(def) * time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
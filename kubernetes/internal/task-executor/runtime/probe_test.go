@@ -0,0 +1,169 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/task-executor/types"
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/task-executor/utils"
+	api "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
+)
+
+// startProbedTask starts task and returns its directory.
+func startProbedTask(t *testing.T, executor Executor, task *types.Task) string {
+	t.Helper()
+	taskDir, err := utils.SafeJoin(executor.(*processExecutor).rootDir, task.Name)
+	assert.Nil(t, err)
+	os.MkdirAll(taskDir, 0755)
+	if err := executor.Start(context.Background(), task); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { _ = executor.Stop(context.Background(), task) })
+	return taskDir
+}
+
+// waitStatus polls Inspect until cond holds or the deadline passes.
+func waitStatus(t *testing.T, executor Executor, task *types.Task, within time.Duration, cond func(*types.Status) bool) *types.Status {
+	t.Helper()
+	deadline := time.Now().Add(within)
+	for {
+		status, err := executor.Inspect(context.Background(), task)
+		if err != nil {
+			t.Fatalf("Inspect failed: %v", err)
+		}
+		if cond(status) || time.Now().After(deadline) {
+			return status
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestProcessExecutor_StartupProbePassesAfterDelay(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	executor, _ := setupTestExecutor(t)
+	marker := filepath.Join(t.TempDir(), "started")
+
+	task := &types.Task{
+		Name: "probe-delayed",
+		Process: &api.Process{
+			Command: []string{"/bin/sh", "-c", "sleep 1; touch " + marker + "; sleep 30"},
+			StartupProbe: &corev1.Probe{
+				ProbeHandler:     corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"test", "-f", marker}}},
+				PeriodSeconds:    1,
+				FailureThreshold: 10,
+			},
+		},
+	}
+	taskDir := startProbedTask(t, executor, task)
+
+	status, err := executor.Inspect(context.Background(), task)
+	assert.Nil(t, err)
+	assert.Equal(t, types.TaskStateRunning, status.State)
+	assert.False(t, status.SubStatuses[0].Ready, "task must not be ready before the probe passes")
+
+	status = waitStatus(t, executor, task, 5*time.Second, func(s *types.Status) bool { return s.SubStatuses[0].Ready })
+	assert.Equal(t, types.TaskStateRunning, status.State)
+	assert.True(t, status.SubStatuses[0].Ready, "task should be ready once the probe passes")
+	assert.FileExists(t, filepath.Join(taskDir, ReadyFile))
+}
+
+func TestProcessExecutor_StartupProbeNeverPasses(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	executor, _ := setupTestExecutor(t)
+
+	task := &types.Task{
+		Name: "probe-never",
+		Process: &api.Process{
+			Command: []string{"sleep", "30"},
+			StartupProbe: &corev1.Probe{
+				ProbeHandler:     corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"false"}}},
+				PeriodSeconds:    1,
+				FailureThreshold: 2,
+			},
+		},
+	}
+	startProbedTask(t, executor, task)
+
+	status := waitStatus(t, executor, task, 5*time.Second, func(s *types.Status) bool { return s.State != types.TaskStateRunning })
+	assert.Equal(t, types.TaskStateTimeout, status.State, "a task whose probe gives up is stopped like a timed out one")
+	assert.False(t, status.SubStatuses[0].Ready)
+	assert.Equal(t, StartupProbeFailedReason, status.SubStatuses[0].Reason)
+	assert.Contains(t, status.SubStatuses[0].Message, "failed 2 times")
+
+	// Once stopped, the failure reason survives the exit code.
+	assert.Nil(t, executor.Stop(context.Background(), task))
+	status = waitStatus(t, executor, task, 5*time.Second, func(s *types.Status) bool { return s.State == types.TaskStateFailed })
+	assert.Equal(t, types.TaskStateFailed, status.State)
+	assert.Equal(t, StartupProbeFailedReason, status.SubStatuses[0].Reason)
+}
+
+func TestProcessExecutor_ReadyWithoutProbe(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	executor, _ := setupTestExecutor(t)
+	task := &types.Task{Name: "no-probe", Process: &api.Process{Command: []string{"sleep", "30"}}}
+	startProbedTask(t, executor, task)
+
+	status, err := executor.Inspect(context.Background(), task)
+	assert.Nil(t, err)
+	assert.Equal(t, types.TaskStateRunning, status.State)
+	assert.True(t, status.SubStatuses[0].Ready)
+}
+
+func TestProbeHTTP(t *testing.T) {
+	healthy := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" || r.Header.Get("X-Probe") != "startup" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	action := &corev1.HTTPGetAction{
+		Path:        "healthz",
+		Port:        intstr.FromInt32(int32(port)),
+		HTTPHeaders: []corev1.HTTPHeader{{Name: "X-Probe", Value: "startup"}},
+	}
+	assert.Error(t, probeHTTP(context.Background(), action))
+	healthy = true
+	assert.NoError(t, probeHTTP(context.Background(), action))
+}
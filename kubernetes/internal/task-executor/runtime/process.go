@@ -22,6 +22,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -30,6 +31,7 @@ import (
 	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/task-executor/config"
 	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/task-executor/types"
 	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/task-executor/utils"
+	api "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
 )
 
 const (
@@ -44,6 +46,8 @@ const (
 type processExecutor struct {
 	config  *config.Config
 	rootDir string
+	// probers holds the names of tasks whose startup probe is running.
+	probers sync.Map
 }
 
 func NewProcessExecutor(config *config.Config) (Executor, error) {
@@ -75,7 +79,7 @@ func (e *processExecutor) Start(ctx context.Context, task *types.Task) error {
 
 	// Use shell escaping to prevent command injection
 	safeCmdStr := shellEscape(cmdList)
-	shimScript := e.buildShimScript(exitPath, safeCmdStr)
+	shimScript := e.buildShimScript(exitPath, safeCmdStr, taskDir, task.Sidecars)
 
 	// 2. Prepare the execution command based on mode
 	var cmd *exec.Cmd
@@ -120,7 +124,11 @@ func (e *processExecutor) Start(ctx context.Context, task *types.Task) error {
 	}
 
 	// 3. Execute common logic (logs, shim start)
-	return e.executeCommand(task, cmd, pidPath)
+	if err := e.executeCommand(task, cmd, pidPath); err != nil {
+		return err
+	}
+	e.startProber(task, taskDir)
+	return nil
 }
 
 // executeCommand handles log setup and process starting
@@ -203,28 +211,41 @@ func (e *processExecutor) executeCommand(task *types.Task, cmd *exec.Cmd, pidPat
 	return nil
 }
 
-func (e *processExecutor) buildShimScript(exitPath, cmdStr string) string {
+func (e *processExecutor) buildShimScript(exitPath, cmdStr, taskDir string, sidecars []api.Sidecar) string {
 	// The shim script acts as a mini-init process.
 	// 1. It runs the user command in the background.
 	// 2. It traps SIGTERM and forwards it to the child process.
 	// 3. It waits for the child to exit and captures the exit code.
 	// This ensures graceful shutdown propagation in sidecar/host modes.
+	// A SIGTERM that arrives before the child's PID is known is remembered
+	// and forwarded as soon as the child has been started.
+	// Sidecars are started before the user command and stopped after it
+	// exits, before the exit code is recorded.
+	var launchSidecars, stopSidecars string
+	if len(sidecars) > 0 {
+		launchSidecars = sidecarLaunchScript(taskDir, sidecars)
+		stopSidecars = sidecarStopScript()
+	}
 	script := fmt.Sprintf(`
 cleanup() {
+    TERMINATING=1
     if [ -n "$CHILD_PID" ]; then
         kill -TERM "$CHILD_PID" 2>/dev/null
     fi
 }
 trap cleanup TERM
 
-%s &
+%s%s &
 CHILD_PID=$!
+if [ -n "$TERMINATING" ]; then
+    kill -TERM "$CHILD_PID" 2>/dev/null
+fi
 wait "$CHILD_PID"
 EXIT_CODE=$?
-
+%s
 printf "%%d" $EXIT_CODE > %s
 exit $EXIT_CODE
-`, cmdStr, shellEscapePath(exitPath))
+`, launchSidecars, cmdStr, stopSidecars, shellEscapePath(exitPath))
 	klog.InfoS("Generated shim script", "exitPath", exitPath, "script", script)
 	return script
 }
@@ -258,6 +279,10 @@ func (e *processExecutor) Inspect(ctx context.Context, task *types.Task) (*types
 		} else {
 			status.State = types.TaskStateFailed
 			subStatus.Reason = "Failed"
+			if msg, err := os.ReadFile(filepath.Join(taskDir, StartupFailedFile)); err == nil {
+				subStatus.Reason = StartupProbeFailedReason
+				subStatus.Message = string(msg)
+			}
 		}
 
 		// Try to read start time from PID file
@@ -279,7 +304,19 @@ func (e *processExecutor) Inspect(ctx context.Context, task *types.Task) (*types
 
 		if isProcessRunning(pid) {
 			status.State = types.TaskStateRunning
-			if task.Process != nil && task.Process.TimeoutSeconds != nil {
+			subStatus.Ready = true
+			if task.Process != nil && task.Process.StartupProbe != nil {
+				// Not ready until the probe passes; a failed probe stops the task like a timeout.
+				subStatus.Ready = fileExists(filepath.Join(taskDir, ReadyFile))
+				if msg, err := os.ReadFile(filepath.Join(taskDir, StartupFailedFile)); err == nil {
+					status.State = types.TaskStateTimeout
+					subStatus.Reason = StartupProbeFailedReason
+					subStatus.Message = string(msg)
+				} else if !subStatus.Ready {
+					e.startProber(task, taskDir)
+				}
+			}
+			if status.State == types.TaskStateRunning && task.Process != nil && task.Process.TimeoutSeconds != nil {
 				timeout := time.Duration(*task.Process.TimeoutSeconds) * time.Second
 				elapsed := time.Since(startedAt)
 				if elapsed > timeout {
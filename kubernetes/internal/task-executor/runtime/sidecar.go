@@ -0,0 +1,88 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	api "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
+)
+
+const (
+	// SidecarLogPrefix prefixes the log files of a sidecar in the task
+	// directory, e.g. sidecar-log-shipper.stdout.log.
+	SidecarLogPrefix = "sidecar-"
+	// sidecarStopGracePeriod is how long, in seconds, sidecars get to exit
+	// after SIGTERM before the shim kills them.
+	sidecarStopGracePeriod = 5
+)
+
+// SidecarLogPaths returns the stdout and stderr log paths of the named sidecar.
+func SidecarLogPaths(taskDir, name string) (stdout, stderr string) {
+	base := filepath.Join(taskDir, SidecarLogPrefix+name)
+	return base + ".stdout.log", base + ".stderr.log"
+}
+
+// sidecarLaunchScript returns the shim lines that start each sidecar in the
+// background, in order, and collect their PIDs in SIDECAR_PIDS. A sidecar runs
+// in a subshell that execs its command, so signals sent to the collected PID
+// reach the command itself.
+func sidecarLaunchScript(taskDir string, sidecars []api.Sidecar) string {
+	var b strings.Builder
+	for _, sidecar := range sidecars {
+		argv := append(append([]string(nil), sidecar.Command...), sidecar.Args...)
+		cmd := "exec " + shellEscape(argv)
+		if len(sidecar.Env) > 0 {
+			assignments := make([]string, 0, len(sidecar.Env))
+			for _, env := range sidecar.Env {
+				if env.Name != "" {
+					assignments = append(assignments, env.Name+"="+env.Value)
+				}
+			}
+			cmd = "exec env " + shellEscape(assignments) + " " + shellEscape(argv)
+		}
+		if sidecar.WorkingDir != "" {
+			cmd = "cd " + shellEscapePath(sidecar.WorkingDir) + " && " + cmd
+		}
+		stdout, stderr := SidecarLogPaths(taskDir, sidecar.Name)
+		fmt.Fprintf(&b, "( %s ) >>%s 2>>%s &\nSIDECAR_PIDS=\"$SIDECAR_PIDS $!\"\n",
+			cmd, shellEscapePath(stdout), shellEscapePath(stderr))
+	}
+	return b.String()
+}
+
+// sidecarStopScript returns the shim lines run once the main process has been
+// waited for. A SIGTERM interrupts the shim's first wait while the process is
+// still shutting down, so it is waited for again until it is gone; only then
+// are the sidecars sent SIGTERM, and SIGKILL after the grace period.
+func sidecarStopScript() string {
+	return fmt.Sprintf(`
+while kill -0 "$CHILD_PID" 2>/dev/null; do
+    wait "$CHILD_PID"
+    EXIT_CODE=$?
+done
+for pid in $SIDECAR_PIDS; do
+    kill -TERM "$pid" 2>/dev/null
+done
+( sleep %d; for pid in $SIDECAR_PIDS; do kill -KILL "$pid" 2>/dev/null; done ) &
+REAPER_PID=$!
+for pid in $SIDECAR_PIDS; do
+    wait "$pid"
+done
+kill "$REAPER_PID" 2>/dev/null
+`, sidecarStopGracePeriod)
+}
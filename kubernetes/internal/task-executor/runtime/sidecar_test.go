@@ -0,0 +1,139 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/task-executor/types"
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/task-executor/utils"
+	api "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
+)
+
+// sidecarScript writes its PID and "up" to marker, then runs until SIGTERM,
+// on which it prints "stopped".
+const sidecarScript = `echo $$ > "$MARKER"; echo "up $ROLE"; trap 'echo stopped; exit 0' TERM; while true; do sleep 0.1; done`
+
+func startSidecarTask(t *testing.T, main string) (Executor, *types.Task, string) {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	executor, _ := setupTestExecutor(t)
+	pExecutor := executor.(*processExecutor)
+	task := &types.Task{Name: "with-sidecar"}
+	taskDir, err := utils.SafeJoin(pExecutor.rootDir, task.Name)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(taskDir, 0755))
+
+	marker := filepath.Join(taskDir, "sidecar.pid")
+	task.Process = &api.Process{
+		Command: []string{"/bin/sh", "-c", main},
+		Env:     []corev1.EnvVar{{Name: "MARKER", Value: marker}},
+	}
+	task.Sidecars = []api.Sidecar{{
+		Name:       "log-shipper",
+		Command:    []string{"/bin/sh", "-c"},
+		Args:       []string{sidecarScript},
+		Env:        []corev1.EnvVar{{Name: "ROLE", Value: "shipper"}},
+		WorkingDir: taskDir,
+	}}
+	require.NoError(t, executor.Start(context.Background(), task))
+	return executor, task, taskDir
+}
+
+func waitForState(t *testing.T, executor Executor, task *types.Task, want types.TaskState) *types.Status {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		status, err := executor.Inspect(context.Background(), task)
+		require.NoError(t, err)
+		if status.State == want {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("task state = %s, want %s", status.State, want)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func sidecarPID(t *testing.T, taskDir string) int {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(taskDir, "sidecar.pid"))
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	require.NoError(t, err)
+	return pid
+}
+
+func TestProcessExecutor_SidecarSharesLifecycle(t *testing.T) {
+	// The main process only succeeds once it sees the sidecar running.
+	executor, task, taskDir := startSidecarTask(t,
+		`for i in $(seq 50); do [ -s "$MARKER" ] && exit 0; sleep 0.1; done; exit 3`)
+
+	status := waitForState(t, executor, task, types.TaskStateSucceeded)
+	assert.Equal(t, 0, status.SubStatuses[0].ExitCode)
+
+	// The exit code is recorded only after the sidecar has been stopped.
+	assert.False(t, isProcessRunning(sidecarPID(t, taskDir)), "sidecar outlived the task")
+	stdout, stderr := SidecarLogPaths(taskDir, "log-shipper")
+	out, err := os.ReadFile(stdout)
+	require.NoError(t, err)
+	assert.Equal(t, "up shipper\nstopped\n", string(out))
+	_, err = os.Stat(stderr)
+	assert.NoError(t, err)
+}
+
+func TestProcessExecutor_StopStopsSidecars(t *testing.T) {
+	executor, task, taskDir := startSidecarTask(t, `sleep 30`)
+	waitForState(t, executor, task, types.TaskStateRunning)
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(taskDir, "sidecar.pid"))
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+	pid := sidecarPID(t, taskDir)
+	assert.True(t, isProcessRunning(pid))
+
+	require.NoError(t, executor.Stop(context.Background(), task))
+	assert.Eventually(t, func() bool { return !isProcessRunning(pid) }, 5*time.Second, 50*time.Millisecond)
+	stdout, _ := SidecarLogPaths(taskDir, "log-shipper")
+	out, err := os.ReadFile(stdout)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "stopped")
+}
+
+func TestSidecarLaunchScript(t *testing.T) {
+	script := sidecarLaunchScript("/data/task", []api.Sidecar{
+		{Name: "metrics", Command: []string{"agent"}, Args: []string{"--port", "9100"}},
+		{Name: "logs", Command: []string{"ship"}, Env: []corev1.EnvVar{{Name: "TARGET", Value: "it's"}}, WorkingDir: "/var/log"},
+	})
+	assert.Equal(t, `( exec 'agent' '--port' '9100' ) >>'/data/task/sidecar-metrics.stdout.log' 2>>'/data/task/sidecar-metrics.stderr.log' &
+SIDECAR_PIDS="$SIDECAR_PIDS $!"
+( cd '/var/log' && exec env 'TARGET=it'\''s' 'ship' ) >>'/data/task/sidecar-logs.stdout.log' 2>>'/data/task/sidecar-logs.stderr.log' &
+SIDECAR_PIDS="$SIDECAR_PIDS $!"
+`, script)
+}
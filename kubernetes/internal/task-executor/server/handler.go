@@ -72,6 +72,16 @@ func (h *Handler) CreateTask(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "task name is required")
 		return
 	}
+	if apiTask.Process != nil {
+		if err := api.ValidateStartupProbe(apiTask.Process.StartupProbe); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if err := api.ValidateSidecars(apiTask.Sidecars, apiTask.Process); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	// Convert to internal model
 	task := h.convertAPIToInternalTask(&apiTask)
@@ -249,9 +259,14 @@ func (h *Handler) convertAPIToInternalTask(apiTask *api.Task) *types.Task {
 		return nil
 	}
 	task := &types.Task{
-		Name:            apiTask.Name,
-		Process:         apiTask.Process,
-		PodTemplateSpec: apiTask.PodTemplateSpec,
+		Name:               apiTask.Name,
+		IdempotencyKey:     apiTask.IdempotencyKey,
+		ServiceAccountName: apiTask.ServiceAccountName,
+		PriorityClassName:  apiTask.PriorityClassName,
+		Annotations:        apiTask.Annotations,
+		Process:            apiTask.Process,
+		PodTemplateSpec:    apiTask.PodTemplateSpec,
+		Sidecars:           apiTask.Sidecars,
 	}
 	// Initialize default status
 	task.Status = types.Status{
@@ -268,9 +283,14 @@ func convertInternalToAPITask(task *types.Task) *api.Task {
 	}
 
 	apiTask := &api.Task{
-		Name:            task.Name,
-		Process:         task.Process,
-		PodTemplateSpec: task.PodTemplateSpec,
+		Name:               task.Name,
+		IdempotencyKey:     task.IdempotencyKey,
+		ServiceAccountName: task.ServiceAccountName,
+		PriorityClassName:  task.PriorityClassName,
+		Annotations:        task.Annotations,
+		Process:            task.Process,
+		PodTemplateSpec:    task.PodTemplateSpec,
+		Sidecars:           task.Sidecars,
 	}
 
 	// 1. Process Status Conversion
@@ -306,6 +326,7 @@ func convertInternalToAPITask(task *types.Task) *api.Task {
 			// Running
 			apiStatus.Running = &api.Running{
 				StartedAt: metav1.NewTime(*sub.StartedAt),
+				Ready:     sub.Ready,
 			}
 		} else {
 			// Waiting
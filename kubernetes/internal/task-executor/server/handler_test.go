@@ -22,10 +22,14 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 
 	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/task-executor/config"
@@ -139,6 +143,26 @@ func TestHandler_CreateTask(t *testing.T) {
 	}
 }
 
+func TestHandler_CreateTask_Sidecars(t *testing.T) {
+	mgr := NewMockTaskManager()
+	h := NewHandler(mgr, &config.Config{})
+	create := func(task api.Task) int {
+		body, _ := json.Marshal(task)
+		w := httptest.NewRecorder()
+		h.CreateTask(w, httptest.NewRequest("POST", "/tasks", bytes.NewReader(body)))
+		return w.Code
+	}
+
+	sidecars := []api.Sidecar{{Name: "log-shipper", Command: []string{"fluent-bit"}}}
+	if code := create(api.Task{Name: "orphan-sidecars", Sidecars: sidecars}); code != http.StatusBadRequest {
+		t.Errorf("sidecars without a process: status %d, want 400", code)
+	}
+	if code := create(api.Task{Name: "with-sidecars", Process: &api.Process{Command: []string{"echo"}}, Sidecars: sidecars}); code != http.StatusCreated {
+		t.Fatalf("CreateTask returned status %d", code)
+	}
+	assert.Equal(t, sidecars, mgr.tasks["with-sidecars"].Sidecars)
+}
+
 func TestHandler_GetTask(t *testing.T) {
 	mgr := NewMockTaskManager()
 	mgr.tasks["test-task"] = &types.Task{Name: "test-task"}
@@ -427,3 +451,46 @@ func TestConvertInternalToAPITask_Timeout(t *testing.T) {
 		assert.Equal(t, later.Unix(), apiTask.ProcessStatus.Terminated.FinishedAt.Unix())
 	})
 }
+
+func TestHandler_GetTaskLogs(t *testing.T) {
+	dataDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dataDir, "failed-task"), 0755))
+	var stdout strings.Builder
+	for i := range 50 {
+		fmt.Fprintf(&stdout, "step %d\n", i)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "failed-task", "stdout.log"), []byte(stdout.String()), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "failed-task", "stderr.log"), []byte("Traceback\nValueError: bad shard"), 0644))
+	mgr := NewMockTaskManager()
+	mgr.tasks["failed-task"] = &types.Task{Name: "failed-task"}
+	router := NewRouter(NewHandler(mgr, &config.Config{DataDir: dataDir}))
+
+	get := func(target string) (*httptest.ResponseRecorder, api.TaskLogs) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		var logs api.TaskLogs
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&logs))
+		}
+		return w, logs
+	}
+
+	w, logs := get("/tasks/failed-task/logs?tailLines=3")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "step 47\nstep 48\nstep 49\n", logs.Stdout)
+	assert.Equal(t, "Traceback\nValueError: bad shard", logs.Stderr)
+	assert.True(t, logs.Truncated)
+
+	// the line cut by the byte limit is dropped
+	_, logs = get("/tasks/failed-task/logs?limitBytes=12")
+	assert.Equal(t, "step 49\n", logs.Stdout)
+	assert.Equal(t, "bad shard", logs.Stderr[len(logs.Stderr)-9:])
+
+	_, logs = get("/tasks/failed-task/logs")
+	assert.Equal(t, stdout.String(), logs.Stdout)
+
+	w, _ = get("/tasks/failed-task/logs?tailLines=-1")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w, _ = get("/tasks/missing/logs")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,145 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"k8s.io/klog/v2"
+
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/task-executor/runtime"
+	"github.com/alibaba/OpenSandbox/sandbox-k8s/internal/task-executor/utils"
+	api "github.com/alibaba/OpenSandbox/sandbox-k8s/pkg/task-executor"
+)
+
+const (
+	defaultLogTailLines  = 100
+	maxLogTailLines      = 10000
+	defaultLogLimitBytes = 64 << 10
+	maxLogLimitBytes     = 1 << 20
+)
+
+// GetTaskLogs returns the tail of a task's stdout and stderr, bounded by the
+// tailLines and limitBytes query parameters. A task without a process, or
+// one that wrote nothing yet, has empty logs.
+func (h *Handler) GetTaskLogs(w http.ResponseWriter, r *http.Request) {
+	if h.manager == nil || h.config == nil {
+		writeError(w, http.StatusInternalServerError, "task manager not initialized")
+		return
+	}
+
+	taskID := r.PathValue("id")
+	if taskID == "" {
+		writeError(w, http.StatusBadRequest, "task id is required")
+		return
+	}
+	tailLines, err := logQueryParam(r, "tailLines", defaultLogTailLines, maxLogTailLines)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limitBytes, err := logQueryParam(r, "limitBytes", defaultLogLimitBytes, maxLogLimitBytes)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := h.manager.Get(r.Context(), taskID); err != nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("task not found: %v", err))
+		return
+	}
+	taskDir, err := utils.SafeJoin(h.config.DataDir, taskID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid task id: %v", err))
+		return
+	}
+
+	response := api.TaskLogs{}
+	var truncated bool
+	for _, stream := range []struct {
+		file string
+		out  *string
+	}{{runtime.StdoutFile, &response.Stdout}, {runtime.StderrFile, &response.Stderr}} {
+		*stream.out, truncated, err = tailFile(filepath.Join(taskDir, stream.file), tailLines, limitBytes)
+		if err != nil {
+			klog.ErrorS(err, "failed to read task logs", "id", taskID, "file", stream.file)
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to read %s: %v", stream.file, err))
+			return
+		}
+		response.Truncated = response.Truncated || truncated
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func logQueryParam(r *http.Request, name string, def, limit int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	}
+	return min(n, limit), nil
+}
+
+// tailFile returns the last lines of path, at most limitBytes of them, and
+// whether anything before them was left out. A line cut by limitBytes is
+// dropped unless it is the only one. A missing file reads as empty.
+func tailFile(path string, lines, limitBytes int) (string, bool, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", false, err
+	}
+	offset := max(info.Size()-int64(limitBytes), 0)
+	buf := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return "", false, err
+	}
+	truncated := offset > 0
+	if truncated {
+		if i := bytes.IndexByte(buf, '\n'); i >= 0 && i < len(buf)-1 {
+			buf = buf[i+1:]
+		}
+	}
+	// the final terminator does not start another line
+	body := bytes.TrimSuffix(buf, []byte{'\n'})
+	for i, n := len(body)-1, 0; i >= 0; i-- {
+		if body[i] != '\n' {
+			continue
+		}
+		if n++; n == lines {
+			return string(buf[i+1:]), true, nil
+		}
+	}
+	return string(buf), truncated, nil
+}
@@ -25,6 +25,7 @@ func NewRouter(h *Handler) http.Handler {
 	mux.HandleFunc("GET /getTasks", h.ListTasks)
 	mux.HandleFunc("POST /tasks", h.CreateTask)
 	mux.HandleFunc("GET /tasks/{id}", h.GetTask)
+	mux.HandleFunc("GET /tasks/{id}/logs", h.GetTaskLogs)
 	mux.HandleFunc("DELETE /tasks/{id}", h.DeleteTask)
 	mux.HandleFunc("GET /health", h.Health)
 
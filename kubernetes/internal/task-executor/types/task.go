@@ -43,10 +43,12 @@ type Status struct {
 }
 
 type SubStatus struct {
-	Name       string     `json:"name,omitempty"` // for process it's empty, for PodTemplateSpec is container name
-	Reason     string     `json:"reason,omitempty"`
-	Message    string     `json:"message,omitempty"`
-	ExitCode   int        `json:"exitCode,omitempty"`
+	Name     string `json:"name,omitempty"` // for process it's empty, for PodTemplateSpec is container name
+	Reason   string `json:"reason,omitempty"`
+	Message  string `json:"message,omitempty"`
+	ExitCode int    `json:"exitCode,omitempty"`
+	// Ready is set for a running process once its startup probe passed, or right away without one.
+	Ready      bool       `json:"ready,omitempty"`
 	StartedAt  *time.Time `json:"startedAt,omitempty"`
 	FinishedAt *time.Time `json:"finishedAt,omitempty"`
 }
@@ -54,9 +56,16 @@ type SubStatus struct {
 type Task struct {
 	Name              string     `json:"name"`
 	DeletionTimestamp *time.Time `json:"deletionTimestamp,omitempty"`
+	IdempotencyKey    string     `json:"idempotencyKey,omitempty"`
+
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	PriorityClassName  string `json:"priorityClassName,omitempty"`
+
+	Annotations map[string]string `json:"annotations,omitempty"`
 
 	Process         *api.Process            `json:"process"`
 	PodTemplateSpec *corev1.PodTemplateSpec `json:"podTemplateSpec"`
+	Sidecars        []api.Sidecar           `json:"sidecars,omitempty"`
 
 	// Status is now a first-class citizen and persisted.
 	Status Status `json:"status"`
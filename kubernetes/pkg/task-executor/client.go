@@ -21,6 +21,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"k8s.io/klog/v2"
@@ -145,3 +147,36 @@ func (c *Client) Get(ctx context.Context) (*Task, error) {
 	// No tasks
 	return nil, nil
 }
+
+// Logs retrieves the last tailLines lines of the named task's stdout and
+// stderr, at most limitBytes of each.
+func (c *Client) Logs(ctx context.Context, name string, tailLines, limitBytes int) (*TaskLogs, error) {
+	if c == nil {
+		return nil, fmt.Errorf("client is nil")
+	}
+
+	query := url.Values{}
+	query.Set("tailLines", strconv.Itoa(tailLines))
+	query.Set("limitBytes", strconv.Itoa(limitBytes))
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/tasks/"+url.PathEscape(name)+"/logs?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("network error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("server error: status=%d, body=%s", resp.StatusCode, string(body))
+	}
+
+	logs := &TaskLogs{}
+	if err := json.NewDecoder(resp.Body).Decode(logs); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return logs, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package task_executor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// AnnotationSpecHash is the task annotation carrying the SpecHash the task
+// was submitted with. The executor keeps it, so a controller can tell from a
+// task's status whether the executor already has the spec it wants.
+const AnnotationSpecHash = "task.opensandbox.io/spec-hash"
+
+// SpecHash returns a canonical hash of what task runs: its name, identity,
+// process, Pod template and sidecars. Status, annotations and the deletion
+// timestamp are left out, and env vars are compared by name, so the order
+// they were generated in does not matter.
+func SpecHash(task *Task) string {
+	if task == nil {
+		return ""
+	}
+	canonical := struct {
+		Name               string                  `json:"name"`
+		IdempotencyKey     string                  `json:"idempotencyKey,omitempty"`
+		ServiceAccountName string                  `json:"serviceAccountName,omitempty"`
+		PriorityClassName  string                  `json:"priorityClassName,omitempty"`
+		Process            *Process                `json:"process,omitempty"`
+		PodTemplateSpec    *corev1.PodTemplateSpec `json:"podTemplateSpec,omitempty"`
+		Sidecars           []Sidecar               `json:"sidecars,omitempty"`
+	}{
+		Name:               task.Name,
+		IdempotencyKey:     task.IdempotencyKey,
+		ServiceAccountName: task.ServiceAccountName,
+		PriorityClassName:  task.PriorityClassName,
+	}
+	if task.Process != nil {
+		process := *task.Process
+		process.Env = sortedEnv(process.Env)
+		canonical.Process = &process
+	}
+	if task.PodTemplateSpec != nil {
+		template := task.PodTemplateSpec.DeepCopy()
+		for i := range template.Spec.InitContainers {
+			template.Spec.InitContainers[i].Env = sortedEnv(template.Spec.InitContainers[i].Env)
+		}
+		for i := range template.Spec.Containers {
+			template.Spec.Containers[i].Env = sortedEnv(template.Spec.Containers[i].Env)
+		}
+		canonical.PodTemplateSpec = template
+	}
+	for _, sidecar := range task.Sidecars {
+		sidecar.Env = sortedEnv(sidecar.Env)
+		canonical.Sidecars = append(canonical.Sidecars, sidecar)
+	}
+	// struct fields marshal in declaration order and map keys sorted
+	data, err := json.Marshal(canonical)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// sortedEnv returns a copy of env sorted by name; entries sharing a name keep
+// their order.
+func sortedEnv(env []corev1.EnvVar) []corev1.EnvVar {
+	if len(env) == 0 {
+		return nil
+	}
+	sorted := append([]corev1.EnvVar(nil), env...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
@@ -0,0 +1,46 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package task_executor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSpecHash(t *testing.T) {
+	task := func(env ...corev1.EnvVar) *Task {
+		return &Task{Name: "shards-0", IdempotencyKey: "uid-0-0", Process: &Process{Command: []string{"python", "shard.py"}, Env: env}}
+	}
+	a, b := corev1.EnvVar{Name: "A", Value: "1"}, corev1.EnvVar{Name: "B", Value: "2"}
+
+	hash := SpecHash(task(a, b))
+	assert.Len(t, hash, 32)
+	assert.Equal(t, hash, SpecHash(task(a, b)), "identical specs hash alike")
+	assert.Equal(t, hash, SpecHash(task(b, a)), "env order is normalized")
+
+	annotated := task(a, b)
+	annotated.Annotations = map[string]string{AnnotationSpecHash: hash}
+	annotated.ProcessStatus = &ProcessStatus{Running: &Running{Ready: true}}
+	assert.Equal(t, hash, SpecHash(annotated), "status and annotations are not part of the spec")
+
+	changed := task(a, corev1.EnvVar{Name: "B", Value: "3"})
+	assert.NotEqual(t, hash, SpecHash(changed))
+	changed = task(a, b)
+	changed.Process.Args = []string{"--resume"}
+	assert.NotEqual(t, hash, SpecHash(changed))
+	assert.Equal(t, []corev1.EnvVar{b, a}, task(b, a).Process.Env, "hashing leaves the task alone")
+}
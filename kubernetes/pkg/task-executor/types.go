@@ -25,9 +25,28 @@ type Task struct {
 	Name              string       `json:"name"`
 	DeletionTimestamp *metav1.Time `json:"deletionTimestamp,omitempty"`
 
+	// IdempotencyKey identifies one submission of a shard's task, derived from the
+	// BatchSandbox UID, shard index and attempt. Submitting a task whose key is
+	// already present on the executor is a no-op.
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+
+	// ServiceAccountName is the ServiceAccount identity the task runs as.
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// PriorityClassName is the PriorityClass the task's shard is scheduled at.
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// Annotations are kept by the executor and returned with the task's status,
+	// e.g. AnnotationSpecHash.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
 	Process         *Process                `json:"process,omitempty"`
 	PodTemplateSpec *corev1.PodTemplateSpec `json:"podTemplateSpec,omitempty"`
 
+	// Sidecars are helper processes started before Process and stopped after it
+	// exits. They need Process.
+	Sidecars []Sidecar `json:"sidecars,omitempty"`
+
 	ProcessStatus *ProcessStatus    `json:"processStatus,omitempty"`
 	PodStatus     *corev1.PodStatus `json:"podStatus,omitempty"`
 }
@@ -43,6 +62,23 @@ type Process struct {
 	WorkingDir string `json:"workingDir,omitempty"`
 	// TimeoutSeconds process timeout seconds.
 	TimeoutSeconds *int64 `json:"timeoutSeconds,omitempty"`
+	// StartupProbe is run by the executor after the process starts; the process is
+	// ready once it passes and is stopped if it never does. Exec and HTTPGet only.
+	StartupProbe *corev1.Probe `json:"startupProbe,omitempty"`
+}
+
+// Sidecar is a helper process sharing the lifecycle of a task's process.
+type Sidecar struct {
+	// Name identifies the sidecar within the task and names its log files.
+	Name string `json:"name"`
+	// Command command
+	Command []string `json:"command"`
+	// Arguments to the entrypoint.
+	Args []string `json:"args,omitempty"`
+	// Environment variables set on top of the task's environment.
+	Env []corev1.EnvVar `json:"env,omitempty"`
+	// WorkingDir sidecar working directory.
+	WorkingDir string `json:"workingDir,omitempty"`
 }
 
 // ProcessStatus holds a possible state of process.
@@ -75,6 +111,9 @@ type Running struct {
 	// Time at which the process was last (re-)started
 	// +optional
 	StartedAt metav1.Time `json:"startedAt"`
+	// Ready reports whether the startup probe passed; always true without a probe.
+	// +optional
+	Ready bool `json:"ready,omitempty"`
 }
 
 // Terminated is a terminated state of a process.
@@ -97,3 +136,11 @@ type Terminated struct {
 	// +optional
 	FinishedAt metav1.Time `json:"finishedAt,omitempty"`
 }
+
+// TaskLogs is the tail of a task process's output.
+type TaskLogs struct {
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+	// Truncated is set when either stream had more output than was returned.
+	Truncated bool `json:"truncated,omitempty"`
+}
@@ -0,0 +1,77 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package task_executor
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ValidateStartupProbe rejects probes the executor cannot run. Only exec and
+// httpGet handlers with a numeric port are supported.
+func ValidateStartupProbe(probe *corev1.Probe) error {
+	if probe == nil {
+		return nil
+	}
+	switch {
+	case probe.Exec != nil:
+		if len(probe.Exec.Command) == 0 {
+			return fmt.Errorf("startup probe exec command is empty")
+		}
+	case probe.HTTPGet != nil:
+		if probe.HTTPGet.Port.Type != intstr.Int || probe.HTTPGet.Port.IntVal <= 0 {
+			return fmt.Errorf("startup probe httpGet port must be a port number, got %q", probe.HTTPGet.Port.String())
+		}
+	default:
+		return fmt.Errorf("startup probe needs an exec or httpGet handler")
+	}
+	return nil
+}
+
+// ValidateSidecars rejects sidecars without a process to accompany, without a
+// command, or whose names are not unique DNS labels; names end up in log file names.
+// Env entries may only take their value from a fieldRef, which is resolved
+// against the Pod; the executor has no other source to read.
+func ValidateSidecars(sidecars []Sidecar, process *Process) error {
+	if len(sidecars) == 0 {
+		return nil
+	}
+	if process == nil {
+		return fmt.Errorf("sidecars need a process task")
+	}
+	seen := make(map[string]bool, len(sidecars))
+	for i, sidecar := range sidecars {
+		if errs := validation.IsDNS1123Label(sidecar.Name); len(errs) > 0 {
+			return fmt.Errorf("sidecar %d name %q: %s", i, sidecar.Name, strings.Join(errs, ", "))
+		}
+		if seen[sidecar.Name] {
+			return fmt.Errorf("sidecar name %q is not unique", sidecar.Name)
+		}
+		seen[sidecar.Name] = true
+		if len(sidecar.Command) == 0 || sidecar.Command[0] == "" {
+			return fmt.Errorf("sidecar %q command is empty", sidecar.Name)
+		}
+		for _, env := range sidecar.Env {
+			if env.ValueFrom != nil && env.ValueFrom.FieldRef == nil {
+				return fmt.Errorf("sidecar %q env %s: only fieldRef is supported in valueFrom", sidecar.Name, env.Name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2025 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package task_executor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestValidateStartupProbe(t *testing.T) {
+	assert.NoError(t, ValidateStartupProbe(nil))
+	assert.NoError(t, ValidateStartupProbe(&corev1.Probe{ProbeHandler: corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"true"}}}}))
+	assert.NoError(t, ValidateStartupProbe(&corev1.Probe{ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Port: intstr.FromInt32(8080)}}}))
+	assert.Error(t, ValidateStartupProbe(&corev1.Probe{ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Port: intstr.FromString("http")}}}))
+	assert.Error(t, ValidateStartupProbe(&corev1.Probe{ProbeHandler: corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(80)}}}))
+	assert.Error(t, ValidateStartupProbe(&corev1.Probe{ProbeHandler: corev1.ProbeHandler{Exec: &corev1.ExecAction{}}}))
+}
+
+func TestValidateSidecars(t *testing.T) {
+	process := &Process{Command: []string{"python", "train.py"}}
+	shipper := Sidecar{Name: "log-shipper", Command: []string{"fluent-bit"}}
+	assert.NoError(t, ValidateSidecars(nil, nil))
+	assert.NoError(t, ValidateSidecars([]Sidecar{shipper, {Name: "metrics", Command: []string{"agent"}}}, process))
+	assert.Error(t, ValidateSidecars([]Sidecar{shipper}, nil))
+	assert.Error(t, ValidateSidecars([]Sidecar{shipper, shipper}, process))
+	assert.Error(t, ValidateSidecars([]Sidecar{{Name: "Log_Shipper", Command: []string{"fluent-bit"}}}, process))
+	assert.Error(t, ValidateSidecars([]Sidecar{{Name: "../escape", Command: []string{"fluent-bit"}}}, process))
+	assert.Error(t, ValidateSidecars([]Sidecar{{Name: "empty"}}, process))
+
+	withEnv := func(source *corev1.EnvVarSource) []Sidecar {
+		return []Sidecar{{Name: "agent", Command: []string{"agent"}, Env: []corev1.EnvVar{{Name: "V", ValueFrom: source}}}}
+	}
+	assert.NoError(t, ValidateSidecars(withEnv(&corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}), process))
+	err := ValidateSidecars(withEnv(&corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{Key: "token"}}), process)
+	assert.ErrorContains(t, err, "only fieldRef is supported")
+}